@@ -0,0 +1,218 @@
+package bosh
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	BoshCollectorDataSetId = "bosh_director"
+	DirectorDataType       = "director"
+	DeploymentsDataType    = "deployments"
+	StemcellsDataType      = "stemcells"
+	ReleasesDataType       = "releases"
+
+	VMStateCreated     = "created"
+	VMStateMissing     = "missing"
+	VMStateNotExpected = "not_expected"
+
+	RequestorFailureErrorFormat = "Failed retrieving %s"
+)
+
+type boshService interface {
+	Info() (Info, error)
+	Deployments() ([]Deployment, error)
+	Stemcells() ([]Stemcell, error)
+	Releases() ([]Release, error)
+	Instances(deploymentName string) ([]Instance, error)
+}
+
+type DataCollector struct {
+	logger      *log.Logger
+	service     boshService
+	directorURL string
+}
+
+type directorSummary struct {
+	Version string `json:"version"`
+	CPI     string `json:"cpi"`
+}
+
+type deploymentSummary struct {
+	Name           string          `json:"name"`
+	Releases       []NameVersion   `json:"releases"`
+	Stemcells      []NameVersion   `json:"stemcells"`
+	InstanceGroups []instanceGroup `json:"instance_groups"`
+}
+
+// instanceGroup counts the instances of a job. VM states are read from the
+// director database rather than the agents: a VM is created, missing when the
+// instance expects one but has none, or not expected, as for errands.
+type instanceGroup struct {
+	Name      string         `json:"name"`
+	Instances int            `json:"instances"`
+	VMStates  map[string]int `json:"vm_states"`
+}
+
+type stemcellSummary struct {
+	Name            string `json:"name"`
+	OperatingSystem string `json:"operating_system"`
+	Version         string `json:"version"`
+	Deployments     int    `json:"deployments"`
+}
+
+type releaseSummary struct {
+	Name     string           `json:"name"`
+	Versions []releaseVersion `json:"versions"`
+}
+
+type releaseVersion struct {
+	Version           string `json:"version"`
+	CurrentlyDeployed bool   `json:"currently_deployed"`
+}
+
+func NewDataCollector(logger *log.Logger, service boshService, directorURL string) *DataCollector {
+	return &DataCollector{
+		logger:      logger,
+		service:     service,
+		directorURL: directorURL,
+	}
+}
+
+func (dc *DataCollector) Collect() ([]Data, error) {
+	dc.logger.Printf("Collecting data from BOSH director at %s", dc.directorURL)
+
+	info, err := dc.service.Info()
+	if err != nil {
+		return []Data{}, errors.Wrapf(err, RequestorFailureErrorFormat, InfoPath)
+	}
+
+	deployments, err := dc.deployments()
+	if err != nil {
+		return []Data{}, err
+	}
+
+	stemcells, err := dc.service.Stemcells()
+	if err != nil {
+		return []Data{}, errors.Wrapf(err, RequestorFailureErrorFormat, StemcellsPath)
+	}
+
+	releases, err := dc.service.Releases()
+	if err != nil {
+		return []Data{}, errors.Wrapf(err, RequestorFailureErrorFormat, ReleasesPath)
+	}
+
+	summaries := []struct {
+		dataType string
+		content  interface{}
+	}{
+		{DirectorDataType, directorSummary{Version: info.Version, CPI: info.CPI}},
+		{DeploymentsDataType, deployments},
+		{StemcellsDataType, summarizeStemcells(stemcells)},
+		{ReleasesDataType, summarizeReleases(releases)},
+	}
+
+	var data []Data
+	for _, summary := range summaries {
+		contents, err := json.Marshal(summary.content)
+		if err != nil {
+			return []Data{}, err
+		}
+		data = append(data, NewData(bytes.NewReader(contents), summary.dataType))
+	}
+	return data, nil
+}
+
+func (dc *DataCollector) deployments() ([]deploymentSummary, error) {
+	deployments, err := dc.service.Deployments()
+	if err != nil {
+		return nil, errors.Wrapf(err, RequestorFailureErrorFormat, DeploymentsPath)
+	}
+
+	summaries := []deploymentSummary{}
+	for _, deployment := range deployments {
+		instances, err := dc.service.Instances(deployment.Name)
+		if err != nil {
+			return nil, errors.Wrapf(err, RequestorFailureErrorFormat, deployment.Name+" instances")
+		}
+
+		summary := deploymentSummary{
+			Name:           deployment.Name,
+			Releases:       deployment.Releases,
+			Stemcells:      deployment.Stemcells,
+			InstanceGroups: summarizeInstanceGroups(instances),
+		}
+		if summary.Releases == nil {
+			summary.Releases = []NameVersion{}
+		}
+		if summary.Stemcells == nil {
+			summary.Stemcells = []NameVersion{}
+		}
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Name < summaries[j].Name })
+	return summaries, nil
+}
+
+func summarizeInstanceGroups(instances []Instance) []instanceGroup {
+	groups := map[string]*instanceGroup{}
+	for _, instance := range instances {
+		if groups[instance.Job] == nil {
+			groups[instance.Job] = &instanceGroup{Name: instance.Job, VMStates: map[string]int{}}
+		}
+		group := groups[instance.Job]
+		group.Instances++
+
+		switch {
+		case !instance.ExpectsVM:
+			group.VMStates[VMStateNotExpected]++
+		case instance.CID == "":
+			group.VMStates[VMStateMissing]++
+		default:
+			group.VMStates[VMStateCreated]++
+		}
+	}
+
+	result := []instanceGroup{}
+	for _, group := range groups {
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+func summarizeStemcells(stemcells []Stemcell) []stemcellSummary {
+	result := []stemcellSummary{}
+	for _, stemcell := range stemcells {
+		result = append(result, stemcellSummary{
+			Name:            stemcell.Name,
+			OperatingSystem: stemcell.OperatingSystem,
+			Version:         stemcell.Version,
+			Deployments:     len(stemcell.Deployments),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].Version < result[j].Version
+	})
+	return result
+}
+
+func summarizeReleases(releases []Release) []releaseSummary {
+	result := []releaseSummary{}
+	for _, release := range releases {
+		summary := releaseSummary{Name: release.Name, Versions: []releaseVersion{}}
+		for _, version := range release.ReleaseVersions {
+			summary.Versions = append(summary.Versions, releaseVersion{Version: version.Version, CurrentlyDeployed: version.CurrentlyDeployed})
+		}
+		result = append(result, summary)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
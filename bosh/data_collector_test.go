@@ -0,0 +1,157 @@
+package bosh_test
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotal-cf/aqueduct-courier/bosh"
+)
+
+var _ = Describe("DataCollector", func() {
+	var (
+		logger         *log.Logger
+		bufferedOutput *gbytes.Buffer
+		director       *httptest.Server
+		responses      map[string]string
+		dataCollector  *DataCollector
+	)
+
+	BeforeEach(func() {
+		bufferedOutput = gbytes.NewBuffer()
+		logger = log.New(bufferedOutput, "", 0)
+
+		responses = map[string]string{
+			InfoPath: `{"name": "secret-director-name", "uuid": "some-uuid", "version": "280.0.14 (00000000)", "cpi": "vsphere_cpi", "user_authentication": {"type": "uaa", "options": {"url": "https://10.0.0.6:8443"}}}`,
+			DeploymentsPath: `[
+				{"name": "pivotal-mysql-xyz", "releases": [{"name": "pxc", "version": "1.0.0"}], "stemcells": [{"name": "bosh-vsphere-esxi-ubuntu-jammy-go_agent", "version": "1.351"}]},
+				{"name": "cf-abc123", "releases": [{"name": "capi", "version": "1.2.3"}, {"name": "routing", "version": "0.280.0"}], "stemcells": [{"name": "bosh-vsphere-esxi-ubuntu-jammy-go_agent", "version": "1.340"}]}
+			]`,
+			"/deployments/cf-abc123/instances": `[
+				{"job": "router", "id": "router-1", "cid": "vm-1", "expects_vm": true, "ips": ["10.0.0.10"]},
+				{"job": "router", "id": "router-2", "cid": "", "expects_vm": true},
+				{"job": "smoke_tests", "id": "errand-1", "cid": "", "expects_vm": false}
+			]`,
+			"/deployments/pivotal-mysql-xyz/instances": `[]`,
+			StemcellsPath: `[
+				{"name": "bosh-vsphere-esxi-ubuntu-jammy-go_agent", "operating_system": "ubuntu-jammy", "version": "1.351", "cid": "sc-1", "deployments": [{"name": "pivotal-mysql-xyz"}]},
+				{"name": "bosh-vsphere-esxi-ubuntu-jammy-go_agent", "operating_system": "ubuntu-jammy", "version": "1.340", "cid": "sc-2", "deployments": [{"name": "cf-abc123"}]},
+				{"name": "bosh-vsphere-esxi-ubuntu-jammy-go_agent", "operating_system": "ubuntu-jammy", "version": "1.300", "cid": "sc-3", "deployments": []}
+			]`,
+			ReleasesPath: `[
+				{"name": "routing", "release_versions": [{"version": "0.280.0", "commit_hash": "abc", "currently_deployed": true}]},
+				{"name": "capi", "release_versions": [{"version": "1.2.3", "currently_deployed": true}, {"version": "1.2.2", "currently_deployed": false}]}
+			]`,
+		}
+		director = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			response, ok := responses[req.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			_, _ = w.Write([]byte(response))
+		}))
+
+		directorURL, err := url.Parse(director.URL)
+		Expect(err).NotTo(HaveOccurred())
+		dataCollector = NewDataCollector(logger, &Service{BaseURL: directorURL, Client: director.Client()}, director.URL)
+	})
+
+	AfterEach(func() {
+		director.Close()
+	})
+
+	readData := func(data []Data, dataType string) string {
+		for _, d := range data {
+			if d.DataType() == dataType {
+				content, err := io.ReadAll(d.Content())
+				Expect(err).NotTo(HaveOccurred())
+				return string(content)
+			}
+		}
+		Fail(fmt.Sprintf("no %s data", dataType))
+		return ""
+	}
+
+	It("records the director version and CPI", func() {
+		collectedData, err := dataCollector.Collect()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(bufferedOutput).To(gbytes.Say(fmt.Sprintf("Collecting data from BOSH director at %s", director.URL)))
+		Expect(collectedData).To(HaveLen(4))
+		Expect(readData(collectedData, DirectorDataType)).To(MatchJSON(`{"version": "280.0.14 (00000000)", "cpi": "vsphere_cpi"}`))
+	})
+
+	It("records the releases, stemcells and instance groups of each deployment", func() {
+		collectedData, err := dataCollector.Collect()
+		Expect(err).NotTo(HaveOccurred())
+
+		deployments := readData(collectedData, DeploymentsDataType)
+		Expect(deployments).To(MatchJSON(`[
+			{
+				"name": "cf-abc123",
+				"releases": [{"name": "capi", "version": "1.2.3"}, {"name": "routing", "version": "0.280.0"}],
+				"stemcells": [{"name": "bosh-vsphere-esxi-ubuntu-jammy-go_agent", "version": "1.340"}],
+				"instance_groups": [
+					{"name": "router", "instances": 2, "vm_states": {"created": 1, "missing": 1}},
+					{"name": "smoke_tests", "instances": 1, "vm_states": {"not_expected": 1}}
+				]
+			},
+			{
+				"name": "pivotal-mysql-xyz",
+				"releases": [{"name": "pxc", "version": "1.0.0"}],
+				"stemcells": [{"name": "bosh-vsphere-esxi-ubuntu-jammy-go_agent", "version": "1.351"}],
+				"instance_groups": []
+			}
+		]`))
+		Expect(deployments).NotTo(ContainSubstring("10.0.0.10"))
+	})
+
+	It("records the uploaded stemcells and releases", func() {
+		collectedData, err := dataCollector.Collect()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(readData(collectedData, StemcellsDataType)).To(MatchJSON(`[
+			{"name": "bosh-vsphere-esxi-ubuntu-jammy-go_agent", "operating_system": "ubuntu-jammy", "version": "1.300", "deployments": 0},
+			{"name": "bosh-vsphere-esxi-ubuntu-jammy-go_agent", "operating_system": "ubuntu-jammy", "version": "1.340", "deployments": 1},
+			{"name": "bosh-vsphere-esxi-ubuntu-jammy-go_agent", "operating_system": "ubuntu-jammy", "version": "1.351", "deployments": 1}
+		]`))
+		Expect(readData(collectedData, ReleasesDataType)).To(MatchJSON(`[
+			{"name": "capi", "versions": [{"version": "1.2.3", "currently_deployed": true}, {"version": "1.2.2", "currently_deployed": false}]},
+			{"name": "routing", "versions": [{"version": "0.280.0", "currently_deployed": true}]}
+		]`))
+	})
+
+	It("does not record the director name", func() {
+		collectedData, err := dataCollector.Collect()
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, d := range collectedData {
+			content, err := io.ReadAll(d.Content())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).NotTo(ContainSubstring("secret-director-name"))
+		}
+	})
+
+	itFailsWhenUnreadable := func(endpointPath, failureName string) {
+		It(fmt.Sprintf("returns an error when %s cannot be read", endpointPath), func() {
+			delete(responses, endpointPath)
+
+			collectedData, err := dataCollector.Collect()
+			Expect(collectedData).To(BeEmpty())
+			Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf(RequestorFailureErrorFormat, failureName))))
+		})
+	}
+	itFailsWhenUnreadable(InfoPath, InfoPath)
+	itFailsWhenUnreadable(DeploymentsPath, DeploymentsPath)
+	itFailsWhenUnreadable("/deployments/cf-abc123/instances", "cf-abc123 instances")
+	itFailsWhenUnreadable(StemcellsPath, StemcellsPath)
+	itFailsWhenUnreadable(ReleasesPath, ReleasesPath)
+})
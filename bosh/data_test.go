@@ -0,0 +1,37 @@
+package bosh_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotal-cf/aqueduct-courier/bosh"
+)
+
+var _ = Describe("Data", func() {
+	It("returns the data type for the name", func() {
+		d := NewData(strings.NewReader(""), DeploymentsDataType)
+		Expect(d.Name()).To(Equal(DeploymentsDataType))
+	})
+
+	It("returns content for the data", func() {
+		dataReader := strings.NewReader("best-data")
+		d := NewData(dataReader, DeploymentsDataType)
+		Expect(d.Content()).To(Equal(dataReader))
+	})
+
+	It("returns json as the mime type", func() {
+		d := NewData(nil, DeploymentsDataType)
+		Expect(d.MimeType()).To(Equal("application/json"))
+	})
+
+	It("returns an empty product type", func() {
+		d := NewData(nil, DeploymentsDataType)
+		Expect(d.Type()).To(Equal(""))
+	})
+
+	It("returns the data type", func() {
+		d := NewData(nil, DeploymentsDataType)
+		Expect(d.DataType()).To(Equal(DeploymentsDataType))
+	})
+})
@@ -0,0 +1,150 @@
+package bosh
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	InfoPath                = "/info"
+	DeploymentsPath         = "/deployments"
+	StemcellsPath           = "/stemcells"
+	ReleasesPath            = "/releases"
+	DeploymentInstancesPath = "/deployments/%s/instances"
+
+	DirectorPort = "25555"
+
+	CreateDirectorHTTPRequestError              = "error creating HTTP request to BOSH director endpoint"
+	DirectorRequestErrorFormat                  = "error accessing BOSH director endpoint %s"
+	DirectorUnexpectedResponseStatusErrorFormat = "unexpected status %d when accessing BOSH director endpoint %s"
+	ReadResponseError                           = "error reading response"
+	UnmarshalResponseErrorFormat                = "error unmarshalling response from %s"
+)
+
+type httpClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// Service reads from the BOSH director API
+type Service struct {
+	BaseURL *url.URL
+	Client  httpClient
+}
+
+type Info struct {
+	Version            string `json:"version"`
+	CPI                string `json:"cpi"`
+	UserAuthentication struct {
+		Type    string `json:"type"`
+		Options struct {
+			URL string `json:"url"`
+		} `json:"options"`
+	} `json:"user_authentication"`
+}
+
+type NameVersion struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type Deployment struct {
+	Name      string        `json:"name"`
+	Releases  []NameVersion `json:"releases"`
+	Stemcells []NameVersion `json:"stemcells"`
+}
+
+type Stemcell struct {
+	Name            string `json:"name"`
+	OperatingSystem string `json:"operating_system"`
+	Version         string `json:"version"`
+	Deployments     []struct {
+		Name string `json:"name"`
+	} `json:"deployments"`
+}
+
+type Release struct {
+	Name            string `json:"name"`
+	ReleaseVersions []struct {
+		Version           string `json:"version"`
+		CurrentlyDeployed bool   `json:"currently_deployed"`
+	} `json:"release_versions"`
+}
+
+type Instance struct {
+	Job       string `json:"job"`
+	ID        string `json:"id"`
+	CID       string `json:"cid"`
+	ExpectsVM bool   `json:"expects_vm"`
+}
+
+// DirectorURL returns the URL of the director API on the BOSH environment host
+func DirectorURL(host string) *url.URL {
+	return &url.URL{Scheme: "https", Host: host + ":" + DirectorPort}
+}
+
+// Info reads the director version, CPI and UAA URL. It does not require
+// authentication.
+func (s *Service) Info() (Info, error) {
+	var info Info
+	err := s.get(InfoPath, &info)
+	return info, err
+}
+
+func (s *Service) Deployments() ([]Deployment, error) {
+	var deployments []Deployment
+	err := s.get(DeploymentsPath, &deployments)
+	return deployments, err
+}
+
+func (s *Service) Stemcells() ([]Stemcell, error) {
+	var stemcells []Stemcell
+	err := s.get(StemcellsPath, &stemcells)
+	return stemcells, err
+}
+
+func (s *Service) Releases() ([]Release, error) {
+	var releases []Release
+	err := s.get(ReleasesPath, &releases)
+	return releases, err
+}
+
+// Instances lists the instances of a deployment without querying their
+// agents, so it does not start a director task
+func (s *Service) Instances(deploymentName string) ([]Instance, error) {
+	var instances []Instance
+	err := s.get(fmt.Sprintf(DeploymentInstancesPath, deploymentName), &instances)
+	return instances, err
+}
+
+func (s *Service) get(endpointPath string, target interface{}) error {
+	targetURL := *s.BaseURL
+	targetURL.Path = path.Join(targetURL.Path, endpointPath)
+
+	req, err := http.NewRequest(http.MethodGet, targetURL.String(), nil)
+	if err != nil {
+		return errors.Wrap(err, CreateDirectorHTTPRequestError)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, DirectorRequestErrorFormat, endpointPath)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf(DirectorUnexpectedResponseStatusErrorFormat, resp.StatusCode, endpointPath)
+	}
+
+	contents, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, ReadResponseError)
+	}
+
+	return errors.Wrapf(json.Unmarshal(contents, target), UnmarshalResponseErrorFormat, endpointPath)
+}
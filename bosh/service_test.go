@@ -0,0 +1,119 @@
+package bosh_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotal-cf/aqueduct-courier/bosh"
+)
+
+var _ = Describe("Service", func() {
+	var (
+		director  *httptest.Server
+		responses map[string]string
+		requested []string
+		service   *Service
+	)
+
+	BeforeEach(func() {
+		responses = map[string]string{
+			InfoPath:                           `{"name": "p-bosh", "version": "280.0.14 (00000000)", "cpi": "vsphere_cpi", "user_authentication": {"type": "uaa", "options": {"url": "https://10.0.0.6:8443"}}}`,
+			DeploymentsPath:                    `[{"name": "cf-abc123", "releases": [{"name": "capi", "version": "1.2.3"}], "stemcells": [{"name": "bosh-vsphere-esxi-ubuntu-jammy-go_agent", "version": "1.351"}]}]`,
+			StemcellsPath:                      `[{"name": "bosh-vsphere-esxi-ubuntu-jammy-go_agent", "operating_system": "ubuntu-jammy", "version": "1.351", "deployments": [{"name": "cf-abc123"}]}]`,
+			ReleasesPath:                       `[{"name": "capi", "release_versions": [{"version": "1.2.3", "currently_deployed": true}]}]`,
+			"/deployments/cf-abc123/instances": `[{"job": "router", "id": "some-id", "cid": "vm-1", "expects_vm": true}]`,
+		}
+		requested = nil
+		director = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			requested = append(requested, req.URL.Path)
+			response, ok := responses[req.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(response))
+		}))
+
+		directorURL, err := url.Parse(director.URL)
+		Expect(err).NotTo(HaveOccurred())
+		service = &Service{BaseURL: directorURL, Client: director.Client()}
+	})
+
+	AfterEach(func() {
+		director.Close()
+	})
+
+	It("reads the director info", func() {
+		info, err := service.Info()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Version).To(Equal("280.0.14 (00000000)"))
+		Expect(info.CPI).To(Equal("vsphere_cpi"))
+		Expect(info.UserAuthentication.Options.URL).To(Equal("https://10.0.0.6:8443"))
+	})
+
+	It("reads the deployments, stemcells and releases", func() {
+		deployments, err := service.Deployments()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deployments).To(Equal([]Deployment{{
+			Name:      "cf-abc123",
+			Releases:  []NameVersion{{Name: "capi", Version: "1.2.3"}},
+			Stemcells: []NameVersion{{Name: "bosh-vsphere-esxi-ubuntu-jammy-go_agent", Version: "1.351"}},
+		}}))
+
+		stemcells, err := service.Stemcells()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stemcells).To(HaveLen(1))
+		Expect(stemcells[0].OperatingSystem).To(Equal("ubuntu-jammy"))
+		Expect(stemcells[0].Deployments).To(HaveLen(1))
+
+		releases, err := service.Releases()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(releases).To(HaveLen(1))
+		Expect(releases[0].ReleaseVersions[0].CurrentlyDeployed).To(BeTrue())
+	})
+
+	It("reads the instances of a deployment", func() {
+		instances, err := service.Instances("cf-abc123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instances).To(Equal([]Instance{{Job: "router", ID: "some-id", CID: "vm-1", ExpectsVM: true}}))
+		Expect(requested).To(Equal([]string{"/deployments/cf-abc123/instances"}))
+	})
+
+	It("keeps the path of the director URL", func() {
+		service.BaseURL.Path = "/some-prefix"
+		responses["/some-prefix"+InfoPath] = responses[InfoPath]
+
+		_, err := service.Info()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requested).To(Equal([]string{"/some-prefix" + InfoPath}))
+	})
+
+	It("errors when the director returns an unexpected status", func() {
+		delete(responses, StemcellsPath)
+
+		_, err := service.Stemcells()
+		Expect(err).To(MatchError(fmt.Sprintf(DirectorUnexpectedResponseStatusErrorFormat, http.StatusNotFound, StemcellsPath)))
+	})
+
+	It("errors when the director cannot be reached", func() {
+		director.Close()
+
+		_, err := service.Releases()
+		Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf(DirectorRequestErrorFormat, ReleasesPath))))
+	})
+
+	It("errors when the response is not json", func() {
+		responses[DeploymentsPath] = `not-json`
+
+		_, err := service.Deployments()
+		Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf(UnmarshalResponseErrorFormat, DeploymentsPath))))
+	})
+
+	It("builds the director URL from the BOSH environment host", func() {
+		Expect(DirectorURL("10.0.0.5").String()).To(Equal("https://10.0.0.5:25555"))
+	})
+})
@@ -0,0 +1,162 @@
+package capacity
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+)
+
+const (
+	CapacityDataSetId = "capacity"
+	ModelDataType     = "capacity_model"
+
+	NoResourcesWarning          = "Warning: No product resources were collected, the capacity model is empty"
+	NoVmTypesWarning            = "Warning: The Ops Manager VM types were not collected, the capacity model has no vCPU, memory or ephemeral disk"
+	UnknownVmTypesWarningFormat = "Warning: VM types missing from the Ops Manager VM types are counted without vCPU, memory or ephemeral disk: %s"
+)
+
+// Totals are the resources used by a job, a product or the foundation. Disk
+// and memory sizes are in MB.
+type Totals struct {
+	Instances        int `json:"instances"`
+	VCPU             int `json:"vcpu"`
+	MemoryMB         int `json:"memory_mb"`
+	EphemeralDiskMB  int `json:"ephemeral_disk_mb"`
+	PersistentDiskMB int `json:"persistent_disk_mb"`
+}
+
+func (t *Totals) add(other Totals) {
+	t.Instances += other.Instances
+	t.VCPU += other.VCPU
+	t.MemoryMB += other.MemoryMB
+	t.EphemeralDiskMB += other.EphemeralDiskMB
+	t.PersistentDiskMB += other.PersistentDiskMB
+}
+
+// Job is the capacity used by the instances of a job. A job running a single
+// instance of a job the tile runs more of is a high availability risk.
+type Job struct {
+	Name   string `json:"job"`
+	VMType string `json:"vm_type"`
+	Totals
+	SupportedInstances int  `json:"supported_instances,omitempty"`
+	HARisk             bool `json:"ha_risk"`
+}
+
+type Product struct {
+	Product string `json:"product"`
+	Source  string `json:"source"`
+	Totals  Totals `json:"totals"`
+	Jobs    []Job  `json:"jobs"`
+}
+
+type HARisk struct {
+	Product            string `json:"product"`
+	Job                string `json:"job"`
+	Instances          int    `json:"instances"`
+	SupportedInstances int    `json:"supported_instances"`
+}
+
+type Model struct {
+	Totals         Totals    `json:"totals"`
+	Products       []Product `json:"products"`
+	HARisks        []HARisk  `json:"ha_risks"`
+	UnknownVMTypes []string  `json:"unknown_vm_types"`
+}
+
+// Analyzer joins the collected product resources with the Ops Manager VM
+// types into a capacity model
+type Analyzer struct {
+	logger *log.Logger
+}
+
+func NewAnalyzer(logger *log.Logger) *Analyzer {
+	return &Analyzer{logger: logger}
+}
+
+// Analyze returns the capacity model file for the collected files, keyed by
+// their path within the collection
+func (a *Analyzer) Analyze(files map[string][]byte) ([]Data, error) {
+	model, err := a.Model(files)
+	if err != nil {
+		return []Data{}, err
+	}
+
+	contents, err := json.Marshal(model)
+	if err != nil {
+		return []Data{}, err
+	}
+	return []Data{NewData(bytes.NewReader(contents), ModelDataType)}, nil
+}
+
+// Model totals the vCPU, memory and disk of every job with instances, and
+// lists the jobs at risk of losing availability. Warnings are logged for
+// data missing from the collection.
+func (a *Analyzer) Model(files map[string][]byte) (Model, error) {
+	products, err := productsResources(files)
+	if err != nil {
+		return Model{}, err
+	}
+	vmTypes, vmTypesCollected, err := readVmTypes(files)
+	if err != nil {
+		return Model{}, err
+	}
+
+	if len(products) == 0 {
+		a.logger.Print(NoResourcesWarning)
+	} else if !vmTypesCollected {
+		a.logger.Print(NoVmTypesWarning)
+	}
+
+	model := Model{Products: []Product{}, HARisks: []HARisk{}, UnknownVMTypes: []string{}}
+	unknownVMTypes := map[string]bool{}
+	for _, resources := range products {
+		product := Product{Product: resources.Product, Source: resources.Source, Jobs: []Job{}}
+		for _, resourcesJob := range resources.Jobs {
+			if resourcesJob.Instances <= 0 {
+				continue
+			}
+
+			job := Job{Name: resourcesJob.Name, VMType: resourcesJob.VMType, SupportedInstances: resourcesJob.SupportedInstances}
+			job.Instances = resourcesJob.Instances
+			job.PersistentDiskMB = resourcesJob.PersistentDiskMB * resourcesJob.Instances
+			if t, ok := vmTypes[resourcesJob.VMType]; ok {
+				job.VCPU = int(t.CPU) * resourcesJob.Instances
+				job.MemoryMB = int(t.RAM) * resourcesJob.Instances
+				job.EphemeralDiskMB = int(t.EphemeralDisk) * resourcesJob.Instances
+			} else if vmTypesCollected && resourcesJob.VMType != "" {
+				unknownVMTypes[resourcesJob.VMType] = true
+			}
+
+			job.HARisk = job.Instances == 1 && job.SupportedInstances > 1
+			if job.HARisk {
+				model.HARisks = append(model.HARisks, HARisk{Product: product.Product, Job: job.Name, Instances: job.Instances, SupportedInstances: job.SupportedInstances})
+			}
+
+			product.Totals.add(job.Totals)
+			product.Jobs = append(product.Jobs, job)
+		}
+		sort.Slice(product.Jobs, func(i, j int) bool { return product.Jobs[i].Name < product.Jobs[j].Name })
+
+		model.Totals.add(product.Totals)
+		model.Products = append(model.Products, product)
+	}
+
+	for name := range unknownVMTypes {
+		model.UnknownVMTypes = append(model.UnknownVMTypes, name)
+	}
+	sort.Strings(model.UnknownVMTypes)
+	if len(model.UnknownVMTypes) > 0 {
+		a.logger.Printf(UnknownVmTypesWarningFormat, strings.Join(model.UnknownVMTypes, ", "))
+	}
+	sort.SliceStable(model.HARisks, func(i, j int) bool {
+		if model.HARisks[i].Product != model.HARisks[j].Product {
+			return model.HARisks[i].Product < model.HARisks[j].Product
+		}
+		return model.HARisks[i].Job < model.HARisks[j].Job
+	})
+
+	return model, nil
+}
@@ -0,0 +1,135 @@
+package capacity_test
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotal-cf/aqueduct-courier/capacity"
+)
+
+var _ = Describe("Analyzer", func() {
+	var (
+		bufferedOutput *gbytes.Buffer
+		analyzer       *Analyzer
+		files          map[string][]byte
+	)
+
+	BeforeEach(func() {
+		bufferedOutput = gbytes.NewBuffer()
+		analyzer = NewAnalyzer(log.New(bufferedOutput, "", 0))
+
+		files = map[string][]byte{
+			VmTypesPath: []byte(`{"vm_types": [
+				{"name": "micro", "cpu": 1, "ram": 1024, "ephemeral_disk": 8192},
+				{"name": "large", "cpu": 2, "ram": 8192, "ephemeral_disk": 16384}
+			]}`),
+			"opsmanager/cf_resources": []byte(`{"resources": [
+				{"identifier": "router", "instances": 1, "instances_best_fit": 3, "instance_type_id": "", "instance_type_best_fit": "micro", "persistent_disk_mb": "0"},
+				{"identifier": "diego_cell", "instances": "automatic", "instances_best_fit": 3, "instance_type_id": "large", "instance_type_best_fit": "micro"},
+				{"identifier": "mysql", "instances": 1, "instances_best_fit": 1, "instance_type_id": "large", "persistent_disk_mb": "automatic", "persistent_disk_best_fit": "10240"},
+				{"identifier": "tcp_router", "instances": 0, "instances_best_fit": 1, "instance_type_id": "micro"}
+			]}`),
+			"opsmanager/p-redis_deployed_resources": []byte(`{"instance_groups": [
+				{"name": "redis", "instances": 1, "vm_type": "xlarge", "persistent_disk_type": "20480"}
+			]}`),
+			"opsmanager/ops_manager_diagnostic_report": []byte(`{}`),
+			"bosh/cf_resources":                        []byte(`not json`),
+		}
+	})
+
+	It("totals the capacity of each product and job with instances", func() {
+		model, err := analyzer.Model(files)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(model.Products).To(Equal([]Product{
+			{
+				Product: "cf",
+				Source:  StagedSource,
+				Totals:  Totals{Instances: 5, VCPU: 9, MemoryMB: 33792, EphemeralDiskMB: 73728, PersistentDiskMB: 10240},
+				Jobs: []Job{
+					{Name: "diego_cell", VMType: "large", Totals: Totals{Instances: 3, VCPU: 6, MemoryMB: 24576, EphemeralDiskMB: 49152}, SupportedInstances: 3},
+					{Name: "mysql", VMType: "large", Totals: Totals{Instances: 1, VCPU: 2, MemoryMB: 8192, EphemeralDiskMB: 16384, PersistentDiskMB: 10240}, SupportedInstances: 1},
+					{Name: "router", VMType: "micro", Totals: Totals{Instances: 1, VCPU: 1, MemoryMB: 1024, EphemeralDiskMB: 8192}, SupportedInstances: 3, HARisk: true},
+				},
+			},
+			{
+				Product: "p-redis",
+				Source:  DeployedSource,
+				Totals:  Totals{Instances: 1, PersistentDiskMB: 20480},
+				Jobs: []Job{
+					{Name: "redis", VMType: "xlarge", Totals: Totals{Instances: 1, PersistentDiskMB: 20480}},
+				},
+			},
+		}))
+		Expect(model.Totals).To(Equal(Totals{Instances: 6, VCPU: 9, MemoryMB: 33792, EphemeralDiskMB: 73728, PersistentDiskMB: 30720}))
+	})
+
+	It("flags single instance jobs the product supports more instances of", func() {
+		model, err := analyzer.Model(files)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(model.HARisks).To(Equal([]HARisk{
+			{Product: "cf", Job: "router", Instances: 1, SupportedInstances: 3},
+		}))
+	})
+
+	It("lists and warns about VM types missing from the Ops Manager VM types", func() {
+		model, err := analyzer.Model(files)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(model.UnknownVMTypes).To(Equal([]string{"xlarge"}))
+		Eventually(bufferedOutput).Should(gbytes.Say("xlarge"))
+	})
+
+	It("warns when the VM types were not collected", func() {
+		delete(files, VmTypesPath)
+
+		model, err := analyzer.Model(files)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(model.Totals).To(Equal(Totals{Instances: 6, PersistentDiskMB: 30720}))
+		Expect(model.UnknownVMTypes).To(BeEmpty())
+		Eventually(bufferedOutput).Should(gbytes.Say(NoVmTypesWarning))
+	})
+
+	It("warns when no product resources were collected", func() {
+		model, err := analyzer.Model(map[string][]byte{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(model.Products).To(BeEmpty())
+		Eventually(bufferedOutput).Should(gbytes.Say(NoResourcesWarning))
+	})
+
+	It("errors when product resources cannot be parsed", func() {
+		files["opsmanager/cf_resources"] = []byte(`not json`)
+
+		_, err := analyzer.Model(files)
+		Expect(err).To(MatchError(ContainSubstring("error parsing opsmanager/cf_resources")))
+	})
+
+	It("errors when the VM types cannot be parsed", func() {
+		files[VmTypesPath] = []byte(`not json`)
+
+		_, err := analyzer.Model(files)
+		Expect(err).To(MatchError(ContainSubstring("error parsing " + VmTypesPath)))
+	})
+
+	It("writes the model as the capacity model data", func() {
+		data, err := analyzer.Analyze(files)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(HaveLen(1))
+		Expect(data[0].Name()).To(Equal(ModelDataType))
+		Expect(data[0].DataType()).To(Equal(ModelDataType))
+
+		contents, err := io.ReadAll(data[0].Content())
+		Expect(err).NotTo(HaveOccurred())
+		var model map[string]interface{}
+		Expect(json.Unmarshal(contents, &model)).To(Succeed())
+		Expect(model).To(HaveKey("products"))
+		Expect(model["ha_risks"]).To(HaveLen(1))
+		Expect(model["totals"]).To(HaveKeyWithValue("vcpu", BeNumerically("==", 9)))
+	})
+})
@@ -0,0 +1,207 @@
+package capacity
+
+import (
+	"encoding/json"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pivotal-cf/aqueduct-courier/opsmanager"
+	"github.com/pivotal-cf/telemetry-utils/collector_tar"
+	"github.com/pkg/errors"
+)
+
+const (
+	UnmarshalSourceErrorFormat = "error parsing %s"
+
+	StagedSource   = "staged"
+	DeployedSource = "deployed"
+)
+
+var (
+	VmTypesPath = path.Join(collector_tar.OpsManagerCollectorDataSetId, opsmanager.NewData(nil, collector_tar.OpsManagerProductType, collector_tar.VmTypesDataType).Name())
+
+	stagedResourcesSuffix   = "_" + collector_tar.ResourcesDataType
+	deployedResourcesSuffix = "_" + opsmanager.DeployedResourcesDataType
+)
+
+type vmType struct {
+	Name          string  `json:"name"`
+	CPU           float64 `json:"cpu"`
+	RAM           float64 `json:"ram"`
+	EphemeralDisk float64 `json:"ephemeral_disk"`
+}
+
+// stagedResources is the staged configuration of a product's jobs. Values
+// may be numbers, numeric strings or "automatic", in which case the best fit
+// chosen by Ops Manager applies.
+type stagedResources struct {
+	Resources []struct {
+		Identifier            string      `json:"identifier"`
+		Instances             interface{} `json:"instances"`
+		InstancesBestFit      interface{} `json:"instances_best_fit"`
+		MaxInstances          interface{} `json:"max_instances"`
+		InstanceTypeID        string      `json:"instance_type_id"`
+		InstanceTypeBestFit   string      `json:"instance_type_best_fit"`
+		PersistentDiskMB      interface{} `json:"persistent_disk_mb"`
+		PersistentDiskBestFit interface{} `json:"persistent_disk_best_fit"`
+	} `json:"resources"`
+}
+
+type deployedResources struct {
+	InstanceGroups []struct {
+		Name               string `json:"name"`
+		Instances          int    `json:"instances"`
+		VMType             string `json:"vm_type"`
+		PersistentDiskType string `json:"persistent_disk_type"`
+		PersistentDisk     int    `json:"persistent_disk"`
+	} `json:"instance_groups"`
+}
+
+// jobResources are the instances, VM type and persistent disk size of a
+// job. SupportedInstances is the largest instance count the tile suggests or
+// allows, and is zero when unknown.
+type jobResources struct {
+	Name               string
+	Instances          int
+	VMType             string
+	PersistentDiskMB   int
+	SupportedInstances int
+}
+
+type productResources struct {
+	Product string
+	Source  string
+	Jobs    []jobResources
+}
+
+// productsResources reads the resources of every product collected, from the
+// staged configuration or the deployed manifests
+func productsResources(files map[string][]byte) ([]productResources, error) {
+	var products []productResources
+	for filePath, contents := range files {
+		dir, name := path.Split(filePath)
+		if path.Clean(dir) != collector_tar.OpsManagerCollectorDataSetId {
+			continue
+		}
+
+		var product productResources
+		var err error
+		switch {
+		case strings.HasSuffix(name, deployedResourcesSuffix):
+			product, err = readDeployedResources(strings.TrimSuffix(name, deployedResourcesSuffix), contents)
+		case strings.HasSuffix(name, stagedResourcesSuffix):
+			product, err = readStagedResources(strings.TrimSuffix(name, stagedResourcesSuffix), contents)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, UnmarshalSourceErrorFormat, filePath)
+		}
+		products = append(products, product)
+	}
+
+	sort.Slice(products, func(i, j int) bool { return products[i].Product < products[j].Product })
+	return products, nil
+}
+
+func readStagedResources(product string, contents []byte) (productResources, error) {
+	var resources stagedResources
+	if err := json.Unmarshal(contents, &resources); err != nil {
+		return productResources{}, err
+	}
+
+	result := productResources{Product: product, Source: StagedSource}
+	for _, resource := range resources.Resources {
+		bestFit, _ := number(resource.InstancesBestFit)
+		instances, ok := number(resource.Instances)
+		if !ok {
+			instances = bestFit
+		}
+		supported := bestFit
+		if maxInstances, ok := number(resource.MaxInstances); ok && maxInstances > supported {
+			supported = maxInstances
+		}
+
+		vmType := resource.InstanceTypeID
+		if vmType == "" {
+			vmType = resource.InstanceTypeBestFit
+		}
+
+		persistentDisk, ok := number(resource.PersistentDiskMB)
+		if !ok {
+			persistentDisk, _ = number(resource.PersistentDiskBestFit)
+		}
+
+		result.Jobs = append(result.Jobs, jobResources{
+			Name:               resource.Identifier,
+			Instances:          instances,
+			VMType:             vmType,
+			PersistentDiskMB:   persistentDisk,
+			SupportedInstances: supported,
+		})
+	}
+	return result, nil
+}
+
+// readDeployedResources reads the instance groups of a deployed manifest.
+// Ops Manager names its disk types by their size in MB, which is used when
+// the size is not given directly. Manifests do not say how many instances a
+// tile supports.
+func readDeployedResources(product string, contents []byte) (productResources, error) {
+	var resources deployedResources
+	if err := json.Unmarshal(contents, &resources); err != nil {
+		return productResources{}, err
+	}
+
+	result := productResources{Product: product, Source: DeployedSource}
+	for _, group := range resources.InstanceGroups {
+		persistentDisk := group.PersistentDisk
+		if persistentDisk == 0 {
+			persistentDisk, _ = number(group.PersistentDiskType)
+		}
+		result.Jobs = append(result.Jobs, jobResources{
+			Name:             group.Name,
+			Instances:        group.Instances,
+			VMType:           group.VMType,
+			PersistentDiskMB: persistentDisk,
+		})
+	}
+	return result, nil
+}
+
+func readVmTypes(files map[string][]byte) (map[string]vmType, bool, error) {
+	contents, ok := files[VmTypesPath]
+	if !ok {
+		return map[string]vmType{}, false, nil
+	}
+
+	// Ops Manager wraps the VM types in an object, a bare list is also read
+	var vmTypes struct {
+		VMTypes []vmType `json:"vm_types"`
+	}
+	if err := json.Unmarshal(contents, &vmTypes); err != nil {
+		if listErr := json.Unmarshal(contents, &vmTypes.VMTypes); listErr != nil {
+			return nil, false, errors.Wrapf(err, UnmarshalSourceErrorFormat, VmTypesPath)
+		}
+	}
+
+	byName := map[string]vmType{}
+	for _, t := range vmTypes.VMTypes {
+		byName[t.Name] = t
+	}
+	return byName, true, nil
+}
+
+// number reads a count or size given as a number or a numeric string
+func number(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case float64:
+		return int(v), true
+	case string:
+		parsed, err := strconv.Atoi(strings.TrimSpace(v))
+		return parsed, err == nil
+	}
+	return 0, false
+}
@@ -18,8 +18,17 @@ const (
 	CFApiUnmarshalError                      = "error unmarshaling response from CF API endpoint: %s"
 	CFApiUnexpectedResponseStatusErrorFormat = "unexpected status in CF API response: %d"
 	UAAEndpointEmptyError                    = "UAA url is empty"
+	UAALinkMissingError                      = "CF API root does not link to UAA"
+	UAADiscoveryErrorFormat                  = "could not discover UAA from the CF API root (%s) or /v2/info"
+
+	rootPath   = "/"
+	v2InfoPath = "/v2/info"
 )
 
+type link struct {
+	Href string `json:"href"`
+}
+
 type Client struct {
 	cfApiURL   string
 	httpClient httpClient
@@ -34,44 +43,86 @@ func NewClient(cfApiURL string, httpClient httpClient) *Client {
 	return &Client{cfApiURL: cfApiURL, httpClient: httpClient}
 }
 
+// GetUAAURL discovers UAA from the links of the CF API root, as served by the
+// v3 API. It falls back to the token_endpoint of /v2/info for foundations
+// whose root does not link to UAA.
 func (cl *Client) GetUAAURL() (string, error) {
 	cfApiURL, err := url.Parse(cl.cfApiURL)
 	if err != nil {
 		return "", errors.Wrapf(err, CfApiURLParsingError, cl.cfApiURL)
 	}
 
-	cfApiURL.Path = path.Join(cfApiURL.Path, "/v2/info")
-	req, err := http.NewRequest(http.MethodGet, cfApiURL.String(), nil)
-	if err != nil {
-		return "", errors.Wrap(err, CreateCfApiHTTPRequestError)
+	uaaURL, rootErr := cl.uaaURLFromRoot(*cfApiURL)
+	if rootErr == nil {
+		return uaaURL, nil
 	}
 
-	resp, err := cl.httpClient.Do(req)
+	uaaURL, err = cl.uaaURLFromV2Info(*cfApiURL)
 	if err != nil {
-		return "", errors.Wrapf(err, CfApiRequestError, cfApiURL.String())
+		return "", errors.Wrapf(err, UAADiscoveryErrorFormat, rootErr)
 	}
-	defer resp.Body.Close()
+	return uaaURL, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", errors.Errorf(CFApiUnexpectedResponseStatusErrorFormat, resp.StatusCode)
+func (cl *Client) uaaURLFromRoot(cfApiURL url.URL) (string, error) {
+	var rootResponse struct {
+		Links struct {
+			UAA   link `json:"uaa"`
+			Login link `json:"login"`
+		} `json:"links"`
+	}
+	if err := cl.getJSON(cfApiURL, rootPath, &rootResponse); err != nil {
+		return "", err
 	}
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", errors.Wrapf(err, CFApiReadResponseError, cfApiURL.String())
+	if rootResponse.Links.UAA.Href != "" {
+		return rootResponse.Links.UAA.Href, nil
 	}
+	if rootResponse.Links.Login.Href != "" {
+		return rootResponse.Links.Login.Href, nil
+	}
+	return "", errors.New(UAALinkMissingError)
+}
 
+func (cl *Client) uaaURLFromV2Info(cfApiURL url.URL) (string, error) {
 	var cfResponse struct {
 		TokenEndpoint string `json:"token_endpoint"`
 	}
-	err = json.Unmarshal(respBody, &cfResponse)
-	if err != nil {
-		return "", errors.Wrapf(err, CFApiUnmarshalError, cfApiURL.String())
+	if err := cl.getJSON(cfApiURL, v2InfoPath, &cfResponse); err != nil {
+		return "", err
 	}
 
 	if cfResponse.TokenEndpoint == "" {
 		return "", errors.New(UAAEndpointEmptyError)
 	}
-
 	return cfResponse.TokenEndpoint, nil
 }
+
+func (cl *Client) getJSON(endpointURL url.URL, endpointPath string, v interface{}) error {
+	endpointURL.Path = path.Join(endpointURL.Path, endpointPath)
+	req, err := http.NewRequest(http.MethodGet, endpointURL.String(), nil)
+	if err != nil {
+		return errors.Wrap(err, CreateCfApiHTTPRequestError)
+	}
+
+	resp, err := cl.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, CfApiRequestError, endpointURL.String())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf(CFApiUnexpectedResponseStatusErrorFormat, resp.StatusCode)
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrapf(err, CFApiReadResponseError, endpointURL.String())
+	}
+
+	err = json.Unmarshal(respBody, v)
+	if err != nil {
+		return errors.Wrapf(err, CFApiUnmarshalError, endpointURL.String())
+	}
+	return nil
+}
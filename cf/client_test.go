@@ -19,27 +19,57 @@ var _ = Describe("Client", func() {
 	const (
 		cfURL = "https://example.com/whatever"
 	)
+
 	var (
 		client         *Client
-		responseReader *readerCloser
+		rootResponse   *http.Response
+		v2InfoResponse *http.Response
 		fakeHTTPClient *cffakes.FakeHttpClient
 	)
 
+	jsonResponse := func(statusCode int, body string) *http.Response {
+		return &http.Response{Body: &readerCloser{reader: bytes.NewReader([]byte(body))}, StatusCode: statusCode}
+	}
+
 	BeforeEach(func() {
-		v2InfoResponse := `{"token_endpoint":"http://api.funstuff.com/uaa"}`
-		responseReader = &readerCloser{reader: bytes.NewReader([]byte(v2InfoResponse))}
+		rootResponse = jsonResponse(http.StatusOK, `{"links": {
+			"cloud_controller_v3": {"href": "https://example.com/whatever/v3"},
+			"login": {"href": "https://login.funstuff.com"},
+			"uaa": {"href": "https://uaa.funstuff.com"}
+		}}`)
+		v2InfoResponse = jsonResponse(http.StatusOK, `{"token_endpoint":"http://api.funstuff.com/uaa"}`)
 
 		fakeHTTPClient = &cffakes.FakeHttpClient{}
-		fakeHTTPClient.DoReturns(&http.Response{Body: responseReader, StatusCode: http.StatusOK}, nil)
+		fakeHTTPClient.DoStub = func(req *http.Request) (*http.Response, error) {
+			switch req.URL.Path {
+			case "/whatever":
+				return rootResponse, nil
+			case "/whatever/v2/info":
+				return v2InfoResponse, nil
+			}
+			return jsonResponse(http.StatusNotFound, ""), nil
+		}
 		client = NewClient(cfURL, fakeHTTPClient)
 	})
 
 	Describe("GetUAAURL", func() {
-		It("makes a request to UAA and retrieves the UAA url", func() {
+		It("retrieves the UAA url from the links of the CF API root", func() {
 			uaaURL, err := client.GetUAAURL()
 			Expect(err).NotTo(HaveOccurred())
-			Expect(uaaURL).To(Equal("http://api.funstuff.com/uaa"))
-			Expect(responseReader.isClosed).To(BeTrue())
+			Expect(uaaURL).To(Equal("https://uaa.funstuff.com"))
+
+			Expect(fakeHTTPClient.DoCallCount()).To(Equal(1))
+			Expect(fakeHTTPClient.DoArgsForCall(0).URL.String()).To(Equal(cfURL))
+			Expect(rootResponse.Body.(*readerCloser).isClosed).To(BeTrue())
+		})
+
+		It("uses the login link when the CF API root does not link to UAA", func() {
+			rootResponse = jsonResponse(http.StatusOK, `{"links": {"login": {"href": "https://login.funstuff.com"}}}`)
+
+			uaaURL, err := client.GetUAAURL()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(uaaURL).To(Equal("https://login.funstuff.com"))
+			Expect(fakeHTTPClient.DoCallCount()).To(Equal(1))
 		})
 
 		It("returns an error when the CF API URL is invalid", func() {
@@ -49,51 +79,79 @@ var _ = Describe("Client", func() {
 			Expect(err).To(MatchError(ContainSubstring("first path segment in URL cannot contain colon")))
 		})
 
-		It("returns an error when the request to the CF API endpoint fails", func() {
-			fakeHTTPClient.DoReturns(nil, errors.New("Requesting stuff is hard"))
+		Context("when the CF API root does not link to UAA", func() {
+			BeforeEach(func() {
+				rootResponse = jsonResponse(http.StatusOK, `{"links": {"self": {"href": "https://example.com/whatever"}}}`)
+			})
 
-			_, err := client.GetUAAURL()
+			It("falls back to the token endpoint from /v2/info", func() {
+				uaaURL, err := client.GetUAAURL()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(uaaURL).To(Equal("http://api.funstuff.com/uaa"))
 
-			Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf(CfApiRequestError, cfURL+"/v2/info"))))
-			Expect(err).To(MatchError(ContainSubstring("Requesting stuff is hard")))
-		})
+				Expect(fakeHTTPClient.DoCallCount()).To(Equal(2))
+				Expect(fakeHTTPClient.DoArgsForCall(1).URL.String()).To(Equal(cfURL + "/v2/info"))
+				Expect(v2InfoResponse.Body.(*readerCloser).isClosed).To(BeTrue())
+			})
 
-		It("returns an error when reading the response fails", func() {
-			responseReader := &readerCloser{reader: &badReader{}}
-			fakeHTTPClient.DoReturns(&http.Response{StatusCode: http.StatusOK, Body: responseReader}, nil)
+			It("includes why the root could not be used when /v2/info also fails", func() {
+				v2InfoResponse = jsonResponse(http.StatusNotFound, "")
 
-			_, err := client.GetUAAURL()
-			Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf(CFApiReadResponseError, cfURL+"/v2/info"))))
-			Expect(err).To(MatchError(ContainSubstring("Reading is hard")))
-			Expect(responseReader.isClosed).To(BeTrue())
+				_, err := client.GetUAAURL()
+				Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf(UAADiscoveryErrorFormat, UAALinkMissingError))))
+				Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf(CFApiUnexpectedResponseStatusErrorFormat, 404))))
+			})
 		})
 
-		It("returns an error when unmarshaling the response fails", func() {
-			responseReader = &readerCloser{reader: bytes.NewReader([]byte(`{"messed-up,"}`))}
-			fakeHTTPClient.DoReturns(&http.Response{Body: responseReader, StatusCode: http.StatusOK}, nil)
-
-			_, err := client.GetUAAURL()
-			Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf(CFApiUnmarshalError, cfURL+"/v2/info"))))
-			Expect(err).To(MatchError(ContainSubstring("invalid character '}' after object key")))
-			Expect(responseReader.isClosed).To(BeTrue())
-		})
-
-		It("returns an error when the response is not 200", func() {
-			fakeHTTPClient.DoReturns(&http.Response{Body: responseReader, StatusCode: http.StatusInternalServerError}, nil)
-			_, err := client.GetUAAURL()
-			Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf(CFApiUnexpectedResponseStatusErrorFormat, 500))))
-			Expect(responseReader.isClosed).To(BeTrue())
+		Context("when the CF API root cannot be read", func() {
+			BeforeEach(func() {
+				rootResponse = jsonResponse(http.StatusNotFound, "")
+			})
+
+			It("falls back to the token endpoint from /v2/info", func() {
+				uaaURL, err := client.GetUAAURL()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(uaaURL).To(Equal("http://api.funstuff.com/uaa"))
+			})
+
+			It("returns an error when the request to the CF API endpoint fails", func() {
+				fakeHTTPClient.DoStub = nil
+				fakeHTTPClient.DoReturns(nil, errors.New("Requesting stuff is hard"))
+				_, err := client.GetUAAURL()
+				Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf(CfApiRequestError, cfURL+"/v2/info"))))
+				Expect(err).To(MatchError(ContainSubstring("Requesting stuff is hard")))
+			})
+
+			It("returns an error when reading the response fails", func() {
+				responseReader := &readerCloser{reader: &badReader{}}
+				v2InfoResponse = &http.Response{StatusCode: http.StatusOK, Body: responseReader}
+				_, err := client.GetUAAURL()
+				Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf(CFApiReadResponseError, cfURL+"/v2/info"))))
+				Expect(err).To(MatchError(ContainSubstring("Reading is hard")))
+				Expect(responseReader.isClosed).To(BeTrue())
+			})
+
+			It("returns an error when unmarshaling the response fails", func() {
+				v2InfoResponse = jsonResponse(http.StatusOK, `{"messed-up,"}`)
+				_, err := client.GetUAAURL()
+				Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf(CFApiUnmarshalError, cfURL+"/v2/info"))))
+				Expect(err).To(MatchError(ContainSubstring("invalid character '}' after object key")))
+				Expect(v2InfoResponse.Body.(*readerCloser).isClosed).To(BeTrue())
+			})
+
+			It("returns an error when the response is not 200", func() {
+				v2InfoResponse = jsonResponse(http.StatusInternalServerError, "")
+				_, err := client.GetUAAURL()
+				Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf(CFApiUnexpectedResponseStatusErrorFormat, 500))))
+				Expect(v2InfoResponse.Body.(*readerCloser).isClosed).To(BeTrue())
+			})
+
+			It("returns an error if the UAA endpoint is empty", func() {
+				v2InfoResponse = jsonResponse(http.StatusOK, `{"token_endpoint":"", "other_non_string_prop": true}`)
+				_, err := client.GetUAAURL()
+				Expect(err).To(MatchError(ContainSubstring(UAAEndpointEmptyError)))
+			})
 		})
-
-		It("returns an error if the UAA endpoint is empty", func() {
-			responseReader = &readerCloser{reader: bytes.NewReader([]byte(`{"token_endpoint":"", "other_non_string_prop": true}`))}
-			fakeHTTPClient.DoReturns(&http.Response{Body: responseReader, StatusCode: http.StatusOK}, nil)
-
-			_, err := client.GetUAAURL()
-			Expect(err).To(MatchError(ContainSubstring(UAAEndpointEmptyError)))
-			Expect(responseReader.isClosed).To(BeTrue())
-		})
-
 	})
 })
 
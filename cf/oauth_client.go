@@ -5,18 +5,31 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"path"
 	"time"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/clientcredentials"
 )
 
+const (
+	TokenRefreshWindow        = time.Minute
+	UnauthorizedClientFormat  = "UAA at %s rejected the credentials of client %q"
+	TokenRequestErrorFormat   = "error retrieving token for client %q from %s: %s"
+	InvalidTargetURLFormat    = "could not parse target url: %s"
+	PerformRequestErrorFormat = "error performing request %s"
+	tokenPath                 = "/oauth/token"
+)
+
+// OAuthClient authenticates requests with a UAA client credentials token. The
+// token is fetched once and shared by copies of the client, so it is safe to
+// use from parallel requests. It is refreshed shortly before it expires.
 type OAuthClient struct {
-	oauthConfig   *oauth2.Config
-	oauthConfigCC *clientcredentials.Config
-	context       context.Context
-	target        string
-	timeout       time.Duration
+	clientID    string
+	tokenURL    string
+	tokenSource oauth2.TokenSource
+	httpClient  *http.Client
+	targetErr   error
 }
 
 type client interface {
@@ -24,43 +37,64 @@ type client interface {
 }
 
 func NewOAuthClient(target, clientID, clientSecret string, requestTimeout time.Duration, client client) OAuthClient {
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		return OAuthClient{clientID: clientID, targetErr: fmt.Errorf(InvalidTargetURLFormat, err)}
+	}
+	targetURL.Path = path.Join(targetURL.Path, tokenPath)
+
 	confCC := &clientcredentials.Config{
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
+		TokenURL:     targetURL.String(),
 	}
+	ctx := context.WithValue(context.TODO(), oauth2.HTTPClient, client)
+	tokenSource := oauth2.ReuseTokenSourceWithExpiry(nil, tokenSourceFunc(func() (*oauth2.Token, error) {
+		return confCC.Token(ctx)
+	}), TokenRefreshWindow)
 
-	return funcName(confCC, client, target, requestTimeout)
-}
+	base := http.DefaultTransport
+	if hc, ok := client.(*http.Client); ok && hc.Transport != nil {
+		base = hc.Transport
+	}
 
-func funcName(confCC *clientcredentials.Config, client client, target string, requestTimeout time.Duration) OAuthClient {
 	return OAuthClient{
-		oauthConfig:   &oauth2.Config{},
-		oauthConfigCC: confCC,
-		context:       context.WithValue(context.TODO(), oauth2.HTTPClient, client),
-		target:        target,
-		timeout:       requestTimeout,
+		clientID:    clientID,
+		tokenURL:    confCC.TokenURL,
+		tokenSource: tokenSource,
+		httpClient:  &http.Client{Transport: base, Timeout: requestTimeout},
 	}
 }
 
 func (oc OAuthClient) Do(request *http.Request) (*http.Response, error) {
-	var client *http.Client
+	if oc.targetErr != nil {
+		return nil, oc.targetErr
+	}
 
-	targetURL, err := url.Parse(oc.target)
+	token, err := oc.tokenSource.Token()
 	if err != nil {
-		return nil, fmt.Errorf("could not parse target url: %s", err)
+		return nil, oc.tokenError(err)
 	}
 
-	targetURL.Path = "/oauth/token"
-	oc.oauthConfigCC.TokenURL = targetURL.String()
-	oc.oauthConfig.Endpoint.TokenURL = targetURL.String()
-
-	client = oc.oauthConfigCC.Client(oc.context)
-	client.Timeout = oc.timeout
-
-	resp, err := client.Do(request)
+	authedRequest := request.Clone(request.Context())
+	token.SetAuthHeader(authedRequest)
 
+	resp, err := oc.httpClient.Do(authedRequest)
 	if err != nil {
-		return nil, fmt.Errorf("error performing request %s", err)
+		return nil, fmt.Errorf(PerformRequestErrorFormat, err)
 	}
 	return resp, err
 }
+
+func (oc OAuthClient) tokenError(err error) error {
+	if retrieveErr, ok := err.(*oauth2.RetrieveError); ok && retrieveErr.Response != nil && retrieveErr.Response.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf(UnauthorizedClientFormat, oc.tokenURL, oc.clientID)
+	}
+	return fmt.Errorf(TokenRequestErrorFormat, oc.clientID, oc.tokenURL, err)
+}
+
+type tokenSourceFunc func() (*oauth2.Token, error)
+
+func (f tokenSourceFunc) Token() (*oauth2.Token, error) {
+	return f()
+}
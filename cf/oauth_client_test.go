@@ -3,12 +3,14 @@ package cf_test
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/http/httputil"
 	"net/url"
 	"strings"
-
+	"sync"
+	"sync/atomic"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -83,6 +85,113 @@ var _ = Describe("OAuthClient", func() {
 			}))
 		})
 
+		It("requests the token below the base path of the target", func() {
+			var tokenPath string
+			uaaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				tokenPath = req.URL.Path
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"access_token": "some-token", "token_type": "bearer", "expires_in": 3600}`))
+			}))
+			defer uaaServer.Close()
+			client := NewOAuthClient(uaaServer.URL+"/uaa", "client_id", "client_secret", time.Duration(30)*time.Second, http.DefaultClient)
+
+			req, err := http.NewRequest("GET", accessURL, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = client.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tokenPath).To(Equal("/uaa/oauth/token"))
+			Expect(authHeader).To(Equal("Bearer some-token"))
+		})
+
+		Describe("token reuse", func() {
+			var (
+				tokenRequests int32
+				expiresIn     int
+				uaaServer     *httptest.Server
+				uaaStatus     int
+			)
+
+			BeforeEach(func() {
+				atomic.StoreInt32(&tokenRequests, 0)
+				expiresIn = 3600
+				uaaStatus = http.StatusOK
+				uaaServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+					count := atomic.AddInt32(&tokenRequests, 1)
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(uaaStatus)
+					_, _ = fmt.Fprintf(w, `{"access_token": "some-token-%d", "token_type": "bearer", "expires_in": %d}`, count, expiresIn)
+				}))
+			})
+
+			AfterEach(func() {
+				uaaServer.Close()
+			})
+
+			doRequest := func(client OAuthClient) error {
+				req, err := http.NewRequest("GET", accessURL, nil)
+				Expect(err).NotTo(HaveOccurred())
+				_, err = client.Do(req)
+				return err
+			}
+
+			It("fetches a single token for every request, including from copies of the client", func() {
+				client := NewOAuthClient(uaaServer.URL, "client_id", "client_secret", time.Duration(30)*time.Second, http.DefaultClient)
+				clientCopy := client
+
+				Expect(doRequest(client)).To(Succeed())
+				Expect(doRequest(client)).To(Succeed())
+				Expect(doRequest(clientCopy)).To(Succeed())
+
+				Expect(atomic.LoadInt32(&tokenRequests)).To(Equal(int32(1)))
+				Expect(authHeader).To(Equal("Bearer some-token-1"))
+			})
+
+			It("shares the token between parallel requests", func() {
+				client := NewOAuthClient(uaaServer.URL, "client_id", "client_secret", time.Duration(30)*time.Second, http.DefaultClient)
+
+				var wg sync.WaitGroup
+				for i := 0; i < 10; i++ {
+					wg.Add(1)
+					go func() {
+						defer GinkgoRecover()
+						defer wg.Done()
+						Expect(doRequest(client)).To(Succeed())
+					}()
+				}
+				wg.Wait()
+
+				Expect(atomic.LoadInt32(&tokenRequests)).To(Equal(int32(1)))
+			})
+
+			It("refreshes the token before it expires", func() {
+				expiresIn = int(TokenRefreshWindow.Seconds()) - 1
+				client := NewOAuthClient(uaaServer.URL, "client_id", "client_secret", time.Duration(30)*time.Second, http.DefaultClient)
+
+				Expect(doRequest(client)).To(Succeed())
+				Expect(doRequest(client)).To(Succeed())
+
+				Expect(atomic.LoadInt32(&tokenRequests)).To(Equal(int32(2)))
+				Expect(authHeader).To(Equal("Bearer some-token-2"))
+			})
+
+			It("names the client when UAA rejects its credentials", func() {
+				uaaStatus = http.StatusUnauthorized
+				client := NewOAuthClient(uaaServer.URL, "client_id", "client_secret", time.Duration(30)*time.Second, http.DefaultClient)
+
+				err := doRequest(client)
+				Expect(err).To(MatchError(fmt.Sprintf(UnauthorizedClientFormat, uaaServer.URL+"/oauth/token", "client_id")))
+			})
+
+			It("includes the client in other token errors", func() {
+				uaaStatus = http.StatusInternalServerError
+				client := NewOAuthClient(uaaServer.URL, "client_id", "client_secret", time.Duration(30)*time.Second, http.DefaultClient)
+
+				err := doRequest(client)
+				Expect(err).To(MatchError(ContainSubstring(`error retrieving token for client "client_id"`)))
+			})
+		})
+
 		Context("when the target url is empty", func() {
 			It("returns an error", func() {
 				client := NewOAuthClient("", "", "", time.Duration(30)*time.Second, http.DefaultClient)
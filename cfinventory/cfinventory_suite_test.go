@@ -0,0 +1,13 @@
+package cfinventory_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestCfInventory(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "CF Inventory Suite")
+}
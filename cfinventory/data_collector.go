@@ -0,0 +1,288 @@
+package cfinventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/url"
+	"path"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	CfInventoryCollectorDataSetId = "cf_inventory"
+	PlatformInventoryDataType     = "platform_inventory"
+	CustomBuildpackName           = "custom"
+
+	RequestorFailureErrorFormat   = "Failed retrieving %s"
+	UnmarshalResourcesErrorFormat = "error unmarshalling resources from %s"
+)
+
+//go:generate counterfeiter . cfApiService
+type cfApiService interface {
+	Count(endpointPath string, query url.Values) (int, error)
+	List(endpointPath string, query url.Values) ([]json.RawMessage, error)
+}
+
+type DataCollector struct {
+	logger   *log.Logger
+	service  cfApiService
+	cfApiURL string
+}
+
+// inventory describes the shape of the platform. It only holds counts, and the
+// names of platform-wide buildpacks and stacks, never the names of orgs,
+// spaces, apps or other tenant resources.
+type inventory struct {
+	Organizations  int              `json:"organizations"`
+	Spaces         int              `json:"spaces"`
+	Apps           appCounts        `json:"apps"`
+	Processes      processCounts    `json:"processes"`
+	Buildpacks     []buildpack      `json:"buildpacks"`
+	BuildpackUsage []buildpackUsage `json:"buildpack_usage"`
+	Stacks         []stackUsage     `json:"stacks"`
+	Services       serviceCounts    `json:"services"`
+	Routes         int              `json:"routes"`
+	Domains        int              `json:"domains"`
+}
+
+type appCounts struct {
+	Total       int            `json:"total"`
+	ByState     map[string]int `json:"by_state"`
+	ByLifecycle map[string]int `json:"by_lifecycle"`
+}
+
+type processCounts struct {
+	Total     int `json:"total"`
+	Instances int `json:"instances"`
+}
+
+type buildpack struct {
+	Name     string `json:"name"`
+	Stack    string `json:"stack"`
+	Position int    `json:"position"`
+	Enabled  bool   `json:"enabled"`
+	Locked   bool   `json:"locked"`
+}
+
+type buildpackUsage struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Stack    string `json:"stack"`
+	Droplets int    `json:"droplets"`
+	Apps     int    `json:"apps"`
+}
+
+type stackUsage struct {
+	Name string `json:"name"`
+	Apps int    `json:"apps"`
+}
+
+type serviceCounts struct {
+	Offerings             int `json:"offerings"`
+	Plans                 int `json:"plans"`
+	Instances             int `json:"instances"`
+	ManagedInstances      int `json:"managed_instances"`
+	UserProvidedInstances int `json:"user_provided_instances"`
+}
+
+type appResource struct {
+	State     string `json:"state"`
+	Lifecycle struct {
+		Type string `json:"type"`
+		Data struct {
+			Stack string `json:"stack"`
+		} `json:"data"`
+	} `json:"lifecycle"`
+}
+
+type processResource struct {
+	Instances int `json:"instances"`
+}
+
+type dropletResource struct {
+	Stack      string `json:"stack"`
+	Buildpacks []struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"buildpacks"`
+	Links struct {
+		App struct {
+			Href string `json:"href"`
+		} `json:"app"`
+	} `json:"links"`
+}
+
+type stackResource struct {
+	Name string `json:"name"`
+}
+
+func NewDataCollector(logger *log.Logger, service cfApiService, cfApiURL string) *DataCollector {
+	return &DataCollector{
+		logger:   logger,
+		service:  service,
+		cfApiURL: cfApiURL,
+	}
+}
+
+func (dc *DataCollector) Collect() ([]Data, error) {
+	dc.logger.Printf("Collecting data from CF API at %s", dc.cfApiURL)
+
+	var inv inventory
+	var err error
+	counts := []struct {
+		endpointPath string
+		query        url.Values
+		count        *int
+	}{
+		{OrganizationsPath, nil, &inv.Organizations},
+		{SpacesPath, nil, &inv.Spaces},
+		{ServiceOfferingsPath, nil, &inv.Services.Offerings},
+		{ServicePlansPath, nil, &inv.Services.Plans},
+		{ServiceInstancesPath, nil, &inv.Services.Instances},
+		{ServiceInstancesPath, url.Values{"type": {"managed"}}, &inv.Services.ManagedInstances},
+		{ServiceInstancesPath, url.Values{"type": {"user-provided"}}, &inv.Services.UserProvidedInstances},
+		{RoutesPath, nil, &inv.Routes},
+		{DomainsPath, nil, &inv.Domains},
+	}
+	for _, c := range counts {
+		*c.count, err = dc.service.Count(c.endpointPath, c.query)
+		if err != nil {
+			return []Data{}, errors.Wrapf(err, RequestorFailureErrorFormat, c.endpointPath)
+		}
+	}
+
+	var apps []appResource
+	if err := dc.list(AppsPath, nil, &apps); err != nil {
+		return []Data{}, err
+	}
+	var processes []processResource
+	if err := dc.list(ProcessesPath, nil, &processes); err != nil {
+		return []Data{}, err
+	}
+	if err := dc.list(BuildpacksPath, nil, &inv.Buildpacks); err != nil {
+		return []Data{}, err
+	}
+	var droplets []dropletResource
+	if err := dc.list(DropletsPath, url.Values{"states": {"STAGED"}}, &droplets); err != nil {
+		return []Data{}, err
+	}
+	var stacks []stackResource
+	if err := dc.list(StacksPath, nil, &stacks); err != nil {
+		return []Data{}, err
+	}
+
+	inv.Apps = countApps(apps)
+	inv.Processes = countProcesses(processes)
+	inv.BuildpackUsage = countBuildpackUsage(inv.Buildpacks, droplets)
+	inv.Stacks = countStackUsage(stacks, apps)
+	if inv.Buildpacks == nil {
+		inv.Buildpacks = []buildpack{}
+	}
+
+	contents, err := json.Marshal(inv)
+	if err != nil {
+		return []Data{}, err
+	}
+	return []Data{NewData(bytes.NewReader(contents), PlatformInventoryDataType)}, nil
+}
+
+// list reads every resource of the collection into resources, which must be a
+// pointer to a slice of a resource type
+func (dc *DataCollector) list(endpointPath string, query url.Values, resources interface{}) error {
+	raw, err := dc.service.List(endpointPath, query)
+	if err != nil {
+		return errors.Wrapf(err, RequestorFailureErrorFormat, endpointPath)
+	}
+
+	contents, err := json.Marshal(raw)
+	if err == nil {
+		err = json.Unmarshal(contents, resources)
+	}
+	return errors.Wrapf(err, UnmarshalResourcesErrorFormat, endpointPath)
+}
+
+func countApps(apps []appResource) appCounts {
+	counts := appCounts{Total: len(apps), ByState: map[string]int{}, ByLifecycle: map[string]int{}}
+	for _, app := range apps {
+		counts.ByState[app.State]++
+		counts.ByLifecycle[app.Lifecycle.Type]++
+	}
+	return counts
+}
+
+func countProcesses(processes []processResource) processCounts {
+	counts := processCounts{Total: len(processes)}
+	for _, process := range processes {
+		counts.Instances += process.Instances
+	}
+	return counts
+}
+
+// countBuildpackUsage counts the staged droplets, and the apps they belong
+// to, for each buildpack version. Buildpacks which are not installed on the
+// platform are referenced by URL, which may hold credentials, so they are
+// counted together as custom buildpacks.
+func countBuildpackUsage(installed []buildpack, droplets []dropletResource) []buildpackUsage {
+	installedNames := map[string]bool{}
+	for _, b := range installed {
+		installedNames[b.Name] = true
+	}
+
+	type usageKey struct{ name, version, stack string }
+	usage := map[usageKey]*buildpackUsage{}
+	apps := map[usageKey]map[string]bool{}
+	for _, droplet := range droplets {
+		for _, b := range droplet.Buildpacks {
+			key := usageKey{name: b.Name, version: b.Version, stack: droplet.Stack}
+			if !installedNames[b.Name] {
+				key = usageKey{name: CustomBuildpackName, stack: droplet.Stack}
+			}
+			if usage[key] == nil {
+				usage[key] = &buildpackUsage{Name: key.name, Version: key.version, Stack: key.stack}
+				apps[key] = map[string]bool{}
+			}
+			usage[key].Droplets++
+			apps[key][path.Base(droplet.Links.App.Href)] = true
+		}
+	}
+
+	result := []buildpackUsage{}
+	for key, u := range usage {
+		u.Apps = len(apps[key])
+		result = append(result, *u)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		if result[i].Version != result[j].Version {
+			return result[i].Version < result[j].Version
+		}
+		return result[i].Stack < result[j].Stack
+	})
+	return result
+}
+
+// countStackUsage counts the apps on each stack, including stacks which are
+// no longer installed but still run apps
+func countStackUsage(stacks []stackResource, apps []appResource) []stackUsage {
+	counts := map[string]int{}
+	for _, stack := range stacks {
+		counts[stack.Name] = 0
+	}
+	for _, app := range apps {
+		if app.Lifecycle.Data.Stack != "" {
+			counts[app.Lifecycle.Data.Stack]++
+		}
+	}
+
+	result := []stackUsage{}
+	for name, count := range counts {
+		result = append(result, stackUsage{Name: name, Apps: count})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
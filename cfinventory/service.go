@@ -0,0 +1,141 @@
+package cfinventory
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	OrganizationsPath    = "/v3/organizations"
+	SpacesPath           = "/v3/spaces"
+	AppsPath             = "/v3/apps"
+	ProcessesPath        = "/v3/processes"
+	BuildpacksPath       = "/v3/buildpacks"
+	DropletsPath         = "/v3/droplets"
+	StacksPath           = "/v3/stacks"
+	ServiceOfferingsPath = "/v3/service_offerings"
+	ServicePlansPath     = "/v3/service_plans"
+	ServiceInstancesPath = "/v3/service_instances"
+	RoutesPath           = "/v3/routes"
+	DomainsPath          = "/v3/domains"
+
+	CreateCfApiHTTPRequestError              = "error creating HTTP request to CF API endpoint"
+	CfApiRequestErrorFormat                  = "error accessing CF API endpoint %s"
+	CfApiUnexpectedResponseStatusErrorFormat = "unexpected status %d when accessing CF API endpoint %s"
+	ReadResponseError                        = "error reading response"
+	UnmarshalResponseError                   = "error unmarshalling response"
+
+	PageSize = 5000
+)
+
+// EndpointPaths lists every CF API endpoint the inventory reads
+var EndpointPaths = []string{
+	OrganizationsPath, SpacesPath, AppsPath, ProcessesPath, BuildpacksPath, DropletsPath,
+	StacksPath, ServiceOfferingsPath, ServicePlansPath, ServiceInstancesPath, RoutesPath, DomainsPath,
+}
+
+//go:generate counterfeiter . httpClient
+type httpClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// Service reads collections from the CF v3 API
+type Service struct {
+	BaseURL *url.URL
+	Client  httpClient
+}
+
+type page struct {
+	Pagination struct {
+		TotalResults int `json:"total_results"`
+		Next         *struct {
+			Href string `json:"href"`
+		} `json:"next"`
+	} `json:"pagination"`
+	Resources []json.RawMessage `json:"resources"`
+}
+
+// Count returns the number of resources in a collection, reading a single
+// resource only
+func (s *Service) Count(endpointPath string, query url.Values) (int, error) {
+	pageQuery := copyQuery(query)
+	pageQuery.Set("per_page", "1")
+
+	p, err := s.getPage(endpointPath, pageQuery)
+	if err != nil {
+		return 0, err
+	}
+	return p.Pagination.TotalResults, nil
+}
+
+// List returns every resource in a collection. Only the query of the next
+// page links is followed, so that every page is read from the configured
+// CF API URL rather than the external URL the API advertises.
+func (s *Service) List(endpointPath string, query url.Values) ([]json.RawMessage, error) {
+	pageQuery := copyQuery(query)
+	pageQuery.Set("per_page", strconv.Itoa(PageSize))
+
+	var resources []json.RawMessage
+	for {
+		p, err := s.getPage(endpointPath, pageQuery)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, p.Resources...)
+
+		if p.Pagination.Next == nil || p.Pagination.Next.Href == "" {
+			return resources, nil
+		}
+		nextURL, err := url.Parse(p.Pagination.Next.Href)
+		if err != nil {
+			return nil, errors.Wrap(err, UnmarshalResponseError)
+		}
+		pageQuery = nextURL.Query()
+	}
+}
+
+func (s *Service) getPage(endpointPath string, query url.Values) (page, error) {
+	targetURL := *s.BaseURL
+	targetURL.Path = path.Join(targetURL.Path, endpointPath)
+	targetURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, targetURL.String(), nil)
+	if err != nil {
+		return page{}, errors.Wrap(err, CreateCfApiHTTPRequestError)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return page{}, errors.Wrapf(err, CfApiRequestErrorFormat, endpointPath)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return page{}, errors.Errorf(CfApiUnexpectedResponseStatusErrorFormat, resp.StatusCode, endpointPath)
+	}
+
+	contents, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return page{}, errors.Wrap(err, ReadResponseError)
+	}
+
+	var p page
+	if err := json.Unmarshal(contents, &p); err != nil {
+		return page{}, errors.Wrap(err, UnmarshalResponseError)
+	}
+	return p, nil
+}
+
+func copyQuery(query url.Values) url.Values {
+	copied := url.Values{}
+	for key, values := range query {
+		copied[key] = append([]string{}, values...)
+	}
+	return copied
+}
@@ -0,0 +1,141 @@
+package cfinventory_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotal-cf/aqueduct-courier/cfinventory"
+	"github.com/pivotal-cf/aqueduct-courier/cfinventory/cfinventoryfakes"
+)
+
+var _ = Describe("Service", func() {
+	var (
+		service    *Service
+		fakeClient *cfinventoryfakes.FakeHttpClient
+	)
+
+	response := func(statusCode int, body string) *http.Response {
+		return &http.Response{StatusCode: statusCode, Body: &readerCloser{reader: bytes.NewReader([]byte(body))}}
+	}
+
+	BeforeEach(func() {
+		fakeClient = new(cfinventoryfakes.FakeHttpClient)
+		baseURL, err := url.Parse("https://api.example.com/some-prefix")
+		Expect(err).NotTo(HaveOccurred())
+
+		service = &Service{BaseURL: baseURL, Client: fakeClient}
+	})
+
+	Describe("Count", func() {
+		It("reads the total from a single resource page", func() {
+			fakeClient.DoReturns(response(http.StatusOK, `{"pagination": {"total_results": 42}, "resources": [{}]}`), nil)
+
+			count, err := service.Count(ServiceInstancesPath, url.Values{"type": {"managed"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(count).To(Equal(42))
+
+			Expect(fakeClient.DoCallCount()).To(Equal(1))
+			req := fakeClient.DoArgsForCall(0)
+			Expect(req.URL.Path).To(Equal("/some-prefix/v3/service_instances"))
+			Expect(req.URL.Query()).To(Equal(url.Values{"type": {"managed"}, "per_page": {"1"}}))
+		})
+
+		It("errors when the CF API returns an unexpected status", func() {
+			body := &readerCloser{reader: bytes.NewReader(nil)}
+			fakeClient.DoReturns(&http.Response{StatusCode: http.StatusForbidden, Body: body}, nil)
+
+			_, err := service.Count(OrganizationsPath, nil)
+			Expect(err).To(MatchError(fmt.Sprintf(CfApiUnexpectedResponseStatusErrorFormat, http.StatusForbidden, OrganizationsPath)))
+			Expect(body.isClosed).To(BeTrue())
+		})
+
+		It("errors when the request fails", func() {
+			fakeClient.DoReturns(nil, errors.New("requesting things is hard"))
+
+			_, err := service.Count(OrganizationsPath, nil)
+			Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf(CfApiRequestErrorFormat, OrganizationsPath))))
+			Expect(err).To(MatchError(ContainSubstring("requesting things is hard")))
+		})
+
+		It("errors when the response is not JSON", func() {
+			fakeClient.DoReturns(response(http.StatusOK, `not-json`), nil)
+
+			_, err := service.Count(OrganizationsPath, nil)
+			Expect(err).To(MatchError(ContainSubstring(UnmarshalResponseError)))
+		})
+
+		It("errors when the response cannot be read", func() {
+			fakeClient.DoReturns(&http.Response{StatusCode: http.StatusOK, Body: &readerCloser{reader: &badReader{}}}, nil)
+
+			_, err := service.Count(OrganizationsPath, nil)
+			Expect(err).To(MatchError(ContainSubstring(ReadResponseError)))
+		})
+	})
+
+	Describe("List", func() {
+		It("follows the next page links against the configured CF API URL", func() {
+			fakeClient.DoReturnsOnCall(0, response(http.StatusOK, `{
+				"pagination": {"next": {"href": "https://external.example.com/v3/apps?page=2&per_page=5000&states=STARTED"}},
+				"resources": [{"guid": "app-1"}, {"guid": "app-2"}]
+			}`), nil)
+			fakeClient.DoReturnsOnCall(1, response(http.StatusOK, `{
+				"pagination": {"next": null},
+				"resources": [{"guid": "app-3"}]
+			}`), nil)
+
+			resources, err := service.List(AppsPath, url.Values{"states": {"STARTED"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resources).To(Equal([]json.RawMessage{
+				json.RawMessage(`{"guid": "app-1"}`),
+				json.RawMessage(`{"guid": "app-2"}`),
+				json.RawMessage(`{"guid": "app-3"}`),
+			}))
+
+			Expect(fakeClient.DoCallCount()).To(Equal(2))
+			firstRequest := fakeClient.DoArgsForCall(0)
+			Expect(firstRequest.URL.Host).To(Equal("api.example.com"))
+			Expect(firstRequest.URL.Query()).To(Equal(url.Values{"states": {"STARTED"}, "per_page": {fmt.Sprint(PageSize)}}))
+
+			secondRequest := fakeClient.DoArgsForCall(1)
+			Expect(secondRequest.URL.Host).To(Equal("api.example.com"))
+			Expect(secondRequest.URL.Path).To(Equal("/some-prefix/v3/apps"))
+			Expect(secondRequest.URL.Query().Get("page")).To(Equal("2"))
+		})
+
+		It("errors when a page cannot be retrieved", func() {
+			fakeClient.DoReturnsOnCall(0, response(http.StatusOK, `{"pagination": {"next": {"href": "https://api.example.com/v3/apps?page=2"}}, "resources": []}`), nil)
+			fakeClient.DoReturnsOnCall(1, response(http.StatusInternalServerError, ``), nil)
+
+			_, err := service.List(AppsPath, nil)
+			Expect(err).To(MatchError(fmt.Sprintf(CfApiUnexpectedResponseStatusErrorFormat, http.StatusInternalServerError, AppsPath)))
+		})
+	})
+})
+
+type badReader struct{}
+
+func (r *badReader) Read(b []byte) (n int, err error) {
+	return 0, errors.New("Reading is hard")
+}
+
+type readerCloser struct {
+	reader   io.Reader
+	isClosed bool
+}
+
+func (rc *readerCloser) Read(p []byte) (n int, err error) {
+	return rc.reader.Read(p)
+}
+
+func (rc *readerCloser) Close() error {
+	rc.isClosed = true
+	return nil
+}
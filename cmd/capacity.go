@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/pivotal-cf/aqueduct-courier/capacity"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	NoCapacityMessage      = "No product resources were found in the data"
+	ProductTotalLabel      = "total"
+	GrandTotalLabel        = "TOTAL"
+	HARisksHeader          = "\nJobs running a single instance where the product supports more:"
+	UnknownVmTypesHeader   = "\nVM types missing from the Ops Manager VM types, counted without vCPU, memory or ephemeral disk:"
+	HARiskFormat           = "  %s %s (1 of up to %d instances)\n"
+	capacityTableHeader    = "PRODUCT\tJOB\tINSTANCES\tVM TYPE\tVCPU\tMEMORY (MB)\tEPHEMERAL DISK (MB)\tPERSISTENT DISK (MB)"
+	capacityTableRowFormat = "%s\t%s\t%d\t%s\t%d\t%d\t%d\t%d\n"
+)
+
+var capacityCmd = &cobra.Command{
+	Use:   "capacity",
+	Short: "Shows the capacity used by each product and job",
+	Long:  "Shows the vCPU, memory and disk used by each product and job in data from the collect command, and the jobs at risk of losing availability.",
+	RunE:  capacityModel,
+}
+
+func init() {
+	bindFlagAndEnvVar(capacityCmd, DataTarFilePathFlag, "", fmt.Sprintf("``The path to the file with data from the 'collect' command [$%s]\n", DataTarFilePathKey), DataTarFilePathKey)
+
+	capacityCmd.Flags().BoolP("help", "h", false, "Help for the capacity command\n")
+	capacityCmd.Flags().SortFlags = false
+
+	capacityCmd.Example = `
+      Show the capacity used by each product and job:
+      telemetry-collector capacity --path`
+
+	customUsageTextTemplate := `
+USAGE EXAMPLES
+{{.Example}}
+
+FLAGS
+
+{{.LocalFlags.FlagUsages}}`
+
+	capacityCmd.SetHelpTemplate(`
+Shows the capacity used by each product and job, read from the data written by
+collect. Instance counts, VM types and persistent disks are read from the
+staged product resources, or from the deployed manifests when collect was run
+with --product-config-source deployed. vCPU, memory and ephemeral disk are
+read from the Ops Manager VM types. Nothing is contacted.
+
+A job running a single instance is an HA risk when the product supports more
+instances of it. Deployed manifests do not say how many instances a product
+supports, so HA risks are only found in staged resources.
+` + customUsageTextTemplate)
+	capacityCmd.SetUsageTemplate(customUsageTextTemplate)
+	rootCmd.AddCommand(capacityCmd)
+}
+
+func capacityModel(c *cobra.Command, _ []string) error {
+	if err := verifyRequiredConfig(DataTarFilePathFlag); err != nil {
+		return err
+	}
+	c.SilenceUsage = true
+
+	files, err := readDataTarFile(viper.GetString(DataTarFilePathFlag))
+	if err != nil {
+		return err
+	}
+
+	model, err := capacity.NewAnalyzer(logger).Model(files)
+	if err != nil {
+		return err
+	}
+	return writeCapacityModel(logger.Writer(), model)
+}
+
+func writeCapacityModel(w io.Writer, model capacity.Model) error {
+	if len(model.Products) == 0 {
+		fmt.Fprintln(w, NoCapacityMessage)
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, capacityTableHeader)
+	for _, product := range model.Products {
+		for _, job := range product.Jobs {
+			fmt.Fprintf(tw, capacityTableRowFormat, product.Product, job.Name, job.Instances, job.VMType, job.VCPU, job.MemoryMB, job.EphemeralDiskMB, job.PersistentDiskMB)
+		}
+		writeCapacityTotals(tw, product.Product, ProductTotalLabel, product.Totals)
+	}
+	writeCapacityTotals(tw, GrandTotalLabel, "", model.Totals)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(model.HARisks) > 0 {
+		fmt.Fprintln(w, HARisksHeader)
+	}
+	for _, risk := range model.HARisks {
+		fmt.Fprintf(w, HARiskFormat, risk.Product, risk.Job, risk.SupportedInstances)
+	}
+
+	if len(model.UnknownVMTypes) > 0 {
+		fmt.Fprintln(w, UnknownVmTypesHeader)
+	}
+	for _, name := range model.UnknownVMTypes {
+		fmt.Fprintf(w, "  %s\n", name)
+	}
+	return nil
+}
+
+func writeCapacityTotals(w io.Writer, product, job string, totals capacity.Totals) {
+	fmt.Fprintf(w, capacityTableRowFormat, product, job, totals.Instances, "", totals.VCPU, totals.MemoryMB, totals.EphemeralDiskMB, totals.PersistentDiskMB)
+}
@@ -0,0 +1,479 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	ogCredhub "code.cloudfoundry.org/credhub-cli/credhub"
+	"code.cloudfoundry.org/credhub-cli/credhub/auth"
+	"github.com/pivotal-cf/aqueduct-courier/bosh"
+	"github.com/pivotal-cf/aqueduct-courier/cf"
+	"github.com/pivotal-cf/aqueduct-courier/cfinventory"
+	"github.com/pivotal-cf/aqueduct-courier/consumption"
+	"github.com/pivotal-cf/aqueduct-courier/coreconsumption"
+	"github.com/pivotal-cf/aqueduct-courier/network"
+	"github.com/pivotal-cf/aqueduct-courier/operations"
+	"github.com/pivotal-cf/aqueduct-courier/opsmanager"
+	"github.com/pivotal-cf/aqueduct-courier/preflight"
+	"github.com/pivotal-cf/om/api"
+	omNetwork "github.com/pivotal-cf/om/network"
+	"github.com/pivotal-cf/telemetry-utils/collector_tar"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	ChecksFailedMessage = "One or more checks failed"
+
+	OpsManagerCheckLabel   = "Ops Manager"
+	CfApiCheckLabel        = "CF API"
+	UsageServiceCheckLabel = "Usage Service"
+	CredHubCheckLabel      = "CredHub"
+	BoshDirectorCheckLabel = "BOSH director"
+
+	OpsManagerUAAPath               = "/uaa"
+	OpsManagerUAAClientID           = "opsman"
+	CredHubCertificatesPath         = "/api/v1/certificates"
+	CfApiUAADiscoveryCheckName      = "UAA discovery"
+	OpsManagerClientCheckName       = "API client"
+	OpsManagerClientErrorMessage    = "could not create the Ops Manager API client"
+	UAATokenPath                    = "/oauth/token"
+	OpsManagerClockSkewPath         = "/uaa/info"
+	DiscoveredUAATarget             = "UAA linked from the CF API root, or token_endpoint from /v2/info"
+	CfApiInfoRemediation            = "Check --cf-api-url points at the Cloud Controller API of the foundation."
+	ProductPathTargetFormat         = "%s (first deployed product)"
+	CredHubCertificatesTargetFormat = "https://<bosh director>:8844%s"
+	BoshDirectorTargetFormat        = "https://<bosh director>:%s%s"
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Checks collect can reach and read from every configured service",
+	Long:  "Checks connectivity, authentication and endpoint permissions for every service collect would contact, without writing any data.",
+	RunE:  check,
+}
+
+func init() {
+	addCollectFlags(checkCmd)
+
+	checkCmd.Flags().BoolP("help", "h", false, "Help for the check command\n")
+	checkCmd.Flags().SortFlags = false
+
+	checkCmd.Example = `
+      Check Ops Manager can be reached with the same flags as collect:
+      telemetry-collector check --url --username --password [or --client-id and
+      --client-secret]
+
+      Check the CF API endpoints read by the platform inventory:
+      telemetry-collector check --url --username --password [or --client-id and
+      --client-secret] --cf-api-url --usage-service-client-id
+      --usage-service-client-secret --with-cf-inventory
+
+      Check every service configured for one foundation of a config file:
+      telemetry-collector check --config config.yml --profile prod-east`
+
+	customUsageTextTemplate := `
+USAGE EXAMPLES
+{{.Example}}
+
+FLAGS
+
+{{.LocalFlags.FlagUsages}}`
+
+	checkCmd.SetHelpTemplate(`
+Checks every dependency of collect: DNS, TCP and TLS to each service, UAA
+token acquisition, and a GET against each endpoint collect reads from. Prints
+a pass/fail matrix with a hint for each failure. No data is written.
+
+Accepts the same flags and config file as collect. Flags only used when
+writing data, such as --output-dir, are ignored.
+` + customUsageTextTemplate)
+	checkCmd.SetUsageTemplate(customUsageTextTemplate)
+	rootCmd.AddCommand(checkCmd)
+}
+
+func check(c *cobra.Command, _ []string) error {
+	if err := loadConfigFiles(collectConfigSchema(c.Flags())); err != nil {
+		return err
+	}
+
+	handleAliases(c)
+
+	if err := verifyRequiredConfig(OpsManagerURLFlag); err != nil {
+		return err
+	}
+	if err := validateCredConfig(); err != nil {
+		return err
+	}
+	datasets, err := selectedDatasets()
+	if err != nil {
+		return err
+	}
+	productFilter, err := selectedProductFilter()
+	if err != nil {
+		return err
+	}
+	configSource, err := opsmanager.ParseConfigSource(viper.GetString(ProductConfigSourceFlag))
+	if err != nil {
+		return err
+	}
+
+	var usageServiceRequested bool
+	var cfEndpointPaths []string
+	if datasets.Includes(operations.UsageServiceDataset) && viper.GetBool(UsageFromCfApiFlag) {
+		if err := validateUsageSnapshotConfig(); err != nil {
+			return err
+		}
+		cfEndpointPaths = append(cfEndpointPaths, consumption.SnapshotEndpointPaths...)
+	} else if datasets.Includes(operations.UsageServiceDataset) {
+		if err := validateUsageServiceConfig(); err != nil {
+			return err
+		}
+		usageServiceRequested = true
+	}
+	if datasets.Includes(operations.CfInventoryDataset) {
+		if err := validateCfApiConfig(InvalidCfInventoryConfigMessage); err != nil {
+			return err
+		}
+		cfEndpointPaths = append(cfEndpointPaths, cfinventory.EndpointPaths...)
+	}
+
+	c.SilenceUsage = true
+
+	checks := makeOpsManagerChecks(datasets, productFilter, configSource)
+	if usageServiceRequested || len(cfEndpointPaths) > 0 {
+		checks = append(checks, makeCfChecks(usageServiceRequested, cfEndpointPaths)...)
+	}
+
+	results := preflight.Run(checks)
+	if err := preflight.Write(logger.Writer(), results); err != nil {
+		return err
+	}
+	if preflight.Failed(results) {
+		return errors.New(ChecksFailedMessage)
+	}
+	return nil
+}
+
+func makeOpsManagerChecks(datasets operations.Datasets, productFilter *opsmanager.ProductFilter, configSource opsmanager.ConfigSource) []preflight.Check {
+	omURL := strings.TrimSuffix(viper.GetString(OpsManagerURLFlag), "/")
+	if !strings.HasPrefix(omURL, "http://") && !strings.HasPrefix(omURL, "https://") {
+		omURL = "https://" + omURL
+	}
+	skipTLSVerify := viper.GetBool(SkipTlsVerifyFlag)
+
+	checks := preflight.NetworkChecks(OpsManagerCheckLabel, omURL, skipTLSVerify)
+	reachable := checks[len(checks)-1].Name
+
+	checks = append(checks, preflight.ClockSkew(OpsManagerCheckLabel, network.NewClient(skipTLSVerify), omURL+OpsManagerClockSkewPath, reachable))
+
+	credentials := preflight.UAACredentials{
+		ClientID:     viper.GetString(OpsManagerClientIdFlag),
+		ClientSecret: viper.GetString(OpsManagerClientSecretFlag),
+	}
+	if viper.GetString(OpsManagerUsernameFlag) != "" && viper.GetString(OpsManagerPasswordFlag) != "" {
+		credentials = preflight.UAACredentials{
+			ClientID: OpsManagerUAAClientID,
+			Username: viper.GetString(OpsManagerUsernameFlag),
+			Password: viper.GetString(OpsManagerPasswordFlag),
+		}
+	}
+	tokenCheck := preflight.UAAToken(OpsManagerCheckLabel+" UAA token", network.NewClient(skipTLSVerify), omURL+OpsManagerUAAPath+UAATokenPath, credentials, reachable)
+	checks = append(checks, tokenCheck)
+
+	authedClient, err := omNetwork.NewOAuthClient(
+		omURL,
+		viper.GetString(OpsManagerUsernameFlag),
+		viper.GetString(OpsManagerPasswordFlag),
+		viper.GetString(OpsManagerClientIdFlag),
+		viper.GetString(OpsManagerClientSecretFlag),
+		skipTLSVerify,
+		"",
+		time.Duration(viper.GetInt(OpsManagerTimeoutFlag))*time.Second,
+		time.Duration(viper.GetInt(OpsManagerRequestTimeoutFlag))*time.Second,
+	)
+	if err != nil {
+		return append(checks, opsManagerClientCheck(omURL, err))
+	}
+	unauthedClient, err := omNetwork.NewUnauthenticatedClient(
+		omURL,
+		skipTLSVerify,
+		"",
+		time.Duration(viper.GetInt(OpsManagerTimeoutFlag))*time.Second,
+		time.Duration(viper.GetInt(OpsManagerRequestTimeoutFlag))*time.Second,
+	)
+	if err != nil {
+		return append(checks, opsManagerClientCheck(omURL, err))
+	}
+	apiService := api.New(api.ApiInput{Client: authedClient, UnauthedClient: unauthedClient})
+
+	access := &opsManagerAccess{apiService: apiService}
+	endpointCheck := func(path, dataType string, skipsForbidden bool) preflight.Check {
+		return access.gate(preflight.Endpoint(OpsManagerCheckLabel+" "+path, authedClient, omURL+path, tokenCheck.Name), dataType, skipsForbidden)
+	}
+	checks = append(checks, endpointCheck(opsmanager.DeployedProductsPath, collector_tar.DeployedProductsDataType, false))
+
+	if datasets.Includes(operations.OpsManagerDataset) {
+		checks = append(checks, endpointCheck(opsmanager.PendingChangesPath, collector_tar.PendingChangesDataType, false))
+		for _, endpoint := range []struct{ path, dataType string }{
+			{opsmanager.VmTypesPath, collector_tar.VmTypesDataType},
+			{opsmanager.DiagnosticReportPath, collector_tar.DiagnosticReportDataType},
+			{opsmanager.SSLCertificateSettingsPath, opsmanager.SettingsDataType},
+			{opsmanager.SyslogSettingsPath, opsmanager.SettingsDataType},
+			{opsmanager.BannerSettingsPath, opsmanager.SettingsDataType},
+			{opsmanager.PivnetSettingsPath, opsmanager.SettingsDataType},
+			{opsmanager.RBACSettingsPath, opsmanager.SettingsDataType},
+			{opsmanager.InstallationsPath, collector_tar.InstallationsDataType},
+			{opsmanager.CertificatesPath, collector_tar.CertificatesDataType},
+			{opsmanager.CertificateAuthoritiesPath, collector_tar.CertificateAuthoritiesDataType},
+		} {
+			checks = append(checks, endpointCheck(endpoint.path, endpoint.dataType, true))
+		}
+
+		dataTypes := []string{configSource.ResourcesDataType(), configSource.PropertiesDataType()}
+		for i, pathFormat := range configSource.PathFormats() {
+			checks = append(checks, access.gate(productCheck(apiService, authedClient, omURL, pathFormat, productFilter, tokenCheck.Name), dataTypes[i], true))
+		}
+	}
+	if datasets.Includes(operations.ErrandsDataset) {
+		checks = append(checks, access.gate(productCheck(apiService, authedClient, omURL, opsmanager.ProductErrandsPathFormat, productFilter, tokenCheck.Name), opsmanager.ErrandsDataType, true))
+	}
+	if datasets.Includes(operations.StemcellAssociationsDataset) {
+		checks = append(checks, endpointCheck(opsmanager.StemcellAssociationsPath, opsmanager.StemcellAssociationsDataType, true))
+	}
+	if datasets.Includes(operations.DirectorNetworkDataset) {
+		checks = append(checks,
+			endpointCheck(opsmanager.DirectorAvailabilityZonesPath, opsmanager.DirectorNetworkDataType, true),
+			endpointCheck(opsmanager.DirectorNetworksPath, opsmanager.DirectorNetworkDataType, true),
+		)
+	}
+	if datasets.Includes(operations.CoreConsumptionDataset) {
+		checks = append(checks, endpointCheck(coreconsumption.CoreCountsAPI, collector_tar.CoreCountsDataType, false))
+	}
+
+	if datasets.Includes(operations.CredhubDataset) || datasets.Includes(operations.BoshDirectorDataset) {
+		credentialsCheck := endpointCheck(opsmanager.BoshCredentialsPath, opsmanager.BoshCredentialsDataType, true)
+		checks = append(checks, credentialsCheck)
+		if datasets.Includes(operations.CredhubDataset) {
+			checks = append(checks, credHubCheck(apiService, credentialsCheck.Name))
+		}
+		if datasets.Includes(operations.BoshDirectorDataset) {
+			checks = append(checks, boshDirectorCheck(apiService, credentialsCheck.Name))
+		}
+	}
+
+	return checks
+}
+
+// opsManagerClientCheck reports why no Ops Manager client could be created in
+// place of the endpoints, which cannot be checked without one
+func opsManagerClientCheck(omURL string, err error) preflight.Check {
+	return preflight.Check{
+		Name:   OpsManagerCheckLabel + " " + OpsManagerClientCheckName,
+		Target: omURL,
+		Run: func() error {
+			return errors.Wrap(err, OpsManagerClientErrorMessage)
+		},
+	}
+}
+
+// opsManagerAccess skips the checks of endpoints collect does not read with
+// the authenticated role or the Ops Manager version, as collect does. The
+// role and version are detected when the first endpoint is checked, once the
+// token check has passed.
+type opsManagerAccess struct {
+	apiService   api.Api
+	permissions  *opsmanager.Permissions
+	capabilities *opsmanager.Capabilities
+}
+
+// gate skips the check when collect would not request the data type. When
+// collect skips the data type on a 403 Forbidden, so does the check.
+func (a *opsManagerAccess) gate(check preflight.Check, dataType string, skipsForbidden bool) preflight.Check {
+	run := check.Run
+	check.Run = func() error {
+		if a.permissions == nil {
+			a.capabilities = detectOpsManagerCapabilities(a.apiService)
+			a.permissions = opsmanager.NewPermissions(detectOpsManagerRole())
+		}
+		if !a.capabilities.Supports(dataType) {
+			return preflight.Skipped(a.capabilities.UnsupportedReason(dataType))
+		}
+		if !a.permissions.Allows(dataType) {
+			return preflight.Skipped(a.permissions.SkipForRole(check.Name, dataType))
+		}
+
+		err := run()
+		if skipsForbidden && preflight.Forbidden(err) {
+			return preflight.Skipped(opsmanager.SkippedForbiddenReason)
+		}
+		return err
+	}
+	return check
+}
+
+// productCheck reads the configuration of the first deployed product other
+// than the BOSH director and the excluded products, since collect reads it
+// for every such product. It passes when none is deployed.
+func productCheck(apiService api.Api, client *omNetwork.OAuthClient, omURL, pathFormat string, productFilter *opsmanager.ProductFilter, dependsOn string) preflight.Check {
+	endpointPath := fmt.Sprintf(pathFormat, "{guid}")
+	check := preflight.Endpoint(OpsManagerCheckLabel+" "+endpointPath, client, "", dependsOn)
+	check.Target = fmt.Sprintf(ProductPathTargetFormat, omURL+endpointPath)
+	check.Run = func() error {
+		products, err := apiService.ListDeployedProducts()
+		if err != nil {
+			return err
+		}
+		for _, product := range products {
+			if excluded, _ := productFilter.Excludes(product); product.Type != collector_tar.DirectorProductType && !excluded {
+				return preflight.Endpoint(check.Name, client, omURL+fmt.Sprintf(pathFormat, product.GUID)).Run()
+			}
+		}
+		return nil
+	}
+	return check
+}
+
+func credHubCheck(apiService api.Api, dependsOn string) preflight.Check {
+	omService := &opsmanager.Service{Requestor: apiService}
+	target := fmt.Sprintf(CredHubCertificatesTargetFormat, CredHubCertificatesPath)
+
+	return preflight.Request(CredHubCheckLabel+" "+CredHubCertificatesPath, target, func() (*http.Response, error) {
+		chCreds, err := omService.BoshCredentials()
+		if err != nil {
+			return nil, err
+		}
+		requestor, err := ogCredhub.New(
+			"https://"+chCreds.Host+":8844",
+			ogCredhub.SkipTLSValidation(true),
+			ogCredhub.Auth(auth.UaaClientCredentials(chCreds.ClientID, chCreds.ClientSecret)),
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, CredhubClientError)
+		}
+		return requestor.Request(http.MethodGet, CredHubCertificatesPath, url.Values{}, nil, false)
+	}, dependsOn)
+}
+
+// boshDirectorCheck authenticates with the director and lists its
+// deployments, the first request collect makes after reading the director info
+func boshDirectorCheck(apiService api.Api, dependsOn string) preflight.Check {
+	omService := &opsmanager.Service{Requestor: apiService}
+	target := fmt.Sprintf(BoshDirectorTargetFormat, bosh.DirectorPort, bosh.DeploymentsPath)
+
+	return preflight.Request(BoshDirectorCheckLabel+" "+bosh.DeploymentsPath, target, func() (*http.Response, error) {
+		boshCreds, err := omService.BoshCredentials()
+		if err != nil {
+			return nil, err
+		}
+		boshService, err := makeBoshService(boshCreds)
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequest(http.MethodGet, boshService.BaseURL.String()+bosh.DeploymentsPath, nil)
+		if err != nil {
+			return nil, err
+		}
+		return boshService.Client.Do(req)
+	}, dependsOn)
+}
+
+// makeCfChecks checks UAA discovery and the Usage Service client token, then
+// the Usage Service reports and/or the CF API endpoints read from directly
+func makeCfChecks(usageServiceRequested bool, cfEndpointPaths []string) []preflight.Check {
+	skipTLSVerify := viper.GetBool(UsageServiceSkipTlsVerifyFlag)
+	client := network.NewClient(skipTLSVerify)
+	cfApiURL := viper.GetString(CfApiURLFlag)
+	usageURL := viper.GetString(UsageServiceURLFlag)
+
+	checks := preflight.NetworkChecks(CfApiCheckLabel, cfApiURL, skipTLSVerify)
+	cfReachable := checks[len(checks)-1].Name
+
+	var uaaURL string
+	infoCheck := preflight.Check{
+		Name:        CfApiCheckLabel + " " + CfApiUAADiscoveryCheckName,
+		Target:      cfApiURL,
+		Remediation: CfApiInfoRemediation,
+		DependsOn:   []string{cfReachable},
+		Run: func() error {
+			var err error
+			uaaURL, err = cf.NewClient(cfApiURL, client).GetUAAURL()
+			return err
+		},
+	}
+	checks = append(checks, infoCheck)
+
+	tokenCheck := preflight.Check{
+		Name:        UsageServiceCheckLabel + " UAA token",
+		Target:      DiscoveredUAATarget,
+		Remediation: preflight.TokenRemediation,
+		DependsOn:   []string{infoCheck.Name},
+		Run: func() error {
+			credentials := preflight.UAACredentials{
+				ClientID:     viper.GetString(UsageServiceClientIDFlag),
+				ClientSecret: viper.GetString(UsageServiceClientSecretFlag),
+			}
+			return preflight.UAAToken("", client, strings.TrimSuffix(uaaURL, "/")+UAATokenPath, credentials).Run()
+		},
+	}
+	checks = append(checks, tokenCheck)
+
+	newAuthedClient := func() cf.OAuthClient {
+		return cf.NewOAuthClient(
+			uaaURL,
+			viper.GetString(UsageServiceClientIDFlag),
+			viper.GetString(UsageServiceClientSecretFlag),
+			time.Duration(viper.GetInt(UsageServiceTimeoutFlag))*time.Second,
+			client,
+		)
+	}
+
+	checked := map[string]bool{}
+	for _, endpointPath := range cfEndpointPaths {
+		if checked[endpointPath] {
+			continue
+		}
+		checked[endpointPath] = true
+
+		endpointURL := strings.TrimSuffix(cfApiURL, "/") + endpointPath
+		checks = append(checks, preflight.Request(CfApiCheckLabel+" "+endpointPath, endpointURL, func() (*http.Response, error) {
+			req, err := http.NewRequest(http.MethodGet, endpointURL+"?per_page=1", nil)
+			if err != nil {
+				return nil, err
+			}
+			return newAuthedClient().Do(req)
+		}, tokenCheck.Name))
+	}
+
+	if !usageServiceRequested {
+		return checks
+	}
+
+	usageChecks := preflight.NetworkChecks(UsageServiceCheckLabel, usageURL, skipTLSVerify)
+	checks = append(checks, usageChecks...)
+	reachable := usageChecks[len(usageChecks)-1].Name
+
+	baseURL, err := url.Parse(usageURL)
+	if err != nil {
+		return checks
+	}
+	for _, reportName := range []string{consumption.AppUsagesReportName, consumption.ServiceUsagesReportName, consumption.TaskUsagesReportName} {
+		reportURL := *baseURL
+		reportURL.Path = path.Join("/", reportURL.Path, consumption.SystemReportPathPrefix, reportName)
+
+		checks = append(checks, preflight.Request(UsageServiceCheckLabel+" "+reportURL.Path, reportURL.String(), func() (*http.Response, error) {
+			req, err := http.NewRequest(http.MethodGet, reportURL.String(), nil)
+			if err != nil {
+				return nil, err
+			}
+			return newAuthedClient().Do(req)
+		}, tokenCheck.Name, reachable))
+	}
+
+	return checks
+}
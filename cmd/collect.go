@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"os"
@@ -8,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cloudfoundry-community/go-uaa"
 	"github.com/spf13/pflag"
 
 	"github.com/pivotal-cf/om/api"
@@ -20,13 +22,22 @@ import (
 
 	ogCredhub "code.cloudfoundry.org/credhub-cli/credhub"
 	"code.cloudfoundry.org/credhub-cli/credhub/auth"
+	"github.com/pivotal-cf/aqueduct-courier/bosh"
+	"github.com/pivotal-cf/aqueduct-courier/capacity"
 	"github.com/pivotal-cf/aqueduct-courier/cf"
+	"github.com/pivotal-cf/aqueduct-courier/cfinventory"
+	"github.com/pivotal-cf/aqueduct-courier/config"
 	"github.com/pivotal-cf/aqueduct-courier/credhub"
+	"github.com/pivotal-cf/aqueduct-courier/lifecycle"
+	"github.com/pivotal-cf/aqueduct-courier/lint"
 
 	"github.com/pivotal-cf/aqueduct-courier/coreconsumption"
 	"github.com/pivotal-cf/aqueduct-courier/operations"
 	"github.com/pivotal-cf/aqueduct-courier/opsmanager"
+	"github.com/pivotal-cf/aqueduct-courier/pseudonym"
+	"github.com/pivotal-cf/aqueduct-courier/secretscan"
 	omNetwork "github.com/pivotal-cf/om/network"
+	"github.com/pivotal-cf/telemetry-utils/collector_tar"
 	"github.com/pivotal-cf/telemetry-utils/tar"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -35,6 +46,10 @@ import (
 
 const (
 	ConfigFileKey                = "CONFIG_FILE"
+	OmEnvFileKey                 = "OM_ENV_FILE"
+	VarsFileKey                  = "VARS_FILE"
+	VarsEnvKey                   = "VARS_ENV"
+	ProfileKey                   = "PROFILE"
 	OpsManagerURLKey             = "OPS_MANAGER_URL"
 	OpsManagerURLAliasKey        = "TARGET"
 	OpsManagerUsernameKey        = "OPS_MANAGER_USERNAME"
@@ -48,6 +63,7 @@ const (
 	SkipTlsVerifyKey             = "INSECURE_SKIP_TLS_VERIFY"
 	SkipTlsVerifyKeyAlias        = "SKIP_SSL_VALIDATION"
 	WithCredhubInfoKey           = "WITH_CREDHUB_INFO"
+	WithBoshInfoKey              = "WITH_BOSH_INFO"
 	UsageServiceURLKey           = "USAGE_SERVICE_URL"
 	UsageServiceClientIDKey      = "USAGE_SERVICE_CLIENT_ID"
 	UsageServiceClientSecretKey  = "USAGE_SERVICE_CLIENT_SECRET"
@@ -56,8 +72,32 @@ const (
 	UsageServiceSkipTlsVerifyKey = "USAGE_SERVICE_INSECURE_SKIP_TLS_VERIFY"
 	FoundationNicknameKey        = "FOUNDATION_NICKNAME"
 	OperationalDataOnlyKey       = "OPERATIONAL_DATA_ONLY"
+	WithCfInventoryKey           = "WITH_CF_INVENTORY"
+	UsageFromCfApiKey            = "USAGE_FROM_CF_API"
+	LifecycleCatalogKey          = "LIFECYCLE_CATALOG"
+	LintKey                      = "LINT"
+	LintRulesKey                 = "LINT_RULES"
+	DatasetsKey                  = "DATASETS"
+	IncludeProductTypeKey        = "INCLUDE_PRODUCT_TYPE"
+	ExcludeProductTypeKey        = "EXCLUDE_PRODUCT_TYPE"
+	ExcludeProductGUIDKey        = "EXCLUDE_PRODUCT_GUID"
+	ProductConfigSourceKey       = "PRODUCT_CONFIG_SOURCE"
+	PendingChangesKey            = "PENDING_CHANGES"
+	IfInstallingKey              = "IF_INSTALLING"
+	InstallingPollIntervalKey    = "INSTALLING_POLL_INTERVAL"
+	InstallingMaxWaitKey         = "INSTALLING_MAX_WAIT"
+	InstallationsSinceKey        = "INSTALLATIONS_SINCE"
+	InstallationsMaxKey          = "INSTALLATIONS_MAX"
+	PseudonymizeKey              = "PSEUDONYMIZE"
+	PseudonymizeSaltKey          = "PSEUDONYMIZE_SALT"
+	KeepFoundationIDKey          = "KEEP_FOUNDATION_ID"
+	OnSecretDetectedKey          = "ON_SECRET_DETECTED"
 
 	ConfigFlag                    = "config"
+	OmEnvFileFlag                 = "om-env"
+	VarsFileFlag                  = "vars-file"
+	VarsEnvFlag                   = "vars-env"
+	ProfileFlag                   = "profile"
 	OpsManagerURLFlag             = "url"
 	OpsManagerURLAliasFlag        = "target"
 	OpsManagerUsernameFlag        = "username"
@@ -67,6 +107,7 @@ const (
 	OpsManagerTimeoutFlag         = "ops-manager-timeout"
 	OpsManagerRequestTimeoutFlag  = "ops-manager-request-timeout"
 	CollectFromCredhubFlag        = "with-credhub-info"
+	CollectFromBoshFlag           = "with-bosh-info"
 	EnvTypeFlag                   = "env-type"
 	OutputPathFlag                = "output-dir"
 	SkipTlsVerifyFlag             = "insecure-skip-tls-verify"
@@ -79,6 +120,26 @@ const (
 	UsageServiceSkipTlsVerifyFlag = "usage-service-insecure-skip-tls-verify"
 	FoundationNicknameFlag        = "foundation-nickname"
 	OperationalDataOnlyFlag       = "operational-data-only"
+	CollectCfInventoryFlag        = "with-cf-inventory"
+	UsageFromCfApiFlag            = "usage-from-cf-api"
+	LifecycleCatalogFlag          = "lifecycle-catalog"
+	LintFlag                      = "lint"
+	LintRulesFlag                 = "lint-rules"
+	DatasetsFlag                  = "datasets"
+	IncludeProductTypeFlag        = "include-product-type"
+	ExcludeProductTypeFlag        = "exclude-product-type"
+	ExcludeProductGUIDFlag        = "exclude-product-guid"
+	ProductConfigSourceFlag       = "product-config-source"
+	PendingChangesFlag            = "pending-changes"
+	IfInstallingFlag              = "if-installing"
+	InstallingPollIntervalFlag    = "installing-poll-interval"
+	InstallingMaxWaitFlag         = "installing-max-wait"
+	InstallationsSinceFlag        = "installations-since"
+	InstallationsMaxFlag          = "installations-max"
+	PseudonymizeFlag              = "pseudonymize"
+	PseudonymizeSaltFlag          = "pseudonymize-salt"
+	KeepFoundationIDFlag          = "keep-foundation-id"
+	OnSecretDetectedFlag          = "on-secret-detected"
 
 	EnvTypeSandbox       = "sandbox"
 	EnvTypeDevelopment   = "development"
@@ -86,14 +147,37 @@ const (
 	EnvTypePreProduction = "pre-production"
 	EnvTypeProduction    = "production"
 
-	OutputFilePrefix                 = "FoundationDetails_"
-	CredhubClientError               = "Failed creating credhub client"
-	InvalidEnvTypeFailureFormat      = "Invalid env-type %s. See help for the list of valid types."
-	InvalidAuthConfigurationMessage  = "Invalid auth configuration. Requires username/password or client/secret to be set."
-	InvalidUsageConfigurationMessage = "Not all usage service configurations provided."
-	CreateTarFileFailureFormat       = "Could not create tar file %s"
-	UsageServiceURLParsingError      = "error parsing Usage Service URL"
-	GetUAAURLError                   = "error getting UAA URL"
+	OutputFilePrefix                       = "FoundationDetails_"
+	PseudonymLookupTableSuffix             = ".pseudonyms.json"
+	CredhubClientError                     = "Failed creating credhub client"
+	BoshDirectorInfoError                  = "error reading BOSH director info"
+	BoshDirectorWithoutUAAFormat           = "BOSH director at %s does not authenticate with UAA"
+	InvalidEnvTypeFailureFormat            = "Invalid env-type %s. See help for the list of valid types."
+	InvalidAuthConfigurationMessage        = "Invalid auth configuration. Requires username/password or client/secret to be set."
+	InvalidUsageConfigurationMessage       = "Not all usage service configurations provided."
+	CreateTarFileFailureFormat             = "Could not create tar file %s"
+	UsageServiceURLParsingError            = "error parsing Usage Service URL"
+	GetUAAURLError                         = "error getting UAA URL"
+	CfApiURLParsingError                   = "error parsing CF API URL"
+	InvalidCfInventoryConfigMessage        = "--with-cf-inventory requires --cf-api-url, --usage-service-client-id and --usage-service-client-secret"
+	InvalidUsageSnapshotConfigMessage      = "--usage-from-cf-api requires --cf-api-url, --usage-service-client-id and --usage-service-client-secret"
+	UsageSnapshotWithUsageServiceMessage   = "--usage-from-cf-api cannot be used with --usage-service-url"
+	DatasetsWithOperationalDataOnlyMessage = "--datasets cannot be used with --operational-data-only"
+	SkippedWhileInstallingMessage          = "Skipping collection: an installation is running on this Operations Manager"
+	InvalidInstallingPollIntervalMessage   = "--installing-poll-interval must be greater than 0"
+	InvalidInstallingMaxWaitMessage        = "--installing-max-wait cannot be negative"
+	ReadConfigFileErrorFormat              = "error reading config file: %s \n"
+	ReadOmEnvFileErrorFormat               = "error reading om env file: %s \n"
+	OmEnvUnsupportedKeyWarningFormat       = "Warning: %s in the om env file is not supported by the collector and is ignored"
+	LoadVarsErrorFormat                    = "error loading vars: %s \n"
+	ProfileWithoutConfigMessage            = "--profile requires a config file to be set with --config"
+	OpsManagerRoleFormat                   = "Authenticated with the Ops Manager %s role"
+	PseudonymizeWithoutSaltMessage         = "--pseudonymize requires --pseudonymize-salt"
+	FoundationIDWithoutPseudonymizeMessage = "--keep-foundation-id requires --pseudonymize"
+	WrotePseudonymLookupTableFormat        = "Wrote the pseudonym lookup table to %s, keep it local: it is never sent and reverses the pseudonyms\n"
+	UnknownOpsManagerRoleFormat            = "Could not determine the Ops Manager role, datasets refused by Ops Manager will be skipped: %s"
+	OpsManagerVersionFormat                = "Ops Manager version %s"
+	UnknownOpsManagerVersionFormat         = "Could not determine the Ops Manager version, every dataset will be requested: %s"
 )
 
 var collectCmd = &cobra.Command{
@@ -104,34 +188,7 @@ var collectCmd = &cobra.Command{
 }
 
 func init() {
-	bindFlagAndEnvVar(collectCmd, OpsManagerURLFlag, "", fmt.Sprintf("``Ops Manager URL [$%s]", OpsManagerURLKey), OpsManagerURLKey)
-	bindFlagAndEnvVar(collectCmd, OpsManagerURLAliasFlag, "", fmt.Sprintf("``Ops Manager URL [$%s]", OpsManagerURLAliasKey), OpsManagerURLAliasKey)
-	_ = collectCmd.Flags().MarkHidden(OpsManagerURLAliasFlag)
-
-	bindFlagAndEnvVar(collectCmd, OpsManagerUsernameFlag, "", fmt.Sprintf("``Ops Manager username [$%s]", OpsManagerUsernameKey), OpsManagerUsernameKey)
-	bindFlagAndEnvVar(collectCmd, OpsManagerPasswordFlag, "", fmt.Sprintf("``Ops Manager password [$%s]", OpsManagerPasswordKey), OpsManagerPasswordKey)
-	bindFlagAndEnvVar(collectCmd, OpsManagerClientIdFlag, "", fmt.Sprintf("``Ops Manager client id [$%s]", OpsManagerClientIdKey), OpsManagerClientIdKey)
-	bindFlagAndEnvVar(collectCmd, OpsManagerClientSecretFlag, "", fmt.Sprintf("``Ops Manager client secret [$%s]", OpsManagerClientSecretKey), OpsManagerClientSecretKey)
-	bindFlagAndEnvVar(collectCmd, EnvTypeFlag, "", fmt.Sprintf("``Specify environment type (sandbox, development, qa, pre-production, production) [$%s]", EnvTypeKey), EnvTypeKey)
-	bindFlagAndEnvVar(collectCmd, FoundationNicknameFlag, "", fmt.Sprintf("``Specify foundation nickname used in reporting by VMware [$%s]", FoundationNicknameKey), FoundationNicknameKey)
-	bindFlagAndEnvVar(collectCmd, OperationalDataOnlyFlag, false, fmt.Sprintf("``Collect only operational data [$%s]", OperationalDataOnlyKey), OperationalDataOnlyKey)
-	bindFlagAndEnvVar(collectCmd, OpsManagerTimeoutFlag, 30, fmt.Sprintf("``Timeout on network connection to Ops Manager in seconds [$%s]", OpsManagerTimeoutKey), OpsManagerTimeoutKey)
-	bindFlagAndEnvVar(collectCmd, OpsManagerRequestTimeoutFlag, 30, fmt.Sprintf("``Timeout on request fulfillment from Ops Manager in seconds [$%s]", OpsManagerRequestTimeoutKey), OpsManagerRequestTimeoutKey)
-	bindFlagAndEnvVar(collectCmd, SkipTlsVerifyFlag, false, fmt.Sprintf("``Skip TLS validation on http requests to Ops Manager [$%s]\n", SkipTlsVerifyKey), SkipTlsVerifyKey)
-	bindFlagAndEnvVar(collectCmd, SkipTlsVerifyAliasFlag, false, fmt.Sprintf("``Ops Manager URL [$%s]", SkipTlsVerifyKeyAlias), SkipTlsVerifyKeyAlias)
-	_ = collectCmd.Flags().MarkHidden(SkipTlsVerifyAliasFlag)
-
-	bindFlagAndEnvVar(collectCmd, CfApiURLFlag, "", fmt.Sprintf("``CF API URL for UAA authentication to access Usage Service [$%s]", CfApiURLKey), CfApiURLKey)
-	bindFlagAndEnvVar(collectCmd, UsageServiceURLFlag, "", fmt.Sprintf("``Usage Service URL [$%s]", UsageServiceURLKey), UsageServiceURLKey)
-	bindFlagAndEnvVar(collectCmd, UsageServiceClientIDFlag, "", fmt.Sprintf("``Usage Service client id [$%s]", UsageServiceClientIDKey), UsageServiceClientIDKey)
-	bindFlagAndEnvVar(collectCmd, UsageServiceClientSecretFlag, "", fmt.Sprintf("``Usage Service client secret [$%s]", UsageServiceClientSecretKey), UsageServiceClientSecretKey)
-	bindFlagAndEnvVar(collectCmd, UsageServiceSkipTlsVerifyFlag, false, fmt.Sprintf("``Skip TLS validation for Usage Service components [$%s]\n", UsageServiceSkipTlsVerifyKey), UsageServiceSkipTlsVerifyKey)
-	bindFlagAndEnvVar(collectCmd, UsageServiceTimeoutFlag, 30, fmt.Sprintf("``Timeout on request connection and fulfillment to Usage Service in seconds [$%s]", UsageServiceTimeoutKey), UsageServiceTimeoutKey)
-
-	bindFlagAndEnvVar(collectCmd, CollectFromCredhubFlag, false, fmt.Sprintf("Include CredHub certificate expiry information [$%s]\n", WithCredhubInfoKey), WithCredhubInfoKey)
-	bindFlagAndEnvVar(collectCmd, OutputPathFlag, "", fmt.Sprintf("``Local directory to write data [$%s]\n", OutputPathKey), OutputPathKey)
-
-	bindFlagAndEnvVar(collectCmd, ConfigFlag, "", fmt.Sprintf("``Config file for all other command line arguments, requires a file extension e.g. '.yml' or '.json' [$%s]\n", ConfigFileKey), ConfigFileKey)
+	addCollectFlags(collectCmd)
 
 	collectCmd.Flags().BoolP("help", "h", false, "Help for the collect command\n")
 	collectCmd.Flags().SortFlags = false
@@ -150,7 +207,86 @@ func init() {
       telemetry-collector collect --url --username --password [or --client-id and
       --client-secret] --usage-service-url --usage-service-client-id
       --usage-service-client-secret --cf-api-url --env-type --output-dir
-      --operational-data-only`
+      --operational-data-only
+
+      Collect Telemetry data and core counts, without Usage Service:
+      telemetry-collector collect --url --username --password [or --client-id and
+      --client-secret] --datasets opsmanager,core_consumption --env-type
+      --output-dir
+
+      Collect Telemetry data with at most 500 installations started since 2024:
+      telemetry-collector collect --url --username --password [or --client-id and
+      --client-secret] --installations-since 2024-01-01 --installations-max 500
+      --env-type --output-dir
+
+      Collect Telemetry data without errands or the director network shape:
+      telemetry-collector collect --url --username --password [or --client-id and
+      --client-secret] --datasets opsmanager,stemcell_associations --env-type
+      --output-dir
+
+      Collect Telemetry data and the CF platform inventory:
+      telemetry-collector collect --url --username --password [or --client-id and
+      --client-secret] --cf-api-url --usage-service-client-id
+      --usage-service-client-secret --with-cf-inventory --env-type --output-dir
+
+      Collect Telemetry data and app and service instance counts from the CF API,
+      for foundations without Usage Service:
+      telemetry-collector collect --url --username --password [or --client-id and
+      --client-secret] --cf-api-url --usage-service-client-id
+      --usage-service-client-secret --usage-from-cf-api --env-type --output-dir
+
+      Collect Telemetry data without the properties of partner tiles:
+      telemetry-collector collect --url --username --password [or --client-id and
+      --client-secret] --exclude-product-type 'partner-*' --env-type --output-dir
+
+      Collect Telemetry data with the product configuration that is deployed
+      rather than staged:
+      telemetry-collector collect --url --username --password [or --client-id and
+      --client-secret] --product-config-source deployed --env-type --output-dir
+
+      Collect Telemetry data only when Ops Manager has no pending changes,
+      exiting with code 3 otherwise:
+      telemetry-collector collect --url --username --password [or --client-id and
+      --client-secret] --pending-changes fail --env-type --output-dir
+
+      Collect Telemetry data once a running Apply Changes has finished, waiting
+      at most an hour:
+      telemetry-collector collect --url --username --password [or --client-id and
+      --client-secret] --if-installing wait --installing-max-wait 3600 --env-type
+      --output-dir
+
+      Collect Telemetry data and BOSH director deployments, stemcells and releases:
+      telemetry-collector collect --url --username --password [or --client-id and
+      --client-secret] --with-bosh-info --env-type --output-dir
+
+      Collect Telemetry data and a freshness report of the deployed stemcells and
+      releases:
+      telemetry-collector collect --url --username --password [or --client-id and
+      --client-secret] --with-bosh-info --lifecycle-catalog catalog.yml
+      --env-type --output-dir
+
+      Collect Telemetry data and report findings of the built-in best practice
+      rules, or of the rules in a file:
+      telemetry-collector collect --url --username --password [or --client-id and
+      --client-secret] --lint [or --lint-rules rules.yml] --env-type --output-dir
+
+      Collect Telemetry data with GUIDs, hostnames and IP addresses replaced by
+      keyed hashes, keeping the foundation id:
+      telemetry-collector collect --url --username --password [or --client-id and
+      --client-secret] --pseudonymize --pseudonymize-salt [or $PSEUDONYMIZE_SALT]
+      --keep-foundation-id --env-type --output-dir
+
+      Collect Telemetry data, failing instead of redacting when the data holds
+      secret-shaped content:
+      telemetry-collector collect --url --username --password [or --client-id and
+      --client-secret] --on-secret-detected fail --env-type --output-dir
+
+      Collect Telemetry data using an om CLI env file and interpolated config:
+      telemetry-collector collect --om-env env.yml --config config.yml
+      --vars-file vars.yml --vars-env OM_VAR
+
+      Collect Telemetry data for one foundation of a shared config file:
+      telemetry-collector collect --config config.yml --profile prod-east`
 
 	customUsageTextTemplate := `
 USAGE EXAMPLES
@@ -160,23 +296,85 @@ FLAGS
 
 {{.LocalFlags.FlagUsages}}`
 
+	cobra.AddTemplateFunc("minimumRoles", opsmanager.MinimumRolesHelp)
+
 	customHelpTextTemplate := fmt.Sprintf(`
 Collects information from a single Ops Manager (and optionally from
 Usage Service and/or Credhub) and outputs the content to the configured directory.
-%s`, customUsageTextTemplate)
+%s
+OPS MANAGER ROLES
+
+Datasets the authenticated Ops Manager role cannot read are skipped and listed
+in the %s_%s file. The minimum role for each dataset:
+
+{{minimumRoles}}`, customUsageTextTemplate, collector_tar.OpsManagerProductType, opsmanager.CollectionDetailsDataType)
 
 	collectCmd.SetHelpTemplate(customHelpTextTemplate)
 	collectCmd.SetUsageTemplate(customUsageTextTemplate)
 	rootCmd.AddCommand(collectCmd)
 }
 
+// addCollectFlags defines the flags describing how to reach a foundation, so
+// that every command which contacts it accepts the same configuration
+func addCollectFlags(c *cobra.Command) {
+	bindFlagAndEnvVar(c, OpsManagerURLFlag, "", fmt.Sprintf("``Ops Manager URL [$%s]", OpsManagerURLKey), OpsManagerURLKey)
+	bindFlagAndEnvVar(c, OpsManagerURLAliasFlag, "", fmt.Sprintf("``Ops Manager URL [$%s]", OpsManagerURLAliasKey), OpsManagerURLAliasKey)
+	_ = c.Flags().MarkHidden(OpsManagerURLAliasFlag)
+
+	bindFlagAndEnvVar(c, OpsManagerUsernameFlag, "", fmt.Sprintf("``Ops Manager username [$%s]", OpsManagerUsernameKey), OpsManagerUsernameKey)
+	bindFlagAndEnvVar(c, OpsManagerPasswordFlag, "", fmt.Sprintf("``Ops Manager password [$%s]", OpsManagerPasswordKey), OpsManagerPasswordKey)
+	bindFlagAndEnvVar(c, OpsManagerClientIdFlag, "", fmt.Sprintf("``Ops Manager client id [$%s]", OpsManagerClientIdKey), OpsManagerClientIdKey)
+	bindFlagAndEnvVar(c, OpsManagerClientSecretFlag, "", fmt.Sprintf("``Ops Manager client secret [$%s]", OpsManagerClientSecretKey), OpsManagerClientSecretKey)
+	bindFlagAndEnvVar(c, EnvTypeFlag, "", fmt.Sprintf("``Specify environment type (sandbox, development, qa, pre-production, production) [$%s]", EnvTypeKey), EnvTypeKey)
+	bindFlagAndEnvVar(c, FoundationNicknameFlag, "", fmt.Sprintf("``Specify foundation nickname used in reporting by VMware [$%s]", FoundationNicknameKey), FoundationNicknameKey)
+	bindFlagAndEnvVar(c, DatasetsFlag, []string{}, fmt.Sprintf("``Datasets to collect, comma separated: %s. Defaults to the datasets chosen by the other flags, and %s are only collected when named here [$%s]", strings.Join(operations.DatasetNames, ", "), strings.Join(operations.OpsManagerDatasets, ", "), DatasetsKey), DatasetsKey)
+	bindFlagAndEnvVar(c, OperationalDataOnlyFlag, false, fmt.Sprintf("``Collect only operational data, the same as --datasets %s,%s [$%s]", operations.UsageServiceDataset, operations.CoreConsumptionDataset, OperationalDataOnlyKey), OperationalDataOnlyKey)
+	bindFlagAndEnvVar(c, IncludeProductTypeFlag, []string{}, fmt.Sprintf("``Collect resources and properties only of products with these types, comma separated globs e.g. 'cf,p-*' [$%s]", IncludeProductTypeKey), IncludeProductTypeKey)
+	bindFlagAndEnvVar(c, ExcludeProductTypeFlag, []string{}, fmt.Sprintf("``Do not collect resources and properties of products with these types, comma separated globs [$%s]", ExcludeProductTypeKey), ExcludeProductTypeKey)
+	bindFlagAndEnvVar(c, ExcludeProductGUIDFlag, []string{}, fmt.Sprintf("``Do not collect resources and properties of products with these GUIDs, comma separated globs [$%s]", ExcludeProductGUIDKey), ExcludeProductGUIDKey)
+	bindFlagAndEnvVar(c, ProductConfigSourceFlag, string(opsmanager.StagedConfigSource), fmt.Sprintf("``Read product resources and properties from the %s configuration, or from the %s manifests which describe what is running [$%s]", opsmanager.StagedConfigSource, opsmanager.DeployedConfigSource, ProductConfigSourceKey), ProductConfigSourceKey)
+	bindFlagAndEnvVar(c, PendingChangesFlag, string(opsmanager.WarnOnPendingChanges), fmt.Sprintf("``When Ops Manager has pending changes: %s and collect, %s with exit code %d, or %s with changes [$%s]", opsmanager.WarnOnPendingChanges, opsmanager.FailOnPendingChanges, PendingChangesExistsExitCode, opsmanager.SkipChangedProducts, PendingChangesKey), PendingChangesKey)
+	bindFlagAndEnvVar(c, IfInstallingFlag, string(opsmanager.ProceedIfInstalling), fmt.Sprintf("``When Apply Changes is running: %s for it to finish, %s the collection, or %s [$%s]", opsmanager.WaitIfInstalling, opsmanager.SkipIfInstalling, opsmanager.ProceedIfInstalling, IfInstallingKey), IfInstallingKey)
+	bindFlagAndEnvVar(c, InstallingPollIntervalFlag, 30, fmt.Sprintf("``Interval between checks for a running installation in seconds, with --if-installing wait [$%s]", InstallingPollIntervalKey), InstallingPollIntervalKey)
+	bindFlagAndEnvVar(c, InstallingMaxWaitFlag, 3600, fmt.Sprintf("``Maximum wait for a running installation in seconds, with --if-installing wait [$%s]", InstallingMaxWaitKey), InstallingMaxWaitKey)
+	bindFlagAndEnvVar(c, InstallationsSinceFlag, "", fmt.Sprintf("``Collect only installations started since this date (e.g. 2024-01-31) or RFC 3339 time. Installation analytics always cover the whole history [$%s]", InstallationsSinceKey), InstallationsSinceKey)
+	bindFlagAndEnvVar(c, InstallationsMaxFlag, 0, fmt.Sprintf("``Collect at most this many of the newest installations, 0 for all [$%s]", InstallationsMaxKey), InstallationsMaxKey)
+	bindFlagAndEnvVar(c, OpsManagerTimeoutFlag, 30, fmt.Sprintf("``Timeout on network connection to Ops Manager in seconds [$%s]", OpsManagerTimeoutKey), OpsManagerTimeoutKey)
+	bindFlagAndEnvVar(c, OpsManagerRequestTimeoutFlag, 30, fmt.Sprintf("``Timeout on request fulfillment from Ops Manager in seconds [$%s]", OpsManagerRequestTimeoutKey), OpsManagerRequestTimeoutKey)
+	bindFlagAndEnvVar(c, SkipTlsVerifyFlag, false, fmt.Sprintf("``Skip TLS validation on http requests to Ops Manager [$%s]\n", SkipTlsVerifyKey), SkipTlsVerifyKey)
+	bindFlagAndEnvVar(c, SkipTlsVerifyAliasFlag, false, fmt.Sprintf("``Ops Manager URL [$%s]", SkipTlsVerifyKeyAlias), SkipTlsVerifyKeyAlias)
+	_ = c.Flags().MarkHidden(SkipTlsVerifyAliasFlag)
+
+	bindFlagAndEnvVar(c, CfApiURLFlag, "", fmt.Sprintf("``CF API URL for UAA authentication to access Usage Service [$%s]", CfApiURLKey), CfApiURLKey)
+	bindFlagAndEnvVar(c, UsageServiceURLFlag, "", fmt.Sprintf("``Usage Service URL [$%s]", UsageServiceURLKey), UsageServiceURLKey)
+	bindFlagAndEnvVar(c, UsageServiceClientIDFlag, "", fmt.Sprintf("``Usage Service client id [$%s]", UsageServiceClientIDKey), UsageServiceClientIDKey)
+	bindFlagAndEnvVar(c, UsageServiceClientSecretFlag, "", fmt.Sprintf("``Usage Service client secret [$%s]", UsageServiceClientSecretKey), UsageServiceClientSecretKey)
+	bindFlagAndEnvVar(c, UsageServiceSkipTlsVerifyFlag, false, fmt.Sprintf("``Skip TLS validation for Usage Service components [$%s]\n", UsageServiceSkipTlsVerifyKey), UsageServiceSkipTlsVerifyKey)
+	bindFlagAndEnvVar(c, UsageFromCfApiFlag, false, fmt.Sprintf("``Without Usage Service, record point in time app and service instance counts read from the CF API with the Usage Service client [$%s]", UsageFromCfApiKey), UsageFromCfApiKey)
+	bindFlagAndEnvVar(c, UsageServiceTimeoutFlag, 30, fmt.Sprintf("``Timeout on request connection and fulfillment to Usage Service in seconds [$%s]", UsageServiceTimeoutKey), UsageServiceTimeoutKey)
+
+	bindFlagAndEnvVar(c, CollectFromCredhubFlag, false, fmt.Sprintf("Include CredHub certificate expiry information [$%s]", WithCredhubInfoKey), WithCredhubInfoKey)
+	bindFlagAndEnvVar(c, CollectFromBoshFlag, false, fmt.Sprintf("Include BOSH director deployments, stemcells and releases [$%s]", WithBoshInfoKey), WithBoshInfoKey)
+	bindFlagAndEnvVar(c, CollectCfInventoryFlag, false, fmt.Sprintf("Include aggregate counts of CF orgs, spaces, apps, buildpacks, stacks and services, read from the CF API with the Usage Service client [$%s]", WithCfInventoryKey), WithCfInventoryKey)
+	bindFlagAndEnvVar(c, LifecycleCatalogFlag, "", fmt.Sprintf("``Lifecycle catalog of stemcell and release versions, includes a freshness report of the versions deployed [$%s]\n", LifecycleCatalogKey), LifecycleCatalogKey)
+	bindFlagAndEnvVar(c, LintFlag, false, fmt.Sprintf("Report findings of the built-in best practice rules, printed and written to the data [$%s]", LintKey), LintKey)
+	bindFlagAndEnvVar(c, LintRulesFlag, "", fmt.Sprintf("``YAML file of lint rules to report findings of instead of the built-in rules [$%s]\n", LintRulesKey), LintRulesKey)
+	bindFlagAndEnvVar(c, PseudonymizeFlag, false, fmt.Sprintf("Replace product GUIDs, installation ids, CredHub certificate names, hostnames and IP addresses with keyed hashes, and write a local lookup table next to the data [$%s]", PseudonymizeKey), PseudonymizeKey)
+	bindFlagAndEnvVar(c, PseudonymizeSaltFlag, "", fmt.Sprintf("``Secret salt of at least 16 characters keying the hashes, the same salt gives the same hashes in every collection [$%s]", PseudonymizeSaltKey), PseudonymizeSaltKey)
+	bindFlagAndEnvVar(c, KeepFoundationIDFlag, false, fmt.Sprintf("Keep the foundation id in the metadata un-hashed, with --pseudonymize [$%s]\n", KeepFoundationIDKey), KeepFoundationIDKey)
+	bindFlagAndEnvVar(c, OnSecretDetectedFlag, string(secretscan.WarnOnSecrets), fmt.Sprintf("``When collected data holds a private key, JWT, password, AWS key or high entropy string: %s without writing the data, %s it, or %s and write it as is [$%s]", secretscan.FailOnSecrets, secretscan.RedactSecrets, secretscan.WarnOnSecrets, OnSecretDetectedKey), OnSecretDetectedKey)
+	bindFlagAndEnvVar(c, OutputPathFlag, "", fmt.Sprintf("``Local directory to write data [$%s]\n", OutputPathKey), OutputPathKey)
+
+	bindFlagAndEnvVar(c, ConfigFlag, "", fmt.Sprintf("``Config file for all other command line arguments, requires a file extension e.g. '.yml' or '.json' [$%s]", ConfigFileKey), ConfigFileKey)
+	bindFlagAndEnvVar(c, ProfileFlag, "", fmt.Sprintf("``Named profile from the config file to merge over its shared settings [$%s]", ProfileKey), ProfileKey)
+	bindFlagAndEnvVar(c, OmEnvFileFlag, "", fmt.Sprintf("``om CLI env file with the Ops Manager target and credentials [$%s]", OmEnvFileKey), OmEnvFileKey)
+	bindFlagAndEnvVar(c, VarsFileFlag, []string{}, fmt.Sprintf("``Load variables for ((var)) placeholders in the config and om env files from a YAML file, can be repeated [$%s]", VarsFileKey), VarsFileKey)
+	bindFlagAndEnvVar(c, VarsEnvFlag, []string{}, fmt.Sprintf("``Load variables from environment variables with this prefix (e.g. 'MY' to load MY_var=value), can be repeated [$%s]\n", VarsEnvKey), VarsEnvKey)
+}
+
 func collect(c *cobra.Command, _ []string) error {
-	if useConfigFile() {
-		viper.SetConfigFile(viper.GetString(ConfigFlag))
-		err := viper.ReadInConfig()
-		if err != nil {
-			return fmt.Errorf("error reading config file: %s \n", err)
-		}
+	if err := loadConfigFiles(collectConfigSchema(c.Flags())); err != nil {
+		return err
 	}
 
 	handleAliases(c)
@@ -197,6 +395,52 @@ func collect(c *cobra.Command, _ []string) error {
 		return err
 	}
 
+	datasets, err := selectedDatasets()
+	if err != nil {
+		return err
+	}
+
+	productFilter, err := selectedProductFilter()
+	if err != nil {
+		return err
+	}
+
+	configSource, err := opsmanager.ParseConfigSource(viper.GetString(ProductConfigSourceFlag))
+	if err != nil {
+		return err
+	}
+
+	pendingChangesPolicy, err := opsmanager.ParsePendingChangesPolicy(viper.GetString(PendingChangesFlag))
+	if err != nil {
+		return err
+	}
+
+	installingPolicy, err := opsmanager.ParseInstallingPolicy(viper.GetString(IfInstallingFlag))
+	if err != nil {
+		return err
+	}
+	if viper.GetInt(InstallingPollIntervalFlag) <= 0 {
+		return errors.New(InvalidInstallingPollIntervalMessage)
+	}
+	if viper.GetInt(InstallingMaxWaitFlag) < 0 {
+		return errors.New(InvalidInstallingMaxWaitMessage)
+	}
+
+	installationsWindow, err := opsmanager.NewInstallationsWindow(viper.GetString(InstallationsSinceFlag), viper.GetInt(InstallationsMaxFlag))
+	if err != nil {
+		return err
+	}
+
+	pseudonymizer, err := makePseudonymizer()
+	if err != nil {
+		return err
+	}
+
+	secretPolicy, err := secretscan.ParsePolicy(viper.GetString(OnSecretDetectedFlag))
+	if err != nil {
+		return err
+	}
+
 	c.SilenceUsage = true
 
 	tarFilePath := filepath.Join(
@@ -211,8 +455,7 @@ func collect(c *cobra.Command, _ []string) error {
 
 	tarWriter := tar.NewTarWriter(tarFile)
 
-	operationalDataOnly := viper.GetBool(OperationalDataOnlyFlag)
-	collectExecutor, err := makeCollector(tarWriter, operationalDataOnly)
+	collectExecutor, err := makeCollector(tarWriter, envType, datasets, productFilter, configSource, pendingChangesPolicy, installingPolicy, installationsWindow, pseudonymizer, secretPolicy)
 	if err != nil {
 		tarFile.Close()
 		os.Remove(tarFilePath)
@@ -223,9 +466,21 @@ func collect(c *cobra.Command, _ []string) error {
 	if err != nil {
 		tarFile.Close()
 		os.Remove(tarFilePath)
+		if errors.Is(err, opsmanager.InstallationRunningError) {
+			logger.Println(SkippedWhileInstallingMessage)
+			return nil
+		}
 		return err
 	}
 
+	if pseudonymizer != nil {
+		lookupTablePath := strings.TrimSuffix(tarFilePath, filepath.Ext(tarFilePath)) + PseudonymLookupTableSuffix
+		if err := pseudonymizer.WriteLookupTable(lookupTablePath); err != nil {
+			return err
+		}
+		logger.Printf(WrotePseudonymLookupTableFormat, lookupTablePath)
+	}
+
 	logger.Printf("Wrote output to %s\n", tarFilePath)
 	logger.Println("Success!")
 	return nil
@@ -255,6 +510,86 @@ func useConfigFile() bool {
 	return viper.GetString(ConfigFlag) != ""
 }
 
+func useOmEnvFile() bool {
+	return viper.GetString(OmEnvFileFlag) != ""
+}
+
+func loadConfigFiles(schema config.Schema) error {
+	if !useConfigFile() && viper.GetString(ProfileFlag) != "" {
+		return errors.New(ProfileWithoutConfigMessage)
+	}
+	if !useConfigFile() && !useOmEnvFile() {
+		return nil
+	}
+
+	vars, err := config.LoadVars(viper.GetStringSlice(VarsFileFlag), viper.GetStringSlice(VarsEnvFlag), os.Environ())
+	if err != nil {
+		return fmt.Errorf(LoadVarsErrorFormat, err)
+	}
+
+	if useConfigFile() {
+		configFile, err := config.ReadFile(viper.GetString(ConfigFlag), vars, schema)
+		if err != nil {
+			return fmt.Errorf(ReadConfigFileErrorFormat, err)
+		}
+		settings, err := configFile.Settings(viper.GetString(ProfileFlag))
+		if err != nil {
+			return fmt.Errorf(ReadConfigFileErrorFormat, err)
+		}
+		if err := viper.MergeConfigMap(settings); err != nil {
+			return fmt.Errorf(ReadConfigFileErrorFormat, err)
+		}
+	}
+
+	if useOmEnvFile() {
+		omEnv, err := readOmEnv(vars)
+		if err != nil {
+			return err
+		}
+		if err := viper.MergeConfigMap(omEnvSettings(omEnv)); err != nil {
+			return fmt.Errorf(ReadOmEnvFileErrorFormat, err)
+		}
+	}
+
+	return nil
+}
+
+// readOmEnv reads the om env file, warning about keys the collector does not
+// use so that, for example, a ca-cert is not mistaken for being trusted.
+func readOmEnv(vars map[string]interface{}) (config.OmEnv, error) {
+	omEnv, err := config.ReadOmEnv(viper.GetString(OmEnvFileFlag), vars)
+	if err != nil {
+		return config.OmEnv{}, fmt.Errorf(ReadOmEnvFileErrorFormat, err)
+	}
+	for _, key := range omEnv.UnsupportedKeys() {
+		logger.Printf(OmEnvUnsupportedKeyWarningFormat, key)
+	}
+	return omEnv, nil
+}
+
+// omEnvSettings maps the om env file keys onto the collect config keys.
+// Settings from the om env file take precedence over the config file, but
+// not over flags or environment variables.
+func omEnvSettings(omEnv config.OmEnv) map[string]interface{} {
+	settings := map[string]interface{}{}
+	setIfPresent := func(key string, value interface{}, present bool) {
+		if present {
+			settings[key] = value
+		}
+	}
+
+	setIfPresent(OpsManagerURLFlag, omEnv.Target, omEnv.Target != "")
+	setIfPresent(OpsManagerUsernameFlag, omEnv.Username, omEnv.Username != "")
+	setIfPresent(OpsManagerPasswordFlag, omEnv.Password, omEnv.Password != "")
+	setIfPresent(OpsManagerClientIdFlag, omEnv.ClientID, omEnv.ClientID != "")
+	setIfPresent(OpsManagerClientSecretFlag, omEnv.ClientSecret, omEnv.ClientSecret != "")
+	setIfPresent(SkipTlsVerifyAliasFlag, omEnv.SkipSSLValidation, omEnv.SkipSSLValidation)
+	setIfPresent(OpsManagerTimeoutFlag, omEnv.ConnectTimeout, omEnv.ConnectTimeout != 0)
+	setIfPresent(OpsManagerRequestTimeoutFlag, omEnv.RequestTimeout, omEnv.RequestTimeout != 0)
+
+	return settings
+}
+
 func anyUsageServiceConfigsProvided() bool {
 	return viper.GetString(CfApiURLFlag) != "" ||
 		viper.GetString(UsageServiceURLFlag) != "" ||
@@ -262,6 +597,78 @@ func anyUsageServiceConfigsProvided() bool {
 		viper.GetString(UsageServiceClientSecretFlag) != ""
 }
 
+// usageServiceCollectionRequested decides whether to collect from Usage
+// Service. The CF inventory and the usage snapshot share the CF API and client
+// configuration, so when either is enabled only the Usage Service URL requests
+// usage data.
+func usageServiceCollectionRequested() bool {
+	if viper.GetBool(CollectCfInventoryFlag) || viper.GetBool(UsageFromCfApiFlag) {
+		return viper.GetString(UsageServiceURLFlag) != ""
+	}
+	return anyUsageServiceConfigsProvided()
+}
+
+// selectedDatasets returns the datasets chosen with --datasets, adding those
+// enabled with a --with-* flag. Without --datasets, the datasets are chosen
+// the way they were before it existed: --operational-data-only replaces the
+// Ops Manager data with core counts, and usage configuration adds Usage
+// Service data and core counts. The optional Ops Manager datasets are only
+// collected when named in --datasets.
+func selectedDatasets() (operations.Datasets, error) {
+	var datasets operations.Datasets
+	if names := viper.GetStringSlice(DatasetsFlag); len(names) > 0 {
+		if viper.GetBool(OperationalDataOnlyFlag) {
+			return nil, errors.New(DatasetsWithOperationalDataOnlyMessage)
+		}
+
+		var err error
+		datasets, err = operations.ParseDatasets(names)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		operationalDataOnly := viper.GetBool(OperationalDataOnlyFlag)
+		datasets = operations.NewDatasets()
+		datasets[operations.OpsManagerDataset] = !operationalDataOnly
+		datasets[operations.UsageServiceDataset] = viper.GetBool(UsageFromCfApiFlag) || usageServiceCollectionRequested()
+		datasets[operations.CoreConsumptionDataset] = anyUsageServiceConfigsProvided() || operationalDataOnly
+	}
+
+	if viper.GetBool(CollectFromCredhubFlag) {
+		datasets[operations.CredhubDataset] = true
+	}
+	if viper.GetBool(CollectCfInventoryFlag) {
+		datasets[operations.CfInventoryDataset] = true
+	}
+	if viper.GetBool(CollectFromBoshFlag) {
+		datasets[operations.BoshDirectorDataset] = true
+	}
+	return datasets, nil
+}
+
+// selectedProductFilter returns the products whose resources and properties
+// are collected. Each flag value may itself be a comma separated list, as
+// when read from an environment variable.
+func selectedProductFilter() (*opsmanager.ProductFilter, error) {
+	return opsmanager.NewProductFilter(
+		splitList(viper.GetStringSlice(IncludeProductTypeFlag)),
+		splitList(viper.GetStringSlice(ExcludeProductTypeFlag)),
+		splitList(viper.GetStringSlice(ExcludeProductGUIDFlag)),
+	)
+}
+
+func splitList(values []string) []string {
+	var items []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
 func validateUsageServiceConfig() error {
 	if viper.GetString(CfApiURLFlag) == "" ||
 		viper.GetString(UsageServiceURLFlag) == "" ||
@@ -273,6 +680,24 @@ func validateUsageServiceConfig() error {
 	return nil
 }
 
+// validateCfApiConfig checks the configuration needed to read from the CF
+// API, returning message when it is incomplete
+func validateCfApiConfig(message string) error {
+	if viper.GetString(CfApiURLFlag) == "" ||
+		viper.GetString(UsageServiceClientIDFlag) == "" ||
+		viper.GetString(UsageServiceClientSecretFlag) == "" {
+		return errors.New(message)
+	}
+	return nil
+}
+
+func validateUsageSnapshotConfig() error {
+	if viper.GetString(UsageServiceURLFlag) != "" {
+		return errors.New(UsageSnapshotWithUsageServiceMessage)
+	}
+	return validateCfApiConfig(InvalidUsageSnapshotConfigMessage)
+}
+
 func validateCredConfig() error {
 	noUsernamePasswordAuth := viper.GetString(OpsManagerUsernameFlag) == "" || viper.GetString(OpsManagerPasswordFlag) == ""
 	noClientSecretAuth := viper.GetString(OpsManagerClientIdFlag) == "" || viper.GetString(OpsManagerClientSecretFlag) == ""
@@ -284,14 +709,21 @@ func validateCredConfig() error {
 }
 
 func validateAndNormalizeEnvType() (string, error) {
-	validEnvTypes := []string{EnvTypeSandbox, EnvTypeDevelopment, EnvTypeQA, EnvTypePreProduction, EnvTypeProduction}
 	envType := strings.ToLower(viper.GetString(EnvTypeFlag))
+	if isValidEnvType(envType) {
+		return envType, nil
+	}
+	return "", errors.Errorf(InvalidEnvTypeFailureFormat, envType)
+}
+
+func isValidEnvType(envType string) bool {
+	validEnvTypes := []string{EnvTypeSandbox, EnvTypeDevelopment, EnvTypeQA, EnvTypePreProduction, EnvTypeProduction}
 	for _, validType := range validEnvTypes {
-		if validType == envType {
-			return envType, nil
+		if validType == strings.ToLower(envType) {
+			return true
 		}
 	}
-	return "", errors.Errorf(InvalidEnvTypeFailureFormat, envType)
+	return false
 }
 
 func validateAndNormalizeFoundationNickname() (string, error) {
@@ -307,90 +739,186 @@ type coreConsumptionDataCollector interface {
 	Collect() ([]coreconsumption.Data, error)
 }
 
-func makeConsumptionCollector() (consumptionDataCollector, error) {
-	if anyUsageServiceConfigsProvided() {
-		err := validateUsageServiceConfig()
-		if err != nil {
-			return nil, err
-		}
+type cfInventoryDataCollector interface {
+	Collect() ([]cfinventory.Data, error)
+}
 
-		client := network.NewClient(viper.GetBool(UsageServiceSkipTlsVerifyFlag))
-		cfApiClient := cf.NewClient(viper.GetString(CfApiURLFlag), client)
+// cfApiAuth discovers UAA from the CF API and authenticates as the Usage
+// Service client. The client is built once, so every collector reading from
+// CF shares the same token.
+type cfApiAuth struct {
+	client *cf.OAuthClient
+}
 
-		usageURL, err := url.Parse(viper.GetString(UsageServiceURLFlag))
-		if err != nil {
-			return nil, errors.New(UsageServiceURLParsingError)
-		}
+func (a *cfApiAuth) authedClient() (cf.OAuthClient, error) {
+	if a.client != nil {
+		return *a.client, nil
+	}
 
-		uaaURL, err := cfApiClient.GetUAAURL()
-		if err != nil {
-			return nil, errors.Wrap(err, GetUAAURLError)
-		}
+	client := network.NewClient(viper.GetBool(UsageServiceSkipTlsVerifyFlag))
+	uaaURL, err := cf.NewClient(viper.GetString(CfApiURLFlag), client).GetUAAURL()
+	if err != nil {
+		return cf.OAuthClient{}, errors.Wrap(err, GetUAAURLError)
+	}
 
-		authedClient := cf.NewOAuthClient(
-			uaaURL,
-			viper.GetString(UsageServiceClientIDFlag),
-			viper.GetString(UsageServiceClientSecretFlag),
-			time.Duration(viper.GetInt(UsageServiceTimeoutFlag))*time.Second,
-			client,
-		)
+	authedClient := cf.NewOAuthClient(
+		uaaURL,
+		viper.GetString(UsageServiceClientIDFlag),
+		viper.GetString(UsageServiceClientSecretFlag),
+		time.Duration(viper.GetInt(UsageServiceTimeoutFlag))*time.Second,
+		client,
+	)
+	a.client = &authedClient
+	return authedClient, nil
+}
 
-		consumptionService := &consumption.Service{
-			BaseURL: usageURL,
-			Client:  authedClient,
-		}
+func makeConsumptionCollector(auth *cfApiAuth, usageCollectionEnabled bool) (consumptionDataCollector, error) {
+	if !usageCollectionEnabled {
+		return nil, nil
+	}
 
-		consumptionCollector := consumption.NewDataCollector(
-			logger,
-			consumptionService,
-			viper.GetString(UsageServiceURLFlag),
-		)
+	if viper.GetBool(UsageFromCfApiFlag) {
+		return makeUsageSnapshotCollector(auth)
+	}
 
-		return consumptionCollector, nil
-	}
-	return nil, nil
-}
-
-func makeCoreConsumptionCollector(operationalDataOnly bool, apiService api.Api) (coreConsumptionDataCollector, error) {
-	// FIXME
-	// This doesn't accurately support the case where only TKGi
-	// is installed (not TAS) and the user has opted into both
-	// data programs.
-	// In order to support that scenario, we would need to add
-	// an additional flag. Something like `--core-counts`. But
-	// after talking this through with the team, it was decided
-	// that adding a flag like this would create too much confusion.
-	// Instead, we've agreed to re-think our approach to data
-	// collection down the road where we might give users of
-	// of the tile the ability to specify exactly which APIs to
-	// collect from. That is, instead of the current 2 checkboxes:
-	// CEIP and Operational Data -- there would be 3: CEIP,
-	// Core Counts, and Usage Service
-	if anyUsageServiceConfigsProvided() || operationalDataOnly {
-		// collect data from api/v0/download_core_consumption
-		ccOmService := &coreconsumption.Service{
-			Requestor: apiService,
-		}
+	err := validateUsageServiceConfig()
+	if err != nil {
+		return nil, err
+	}
 
-		coreConsumptionCollector := coreconsumption.NewDataCollector(
-			logger,
-			ccOmService,
-			viper.GetString(OpsManagerURLFlag),
-		)
+	usageURL, err := url.Parse(viper.GetString(UsageServiceURLFlag))
+	if err != nil {
+		return nil, errors.New(UsageServiceURLParsingError)
+	}
+
+	authedClient, err := auth.authedClient()
+	if err != nil {
+		return nil, err
+	}
+
+	consumptionService := &consumption.Service{
+		BaseURL: usageURL,
+		Client:  authedClient,
+	}
+
+	consumptionCollector := consumption.NewDataCollector(
+		logger,
+		consumptionService,
+		viper.GetString(UsageServiceURLFlag),
+	)
+
+	return consumptionCollector, nil
+}
+
+// makeUsageSnapshotCollector reads point in time app and service instance
+// counts from the CF API, in place of the Usage Service reports
+func makeUsageSnapshotCollector(auth *cfApiAuth) (consumptionDataCollector, error) {
+	if err := validateUsageSnapshotConfig(); err != nil {
+		return nil, err
+	}
+
+	cfApiURL, err := url.Parse(viper.GetString(CfApiURLFlag))
+	if err != nil {
+		return nil, errors.New(CfApiURLParsingError)
+	}
+
+	authedClient, err := auth.authedClient()
+	if err != nil {
+		return nil, err
+	}
+
+	cfApiService := &cfinventory.Service{
+		BaseURL: cfApiURL,
+		Client:  authedClient,
+	}
+
+	return consumption.NewSnapshotCollector(logger, cfApiService, viper.GetString(CfApiURLFlag), time.Now), nil
+}
+
+func makeCfInventoryCollector(auth *cfApiAuth, cfInventoryCollectionEnabled bool) (cfInventoryDataCollector, error) {
+	if !cfInventoryCollectionEnabled {
+		return nil, nil
+	}
+
+	if err := validateCfApiConfig(InvalidCfInventoryConfigMessage); err != nil {
+		return nil, err
+	}
+
+	cfApiURL, err := url.Parse(viper.GetString(CfApiURLFlag))
+	if err != nil {
+		return nil, errors.New(CfApiURLParsingError)
+	}
+
+	authedClient, err := auth.authedClient()
+	if err != nil {
+		return nil, err
+	}
 
-		return coreConsumptionCollector, nil
+	cfInventoryService := &cfinventory.Service{
+		BaseURL: cfApiURL,
+		Client:  authedClient,
 	}
-	return nil, nil
+
+	return cfinventory.NewDataCollector(logger, cfInventoryService, viper.GetString(CfApiURLFlag)), nil
+}
+
+func makeCoreConsumptionCollector(coreConsumptionCollectionEnabled bool, apiService api.Api, capabilities *opsmanager.Capabilities) (coreConsumptionDataCollector, error) {
+	if !coreConsumptionCollectionEnabled {
+		return nil, nil
+	}
+
+	if !capabilities.Supports(collector_tar.CoreCountsDataType) {
+		logger.Print(capabilities.UnsupportedWarning(collector_tar.CoreCountsDataType))
+		return nil, nil
+	}
+
+	// collect data from api/v0/download_core_consumption
+	ccOmService := &coreconsumption.Service{
+		Requestor: apiService,
+	}
+
+	coreConsumptionCollector := coreconsumption.NewDataCollector(
+		logger,
+		ccOmService,
+		viper.GetString(OpsManagerURLFlag),
+	)
+
+	return coreConsumptionCollector, nil
 }
 
 type credhubDataCollector interface {
 	Collect() (credhub.Data, error)
 }
 
-func makeCredhubCollector(omService *opsmanager.Service, credhubCollectionEnabled bool) (credhubDataCollector, error) {
+// boshCredentials reads the director client credentials from Ops Manager for
+// the named dataset. It returns false when the dataset is skipped because the
+// Ops Manager version or role cannot read the credentials.
+func boshCredentials(omService *opsmanager.Service, permissions *opsmanager.Permissions, capabilities *opsmanager.Capabilities, datasetName string) (opsmanager.BoshCredential, bool, error) {
+	if !capabilities.Supports(opsmanager.BoshCredentialsDataType) {
+		logger.Print(capabilities.UnsupportedWarning(datasetName))
+		permissions.SkipUnsupported(datasetName, opsmanager.BoshCredentialsDataType, capabilities.UnsupportedReason(opsmanager.BoshCredentialsDataType))
+		return opsmanager.BoshCredential{}, false, nil
+	}
+	if !permissions.Allows(opsmanager.BoshCredentialsDataType) {
+		logger.Printf(opsmanager.SkippingDatasetWarningFormat, datasetName, permissions.SkipForRole(datasetName, opsmanager.BoshCredentialsDataType))
+		return opsmanager.BoshCredential{}, false, nil
+	}
+
+	creds, err := omService.BoshCredentials()
+	if errors.As(err, &opsmanager.ForbiddenError{}) {
+		logger.Printf(opsmanager.SkippingDatasetWarningFormat, datasetName, permissions.SkipForbidden(datasetName, opsmanager.BoshCredentialsDataType))
+		return opsmanager.BoshCredential{}, false, nil
+	}
+	if err != nil {
+		return opsmanager.BoshCredential{}, false, err
+	}
+	return creds, true, nil
+}
+
+func makeCredhubCollector(omService *opsmanager.Service, permissions *opsmanager.Permissions, capabilities *opsmanager.Capabilities, credhubCollectionEnabled bool) (credhubDataCollector, error) {
 	if credhubCollectionEnabled {
-		chCreds, err := omService.BoshCredentials()
-		if err != nil {
+		chCreds, ok, err := boshCredentials(omService, permissions, capabilities, credhub.NewData(nil).Name())
+		if err != nil || !ok {
 			return nil, err
 		}
 		credHubURL := "https://" + chCreds.Host + ":8844"
@@ -409,7 +937,139 @@ func makeCredhubCollector(omService *opsmanager.Service, credhubCollectionEnable
 	}
 }
 
-func makeCollector(tarWriter *tar.TarWriter, operationalDataOnly bool) (*operations.CollectExecutor, error) {
+type boshDataCollector interface {
+	Collect() ([]bosh.Data, error)
+}
+
+func makeBoshCollector(omService *opsmanager.Service, permissions *opsmanager.Permissions, capabilities *opsmanager.Capabilities, boshCollectionEnabled bool) (boshDataCollector, error) {
+	if !boshCollectionEnabled {
+		return nil, nil
+	}
+
+	boshCreds, ok, err := boshCredentials(omService, permissions, capabilities, bosh.BoshCollectorDataSetId)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	boshService, err := makeBoshService(boshCreds)
+	if err != nil {
+		return nil, err
+	}
+	return bosh.NewDataCollector(logger, boshService, boshService.BaseURL.String()), nil
+}
+
+// makeBoshService authenticates with the UAA advertised by the director. As
+// for CredHub, TLS is not verified since the director CA is only available on
+// the Ops Manager VM.
+func makeBoshService(boshCreds opsmanager.BoshCredential) (*bosh.Service, error) {
+	directorURL := bosh.DirectorURL(boshCreds.Host)
+	client := network.NewClient(true)
+
+	info, err := (&bosh.Service{BaseURL: directorURL, Client: client}).Info()
+	if err != nil {
+		return nil, errors.Wrap(err, BoshDirectorInfoError)
+	}
+	uaaURL := info.UserAuthentication.Options.URL
+	if uaaURL == "" {
+		return nil, errors.Errorf(BoshDirectorWithoutUAAFormat, directorURL)
+	}
+
+	authedClient := cf.NewOAuthClient(
+		uaaURL,
+		boshCreds.ClientID,
+		boshCreds.ClientSecret,
+		time.Duration(viper.GetInt(OpsManagerRequestTimeoutFlag))*time.Second,
+		client,
+	)
+	return &bosh.Service{BaseURL: directorURL, Client: authedClient}, nil
+}
+
+type lifecycleAnalyzer interface {
+	Analyze(files map[string][]byte) ([]lifecycle.Data, error)
+}
+
+func makeLifecycleAnalyzer() (lifecycleAnalyzer, error) {
+	catalogPath := viper.GetString(LifecycleCatalogFlag)
+	if catalogPath == "" {
+		return nil, nil
+	}
+
+	catalog, err := lifecycle.LoadCatalog(catalogPath)
+	if err != nil {
+		return nil, err
+	}
+	return lifecycle.NewAnalyzer(logger, catalog, time.Now), nil
+}
+
+type capacityAnalyzer interface {
+	Analyze(files map[string][]byte) ([]capacity.Data, error)
+}
+
+// makeCapacityAnalyzer models capacity from the product resources and VM
+// types, which are only collected with the Ops Manager dataset
+func makeCapacityAnalyzer(datasets operations.Datasets) capacityAnalyzer {
+	if !datasets.Includes(operations.OpsManagerDataset) {
+		return nil
+	}
+	return capacity.NewAnalyzer(logger)
+}
+
+type lintAnalyzer interface {
+	Analyze(files map[string][]byte) ([]lint.Data, error)
+}
+
+// makeLintAnalyzer runs the rules file when one is given, and otherwise the
+// built-in rules when linting is enabled
+func makeLintAnalyzer(envType string) (lintAnalyzer, error) {
+	var rules []lint.Rule
+	var err error
+	switch {
+	case viper.GetString(LintRulesFlag) != "":
+		rules, err = lint.LoadRules(viper.GetString(LintRulesFlag))
+	case viper.GetBool(LintFlag):
+		rules, err = lint.DefaultRules()
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lint.NewAnalyzer(logger, rules, envType, time.Now), nil
+}
+
+type pseudonymizer interface {
+	Pseudonymize(contents []byte) ([]byte, error)
+	FoundationID(foundationID string) string
+	WriteLookupTable(tablePath string) error
+}
+
+func makePseudonymizer() (pseudonymizer, error) {
+	if !viper.GetBool(PseudonymizeFlag) {
+		if viper.GetBool(KeepFoundationIDFlag) {
+			return nil, errors.New(FoundationIDWithoutPseudonymizeMessage)
+		}
+		return nil, nil
+	}
+
+	salt := viper.GetString(PseudonymizeSaltFlag)
+	if salt == "" {
+		return nil, errors.New(PseudonymizeWithoutSaltMessage)
+	}
+	return pseudonym.New(salt, viper.GetBool(KeepFoundationIDFlag))
+}
+
+func makeCollector(tarWriter *tar.TarWriter, envType string, datasets operations.Datasets, productFilter *opsmanager.ProductFilter, configSource opsmanager.ConfigSource, pendingChangesPolicy opsmanager.PendingChangesPolicy, installingPolicy opsmanager.InstallingPolicy, installationsWindow opsmanager.InstallationsWindow, pseudonymizer pseudonymizer, secretPolicy secretscan.Policy) (*operations.CollectExecutor, error) {
+	// The catalog and lint rules are read first, so a broken file fails
+	// before any service is contacted
+	analyzer, err := makeLifecycleAnalyzer()
+	if err != nil {
+		return nil, err
+	}
+	linter, err := makeLintAnalyzer(envType)
+	if err != nil {
+		return nil, err
+	}
+
 	authedClient, _ := omNetwork.NewOAuthClient(
 		viper.GetString(OpsManagerURLFlag),
 		viper.GetString(OpsManagerUsernameFlag),
@@ -422,34 +1082,151 @@ func makeCollector(tarWriter *tar.TarWriter, operationalDataOnly bool) (*operati
 		time.Duration(viper.GetInt(OpsManagerRequestTimeoutFlag))*time.Second,
 	)
 
-	apiService := api.New(api.ApiInput{Client: authedClient})
+	unauthedClient, _ := omNetwork.NewUnauthenticatedClient(
+		viper.GetString(OpsManagerURLFlag),
+		viper.GetBool(SkipTlsVerifyFlag),
+		"",
+		time.Duration(viper.GetInt(OpsManagerTimeoutFlag))*time.Second,
+		time.Duration(viper.GetInt(OpsManagerRequestTimeoutFlag))*time.Second,
+	)
+
+	apiService := api.New(api.ApiInput{Client: authedClient, UnauthedClient: unauthedClient})
 	omService := &opsmanager.Service{
-		Requestor: apiService,
+		Requestor:           apiService,
+		InstallationsWindow: installationsWindow,
+	}
+	if pseudonymizer != nil {
+		// The salt keys the address hashes too, so they match across
+		// collections like the other pseudonyms
+		omService.AddressKey = []byte(viper.GetString(PseudonymizeSaltFlag))
 	}
 
+	capabilities := detectOpsManagerCapabilities(apiService)
+	permissions := opsmanager.NewPermissions(detectOpsManagerRole())
+
 	omCollector := opsmanager.NewDataCollector(
 		logger,
 		omService,
 		viper.GetString(OpsManagerURLFlag),
 		apiService,
 		apiService,
-		operationalDataOnly,
+		permissions,
+		capabilities,
+		productFilter,
+		configSource,
+		pendingChangesPolicy,
+		opsmanager.NewInstallationGuard(
+			logger,
+			omService,
+			installingPolicy,
+			time.Duration(viper.GetInt(InstallingPollIntervalFlag))*time.Second,
+			time.Duration(viper.GetInt(InstallingMaxWaitFlag))*time.Second,
+			time.Sleep,
+		),
+		datasets.OpsManagerDataTypes(),
+		!datasets.Includes(operations.OpsManagerDataset),
 	)
 
-	consumptionCollector, err := makeConsumptionCollector()
+	cfAuth := &cfApiAuth{}
+	consumptionCollector, err := makeConsumptionCollector(cfAuth, datasets.Includes(operations.UsageServiceDataset))
+	if err != nil {
+		return nil, err
+	}
+
+	cfInventoryCollector, err := makeCfInventoryCollector(cfAuth, datasets.Includes(operations.CfInventoryDataset))
 	if err != nil {
 		return nil, err
 	}
 
-	coreConsumptionCollector, err := makeCoreConsumptionCollector(operationalDataOnly, apiService)
+	coreConsumptionCollector, err := makeCoreConsumptionCollector(datasets.Includes(operations.CoreConsumptionDataset), apiService, capabilities)
 	if err != nil {
 		return nil, err
 	}
 
-	credhubCollector, err := makeCredhubCollector(omService, viper.GetBool(CollectFromCredhubFlag))
+	credhubCollector, err := makeCredhubCollector(omService, permissions, capabilities, datasets.Includes(operations.CredhubDataset))
 	if err != nil {
 		return nil, err
 	}
 
-	return operations.NewCollector(omCollector, credhubCollector, consumptionCollector, coreConsumptionCollector, tarWriter, uuid.DefaultGenerator, operationalDataOnly), nil
+	boshCollector, err := makeBoshCollector(omService, permissions, capabilities, datasets.Includes(operations.BoshDirectorDataset))
+	if err != nil {
+		return nil, err
+	}
+
+	return operations.NewCollector(omCollector, operations.CollectorOptions{
+		Credhub:            credhubCollector,
+		Consumption:        consumptionCollector,
+		CoreConsumption:    coreConsumptionCollector,
+		CoreCountsRequired: capabilities.Version() != "",
+		CfInventory:        cfInventoryCollector,
+		Bosh:               boshCollector,
+		Lifecycle:          analyzer,
+		Capacity:           makeCapacityAnalyzer(datasets),
+		Lint:               linter,
+		Pseudonymizer:      pseudonymizer,
+		SecretScanner:      secretscan.NewScanner(logger, secretPolicy),
+	}, tarWriter, uuid.DefaultGenerator, datasets), nil
+}
+
+// detectOpsManagerCapabilities reads the Ops Manager version, which decides
+// the endpoints collect requests. When the version cannot be read every
+// endpoint is requested, and failures are reported as usual.
+func detectOpsManagerCapabilities(apiService api.Api) *opsmanager.Capabilities {
+	info, err := apiService.Info()
+	if err != nil {
+		logger.Printf(UnknownOpsManagerVersionFormat, err)
+		return &opsmanager.Capabilities{}
+	}
+
+	capabilities, err := opsmanager.NewCapabilities(info.Version)
+	if err != nil {
+		logger.Printf(UnknownOpsManagerVersionFormat, err)
+		return capabilities
+	}
+
+	logger.Printf(OpsManagerVersionFormat, info.Version)
+	return capabilities
+}
+
+// detectOpsManagerRole requests a token the same way the om client does and
+// reads the role from its scopes. When the role cannot be determined every
+// dataset is requested, and those refused with a 403 are skipped.
+func detectOpsManagerRole() opsmanager.Role {
+	target := viper.GetString(OpsManagerURLFlag)
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		target = "https://" + target
+	}
+	uaaURL, err := url.Parse(target)
+	if err != nil {
+		logger.Printf(UnknownOpsManagerRoleFormat, err)
+		return opsmanager.RoleUnknown
+	}
+	uaaURL.Path = OpsManagerUAAPath
+
+	authOption := uaa.WithClientCredentials(viper.GetString(OpsManagerClientIdFlag), viper.GetString(OpsManagerClientSecretFlag), uaa.JSONWebToken)
+	if viper.GetString(OpsManagerUsernameFlag) != "" && viper.GetString(OpsManagerPasswordFlag) != "" {
+		authOption = uaa.WithPasswordCredentials(OpsManagerUAAClientID, "", viper.GetString(OpsManagerUsernameFlag), viper.GetString(OpsManagerPasswordFlag), uaa.JSONWebToken)
+	}
+
+	skipTLSVerify := viper.GetBool(SkipTlsVerifyFlag)
+	uaaAPI, err := uaa.New(uaaURL.String(), authOption, uaa.WithSkipSSLValidation(skipTLSVerify), uaa.WithClient(network.NewClient(skipTLSVerify)))
+	if err != nil {
+		logger.Printf(UnknownOpsManagerRoleFormat, err)
+		return opsmanager.RoleUnknown
+	}
+
+	token, err := uaaAPI.Token(context.Background())
+	if err != nil {
+		logger.Printf(UnknownOpsManagerRoleFormat, err)
+		return opsmanager.RoleUnknown
+	}
+
+	role, err := opsmanager.RoleFromAccessToken(token.AccessToken)
+	if err != nil {
+		logger.Printf(UnknownOpsManagerRoleFormat, err)
+		return opsmanager.RoleUnknown
+	}
+
+	logger.Printf(OpsManagerRoleFormat, role)
+	return role
 }
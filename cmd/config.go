@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pivotal-cf/aqueduct-courier/config"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+const (
+	ConfigFileRequiredMessage    = "Missing required flags: --config or --om-env"
+	ConfigFileValidFormat        = "Config file %s is valid"
+	OmEnvFileValidFormat         = "Om env file %s is valid"
+	ConfigFileProfilesFormat     = "Profiles: %s"
+	InvalidProfileEnvTypeFormat  = "profile %q: " + InvalidEnvTypeFailureFormat
+	InvalidDefaultEnvTypeMessage = "shared settings: " + InvalidEnvTypeFailureFormat
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Works with collector config files",
+	Long:  "Works with the config files accepted by the collect command's --config flag.",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validates a collector config file",
+	Long:  "Validates a collector config file against the config schema, and an om env file, without contacting any service.",
+	RunE:  validateConfig,
+}
+
+// configFileOnlyFlags are collect flags which cannot be set from within the
+// config file itself, since they are needed to read it
+var configFileOnlyFlags = []string{ConfigFlag, ProfileFlag, VarsFileFlag, VarsEnvFlag, "help"}
+
+func init() {
+	configValidateCmd.Flags().String(ConfigFlag, "", fmt.Sprintf("``Config file to validate [$%s]", ConfigFileKey))
+	configValidateCmd.Flags().String(OmEnvFileFlag, "", fmt.Sprintf("``om CLI env file to validate [$%s]", OmEnvFileKey))
+	configValidateCmd.Flags().String(ProfileFlag, "", fmt.Sprintf("``Only validate that this profile exists and resolves [$%s]", ProfileKey))
+	configValidateCmd.Flags().StringSlice(VarsFileFlag, []string{}, fmt.Sprintf("``Load variables for ((var)) placeholders from a YAML file, can be repeated [$%s]", VarsFileKey))
+	configValidateCmd.Flags().StringSlice(VarsEnvFlag, []string{}, fmt.Sprintf("``Load variables from environment variables with this prefix, can be repeated [$%s]\n", VarsEnvKey))
+	configValidateCmd.Flags().BoolP("help", "h", false, "Help for the config validate command\n")
+	configValidateCmd.Flags().SortFlags = false
+
+	configValidateCmd.Example = `
+      Validate a config file and every profile it defines:
+      telemetry-collector config validate --config config.yml
+
+      Validate a config file which uses ((var)) placeholders:
+      telemetry-collector config validate --config config.yml --vars-file vars.yml
+
+      Validate an om env file:
+      telemetry-collector config validate --om-env env.yml`
+
+	cobra.AddTemplateFunc("configFileKeys", configFileKeysHelp)
+
+	customUsageTextTemplate := `
+USAGE EXAMPLES
+{{.Example}}
+
+FLAGS
+
+{{.LocalFlags.FlagUsages}}`
+
+	configValidateCmd.SetHelpTemplate(`
+Validates a collector config file without contacting any service. Unknown
+keys and values of the wrong type are reported with their line numbers. An
+om env file given with --om-env is checked for keys om does not accept, and
+for keys the collector ignores.
+
+Settings at the top level of the file are shared by every profile. Settings
+under "profiles.<name>" override them when collect is run with --profile.
+` + customUsageTextTemplate + `
+CONFIG FILE KEYS
+
+{{configFileKeys}}`)
+	configValidateCmd.SetUsageTemplate(customUsageTextTemplate)
+
+	configCmd.AddCommand(configValidateCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+func validateConfig(c *cobra.Command, _ []string) error {
+	if !useConfigFile() && !useOmEnvFile() {
+		return errors.New(ConfigFileRequiredMessage)
+	}
+	c.SilenceUsage = true
+
+	vars, err := config.LoadVars(viper.GetStringSlice(VarsFileFlag), viper.GetStringSlice(VarsEnvFlag), os.Environ())
+	if err != nil {
+		return fmt.Errorf(LoadVarsErrorFormat, err)
+	}
+
+	if useConfigFile() {
+		if err := validateConfigFile(vars); err != nil {
+			return err
+		}
+	}
+
+	if useOmEnvFile() {
+		if _, err := readOmEnv(vars); err != nil {
+			return err
+		}
+		logger.Printf(OmEnvFileValidFormat, viper.GetString(OmEnvFileFlag))
+	}
+	return nil
+}
+
+func validateConfigFile(vars map[string]interface{}) error {
+	configFile, err := config.ReadFile(viper.GetString(ConfigFlag), vars, collectConfigSchema(collectCmd.Flags()))
+	if err != nil {
+		return err
+	}
+
+	profiles := configFile.Profiles()
+	if profile := viper.GetString(ProfileFlag); profile != "" {
+		profiles = []string{profile}
+	}
+
+	settings, err := configFile.Settings("")
+	if err != nil {
+		return err
+	}
+	if err := validateEnvTypeSetting(settings); err != nil {
+		return errors.Errorf(InvalidDefaultEnvTypeMessage, settings[EnvTypeFlag])
+	}
+
+	for _, profile := range profiles {
+		settings, err := configFile.Settings(profile)
+		if err != nil {
+			return err
+		}
+		if err := validateEnvTypeSetting(settings); err != nil {
+			return errors.Errorf(InvalidProfileEnvTypeFormat, profile, settings[EnvTypeFlag])
+		}
+	}
+
+	logger.Printf(ConfigFileValidFormat, viper.GetString(ConfigFlag))
+	if len(profiles) > 0 {
+		logger.Printf(ConfigFileProfilesFormat, strings.Join(profiles, ", "))
+	}
+	return nil
+}
+
+func validateEnvTypeSetting(settings map[string]interface{}) error {
+	envType, ok := settings[EnvTypeFlag]
+	if !ok {
+		return nil
+	}
+	if !isValidEnvType(fmt.Sprint(envType)) {
+		return errors.New(InvalidEnvTypeFailureFormat)
+	}
+	return nil
+}
+
+// collectConfigSchema derives the config file schema from the collect flags,
+// so every flag can also be set in the config file under the same name
+func collectConfigSchema(collectFlags *pflag.FlagSet) config.Schema {
+	schema := config.Schema{}
+	collectFlags.VisitAll(func(flag *pflag.Flag) {
+		for _, excluded := range configFileOnlyFlags {
+			if flag.Name == excluded {
+				return
+			}
+		}
+
+		keyType := config.StringKey
+		switch flag.Value.Type() {
+		case "int":
+			keyType = config.IntKey
+		case "bool":
+			keyType = config.BoolKey
+		case "stringSlice":
+			keyType = config.StringSliceKey
+		}
+
+		schema[flag.Name] = config.Key{
+			Name:        flag.Name,
+			Type:        keyType,
+			Description: strings.TrimSpace(strings.TrimPrefix(flag.Usage, "``")),
+		}
+	})
+	return schema
+}
+
+func configFileKeysHelp() string {
+	var lines []string
+	for _, key := range collectConfigSchema(collectCmd.Flags()).Keys() {
+		if collectCmd.Flags().Lookup(key.Name).Hidden {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("  %-40s %-16s %s", key.Name, key.Type.Description(), key.Description))
+	}
+	lines = append(lines, fmt.Sprintf("  %-40s %-16s %s", config.ProfilesKey+".<name>.<key>", "map", "Settings for a named profile, selected with --profile"))
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pivotal-cf/aqueduct-courier/export"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	ExportFormatFlag = "format"
+	ExportFormatKey  = "EXPORT_FORMAT"
+
+	CreateExportFileFailureFormat = "Could not create export file %s"
+	WroteExportFileFormat         = "Wrote %s\n"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Exports collected data as CSV files",
+	Long:  "Flattens the data from the collect command into a CSV file for each kind of data, for spreadsheets and BI tools.",
+	RunE:  exportData,
+}
+
+func init() {
+	bindFlagAndEnvVar(exportCmd, DataTarFilePathFlag, "", fmt.Sprintf("``The path to the file with data from the 'collect' command [$%s]", DataTarFilePathKey), DataTarFilePathKey)
+	bindFlagAndEnvVar(exportCmd, ExportFormatFlag, string(export.CSVFormat), fmt.Sprintf("``Format of the exported files, only csv is supported [$%s]", ExportFormatKey), ExportFormatKey)
+	bindFlagAndEnvVar(exportCmd, OutputPathFlag, "", fmt.Sprintf("``Local directory to write the exported files [$%s]\n", OutputPathKey), OutputPathKey)
+
+	exportCmd.Flags().BoolP("help", "h", false, "Help for the export command\n")
+	exportCmd.Flags().SortFlags = false
+
+	exportCmd.Example = `
+      Export collected data as CSV files:
+      telemetry-collector export --path --format csv --output-dir`
+
+	customUsageTextTemplate := `
+USAGE EXAMPLES
+{{.Example}}
+
+FLAGS
+
+{{.LocalFlags.FlagUsages}}`
+
+	exportCmd.SetHelpTemplate(`
+Flattens the data written by collect into a CSV file for each kind of data,
+with a row per observation. Nothing is contacted.
+
+  products        product versions, from the Ops Manager deployed products
+  jobs            instances, VM types, vCPU, memory and disk of each job
+  certificates    Ops Manager, certificate authority and CredHub certificates
+  core_counts     physical and virtual core counts
+  app_usage       app instances by month, from the usage service or the CF
+                  API snapshot, as the source column records
+  service_usage   service instances by plan and month, from either source
+  task_usage      task runs by month, from the usage service only
+
+A file is written for each kind of data in the data sets collected, named
+after the data file, such as FoundationDetails_1700000000_products.csv. Every
+file starts with foundation_nickname, foundation_id, env_type and
+collected_at columns, so files exported from several foundations can be
+concatenated.
+` + customUsageTextTemplate)
+	exportCmd.SetUsageTemplate(customUsageTextTemplate)
+	rootCmd.AddCommand(exportCmd)
+}
+
+func exportData(c *cobra.Command, _ []string) error {
+	if err := verifyRequiredConfig(DataTarFilePathFlag, OutputPathFlag); err != nil {
+		return err
+	}
+	format, err := export.ParseFormat(viper.GetString(ExportFormatFlag))
+	if err != nil {
+		return err
+	}
+	c.SilenceUsage = true
+
+	tarFilePath := viper.GetString(DataTarFilePathFlag)
+	files, err := readDataTarFile(tarFilePath)
+	if err != nil {
+		return err
+	}
+
+	tables, err := export.NewExporter(logger).Tables(files)
+	if err != nil {
+		return err
+	}
+
+	prefix := strings.TrimSuffix(filepath.Base(tarFilePath), filepath.Ext(tarFilePath))
+	for _, table := range tables {
+		exportFilePath := filepath.Join(viper.GetString(OutputPathFlag), prefix+"_"+table.FileName(format))
+		if err := writeExportFile(exportFilePath, table); err != nil {
+			return err
+		}
+		logger.Printf(WroteExportFileFormat, exportFilePath)
+	}
+	return nil
+}
+
+func writeExportFile(exportFilePath string, table export.Table) error {
+	exportFile, err := os.Create(exportFilePath)
+	if err != nil {
+		return errors.Wrapf(err, CreateExportFileFailureFormat, exportFilePath)
+	}
+	defer exportFile.Close()
+
+	if err := table.WriteCSV(exportFile); err != nil {
+		return errors.Wrapf(err, CreateExportFileFailureFormat, exportFilePath)
+	}
+	return exportFile.Close()
+}
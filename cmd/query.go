@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/pivotal-cf/aqueduct-courier/query"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	RawOutputFlag     = "raw-output"
+	RawOutputKey      = "QUERY_RAW_OUTPUT"
+	CompactOutputFlag = "compact-output"
+	CompactOutputKey  = "QUERY_COMPACT_OUTPUT"
+
+	QueryExpressionRequiredMessage = "Exactly one query expression is required"
+	QueryFailedErrorFormat         = "Query failed on %s"
+)
+
+var queryCmd = &cobra.Command{
+	Use:   "query [flags] <expression>",
+	Short: "Runs a jq-style expression over collected data",
+	Long:  "Runs a jq-style expression over the data in one or more files from the collect command, addressing collected files by product type and data type.",
+	Args: func(_ *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New(QueryExpressionRequiredMessage)
+		}
+		return nil
+	},
+	RunE: runQuery,
+}
+
+// queryResult is a result from one of several collections, labelled with
+// the foundation it came from
+type queryResult struct {
+	FoundationNickname interface{} `json:"foundation_nickname"`
+	FoundationID       interface{} `json:"foundation_id"`
+	Path               string      `json:"path"`
+	Result             interface{} `json:"result"`
+}
+
+func init() {
+	bindFlagAndEnvVar(queryCmd, DataTarFilePathFlag, []string{}, fmt.Sprintf("``The path to a file with data from the 'collect' command, repeat to query several [$%s]", DataTarFilePathKey), DataTarFilePathKey)
+	bindFlagAndEnvVar(queryCmd, RawOutputFlag, false, fmt.Sprintf("``Write string results without JSON quoting [$%s]", RawOutputKey), RawOutputKey)
+	bindFlagAndEnvVar(queryCmd, CompactOutputFlag, false, fmt.Sprintf("``Write each result on a single line [$%s]\n", CompactOutputKey), CompactOutputKey)
+
+	queryCmd.Flags().BoolP("help", "h", false, "Help for the query command\n")
+	queryCmd.Flags().SortFlags = false
+
+	queryCmd.Example = `
+      List the property names of the cf product:
+      telemetry-collector query --path data.tar '.cf.properties | keys'
+
+      Count the deployments of several foundations:
+      telemetry-collector query --path prod.tar --path dev.tar
+      '.bosh_director.deployments | length'`
+
+	customUsageTextTemplate := `
+USAGE EXAMPLES
+{{.Example}}
+
+FLAGS
+
+{{.LocalFlags.FlagUsages}}`
+
+	queryCmd.SetHelpTemplate(`
+Runs a jq-style expression over the data written by collect. Nothing is
+contacted.
+
+Collected files are addressed by product type and then data type, such as
+.cf.properties, .ops_manager.vm_types or ."p-bosh".certificates. Data without
+a product type, such as the BOSH director and lint data, is addressed by its
+data set instead, such as .bosh_director.deployments or .lint.lint_findings.
+The foundation is available as $foundation_id and $foundation_nickname.
+
+With several files, each result is written as an object holding the
+foundation nickname, foundation id and path alongside it, or with
+--raw-output as the nickname, or id without one, followed by a tab and the
+result.
+
+Expressions use a subset of jq 1.6:
+  paths, slices and iteration, such as .a.b[0], ."p-bosh", .[1:], .[] and ..,
+  each optional with ?; the operators |, ",", +, -, *, /, %, ==, !=, <, <=,
+  >, >=, and, or and //; if, try and catch, reduce, foreach and "as $name";
+  object and array construction and string interpolation
+
+  functions: add, all, any, ascii_downcase, ascii_upcase, capture, contains,
+  empty, endswith, error, explode, first, flatten, from_entries, fromdate,
+  fromdateiso8601, fromjson, getpath, group_by, gsub, has, implode, in,
+  inside, isempty, join, keys, keys_unsorted, last, leaf_paths, length,
+  limit, ltrim, ltrimstr, map, map_values, max, max_by, min, min_by, not,
+  now, paths, range, recurse, reverse, rtrim, rtrimstr, scan, select, sort,
+  sort_by, split, startswith, sub, test, to_entries, todate, todateiso8601,
+  tojson, tonumber, tostring, trim, type, unique, unique_by, utf8bytelength,
+  walk, with_entries, the math functions floor, ceil, round, sqrt, fabs and
+  abs, and the type filters such as numbers, strings and objects
+
+  formats: @text, @json, @csv, @tsv, @html, @uri, @sh, @base64 and @base64d,
+  applied to the input, such as .name | @sh
+
+Anything else is not supported, including assignment, def, label, modules,
+destructuring such as ". as [$a, $b]", format strings such as @sh "\(.)",
+env and $ENV, and functions such as del, index, splits, transpose, strptime
+and pow. Object keys are always in sorted order, so keys_unsorted returns the
+same keys as keys.
+` + customUsageTextTemplate)
+	queryCmd.SetUsageTemplate(customUsageTextTemplate)
+	rootCmd.AddCommand(queryCmd)
+}
+
+func runQuery(c *cobra.Command, args []string) error {
+	paths := viper.GetStringSlice(DataTarFilePathFlag)
+	if len(paths) == 0 {
+		return errors.New(fmt.Sprintf(RequiredConfigErrorFormat, "--"+DataTarFilePathFlag))
+	}
+
+	q, err := query.Parse(args[0])
+	if err != nil {
+		return err
+	}
+	c.SilenceUsage = true
+
+	for _, tarPath := range paths {
+		files, err := readDataTarFile(tarPath)
+		if err != nil {
+			return err
+		}
+		collection, err := query.NewCollection(files)
+		if err != nil {
+			return errors.Wrapf(err, QueryFailedErrorFormat, tarPath)
+		}
+		results, err := q.Run(collection.Document, collection.Variables())
+		if err != nil {
+			return errors.Wrapf(err, QueryFailedErrorFormat, tarPath)
+		}
+
+		for _, result := range results {
+			if len(paths) > 1 {
+				err = writeLabelledQueryResult(logger.Writer(), collection, tarPath, result)
+			} else {
+				err = writeQueryResult(logger.Writer(), result)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeLabelledQueryResult(w io.Writer, collection query.Collection, tarPath string, result interface{}) error {
+	if viper.GetBool(RawOutputFlag) {
+		label := collection.FoundationNickname
+		if label == "" {
+			label = collection.FoundationID
+		}
+		if _, err := fmt.Fprintf(w, "%s\t", label); err != nil {
+			return err
+		}
+		return writeQueryResult(w, result)
+	}
+
+	variables := collection.Variables()
+	return writeQueryResult(w, queryResult{
+		FoundationNickname: variables[query.FoundationNicknameVariable],
+		FoundationID:       variables[query.FoundationIDVariable],
+		Path:               tarPath,
+		Result:             result,
+	})
+}
+
+func writeQueryResult(w io.Writer, result interface{}) error {
+	if s, ok := result.(string); ok && viper.GetBool(RawOutputFlag) {
+		_, err := fmt.Fprintln(w, s)
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	if !viper.GetBool(CompactOutputFlag) {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder.Encode(result)
+}
@@ -6,9 +6,11 @@ import (
 	"os"
 	"strings"
 
+	"github.com/pivotal-cf/aqueduct-courier/opsmanager"
 	"github.com/pkg/errors"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -22,8 +24,9 @@ var (
 	version = "dev"
 	logger  *log.Logger
 	rootCmd = &cobra.Command{
-		Use:   toolName,
-		Short: "Utility for collecting information about a PCF Foundation",
+		Use:              toolName,
+		Short:            "Utility for collecting information about a PCF Foundation",
+		PersistentPreRun: bindCommandFlags,
 	}
 )
 
@@ -46,8 +49,13 @@ USAGE EXAMPLES
 
 COMMANDS
 
+  check       Checks collect can reach and read from every configured service
   collect     Collects information from a PCF foundation
+  config      Works with collector config files
+  export      Exports collected data as CSV files
+  query       Runs a jq-style expression over collected data
   send        Sends information to VMware
+  stemcells   Lists deployments using outdated stemcells
   help        Shows help about any command
 
 FLAGS
@@ -56,6 +64,9 @@ FLAGS
 	rootCmd.SetHelpTemplate(customHelpTextTemplate)
 
 	if err := rootCmd.Execute(); err != nil {
+		if errors.Is(err, opsmanager.PendingChangesExistsError) {
+			os.Exit(PendingChangesExistsExitCode)
+		}
 		os.Exit(1)
 	}
 }
@@ -83,7 +94,18 @@ func bindFlagAndEnvVar(cmd *cobra.Command, flagName string, defaultValue interfa
 		cmd.Flags().Int(flagName, val, usageText)
 	case bool:
 		cmd.Flags().Bool(flagName, val, usageText)
+	case []string:
+		cmd.Flags().StringSlice(flagName, val, usageText)
 	}
 	_ = viper.BindPFlag(flagName, cmd.Flag(flagName))
 	_ = viper.BindEnv(flagName, flagKey)
 }
+
+// bindCommandFlags binds the flags of the command being run to viper. Several
+// commands define flags with the same name, and viper only keeps the flag
+// bound last, so the binding is refreshed once the command is known.
+func bindCommandFlags(c *cobra.Command, _ []string) {
+	c.Flags().VisitAll(func(flag *pflag.Flag) {
+		_ = viper.BindPFlag(flag.Name, flag)
+	})
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pivotal-cf/aqueduct-courier/lifecycle"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	OlderThanDaysFlag = "older-than-days"
+	OlderThanDaysKey  = "STEMCELL_OLDER_THAN_DAYS"
+
+	ReadDataTarFileErrorFormat     = "Could not read data from %s"
+	InvalidOlderThanDaysMessage    = "--older-than-days cannot be negative"
+	NoOutdatedStemcellsMessage     = "No deployments use stemcells past end of life"
+	NoOutdatedStemcellsAgeFormat   = "No deployments use stemcells older than %d days or past end of life"
+	OutdatedStemcellsSummaryFormat = "\n%d of %d deployments use outdated stemcells\n"
+	UncatalogedStemcellsHeader     = "\nStemcells missing from the lifecycle catalog:"
+	PastEOLReason                  = "past end of life"
+	OlderThanReasonFormat          = "older than %d days"
+)
+
+var stemcellsCmd = &cobra.Command{
+	Use:   "stemcells",
+	Short: "Lists deployments using outdated stemcells",
+	Long:  "Lists the deployments in data from the collect command which use stemcells older than a number of days or past end of life, according to a lifecycle catalog.",
+	RunE:  stemcells,
+}
+
+func init() {
+	bindFlagAndEnvVar(stemcellsCmd, DataTarFilePathFlag, "", fmt.Sprintf("``The path to the file with data from the 'collect' command [$%s]", DataTarFilePathKey), DataTarFilePathKey)
+	bindFlagAndEnvVar(stemcellsCmd, LifecycleCatalogFlag, "", fmt.Sprintf("``Lifecycle catalog of stemcell and release versions [$%s]", LifecycleCatalogKey), LifecycleCatalogKey)
+	bindFlagAndEnvVar(stemcellsCmd, OlderThanDaysFlag, 0, fmt.Sprintf("``Also list stemcells released more than this many days ago, 0 only lists stemcells past end of life [$%s]\n", OlderThanDaysKey), OlderThanDaysKey)
+
+	stemcellsCmd.Flags().BoolP("help", "h", false, "Help for the stemcells command\n")
+	stemcellsCmd.Flags().SortFlags = false
+
+	stemcellsCmd.Example = `
+      List deployments using stemcells past end of life:
+      telemetry-collector stemcells --path --lifecycle-catalog catalog.yml
+
+      List deployments using stemcells past end of life or older than 90 days:
+      telemetry-collector stemcells --path --lifecycle-catalog catalog.yml
+      --older-than-days 90`
+
+	customUsageTextTemplate := `
+USAGE EXAMPLES
+{{.Example}}
+
+FLAGS
+
+{{.LocalFlags.FlagUsages}}`
+
+	stemcellsCmd.SetHelpTemplate(`
+Lists the deployments using outdated stemcells, read from the data written by
+collect. Stemcells are read from the BOSH director deployments when collect
+was run with --with-bosh-info, and otherwise from the Ops Manager diagnostic
+report. Nothing is contacted.
+
+LIFECYCLE CATALOG
+
+The catalog is a YAML or JSON file listing the stemcell and release versions
+you track. Stemcell names may be the full stemcell name, the operating system
+such as ubuntu-jammy, or left out to match every operating system:
+
+  stemcells:
+  - name: ubuntu-jammy
+    version: "1.351"
+    release_date: 2024-02-01
+    eol_date: 2024-08-01
+    cves: 12
+  releases:
+  - name: capi
+    version: "1.2.3"
+    release_date: 2024-01-10
+` + customUsageTextTemplate)
+	stemcellsCmd.SetUsageTemplate(customUsageTextTemplate)
+	rootCmd.AddCommand(stemcellsCmd)
+}
+
+func stemcells(c *cobra.Command, _ []string) error {
+	if err := verifyRequiredConfig(DataTarFilePathFlag, LifecycleCatalogFlag); err != nil {
+		return err
+	}
+	olderThanDays := viper.GetInt(OlderThanDaysFlag)
+	if olderThanDays < 0 {
+		return errors.New(InvalidOlderThanDaysMessage)
+	}
+	c.SilenceUsage = true
+
+	catalog, err := lifecycle.LoadCatalog(viper.GetString(LifecycleCatalogFlag))
+	if err != nil {
+		return err
+	}
+
+	files, err := readDataTarFile(viper.GetString(DataTarFilePathFlag))
+	if err != nil {
+		return err
+	}
+
+	findings, err := lifecycle.NewAnalyzer(logger, catalog, time.Now).Findings(files)
+	if err != nil {
+		return err
+	}
+	if len(findings.Sources) == 0 {
+		logger.Print(lifecycle.NoVersionSourcesWarning)
+		return nil
+	}
+
+	return writeOutdatedStemcells(logger.Writer(), findings, olderThanDays)
+}
+
+// readDataTarFile reads every file written by collect, keyed by its path
+// within the tar
+func readDataTarFile(tarFilePath string) (map[string][]byte, error) {
+	tarFile, err := os.Open(tarFilePath)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf(FileNotFoundErrorFormat, tarFilePath))
+	}
+	defer tarFile.Close()
+
+	files := map[string][]byte{}
+	reader := tar.NewReader(tarFile)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, ReadDataTarFileErrorFormat, tarFilePath)
+		}
+
+		contents, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, errors.Wrapf(err, ReadDataTarFileErrorFormat, tarFilePath)
+		}
+		files[header.Name] = contents
+	}
+}
+
+func writeOutdatedStemcells(w io.Writer, findings lifecycle.Findings, olderThanDays int) error {
+	deployments := map[string]bool{}
+	outdatedDeployments := map[string]bool{}
+	var outdated, uncataloged []lifecycle.Finding
+	for _, finding := range findings.Findings {
+		if finding.Component != lifecycle.StemcellComponent {
+			continue
+		}
+		deployments[finding.Deployment] = true
+		if !finding.InCatalog {
+			uncataloged = append(uncataloged, finding)
+		} else if finding.PastEOL || finding.OlderThan(olderThanDays) {
+			outdated = append(outdated, finding)
+			outdatedDeployments[finding.Deployment] = true
+		}
+	}
+
+	if len(outdated) == 0 {
+		if olderThanDays > 0 {
+			fmt.Fprintf(w, NoOutdatedStemcellsAgeFormat+"\n", olderThanDays)
+		} else {
+			fmt.Fprintln(w, NoOutdatedStemcellsMessage)
+		}
+	} else {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "DEPLOYMENT\tSTEMCELL\tVERSION\tRELEASED\tAGE (DAYS)\tEOL\tCVES\tREASON")
+		for _, finding := range outdated {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%d\t%s\n", finding.Deployment, finding.Name, finding.Version, finding.ReleaseDate, finding.AgeDays, finding.EOLDate, finding.CVEs, outdatedReason(finding, olderThanDays))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, OutdatedStemcellsSummaryFormat, len(outdatedDeployments), len(deployments))
+	}
+
+	if len(uncataloged) > 0 {
+		fmt.Fprintln(w, UncatalogedStemcellsHeader)
+	}
+	for _, finding := range uncataloged {
+		fmt.Fprintf(w, "  %s %s (%s)\n", finding.Name, finding.Version, finding.Deployment)
+	}
+	return nil
+}
+
+func outdatedReason(finding lifecycle.Finding, olderThanDays int) string {
+	var reasons []string
+	if finding.PastEOL {
+		reasons = append(reasons, PastEOLReason)
+	}
+	if finding.OlderThan(olderThanDays) {
+		reasons = append(reasons, fmt.Sprintf(OlderThanReasonFormat, olderThanDays))
+	}
+	return strings.Join(reasons, ", ")
+}
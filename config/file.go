@@ -0,0 +1,212 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ProfilesKey = "profiles"
+
+	ReadConfigFileErrorFormat    = "could not read config file %s"
+	ProfileNotFoundErrorFormat   = "profile %q not found in config file, available profiles: %s"
+	NoProfilesErrorFormat        = "profile %q requested but the config file does not define any profiles"
+	InvalidConfigFileErrorFormat = "config file %s is invalid:\n%s"
+)
+
+// File is a parsed, interpolated and validated collector config file. Top
+// level settings are defaults shared by every profile; settings under
+// `profiles.<name>` override them when that profile is selected.
+type File struct {
+	defaults map[string]interface{}
+	profiles map[string]map[string]interface{}
+}
+
+type problem struct {
+	line    int
+	message string
+}
+
+// ReadFile reads a config file, interpolates ((var)) placeholders with the
+// given vars and validates every key against the schema. YAML and JSON files
+// report problems with line numbers; other formats viper understands are
+// validated without them.
+func ReadFile(path string, vars map[string]interface{}, schema Schema) (*File, error) {
+	tree, lines, err := parseFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, ReadConfigFileErrorFormat, path)
+	}
+
+	interpolated, err := Interpolate(tree, vars)
+	if err != nil {
+		return nil, err
+	}
+	settings := interpolated.(map[string]interface{})
+
+	var problems []problem
+	file := &File{profiles: map[string]map[string]interface{}{}}
+	file.defaults, problems = schema.validate(settings, "", lines)
+
+	if rawProfiles, ok := settings[ProfilesKey]; ok && rawProfiles != nil {
+		profilesMap, ok := rawProfiles.(map[string]interface{})
+		if !ok {
+			problems = append(problems, problem{line: lines[ProfilesKey], message: fmt.Sprintf("key %q must be a map of profile names to settings", ProfilesKey)})
+		}
+		for name, rawProfile := range profilesMap {
+			profilePath := ProfilesKey + "." + name
+			profileSettings, ok := rawProfile.(map[string]interface{})
+			if !ok {
+				problems = append(problems, problem{line: lines[profilePath], message: fmt.Sprintf("profile %q must be a map of settings", name)})
+				continue
+			}
+			validated, profileProblems := schema.validate(profileSettings, profilePath+".", lines)
+			if _, nested := profileSettings[ProfilesKey]; nested {
+				profileProblems = append(profileProblems, problem{line: lines[profilePath+"."+ProfilesKey], message: fmt.Sprintf("profile %q cannot define profiles", name)})
+			}
+			file.profiles[name] = validated
+			problems = append(problems, profileProblems...)
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, errors.Errorf(InvalidConfigFileErrorFormat, path, formatProblems(problems))
+	}
+
+	return file, nil
+}
+
+// Profiles returns the sorted names of the profiles defined in the file
+func (f *File) Profiles() []string {
+	var names []string
+	for name := range f.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Settings returns the shared settings merged with those of the named
+// profile. An empty profile name returns only the shared settings.
+func (f *File) Settings(profile string) (map[string]interface{}, error) {
+	settings := map[string]interface{}{}
+	for k, v := range f.defaults {
+		settings[k] = v
+	}
+
+	if profile == "" {
+		return settings, nil
+	}
+
+	profileSettings, ok := f.profiles[profile]
+	if !ok {
+		if len(f.profiles) == 0 {
+			return nil, errors.Errorf(NoProfilesErrorFormat, profile)
+		}
+		return nil, errors.Errorf(ProfileNotFoundErrorFormat, profile, strings.Join(f.Profiles(), ", "))
+	}
+	for k, v := range profileSettings {
+		settings[k] = v
+	}
+
+	return settings, nil
+}
+
+func parseFile(path string) (map[string]interface{}, map[string]int, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml", ".json":
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var document yaml.Node
+		if err := yaml.Unmarshal(contents, &document); err != nil {
+			return nil, nil, err
+		}
+
+		lines := map[string]int{}
+		if len(document.Content) == 0 {
+			return map[string]interface{}{}, lines, nil
+		}
+
+		root := document.Content[0]
+		if root.Kind != yaml.MappingNode {
+			return nil, nil, errors.Errorf("line %d: expected a map of settings", root.Line)
+		}
+
+		tree, err := nodeToMap(root, "", lines)
+		return tree, lines, err
+	default:
+		v := viper.New()
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, nil, err
+		}
+		return v.AllSettings(), map[string]int{}, nil
+	}
+}
+
+func nodeToMap(node *yaml.Node, prefix string, lines map[string]int) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		if valueNode.Kind == yaml.AliasNode {
+			valueNode = valueNode.Alias
+		}
+
+		if keyNode.Tag == "!!merge" && valueNode.Kind == yaml.MappingNode {
+			merged, err := nodeToMap(valueNode, prefix, lines)
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range merged {
+				if _, exists := result[k]; !exists {
+					result[k] = v
+				}
+			}
+			continue
+		}
+
+		key := strings.ToLower(keyNode.Value)
+		lines[prefix+key] = keyNode.Line
+
+		if valueNode.Kind == yaml.MappingNode {
+			nested, err := nodeToMap(valueNode, prefix+key+".", lines)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = nested
+			continue
+		}
+
+		var value interface{}
+		if err := valueNode.Decode(&value); err != nil {
+			return nil, errors.Wrapf(err, "line %d", valueNode.Line)
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+func formatProblems(problems []problem) string {
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].line < problems[j].line
+	})
+
+	var formatted []string
+	for _, p := range problems {
+		if p.line > 0 {
+			formatted = append(formatted, fmt.Sprintf("  line %d: %s", p.line, p.message))
+		} else {
+			formatted = append(formatted, "  "+p.message)
+		}
+	}
+	return strings.Join(formatted, "\n")
+}
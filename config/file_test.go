@@ -0,0 +1,169 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotal-cf/aqueduct-courier/config"
+)
+
+var _ = Describe("ReadFile", func() {
+	var (
+		tempDir string
+		schema  Schema
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = os.MkdirTemp("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		schema = Schema{
+			"url":                 {Name: "url", Type: StringKey},
+			"env-type":            {Name: "env-type", Type: StringKey},
+			"ops-manager-timeout": {Name: "ops-manager-timeout", Type: IntKey},
+			"with-credhub-info":   {Name: "with-credhub-info", Type: BoolKey},
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tempDir)).To(Succeed())
+	})
+
+	writeConfig := func(name, contents string) string {
+		configFile := filepath.Join(tempDir, name)
+		Expect(os.WriteFile(configFile, []byte(contents), 0600)).To(Succeed())
+		return configFile
+	}
+
+	It("reads a yaml config file and interpolates its variables", func() {
+		configFile := writeConfig("config.yml", "url: ((url))\nops-manager-timeout: ((timeout))\n")
+
+		file, err := ReadFile(configFile, map[string]interface{}{"url": "https://opsman.example.com", "timeout": 10}, schema)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Settings("")).To(Equal(map[string]interface{}{
+			"url":                 "https://opsman.example.com",
+			"ops-manager-timeout": 10,
+		}))
+	})
+
+	It("reads a json config file", func() {
+		configFile := writeConfig("config.json", `{"env-type": "((env_type))", "with-credhub-info": "true"}`)
+
+		file, err := ReadFile(configFile, map[string]interface{}{"env_type": "production"}, schema)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Settings("")).To(Equal(map[string]interface{}{"env-type": "production", "with-credhub-info": "true"}))
+	})
+
+	It("rejects unknown keys and values of the wrong type with their line numbers", func() {
+		configFile := writeConfig("config.yml", `---
+url: https://opsman.example.com
+usrname: typo
+ops-manager-timeout: soon
+profiles:
+  prod-east:
+    with-credhub-info: maybe
+    env-typ: production
+`)
+
+		_, err := ReadFile(configFile, nil, schema)
+		Expect(err).To(MatchError(ContainSubstring("is invalid")))
+		Expect(err).To(MatchError(ContainSubstring(`line 3: unknown key "usrname"`)))
+		Expect(err).To(MatchError(ContainSubstring(`line 4: key "ops-manager-timeout" must be a number, found soon`)))
+		Expect(err).To(MatchError(ContainSubstring(`line 7: key "with-credhub-info" must be a boolean, found maybe`)))
+		Expect(err).To(MatchError(ContainSubstring(`line 8: unknown key "env-typ"`)))
+	})
+
+	It("rejects profiles which are not maps", func() {
+		configFile := writeConfig("config.yml", "profiles:\n  prod-east: https://opsman.example.com\n")
+
+		_, err := ReadFile(configFile, nil, schema)
+		Expect(err).To(MatchError(ContainSubstring(`line 2: profile "prod-east" must be a map of settings`)))
+	})
+
+	It("returns an error when a variable is missing", func() {
+		configFile := writeConfig("config.yml", "url: ((url))\n")
+
+		_, err := ReadFile(configFile, map[string]interface{}{}, schema)
+		Expect(err).To(MatchError("Expected to find variables: url"))
+	})
+
+	It("returns an error when the file cannot be read", func() {
+		_, err := ReadFile(filepath.Join(tempDir, "missing.yml"), nil, schema)
+		Expect(err).To(MatchError(ContainSubstring("could not read config file")))
+	})
+
+	Describe("profiles", func() {
+		var file *File
+
+		BeforeEach(func() {
+			configFile := writeConfig("config.yml", `---
+env-type: production
+with-credhub-info: true
+profiles:
+  prod-west:
+    url: https://west.example.com
+  prod-east:
+    url: https://east.example.com
+    with-credhub-info: false
+`)
+			var err error
+			file, err = ReadFile(configFile, nil, schema)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("lists the profile names", func() {
+			Expect(file.Profiles()).To(Equal([]string{"prod-east", "prod-west"}))
+		})
+
+		It("merges the selected profile over the shared settings", func() {
+			Expect(file.Settings("prod-east")).To(Equal(map[string]interface{}{
+				"env-type":          "production",
+				"with-credhub-info": false,
+				"url":               "https://east.example.com",
+			}))
+		})
+
+		It("returns only the shared settings when no profile is selected", func() {
+			Expect(file.Settings("")).To(Equal(map[string]interface{}{
+				"env-type":          "production",
+				"with-credhub-info": true,
+			}))
+		})
+
+		It("returns an error for an unknown profile", func() {
+			_, err := file.Settings("prod-north")
+			Expect(err).To(MatchError(`profile "prod-north" not found in config file, available profiles: prod-east, prod-west`))
+		})
+	})
+
+	It("supports yaml merge keys within profiles", func() {
+		configFile := writeConfig("config.yml", `---
+profiles:
+  prod-west: &west
+    url: https://west.example.com
+    ops-manager-timeout: 60
+  prod-east:
+    <<: *west
+    url: https://east.example.com
+`)
+
+		file, err := ReadFile(configFile, nil, schema)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Settings("prod-east")).To(Equal(map[string]interface{}{
+			"url":                 "https://east.example.com",
+			"ops-manager-timeout": 60,
+		}))
+	})
+
+	It("returns an error when a profile is requested from a file without profiles", func() {
+		configFile := writeConfig("config.yml", "url: https://opsman.example.com\n")
+
+		file, err := ReadFile(configFile, nil, schema)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = file.Settings("prod-east")
+		Expect(err).To(MatchError(`profile "prod-east" requested but the config file does not define any profiles`))
+	})
+})
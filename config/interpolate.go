@@ -0,0 +1,167 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ReadVarsFileErrorFormat        = "could not read vars file %s"
+	InvalidVarsFileErrorFormat     = "vars file %s must contain a map of variables"
+	InvalidVarsEnvValueErrorFormat = "could not parse value of environment variable %s"
+	MissingVariablesErrorFormat    = "Expected to find variables: %s"
+	InvalidVariableTypeErrorFormat = "variable ((%s)) cannot be interpolated into a string, found a %T"
+)
+
+var variablePattern = regexp.MustCompile(`\(\(([-/.\w]+)\)\)`)
+
+// LoadVars builds the variable set used for ((var)) interpolation. Variables
+// from environment variables with one of the given prefixes are read first,
+// then each vars file in order, with later sources taking precedence.
+func LoadVars(varsFiles, varsEnvPrefixes, environ []string) (map[string]interface{}, error) {
+	vars := map[string]interface{}{}
+
+	for _, prefix := range varsEnvPrefixes {
+		prefix = strings.TrimSuffix(prefix, "_") + "_"
+		for _, envVar := range environ {
+			key, value, found := strings.Cut(envVar, "=")
+			if !found || !strings.HasPrefix(key, prefix) || key == prefix {
+				continue
+			}
+
+			var parsed interface{}
+			if err := yaml.Unmarshal([]byte(value), &parsed); err != nil {
+				return nil, errors.Wrapf(err, InvalidVarsEnvValueErrorFormat, key)
+			}
+			vars[strings.TrimPrefix(key, prefix)] = parsed
+		}
+	}
+
+	for _, varsFile := range varsFiles {
+		contents, err := os.ReadFile(varsFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, ReadVarsFileErrorFormat, varsFile)
+		}
+
+		var fileVars map[string]interface{}
+		if err := yaml.Unmarshal(contents, &fileVars); err != nil {
+			return nil, errors.Wrapf(err, InvalidVarsFileErrorFormat, varsFile)
+		}
+		for k, v := range fileVars {
+			vars[k] = v
+		}
+	}
+
+	return vars, nil
+}
+
+// Interpolate replaces ((var)) placeholders in every string value of the
+// given tree. A placeholder that makes up a whole value is replaced by the
+// variable as-is, so it may be a number, boolean or map; placeholders embedded
+// in a longer string must resolve to scalar values. Nested variables are
+// addressed with dots, e.g. ((opsman.password)).
+func Interpolate(node interface{}, vars map[string]interface{}) (interface{}, error) {
+	missing := map[string]bool{}
+	interpolated, err := interpolateNode(node, vars, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(missing) > 0 {
+		var names []string
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, errors.Errorf(MissingVariablesErrorFormat, strings.Join(names, ", "))
+	}
+
+	return interpolated, nil
+}
+
+func interpolateNode(node interface{}, vars map[string]interface{}, missing map[string]bool) (interface{}, error) {
+	switch typed := node.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(typed))
+		for k, v := range typed {
+			interpolated, err := interpolateNode(v, vars, missing)
+			if err != nil {
+				return nil, err
+			}
+			result[k] = interpolated
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, len(typed))
+		for i, v := range typed {
+			interpolated, err := interpolateNode(v, vars, missing)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = interpolated
+		}
+		return result, nil
+	case string:
+		return interpolateString(typed, vars, missing)
+	default:
+		return node, nil
+	}
+}
+
+func interpolateString(s string, vars map[string]interface{}, missing map[string]bool) (interface{}, error) {
+	if match := variablePattern.FindStringSubmatch(s); match != nil && match[0] == s {
+		value, found := lookupVariable(match[1], vars)
+		if !found {
+			missing[match[1]] = true
+			return s, nil
+		}
+		return value, nil
+	}
+
+	var typeErr error
+	result := variablePattern.ReplaceAllStringFunc(s, func(placeholder string) string {
+		name := variablePattern.FindStringSubmatch(placeholder)[1]
+		value, found := lookupVariable(name, vars)
+		if !found {
+			missing[name] = true
+			return placeholder
+		}
+
+		switch value.(type) {
+		case string, int, int64, float64, bool:
+			return fmt.Sprint(value)
+		default:
+			if typeErr == nil {
+				typeErr = errors.Errorf(InvalidVariableTypeErrorFormat, name, value)
+			}
+			return placeholder
+		}
+	})
+
+	return result, typeErr
+}
+
+func lookupVariable(name string, vars map[string]interface{}) (interface{}, bool) {
+	if value, found := vars[name]; found {
+		return value, true
+	}
+
+	var current interface{} = vars
+	for _, part := range strings.Split(name, ".") {
+		currentMap, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = currentMap[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
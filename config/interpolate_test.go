@@ -0,0 +1,121 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotal-cf/aqueduct-courier/config"
+)
+
+var _ = Describe("Interpolate", func() {
+	var vars map[string]interface{}
+
+	BeforeEach(func() {
+		vars = map[string]interface{}{
+			"password": "some-password",
+			"timeout":  30,
+			"opsman": map[string]interface{}{
+				"host": "opsman.example.com",
+			},
+		}
+	})
+
+	It("replaces whole values and keeps the variable type", func() {
+		result, err := Interpolate(map[string]interface{}{
+			"password": "((password))",
+			"timeout":  "((timeout))",
+			"nested":   []interface{}{"((opsman))"},
+		}, vars)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(map[string]interface{}{
+			"password": "some-password",
+			"timeout":  30,
+			"nested":   []interface{}{map[string]interface{}{"host": "opsman.example.com"}},
+		}))
+	})
+
+	It("replaces placeholders embedded in strings, including nested variables", func() {
+		result, err := Interpolate(map[string]interface{}{
+			"url": "https://((opsman.host)):((timeout))",
+		}, vars)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(map[string]interface{}{
+			"url": "https://opsman.example.com:30",
+		}))
+	})
+
+	It("leaves values without placeholders untouched", func() {
+		result, err := Interpolate(map[string]interface{}{"enabled": true, "name": "plain"}, vars)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(map[string]interface{}{"enabled": true, "name": "plain"}))
+	})
+
+	It("returns an error listing every missing variable", func() {
+		_, err := Interpolate(map[string]interface{}{
+			"a": "((zebra))",
+			"b": "prefix-((apple))",
+		}, vars)
+		Expect(err).To(MatchError("Expected to find variables: apple, zebra"))
+	})
+
+	It("returns an error when a map is embedded in a string", func() {
+		_, err := Interpolate(map[string]interface{}{"url": "https://((opsman))"}, vars)
+		Expect(err).To(MatchError(ContainSubstring("variable ((opsman)) cannot be interpolated into a string")))
+	})
+})
+
+var _ = Describe("LoadVars", func() {
+	var tempDir string
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = os.MkdirTemp("", "")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tempDir)).To(Succeed())
+	})
+
+	It("loads variables from prefixed environment variables", func() {
+		vars, err := LoadVars(nil, []string{"OM_VAR"}, []string{
+			"OM_VAR_password=some-password",
+			"OM_VAR_timeout=30",
+			"OTHER_password=ignored",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(vars).To(Equal(map[string]interface{}{
+			"password": "some-password",
+			"timeout":  30,
+		}))
+	})
+
+	It("loads variables from vars files, later files taking precedence over the environment", func() {
+		firstVarsFile := filepath.Join(tempDir, "first.yml")
+		Expect(os.WriteFile(firstVarsFile, []byte("password: from-file\nuser: first-user\n"), 0600)).To(Succeed())
+		secondVarsFile := filepath.Join(tempDir, "second.yml")
+		Expect(os.WriteFile(secondVarsFile, []byte("user: second-user\n"), 0600)).To(Succeed())
+
+		vars, err := LoadVars([]string{firstVarsFile, secondVarsFile}, []string{"OM_VAR_"}, []string{"OM_VAR_password=from-env"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(vars).To(Equal(map[string]interface{}{
+			"password": "from-file",
+			"user":     "second-user",
+		}))
+	})
+
+	It("returns an error when a vars file does not exist", func() {
+		_, err := LoadVars([]string{filepath.Join(tempDir, "missing.yml")}, nil, nil)
+		Expect(err).To(MatchError(ContainSubstring("could not read vars file")))
+	})
+
+	It("returns an error when a vars file is not a map", func() {
+		varsFile := filepath.Join(tempDir, "vars.yml")
+		Expect(os.WriteFile(varsFile, []byte("- not\n- a map\n"), 0600)).To(Succeed())
+
+		_, err := LoadVars([]string{varsFile}, nil, nil)
+		Expect(err).To(MatchError(ContainSubstring("must contain a map of variables")))
+	})
+})
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"bytes"
+	"os"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ReadOmEnvFileErrorFormat    = "could not read om env file %s"
+	InvalidOmEnvFileErrorFormat = "could not parse om env file %s"
+)
+
+// OmEnv is the env file format accepted by the om CLI and Platform Automation
+type OmEnv struct {
+	Target               string `yaml:"target"`
+	Username             string `yaml:"username"`
+	Password             string `yaml:"password"`
+	ClientID             string `yaml:"client-id"`
+	ClientSecret         string `yaml:"client-secret"`
+	SkipSSLValidation    bool   `yaml:"skip-ssl-validation"`
+	ConnectTimeout       int    `yaml:"connect-timeout"`
+	RequestTimeout       int    `yaml:"request-timeout"`
+	DecryptionPassphrase string `yaml:"decryption-passphrase"`
+	CACert               string `yaml:"ca-cert"`
+}
+
+// ReadOmEnv parses an om env file, interpolating any ((var)) placeholders
+// with the given vars. Keys om does not know about are rejected.
+func ReadOmEnv(path string, vars map[string]interface{}) (OmEnv, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return OmEnv{}, errors.Wrapf(err, ReadOmEnvFileErrorFormat, path)
+	}
+
+	var raw interface{}
+	if err := yaml.Unmarshal(contents, &raw); err != nil {
+		return OmEnv{}, errors.Wrapf(err, InvalidOmEnvFileErrorFormat, path)
+	}
+
+	interpolated, err := Interpolate(raw, vars)
+	if err != nil {
+		return OmEnv{}, errors.Wrapf(err, InvalidOmEnvFileErrorFormat, path)
+	}
+
+	interpolatedContents, err := yaml.Marshal(interpolated)
+	if err != nil {
+		return OmEnv{}, errors.Wrapf(err, InvalidOmEnvFileErrorFormat, path)
+	}
+
+	var env OmEnv
+	decoder := yaml.NewDecoder(bytes.NewReader(interpolatedContents))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&env); err != nil {
+		return OmEnv{}, errors.Wrapf(err, InvalidOmEnvFileErrorFormat, path)
+	}
+
+	return env, nil
+}
+
+// UnsupportedKeys returns the keys set in the om env file which om accepts
+// but the collector does not use, so they can be reported instead of being
+// silently ignored.
+func (e OmEnv) UnsupportedKeys() []string {
+	var keys []string
+	if e.CACert != "" {
+		keys = append(keys, "ca-cert")
+	}
+	if e.DecryptionPassphrase != "" {
+		keys = append(keys, "decryption-passphrase")
+	}
+	return keys
+}
@@ -0,0 +1,92 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotal-cf/aqueduct-courier/config"
+)
+
+var _ = Describe("ReadOmEnv", func() {
+	var (
+		tempDir string
+		envFile string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = os.MkdirTemp("", "")
+		Expect(err).NotTo(HaveOccurred())
+		envFile = filepath.Join(tempDir, "env.yml")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tempDir)).To(Succeed())
+	})
+
+	It("parses an om env file and interpolates its variables", func() {
+		Expect(os.WriteFile(envFile, []byte(`---
+target: https://opsman.example.com
+connect-timeout: 10
+request-timeout: 1800
+skip-ssl-validation: true
+username: admin
+password: ((opsman_password))
+decryption-passphrase: ignored
+`), 0600)).To(Succeed())
+
+		env, err := ReadOmEnv(envFile, map[string]interface{}{"opsman_password": "some-password"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env).To(Equal(OmEnv{
+			Target:               "https://opsman.example.com",
+			Username:             "admin",
+			Password:             "some-password",
+			SkipSSLValidation:    true,
+			ConnectTimeout:       10,
+			RequestTimeout:       1800,
+			DecryptionPassphrase: "ignored",
+		}))
+	})
+
+	It("reports the keys the collector does not use", func() {
+		Expect(os.WriteFile(envFile, []byte(`---
+target: https://opsman.example.com
+ca-cert: some-ca
+decryption-passphrase: some-passphrase
+`), 0600)).To(Succeed())
+
+		env, err := ReadOmEnv(envFile, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env.UnsupportedKeys()).To(Equal([]string{"ca-cert", "decryption-passphrase"}))
+	})
+
+	It("reports no unsupported keys when only supported keys are set", func() {
+		Expect(os.WriteFile(envFile, []byte("target: https://opsman.example.com\nusername: admin\n"), 0600)).To(Succeed())
+
+		env, err := ReadOmEnv(envFile, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env.UnsupportedKeys()).To(BeEmpty())
+	})
+
+	It("returns an error for keys om does not accept", func() {
+		Expect(os.WriteFile(envFile, []byte("target: https://opsman.example.com\ntargett: typo\n"), 0600)).To(Succeed())
+
+		_, err := ReadOmEnv(envFile, nil)
+		Expect(err).To(MatchError(ContainSubstring("could not parse om env file")))
+		Expect(err).To(MatchError(ContainSubstring("field targett not found")))
+	})
+
+	It("returns an error when a variable is missing", func() {
+		Expect(os.WriteFile(envFile, []byte("password: ((opsman_password))\n"), 0600)).To(Succeed())
+
+		_, err := ReadOmEnv(envFile, nil)
+		Expect(err).To(MatchError(ContainSubstring("Expected to find variables: opsman_password")))
+	})
+
+	It("returns an error when the file does not exist", func() {
+		_, err := ReadOmEnv(envFile, nil)
+		Expect(err).To(MatchError(ContainSubstring("could not read om env file")))
+	})
+})
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+type KeyType string
+
+const (
+	StringKey      KeyType = "string"
+	IntKey         KeyType = "int"
+	BoolKey        KeyType = "bool"
+	StringSliceKey KeyType = "stringSlice"
+)
+
+// Key documents a single setting accepted in the config file
+type Key struct {
+	Name        string
+	Type        KeyType
+	Description string
+}
+
+// Schema is the set of settings accepted in the config file, keyed by name
+type Schema map[string]Key
+
+// Keys returns the schema keys sorted by name
+func (s Schema) Keys() []Key {
+	var keys []Key
+	for _, k := range s {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name < keys[j].Name
+	})
+	return keys
+}
+
+func (s Schema) validate(settings map[string]interface{}, pathPrefix string, lines map[string]int) (map[string]interface{}, []problem) {
+	validated := map[string]interface{}{}
+	var problems []problem
+
+	for name, value := range settings {
+		if name == ProfilesKey {
+			continue
+		}
+
+		line := lines[pathPrefix+name]
+		key, known := s[name]
+		if !known {
+			problems = append(problems, problem{line: line, message: fmt.Sprintf("unknown key %q", name)})
+			continue
+		}
+
+		if value == nil {
+			continue
+		}
+
+		if !key.Type.accepts(value) {
+			problems = append(problems, problem{line: line, message: fmt.Sprintf("key %q must be a %s, found %v", name, key.Type.Description(), value)})
+			continue
+		}
+		validated[name] = value
+	}
+
+	return validated, problems
+}
+
+func (t KeyType) accepts(value interface{}) bool {
+	switch t {
+	case IntKey:
+		switch typed := value.(type) {
+		case int, int64:
+			return true
+		case string:
+			_, err := strconv.Atoi(typed)
+			return err == nil
+		}
+		return false
+	case BoolKey:
+		switch typed := value.(type) {
+		case bool:
+			return true
+		case string:
+			_, err := strconv.ParseBool(typed)
+			return err == nil
+		}
+		return false
+	case StringSliceKey:
+		switch typed := value.(type) {
+		case string:
+			return true
+		case []interface{}:
+			for _, element := range typed {
+				if !isScalar(element) {
+					return false
+				}
+			}
+			return true
+		}
+		return false
+	default:
+		return isScalar(value)
+	}
+}
+
+// Description is the human readable name of the type, used in errors and help text
+func (t KeyType) Description() string {
+	switch t {
+	case IntKey:
+		return "number"
+	case BoolKey:
+		return "boolean"
+	case StringSliceKey:
+		return "list of strings"
+	default:
+		return "string"
+	}
+}
+
+func isScalar(value interface{}) bool {
+	switch value.(type) {
+	case string, int, int64, float64, bool:
+		return true
+	}
+	return false
+}
@@ -6,15 +6,23 @@ import (
 
 type Data struct {
 	reader   io.Reader
+	name     string
 	dataType string
 }
 
 func NewData(reader io.Reader, dataType string) Data {
-	return Data{reader: reader, dataType: dataType}
+	return Data{reader: reader, name: dataType, dataType: dataType}
+}
+
+// NewSnapshotData returns data computed from the CF API rather than read from
+// a Usage Service report. It is written to the file of the report it stands
+// in for, and recorded in the metadata with the snapshot data type.
+func NewSnapshotData(reader io.Reader, dataType string) Data {
+	return Data{reader: reader, name: dataType, dataType: dataType + SnapshotDataTypeSuffix}
 }
 
 func (d Data) Name() string {
-	return d.dataType
+	return d.name
 }
 
 func (d Data) Content() io.Reader {
@@ -32,6 +32,13 @@ var _ = Describe("Data", func() {
 		Expect(d.Type()).To(Equal(""))
 	})
 
+	It("returns the snapshot data type and the report name for snapshot data", func() {
+		d := NewSnapshotData(nil, collector_tar.AppUsageDataType)
+		Expect(d.Name()).To(Equal(collector_tar.AppUsageDataType))
+		Expect(d.Type()).To(Equal(""))
+		Expect(d.DataType()).To(Equal(AppUsageSnapshotDataType))
+	})
+
 	It("returns the data type", func() {
 		d := NewData(nil, collector_tar.AppUsageDataType)
 		Expect(d.DataType()).To(Equal(collector_tar.AppUsageDataType))
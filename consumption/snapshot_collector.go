@@ -0,0 +1,294 @@
+package consumption
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/pivotal-cf/telemetry-utils/collector_tar"
+	"github.com/pkg/errors"
+)
+
+const (
+	SnapshotDataTypeSuffix       = "_snapshot"
+	AppUsageSnapshotDataType     = collector_tar.AppUsageDataType + SnapshotDataTypeSuffix
+	ServiceUsageSnapshotDataType = collector_tar.ServiceUsageDataType + SnapshotDataTypeSuffix
+
+	CfAppsPath             = "/v3/apps"
+	CfProcessesPath        = "/v3/processes"
+	CfServiceInstancesPath = "/v3/service_instances"
+	CfServicePlansPath     = "/v3/service_plans"
+	CfServiceOfferingsPath = "/v3/service_offerings"
+
+	CfApiRequestErrorFormat       = "Failed retrieving %s"
+	UnmarshalResourcesErrorFormat = "error unmarshalling resources from %s"
+
+	snapshotReportTimeFormat = "2006-01-02 15:04:05 UTC"
+)
+
+// SnapshotEndpointPaths lists every CF API endpoint the snapshot reads
+var SnapshotEndpointPaths = []string{CfAppsPath, CfProcessesPath, CfServiceOfferingsPath, CfServicePlansPath, CfServiceInstancesPath}
+
+//go:generate counterfeiter . cfApiService
+type cfApiService interface {
+	List(endpointPath string, query url.Values) ([]json.RawMessage, error)
+}
+
+// SnapshotCollector computes point in time app and service instance counts
+// from the CF API, for foundations without Usage Service. The reports have the
+// shape of the Usage Service reports, with the current counts as both the
+// average and the maximum of the current month and year. Durations cannot be
+// known from a single point in time, so they are left out.
+type SnapshotCollector struct {
+	logger   *log.Logger
+	service  cfApiService
+	cfApiURL string
+	now      func() time.Time
+}
+
+type snapshotAppReport struct {
+	ReportTime     string                     `json:"report_time"`
+	MonthlyReports []snapshotMonthlyAppReport `json:"monthly_reports"`
+	YearlyReports  []snapshotYearlyAppReport  `json:"yearly_reports"`
+}
+
+type snapshotMonthlyAppReport struct {
+	Month               int `json:"month"`
+	Year                int `json:"year"`
+	AverageAppInstances int `json:"average_app_instances"`
+	MaximumAppInstances int `json:"maximum_app_instances"`
+}
+
+type snapshotYearlyAppReport struct {
+	Year                int `json:"year"`
+	AverageAppInstances int `json:"average_app_instances"`
+	MaximumAppInstances int `json:"maximum_app_instances"`
+}
+
+type snapshotServiceReport struct {
+	ReportTime            string                         `json:"report_time"`
+	MonthlyServiceReports []snapshotMonthlyServiceReport `json:"monthly_service_reports"`
+	YearlyServiceReport   []snapshotYearlyServiceReport  `json:"yearly_service_report"`
+}
+
+type snapshotUsage struct {
+	Month            int `json:"month"`
+	Year             int `json:"year"`
+	AverageInstances int `json:"average_instances"`
+	MaximumInstances int `json:"maximum_instances"`
+}
+
+type snapshotMonthlyServiceReport struct {
+	ServiceName string                      `json:"service_name"`
+	ServiceGUID string                      `json:"service_guid"`
+	Usages      []snapshotUsage             `json:"usages"`
+	Plans       []snapshotMonthlyPlanReport `json:"plans"`
+}
+
+type snapshotMonthlyPlanReport struct {
+	Usages          []snapshotUsage `json:"usages"`
+	ServicePlanGUID string          `json:"service_plan_guid"`
+}
+
+type snapshotYearlyServiceReport struct {
+	ServiceName      string                     `json:"service_name"`
+	ServiceGUID      string                     `json:"service_guid"`
+	Year             int                        `json:"year"`
+	MaximumInstances int                        `json:"maximum_instances"`
+	AverageInstances int                        `json:"average_instances"`
+	Plans            []snapshotYearlyPlanReport `json:"plans"`
+}
+
+type snapshotYearlyPlanReport struct {
+	Year             int    `json:"year"`
+	ServicePlanGUID  string `json:"service_plan_guid"`
+	MaximumInstances int    `json:"maximum_instances"`
+	AverageInstances int    `json:"average_instances"`
+}
+
+type cfResource struct {
+	GUID          string `json:"guid"`
+	Name          string `json:"name"`
+	Instances     int    `json:"instances"`
+	Relationships struct {
+		App             cfRelationship `json:"app"`
+		ServicePlan     cfRelationship `json:"service_plan"`
+		ServiceOffering cfRelationship `json:"service_offering"`
+	} `json:"relationships"`
+}
+
+type cfRelationship struct {
+	Data struct {
+		GUID string `json:"guid"`
+	} `json:"data"`
+}
+
+func NewSnapshotCollector(logger *log.Logger, service cfApiService, cfApiURL string, now func() time.Time) *SnapshotCollector {
+	return &SnapshotCollector{
+		logger:   logger,
+		service:  service,
+		cfApiURL: cfApiURL,
+		now:      now,
+	}
+}
+
+func (sc *SnapshotCollector) Collect() ([]Data, error) {
+	sc.logger.Printf("Collecting app and service instance snapshot from CF API at %s", sc.cfApiURL)
+	collectedAt := sc.now().UTC()
+
+	appReport, err := sc.appReport(collectedAt)
+	if err != nil {
+		return []Data{}, err
+	}
+
+	serviceReport, err := sc.serviceReport(collectedAt)
+	if err != nil {
+		return []Data{}, err
+	}
+
+	appContents, err := json.Marshal(appReport)
+	if err != nil {
+		return []Data{}, err
+	}
+	serviceContents, err := json.Marshal(serviceReport)
+	if err != nil {
+		return []Data{}, err
+	}
+
+	return []Data{
+		NewSnapshotData(bytes.NewReader(appContents), collector_tar.AppUsageDataType),
+		NewSnapshotData(bytes.NewReader(serviceContents), collector_tar.ServiceUsageDataType),
+	}, nil
+}
+
+// appReport counts the instances of every process of the started apps
+func (sc *SnapshotCollector) appReport(collectedAt time.Time) (snapshotAppReport, error) {
+	startedApps, err := sc.list(CfAppsPath, url.Values{"states": {"STARTED"}})
+	if err != nil {
+		return snapshotAppReport{}, err
+	}
+	started := map[string]bool{}
+	for _, app := range startedApps {
+		started[app.GUID] = true
+	}
+
+	processes, err := sc.list(CfProcessesPath, nil)
+	if err != nil {
+		return snapshotAppReport{}, err
+	}
+	instances := 0
+	for _, process := range processes {
+		if started[process.Relationships.App.Data.GUID] {
+			instances += process.Instances
+		}
+	}
+
+	return snapshotAppReport{
+		ReportTime: collectedAt.Format(snapshotReportTimeFormat),
+		MonthlyReports: []snapshotMonthlyAppReport{{
+			Month:               int(collectedAt.Month()),
+			Year:                collectedAt.Year(),
+			AverageAppInstances: instances,
+			MaximumAppInstances: instances,
+		}},
+		YearlyReports: []snapshotYearlyAppReport{{
+			Year:                collectedAt.Year(),
+			AverageAppInstances: instances,
+			MaximumAppInstances: instances,
+		}},
+	}, nil
+}
+
+// serviceReport counts the managed service instances of each plan, grouped by
+// service offering. Plan names are left out, as they are from Usage Service
+// reports.
+func (sc *SnapshotCollector) serviceReport(collectedAt time.Time) (snapshotServiceReport, error) {
+	offerings, err := sc.list(CfServiceOfferingsPath, nil)
+	if err != nil {
+		return snapshotServiceReport{}, err
+	}
+	plans, err := sc.list(CfServicePlansPath, nil)
+	if err != nil {
+		return snapshotServiceReport{}, err
+	}
+	serviceInstances, err := sc.list(CfServiceInstancesPath, url.Values{"type": {"managed"}})
+	if err != nil {
+		return snapshotServiceReport{}, err
+	}
+
+	offeringNames := map[string]string{}
+	for _, offering := range offerings {
+		offeringNames[offering.GUID] = offering.Name
+	}
+	planOfferings := map[string]string{}
+	for _, plan := range plans {
+		planOfferings[plan.GUID] = plan.Relationships.ServiceOffering.Data.GUID
+	}
+	planInstances := map[string]map[string]int{}
+	for _, instance := range serviceInstances {
+		planGUID := instance.Relationships.ServicePlan.Data.GUID
+		offeringGUID := planOfferings[planGUID]
+		if planInstances[offeringGUID] == nil {
+			planInstances[offeringGUID] = map[string]int{}
+		}
+		planInstances[offeringGUID][planGUID]++
+	}
+
+	report := snapshotServiceReport{
+		ReportTime:            collectedAt.Format(snapshotReportTimeFormat),
+		MonthlyServiceReports: []snapshotMonthlyServiceReport{},
+		YearlyServiceReport:   []snapshotYearlyServiceReport{},
+	}
+	month, year := int(collectedAt.Month()), collectedAt.Year()
+	offeringGUIDs := make([]string, 0, len(planInstances))
+	for offeringGUID := range planInstances {
+		offeringGUIDs = append(offeringGUIDs, offeringGUID)
+	}
+	sort.Strings(offeringGUIDs)
+
+	for _, offeringGUID := range offeringGUIDs {
+		monthly := snapshotMonthlyServiceReport{ServiceName: offeringNames[offeringGUID], ServiceGUID: offeringGUID}
+		yearly := snapshotYearlyServiceReport{ServiceName: offeringNames[offeringGUID], ServiceGUID: offeringGUID, Year: year}
+
+		planGUIDs := make([]string, 0, len(planInstances[offeringGUID]))
+		for planGUID := range planInstances[offeringGUID] {
+			planGUIDs = append(planGUIDs, planGUID)
+		}
+		sort.Strings(planGUIDs)
+
+		total := 0
+		for _, planGUID := range planGUIDs {
+			count := planInstances[offeringGUID][planGUID]
+			total += count
+			monthly.Plans = append(monthly.Plans, snapshotMonthlyPlanReport{
+				ServicePlanGUID: planGUID,
+				Usages:          []snapshotUsage{{Month: month, Year: year, AverageInstances: count, MaximumInstances: count}},
+			})
+			yearly.Plans = append(yearly.Plans, snapshotYearlyPlanReport{Year: year, ServicePlanGUID: planGUID, AverageInstances: count, MaximumInstances: count})
+		}
+		monthly.Usages = []snapshotUsage{{Month: month, Year: year, AverageInstances: total, MaximumInstances: total}}
+		yearly.AverageInstances, yearly.MaximumInstances = total, total
+
+		report.MonthlyServiceReports = append(report.MonthlyServiceReports, monthly)
+		report.YearlyServiceReport = append(report.YearlyServiceReport, yearly)
+	}
+	return report, nil
+}
+
+func (sc *SnapshotCollector) list(endpointPath string, query url.Values) ([]cfResource, error) {
+	raw, err := sc.service.List(endpointPath, query)
+	if err != nil {
+		return nil, errors.Wrapf(err, CfApiRequestErrorFormat, endpointPath)
+	}
+
+	resources := make([]cfResource, len(raw))
+	for i, r := range raw {
+		if err := json.Unmarshal(r, &resources[i]); err != nil {
+			return nil, errors.Wrapf(err, UnmarshalResourcesErrorFormat, endpointPath)
+		}
+	}
+	return resources, nil
+}
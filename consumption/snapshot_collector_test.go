@@ -0,0 +1,170 @@
+package consumption_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pivotal-cf/telemetry-utils/collector_tar"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotal-cf/aqueduct-courier/consumption"
+	"github.com/pivotal-cf/aqueduct-courier/consumption/consumptionfakes"
+)
+
+var _ = Describe("SnapshotCollector", func() {
+	var (
+		logger            *log.Logger
+		bufferedOutput    *gbytes.Buffer
+		cfApiService      *consumptionfakes.FakeCfApiService
+		snapshotCollector *SnapshotCollector
+		listResponses     map[string]string
+	)
+
+	BeforeEach(func() {
+		bufferedOutput = gbytes.NewBuffer()
+		logger = log.New(bufferedOutput, "", 0)
+		cfApiService = new(consumptionfakes.FakeCfApiService)
+		now := func() time.Time {
+			return time.Date(2024, time.March, 5, 14, 30, 0, 0, time.FixedZone("somewhere", 3600))
+		}
+		snapshotCollector = NewSnapshotCollector(logger, cfApiService, "some-cf-api-url", now)
+
+		listResponses = map[string]string{
+			CfAppsPath: `[{"guid": "app-1", "name": "secret-app-name"}, {"guid": "app-2"}]`,
+			CfProcessesPath: `[
+				{"type": "web", "instances": 2, "relationships": {"app": {"data": {"guid": "app-1"}}}},
+				{"type": "worker", "instances": 3, "relationships": {"app": {"data": {"guid": "app-2"}}}},
+				{"type": "web", "instances": 4, "relationships": {"app": {"data": {"guid": "stopped-app"}}}}
+			]`,
+			CfServiceOfferingsPath: `[{"guid": "offering-1", "name": "p.mysql"}, {"guid": "offering-2", "name": "p.redis"}]`,
+			CfServicePlansPath: `[
+				{"guid": "plan-1", "name": "secret-plan-name", "relationships": {"service_offering": {"data": {"guid": "offering-1"}}}},
+				{"guid": "plan-2", "relationships": {"service_offering": {"data": {"guid": "offering-1"}}}},
+				{"guid": "plan-3", "relationships": {"service_offering": {"data": {"guid": "offering-2"}}}}
+			]`,
+			CfServiceInstancesPath: `[
+				{"guid": "si-1", "name": "secret-instance-name", "relationships": {"service_plan": {"data": {"guid": "plan-1"}}}},
+				{"guid": "si-2", "relationships": {"service_plan": {"data": {"guid": "plan-1"}}}},
+				{"guid": "si-3", "relationships": {"service_plan": {"data": {"guid": "plan-2"}}}}
+			]`,
+		}
+		cfApiService.ListStub = func(endpointPath string, query url.Values) ([]json.RawMessage, error) {
+			var resources []json.RawMessage
+			err := json.Unmarshal([]byte(listResponses[endpointPath]), &resources)
+			return resources, err
+		}
+	})
+
+	It("counts the running app instances in the shape of the app usage report", func() {
+		collectedData, err := snapshotCollector.Collect()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(bufferedOutput).To(gbytes.Say("Collecting app and service instance snapshot from CF API at some-cf-api-url"))
+		Expect(collectedData).To(HaveLen(2))
+		Expect(collectedData[0].Name()).To(Equal(collector_tar.AppUsageDataType))
+		Expect(collectedData[0].DataType()).To(Equal(AppUsageSnapshotDataType))
+
+		content, err := io.ReadAll(collectedData[0].Content())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(MatchJSON(`{
+			"report_time": "2024-03-05 13:30:00 UTC",
+			"monthly_reports": [{"month": 3, "year": 2024, "average_app_instances": 5, "maximum_app_instances": 5}],
+			"yearly_reports": [{"year": 2024, "average_app_instances": 5, "maximum_app_instances": 5}]
+		}`))
+		Expect(string(content)).NotTo(ContainSubstring("secret-app-name"))
+	})
+
+	It("only lists started apps", func() {
+		_, err := snapshotCollector.Collect()
+		Expect(err).NotTo(HaveOccurred())
+
+		endpointPath, query := cfApiService.ListArgsForCall(0)
+		Expect(endpointPath).To(Equal(CfAppsPath))
+		Expect(query).To(Equal(url.Values{"states": {"STARTED"}}))
+	})
+
+	It("counts the managed service instances in the shape of the service usage report", func() {
+		collectedData, err := snapshotCollector.Collect()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(collectedData[1].Name()).To(Equal(collector_tar.ServiceUsageDataType))
+		Expect(collectedData[1].DataType()).To(Equal(ServiceUsageSnapshotDataType))
+
+		content, err := io.ReadAll(collectedData[1].Content())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(MatchJSON(`{
+			"report_time": "2024-03-05 13:30:00 UTC",
+			"monthly_service_reports": [{
+				"service_name": "p.mysql",
+				"service_guid": "offering-1",
+				"usages": [{"month": 3, "year": 2024, "average_instances": 3, "maximum_instances": 3}],
+				"plans": [
+					{"service_plan_guid": "plan-1", "usages": [{"month": 3, "year": 2024, "average_instances": 2, "maximum_instances": 2}]},
+					{"service_plan_guid": "plan-2", "usages": [{"month": 3, "year": 2024, "average_instances": 1, "maximum_instances": 1}]}
+				]
+			}],
+			"yearly_service_report": [{
+				"service_name": "p.mysql",
+				"service_guid": "offering-1",
+				"year": 2024,
+				"average_instances": 3,
+				"maximum_instances": 3,
+				"plans": [
+					{"service_plan_guid": "plan-1", "year": 2024, "average_instances": 2, "maximum_instances": 2},
+					{"service_plan_guid": "plan-2", "year": 2024, "average_instances": 1, "maximum_instances": 1}
+				]
+			}]
+		}`))
+		Expect(string(content)).NotTo(ContainSubstring("secret"))
+	})
+
+	It("only lists managed service instances", func() {
+		_, err := snapshotCollector.Collect()
+		Expect(err).NotTo(HaveOccurred())
+
+		for i := 0; i < cfApiService.ListCallCount(); i++ {
+			endpointPath, query := cfApiService.ListArgsForCall(i)
+			if endpointPath == CfServiceInstancesPath {
+				Expect(query).To(Equal(url.Values{"type": {"managed"}}))
+				return
+			}
+		}
+		Fail("service instances were not listed")
+	})
+
+	It("returns empty service reports when there are no service instances", func() {
+		listResponses[CfServiceInstancesPath] = `[]`
+
+		collectedData, err := snapshotCollector.Collect()
+		Expect(err).NotTo(HaveOccurred())
+
+		content, err := io.ReadAll(collectedData[1].Content())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(MatchJSON(`{"report_time": "2024-03-05 13:30:00 UTC", "monthly_service_reports": [], "yearly_service_report": []}`))
+	})
+
+	It("returns an error when listing fails", func() {
+		cfApiService.ListStub = nil
+		cfApiService.ListReturns(nil, errors.New("listing is hard"))
+
+		collectedData, err := snapshotCollector.Collect()
+		Expect(collectedData).To(BeEmpty())
+		Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf(CfApiRequestErrorFormat, CfAppsPath))))
+		Expect(err).To(MatchError(ContainSubstring("listing is hard")))
+	})
+
+	It("returns an error when a resource does not have the expected shape", func() {
+		listResponses[CfProcessesPath] = `[{"instances": "many"}]`
+
+		collectedData, err := snapshotCollector.Collect()
+		Expect(collectedData).To(BeEmpty())
+		Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf(UnmarshalResourcesErrorFormat, CfProcessesPath))))
+	})
+})
@@ -0,0 +1,137 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/pivotal-cf/telemetry-utils/collector_tar"
+	"github.com/pkg/errors"
+)
+
+const (
+	CSVFormat Format = "csv"
+
+	InvalidFormatErrorFormat   = "invalid export format %q, valid formats are: %s"
+	UnmarshalSourceErrorFormat = "error parsing %s"
+	NoTablesWarning            = "Warning: None of the collected data sets can be exported"
+)
+
+// Format is a file format tables are written in
+type Format string
+
+var formats = []Format{CSVFormat}
+
+// ParseFormat reads the --format flag, which defaults to CSV
+func ParseFormat(format string) (Format, error) {
+	if format == "" {
+		return CSVFormat, nil
+	}
+	var names []string
+	for _, f := range formats {
+		if strings.ToLower(format) == string(f) {
+			return f, nil
+		}
+		names = append(names, string(f))
+	}
+	return "", errors.New(fmt.Sprintf(InvalidFormatErrorFormat, format, strings.Join(names, ", ")))
+}
+
+// FoundationColumns start every table, so tables exported from several
+// foundations can be concatenated
+var FoundationColumns = []string{"foundation_nickname", "foundation_id", "env_type", "collected_at"}
+
+// Table is a tidy table of one kind of collected data, with a row per
+// observation
+type Table struct {
+	Name   string
+	Header []string
+	Rows   [][]string
+}
+
+// FileName is the name of the file the table is written to
+func (t Table) FileName(format Format) string {
+	return t.Name + "." + string(format)
+}
+
+// WriteCSV writes the header and rows of the table as CSV
+func (t Table) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(t.Header); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(t.Rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+type Exporter struct {
+	logger *log.Logger
+}
+
+func NewExporter(logger *log.Logger) *Exporter {
+	return &Exporter{logger: logger}
+}
+
+// Tables flattens the collected data sets into tables. A table is only
+// returned when the data set it is read from was collected, and has no rows
+// when the files it is read from were not, unless it requires them.
+func (e *Exporter) Tables(files map[string][]byte) ([]Table, error) {
+	var tables []Table
+	for _, source := range tableSources {
+		metadata, collected, err := readMetadata(files, source.dataSet)
+		if err != nil {
+			return nil, err
+		}
+		if !collected {
+			continue
+		}
+		if _, found := files[source.requiredFile]; source.requiredFile != "" && !found {
+			continue
+		}
+
+		rows, err := source.rows(e, files)
+		if err != nil {
+			return nil, err
+		}
+		foundation := []string{metadata.FoundationNickname, metadata.FoundationId, metadata.EnvType, metadata.CollectedAt}
+		table := Table{Name: source.name, Header: append(append([]string{}, FoundationColumns...), source.header...), Rows: [][]string{}}
+		for _, row := range rows {
+			table.Rows = append(table.Rows, append(append([]string{}, foundation...), row...))
+		}
+		tables = append(tables, table)
+	}
+
+	if len(tables) == 0 {
+		e.logger.Print(NoTablesWarning)
+	}
+	return tables, nil
+}
+
+func readMetadata(files map[string][]byte, dataSet string) (collector_tar.Metadata, bool, error) {
+	metadataPath := path.Join(dataSet, collector_tar.MetadataFileName)
+	contents, ok := files[metadataPath]
+	if !ok {
+		return collector_tar.Metadata{}, false, nil
+	}
+	var metadata collector_tar.Metadata
+	if err := json.Unmarshal(contents, &metadata); err != nil {
+		return collector_tar.Metadata{}, false, errors.Wrapf(err, UnmarshalSourceErrorFormat, metadataPath)
+	}
+	return metadata, true, nil
+}
+
+// readFile unmarshals a collected file into v, leaving v as it is when the
+// file was not collected
+func readFile(files map[string][]byte, filePath string, v interface{}) error {
+	contents, ok := files[filePath]
+	if !ok {
+		return nil
+	}
+	return errors.Wrapf(json.Unmarshal(contents, v), UnmarshalSourceErrorFormat, filePath)
+}
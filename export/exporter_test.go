@@ -0,0 +1,211 @@
+package export_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"path"
+
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/aqueduct-courier/capacity"
+	"github.com/pivotal-cf/aqueduct-courier/consumption"
+	. "github.com/pivotal-cf/aqueduct-courier/export"
+	"github.com/pivotal-cf/telemetry-utils/collector_tar"
+)
+
+var _ = Describe("Exporter", func() {
+	var (
+		logOutput  *gbytes.Buffer
+		exporter   *Exporter
+		foundation []string
+	)
+
+	metadata := func(dataSet string) (string, []byte) {
+		contents, err := json.Marshal(collector_tar.Metadata{
+			EnvType:            "production",
+			CollectedAt:        "2024-03-01T00:00:00Z",
+			FoundationId:       "foundation-guid",
+			FoundationNickname: "prod",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		return path.Join(dataSet, collector_tar.MetadataFileName), contents
+	}
+
+	withMetadata := func(files map[string][]byte, dataSets ...string) map[string][]byte {
+		for _, dataSet := range dataSets {
+			name, contents := metadata(dataSet)
+			files[name] = contents
+		}
+		return files
+	}
+
+	tableNamed := func(tables []Table, name string) Table {
+		for _, table := range tables {
+			if table.Name == name {
+				return table
+			}
+		}
+		Fail("no table named " + name)
+		return Table{}
+	}
+
+	BeforeEach(func() {
+		logOutput = gbytes.NewBuffer()
+		exporter = NewExporter(log.New(logOutput, "", 0))
+		foundation = []string{"prod", "foundation-guid", "production", "2024-03-01T00:00:00Z"}
+	})
+
+	Describe("ParseFormat", func() {
+		It("defaults to CSV", func() {
+			Expect(ParseFormat("")).To(Equal(CSVFormat))
+			Expect(ParseFormat("CSV")).To(Equal(CSVFormat))
+		})
+
+		It("rejects other formats", func() {
+			_, err := ParseFormat("xlsx")
+			Expect(err).To(MatchError(`invalid export format "xlsx", valid formats are: csv`))
+		})
+	})
+
+	It("flattens the Ops Manager data into products, jobs and certificates", func() {
+		tables, err := exporter.Tables(withMetadata(map[string][]byte{
+			DeployedProductsPath: []byte(`[
+				{"installation_name": "cf-0123", "guid": "cf-0123", "type": "cf", "product_version": "4.0.1"},
+				{"installation_name": "p-bosh", "guid": "p-bosh-0123", "type": "p-bosh", "product_version": "3.0.2"}
+			]`),
+			capacity.VmTypesPath: []byte(`{"vm_types": [{"name": "large", "cpu": 2, "ram": 8192, "ephemeral_disk": 16384}]}`),
+			"opsmanager/cf_resources": []byte(`{"resources": [
+				{"identifier": "router", "instances": 1, "instances_best_fit": 3, "instance_type_id": "large"},
+				{"identifier": "diego_cell", "instances": 3, "instance_type_id": "large", "persistent_disk_mb": "10240"}
+			]}`),
+			CertificatesPath: []byte(`{"certificates": [
+				{"product_guid": "cf-0123", "property_reference": ".properties.networking_poe_ssl_certs", "issuer": "CN=opsmgr", "valid_from": "2024-01-01T00:00:00Z", "valid_until": "2026-01-01T00:00:00Z"},
+				{"product_guid": "p-bosh-0123", "variable_path": "/p-bosh/nats_cert", "issuer": "CN=nats", "valid_from": "2024-01-02T00:00:00Z", "valid_until": "2025-01-02T00:00:00Z"}
+			]}`),
+			CertificateAuthoritiesPath: []byte(`{"certificate_authorities": [{"guid": "ca-guid", "issuer": "CN=root", "created_on": "2023-01-01", "expires_on": "2027-01-01", "active": true}]}`),
+			CredhubCertificatesPath:    []byte(`{"credhub_certificates": [{"name": "/services/tls", "issuer": "CN=services", "not_before": "2024-02-01T00:00:00Z", "not_after": "2025-02-01T00:00:00Z"}]}`),
+		}, collector_tar.OpsManagerCollectorDataSetId))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tables).To(HaveLen(3))
+
+		products := tableNamed(tables, ProductsTable)
+		Expect(products.Header).To(Equal(append(append([]string{}, FoundationColumns...), "product_type", "installation_name", "guid", "product_version")))
+		Expect(products.Rows).To(Equal([][]string{
+			append(append([]string{}, foundation...), "cf", "cf-0123", "cf-0123", "4.0.1"),
+			append(append([]string{}, foundation...), "p-bosh", "p-bosh", "p-bosh-0123", "3.0.2"),
+		}))
+
+		jobs := tableNamed(tables, JobsTable)
+		Expect(jobs.Header[len(FoundationColumns):]).To(Equal([]string{"product_type", "source", "job", "vm_type", "instances", "vcpu", "memory_mb", "ephemeral_disk_mb", "persistent_disk_mb", "supported_instances", "ha_risk"}))
+		Expect(jobs.Rows).To(Equal([][]string{
+			append(append([]string{}, foundation...), "cf", capacity.StagedSource, "diego_cell", "large", "3", "6", "24576", "49152", "30720", "", "false"),
+			append(append([]string{}, foundation...), "cf", capacity.StagedSource, "router", "large", "1", "2", "8192", "16384", "0", "3", "true"),
+		}))
+
+		certificates := tableNamed(tables, CertificatesTable)
+		Expect(certificates.Rows).To(Equal([][]string{
+			append(append([]string{}, foundation...), OpsManagerCertificateSource, "cf-0123", ".properties.networking_poe_ssl_certs", "CN=opsmgr", "2024-01-01T00:00:00Z", "2026-01-01T00:00:00Z"),
+			append(append([]string{}, foundation...), OpsManagerCertificateSource, "p-bosh-0123", "/p-bosh/nats_cert", "CN=nats", "2024-01-02T00:00:00Z", "2025-01-02T00:00:00Z"),
+			append(append([]string{}, foundation...), CertificateAuthoritySource, "", "ca-guid", "CN=root", "2023-01-01", "2027-01-01"),
+			append(append([]string{}, foundation...), CredhubCertificateSource, "", "/services/tls", "CN=services", "2024-02-01T00:00:00Z", "2025-02-01T00:00:00Z"),
+		}))
+	})
+
+	It("flattens core counts and usage by month", func() {
+		tables, err := exporter.Tables(withMetadata(map[string][]byte{
+			CoreCountsPath: []byte(`[{"TimeReported": "2024-02-01T00:00:00Z", "ProductIdentifier": "cf", "PhysicalCoreCount": 12, "VirtualCoreCount": 24}]`),
+			AppUsagePath: []byte(`{"report_time": "2024-03-01", "monthly_reports": [
+				{"month": 1, "year": 2024, "average_app_instances": 10.5, "maximum_app_instances": 12, "app_instance_hours": 7812}
+			], "yearly_reports": [{"year": 2024, "average_app_instances": 10.5}]}`),
+			ServiceUsagePath: []byte(`{"monthly_service_reports": [
+				{"service_name": "mysql", "service_guid": "mysql-guid", "usages": [{"month": 1, "year": 2024, "average_instances": 3, "maximum_instances": 4, "duration_in_hours": 2232}],
+				 "plans": [
+					{"service_plan_guid": "small", "usages": [{"month": 1, "year": 2024, "average_instances": 2, "maximum_instances": 3, "duration_in_hours": 1488}]},
+					{"service_plan_guid": "large", "usages": [{"month": 1, "year": 2024, "average_instances": 1, "maximum_instances": 1, "duration_in_hours": 744}]}
+				 ]},
+				{"service_name": "redis", "service_guid": "redis-guid", "usages": [{"month": 1, "year": 2024, "average_instances": 1, "maximum_instances": 1}]}
+			]}`),
+			TaskUsagePath: []byte(`{"monthly_reports": [{"month": 1, "year": 2024, "total_task_runs": 40, "maximum_concurrent_tasks": 3, "task_hours": 1.5}]}`),
+		}, collector_tar.CoreConsumptionCollectorDataSetId, collector_tar.UsageServiceCollectorDataSetId))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tables).To(HaveLen(4))
+
+		Expect(tableNamed(tables, CoreCountsTable).Rows).To(Equal([][]string{
+			append(append([]string{}, foundation...), "2024-02-01T00:00:00Z", "cf", "12", "24"),
+		}))
+		Expect(tableNamed(tables, AppUsageTable).Rows).To(Equal([][]string{
+			append(append([]string{}, foundation...), UsageServiceSource, "2024", "1", "10.5", "12", "7812"),
+		}))
+		Expect(tableNamed(tables, ServiceUsageTable).Rows).To(Equal([][]string{
+			append(append([]string{}, foundation...), UsageServiceSource, "mysql", "mysql-guid", "small", "2024", "1", "2", "3", "1488"),
+			append(append([]string{}, foundation...), UsageServiceSource, "mysql", "mysql-guid", "large", "2024", "1", "1", "1", "744"),
+			append(append([]string{}, foundation...), UsageServiceSource, "redis", "redis-guid", "", "2024", "1", "1", "1", ""),
+		}))
+		Expect(tableNamed(tables, TaskUsageTable).Rows).To(Equal([][]string{
+			append(append([]string{}, foundation...), "2024", "1", "40", "3", "1.5"),
+		}))
+	})
+
+	It("records usage read from the CF API and leaves out the task usage it has no snapshot of", func() {
+		contents, err := json.Marshal(collector_tar.Metadata{
+			EnvType:            "production",
+			CollectedAt:        "2024-03-01T00:00:00Z",
+			FoundationId:       "foundation-guid",
+			FoundationNickname: "prod",
+			FileDigests: []collector_tar.FileDigest{
+				{Name: collector_tar.AppUsageDataType, DataType: consumption.AppUsageSnapshotDataType},
+				{Name: collector_tar.ServiceUsageDataType, DataType: consumption.ServiceUsageSnapshotDataType},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		tables, err := exporter.Tables(map[string][]byte{
+			path.Join(collector_tar.UsageServiceCollectorDataSetId, collector_tar.MetadataFileName): contents,
+			AppUsagePath: []byte(`{"monthly_reports": [{"month": 3, "year": 2024, "average_app_instances": 4, "maximum_app_instances": 4}]}`),
+			ServiceUsagePath: []byte(`{"monthly_service_reports": [
+				{"service_name": "mysql", "service_guid": "mysql-guid", "usages": [{"month": 3, "year": 2024, "average_instances": 1, "maximum_instances": 1}]}
+			]}`),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tables).To(HaveLen(2))
+
+		Expect(tableNamed(tables, AppUsageTable).Rows).To(Equal([][]string{
+			append(append([]string{}, foundation...), CfApiSnapshotSource, "2024", "3", "4", "4", ""),
+		}))
+		Expect(tableNamed(tables, ServiceUsageTable).Rows).To(Equal([][]string{
+			append(append([]string{}, foundation...), CfApiSnapshotSource, "mysql", "mysql-guid", "", "2024", "3", "1", "1", ""),
+		}))
+	})
+
+	It("writes tables without rows when their files were not collected", func() {
+		tables, err := exporter.Tables(withMetadata(map[string][]byte{}, collector_tar.UsageServiceCollectorDataSetId))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tables).To(HaveLen(2))
+		for _, table := range tables {
+			Expect(table.Rows).To(BeEmpty())
+		}
+	})
+
+	It("warns when no data set can be exported", func() {
+		tables, err := exporter.Tables(withMetadata(map[string][]byte{}, "bosh_director"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tables).To(BeEmpty())
+		Expect(logOutput).To(gbytes.Say(NoTablesWarning))
+	})
+
+	It("fails when a file cannot be parsed", func() {
+		_, err := exporter.Tables(withMetadata(map[string][]byte{CoreCountsPath: []byte("not json")}, collector_tar.CoreConsumptionCollectorDataSetId))
+		Expect(err).To(MatchError(ContainSubstring("error parsing " + CoreCountsPath)))
+	})
+
+	It("writes tables as CSV", func() {
+		var buffer bytes.Buffer
+		table := Table{Name: "t", Header: []string{"name", "issuer"}, Rows: [][]string{{"cert", "CN=a, O=b"}}}
+		Expect(table.WriteCSV(&buffer)).To(Succeed())
+		Expect(buffer.String()).To(Equal("name,issuer\ncert,\"CN=a, O=b\"\n"))
+		Expect(table.FileName(CSVFormat)).To(Equal("t.csv"))
+	})
+})
@@ -0,0 +1,340 @@
+package export
+
+import (
+	"encoding/json"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pivotal-cf/aqueduct-courier/capacity"
+	"github.com/pivotal-cf/aqueduct-courier/consumption"
+	"github.com/pivotal-cf/aqueduct-courier/credhub"
+	"github.com/pivotal-cf/aqueduct-courier/opsmanager"
+	"github.com/pivotal-cf/telemetry-utils/collector_tar"
+)
+
+const (
+	ProductsTable     = "products"
+	JobsTable         = "jobs"
+	CertificatesTable = "certificates"
+	CoreCountsTable   = "core_counts"
+	AppUsageTable     = "app_usage"
+	ServiceUsageTable = "service_usage"
+	TaskUsageTable    = "task_usage"
+
+	OpsManagerCertificateSource = "ops_manager"
+	CertificateAuthoritySource  = "certificate_authority"
+	CredhubCertificateSource    = "credhub"
+
+	UsageServiceSource  = "usage_service"
+	CfApiSnapshotSource = "cf_api_snapshot"
+)
+
+var (
+	DeployedProductsPath       = opsManagerPath(collector_tar.DeployedProductsDataType)
+	CertificatesPath           = opsManagerPath(collector_tar.CertificatesDataType)
+	CertificateAuthoritiesPath = opsManagerPath(collector_tar.CertificateAuthoritiesDataType)
+	CredhubCertificatesPath    = path.Join(collector_tar.OpsManagerCollectorDataSetId, credhub.NewData(nil).Name())
+	CoreCountsPath             = path.Join(collector_tar.CoreConsumptionCollectorDataSetId, collector_tar.CoreCountsDataType)
+	AppUsagePath               = path.Join(collector_tar.UsageServiceCollectorDataSetId, collector_tar.AppUsageDataType)
+	ServiceUsagePath           = path.Join(collector_tar.UsageServiceCollectorDataSetId, collector_tar.ServiceUsageDataType)
+	TaskUsagePath              = path.Join(collector_tar.UsageServiceCollectorDataSetId, collector_tar.TaskUsageDataType)
+)
+
+// tableSource is a table, the data set it is read from and the columns
+// following the foundation columns. A table with a required file is left out
+// when that file was not collected.
+type tableSource struct {
+	name         string
+	dataSet      string
+	requiredFile string
+	header       []string
+	rows         func(e *Exporter, files map[string][]byte) ([][]string, error)
+}
+
+var tableSources = []tableSource{
+	{
+		name:    ProductsTable,
+		dataSet: collector_tar.OpsManagerCollectorDataSetId,
+		header:  []string{"product_type", "installation_name", "guid", "product_version"},
+		rows:    productRows,
+	},
+	{
+		name:    JobsTable,
+		dataSet: collector_tar.OpsManagerCollectorDataSetId,
+		header:  []string{"product_type", "source", "job", "vm_type", "instances", "vcpu", "memory_mb", "ephemeral_disk_mb", "persistent_disk_mb", "supported_instances", "ha_risk"},
+		rows:    jobRows,
+	},
+	{
+		name:    CertificatesTable,
+		dataSet: collector_tar.OpsManagerCollectorDataSetId,
+		header:  []string{"source", "product_guid", "name", "issuer", "valid_from", "valid_until"},
+		rows:    certificateRows,
+	},
+	{
+		name:    CoreCountsTable,
+		dataSet: collector_tar.CoreConsumptionCollectorDataSetId,
+		header:  []string{"time_reported", "product_identifier", "physical_core_count", "virtual_core_count"},
+		rows:    coreCountRows,
+	},
+	{
+		name:    AppUsageTable,
+		dataSet: collector_tar.UsageServiceCollectorDataSetId,
+		header:  []string{"source", "year", "month", "average_app_instances", "maximum_app_instances", "app_instance_hours"},
+		rows:    appUsageRows,
+	},
+	{
+		name:    ServiceUsageTable,
+		dataSet: collector_tar.UsageServiceCollectorDataSetId,
+		header:  []string{"source", "service_name", "service_guid", "service_plan_guid", "year", "month", "average_instances", "maximum_instances", "duration_in_hours"},
+		rows:    serviceUsageRows,
+	},
+	{
+		// usage read from the CF API has no task snapshot
+		name:         TaskUsageTable,
+		dataSet:      collector_tar.UsageServiceCollectorDataSetId,
+		requiredFile: TaskUsagePath,
+		header:       []string{"year", "month", "total_task_runs", "maximum_concurrent_tasks", "task_hours"},
+		rows:         taskUsageRows,
+	},
+}
+
+func opsManagerPath(dataType string) string {
+	return path.Join(collector_tar.OpsManagerCollectorDataSetId, opsmanager.NewData(nil, collector_tar.OpsManagerProductType, dataType).Name())
+}
+
+func productRows(_ *Exporter, files map[string][]byte) ([][]string, error) {
+	var products []struct {
+		Type             string `json:"type"`
+		InstallationName string `json:"installation_name"`
+		GUID             string `json:"guid"`
+		ProductVersion   string `json:"product_version"`
+	}
+	if err := readFile(files, DeployedProductsPath, &products); err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for _, p := range products {
+		rows = append(rows, []string{p.Type, p.InstallationName, p.GUID, p.ProductVersion})
+	}
+	return rows, nil
+}
+
+func jobRows(e *Exporter, files map[string][]byte) ([][]string, error) {
+	model, err := capacity.NewAnalyzer(e.logger).Model(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for _, product := range model.Products {
+		for _, job := range product.Jobs {
+			rows = append(rows, []string{
+				product.Product,
+				product.Source,
+				job.Name,
+				job.VMType,
+				strconv.Itoa(job.Instances),
+				strconv.Itoa(job.VCPU),
+				strconv.Itoa(job.MemoryMB),
+				strconv.Itoa(job.EphemeralDiskMB),
+				strconv.Itoa(job.PersistentDiskMB),
+				optionalInt(job.SupportedInstances),
+				strconv.FormatBool(job.HARisk),
+			})
+		}
+	}
+	return rows, nil
+}
+
+func certificateRows(_ *Exporter, files map[string][]byte) ([][]string, error) {
+	var rows [][]string
+
+	var certificates struct {
+		Certificates []struct {
+			ProductGUID       string `json:"product_guid"`
+			PropertyReference string `json:"property_reference"`
+			VariablePath      string `json:"variable_path"`
+			Issuer            string `json:"issuer"`
+			ValidFrom         string `json:"valid_from"`
+			ValidUntil        string `json:"valid_until"`
+		} `json:"certificates"`
+	}
+	if err := readFile(files, CertificatesPath, &certificates); err != nil {
+		return nil, err
+	}
+	for _, c := range certificates.Certificates {
+		name := c.PropertyReference
+		if name == "" {
+			name = c.VariablePath
+		}
+		rows = append(rows, []string{OpsManagerCertificateSource, c.ProductGUID, name, c.Issuer, c.ValidFrom, c.ValidUntil})
+	}
+
+	var authorities struct {
+		CertificateAuthorities []struct {
+			GUID      string `json:"guid"`
+			Issuer    string `json:"issuer"`
+			CreatedOn string `json:"created_on"`
+			ExpiresOn string `json:"expires_on"`
+		} `json:"certificate_authorities"`
+	}
+	if err := readFile(files, CertificateAuthoritiesPath, &authorities); err != nil {
+		return nil, err
+	}
+	for _, ca := range authorities.CertificateAuthorities {
+		rows = append(rows, []string{CertificateAuthoritySource, "", ca.GUID, ca.Issuer, ca.CreatedOn, ca.ExpiresOn})
+	}
+
+	var credhubCertificates struct {
+		CredhubCertificates []struct {
+			Name      string `json:"name"`
+			Issuer    string `json:"issuer"`
+			NotBefore string `json:"not_before"`
+			NotAfter  string `json:"not_after"`
+		} `json:"credhub_certificates"`
+	}
+	if err := readFile(files, CredhubCertificatesPath, &credhubCertificates); err != nil {
+		return nil, err
+	}
+	for _, c := range credhubCertificates.CredhubCertificates {
+		rows = append(rows, []string{CredhubCertificateSource, "", c.Name, c.Issuer, c.NotBefore, c.NotAfter})
+	}
+	return rows, nil
+}
+
+func coreCountRows(_ *Exporter, files map[string][]byte) ([][]string, error) {
+	var counts []struct {
+		TimeReported      string
+		ProductIdentifier string
+		PhysicalCoreCount json.Number
+		VirtualCoreCount  json.Number
+	}
+	if err := readFile(files, CoreCountsPath, &counts); err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for _, c := range counts {
+		rows = append(rows, []string{c.TimeReported, c.ProductIdentifier, c.PhysicalCoreCount.String(), c.VirtualCoreCount.String()})
+	}
+	return rows, nil
+}
+
+func appUsageRows(_ *Exporter, files map[string][]byte) ([][]string, error) {
+	var report struct {
+		MonthlyReports []struct {
+			Year                json.Number `json:"year"`
+			Month               json.Number `json:"month"`
+			AverageAppInstances json.Number `json:"average_app_instances"`
+			MaximumAppInstances json.Number `json:"maximum_app_instances"`
+			AppInstanceHours    json.Number `json:"app_instance_hours"`
+		} `json:"monthly_reports"`
+	}
+	if err := readFile(files, AppUsagePath, &report); err != nil {
+		return nil, err
+	}
+	source, err := usageSource(files, collector_tar.AppUsageDataType)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for _, r := range report.MonthlyReports {
+		rows = append(rows, []string{source, r.Year.String(), r.Month.String(), r.AverageAppInstances.String(), r.MaximumAppInstances.String(), r.AppInstanceHours.String()})
+	}
+	return rows, nil
+}
+
+type serviceUsage struct {
+	Year             json.Number `json:"year"`
+	Month            json.Number `json:"month"`
+	AverageInstances json.Number `json:"average_instances"`
+	MaximumInstances json.Number `json:"maximum_instances"`
+	DurationInHours  json.Number `json:"duration_in_hours"`
+}
+
+// serviceUsageRows has a row for each plan of a service and month, or for
+// the service when the report has no plans, so usage is not counted twice
+func serviceUsageRows(_ *Exporter, files map[string][]byte) ([][]string, error) {
+	var report struct {
+		MonthlyServiceReports []struct {
+			ServiceName string         `json:"service_name"`
+			ServiceGUID string         `json:"service_guid"`
+			Usages      []serviceUsage `json:"usages"`
+			Plans       []struct {
+				ServicePlanGUID string         `json:"service_plan_guid"`
+				Usages          []serviceUsage `json:"usages"`
+			} `json:"plans"`
+		} `json:"monthly_service_reports"`
+	}
+	if err := readFile(files, ServiceUsagePath, &report); err != nil {
+		return nil, err
+	}
+	source, err := usageSource(files, collector_tar.ServiceUsageDataType)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	row := func(serviceName, serviceGUID, planGUID string, u serviceUsage) []string {
+		return []string{source, serviceName, serviceGUID, planGUID, u.Year.String(), u.Month.String(), u.AverageInstances.String(), u.MaximumInstances.String(), u.DurationInHours.String()}
+	}
+	for _, service := range report.MonthlyServiceReports {
+		if len(service.Plans) == 0 {
+			for _, u := range service.Usages {
+				rows = append(rows, row(service.ServiceName, service.ServiceGUID, "", u))
+			}
+		}
+		for _, plan := range service.Plans {
+			for _, u := range plan.Usages {
+				rows = append(rows, row(service.ServiceName, service.ServiceGUID, plan.ServicePlanGUID, u))
+			}
+		}
+	}
+	return rows, nil
+}
+
+func taskUsageRows(_ *Exporter, files map[string][]byte) ([][]string, error) {
+	var report struct {
+		MonthlyReports []struct {
+			Year                   json.Number `json:"year"`
+			Month                  json.Number `json:"month"`
+			TotalTaskRuns          json.Number `json:"total_task_runs"`
+			MaximumConcurrentTasks json.Number `json:"maximum_concurrent_tasks"`
+			TaskHours              json.Number `json:"task_hours"`
+		} `json:"monthly_reports"`
+	}
+	if err := readFile(files, TaskUsagePath, &report); err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for _, r := range report.MonthlyReports {
+		rows = append(rows, []string{r.Year.String(), r.Month.String(), r.TotalTaskRuns.String(), r.MaximumConcurrentTasks.String(), r.TaskHours.String()})
+	}
+	return rows, nil
+}
+
+// usageSource is where a usage file was read from, which the data type of
+// its file digest records
+func usageSource(files map[string][]byte, name string) (string, error) {
+	metadata, _, err := readMetadata(files, collector_tar.UsageServiceCollectorDataSetId)
+	if err != nil {
+		return "", err
+	}
+	for _, digest := range metadata.FileDigests {
+		if digest.Name == name && strings.HasSuffix(digest.DataType, consumption.SnapshotDataTypeSuffix) {
+			return CfApiSnapshotSource, nil
+		}
+	}
+	return UsageServiceSource, nil
+}
+
+func optionalInt(i int) string {
+	if i == 0 {
+		return ""
+	}
+	return strconv.Itoa(i)
+}
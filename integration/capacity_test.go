@@ -0,0 +1,88 @@
+package integration
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/pivotal-cf/aqueduct-courier/capacity"
+	"github.com/pivotal-cf/aqueduct-courier/cmd"
+	"github.com/pivotal-cf/telemetry-utils/tar"
+)
+
+var _ = Describe("Capacity", func() {
+	var (
+		tempDir     string
+		tarFilePath string
+	)
+
+	writeDataTarFile := func(files map[string]string) {
+		tarFile, err := os.Create(tarFilePath)
+		Expect(err).NotTo(HaveOccurred())
+		tarWriter := tar.NewTarWriter(tarFile)
+		for name, contents := range files {
+			Expect(tarWriter.AddFile([]byte(contents), name)).To(Succeed())
+		}
+		Expect(tarWriter.Close()).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = os.MkdirTemp("", "")
+		Expect(err).NotTo(HaveOccurred())
+		tarFilePath = filepath.Join(tempDir, "data.tar")
+
+		writeDataTarFile(map[string]string{
+			capacity.VmTypesPath: `{"vm_types": [
+				{"name": "micro", "cpu": 1, "ram": 1024, "ephemeral_disk": 8192},
+				{"name": "large", "cpu": 2, "ram": 8192, "ephemeral_disk": 16384}
+			]}`,
+			"opsmanager/cf_resources": `{"resources": [
+				{"identifier": "router", "instances": 1, "instances_best_fit": 3, "instance_type_id": "micro"},
+				{"identifier": "diego_cell", "instances": 3, "instances_best_fit": 3, "instance_type_id": "large", "persistent_disk_mb": "10240"}
+			]}`,
+		})
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tempDir)).To(Succeed())
+	})
+
+	runCapacity := func(args ...string) *gexec.Session {
+		command := exec.Command(aqueductBinaryPath, append([]string{"capacity"}, args...)...)
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		return session
+	}
+
+	It("shows the capacity of each product and job and the HA risks", func() {
+		session := runCapacity("--"+cmd.DataTarFilePathFlag, tarFilePath)
+		Eventually(session).Should(gexec.Exit(0))
+
+		Expect(session.Out).To(gbytes.Say(`PRODUCT\s+JOB\s+INSTANCES\s+VM TYPE\s+VCPU\s+MEMORY \(MB\)\s+EPHEMERAL DISK \(MB\)\s+PERSISTENT DISK \(MB\)`))
+		Expect(session.Out).To(gbytes.Say(`cf\s+diego_cell\s+3\s+large\s+6\s+24576\s+49152\s+30720`))
+		Expect(session.Out).To(gbytes.Say(`cf\s+router\s+1\s+micro\s+1\s+1024\s+8192\s+0`))
+		Expect(session.Out).To(gbytes.Say(`cf\s+total\s+4\s+7\s+25600\s+57344\s+30720`))
+		Expect(session.Out).To(gbytes.Say(`TOTAL\s+4\s+7\s+25600\s+57344\s+30720`))
+		Expect(session.Out).To(gbytes.Say(`Jobs running a single instance where the product supports more:\n  cf router \(1 of up to 3 instances\)`))
+	})
+
+	It("reports when the data has no product resources", func() {
+		writeDataTarFile(map[string]string{capacity.VmTypesPath: `{"vm_types": []}`})
+
+		session := runCapacity("--"+cmd.DataTarFilePathFlag, tarFilePath)
+		Eventually(session).Should(gexec.Exit(0))
+		Expect(session.Out).To(gbytes.Say(capacity.NoResourcesWarning))
+		Expect(session.Out).To(gbytes.Say(cmd.NoCapacityMessage))
+	})
+
+	It("requires the data file", func() {
+		session := runCapacity()
+		Eventually(session).Should(gexec.Exit(1))
+		Expect(session.Err).To(gbytes.Say("Missing required flags: --path"))
+	})
+})
@@ -0,0 +1,306 @@
+package integration
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/elazarl/goproxy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/aqueduct-courier/cmd"
+	"github.com/pivotal-cf/aqueduct-courier/coreconsumption"
+	"github.com/pivotal-cf/aqueduct-courier/operations"
+	"github.com/pivotal-cf/aqueduct-courier/opsmanager"
+	"github.com/pivotal-cf/aqueduct-courier/preflight"
+)
+
+var _ = Describe("Check", func() {
+	var (
+		opsManagerServer *ghttp.Server
+		flagValues       map[string]string
+	)
+
+	BeforeEach(func() {
+		opsManagerServer = setupOpsManagerServer()
+		opsManagerServer.RouteToHandler(http.MethodGet, "/uaa/info", ghttp.RespondWith(http.StatusOK, "{}"))
+
+		flagValues = map[string]string{
+			cmd.OpsManagerURLFlag:          opsManagerServer.URL(),
+			cmd.OpsManagerClientIdFlag:     "some-client-id",
+			cmd.OpsManagerClientSecretFlag: "some-client-secret",
+			cmd.SkipTlsVerifyFlag:          "true",
+		}
+	})
+
+	AfterEach(func() {
+		opsManagerServer.Close()
+	})
+
+	runCheck := func(flagValues map[string]string) *gexec.Session {
+		command := exec.Command(aqueductBinaryPath, "check")
+		for k, v := range flagValues {
+			command.Args = append(command.Args, fmt.Sprintf("--%s=%s", k, v))
+		}
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		return session
+	}
+
+	It("passes every check against a reachable Ops Manager", func() {
+		opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/deployed/products", ghttp.RespondWith(http.StatusOK, `[
+			{"installation_name": "p-bosh-guid", "guid": "p-bosh-guid", "type": "p-bosh"},
+			{"installation_name": "cf-guid", "guid": "cf-guid", "type": "cf"}
+		]`))
+		opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/products/cf-guid/resources", ghttp.RespondWith(http.StatusOK, "{}"))
+		opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/products/cf-guid/properties", ghttp.RespondWith(http.StatusOK, "{}"))
+		opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/products/cf-guid/errands", ghttp.RespondWith(http.StatusOK, "{}"))
+
+		session := runCheck(flagValues)
+		Eventually(session).Should(gexec.Exit(0))
+
+		Expect(session.Out).To(gbytes.Say(`CHECK\s+TARGET\s+RESULT`))
+		Expect(session.Out).To(gbytes.Say(`Ops Manager DNS\s+127.0.0.1\s+PASS`))
+		Expect(session.Out).To(gbytes.Say(`Ops Manager TLS\s+.*PASS`))
+		Expect(session.Out).To(gbytes.Say(`Ops Manager clock skew\s+.*PASS`))
+		Expect(session.Out).To(gbytes.Say(`Ops Manager UAA token\s+.*PASS`))
+		Expect(session.Out).To(gbytes.Say(`Ops Manager /api/v0/installations\s+.*PASS`))
+		Expect(session.Out).To(gbytes.Say(`Ops Manager /api/v0/staged/products/\{guid\}/properties\s+.*PASS`))
+		Expect(session.Out).To(gbytes.Say(`passed, 0 failed, 0 skipped`))
+		Expect(string(session.Out.Contents())).NotTo(ContainSubstring(coreconsumption.CoreCountsAPI))
+	})
+
+	It("makes the TLS handshake through the proxy when one is set", func() {
+		omURL, err := url.Parse(opsManagerServer.URL())
+		Expect(err).NotTo(HaveOccurred())
+		var connectedHosts []string
+		proxy := goproxy.NewProxyHttpServer()
+		proxy.OnRequest().HandleConnectFunc(func(host string, ctx *goproxy.ProxyCtx) (*goproxy.ConnectAction, string) {
+			connectedHosts = append(connectedHosts, host)
+			return goproxy.OkConnect, omURL.Host
+		})
+		proxyServer := httptest.NewServer(proxy)
+		defer proxyServer.Close()
+
+		flagValues[cmd.OpsManagerURLFlag] = "https://opsman.example.com"
+		command := exec.Command(aqueductBinaryPath, "check")
+		for k, v := range flagValues {
+			command.Args = append(command.Args, fmt.Sprintf("--%s=%s", k, v))
+		}
+		command.Env = append(os.Environ(), "HTTPS_PROXY="+proxyServer.URL)
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit(0))
+
+		proxyAddress := strings.TrimPrefix(proxyServer.URL, "http://")
+		Expect(session.Out).To(gbytes.Say(`Ops Manager TCP\s+` + regexp.QuoteMeta(proxyAddress) + `\s+PASS`))
+		Expect(session.Out).To(gbytes.Say(`Ops Manager TLS\s+` + regexp.QuoteMeta(fmt.Sprintf(preflight.ProxyTargetFormat, "opsman.example.com:443", proxyAddress)) + `\s+PASS`))
+		Expect(connectedHosts).To(ContainElement("opsman.example.com:443"))
+	})
+
+	It("fails the TLS check when the server behind the proxy does not complete a handshake", func() {
+		plainServer := httptest.NewServer(http.NotFoundHandler())
+		defer plainServer.Close()
+		proxy := goproxy.NewProxyHttpServer()
+		proxy.OnRequest().HandleConnectFunc(func(host string, ctx *goproxy.ProxyCtx) (*goproxy.ConnectAction, string) {
+			return goproxy.OkConnect, strings.TrimPrefix(plainServer.URL, "http://")
+		})
+		proxyServer := httptest.NewServer(proxy)
+		defer proxyServer.Close()
+
+		flagValues[cmd.OpsManagerURLFlag] = "https://opsman.example.com"
+		command := exec.Command(aqueductBinaryPath, "check")
+		for k, v := range flagValues {
+			command.Args = append(command.Args, fmt.Sprintf("--%s=%s", k, v))
+		}
+		command.Env = append(os.Environ(), "HTTPS_PROXY="+proxyServer.URL)
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, 15).Should(gexec.Exit(1))
+
+		Expect(session.Out).To(gbytes.Say(`Ops Manager TLS\s+.*FAIL`))
+		Expect(session.Out).To(gbytes.Say(`hint: ` + preflight.TLSRemediation))
+	})
+
+	It("reports every failing endpoint with a remediation hint", func() {
+		opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/diagnostic_report", ghttp.RespondWith(http.StatusForbidden, ""))
+		opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/vm_types", ghttp.RespondWith(http.StatusNotFound, ""))
+
+		session := runCheck(flagValues)
+		Eventually(session).Should(gexec.Exit(1))
+
+		Expect(session.Out).To(gbytes.Say(`Ops Manager /api/v0/vm_types\s+.*FAIL`))
+		Expect(session.Out).To(gbytes.Say(`Ops Manager /api/v0/diagnostic_report\s+.*SKIP`))
+		Expect(session.Out).To(gbytes.Say("FAILURES"))
+		Expect(session.Out).To(gbytes.Say(`hint: ` + preflight.NotFoundRemediation))
+		Expect(session.Out).To(gbytes.Say("SKIPPED"))
+		Expect(session.Out).To(gbytes.Say(`reason: ` + opsmanager.SkippedForbiddenReason))
+		Expect(session.Out).To(gbytes.Say(`1 failed, 1 skipped`))
+		Expect(session.Err).To(gbytes.Say(cmd.ChecksFailedMessage))
+		Expect(session.Err).NotTo(gbytes.Say("USAGE EXAMPLES"))
+	})
+
+	It("skips the endpoints the role cannot read without requesting them", func() {
+		claims := base64.RawURLEncoding.EncodeToString([]byte(`{"scope": ["opsman.restricted_view"]}`))
+		opsManagerServer.RouteToHandler(http.MethodPost, "/uaa/oauth/token", func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{
+				"access_token": "e30.` + claims + `.signature",
+				"token_type": "bearer",
+				"expires_in": 3600
+			}`))
+		})
+
+		session := runCheck(flagValues)
+		Eventually(session).Should(gexec.Exit(0))
+
+		Expect(session.Out).To(gbytes.Say(`Ops Manager /api/v0/vm_types\s+.*PASS`))
+		Expect(session.Out).To(gbytes.Say(`Ops Manager /api/v0/diagnostic_report\s+.*SKIP`))
+		Expect(session.Out).To(gbytes.Say(`Ops Manager /api/v0/settings/syslog\s+.*SKIP`))
+		Expect(session.Out).To(gbytes.Say(`reason: requires the full_view role, authenticated with the restricted_view role`))
+		for _, request := range opsManagerServer.ReceivedRequests() {
+			Expect(request.URL.Path).NotTo(Equal("/api/v0/diagnostic_report"))
+		}
+	})
+
+	It("skips the endpoints the Ops Manager version does not serve", func() {
+		opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/info", ghttp.RespondWith(http.StatusOK, `{"info": {"version": "2.2.5-build.1"}}`))
+
+		session := runCheck(flagValues)
+		Eventually(session).Should(gexec.Exit(0))
+
+		Expect(session.Out).To(gbytes.Say(`Ops Manager /api/v0/deployed/certificates\s+.*SKIP`))
+		Expect(session.Out).To(gbytes.Say(`reason: unsupported on OM 2.2.5, requires 2.3.0`))
+	})
+
+	It("only checks the endpoints of the selected datasets", func() {
+		flagValues[cmd.DatasetsFlag] = operations.CoreConsumptionDataset
+
+		session := runCheck(flagValues)
+		Eventually(session).Should(gexec.Exit(0))
+
+		Expect(session.Out).To(gbytes.Say(`Ops Manager /api/v0/deployed/products\s+.*PASS`))
+		Expect(session.Out).To(gbytes.Say(`Ops Manager /api/v0/download_core_consumption\s+.*PASS`))
+		Expect(string(session.Out.Contents())).NotTo(ContainSubstring("/api/v0/staged/pending_changes"))
+		Expect(string(session.Out.Contents())).NotTo(ContainSubstring("/api/v0/diagnostic_report"))
+	})
+
+	It("skips the endpoints when no token can be retrieved", func() {
+		opsManagerServer.RouteToHandler(http.MethodPost, "/uaa/oauth/token", ghttp.RespondWith(http.StatusUnauthorized, `{"error": "unauthorized"}`))
+
+		session := runCheck(flagValues)
+		Eventually(session).Should(gexec.Exit(1))
+
+		Expect(session.Out).To(gbytes.Say(`Ops Manager UAA token\s+.*FAIL`))
+		Expect(session.Out).To(gbytes.Say(`Ops Manager /api/v0/staged/pending_changes\s+.*SKIP`))
+		Expect(session.Out).To(gbytes.Say(`hint: ` + preflight.TokenRemediation))
+	})
+
+	It("checks the usage service and credhub when they are configured", func() {
+		uaaService, cfService, usageService := setupUsageService("")
+		defer uaaService.Close()
+		defer cfService.Close()
+		defer usageService.Close()
+
+		credhubServer := setupCredHubServer()
+		defer credhubServer.Close()
+		opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/deployed/director/credentials/bosh_commandline_credentials", ghttp.RespondWith(http.StatusOK,
+			`{ "credential": "BOSH_CLIENT=best_client BOSH_CLIENT_SECRET=best_secret BOSH_CA_CERT=/cool/path BOSH_ENVIRONMENT=127.0.0.1 bosh "}`,
+		))
+
+		flagValues[cmd.CollectFromCredhubFlag] = "true"
+		flagValues[cmd.CfApiURLFlag] = cfService.URL()
+		flagValues[cmd.UsageServiceURLFlag] = usageService.URL()
+		flagValues[cmd.UsageServiceClientIDFlag] = "best-usage-service-client-id"
+		flagValues[cmd.UsageServiceClientSecretFlag] = "best-usage-service-client-secret"
+		flagValues[cmd.UsageServiceSkipTlsVerifyFlag] = "true"
+
+		session := runCheck(flagValues)
+		Eventually(session).Should(gexec.Exit(0))
+
+		Expect(session.Out).To(gbytes.Say(`CredHub /api/v1/certificates\s+.*PASS`))
+		Expect(session.Out).To(gbytes.Say(`CF API UAA discovery\s+.*PASS`))
+		Expect(session.Out).To(gbytes.Say(`Usage Service UAA token\s+.*PASS`))
+		Expect(session.Out).To(gbytes.Say(`Usage Service /system_report/app_usages\s+.*PASS`))
+		Expect(session.Out).To(gbytes.Say(`Usage Service /system_report/task_usages\s+.*PASS`))
+		Expect(session.Out).To(gbytes.Say(`0 failed`))
+	})
+
+	It("checks the CF API endpoints read by the platform inventory without the usage service", func() {
+		uaaService, cfService, usageService := setupUsageService("")
+		defer uaaService.Close()
+		defer cfService.Close()
+		defer usageService.Close()
+		setupCfInventoryHandlers(cfService)
+
+		flagValues[cmd.CollectCfInventoryFlag] = "true"
+		flagValues[cmd.CfApiURLFlag] = cfService.URL()
+		flagValues[cmd.UsageServiceClientIDFlag] = "best-usage-service-client-id"
+		flagValues[cmd.UsageServiceClientSecretFlag] = "best-usage-service-client-secret"
+		flagValues[cmd.UsageServiceSkipTlsVerifyFlag] = "true"
+
+		session := runCheck(flagValues)
+		Eventually(session).Should(gexec.Exit(0))
+
+		Expect(session.Out).To(gbytes.Say(`CF API UAA discovery\s+.*PASS`))
+		Expect(session.Out).To(gbytes.Say(`CF API /v3/organizations\s+.*PASS`))
+		Expect(session.Out).To(gbytes.Say(`CF API /v3/domains\s+.*PASS`))
+		Expect(session.Out).NotTo(gbytes.Say(`/system_report/app_usages`))
+		Expect(session.Out).To(gbytes.Say(`0 failed`))
+	})
+
+	It("checks the bosh director when it is configured", func() {
+		directorServer := setupBoshDirectorServer()
+		defer directorServer.Close()
+		opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/deployed/director/credentials/bosh_commandline_credentials", ghttp.RespondWith(http.StatusOK,
+			`{ "credential": "BOSH_CLIENT=best_client BOSH_CLIENT_SECRET=best_secret BOSH_CA_CERT=/cool/path BOSH_ENVIRONMENT=127.0.0.1 bosh "}`,
+		))
+
+		flagValues[cmd.CollectFromBoshFlag] = "true"
+
+		session := runCheck(flagValues)
+		Eventually(session).Should(gexec.Exit(0))
+
+		Expect(session.Out).To(gbytes.Say(`Ops Manager /api/v0/deployed/director/credentials/bosh_commandline_credentials\s+.*PASS`))
+		Expect(session.Out).To(gbytes.Say(`BOSH director /deployments\s+.*PASS`))
+		Expect(session.Out).To(gbytes.Say(`0 failed`))
+	})
+
+	It("reads the same config file as collect", func() {
+		configDirPath, err := os.MkdirTemp("", "")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(configDirPath)
+
+		configFile := filepath.Join(configDirPath, "config.yml")
+		Expect(os.WriteFile(configFile, []byte(fmt.Sprintf(`---
+url: %s
+username: some-username
+password: some-password
+insecure-skip-tls-verify: true
+env-type: production
+output-dir: /not/written/to
+`, opsManagerServer.URL())), 0755)).To(Succeed())
+
+		session := runCheck(map[string]string{cmd.ConfigFlag: configFile})
+		Eventually(session).Should(gexec.Exit(0))
+		Expect(session.Out).To(gbytes.Say(`0 failed`))
+	})
+
+	It("requires ops manager credentials", func() {
+		delete(flagValues, cmd.OpsManagerClientSecretFlag)
+
+		session := runCheck(flagValues)
+		Eventually(session).Should(gexec.Exit(1))
+		Expect(session.Err).To(gbytes.Say(cmd.InvalidAuthConfigurationMessage))
+	})
+})
@@ -1,8 +1,10 @@
 package integration
 
 import (
+	"crypto/sha256"
 	"crypto/tls"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -12,11 +14,15 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/pivotal-cf/om/api"
 
+	"github.com/pivotal-cf/aqueduct-courier/bosh"
+	"github.com/pivotal-cf/aqueduct-courier/capacity"
 	"github.com/pivotal-cf/aqueduct-courier/cf"
+	"github.com/pivotal-cf/aqueduct-courier/cfinventory"
 
 	"github.com/elazarl/goproxy"
 	"github.com/joyvuu-dave/archiver/v3"
@@ -27,7 +33,11 @@ import (
 	"github.com/onsi/gomega/gexec"
 	"github.com/onsi/gomega/ghttp"
 	"github.com/pivotal-cf/aqueduct-courier/cmd"
+	"github.com/pivotal-cf/aqueduct-courier/consumption"
+	"github.com/pivotal-cf/aqueduct-courier/lifecycle"
+	"github.com/pivotal-cf/aqueduct-courier/lint"
 	"github.com/pivotal-cf/aqueduct-courier/operations"
+	"github.com/pivotal-cf/aqueduct-courier/opsmanager"
 	"github.com/pivotal-cf/telemetry-utils/collector_tar"
 )
 
@@ -443,6 +453,132 @@ var _ = Describe("Collect", func() {
 		})
 	})
 
+	Context("with a restricted ops manager role", func() {
+		BeforeEach(func() {
+			claims := base64.RawURLEncoding.EncodeToString([]byte(`{"scope": ["opsman.restricted_view"]}`))
+			opsManagerServer.RouteToHandler(http.MethodPost, "/uaa/oauth/token", func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{
+					"access_token": "e30.` + claims + `.signature",
+					"token_type": "bearer",
+					"expires_in": 3600
+				}`))
+			})
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/diagnostic_report", func(w http.ResponseWriter, req *http.Request) {
+				Fail("the diagnostic report should not be requested with the restricted_view role")
+			})
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/deployed/certificates", ghttp.RespondWith(http.StatusForbidden, ""))
+		})
+
+		It("skips the datasets the role cannot read and records them", func() {
+			defaultEnvVars[cmd.WithCredhubInfoKey] = "true"
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			Expect(session.Out).To(gbytes.Say("Authenticated with the Ops Manager restricted_view role"))
+			Expect(session.Out).To(gbytes.Say("Warning: Skipping p-bosh_certificates: requires the full_view role"))
+			Expect(session.Out).To(gbytes.Say("Warning: Skipping ops_manager_diagnostic_report: requires the full_view role"))
+			Expect(session.Out).To(gbytes.Say("Warning: Skipping ops_manager_certificates: Ops Manager returned 403 Forbidden"))
+
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			assertValidOutput(tarFilePath, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_collection_details", "development")
+
+			tmpDir, err := os.MkdirTemp("", "")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(tmpDir)
+			Expect((&archiver.Tar{}).Unarchive(tarFilePath, tmpDir)).To(Succeed())
+
+			Expect(filepath.Join(tmpDir, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_diagnostic_report")).NotTo(BeAnExistingFile())
+			details, err := os.ReadFile(filepath.Join(tmpDir, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_collection_details"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(details).To(MatchJSON(`{
+				"role": "restricted_view",
+				"ops_manager_version": "3.0.10-build.1",
+				"skipped_datasets": [
+					{"name": "p-bosh_certificates", "required_role": "full_view", "reason": "requires the full_view role, authenticated with the restricted_view role"},
+					{"name": "ops_manager_diagnostic_report", "required_role": "full_view", "reason": "requires the full_view role, authenticated with the restricted_view role"},
+					{"name": "ops_manager_settings", "required_role": "full_view", "reason": "requires the full_view role, authenticated with the restricted_view role"},
+					{"name": "ops_manager_certificates", "required_role": "restricted_view", "reason": "Ops Manager returned 403 Forbidden"}
+				]
+			}`))
+		})
+	})
+
+	Context("with an Ops Manager version older than some endpoints", func() {
+		BeforeEach(func() {
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/info", ghttp.RespondWith(http.StatusOK, `{"info": {"version": "2.2.5-build.12"}}`))
+			for _, unsupportedPath := range []string{"/api/v0/deployed/certificates", "/api/v0/download_core_consumption"} {
+				opsManagerServer.RouteToHandler(http.MethodGet, unsupportedPath, func(w http.ResponseWriter, req *http.Request) {
+					Fail(req.URL.Path + " should not be requested from Ops Manager 2.2")
+				})
+			}
+		})
+
+		It("skips the unsupported endpoints and records the version", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			command.Args = append(command.Args, "--"+cmd.DatasetsFlag, "opsmanager,stemcell_associations")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			Expect(session.Out).To(gbytes.Say("Ops Manager version 2.2.5-build.12"))
+			Expect(session.Out).To(gbytes.Say("Warning: ops_manager_certificates skipped, unsupported on OM 2.2.5"))
+
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			tmpDir, err := os.MkdirTemp("", "")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(tmpDir)
+			Expect((&archiver.Tar{}).Unarchive(tarFilePath, tmpDir)).To(Succeed())
+
+			details, err := os.ReadFile(filepath.Join(tmpDir, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_collection_details"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(details).To(MatchJSON(`{
+				"role": "unknown",
+				"ops_manager_version": "2.2.5-build.12",
+				"skipped_datasets": [
+					{"name": "ops_manager_certificates", "required_role": "restricted_view", "reason": "unsupported on OM 2.2.5, requires 2.3.0"},
+					{"name": "ops_manager_stemcell_associations", "required_role": "restricted_view", "reason": "unsupported on OM 2.2.5, requires 2.6.0"}
+				]
+			}`))
+		})
+
+		It("skips core consumption when collecting operational data", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			command.Args = append(command.Args, "--"+cmd.OperationalDataOnlyFlag)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			Expect(session.Out).To(gbytes.Say("Warning: core_counts skipped, unsupported on OM 2.2.5"))
+		})
+	})
+
+	Context("with an Ops Manager version that cannot be read", func() {
+		BeforeEach(func() {
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/info", ghttp.RespondWith(http.StatusInternalServerError, ""))
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/download_core_consumption", ghttp.RespondWith(http.StatusNotFound, ""))
+		})
+
+		It("skips the core counts when the Core Counting API is missing", func() {
+			defaultEnvVars[cmd.DatasetsKey] = "opsmanager,core_consumption"
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Err).To(gbytes.Say("Warning: core counts skipped, the Ops Manager version is unknown: " + operations.CoreCountsCollectFailureMessage))
+
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			assertValidOutput(tarFilePath, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_deployed_products", "development")
+			tmpDir, err := os.MkdirTemp("", "")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(tmpDir)
+			Expect((&archiver.Tar{}).Unarchive(tarFilePath, tmpDir)).To(Succeed())
+			Expect(filepath.Join(tmpDir, collector_tar.CoreConsumptionCollectorDataSetId)).NotTo(BeADirectory())
+		})
+	})
+
 	Context("specifying foundation nickname", func() {
 		It("succeeds with env variable configuration", func() {
 			defaultEnvVars[cmd.FoundationNicknameKey] = "some-nickname"
@@ -495,9 +631,1007 @@ var _ = Describe("Collect", func() {
 
 			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
 			Expect(err).NotTo(HaveOccurred())
-			Eventually(session).Should(gexec.Exit(0))
-			tarFilePath := validatedTarFilePath(outputDirPath)
-			assertValidNickname(tarFilePath, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_vm_types", "some-nickname")
+			Eventually(session).Should(gexec.Exit(0))
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			assertValidNickname(tarFilePath, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_vm_types", "some-nickname")
+		})
+	})
+
+	Context("with an om env file", func() {
+		It("reads the Ops Manager target and credentials from the om env file", func() {
+			omEnv := fmt.Sprintf(`---
+target: %s
+username: some-username
+password: ((opsman_password))
+skip-ssl-validation: true
+connect-timeout: 10
+request-timeout: 20
+`, opsManagerServer.URL())
+			omEnvFile := filepath.Join(configDirPath, "env.yml")
+			Expect(os.WriteFile(omEnvFile, []byte(omEnv), 0755)).To(Succeed())
+
+			command := exec.Command(aqueductBinaryPath, "collect",
+				"--"+cmd.OmEnvFileFlag, omEnvFile,
+				"--"+cmd.VarsEnvFlag, "OM_VAR",
+				"--"+cmd.EnvTypeFlag, "Development",
+				"--"+cmd.OutputPathFlag, outputDirPath,
+			)
+			command.Env = append(os.Environ(), "OM_VAR_opsman_password=some-password")
+
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			assertValidOutput(tarFilePath, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_vm_types", "development")
+			assertLogging(session, tarFilePath, false, false)
+		})
+
+		It("warns about om env keys the collector does not use", func() {
+			omEnv := fmt.Sprintf(`---
+target: %s
+username: some-username
+password: some-password
+skip-ssl-validation: true
+ca-cert: some-ca
+decryption-passphrase: some-passphrase
+`, opsManagerServer.URL())
+			omEnvFile := filepath.Join(configDirPath, "env.yml")
+			Expect(os.WriteFile(omEnvFile, []byte(omEnv), 0755)).To(Succeed())
+
+			command := exec.Command(aqueductBinaryPath, "collect",
+				"--"+cmd.OmEnvFileFlag, omEnvFile,
+				"--"+cmd.EnvTypeFlag, "Development",
+				"--"+cmd.OutputPathFlag, outputDirPath,
+			)
+
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say(fmt.Sprintf(cmd.OmEnvUnsupportedKeyWarningFormat, "ca-cert")))
+			Expect(session.Out).To(gbytes.Say(fmt.Sprintf(cmd.OmEnvUnsupportedKeyWarningFormat, "decryption-passphrase")))
+			validatedTarFilePath(outputDirPath)
+		})
+
+		It("fails when the om env file has unknown keys", func() {
+			omEnvFile := filepath.Join(configDirPath, "env.yml")
+			Expect(os.WriteFile(omEnvFile, []byte("targt: https://example.com\n"), 0755)).To(Succeed())
+
+			command := exec.Command(aqueductBinaryPath, "collect", "--"+cmd.OmEnvFileFlag, omEnvFile)
+
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say("error reading om env file"))
+			Expect(session.Err).To(gbytes.Say("field targt not found"))
+			assertOutputDirEmpty(outputDirPath)
+		})
+	})
+
+	Context("with ((var)) placeholders in the config file", func() {
+		var configFile string
+
+		BeforeEach(func() {
+			config := fmt.Sprintf(`---
+url: %s
+username: ((opsman.username))
+password: ((opsman.password))
+env-type: ((env_type))
+insecure-skip-tls-verify: true
+output-dir: %s
+`, opsManagerServer.URL(), outputDirPath)
+			configFile = filepath.Join(configDirPath, "config.yml")
+			Expect(os.WriteFile(configFile, []byte(config), 0755)).To(Succeed())
+		})
+
+		It("interpolates variables from vars files and prefixed environment variables", func() {
+			varsFile := filepath.Join(configDirPath, "vars.yml")
+			Expect(os.WriteFile(varsFile, []byte("opsman:\n  username: some-username\n  password: some-password\n"), 0755)).To(Succeed())
+
+			command := exec.Command(aqueductBinaryPath, "collect",
+				"--"+cmd.ConfigFlag, configFile,
+				"--"+cmd.VarsFileFlag, varsFile,
+				"--"+cmd.VarsEnvFlag, "MY",
+			)
+			command.Env = append(os.Environ(), "MY_env_type=Development")
+
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			assertValidOutput(tarFilePath, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_vm_types", "development")
+			assertLogging(session, tarFilePath, false, false)
+		})
+
+		It("fails when variables are missing", func() {
+			command := exec.Command(aqueductBinaryPath, "collect", "--"+cmd.ConfigFlag, configFile)
+
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say("error reading config file: Expected to find variables: env_type, opsman.password, opsman.username"))
+			assertOutputDirEmpty(outputDirPath)
+		})
+	})
+
+	Context("with profiles in the config file", func() {
+		It("merges the selected profile over the shared settings", func() {
+			config := fmt.Sprintf(`---
+username: some-username
+password: some-password
+env-type: Development
+insecure-skip-tls-verify: true
+output-dir: %s
+profiles:
+  prod-east:
+    url: %s
+    env-type: production
+  prod-west:
+    url: invalid.url.example.com
+`, outputDirPath, opsManagerServer.URL())
+			configFile := filepath.Join(configDirPath, "config.yml")
+			Expect(os.WriteFile(configFile, []byte(config), 0755)).To(Succeed())
+
+			command := exec.Command(aqueductBinaryPath, "collect", "--"+cmd.ConfigFlag, configFile, "--"+cmd.ProfileFlag, "prod-east")
+
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			assertValidOutput(tarFilePath, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_vm_types", "production")
+			assertLogging(session, tarFilePath, false, false)
+		})
+
+		It("fails for unknown keys in the config file", func() {
+			configFile := filepath.Join(configDirPath, "config.yml")
+			Expect(os.WriteFile(configFile, []byte("url: https://example.com\nusrname: typo\n"), 0755)).To(Succeed())
+
+			command := exec.Command(aqueductBinaryPath, "collect", "--"+cmd.ConfigFlag, configFile)
+
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say(`line 2: unknown key "usrname"`))
+			assertOutputDirEmpty(outputDirPath)
+		})
+
+		It("fails when a profile is selected without a config file", func() {
+			command := exec.Command(aqueductBinaryPath, "collect", "--"+cmd.ProfileFlag, "prod-east")
+
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say(cmd.ProfileWithoutConfigMessage))
+		})
+	})
+
+	Context("with an installations window", func() {
+		BeforeEach(func() {
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/installations", ghttp.RespondWith(http.StatusOK, `{"installations": [
+				{"id": 3, "status": "failed", "user_name": "admin", "started_at": "2024-03-01T10:00:00.000Z", "finished_at": "2024-03-01T10:30:00.000Z", "updates": [{"identifier": "cf"}]},
+				{"id": 2, "status": "succeeded", "user_name": "admin", "started_at": "2024-02-01T10:00:00.000Z", "finished_at": "2024-02-01T11:00:00.000Z"},
+				{"id": 1, "status": "succeeded", "user_name": "admin", "started_at": "2023-01-01T10:00:00.000Z", "finished_at": "2023-01-01T10:10:00.000Z"}
+			]}`))
+		})
+
+		It("collects the installations in the window and analytics of the whole history", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			command.Args = append(command.Args, "--"+cmd.InstallationsSinceFlag, "2024-01-01", "--"+cmd.InstallationsMaxFlag, "1")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			assertValidOutput(tarFilePath, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_installation_analytics", "development")
+
+			tmpDir, err := os.MkdirTemp("", "")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(tmpDir)
+			Expect((&archiver.Tar{}).Unarchive(tarFilePath, tmpDir)).To(Succeed())
+
+			installations, err := os.ReadFile(filepath.Join(tmpDir, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_installations"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(installations).To(MatchJSON(`{"installations": [
+				{"id": 3, "status": "failed", "started_at": "2024-03-01T10:00:00.000Z", "finished_at": "2024-03-01T10:30:00.000Z", "updates": [{"identifier": "cf"}]}
+			]}`))
+
+			analytics, err := os.ReadFile(filepath.Join(tmpDir, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_installation_analytics"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(analytics)).To(ContainSubstring(`"installation_count":3`))
+			Expect(string(analytics)).To(ContainSubstring(`"failing_products":[{"product":"cf","failures":1}]`))
+
+			var installationsRequests int
+			for _, request := range opsManagerServer.ReceivedRequests() {
+				if request.URL.Path == "/api/v0/installations" {
+					installationsRequests++
+				}
+			}
+			Expect(installationsRequests).To(Equal(1))
+		})
+
+		It("fails before collecting when the window is invalid", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			command.Args = append(command.Args, "--"+cmd.InstallationsSinceFlag, "yesterday")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say(`invalid installations since "yesterday"`))
+			assertOutputDirEmpty(outputDirPath)
+		})
+	})
+
+	It("collects the Ops Manager settings without URLs or secrets", func() {
+		opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/settings/syslog", ghttp.RespondWith(http.StatusOK, `{"syslog": {"enabled": "true", "address": "syslog.example.com"}}`))
+		opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/settings/pivotal_network_settings", ghttp.RespondWith(http.StatusOK, `{"pivotal_network_settings": {"api_token": "secret-token"}}`))
+		opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/settings/rbac", ghttp.RespondWith(http.StatusNotFound, ""))
+
+		command := buildDefaultCommand(defaultEnvVars)
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit(0))
+
+		tarFilePath := validatedTarFilePath(outputDirPath)
+		assertValidOutput(tarFilePath, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_settings", "development")
+
+		tmpDir, err := os.MkdirTemp("", "")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(tmpDir)
+		Expect((&archiver.Tar{}).Unarchive(tarFilePath, tmpDir)).To(Succeed())
+
+		settings, err := os.ReadFile(filepath.Join(tmpDir, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_settings"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(settings).To(MatchJSON(`{
+			"auth_type": null,
+			"custom_ssl_certificate": false,
+			"syslog_enabled": true,
+			"ui_banner_set": false,
+			"ssh_banner_set": false,
+			"pivnet_token_configured": true,
+			"iaas_type": null
+		}`))
+
+		var diagnosticReportRequests int
+		for _, request := range opsManagerServer.ReceivedRequests() {
+			if request.URL.Path == "/api/v0/diagnostic_report" {
+				diagnosticReportRequests++
+			}
+		}
+		Expect(diagnosticReportRequests).To(Equal(1))
+	})
+
+	Context("with stemcell associations, errands and the director network", func() {
+		BeforeEach(func() {
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/deployed/products", ghttp.RespondWith(http.StatusOK, `[
+				{"installation_name": "p-bosh", "guid": "p-bosh-guid", "type": "p-bosh"},
+				{"installation_name": "cf-abc123", "guid": "cf-abc123", "type": "cf"}
+			]`))
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/products/cf-abc123/resources", ghttp.RespondWith(http.StatusOK, `{"resources": []}`))
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/products/cf-abc123/properties", ghttp.RespondWith(http.StatusOK, `{"properties": {}}`))
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/products/cf-abc123/errands", ghttp.RespondWith(http.StatusOK, `{"errands": [{"name": "smoke_tests", "post_deploy": true, "label": "Smoke Tests"}]}`))
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/stemcell_associations", ghttp.RespondWith(http.StatusOK, `{"products": [{"guid": "cf-abc123", "identifier": "cf", "staged_stemcells": [{"os": "ubuntu-jammy", "version": "1.351"}]}]}`))
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/director/availability_zones", ghttp.RespondWith(http.StatusOK, `{"availability_zones": [{"name": "az1", "guid": "az1-guid"}]}`))
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/director/networks", ghttp.RespondWith(http.StatusOK, `{"networks": [{"name": "pas", "subnets": [{"cidr": "10.0.4.0/24", "gateway": "10.0.4.1", "availability_zone_names": ["az1"]}]}]}`))
+		})
+
+		It("does not collect them by default", func() {
+			for _, optionalPath := range []string{"/api/v0/staged/products/cf-abc123/errands", "/api/v0/stemcell_associations", "/api/v0/staged/director/networks"} {
+				opsManagerServer.RouteToHandler(http.MethodGet, optionalPath, func(w http.ResponseWriter, req *http.Request) {
+					Fail(req.URL.Path + " should not be requested unless its dataset is selected")
+				})
+			}
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+			validatedTarFilePath(outputDirPath)
+		})
+
+		It("collects them when selected, without GUIDs or addresses", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			command.Args = append(command.Args, "--"+cmd.DatasetsFlag, "opsmanager,stemcell_associations,errands,director_network")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			assertValidOutput(tarFilePath, collector_tar.OpsManagerCollectorDataSetId, "cf_errands", "development")
+			assertValidOutput(tarFilePath, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_stemcell_associations", "development")
+			assertValidOutput(tarFilePath, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_director_network", "development")
+
+			tmpDir, err := os.MkdirTemp("", "")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(tmpDir)
+			Expect((&archiver.Tar{}).Unarchive(tarFilePath, tmpDir)).To(Succeed())
+
+			stemcells, err := os.ReadFile(filepath.Join(tmpDir, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_stemcell_associations"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(stemcells)).NotTo(ContainSubstring("cf-abc123"))
+			network, err := os.ReadFile(filepath.Join(tmpDir, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_director_network"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(network)).To(ContainSubstring(`"addresses":"256"`))
+			Expect(string(network)).NotTo(ContainSubstring("10.0.4"))
+			unkeyedHash := sha256.Sum256([]byte("10.0.4.0/24"))
+			Expect(string(network)).NotTo(ContainSubstring(hex.EncodeToString(unkeyedHash[:])))
+		})
+
+		It("collects only the selected ones", func() {
+			for _, unselectedPath := range []string{"/api/v0/staged/products/cf-abc123/errands", "/api/v0/staged/director/networks"} {
+				opsManagerServer.RouteToHandler(http.MethodGet, unselectedPath, func(w http.ResponseWriter, req *http.Request) {
+					Fail(req.URL.Path + " should not be requested when its dataset is not selected")
+				})
+			}
+			command := buildDefaultCommand(defaultEnvVars)
+			command.Args = append(command.Args, "--"+cmd.DatasetsFlag, "opsmanager,stemcell_associations")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			assertValidOutput(tarFilePath, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_stemcell_associations", "development")
+		})
+
+		It("fails before collecting when selected without the opsmanager dataset", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			command.Args = append(command.Args, "--"+cmd.DatasetsFlag, "errands")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say(`dataset "errands" is collected from Ops Manager and requires the opsmanager dataset`))
+			assertOutputDirEmpty(outputDirPath)
+		})
+	})
+
+	Context("with lint rules", func() {
+		BeforeEach(func() {
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/deployed/products", ghttp.RespondWith(http.StatusOK, `[
+				{"installation_name": "p-bosh", "guid": "p-bosh-guid", "type": "p-bosh"},
+				{"installation_name": "cf-abc123", "guid": "cf-abc123", "type": "cf"}
+			]`))
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/products/cf-abc123/resources", ghttp.RespondWith(http.StatusOK, `{"resources": [{"identifier": "router", "instances": 1}]}`))
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/products/cf-abc123/properties", ghttp.RespondWith(http.StatusOK, `{"properties": {".properties.skip_cert_verify": {"type": "boolean", "value": true}}}`))
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/director/availability_zones", ghttp.RespondWith(http.StatusOK, `{"availability_zones": [{"name": "az1", "guid": "az1-guid"}]}`))
+		})
+
+		It("reports the findings of the built-in rules and writes them to the data", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			command.Args = append(command.Args, "--"+cmd.LintFlag, "--"+cmd.DatasetsFlag, "opsmanager,director_network")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			Expect(session.Out).To(gbytes.Say(`2 lint findings from 5 rules: 0 errors, 2 warnings, 0 info`))
+			Expect(session.Out).To(gbytes.Say(`warning\s+insecure-tls-property\s+opsmanager/cf_properties`))
+			Expect(session.Out).To(gbytes.Say(`warning\s+single-availability-zone\s+opsmanager/ops_manager_director_network`))
+
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			assertValidOutput(tarFilePath, lint.LintDataSetId, lint.FindingsDataType, "development")
+
+			tmpDir, err := os.MkdirTemp("", "")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(tmpDir)
+			Expect((&archiver.Tar{}).Unarchive(tarFilePath, tmpDir)).To(Succeed())
+
+			content, err := os.ReadFile(filepath.Join(tmpDir, lint.LintDataSetId, lint.FindingsDataType))
+			Expect(err).NotTo(HaveOccurred())
+			var findings lint.Findings
+			Expect(json.Unmarshal(content, &findings)).To(Succeed())
+			Expect(findings.EnvType).To(Equal("development"))
+			Expect(findings.Findings).To(HaveLen(2))
+		})
+
+		It("runs the rules from a file instead of the built-in rules", func() {
+			rulesPath := filepath.Join(configDirPath, "rules.yml")
+			Expect(os.WriteFile(rulesPath, []byte(`
+rules:
+- id: single-router
+  severity: error
+  description: A single router
+  files: [opsmanager/*_resources]
+  path: $.resources[*]
+  label: identifier
+  where:
+    all:
+    - field: identifier
+      equals: router
+    - field: instances
+      equals: 1
+`), 0644)).To(Succeed())
+			defaultEnvVars[cmd.LintRulesKey] = rulesPath
+
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			Expect(session.Out).To(gbytes.Say(`1 lint findings from 1 rules: 1 errors, 0 warnings, 0 info`))
+			Expect(session.Out).To(gbytes.Say(`error\s+single-router\s+opsmanager/cf_resources\s+router\s+A single router`))
+		})
+
+		It("fails before collecting when the rules are invalid", func() {
+			rulesPath := filepath.Join(configDirPath, "rules.yml")
+			Expect(os.WriteFile(rulesPath, []byte("rules:\n- id: broken\n  severity: critical\n"), 0644)).To(Succeed())
+			defaultEnvVars[cmd.LintRulesKey] = rulesPath
+
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say(`rule "broken": severity "critical" is not error, warning or info`))
+			Expect(opsManagerServer.ReceivedRequests()).To(BeEmpty())
+			assertOutputDirEmpty(outputDirPath)
+		})
+	})
+
+	Context("with pseudonymization", func() {
+		const salt = "a-salt-of-at-least-sixteen-characters"
+
+		BeforeEach(func() {
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/deployed/products", ghttp.RespondWith(http.StatusOK, `[
+				{"installation_name": "p-bosh", "guid": "p-bosh-0123456789abcdef0123", "type": "p-bosh"},
+				{"installation_name": "cf-abcdef0123456789abcd", "guid": "cf-abcdef0123456789abcd", "type": "cf"}
+			]`))
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/products/cf-abcdef0123456789abcd/resources", ghttp.RespondWith(http.StatusOK, `{"resources": [{"identifier": "router", "instances": 1, "static_ips": ["10.0.16.4"]}]}`))
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/products/cf-abcdef0123456789abcd/properties", ghttp.RespondWith(http.StatusOK, `{"properties": {}}`))
+			defaultEnvVars[cmd.PseudonymizeKey] = "true"
+			defaultEnvVars[cmd.PseudonymizeSaltKey] = salt
+		})
+
+		untarredContents := func(tarFilePath string) (string, string) {
+			tmpDir, err := os.MkdirTemp("", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect((&archiver.Tar{}).Unarchive(tarFilePath, tmpDir)).To(Succeed())
+
+			var contents strings.Builder
+			Expect(filepath.Walk(tmpDir, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+				content, err := os.ReadFile(path)
+				contents.Write(content)
+				return err
+			})).To(Succeed())
+			return tmpDir, contents.String()
+		}
+
+		collectedFiles := func() (string, string) {
+			fileInfos, err := os.ReadDir(outputDirPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fileInfos).To(HaveLen(2))
+
+			var tarFilePath, lookupTablePath string
+			for _, fileInfo := range fileInfos {
+				if strings.HasSuffix(fileInfo.Name(), cmd.PseudonymLookupTableSuffix) {
+					lookupTablePath = filepath.Join(outputDirPath, fileInfo.Name())
+				} else {
+					tarFilePath = filepath.Join(outputDirPath, fileInfo.Name())
+				}
+			}
+			Expect(tarFilePath).To(MatchRegexp(fmt.Sprintf(`%s%s.tar$`, cmd.OutputFilePrefix, UnixTimestampRegexp)))
+			Expect(lookupTablePath).To(Equal(strings.TrimSuffix(tarFilePath, ".tar") + cmd.PseudonymLookupTableSuffix))
+			return tarFilePath, lookupTablePath
+		}
+
+		It("replaces the identifiers in the data and writes the lookup table next to it", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			tarFilePath, lookupTablePath := collectedFiles()
+			Expect(session.Out).To(gbytes.Say("Wrote the pseudonym lookup table to " + regexp.QuoteMeta(lookupTablePath)))
+
+			tmpDir, contents := untarredContents(tarFilePath)
+			defer os.RemoveAll(tmpDir)
+			Expect(contents).NotTo(ContainSubstring("p-bosh-0123456789abcdef0123"))
+			Expect(contents).NotTo(ContainSubstring("cf-abcdef0123456789abcd"))
+			Expect(contents).NotTo(ContainSubstring("10.0.16.4"))
+			Expect(contents).To(MatchRegexp(`ip-[0-9a-f]{16}`))
+
+			content, err := os.ReadFile(filepath.Join(tmpDir, collector_tar.OpsManagerCollectorDataSetId, collector_tar.MetadataFileName))
+			Expect(err).NotTo(HaveOccurred())
+			var metadata collector_tar.Metadata
+			Expect(json.Unmarshal(content, &metadata)).To(Succeed())
+			Expect(metadata.FoundationId).To(MatchRegexp(`^guid-[0-9a-f]{16}$`))
+
+			info, err := os.Stat(lookupTablePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+			table, err := os.ReadFile(lookupTablePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(table)).To(ContainSubstring(`"identifier": "p-bosh-0123456789abcdef0123"`))
+			Expect(string(table)).To(ContainSubstring(`"identifier": "10.0.16.4"`))
+		})
+
+		It("keeps the foundation id when asked", func() {
+			defaultEnvVars[cmd.KeepFoundationIDKey] = "true"
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			tarFilePath, _ := collectedFiles()
+			tmpDir, _ := untarredContents(tarFilePath)
+			defer os.RemoveAll(tmpDir)
+
+			content, err := os.ReadFile(filepath.Join(tmpDir, collector_tar.OpsManagerCollectorDataSetId, collector_tar.MetadataFileName))
+			Expect(err).NotTo(HaveOccurred())
+			var metadata collector_tar.Metadata
+			Expect(json.Unmarshal(content, &metadata)).To(Succeed())
+			Expect(metadata.FoundationId).To(Equal("p-bosh-0123456789abcdef0123"))
+		})
+
+		It("fails before collecting without a salt", func() {
+			delete(defaultEnvVars, cmd.PseudonymizeSaltKey)
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say(cmd.PseudonymizeWithoutSaltMessage))
+			Expect(opsManagerServer.ReceivedRequests()).To(BeEmpty())
+			assertOutputDirEmpty(outputDirPath)
+		})
+
+		It("fails before collecting with a short salt", func() {
+			defaultEnvVars[cmd.PseudonymizeSaltKey] = "short"
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say("the pseudonymize salt must be at least 16 characters"))
+			Expect(opsManagerServer.ReceivedRequests()).To(BeEmpty())
+			assertOutputDirEmpty(outputDirPath)
+		})
+	})
+
+	Context("when the collected data holds secrets", func() {
+		BeforeEach(func() {
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/deployed/products", ghttp.RespondWith(http.StatusOK, `[
+				{"installation_name": "p-bosh", "guid": "p-bosh-guid", "type": "p-bosh"},
+				{"installation_name": "cf-abc123", "guid": "cf-abc123", "type": "cf"}
+			]`))
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/products/cf-abc123/resources", ghttp.RespondWith(http.StatusOK, `{"resources": [{"identifier": "router", "instances": 1, "agent_password": "hunter2-leaked"}]}`))
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/products/cf-abc123/properties", ghttp.RespondWith(http.StatusOK, `{"properties": {}}`))
+		})
+
+		resourcesContent := func() string {
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			tmpDir, err := os.MkdirTemp("", "")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(tmpDir)
+			Expect((&archiver.Tar{}).Unarchive(tarFilePath, tmpDir)).To(Succeed())
+
+			content, err := os.ReadFile(filepath.Join(tmpDir, collector_tar.OpsManagerCollectorDataSetId, "cf_resources"))
+			Expect(err).NotTo(HaveOccurred())
+			return string(content)
+		}
+
+		It("redacts them and reports where they were when asked", func() {
+			defaultEnvVars[cmd.OnSecretDetectedKey] = "redact"
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			Expect(session.Out).To(gbytes.Say(`Warning: Redacted possible password or secret field in opsmanager/cf_resources at \$\.resources\[0\]\.agent_password`))
+			Expect(string(session.Out.Contents())).NotTo(ContainSubstring("hunter2-leaked"))
+			Expect(resourcesContent()).To(ContainSubstring(`"agent_password":"[REDACTED]"`))
+		})
+
+		It("warns and writes them unchanged by default", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			Expect(session.Out).To(gbytes.Say(`Warning: Possible password or secret field in opsmanager/cf_resources at \$\.resources\[0\]\.agent_password`))
+			Expect(resourcesContent()).To(ContainSubstring("hunter2-leaked"))
+		})
+
+		It("fails without writing the collection when asked", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			command.Args = append(command.Args, "--"+cmd.OnSecretDetectedFlag, "fail")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+
+			Expect(session.Err).To(gbytes.Say(`possible secrets detected in opsmanager/cf_resources, no collection was written:\n\s+password or secret field at \$\.resources\[0\]\.agent_password`))
+			Expect(string(session.Err.Contents())).NotTo(ContainSubstring("hunter2-leaked"))
+			assertOutputDirEmpty(outputDirPath)
+		})
+
+		It("fails before collecting with an unknown policy", func() {
+			defaultEnvVars[cmd.OnSecretDetectedKey] = "ignore"
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+
+			Expect(session.Err).To(gbytes.Say(`invalid secret detected policy "ignore", valid policies are: fail, redact, warn`))
+			Expect(opsManagerServer.ReceivedRequests()).To(BeEmpty())
+			assertOutputDirEmpty(outputDirPath)
+		})
+	})
+
+	Context("with product filters", func() {
+		BeforeEach(func() {
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/deployed/products", ghttp.RespondWith(http.StatusOK, `[
+				{"installation_name": "p-bosh", "guid": "p-bosh-guid", "type": "p-bosh"},
+				{"installation_name": "cf-abc123", "guid": "cf-abc123", "type": "cf"},
+				{"installation_name": "partner-tile-123", "guid": "partner-tile-123", "type": "partner-tile"}
+			]`))
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/products/cf-abc123/resources", ghttp.RespondWith(http.StatusOK, `{"resources": []}`))
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/products/cf-abc123/properties", ghttp.RespondWith(http.StatusOK, `{"properties": {}}`))
+			for _, excludedPath := range []string{"/api/v0/staged/products/partner-tile-123/resources", "/api/v0/staged/products/partner-tile-123/properties"} {
+				opsManagerServer.RouteToHandler(http.MethodGet, excludedPath, func(w http.ResponseWriter, req *http.Request) {
+					Fail(req.URL.Path + " should not be requested for an excluded product")
+				})
+			}
+		})
+
+		It("skips the excluded products and records their types", func() {
+			defaultEnvVars[cmd.ExcludeProductTypeKey] = "partner-*"
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say(`Skipping resources and properties of product partner-tile \(partner-tile-123\): product type excluded`))
+
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			assertValidOutput(tarFilePath, collector_tar.OpsManagerCollectorDataSetId, "cf_properties", "development")
+
+			tmpDir, err := os.MkdirTemp("", "")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(tmpDir)
+			Expect((&archiver.Tar{}).Unarchive(tarFilePath, tmpDir)).To(Succeed())
+
+			Expect(filepath.Join(tmpDir, collector_tar.OpsManagerCollectorDataSetId, "partner-tile_properties")).NotTo(BeAnExistingFile())
+			details, err := os.ReadFile(filepath.Join(tmpDir, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_collection_details"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(details).To(MatchJSON(`{
+				"role": "unknown",
+				"ops_manager_version": "3.0.10-build.1",
+				"skipped_datasets": [],
+				"excluded_products": ["partner-tile"]
+			}`))
+		})
+
+		It("collects only the included product types", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			command.Args = append(command.Args, "--"+cmd.IncludeProductTypeFlag, "cf", "--"+cmd.ExcludeProductGUIDFlag, "does-not-match")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say(`Skipping resources and properties of product partner-tile \(partner-tile-123\): product type not included`))
+		})
+
+		It("fails before collecting when a pattern is invalid", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			command.Args = append(command.Args, "--"+cmd.ExcludeProductGUIDFlag, "partner-[")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say(`invalid product pattern "partner-\["`))
+			assertOutputDirEmpty(outputDirPath)
+		})
+	})
+
+	Context("with the deployed product configuration", func() {
+		BeforeEach(func() {
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/pending_changes", ghttp.RespondWith(http.StatusOK, `{"product_changes": [{"guid": "cf-abc123", "action": "update"}]}`))
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/deployed/products", ghttp.RespondWith(http.StatusOK, `[
+				{"installation_name": "p-bosh", "guid": "p-bosh-guid", "type": "p-bosh"},
+				{"installation_name": "cf-abc123", "guid": "cf-abc123", "type": "cf"}
+			]`))
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/deployed/products/cf-abc123/manifest", ghttp.RespondWith(http.StatusOK, `{
+				"instance_groups": [{"name": "router", "instances": 2, "vm_type": "medium", "jobs": [
+					{"name": "gorouter", "release": "routing", "properties": {"router": {"port": 80, "status": {"password": "secret-password"}}}}
+				]}]
+			}`))
+			for _, stagedPath := range []string{"/api/v0/staged/products/cf-abc123/resources", "/api/v0/staged/products/cf-abc123/properties"} {
+				opsManagerServer.RouteToHandler(http.MethodGet, stagedPath, func(w http.ResponseWriter, req *http.Request) {
+					Fail(req.URL.Path + " should not be requested when reading the deployed configuration")
+				})
+			}
+		})
+
+		It("reads product resources and properties from the deployed manifests", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			command.Args = append(command.Args, "--"+cmd.ProductConfigSourceFlag, "deployed")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say("Product resources and properties are read from the deployed manifests"))
+
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			assertValidOutput(tarFilePath, collector_tar.OpsManagerCollectorDataSetId, "cf_deployed_resources", "development")
+			assertValidOutput(tarFilePath, collector_tar.OpsManagerCollectorDataSetId, "cf_deployed_properties", "development")
+
+			tmpDir, err := os.MkdirTemp("", "")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(tmpDir)
+			Expect((&archiver.Tar{}).Unarchive(tarFilePath, tmpDir)).To(Succeed())
+
+			properties, err := os.ReadFile(filepath.Join(tmpDir, collector_tar.OpsManagerCollectorDataSetId, "cf_deployed_properties"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(properties).To(MatchJSON(`{"instance_groups": [{"name": "router", "jobs": [{"name": "gorouter", "release": "routing", "properties": {"router": {"port": 80}}}]}]}`))
+
+			metadataContent, err := os.ReadFile(filepath.Join(tmpDir, collector_tar.OpsManagerCollectorDataSetId, collector_tar.MetadataFileName))
+			Expect(err).NotTo(HaveOccurred())
+			var metadata collector_tar.Metadata
+			Expect(json.Unmarshal(metadataContent, &metadata)).To(Succeed())
+			var dataTypes []string
+			for _, digest := range metadata.FileDigests {
+				if digest.ProductType == "cf" {
+					dataTypes = append(dataTypes, digest.DataType)
+				}
+			}
+			Expect(dataTypes).To(ConsistOf(opsmanager.DeployedResourcesDataType, opsmanager.DeployedPropertiesDataType))
+		})
+
+		It("rejects an unknown source", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			command.Args = append(command.Args, "--"+cmd.ProductConfigSourceFlag, "running")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say(`invalid product config source "running"`))
+			assertOutputDirEmpty(outputDirPath)
+		})
+	})
+
+	Context("with pending changes", func() {
+		BeforeEach(func() {
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/pending_changes", ghttp.RespondWith(http.StatusOK, `{"product_changes": [{"guid": "cf-abc123", "action": "update"}]}`))
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/deployed/products", ghttp.RespondWith(http.StatusOK, `[
+				{"installation_name": "p-bosh", "guid": "p-bosh-guid", "type": "p-bosh"},
+				{"installation_name": "cf-abc123", "guid": "cf-abc123", "type": "cf"}
+			]`))
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/products/cf-abc123/resources", ghttp.RespondWith(http.StatusOK, `{"resources": []}`))
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/products/cf-abc123/properties", ghttp.RespondWith(http.StatusOK, `{"properties": {}}`))
+		})
+
+		It("warns, collects and records the changed products by default", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say("Warning: This foundation has pending changes"))
+
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			assertValidOutput(tarFilePath, collector_tar.OpsManagerCollectorDataSetId, "cf_properties", "development")
+
+			tmpDir, err := os.MkdirTemp("", "")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(tmpDir)
+			Expect((&archiver.Tar{}).Unarchive(tarFilePath, tmpDir)).To(Succeed())
+
+			details, err := os.ReadFile(filepath.Join(tmpDir, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_collection_details"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(details).To(MatchJSON(`{
+				"role": "unknown",
+				"ops_manager_version": "3.0.10-build.1",
+				"skipped_datasets": [],
+				"pending_changes": [{"guid": "cf-abc123", "type": "cf", "action": "update"}],
+				"pending_changes_policy": "warn"
+			}`))
+		})
+
+		It("exits with code 3 without writing anything when the policy is to fail", func() {
+			defaultEnvVars[cmd.PendingChangesKey] = "fail"
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(cmd.PendingChangesExistsExitCode))
+			Expect(session.Out).To(gbytes.Say("cf-abc123: update"))
+			Expect(session.Err).To(gbytes.Say(opsmanager.PendingChangesExistsMessage))
+			assertOutputDirEmpty(outputDirPath)
+		})
+
+		It("skips the changed products when the policy is to skip them", func() {
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/products/cf-abc123/properties", func(w http.ResponseWriter, req *http.Request) {
+				Fail("the properties of a product with pending changes should not be requested")
+			})
+
+			command := buildDefaultCommand(defaultEnvVars)
+			command.Args = append(command.Args, "--"+cmd.PendingChangesFlag, "skip-products")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say(`Skipping resources and properties of product cf \(cf-abc123\): product has pending changes`))
+		})
+	})
+
+	Context("with an installation running", func() {
+		var installationsRequests int
+
+		BeforeEach(func() {
+			installationsRequests = 0
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/installations", func(w http.ResponseWriter, req *http.Request) {
+				installationsRequests++
+				status := "running"
+				if installationsRequests > 2 {
+					status = "succeeded"
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"installations": [{"id": 42, "status": "` + status + `", "user_name": "admin"}, {"id": 41, "status": "succeeded"}]}`))
+			})
+		})
+
+		readCollectionDetails := func() []byte {
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			tmpDir, err := os.MkdirTemp("", "")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(tmpDir)
+			Expect((&archiver.Tar{}).Unarchive(tarFilePath, tmpDir)).To(Succeed())
+
+			details, err := os.ReadFile(filepath.Join(tmpDir, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_collection_details"))
+			Expect(err).NotTo(HaveOccurred())
+			return details
+		}
+
+		It("warns, collects and records the running installation by default", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say("Warning: Installation 42 is running"))
+
+			Expect(readCollectionDetails()).To(ContainSubstring(`"installation":{"id":42,"status":"running"}`))
+		})
+
+		It("waits for the installation to finish", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			command.Args = append(command.Args, "--"+cmd.IfInstallingFlag, "wait", "--"+cmd.InstallingPollIntervalFlag, "1")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, 10).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say("Installation 42 is running, checking again in 1s"))
+
+			Expect(readCollectionDetails()).To(ContainSubstring(`"installation":{"id":42,"status":"succeeded"}`))
+		})
+
+		It("fails when the installation is still running after the maximum wait", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			command.Args = append(command.Args, "--"+cmd.IfInstallingFlag, "wait", "--"+cmd.InstallingMaxWaitFlag, "0")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say("installation 42 is still running after waiting 0s"))
+			assertOutputDirEmpty(outputDirPath)
+		})
+
+		It("rejects a poll interval that is not positive and a negative maximum wait before contacting Ops Manager", func() {
+			for flag, message := range map[string]string{
+				"--" + cmd.InstallingPollIntervalFlag + "=0":  cmd.InvalidInstallingPollIntervalMessage,
+				"--" + cmd.InstallingPollIntervalFlag + "=-1": cmd.InvalidInstallingPollIntervalMessage,
+				"--" + cmd.InstallingMaxWaitFlag + "=-1":      cmd.InvalidInstallingMaxWaitMessage,
+			} {
+				command := buildDefaultCommand(defaultEnvVars)
+				command.Args = append(command.Args, "--"+cmd.IfInstallingFlag, "wait", flag)
+				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(1))
+				Expect(session.Err).To(gbytes.Say(message))
+				Expect(session.Err).To(gbytes.Say("USAGE EXAMPLES"))
+			}
+			Expect(opsManagerServer.ReceivedRequests()).To(BeEmpty())
+			assertOutputDirEmpty(outputDirPath)
+		})
+
+		It("skips the collection without writing anything", func() {
+			defaultEnvVars[cmd.IfInstallingKey] = "skip"
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say(cmd.SkippedWhileInstallingMessage))
+			assertOutputDirEmpty(outputDirPath)
+		})
+	})
+
+	Context("with dataset selection", func() {
+		datasetFiles := func(tarFilePath string) []string {
+			tmpDir, err := os.MkdirTemp("", "")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(tmpDir)
+			Expect((&archiver.Tar{}).Unarchive(tarFilePath, tmpDir)).To(Succeed())
+
+			entries, err := os.ReadDir(tmpDir)
+			Expect(err).NotTo(HaveOccurred())
+			var datasets []string
+			for _, entry := range entries {
+				datasets = append(datasets, entry.Name())
+			}
+			return datasets
+		}
+
+		It("collects core counts alongside the Ops Manager data without usage service configuration", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			command.Args = append(command.Args, "--"+cmd.DatasetsFlag, "opsmanager,core_consumption")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			assertValidOutput(tarFilePath, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_vm_types", "development")
+			assertValidOutput(tarFilePath, collector_tar.CoreConsumptionCollectorDataSetId, collector_tar.CoreCountsDataType, "development")
+			Expect(datasetFiles(tarFilePath)).To(ConsistOf(collector_tar.OpsManagerCollectorDataSetId, collector_tar.CoreConsumptionCollectorDataSetId, capacity.CapacityDataSetId))
+		})
+
+		It("only writes the metadata of the selected datasets", func() {
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/deployed/products", ghttp.RespondWith(http.StatusOK, `[
+				{"installation_name": "p-bosh", "guid": "p-bosh-guid", "type": "p-bosh"},
+				{"installation_name": "cf-abc123", "guid": "cf-abc123", "type": "cf"}
+			]`))
+			defaultEnvVars[cmd.DatasetsKey] = "core_consumption"
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			Expect(datasetFiles(tarFilePath)).To(ConsistOf(collector_tar.CoreConsumptionCollectorDataSetId))
+
+			var opsManagerDataRequests []string
+			for _, request := range opsManagerServer.ReceivedRequests() {
+				if strings.HasPrefix(request.URL.Path, "/api/v0/staged/products/") || request.URL.Path == "/api/v0/installations" || request.URL.Path == "/api/v0/staged/pending_changes" {
+					opsManagerDataRequests = append(opsManagerDataRequests, request.URL.Path)
+				}
+			}
+			Expect(opsManagerDataRequests).To(BeEmpty())
+		})
+
+		It("reads the datasets from the config file", func() {
+			configFile := filepath.Join(configDirPath, "config.yml")
+			Expect(os.WriteFile(configFile, []byte("datasets: [opsmanager, core_consumption]\n"), 0755)).To(Succeed())
+
+			command := buildDefaultCommand(defaultEnvVars)
+			command.Args = append(command.Args, "--"+cmd.ConfigFlag, configFile)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			Expect(datasetFiles(tarFilePath)).To(ConsistOf(collector_tar.OpsManagerCollectorDataSetId, collector_tar.CoreConsumptionCollectorDataSetId, capacity.CapacityDataSetId))
+		})
+
+		It("keeps --operational-data-only as a preset for usage service data and core counts", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			command.Args = append(command.Args, "--"+cmd.OperationalDataOnlyFlag)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			Expect(datasetFiles(tarFilePath)).To(ConsistOf(collector_tar.CoreConsumptionCollectorDataSetId))
+		})
+
+		It("fails for unknown datasets", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			command.Args = append(command.Args, "--"+cmd.DatasetsFlag, "opsmanager,usage")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say(`unknown dataset "usage"`))
+			assertOutputDirEmpty(outputDirPath)
+		})
+
+		It("fails when combined with --operational-data-only", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			command.Args = append(command.Args, "--"+cmd.DatasetsFlag, "opsmanager", "--"+cmd.OperationalDataOnlyFlag)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say(cmd.DatasetsWithOperationalDataOnlyMessage))
+		})
+
+		It("requires the usage service configuration when usage service data is selected", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			command.Args = append(command.Args, "--"+cmd.DatasetsFlag, "opsmanager,usage_service")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say(cmd.InvalidUsageConfigurationMessage))
+			assertOutputDirEmpty(outputDirPath)
 		})
 	})
 
@@ -612,7 +1746,56 @@ var _ = Describe("Collect", func() {
 			Expect(session.Err).NotTo(gbytes.Say("USAGE EXAMPLES"))
 		})
 
+		It("discovers UAA from /v2/info when the CF API root does not link to UAA", func() {
+			cfService.RouteToHandler(http.MethodGet, "/", ghttp.RespondWith(http.StatusOK, `{ "links": {} }`))
+
+			defaultEnvVars[cmd.UsageServiceURLKey] = usageService.URL()
+			defaultEnvVars[cmd.CfApiURLKey] = cfService.URL()
+			defaultEnvVars[cmd.UsageServiceClientIDKey] = "best-usage-service-client-id"
+			defaultEnvVars[cmd.UsageServiceClientSecretKey] = "best-usage-service-client-secret"
+			defaultEnvVars[cmd.UsageServiceSkipTlsVerifyKey] = "true"
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			assertValidOutput(tarFilePath, collector_tar.UsageServiceCollectorDataSetId, "app_usage", "development")
+		})
+
+		It("does not request /v2/info when the CF API root links to UAA", func() {
+			cfService.RouteToHandler(http.MethodGet, "/v2/info", func(w http.ResponseWriter, req *http.Request) {
+				Fail("/v2/info should not be requested when the CF API root links to UAA")
+			})
+
+			defaultEnvVars[cmd.UsageServiceURLKey] = usageService.URL()
+			defaultEnvVars[cmd.CfApiURLKey] = cfService.URL()
+			defaultEnvVars[cmd.UsageServiceClientIDKey] = "best-usage-service-client-id"
+			defaultEnvVars[cmd.UsageServiceClientSecretKey] = "best-usage-service-client-secret"
+			defaultEnvVars[cmd.UsageServiceSkipTlsVerifyKey] = "true"
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+		})
+
+		It("names the usage service client when UAA rejects its credentials", func() {
+			uaaService.RouteToHandler(http.MethodPost, "/oauth/token", ghttp.RespondWith(http.StatusUnauthorized, `{"error": "unauthorized"}`))
+
+			defaultEnvVars[cmd.UsageServiceURLKey] = usageService.URL()
+			defaultEnvVars[cmd.CfApiURLKey] = cfService.URL()
+			defaultEnvVars[cmd.UsageServiceClientIDKey] = "best-usage-service-client-id"
+			defaultEnvVars[cmd.UsageServiceClientSecretKey] = "best-usage-service-client-secret"
+			defaultEnvVars[cmd.UsageServiceSkipTlsVerifyKey] = "true"
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say(`rejected the credentials of client "best-usage-service-client-id"`))
+		})
+
 		It("fails if getting the UAA URL fails", func() {
+			cfService.RouteToHandler(http.MethodGet, "/", ghttp.RespondWith(http.StatusNotFound, ""))
 			cfService.RouteToHandler(http.MethodGet, "/v2/info", func(w http.ResponseWriter, req *http.Request) {
 				w.WriteHeader(500)
 			})
@@ -715,6 +1898,282 @@ var _ = Describe("Collect", func() {
 		)
 	})
 
+	Context("when cf inventory collection is enabled", func() {
+		var (
+			usageService *ghttp.Server
+			cfService    *ghttp.Server
+			uaaService   *ghttp.Server
+		)
+		BeforeEach(func() {
+			uaaService, cfService, usageService = setupUsageService("")
+			setupCfInventoryHandlers(cfService)
+
+			defaultEnvVars[cmd.CfApiURLKey] = cfService.URL()
+			defaultEnvVars[cmd.UsageServiceClientIDKey] = "best-usage-service-client-id"
+			defaultEnvVars[cmd.UsageServiceClientSecretKey] = "best-usage-service-client-secret"
+			defaultEnvVars[cmd.UsageServiceSkipTlsVerifyKey] = "true"
+			defaultEnvVars[cmd.WithCfInventoryKey] = "true"
+		})
+
+		AfterEach(func() {
+			usageService.Close()
+			cfService.Close()
+			uaaService.Close()
+		})
+
+		It("writes the platform inventory to its own dataset", func() {
+			defaultEnvVars[cmd.UsageServiceURLKey] = usageService.URL()
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			assertValidOutput(tarFilePath, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_vm_types", "development")
+			assertValidOutput(tarFilePath, collector_tar.UsageServiceCollectorDataSetId, "app_usage", "development")
+			assertValidOutput(tarFilePath, cfinventory.CfInventoryCollectorDataSetId, cfinventory.PlatformInventoryDataType, "development")
+			Expect(session.Out).To(gbytes.Say(fmt.Sprintf("Collecting data from CF API at %s", cfService.URL())))
+
+			tokenRequests := 0
+			for _, req := range uaaService.ReceivedRequests() {
+				if req.URL.Path == "/oauth/token" {
+					tokenRequests++
+				}
+			}
+			Expect(tokenRequests).To(Equal(1))
+		})
+
+		It("does not require the usage service url", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			assertValidOutput(tarFilePath, cfinventory.CfInventoryCollectorDataSetId, cfinventory.PlatformInventoryDataType, "development")
+		})
+
+		It("fails when the usage service client is not configured", func() {
+			delete(defaultEnvVars, cmd.UsageServiceClientIDKey)
+			delete(defaultEnvVars, cmd.UsageServiceClientSecretKey)
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say(cmd.InvalidCfInventoryConfigMessage))
+			assertOutputDirEmpty(outputDirPath)
+		})
+
+		It("fails when the CF API cannot be read", func() {
+			cfService.RouteToHandler(http.MethodGet, cfinventory.AppsPath, ghttp.RespondWith(http.StatusForbidden, ""))
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say(operations.CfInventoryCollectFailureMessage))
+			assertOutputDirEmpty(outputDirPath)
+		})
+	})
+
+	Context("when usage is read from the CF API", func() {
+		var (
+			usageService *ghttp.Server
+			cfService    *ghttp.Server
+			uaaService   *ghttp.Server
+		)
+		BeforeEach(func() {
+			uaaService, cfService, usageService = setupUsageService("")
+			setupCfInventoryHandlers(cfService)
+
+			defaultEnvVars[cmd.CfApiURLKey] = cfService.URL()
+			defaultEnvVars[cmd.UsageServiceClientIDKey] = "best-usage-service-client-id"
+			defaultEnvVars[cmd.UsageServiceClientSecretKey] = "best-usage-service-client-secret"
+			defaultEnvVars[cmd.UsageServiceSkipTlsVerifyKey] = "true"
+			defaultEnvVars[cmd.UsageFromCfApiKey] = "true"
+		})
+
+		AfterEach(func() {
+			usageService.Close()
+			cfService.Close()
+			uaaService.Close()
+		})
+
+		It("writes a snapshot of app and service instances to the usage service dataset", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(usageService.ReceivedRequests()).To(BeEmpty())
+
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			assertValidOutput(tarFilePath, collector_tar.UsageServiceCollectorDataSetId, collector_tar.AppUsageDataType, "development")
+			assertValidOutput(tarFilePath, collector_tar.UsageServiceCollectorDataSetId, collector_tar.ServiceUsageDataType, "development")
+			assertValidOutput(tarFilePath, collector_tar.CoreConsumptionCollectorDataSetId, collector_tar.CoreCountsDataType, "development")
+
+			tmpDir, err := os.MkdirTemp("", "")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(tmpDir)
+			Expect((&archiver.Tar{}).Unarchive(tarFilePath, tmpDir)).To(Succeed())
+
+			content, err := os.ReadFile(filepath.Join(tmpDir, collector_tar.UsageServiceCollectorDataSetId, collector_tar.AppUsageDataType))
+			Expect(err).NotTo(HaveOccurred())
+			var appUsage struct {
+				MonthlyReports []struct {
+					MaximumAppInstances int `json:"maximum_app_instances"`
+				} `json:"monthly_reports"`
+			}
+			Expect(json.Unmarshal(content, &appUsage)).To(Succeed())
+			Expect(appUsage.MonthlyReports).To(HaveLen(1))
+			Expect(appUsage.MonthlyReports[0].MaximumAppInstances).To(Equal(2))
+
+			content, err = os.ReadFile(filepath.Join(tmpDir, collector_tar.UsageServiceCollectorDataSetId, collector_tar.MetadataFileName))
+			Expect(err).NotTo(HaveOccurred())
+			var metadata collector_tar.Metadata
+			Expect(json.Unmarshal(content, &metadata)).To(Succeed())
+			Expect(metadata.FileDigests).To(HaveLen(2))
+			for _, digest := range metadata.FileDigests {
+				Expect(digest.ProductType).To(BeEmpty())
+			}
+			Expect(metadata.FileDigests[0].Name).To(Equal(collector_tar.AppUsageDataType))
+			Expect(metadata.FileDigests[0].DataType).To(Equal(consumption.AppUsageSnapshotDataType))
+			Expect(metadata.FileDigests[1].Name).To(Equal(collector_tar.ServiceUsageDataType))
+			Expect(metadata.FileDigests[1].DataType).To(Equal(consumption.ServiceUsageSnapshotDataType))
+		})
+
+		It("cannot be combined with the usage service", func() {
+			defaultEnvVars[cmd.UsageServiceURLKey] = usageService.URL()
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say(cmd.UsageSnapshotWithUsageServiceMessage))
+			assertOutputDirEmpty(outputDirPath)
+		})
+
+		It("fails when the usage service client is not configured", func() {
+			delete(defaultEnvVars, cmd.UsageServiceClientSecretKey)
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say(cmd.InvalidUsageSnapshotConfigMessage))
+			assertOutputDirEmpty(outputDirPath)
+		})
+
+		It("fails when the CF API cannot be read", func() {
+			cfService.RouteToHandler(http.MethodGet, consumption.CfProcessesPath, ghttp.RespondWith(http.StatusForbidden, ""))
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say(operations.UsageCollectFailureMessage))
+			assertOutputDirEmpty(outputDirPath)
+		})
+	})
+
+	Context("when bosh collection is enabled", func() {
+		var directorServer *ghttp.Server
+
+		BeforeEach(func() {
+			directorServer = setupBoshDirectorServer()
+
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/deployed/director/credentials/bosh_commandline_credentials", ghttp.RespondWith(http.StatusOK,
+				`{ "credential": "BOSH_CLIENT=best_client BOSH_CLIENT_SECRET=best_secret BOSH_CA_CERT=/cool/path BOSH_ENVIRONMENT=127.0.0.1 bosh "}`,
+			))
+			defaultEnvVars[cmd.WithBoshInfoKey] = "true"
+		})
+
+		AfterEach(func() {
+			directorServer.Close()
+		})
+
+		It("writes the director deployments, stemcells and releases to their own dataset", func() {
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say("Collecting data from BOSH director at https://127.0.0.1:25555"))
+
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			assertValidOutput(tarFilePath, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_vm_types", "development")
+			for _, dataType := range []string{bosh.DirectorDataType, bosh.DeploymentsDataType, bosh.StemcellsDataType, bosh.ReleasesDataType} {
+				assertValidOutput(tarFilePath, bosh.BoshCollectorDataSetId, dataType, "development")
+			}
+		})
+
+		It("writes a freshness report of the deployed stemcells and releases", func() {
+			catalogPath := filepath.Join(configDirPath, "catalog.yml")
+			Expect(os.WriteFile(catalogPath, []byte(`
+stemcells:
+- name: ubuntu-jammy
+  version: "1.351"
+  release_date: 2020-01-01
+  eol_date: 2020-06-01
+  cves: 3
+`), 0644)).To(Succeed())
+			defaultEnvVars[cmd.LifecycleCatalogKey] = catalogPath
+
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			assertValidOutput(tarFilePath, lifecycle.LifecycleDataSetId, lifecycle.FindingsDataType, "development")
+
+			tmpDir, err := os.MkdirTemp("", "")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(tmpDir)
+			Expect((&archiver.Tar{}).Unarchive(tarFilePath, tmpDir)).To(Succeed())
+
+			content, err := os.ReadFile(filepath.Join(tmpDir, lifecycle.LifecycleDataSetId, lifecycle.FindingsDataType))
+			Expect(err).NotTo(HaveOccurred())
+			var findings lifecycle.Findings
+			Expect(json.Unmarshal(content, &findings)).To(Succeed())
+			Expect(findings.Sources).To(ContainElement(lifecycle.BoshDeploymentsPath))
+			Expect(findings.Findings).To(HaveLen(2))
+			Expect(findings.Findings[0].Deployment).To(Equal("cf-abc123"))
+			Expect(findings.Findings[0].Version).To(Equal("1.351"))
+			Expect(findings.Findings[0].PastEOL).To(BeTrue())
+			Expect(findings.Findings[0].CVEs).To(Equal(3))
+			Expect(findings.Findings[1].Name).To(Equal("capi"))
+			Expect(findings.Findings[1].InCatalog).To(BeFalse())
+		})
+
+		It("fails before collecting when the lifecycle catalog is invalid", func() {
+			catalogPath := filepath.Join(configDirPath, "catalog.yml")
+			Expect(os.WriteFile(catalogPath, []byte("stemcells:\n- version: \"1.351\"\n"), 0644)).To(Succeed())
+			defaultEnvVars[cmd.LifecycleCatalogKey] = catalogPath
+
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say("stemcells 0: release_date is required"))
+			Expect(opsManagerServer.ReceivedRequests()).To(BeEmpty())
+			assertOutputDirEmpty(outputDirPath)
+		})
+
+		It("fails when the director cannot be read", func() {
+			directorServer.RouteToHandler(http.MethodGet, bosh.StemcellsPath, ghttp.RespondWith(http.StatusInternalServerError, ""))
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say(operations.BoshCollectFailureMessage))
+			assertOutputDirEmpty(outputDirPath)
+		})
+
+		It("skips the director when the Ops Manager role cannot read its credentials", func() {
+			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/deployed/director/credentials/bosh_commandline_credentials", ghttp.RespondWith(http.StatusForbidden, ""))
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(directorServer.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+
 	Context("when credhub collection is enabled", func() {
 		var credhubServer *ghttp.Server
 
@@ -790,6 +2249,23 @@ var _ = Describe("Collect", func() {
 			assertLogging(session, tarFilePath, true, false)
 		})
 
+		It("writes the Ops Manager metadata with the credhub data when only credhub is selected", func() {
+			defaultEnvVars[cmd.DatasetsKey] = "credhub"
+			command := buildDefaultCommand(defaultEnvVars)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			tarFilePath := validatedTarFilePath(outputDirPath)
+			assertValidOutput(tarFilePath, collector_tar.OpsManagerCollectorDataSetId, "p-bosh_certificates", "development")
+
+			tmpDir, err := os.MkdirTemp("", "")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(tmpDir)
+			Expect((&archiver.Tar{}).Unarchive(tarFilePath, tmpDir)).To(Succeed())
+			Expect(filepath.Join(tmpDir, collector_tar.OpsManagerCollectorDataSetId, "ops_manager_deployed_products")).NotTo(BeAnExistingFile())
+		})
+
 		It("errors if fetching credentials for credhub auth fails", func() {
 			opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/deployed/director/credentials/bosh_commandline_credentials", func(w http.ResponseWriter, req *http.Request) {
 				w.WriteHeader(500)
@@ -968,6 +2444,7 @@ var _ = Describe("Collect", func() {
 		failingServer.RouteToHandler(http.MethodPost, "/uaa/oauth/token", func(w http.ResponseWriter, req *http.Request) {
 			w.WriteHeader(500)
 		})
+		failingServer.RouteToHandler(http.MethodGet, "/api/v0/info", ghttp.RespondWith(http.StatusOK, `{"info": {"version": "3.0.10-build.1"}}`))
 		defer failingServer.Close()
 
 		defaultEnvVars[cmd.OpsManagerURLKey] = failingServer.URL()
@@ -1133,6 +2610,10 @@ func setupOpsManagerServer() *ghttp.Server {
 		_, _ = w.Write([]byte(``))
 	}
 
+	opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/info", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"info": {"version": "3.0.10-build.1"}}`))
+	})
 	opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/pending_changes", emptyObjectResponse)
 	opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/deployed/products", emptyArrayResponse)
 	opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/vm_types", emptyArrayResponse)
@@ -1141,10 +2622,52 @@ func setupOpsManagerServer() *ghttp.Server {
 	opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/deployed/certificates", emptyObjectResponse)
 	opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/certificate_authorities", emptyObjectResponse)
 	opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/download_core_consumption", emptyCSVResponse)
+	for _, settingsPath := range []string{"/api/v0/settings/ssl_certificate", "/api/v0/settings/syslog", "/api/v0/settings/banner", "/api/v0/settings/pivotal_network_settings", "/api/v0/settings/rbac"} {
+		opsManagerServer.RouteToHandler(http.MethodGet, settingsPath, emptyObjectResponse)
+	}
+	opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/stemcell_associations", emptyObjectResponse)
+	opsManagerServer.RouteToHandler(http.MethodGet, regexp.MustCompile(`^/api/v0/staged/products/[^/]+/errands$`), emptyObjectResponse)
+	opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/director/availability_zones", emptyObjectResponse)
+	opsManagerServer.RouteToHandler(http.MethodGet, "/api/v0/staged/director/networks", emptyObjectResponse)
 
 	return opsManagerServer
 }
 
+func setupBoshDirectorServer() *ghttp.Server {
+	directorServer := ghttp.NewUnstartedServer()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:25555")
+	Expect(err).NotTo(HaveOccurred())
+	directorServer.HTTPTestServer.Listener = listener
+	directorServer.HTTPTestServer.StartTLS()
+	directorServer.RouteToHandler(http.MethodGet, bosh.InfoPath, ghttp.RespondWith(http.StatusOK,
+		`{"version": "280.0.14 (00000000)", "cpi": "vsphere_cpi", "user_authentication": {"type": "uaa", "options": {"url": "https://127.0.0.1:25555/uaa"}}}`,
+	))
+	directorServer.RouteToHandler(http.MethodPost, "/uaa/oauth/token", func(w http.ResponseWriter, req *http.Request) {
+		credentials := base64.StdEncoding.EncodeToString([]byte("best_client:best_secret"))
+		Expect(req.Header.Get("Authorization")).To(Equal("Basic " + credentials))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token": "some-director-token", "token_type": "bearer", "expires_in": 3600}`))
+	})
+
+	responses := map[string]string{
+		bosh.DeploymentsPath:               `[{"name": "cf-abc123", "releases": [{"name": "capi", "version": "1.2.3"}], "stemcells": [{"name": "bosh-vsphere-esxi-ubuntu-jammy-go_agent", "version": "1.351"}]}]`,
+		"/deployments/cf-abc123/instances": `[{"job": "router", "id": "router-1", "cid": "vm-1", "expects_vm": true}]`,
+		bosh.StemcellsPath:                 `[{"name": "bosh-vsphere-esxi-ubuntu-jammy-go_agent", "operating_system": "ubuntu-jammy", "version": "1.351", "deployments": [{"name": "cf-abc123"}]}]`,
+		bosh.ReleasesPath:                  `[{"name": "capi", "release_versions": [{"version": "1.2.3", "currently_deployed": true}]}]`,
+	}
+	for endpointPath, response := range responses {
+		response := response
+		directorServer.RouteToHandler(http.MethodGet, endpointPath, func(w http.ResponseWriter, req *http.Request) {
+			Expect(req.Header.Get("Authorization")).To(Equal("Bearer some-director-token"))
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(response))
+		})
+	}
+
+	return directorServer
+}
+
 func setupCredHubServer() *ghttp.Server {
 	credhubServer := ghttp.NewUnstartedServer()
 
@@ -1197,13 +2720,17 @@ func setupUsageServerServiceHandlers(uaaService, cfService, usageService *ghttp.
 				}`))
 	})
 
+	uaaURL := uaaService.URL()
+	if uaaServiceURLOverride != "" {
+		uaaURL = uaaServiceURLOverride
+	}
+	cfService.RouteToHandler(http.MethodGet, "/", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{ "links": { "login": { "href": "https://login.example.com" }, "uaa": { "href": "` + uaaURL + `" } } }`))
+	})
 	cfService.RouteToHandler(http.MethodGet, "/v2/info", func(w http.ResponseWriter, req *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		if uaaServiceURLOverride != "" {
-			_, _ = w.Write([]byte(`{ "token_endpoint": "` + uaaServiceURLOverride + `" }`))
-		} else {
-			_, _ = w.Write([]byte(`{ "token_endpoint": "` + uaaService.URL() + `" }`))
-		}
+		_, _ = w.Write([]byte(`{ "token_endpoint": "` + uaaURL + `" }`))
 	})
 
 	usageService.RouteToHandler(http.MethodGet, "/system_report/app_usages", func(w http.ResponseWriter, req *http.Request) {
@@ -1223,6 +2750,30 @@ func setupUsageServerServiceHandlers(uaaService, cfService, usageService *ghttp.
 	})
 }
 
+func setupCfInventoryHandlers(cfService *ghttp.Server) {
+	resources := map[string]string{
+		cfinventory.AppsPath:             `[{"guid": "app-1", "state": "STARTED", "lifecycle": {"type": "buildpack", "data": {"stack": "cflinuxfs4"}}}]`,
+		cfinventory.ProcessesPath:        `[{"type": "web", "instances": 2, "relationships": {"app": {"data": {"guid": "app-1"}}}}]`,
+		cfinventory.BuildpacksPath:       `[{"name": "java_buildpack_offline", "stack": "cflinuxfs4", "position": 1, "enabled": true, "locked": false}]`,
+		cfinventory.DropletsPath:         `[{"stack": "cflinuxfs4", "buildpacks": [{"name": "java_buildpack_offline", "version": "v4.60"}], "links": {"app": {"href": "/v3/apps/app-1"}}}]`,
+		cfinventory.StacksPath:           `[{"name": "cflinuxfs4"}]`,
+		cfinventory.ServiceOfferingsPath: `[{"guid": "offering-1", "name": "p.mysql"}]`,
+		cfinventory.ServicePlansPath:     `[{"guid": "plan-1", "relationships": {"service_offering": {"data": {"guid": "offering-1"}}}}]`,
+		cfinventory.ServiceInstancesPath: `[{"guid": "si-1", "relationships": {"service_plan": {"data": {"guid": "plan-1"}}}}]`,
+	}
+	for _, endpointPath := range cfinventory.EndpointPaths {
+		endpointResources := resources[endpointPath]
+		if endpointResources == "" {
+			endpointResources = "[]"
+		}
+		cfService.RouteToHandler(http.MethodGet, endpointPath, func(w http.ResponseWriter, req *http.Request) {
+			Expect(req.Header.Get("Authorization")).To(Equal("Bearer some-uaa-token"))
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"pagination": {"total_results": 1, "next": null}, "resources": ` + endpointResources + `}`))
+		})
+	}
+}
+
 func serverWithMaxTLSVersion(version uint16) *ghttp.Server {
 	server := ghttp.NewUnstartedServer()
 	listener, err := listenOnFreePort()
@@ -0,0 +1,149 @@
+package integration
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/pivotal-cf/aqueduct-courier/cmd"
+)
+
+var _ = Describe("Config", func() {
+	var configDirPath string
+
+	BeforeEach(func() {
+		var err error
+		configDirPath, err = os.MkdirTemp("", "")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(configDirPath)).To(Succeed())
+	})
+
+	writeConfig := func(contents string) string {
+		configFile := filepath.Join(configDirPath, "config.yml")
+		Expect(os.WriteFile(configFile, []byte(contents), 0755)).To(Succeed())
+		return configFile
+	}
+
+	Describe("validate", func() {
+		It("succeeds for a valid config file and lists its profiles", func() {
+			configFile := writeConfig(`---
+env-type: production
+username: ((username))
+profiles:
+  prod-east:
+    url: https://east.example.com
+  prod-west:
+    url: https://west.example.com
+    env-type: pre-production
+`)
+
+			command := exec.Command(aqueductBinaryPath, "config", "validate", "--"+cmd.ConfigFlag, configFile, "--"+cmd.VarsEnvFlag, "MY")
+			command.Env = append(os.Environ(), "MY_username=some-username")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say("Config file .* is valid"))
+			Expect(session.Out).To(gbytes.Say("Profiles: prod-east, prod-west"))
+		})
+
+		It("reports unknown keys and wrong value types with line numbers", func() {
+			configFile := writeConfig(`---
+url: https://opsman.example.com
+usrname: typo
+ops-manager-timeout: soon
+`)
+
+			command := exec.Command(aqueductBinaryPath, "config", "validate", "--"+cmd.ConfigFlag, configFile)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say(`line 3: unknown key "usrname"`))
+			Expect(session.Err).To(gbytes.Say(`line 4: key "ops-manager-timeout" must be a number, found soon`))
+		})
+
+		It("reports an invalid env type in a profile", func() {
+			configFile := writeConfig(`---
+profiles:
+  prod-east:
+    env-type: prod
+`)
+
+			command := exec.Command(aqueductBinaryPath, "config", "validate", "--"+cmd.ConfigFlag, configFile)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say(`profile "prod-east": Invalid env-type prod`))
+		})
+
+		It("reports a missing profile", func() {
+			configFile := writeConfig(`---
+profiles:
+  prod-east:
+    env-type: production
+`)
+
+			command := exec.Command(aqueductBinaryPath, "config", "validate", "--"+cmd.ConfigFlag, configFile, "--"+cmd.ProfileFlag, "prod-west")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say(`profile "prod-west" not found in config file, available profiles: prod-east`))
+		})
+
+		It("validates an om env file and warns about the keys collect ignores", func() {
+			omEnvFile := filepath.Join(configDirPath, "env.yml")
+			Expect(os.WriteFile(omEnvFile, []byte(`---
+target: https://opsman.example.com
+username: admin
+password: some-password
+ca-cert: some-ca
+decryption-passphrase: some-passphrase
+`), 0755)).To(Succeed())
+
+			command := exec.Command(aqueductBinaryPath, "config", "validate", "--"+cmd.OmEnvFileFlag, omEnvFile)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say(fmt.Sprintf(cmd.OmEnvUnsupportedKeyWarningFormat, "ca-cert")))
+			Expect(session.Out).To(gbytes.Say(fmt.Sprintf(cmd.OmEnvUnsupportedKeyWarningFormat, "decryption-passphrase")))
+			Expect(session.Out).To(gbytes.Say("Om env file .* is valid"))
+		})
+
+		It("reports unknown keys in an om env file", func() {
+			omEnvFile := filepath.Join(configDirPath, "env.yml")
+			Expect(os.WriteFile(omEnvFile, []byte("targt: https://example.com\n"), 0755)).To(Succeed())
+
+			command := exec.Command(aqueductBinaryPath, "config", "validate", "--"+cmd.OmEnvFileFlag, omEnvFile)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say("field targt not found"))
+		})
+
+		It("requires a config file", func() {
+			command := exec.Command(aqueductBinaryPath, "config", "validate")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say(cmd.ConfigFileRequiredMessage))
+		})
+
+		It("documents the config file keys in the help", func() {
+			command := exec.Command(aqueductBinaryPath, "config", "validate", "--help")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say("CONFIG FILE KEYS"))
+			Expect(session.Out).To(gbytes.Say(`env-type\s+string`))
+			Expect(session.Out).To(gbytes.Say(`ops-manager-timeout\s+number`))
+			Expect(session.Out).To(gbytes.Say(`profiles.<name>.<key>\s+map`))
+		})
+	})
+})
@@ -0,0 +1,124 @@
+package integration
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/pivotal-cf/aqueduct-courier/cmd"
+	"github.com/pivotal-cf/aqueduct-courier/export"
+	"github.com/pivotal-cf/telemetry-utils/collector_tar"
+	"github.com/pivotal-cf/telemetry-utils/tar"
+)
+
+var _ = Describe("Export", func() {
+	var (
+		tempDir     string
+		outputDir   string
+		tarFilePath string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = os.MkdirTemp("", "")
+		Expect(err).NotTo(HaveOccurred())
+		outputDir = filepath.Join(tempDir, "export")
+		Expect(os.Mkdir(outputDir, 0755)).To(Succeed())
+		tarFilePath = filepath.Join(tempDir, "FoundationDetails_1709251200.tar")
+
+		metadata, err := json.Marshal(collector_tar.Metadata{
+			EnvType:            "production",
+			CollectedAt:        "2024-03-01T00:00:00Z",
+			FoundationId:       "foundation-guid",
+			FoundationNickname: "prod",
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		tarFile, err := os.Create(tarFilePath)
+		Expect(err).NotTo(HaveOccurred())
+		tarWriter := tar.NewTarWriter(tarFile)
+		for name, contents := range map[string]string{
+			path.Join(collector_tar.OpsManagerCollectorDataSetId, collector_tar.MetadataFileName):   string(metadata),
+			path.Join(collector_tar.UsageServiceCollectorDataSetId, collector_tar.MetadataFileName): string(metadata),
+			export.DeployedProductsPath: `[{"installation_name": "cf-0123", "guid": "cf-0123", "type": "cf", "product_version": "4.0.1"}]`,
+			export.CertificatesPath:     `{"certificates": [{"product_guid": "cf-0123", "property_reference": ".properties.certs", "issuer": "CN=a, O=b", "valid_from": "2024-01-01", "valid_until": "2026-01-01"}]}`,
+			export.AppUsagePath:         `{"monthly_reports": [{"month": 2, "year": 2024, "average_app_instances": 10.5, "maximum_app_instances": 12, "app_instance_hours": 7308}]}`,
+		} {
+			Expect(tarWriter.AddFile([]byte(contents), name)).To(Succeed())
+		}
+		Expect(tarWriter.Close()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tempDir)).To(Succeed())
+	})
+
+	runExport := func(args ...string) *gexec.Session {
+		command := exec.Command(aqueductBinaryPath, append([]string{"export"}, args...)...)
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		return session
+	}
+
+	readExport := func(table string) string {
+		contents, err := os.ReadFile(filepath.Join(outputDir, "FoundationDetails_1709251200_"+table+".csv"))
+		Expect(err).NotTo(HaveOccurred())
+		return string(contents)
+	}
+
+	It("writes a CSV file for each kind of data in the collected data sets", func() {
+		session := runExport("--"+cmd.DataTarFilePathFlag, tarFilePath, "--"+cmd.ExportFormatFlag, "csv", "--"+cmd.OutputPathFlag, outputDir)
+		Eventually(session).Should(gexec.Exit(0))
+		Expect(session.Out).To(gbytes.Say("Wrote " + filepath.Join(outputDir, "FoundationDetails_1709251200_products.csv")))
+
+		entries, err := os.ReadDir(outputDir)
+		Expect(err).NotTo(HaveOccurred())
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		Expect(names).To(ConsistOf(
+			"FoundationDetails_1709251200_products.csv",
+			"FoundationDetails_1709251200_jobs.csv",
+			"FoundationDetails_1709251200_certificates.csv",
+			"FoundationDetails_1709251200_app_usage.csv",
+			"FoundationDetails_1709251200_service_usage.csv",
+		))
+
+		Expect(readExport(export.ProductsTable)).To(Equal(
+			"foundation_nickname,foundation_id,env_type,collected_at,product_type,installation_name,guid,product_version\n" +
+				"prod,foundation-guid,production,2024-03-01T00:00:00Z,cf,cf-0123,cf-0123,4.0.1\n",
+		))
+		Expect(readExport(export.CertificatesTable)).To(ContainSubstring(
+			"prod,foundation-guid,production,2024-03-01T00:00:00Z,ops_manager,cf-0123,.properties.certs,\"CN=a, O=b\",2024-01-01,2026-01-01\n",
+		))
+		Expect(readExport(export.AppUsageTable)).To(Equal(
+			"foundation_nickname,foundation_id,env_type,collected_at,source,year,month,average_app_instances,maximum_app_instances,app_instance_hours\n" +
+				"prod,foundation-guid,production,2024-03-01T00:00:00Z,usage_service,2024,2,10.5,12,7308\n",
+		))
+	})
+
+	It("defaults to CSV", func() {
+		session := runExport("--"+cmd.DataTarFilePathFlag, tarFilePath, "--"+cmd.OutputPathFlag, outputDir)
+		Eventually(session).Should(gexec.Exit(0))
+		Expect(readExport(export.ProductsTable)).To(HavePrefix("foundation_nickname,"))
+	})
+
+	It("fails for unsupported formats before reading the data", func() {
+		session := runExport("--"+cmd.DataTarFilePathFlag, filepath.Join(tempDir, "missing.tar"), "--"+cmd.ExportFormatFlag, "xlsx", "--"+cmd.OutputPathFlag, outputDir)
+		Eventually(session).Should(gexec.Exit(1))
+		Expect(session.Err).To(gbytes.Say(`invalid export format "xlsx", valid formats are: csv`))
+	})
+
+	It("requires the data file and output directory", func() {
+		session := runExport()
+		Eventually(session).Should(gexec.Exit(1))
+		Expect(session.Err).To(gbytes.Say("Missing required flags: --" + cmd.DataTarFilePathFlag + ", --" + cmd.OutputPathFlag))
+	})
+})
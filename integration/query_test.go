@@ -0,0 +1,130 @@
+package integration
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/pivotal-cf/aqueduct-courier/cmd"
+	"github.com/pivotal-cf/telemetry-utils/collector_tar"
+	"github.com/pivotal-cf/telemetry-utils/tar"
+)
+
+var _ = Describe("Query", func() {
+	var (
+		tempDir      string
+		prodTarPath  string
+		devTarPath   string
+		unnamedPath  string
+		cfProperties string
+	)
+
+	writeCollection := func(tarFilePath, nickname, properties string) {
+		metadata, err := json.Marshal(collector_tar.Metadata{
+			FoundationId:       "guid-" + nickname,
+			FoundationNickname: nickname,
+			FileDigests: []collector_tar.FileDigest{
+				{Name: "cf_properties", ProductType: "cf", DataType: collector_tar.PropertiesDataType},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		tarFile, err := os.Create(tarFilePath)
+		Expect(err).NotTo(HaveOccurred())
+		tarWriter := tar.NewTarWriter(tarFile)
+		Expect(tarWriter.AddFile(metadata, filepath.Join(collector_tar.OpsManagerCollectorDataSetId, collector_tar.MetadataFileName))).To(Succeed())
+		Expect(tarWriter.AddFile([]byte(properties), filepath.Join(collector_tar.OpsManagerCollectorDataSetId, "cf_properties"))).To(Succeed())
+		Expect(tarWriter.Close()).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = os.MkdirTemp("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		cfProperties = `{".properties.system_domain": {"value": "sys.example.com"}, ".properties.instances": {"value": 3}}`
+		prodTarPath = filepath.Join(tempDir, "prod.tar")
+		writeCollection(prodTarPath, "prod", cfProperties)
+		devTarPath = filepath.Join(tempDir, "dev.tar")
+		writeCollection(devTarPath, "dev", `{".properties.instances": {"value": 1}}`)
+		unnamedPath = filepath.Join(tempDir, "unnamed.tar")
+		writeCollection(unnamedPath, "", `{}`)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tempDir)).To(Succeed())
+	})
+
+	runQuery := func(args ...string) *gexec.Session {
+		command := exec.Command(aqueductBinaryPath, append([]string{"query"}, args...)...)
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		return session
+	}
+
+	It("writes the results of the expression over the collected files", func() {
+		session := runQuery("--"+cmd.DataTarFilePathFlag, prodTarPath, `.cf.properties | keys`)
+		Eventually(session).Should(gexec.Exit(0))
+		Expect(string(session.Out.Contents())).To(Equal("[\n  \".properties.instances\",\n  \".properties.system_domain\"\n]\n"))
+	})
+
+	It("writes compact and raw results", func() {
+		session := runQuery("--"+cmd.DataTarFilePathFlag, prodTarPath, "--"+cmd.CompactOutputFlag, `.cf.properties[".properties.instances"]`)
+		Eventually(session).Should(gexec.Exit(0))
+		Expect(string(session.Out.Contents())).To(Equal("{\"value\":3}\n"))
+
+		session = runQuery("--"+cmd.DataTarFilePathFlag, prodTarPath, "--"+cmd.RawOutputFlag, `.cf.properties[".properties.system_domain"].value, $foundation_nickname`)
+		Eventually(session).Should(gexec.Exit(0))
+		Expect(string(session.Out.Contents())).To(Equal("sys.example.com\nprod\n"))
+	})
+
+	It("labels the results from several collections with their foundation", func() {
+		session := runQuery(
+			"--"+cmd.DataTarFilePathFlag, prodTarPath,
+			"--"+cmd.DataTarFilePathFlag, devTarPath,
+			"--"+cmd.CompactOutputFlag,
+			`.cf.properties[".properties.instances"].value`,
+		)
+		Eventually(session).Should(gexec.Exit(0))
+		Expect(session.Out).To(gbytes.Say(`{"foundation_nickname":"prod","foundation_id":"guid-prod","path":"` + prodTarPath + `","result":3}\n`))
+		Expect(session.Out).To(gbytes.Say(`{"foundation_nickname":"dev","foundation_id":"guid-dev","path":"` + devTarPath + `","result":1}\n`))
+	})
+
+	It("labels raw results with the nickname, or the foundation id without one", func() {
+		session := runQuery(
+			"--"+cmd.DataTarFilePathFlag, prodTarPath,
+			"--"+cmd.DataTarFilePathFlag, unnamedPath,
+			"--"+cmd.RawOutputFlag,
+			`.cf.properties | length`,
+		)
+		Eventually(session).Should(gexec.Exit(0))
+		Expect(string(session.Out.Contents())).To(Equal("prod\t2\nguid-\t0\n"))
+	})
+
+	It("fails before reading any data when the expression is invalid", func() {
+		session := runQuery("--"+cmd.DataTarFilePathFlag, filepath.Join(tempDir, "missing.tar"), `.cf[`)
+		Eventually(session).Should(gexec.Exit(1))
+		Expect(session.Err).To(gbytes.Say("invalid query at column 5: unexpected end of the query"))
+	})
+
+	It("fails with the path of the collection the expression fails on", func() {
+		session := runQuery("--"+cmd.DataTarFilePathFlag, prodTarPath, `.cf.properties | error("stop")`)
+		Eventually(session).Should(gexec.Exit(1))
+		Expect(session.Err).To(gbytes.Say("Query failed on " + prodTarPath + ": stop"))
+	})
+
+	It("requires a path and exactly one expression", func() {
+		session := runQuery(`.`)
+		Eventually(session).Should(gexec.Exit(1))
+		Expect(session.Err).To(gbytes.Say("Missing required flags: --" + cmd.DataTarFilePathFlag))
+
+		session = runQuery("--"+cmd.DataTarFilePathFlag, prodTarPath)
+		Eventually(session).Should(gexec.Exit(1))
+		Expect(session.Err).To(gbytes.Say(cmd.QueryExpressionRequiredMessage))
+	})
+})
@@ -0,0 +1,105 @@
+package integration
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/pivotal-cf/aqueduct-courier/cmd"
+	"github.com/pivotal-cf/aqueduct-courier/lifecycle"
+	"github.com/pivotal-cf/telemetry-utils/tar"
+)
+
+var _ = Describe("Stemcells", func() {
+	var (
+		tempDir     string
+		tarFilePath string
+		catalogPath string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = os.MkdirTemp("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		tarFilePath = filepath.Join(tempDir, "data.tar")
+		tarFile, err := os.Create(tarFilePath)
+		Expect(err).NotTo(HaveOccurred())
+		tarWriter := tar.NewTarWriter(tarFile)
+		Expect(tarWriter.AddFile([]byte(`[{"installation_name": "cf-abc123", "type": "cf"}, {"installation_name": "pivotal-mysql-xyz", "type": "pivotal-mysql"}]`), lifecycle.DeployedProductsPath)).To(Succeed())
+		Expect(tarWriter.AddFile([]byte(`{"added_products": {"deployed": [
+			{"name": "cf", "stemcell": "bosh-stemcell-1.340-vsphere-esxi-ubuntu-jammy-go_agent.tgz"},
+			{"name": "pivotal-mysql", "stemcell": "bosh-stemcell-1.351-vsphere-esxi-ubuntu-jammy-go_agent.tgz"},
+			{"name": "p-isolation-segment", "stemcell": "bosh-stemcell-1.300-vsphere-esxi-ubuntu-jammy-go_agent.tgz"}
+		]}}`), lifecycle.DiagnosticReportPath)).To(Succeed())
+		Expect(tarWriter.Close()).To(Succeed())
+
+		catalogPath = filepath.Join(tempDir, "catalog.yml")
+		Expect(os.WriteFile(catalogPath, []byte(`
+stemcells:
+- name: ubuntu-jammy
+  version: "1.340"
+  release_date: 2020-01-01
+  eol_date: 2020-06-01
+  cves: 14
+- name: ubuntu-jammy
+  version: "1.351"
+  release_date: 2021-01-01
+`), 0644)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tempDir)).To(Succeed())
+	})
+
+	runStemcells := func(args ...string) *gexec.Session {
+		command := exec.Command(aqueductBinaryPath, append([]string{"stemcells"}, args...)...)
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		return session
+	}
+
+	It("lists the deployments using stemcells past end of life", func() {
+		session := runStemcells("--"+cmd.DataTarFilePathFlag, tarFilePath, "--"+cmd.LifecycleCatalogFlag, catalogPath)
+		Eventually(session).Should(gexec.Exit(0))
+
+		Expect(session.Out).To(gbytes.Say(`DEPLOYMENT\s+STEMCELL\s+VERSION\s+RELEASED\s+AGE \(DAYS\)\s+EOL\s+CVES\s+REASON`))
+		Expect(session.Out).To(gbytes.Say(`cf-abc123\s+bosh-vsphere-esxi-ubuntu-jammy-go_agent\s+1.340\s+2020-01-01\s+\d+\s+2020-06-01\s+14\s+past end of life`))
+		Expect(session.Out).To(gbytes.Say(`1 of 3 deployments use outdated stemcells`))
+		Expect(session.Out).To(gbytes.Say(`Stemcells missing from the lifecycle catalog:\n  bosh-vsphere-esxi-ubuntu-jammy-go_agent 1.300 \(p-isolation-segment\)`))
+		Expect(string(session.Out.Contents())).NotTo(ContainSubstring("pivotal-mysql-xyz"))
+	})
+
+	It("also lists the deployments using stemcells older than a number of days", func() {
+		session := runStemcells("--"+cmd.DataTarFilePathFlag, tarFilePath, "--"+cmd.LifecycleCatalogFlag, catalogPath, "--"+cmd.OlderThanDaysFlag, "30")
+		Eventually(session).Should(gexec.Exit(0))
+
+		Expect(session.Out).To(gbytes.Say(`cf-abc123\s+.*past end of life, older than 30 days`))
+		Expect(session.Out).To(gbytes.Say(`pivotal-mysql-xyz\s+bosh-vsphere-esxi-ubuntu-jammy-go_agent\s+1.351\s+2021-01-01\s+\d+\s+0\s+older than 30 days`))
+		Expect(session.Out).To(gbytes.Say(`2 of 3 deployments use outdated stemcells`))
+	})
+
+	It("reports when no deployment uses outdated stemcells", func() {
+		Expect(os.WriteFile(catalogPath, []byte("stemcells: []\n"), 0644)).To(Succeed())
+
+		session := runStemcells("--"+cmd.DataTarFilePathFlag, tarFilePath, "--"+cmd.LifecycleCatalogFlag, catalogPath, "--"+cmd.OlderThanDaysFlag, "30")
+		Eventually(session).Should(gexec.Exit(0))
+		Expect(session.Out).To(gbytes.Say(`No deployments use stemcells older than 30 days or past end of life`))
+	})
+
+	It("requires the data file and the catalog", func() {
+		session := runStemcells()
+		Eventually(session).Should(gexec.Exit(1))
+		Expect(session.Err).To(gbytes.Say("Missing required flags: --path, --lifecycle-catalog"))
+	})
+
+	It("fails when the data file does not exist", func() {
+		session := runStemcells("--"+cmd.DataTarFilePathFlag, filepath.Join(tempDir, "missing.tar"), "--"+cmd.LifecycleCatalogFlag, catalogPath)
+		Eventually(session).Should(gexec.Exit(1))
+		Expect(session.Err).To(gbytes.Say("File not found at: "))
+	})
+})
@@ -0,0 +1,130 @@
+package lifecycle
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+)
+
+const (
+	LifecycleDataSetId = "lifecycle"
+	FindingsDataType   = "freshness_findings"
+	StemcellComponent  = "stemcell"
+	ReleaseComponent   = "release"
+
+	NoVersionSourcesWarning = "Warning: Neither the Ops Manager diagnostic report nor the BOSH director deployments were collected, the freshness findings are empty"
+)
+
+// Finding records how old a stemcell or release version used by a deployment
+// is. Versions missing from the catalog are reported with InCatalog false and
+// no dates.
+type Finding struct {
+	Deployment  string `json:"deployment"`
+	Product     string `json:"product,omitempty"`
+	Component   string `json:"component"`
+	Name        string `json:"name"`
+	OS          string `json:"os,omitempty"`
+	Version     string `json:"version"`
+	InCatalog   bool   `json:"in_catalog"`
+	ReleaseDate string `json:"release_date,omitempty"`
+	EOLDate     string `json:"eol_date,omitempty"`
+	AgeDays     int    `json:"age_days"`
+	PastEOL     bool   `json:"past_eol"`
+	CVEs        int    `json:"cves"`
+}
+
+// OlderThan reports whether the version was released more than days ago. It
+// is false for versions missing from the catalog, or when days is not
+// positive.
+func (f Finding) OlderThan(days int) bool {
+	return f.InCatalog && days > 0 && f.AgeDays > days
+}
+
+type Findings struct {
+	EvaluatedAt string    `json:"evaluated_at"`
+	Sources     []string  `json:"sources"`
+	Findings    []Finding `json:"findings"`
+}
+
+// Analyzer compares the stemcell and release versions found in a collection
+// against a lifecycle catalog
+type Analyzer struct {
+	logger  *log.Logger
+	catalog Catalog
+	now     func() time.Time
+}
+
+func NewAnalyzer(logger *log.Logger, catalog Catalog, now func() time.Time) *Analyzer {
+	return &Analyzer{logger: logger, catalog: catalog, now: now}
+}
+
+// Analyze returns the freshness findings file for the collected files, keyed
+// by their path within the collection
+func (a *Analyzer) Analyze(files map[string][]byte) ([]Data, error) {
+	findings, err := a.Findings(files)
+	if err != nil {
+		return []Data{}, err
+	}
+	if len(findings.Sources) == 0 {
+		a.logger.Print(NoVersionSourcesWarning)
+	}
+
+	contents, err := json.Marshal(findings)
+	if err != nil {
+		return []Data{}, err
+	}
+	return []Data{NewData(bytes.NewReader(contents), FindingsDataType)}, nil
+}
+
+// Findings lists the stemcells and releases of every deployment, with their
+// age and end of life status from the catalog
+func (a *Analyzer) Findings(files map[string][]byte) (Findings, error) {
+	deployments, sources, err := deployments(files)
+	if err != nil {
+		return Findings{}, err
+	}
+
+	now := a.now().UTC()
+	result := Findings{EvaluatedAt: now.Format(time.RFC3339), Sources: sources, Findings: []Finding{}}
+	if result.Sources == nil {
+		result.Sources = []string{}
+	}
+	for _, deployment := range deployments {
+		for _, stemcell := range deployment.Stemcells {
+			finding := Finding{Deployment: deployment.Name, Product: deployment.Product, Component: StemcellComponent, Name: stemcell.Name, OS: stemcell.OS, Version: stemcell.Version}
+			if entry, ok := a.catalog.stemcell(stemcell); ok {
+				finding.applyEntry(entry, now)
+			}
+			result.Findings = append(result.Findings, finding)
+		}
+		for _, release := range deployment.Releases {
+			finding := Finding{Deployment: deployment.Name, Product: deployment.Product, Component: ReleaseComponent, Name: release.Name, Version: release.Version}
+			if entry, ok := a.catalog.release(release.Name, release.Version); ok {
+				finding.applyEntry(entry, now)
+			}
+			result.Findings = append(result.Findings, finding)
+		}
+	}
+
+	sort.SliceStable(result.Findings, func(i, j int) bool {
+		if result.Findings[i].Deployment != result.Findings[j].Deployment {
+			return result.Findings[i].Deployment < result.Findings[j].Deployment
+		}
+		if result.Findings[i].Component != result.Findings[j].Component {
+			return result.Findings[i].Component == StemcellComponent
+		}
+		return result.Findings[i].Name < result.Findings[j].Name
+	})
+	return result, nil
+}
+
+func (f *Finding) applyEntry(entry CatalogEntry, now time.Time) {
+	f.InCatalog = true
+	f.ReleaseDate = entry.ReleaseDate
+	f.EOLDate = entry.EOLDate
+	f.CVEs = entry.CVEs
+	f.AgeDays = int(now.Sub(entry.released).Hours() / 24)
+	f.PastEOL = entry.EOLDate != "" && now.After(entry.eol)
+}
@@ -0,0 +1,192 @@
+package lifecycle_test
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotal-cf/aqueduct-courier/lifecycle"
+)
+
+var _ = Describe("Analyzer", func() {
+	var (
+		bufferedOutput *gbytes.Buffer
+		analyzer       *Analyzer
+		files          map[string][]byte
+	)
+
+	BeforeEach(func() {
+		tempDir, err := os.MkdirTemp("", "")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(tempDir)
+
+		catalogPath := filepath.Join(tempDir, "catalog.yml")
+		Expect(os.WriteFile(catalogPath, []byte(`
+stemcells:
+- name: ubuntu-jammy
+  version: "1.340"
+  release_date: 2024-01-01
+  eol_date: 2024-05-01
+  cves: 7
+- version: "1.351"
+  release_date: 2024-04-01
+- name: bosh-vsphere-esxi-ubuntu-jammy-go_agent
+  version: "1.351"
+  release_date: 2024-04-02
+  eol_date: 2025-04-01
+releases:
+- name: capi
+  version: "1.2.3"
+  release_date: 2024-03-01
+  cves: 2
+`), 0644)).To(Succeed())
+		catalog, err := LoadCatalog(catalogPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		bufferedOutput = gbytes.NewBuffer()
+		now := func() time.Time { return time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC) }
+		analyzer = NewAnalyzer(log.New(bufferedOutput, "", 0), catalog, now)
+
+		files = map[string][]byte{
+			DeployedProductsPath: []byte(`[
+				{"installation_name": "p-bosh", "guid": "p-bosh-guid", "type": "p-bosh"},
+				{"installation_name": "cf-abc123", "guid": "cf-abc123", "type": "cf"},
+				{"installation_name": "pivotal-mysql-xyz", "guid": "pivotal-mysql-xyz", "type": "pivotal-mysql"}
+			]`),
+			DiagnosticReportPath: []byte(`{"added_products": {"deployed": [
+				{"name": "cf", "version": "2.0.0", "stemcell": "bosh-stemcell-1.340-vsphere-esxi-ubuntu-jammy-go_agent.tgz"},
+				{"name": "pivotal-mysql", "version": "3.0.0", "stemcell": "light-bosh-stemcell-1.400-google-kvm-ubuntu-jammy-go_agent.tgz"},
+				{"name": "p-bosh", "version": "3.0.0"}
+			]}}`),
+		}
+	})
+
+	It("reads the stemcell of each product from the diagnostic report", func() {
+		findings, err := analyzer.Findings(files)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(findings.EvaluatedAt).To(Equal("2024-06-01T12:00:00Z"))
+		Expect(findings.Sources).To(Equal([]string{DiagnosticReportPath, DeployedProductsPath}))
+		Expect(findings.Findings).To(Equal([]Finding{
+			{
+				Deployment:  "cf-abc123",
+				Product:     "cf",
+				Component:   StemcellComponent,
+				Name:        "bosh-vsphere-esxi-ubuntu-jammy-go_agent",
+				OS:          "ubuntu-jammy",
+				Version:     "1.340",
+				InCatalog:   true,
+				ReleaseDate: "2024-01-01",
+				EOLDate:     "2024-05-01",
+				AgeDays:     152,
+				PastEOL:     true,
+				CVEs:        7,
+			},
+			{
+				Deployment: "pivotal-mysql-xyz",
+				Product:    "pivotal-mysql",
+				Component:  StemcellComponent,
+				Name:       "bosh-google-kvm-ubuntu-jammy-go_agent",
+				OS:         "ubuntu-jammy",
+				Version:    "1.400",
+			},
+		}))
+	})
+
+	It("prefers the BOSH director deployments, which include releases", func() {
+		files[BoshDeploymentsPath] = []byte(`[
+			{"name": "cf-abc123", "releases": [{"name": "routing", "version": "0.280.0"}, {"name": "capi", "version": "1.2.3"}], "stemcells": [{"name": "bosh-vsphere-esxi-ubuntu-jammy-go_agent", "version": "1.351"}], "instance_groups": []}
+		]`)
+
+		findings, err := analyzer.Findings(files)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(findings.Sources).To(Equal([]string{BoshDeploymentsPath, DeployedProductsPath}))
+		Expect(findings.Findings).To(HaveLen(3))
+		Expect(findings.Findings[0]).To(Equal(Finding{
+			Deployment:  "cf-abc123",
+			Product:     "cf",
+			Component:   StemcellComponent,
+			Name:        "bosh-vsphere-esxi-ubuntu-jammy-go_agent",
+			OS:          "ubuntu-jammy",
+			Version:     "1.351",
+			InCatalog:   true,
+			ReleaseDate: "2024-04-02",
+			EOLDate:     "2025-04-01",
+			AgeDays:     60,
+		}))
+		Expect(findings.Findings[1]).To(Equal(Finding{
+			Deployment:  "cf-abc123",
+			Product:     "cf",
+			Component:   ReleaseComponent,
+			Name:        "capi",
+			Version:     "1.2.3",
+			InCatalog:   true,
+			ReleaseDate: "2024-03-01",
+			AgeDays:     92,
+			CVEs:        2,
+		}))
+		Expect(findings.Findings[2].Name).To(Equal("routing"))
+		Expect(findings.Findings[2].InCatalog).To(BeFalse())
+	})
+
+	It("matches stemcell entries without a name on any operating system", func() {
+		files[BoshDeploymentsPath] = []byte(`[
+			{"name": "windows-cells", "releases": [], "stemcells": [{"name": "bosh-vsphere-esxi-windows2019-go_agent", "version": "1.351"}]}
+		]`)
+
+		findings, err := analyzer.Findings(files)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(findings.Findings).To(HaveLen(1))
+		Expect(findings.Findings[0].OS).To(Equal("windows2019"))
+		Expect(findings.Findings[0].ReleaseDate).To(Equal("2024-04-01"))
+	})
+
+	It("reports stemcells older than a number of days", func() {
+		findings, err := analyzer.Findings(files)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(findings.Findings[0].OlderThan(150)).To(BeTrue())
+		Expect(findings.Findings[0].OlderThan(152)).To(BeFalse())
+		Expect(findings.Findings[0].OlderThan(0)).To(BeFalse())
+		Expect(findings.Findings[1].OlderThan(1)).To(BeFalse())
+	})
+
+	It("writes the findings as a json file", func() {
+		data, err := analyzer.Analyze(files)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(HaveLen(1))
+		Expect(data[0].Name()).To(Equal(FindingsDataType))
+
+		contents, err := io.ReadAll(data[0].Content())
+		Expect(err).NotTo(HaveOccurred())
+		var findings Findings
+		Expect(json.Unmarshal(contents, &findings)).To(Succeed())
+		Expect(findings.Findings).To(HaveLen(2))
+		Expect(string(contents)).To(ContainSubstring(`"past_eol":true`))
+	})
+
+	It("warns and writes empty findings when no versions were collected", func() {
+		data, err := analyzer.Analyze(map[string][]byte{})
+		Expect(err).NotTo(HaveOccurred())
+
+		contents, err := io.ReadAll(data[0].Content())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(contents).To(MatchJSON(`{"evaluated_at": "2024-06-01T12:00:00Z", "sources": [], "findings": []}`))
+		Expect(bufferedOutput).To(gbytes.Say(NoVersionSourcesWarning))
+	})
+
+	It("errors when a source cannot be parsed", func() {
+		files[DiagnosticReportPath] = []byte("not-json")
+
+		_, err := analyzer.Analyze(files)
+		Expect(err).To(MatchError(ContainSubstring("error parsing " + DiagnosticReportPath)))
+	})
+})
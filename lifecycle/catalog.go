@@ -0,0 +1,119 @@
+package lifecycle
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DateLayout = "2006-01-02"
+
+	ReadCatalogErrorFormat           = "could not read lifecycle catalog %s"
+	InvalidCatalogErrorFormat        = "lifecycle catalog %s is invalid:\n%s"
+	CatalogEntryWithoutVersionFormat = "%s %d: version is required"
+	CatalogEntryWithoutNameFormat    = "%s %d: name is required"
+	CatalogEntryInvalidDateFormat    = "%s %d: %s %q is not a YYYY-MM-DD date"
+	CatalogEntryWithoutReleaseFormat = "%s %d: release_date is required"
+)
+
+// Catalog lists the release date, end of life date and known CVE count of
+// stemcell and release versions. It is maintained by the user, since the
+// collector does not contact any service outside the foundation.
+type Catalog struct {
+	Stemcells []CatalogEntry `yaml:"stemcells"`
+	Releases  []CatalogEntry `yaml:"releases"`
+}
+
+// CatalogEntry describes one version. The name of a stemcell entry may be
+// the full stemcell name, its operating system such as ubuntu-jammy, or empty
+// to match the version on every operating system.
+type CatalogEntry struct {
+	Name        string `yaml:"name"`
+	Version     string `yaml:"version"`
+	ReleaseDate string `yaml:"release_date"`
+	EOLDate     string `yaml:"eol_date"`
+	CVEs        int    `yaml:"cves"`
+
+	released time.Time
+	eol      time.Time
+}
+
+// LoadCatalog reads a YAML or JSON lifecycle catalog and validates every
+// entry, reporting all problems at once
+func LoadCatalog(path string) (Catalog, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return Catalog{}, errors.Wrapf(err, ReadCatalogErrorFormat, path)
+	}
+
+	var catalog Catalog
+	if err := yaml.Unmarshal(contents, &catalog); err != nil {
+		return Catalog{}, errors.Wrapf(err, ReadCatalogErrorFormat, path)
+	}
+
+	if problems := catalog.validate(); len(problems) > 0 {
+		return Catalog{}, errors.Errorf(InvalidCatalogErrorFormat, path, "  "+strings.Join(problems, "\n  "))
+	}
+	return catalog, nil
+}
+
+func (c *Catalog) validate() []string {
+	var problems []string
+	for i := range c.Stemcells {
+		problems = append(problems, c.Stemcells[i].validate("stemcells", i, false)...)
+	}
+	for i := range c.Releases {
+		problems = append(problems, c.Releases[i].validate("releases", i, true)...)
+	}
+	return problems
+}
+
+func (e *CatalogEntry) validate(section string, index int, nameRequired bool) []string {
+	var problems []string
+	if e.Version == "" {
+		problems = append(problems, fmt.Sprintf(CatalogEntryWithoutVersionFormat, section, index))
+	}
+	if nameRequired && e.Name == "" {
+		problems = append(problems, fmt.Sprintf(CatalogEntryWithoutNameFormat, section, index))
+	}
+
+	var err error
+	if e.ReleaseDate == "" {
+		problems = append(problems, fmt.Sprintf(CatalogEntryWithoutReleaseFormat, section, index))
+	} else if e.released, err = time.Parse(DateLayout, e.ReleaseDate); err != nil {
+		problems = append(problems, fmt.Sprintf(CatalogEntryInvalidDateFormat, section, index, "release_date", e.ReleaseDate))
+	}
+	if e.EOLDate != "" {
+		if e.eol, err = time.Parse(DateLayout, e.EOLDate); err != nil {
+			problems = append(problems, fmt.Sprintf(CatalogEntryInvalidDateFormat, section, index, "eol_date", e.EOLDate))
+		}
+	}
+	return problems
+}
+
+// stemcell finds the entry for a stemcell version, preferring entries naming
+// the stemcell over those naming its operating system or no name at all
+func (c Catalog) stemcell(s Stemcell) (CatalogEntry, bool) {
+	for _, candidate := range []string{s.Name, s.OS, ""} {
+		for _, entry := range c.Stemcells {
+			if entry.Version == s.Version && entry.Name == candidate {
+				return entry, true
+			}
+		}
+	}
+	return CatalogEntry{}, false
+}
+
+func (c Catalog) release(name, version string) (CatalogEntry, bool) {
+	for _, entry := range c.Releases {
+		if entry.Name == name && entry.Version == version {
+			return entry, true
+		}
+	}
+	return CatalogEntry{}, false
+}
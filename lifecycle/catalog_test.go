@@ -0,0 +1,97 @@
+package lifecycle_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotal-cf/aqueduct-courier/lifecycle"
+)
+
+var _ = Describe("LoadCatalog", func() {
+	var (
+		tempDir     string
+		catalogPath string
+	)
+
+	writeCatalog := func(contents string) {
+		Expect(os.WriteFile(catalogPath, []byte(contents), 0644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = os.MkdirTemp("", "")
+		Expect(err).NotTo(HaveOccurred())
+		catalogPath = filepath.Join(tempDir, "catalog.yml")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tempDir)).To(Succeed())
+	})
+
+	It("reads stemcell and release entries", func() {
+		writeCatalog(`
+stemcells:
+- name: ubuntu-jammy
+  version: 1.351
+  release_date: 2024-02-01
+  eol_date: 2024-08-01
+  cves: 12
+releases:
+- name: capi
+  version: "1.2.3"
+  release_date: "2024-01-10"
+`)
+
+		catalog, err := LoadCatalog(catalogPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(catalog.Stemcells).To(HaveLen(1))
+		Expect(catalog.Stemcells[0].Version).To(Equal("1.351"))
+		Expect(catalog.Stemcells[0].ReleaseDate).To(Equal("2024-02-01"))
+		Expect(catalog.Stemcells[0].EOLDate).To(Equal("2024-08-01"))
+		Expect(catalog.Stemcells[0].CVEs).To(Equal(12))
+		Expect(catalog.Releases).To(HaveLen(1))
+		Expect(catalog.Releases[0].Name).To(Equal("capi"))
+	})
+
+	It("reads JSON catalogs", func() {
+		writeCatalog(`{"stemcells": [{"version": "1.351", "release_date": "2024-02-01"}]}`)
+
+		catalog, err := LoadCatalog(catalogPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(catalog.Stemcells).To(HaveLen(1))
+	})
+
+	It("reports every invalid entry", func() {
+		writeCatalog(`
+stemcells:
+- release_date: 2024-02-01
+- version: "1.340"
+  release_date: 01/02/2024
+  eol_date: soon
+releases:
+- version: "1.2.3"
+`)
+
+		_, err := LoadCatalog(catalogPath)
+		Expect(err).To(MatchError(ContainSubstring("lifecycle catalog " + catalogPath + " is invalid")))
+		Expect(err).To(MatchError(ContainSubstring("stemcells 0: version is required")))
+		Expect(err).To(MatchError(ContainSubstring(`stemcells 1: release_date "01/02/2024" is not a YYYY-MM-DD date`)))
+		Expect(err).To(MatchError(ContainSubstring(`stemcells 1: eol_date "soon" is not a YYYY-MM-DD date`)))
+		Expect(err).To(MatchError(ContainSubstring("releases 0: name is required")))
+		Expect(err).To(MatchError(ContainSubstring("releases 0: release_date is required")))
+	})
+
+	It("errors when the catalog cannot be read", func() {
+		_, err := LoadCatalog(catalogPath)
+		Expect(err).To(MatchError(ContainSubstring("could not read lifecycle catalog " + catalogPath)))
+	})
+
+	It("errors when the catalog is not YAML", func() {
+		writeCatalog("stemcells: [")
+
+		_, err := LoadCatalog(catalogPath)
+		Expect(err).To(MatchError(ContainSubstring("could not read lifecycle catalog " + catalogPath)))
+	})
+})
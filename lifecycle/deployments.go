@@ -0,0 +1,137 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"path"
+	"regexp"
+	"sort"
+
+	"github.com/pivotal-cf/aqueduct-courier/bosh"
+	"github.com/pivotal-cf/aqueduct-courier/opsmanager"
+	"github.com/pivotal-cf/telemetry-utils/collector_tar"
+	"github.com/pkg/errors"
+)
+
+const UnmarshalSourceErrorFormat = "error parsing %s"
+
+var (
+	DiagnosticReportPath = path.Join(collector_tar.OpsManagerCollectorDataSetId, opsmanager.NewData(nil, collector_tar.OpsManagerProductType, collector_tar.DiagnosticReportDataType).Name())
+	DeployedProductsPath = path.Join(collector_tar.OpsManagerCollectorDataSetId, opsmanager.NewData(nil, collector_tar.OpsManagerProductType, collector_tar.DeployedProductsDataType).Name())
+	BoshDeploymentsPath  = path.Join(bosh.BoshCollectorDataSetId, bosh.NewData(nil, bosh.DeploymentsDataType).Name())
+
+	stemcellFilenamePattern = regexp.MustCompile(`^(?:light-)?bosh-stemcell-([^-]+)-(.+)\.tgz$`)
+	stemcellOSPattern       = regexp.MustCompile(`(ubuntu-[a-z]+|windows[0-9]+|centos-[0-9]+)`)
+)
+
+type Stemcell struct {
+	Name    string
+	OS      string
+	Version string
+}
+
+// Deployment is a BOSH deployment with the stemcells and releases it uses.
+// Releases are only known when the BOSH director data was collected.
+type Deployment struct {
+	Name      string
+	Product   string
+	Stemcells []Stemcell
+	Releases  []bosh.NameVersion
+}
+
+type diagnosticReport struct {
+	AddedProducts struct {
+		Deployed []struct {
+			Name     string `json:"name"`
+			Stemcell string `json:"stemcell"`
+		} `json:"deployed"`
+	} `json:"added_products"`
+}
+
+type deployedProduct struct {
+	InstallationName string `json:"installation_name"`
+	Type             string `json:"type"`
+}
+
+type boshDeployment struct {
+	Name      string             `json:"name"`
+	Releases  []bosh.NameVersion `json:"releases"`
+	Stemcells []bosh.NameVersion `json:"stemcells"`
+}
+
+// deployments reads the deployments from the collected files. The BOSH
+// director deployments are used when they were collected, since they include
+// releases. Otherwise the stemcell of each product is read from the Ops
+// Manager diagnostic report. The sources used are returned with the
+// deployments.
+func deployments(files map[string][]byte) ([]Deployment, []string, error) {
+	products := map[string]string{}
+	if contents, ok := files[DeployedProductsPath]; ok {
+		var deployedProducts []deployedProduct
+		if err := json.Unmarshal(contents, &deployedProducts); err != nil {
+			return nil, nil, errors.Wrapf(err, UnmarshalSourceErrorFormat, DeployedProductsPath)
+		}
+		for _, product := range deployedProducts {
+			products[product.Type] = product.InstallationName
+		}
+	}
+
+	var result []Deployment
+	var sources []string
+	if contents, ok := files[BoshDeploymentsPath]; ok {
+		var boshDeployments []boshDeployment
+		if err := json.Unmarshal(contents, &boshDeployments); err != nil {
+			return nil, nil, errors.Wrapf(err, UnmarshalSourceErrorFormat, BoshDeploymentsPath)
+		}
+
+		productTypes := map[string]string{}
+		for productType, installationName := range products {
+			productTypes[installationName] = productType
+		}
+		for _, d := range boshDeployments {
+			deployment := Deployment{Name: d.Name, Product: productTypes[d.Name], Releases: d.Releases}
+			for _, s := range d.Stemcells {
+				deployment.Stemcells = append(deployment.Stemcells, Stemcell{Name: s.Name, OS: stemcellOS(s.Name), Version: s.Version})
+			}
+			result = append(result, deployment)
+		}
+		sources = append(sources, BoshDeploymentsPath)
+	} else if contents, ok := files[DiagnosticReportPath]; ok {
+		var report diagnosticReport
+		if err := json.Unmarshal(contents, &report); err != nil {
+			return nil, nil, errors.Wrapf(err, UnmarshalSourceErrorFormat, DiagnosticReportPath)
+		}
+
+		for _, product := range report.AddedProducts.Deployed {
+			deployment := Deployment{Name: products[product.Name], Product: product.Name}
+			if deployment.Name == "" {
+				deployment.Name = product.Name
+			}
+			if stemcell, ok := stemcellFromFilename(product.Stemcell); ok {
+				deployment.Stemcells = []Stemcell{stemcell}
+			}
+			result = append(result, deployment)
+		}
+		sources = append(sources, DiagnosticReportPath)
+	}
+	if _, ok := files[DeployedProductsPath]; ok && len(sources) > 0 {
+		sources = append(sources, DeployedProductsPath)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, sources, nil
+}
+
+// stemcellFromFilename reads the stemcell from the tarball name Ops Manager
+// reports, such as bosh-stemcell-1.351-vsphere-esxi-ubuntu-jammy-go_agent.tgz
+func stemcellFromFilename(filename string) (Stemcell, bool) {
+	matches := stemcellFilenamePattern.FindStringSubmatch(filename)
+	if matches == nil {
+		return Stemcell{}, false
+	}
+	name := "bosh-" + matches[2]
+	return Stemcell{Name: name, OS: stemcellOS(name), Version: matches[1]}, true
+}
+
+func stemcellOS(name string) string {
+	return stemcellOSPattern.FindString(name)
+}
@@ -0,0 +1,175 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	LintDataSetId    = "lint"
+	FindingsDataType = "lint_findings"
+
+	UnparseableFileWarningFormat = "Warning: Lint rules skipped %s, which is not JSON"
+	NoFindingsFormat             = "No lint findings from %d rules"
+	FindingsSummaryFormat        = "%d lint findings from %d rules: %d errors, %d warnings, %d info"
+	findingsTableHeader          = "SEVERITY\tRULE\tFILE\tMATCH\tDESCRIPTION"
+)
+
+// Finding is a value a rule reported. Path is the normalized JSON path to the
+// value within the file, and Label is read from the value when the rule
+// names one.
+type Finding struct {
+	ID          string `json:"id"`
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+	File        string `json:"file"`
+	Path        string `json:"path"`
+	Label       string `json:"label,omitempty"`
+}
+
+type Findings struct {
+	EvaluatedAt    string    `json:"evaluated_at"`
+	EnvType        string    `json:"env_type"`
+	RulesEvaluated int       `json:"rules_evaluated"`
+	Findings       []Finding `json:"findings"`
+}
+
+// Analyzer runs lint rules over the collected files
+type Analyzer struct {
+	logger  *log.Logger
+	rules   []Rule
+	envType string
+	now     func() time.Time
+}
+
+func NewAnalyzer(logger *log.Logger, rules []Rule, envType string, now func() time.Time) *Analyzer {
+	return &Analyzer{logger: logger, rules: rules, envType: envType, now: now}
+}
+
+// Analyze writes the findings to the console and returns them as the lint
+// findings file
+func (a *Analyzer) Analyze(files map[string][]byte) ([]Data, error) {
+	findings := a.Findings(files)
+	if err := a.writeFindings(findings); err != nil {
+		return []Data{}, err
+	}
+
+	contents, err := json.Marshal(findings)
+	if err != nil {
+		return []Data{}, err
+	}
+	return []Data{NewData(bytes.NewReader(contents), FindingsDataType)}, nil
+}
+
+// Findings runs the rules applying to the environment type over the files
+// they match, keyed by their path within the collection. Findings are
+// ordered by severity, then by rule, file and path.
+func (a *Analyzer) Findings(files map[string][]byte) Findings {
+	now := a.now().UTC()
+	result := Findings{EvaluatedAt: now.Format(time.RFC3339), EnvType: a.envType, Findings: []Finding{}}
+
+	filePaths := make([]string, 0, len(files))
+	for filePath := range files {
+		filePaths = append(filePaths, filePath)
+	}
+	sort.Strings(filePaths)
+
+	documents := map[string]interface{}{}
+	unparseable := map[string]bool{}
+	for i := range a.rules {
+		rule := &a.rules[i]
+		if !rule.appliesTo(a.envType) {
+			continue
+		}
+		result.RulesEvaluated++
+
+		for _, filePath := range filePaths {
+			if !rule.matchesFile(filePath) || unparseable[filePath] {
+				continue
+			}
+			document, ok := documents[filePath]
+			if !ok {
+				if err := json.Unmarshal(files[filePath], &document); err != nil {
+					a.logger.Printf(UnparseableFileWarningFormat, filePath)
+					unparseable[filePath] = true
+					continue
+				}
+				documents[filePath] = document
+			}
+
+			for _, m := range rule.path.Select(document) {
+				if rule.Where != nil && !rule.Where.holds(m, now) {
+					continue
+				}
+				result.Findings = append(result.Findings, Finding{
+					ID:          rule.ID,
+					Severity:    rule.Severity,
+					Description: rule.Description,
+					File:        filePath,
+					Path:        m.Path,
+					Label:       rule.labelOf(m),
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(result.Findings, func(i, j int) bool {
+		a, b := result.Findings[i], result.Findings[j]
+		if severityRanks[a.Severity] != severityRanks[b.Severity] {
+			return severityRanks[a.Severity] < severityRanks[b.Severity]
+		}
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		return a.File < b.File
+	})
+	return result
+}
+
+func (r Rule) labelOf(m Match) string {
+	if r.label == nil {
+		return ""
+	}
+	for _, labelMatch := range r.label.Select(m.Value) {
+		switch value := labelMatch.Value.(type) {
+		case nil, map[string]interface{}, []interface{}:
+			continue
+		default:
+			return fmt.Sprint(value)
+		}
+	}
+	return ""
+}
+
+func (a *Analyzer) writeFindings(findings Findings) error {
+	if len(findings.Findings) == 0 {
+		a.logger.Printf(NoFindingsFormat, findings.RulesEvaluated)
+		return nil
+	}
+
+	counts := map[string]int{}
+	var table strings.Builder
+	tw := tabwriter.NewWriter(&table, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, findingsTableHeader)
+	for _, finding := range findings.Findings {
+		counts[finding.Severity]++
+		match := finding.Path
+		if finding.Label != "" {
+			match = finding.Label
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", finding.Severity, finding.ID, finding.File, match, finding.Description)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	a.logger.Printf(FindingsSummaryFormat, len(findings.Findings), findings.RulesEvaluated, counts[ErrorSeverity], counts[WarningSeverity], counts[InfoSeverity])
+	a.logger.Print(strings.TrimSuffix(table.String(), "\n"))
+	return nil
+}
@@ -0,0 +1,138 @@
+package lint_test
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"time"
+
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotal-cf/aqueduct-courier/lint"
+)
+
+var _ = Describe("Analyzer", func() {
+	var (
+		bufferedOutput *gbytes.Buffer
+		files          map[string][]byte
+		now            func() time.Time
+	)
+
+	BeforeEach(func() {
+		bufferedOutput = gbytes.NewBuffer()
+		now = func() time.Time { return time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC) }
+
+		files = map[string][]byte{
+			"opsmanager/ops_manager_director_network": []byte(`{"availability_zone_count": 1}`),
+			"opsmanager/cf_resources": []byte(`{"resources": [
+				{"identifier": "router", "instances": 1},
+				{"identifier": "diego_cell", "instances": 3},
+				{"identifier": "uaa", "instances": "automatic", "instances_best_fit": 1},
+				{"identifier": "mysql", "instances": "automatic", "instances_best_fit": 3}
+			]}`),
+			"opsmanager/ops_manager_certificates": []byte(`{"certificates": [
+				{"property_reference": ".properties.expiring", "valid_until": "2024-06-20T00:00:00Z"},
+				{"property_reference": ".properties.expired", "valid_until": "2024-01-01T00:00:00Z"},
+				{"property_reference": ".properties.fine", "valid_until": "2025-06-01T00:00:00Z"}
+			]}`),
+			"opsmanager/ops_manager_certificate_authorities": []byte(`{"certificate_authorities": [
+				{"guid": "active-ca", "active": true, "expires_on": "2024-06-10"},
+				{"guid": "inactive-ca", "active": false, "expires_on": "2024-06-10"}
+			]}`),
+			"opsmanager/cf_properties": []byte(`{"properties": {
+				".properties.skip_cert_verify": {"value": true},
+				".properties.uaa.insecure_cookies": {"value": false},
+				".properties.route_services": {"value": true}
+			}}`),
+			"lifecycle/freshness_findings": []byte(`{"findings": [
+				{"deployment": "cf-abc123", "component": "stemcell", "past_eol": true, "age_days": 10},
+				{"deployment": "mysql-xyz", "component": "stemcell", "past_eol": false, "age_days": 400},
+				{"deployment": "redis-123", "component": "stemcell", "past_eol": false, "age_days": 10},
+				{"deployment": "cf-abc123", "component": "release", "past_eol": true, "age_days": 900}
+			]}`),
+			"core_consumption/core_counts": []byte(`not,json`),
+		}
+	})
+
+	Describe("the default rules", func() {
+		var rules []Rule
+
+		BeforeEach(func() {
+			var err error
+			rules, err = DefaultRules()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("reports best practice findings, most severe first", func() {
+			findings := NewAnalyzer(log.New(bufferedOutput, "", 0), rules, "production", now).Findings(files)
+
+			Expect(findings.EvaluatedAt).To(Equal("2024-06-01T12:00:00Z"))
+			Expect(findings.EnvType).To(Equal("production"))
+			Expect(findings.RulesEvaluated).To(Equal(len(rules)))
+			Expect(findings.Findings).To(Equal([]Finding{
+				{ID: "certificate-authority-expiring", Severity: ErrorSeverity, Description: "The active certificate authority has expired or expires within 30 days", File: "opsmanager/ops_manager_certificate_authorities", Path: "$.certificate_authorities[0]", Label: "active-ca"},
+				{ID: "certificate-expiring", Severity: ErrorSeverity, Description: "A certificate has expired or expires within 30 days", File: "opsmanager/ops_manager_certificates", Path: "$.certificates[0]", Label: ".properties.expiring"},
+				{ID: "certificate-expiring", Severity: ErrorSeverity, Description: "A certificate has expired or expires within 30 days", File: "opsmanager/ops_manager_certificates", Path: "$.certificates[1]", Label: ".properties.expired"},
+				{ID: "insecure-tls-property", Severity: WarningSeverity, Description: "A product property skips TLS verification or allows insecure connections", File: "opsmanager/cf_properties", Path: `$.properties[".properties.skip_cert_verify"]`},
+				{ID: "old-stemcell", Severity: WarningSeverity, Description: "A deployment uses a stemcell past end of life or released more than a year ago", File: "lifecycle/freshness_findings", Path: "$.findings[0]", Label: "cf-abc123"},
+				{ID: "old-stemcell", Severity: WarningSeverity, Description: "A deployment uses a stemcell past end of life or released more than a year ago", File: "lifecycle/freshness_findings", Path: "$.findings[1]", Label: "mysql-xyz"},
+				{ID: "single-availability-zone", Severity: WarningSeverity, Description: "The BOSH director has a single availability zone, so no job survives the loss of a zone", File: "opsmanager/ops_manager_director_network", Path: "$.availability_zone_count"},
+				{ID: "single-instance-job", Severity: WarningSeverity, Description: "A job runs a single instance in a production foundation", File: "opsmanager/cf_resources", Path: "$.resources[0]", Label: "router"},
+				{ID: "single-instance-job", Severity: WarningSeverity, Description: "A job runs a single instance in a production foundation", File: "opsmanager/cf_resources", Path: "$.resources[2]", Label: "uaa"},
+			}))
+		})
+
+		It("only runs rules for the environment type of the collection", func() {
+			findings := NewAnalyzer(log.New(bufferedOutput, "", 0), rules, "development", now).Findings(files)
+
+			Expect(findings.RulesEvaluated).To(Equal(len(rules) - 1))
+			for _, finding := range findings.Findings {
+				Expect(finding.ID).NotTo(Equal("single-instance-job"))
+			}
+		})
+	})
+
+	It("skips and warns about matched files which are not JSON", func() {
+		rules, err := ParseRules([]byte(`
+rules:
+- id: any-core-count
+  severity: info
+  files: [core_consumption/*]
+  path: $
+`), "rules.yml")
+		Expect(err).NotTo(HaveOccurred())
+
+		findings := NewAnalyzer(log.New(bufferedOutput, "", 0), rules, "production", now).Findings(files)
+		Expect(findings.Findings).To(BeEmpty())
+		Expect(bufferedOutput).To(gbytes.Say("Warning: Lint rules skipped core_consumption/core_counts, which is not JSON"))
+	})
+
+	It("writes the findings to the console and as the lint findings data", func() {
+		rules, err := DefaultRules()
+		Expect(err).NotTo(HaveOccurred())
+
+		data, err := NewAnalyzer(log.New(bufferedOutput, "", 0), rules, "production", now).Analyze(files)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(bufferedOutput).To(gbytes.Say(`9 lint findings from 6 rules: 3 errors, 6 warnings, 0 info`))
+		Expect(bufferedOutput).To(gbytes.Say(`SEVERITY\s+RULE\s+FILE\s+MATCH\s+DESCRIPTION`))
+		Expect(bufferedOutput).To(gbytes.Say(`error\s+certificate-authority-expiring\s+opsmanager/ops_manager_certificate_authorities\s+active-ca\s+The active`))
+		Expect(bufferedOutput).To(gbytes.Say(`warning\s+single-availability-zone\s+opsmanager/ops_manager_director_network\s+\$\.availability_zone_count`))
+
+		Expect(data).To(HaveLen(1))
+		Expect(data[0].Name()).To(Equal(FindingsDataType))
+		contents, err := io.ReadAll(data[0].Content())
+		Expect(err).NotTo(HaveOccurred())
+		var written Findings
+		Expect(json.Unmarshal(contents, &written)).To(Succeed())
+		Expect(written.Findings).To(HaveLen(9))
+	})
+
+	It("reports when there are no findings", func() {
+		_, err := NewAnalyzer(log.New(bufferedOutput, "", 0), []Rule{}, "production", now).Analyze(files)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(bufferedOutput).To(gbytes.Say("No lint findings from 0 rules"))
+	})
+})
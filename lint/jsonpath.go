@@ -0,0 +1,233 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	InvalidPathFormat = "invalid JSON path %q: %s"
+
+	relativeRoot = "@"
+	absoluteRoot = "$"
+)
+
+var plainName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+type selector int
+
+const (
+	nameSelector selector = iota
+	indexSelector
+	wildcardSelector
+)
+
+// segment selects children of a value, and of all its descendants when
+// descend is set by the .. operator
+type segment struct {
+	selector selector
+	name     string
+	index    int
+	descend  bool
+}
+
+// Path is a parsed JSON path. It supports the root $ (or @ for a path
+// relative to a matched value), child names as .name or ['name'], array
+// indexes as [0], wildcards as .* or [*], and recursive descent as ..name.
+type Path struct {
+	expression string
+	segments   []segment
+}
+
+// Match is a value selected by a path, with the normalized path to it and
+// the object key or array index it was read from
+type Match struct {
+	Path  string
+	Key   string
+	Value interface{}
+}
+
+// ParsePath parses a JSON path. A path without a root is relative, so "value"
+// is read as "@.value".
+func ParsePath(expression string) (Path, error) {
+	rest := strings.TrimSpace(expression)
+	switch {
+	case strings.HasPrefix(rest, absoluteRoot), strings.HasPrefix(rest, relativeRoot):
+		rest = rest[1:]
+	case rest != "" && !strings.HasPrefix(rest, ".") && !strings.HasPrefix(rest, "["):
+		rest = "." + rest
+	}
+
+	path := Path{expression: expression}
+	for rest != "" {
+		var s segment
+		if strings.HasPrefix(rest, "..") {
+			s.descend = true
+			rest = rest[1:]
+			if strings.HasPrefix(rest, ".[") {
+				rest = rest[1:]
+			}
+		}
+
+		var err error
+		switch rest[0] {
+		case '.':
+			s, rest, err = parseDotSegment(s, rest[1:])
+		case '[':
+			s, rest, err = parseBracketSegment(s, rest[1:])
+		default:
+			err = errors.Errorf("unexpected %q", rest)
+		}
+		if err != nil {
+			return Path{}, errors.Errorf(InvalidPathFormat, expression, err)
+		}
+		path.segments = append(path.segments, s)
+	}
+	return path, nil
+}
+
+func parseDotSegment(s segment, rest string) (segment, string, error) {
+	end := strings.IndexAny(rest, ".[")
+	if end == -1 {
+		end = len(rest)
+	}
+	name := rest[:end]
+	switch name {
+	case "":
+		return s, "", errors.New("missing name after .")
+	case "*":
+		s.selector = wildcardSelector
+	default:
+		s.selector = nameSelector
+		s.name = name
+	}
+	return s, rest[end:], nil
+}
+
+func parseBracketSegment(s segment, rest string) (segment, string, error) {
+	if rest != "" && (rest[0] == '\'' || rest[0] == '"') {
+		quote := rest[0]
+		end := strings.IndexByte(rest[1:], quote)
+		if end == -1 || !strings.HasPrefix(rest[end+2:], "]") {
+			return s, "", errors.New("unterminated quoted name")
+		}
+		s.selector = nameSelector
+		s.name = rest[1 : end+1]
+		return s, rest[end+3:], nil
+	}
+
+	end := strings.IndexByte(rest, ']')
+	if end == -1 {
+		return s, "", errors.New("missing ]")
+	}
+	inner := strings.TrimSpace(rest[:end])
+	if inner == "*" {
+		s.selector = wildcardSelector
+		return s, rest[end+1:], nil
+	}
+	index, err := strconv.Atoi(inner)
+	if err != nil {
+		return s, "", errors.Errorf("%q is not an index, a quoted name or *", inner)
+	}
+	s.selector = indexSelector
+	s.index = index
+	return s, rest[end+1:], nil
+}
+
+func (p Path) String() string {
+	return p.expression
+}
+
+// Select returns every value the path selects from a decoded JSON document.
+// Object members are visited in key order, so matches are deterministic.
+func (p Path) Select(document interface{}) []Match {
+	matches := []Match{{Path: absoluteRoot, Value: document}}
+	for _, s := range p.segments {
+		var next []Match
+		for _, m := range matches {
+			candidates := []Match{m}
+			if s.descend {
+				candidates = append(candidates, descendants(m)...)
+			}
+			for _, candidate := range candidates {
+				next = append(next, s.apply(candidate)...)
+			}
+		}
+		matches = next
+	}
+	return matches
+}
+
+func (s segment) apply(m Match) []Match {
+	switch value := m.Value.(type) {
+	case map[string]interface{}:
+		switch s.selector {
+		case nameSelector:
+			if child, ok := value[s.name]; ok {
+				return []Match{childMatch(m, s.name, child)}
+			}
+		case wildcardSelector:
+			return children(m)
+		}
+	case []interface{}:
+		switch s.selector {
+		case indexSelector:
+			index := s.index
+			if index < 0 {
+				index += len(value)
+			}
+			if index >= 0 && index < len(value) {
+				return []Match{indexMatch(m, index, value[index])}
+			}
+		case wildcardSelector:
+			return children(m)
+		}
+	}
+	return nil
+}
+
+func children(m Match) []Match {
+	var result []Match
+	switch value := m.Value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			result = append(result, childMatch(m, key, value[key]))
+		}
+	case []interface{}:
+		for i, child := range value {
+			result = append(result, indexMatch(m, i, child))
+		}
+	}
+	return result
+}
+
+func descendants(m Match) []Match {
+	var result []Match
+	for _, child := range children(m) {
+		result = append(result, child)
+		result = append(result, descendants(child)...)
+	}
+	return result
+}
+
+func childMatch(parent Match, key string, value interface{}) Match {
+	path := fmt.Sprintf("%s[%s]", parent.Path, strconv.Quote(key))
+	if plainName.MatchString(key) {
+		path = parent.Path + "." + key
+	}
+	return Match{Path: path, Key: key, Value: value}
+}
+
+func indexMatch(parent Match, index int, value interface{}) Match {
+	return Match{Path: fmt.Sprintf("%s[%d]", parent.Path, index), Key: strconv.Itoa(index), Value: value}
+}
@@ -0,0 +1,74 @@
+package lint_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotal-cf/aqueduct-courier/lint"
+)
+
+var _ = Describe("Path", func() {
+	var document interface{}
+
+	BeforeEach(func() {
+		Expect(json.Unmarshal([]byte(`{
+			"resources": [
+				{"identifier": "router", "instances": 1},
+				{"identifier": "diego_cell", "instances": 3}
+			],
+			"properties": {
+				".properties.skip_cert_verify": {"value": true},
+				".properties.route_services": {"value": "enable"}
+			}
+		}`), &document)).To(Succeed())
+	})
+
+	paths := func(matches []Match) []string {
+		result := []string{}
+		for _, m := range matches {
+			result = append(result, m.Path)
+		}
+		return result
+	}
+
+	DescribeTable("selecting values",
+		func(expression string, expectedPaths ...string) {
+			path, err := ParsePath(expression)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths(path.Select(document))).To(Equal(append([]string{}, expectedPaths...)))
+		},
+		Entry("the root", "$", "$"),
+		Entry("a child", "$.resources", "$.resources"),
+		Entry("an index", "$.resources[1]", "$.resources[1]"),
+		Entry("a negative index", "$.resources[-1]", "$.resources[1]"),
+		Entry("array elements", "$.resources[*].identifier", "$.resources[0].identifier", "$.resources[1].identifier"),
+		Entry("object members in key order", "$.properties.*", `$.properties[".properties.route_services"]`, `$.properties[".properties.skip_cert_verify"]`),
+		Entry("a quoted name", "$.properties['.properties.skip_cert_verify'].value", `$.properties[".properties.skip_cert_verify"].value`),
+		Entry("recursive descent", "$..instances", "$.resources[0].instances", "$.resources[1].instances"),
+		Entry("a relative path", "resources[0].identifier", "$.resources[0].identifier"),
+		Entry("a missing child", "$.missing.child"),
+	)
+
+	It("records the key each value was read from", func() {
+		path, err := ParsePath("$.properties.*")
+		Expect(err).NotTo(HaveOccurred())
+
+		matches := path.Select(document)
+		Expect(matches).To(HaveLen(2))
+		Expect(matches[1].Key).To(Equal(".properties.skip_cert_verify"))
+		Expect(matches[1].Value).To(Equal(map[string]interface{}{"value": true}))
+	})
+
+	DescribeTable("rejecting invalid paths",
+		func(expression string) {
+			_, err := ParsePath(expression)
+			Expect(err).To(MatchError(ContainSubstring("invalid JSON path")))
+		},
+		Entry("an empty name", "$.resources."),
+		Entry("an unclosed bracket", "$.resources[0"),
+		Entry("an unterminated quote", "$['resources]"),
+		Entry("a filter", "$.resources[?(@.instances == 1)]"),
+	)
+})
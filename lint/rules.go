@@ -0,0 +1,363 @@
+package lint
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ErrorSeverity   = "error"
+	WarningSeverity = "warning"
+	InfoSeverity    = "info"
+
+	DefaultRulesName = "default rules"
+
+	ReadRulesErrorFormat      = "could not read lint rules %s"
+	InvalidRulesErrorFormat   = "lint rules %s are invalid:\n%s"
+	RuleWithoutIDFormat       = "rule %d: id is required"
+	RuleDuplicateIDFormat     = "rule %q: id is used by more than one rule"
+	RuleInvalidSeverityFormat = "rule %q: severity %q is not error, warning or info"
+	RuleWithoutFilesFormat    = "rule %q: files is required"
+	RuleInvalidFileFormat     = "rule %q: file pattern %q is invalid"
+	RuleWithoutPathFormat     = "rule %q: path is required"
+	RuleInvalidPathFormat     = "rule %q: %s"
+	RuleInvalidRegexFormat    = "rule %q: %s %q is not a valid regular expression"
+	RuleEmptyPredicateFormat  = "rule %q: a predicate in where has no condition"
+)
+
+//go:embed default_rules.yml
+var defaultRules []byte
+
+var severityRanks = map[string]int{ErrorSeverity: 0, WarningSeverity: 1, InfoSeverity: 2}
+
+type RuleSet struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule selects values with a JSON path from the collected files matching one
+// of its patterns, and reports a finding for every value the where predicate
+// holds for. A rule with env types only applies to collections of those
+// environment types.
+type Rule struct {
+	ID          string     `yaml:"id"`
+	Severity    string     `yaml:"severity"`
+	Description string     `yaml:"description"`
+	Files       []string   `yaml:"files"`
+	EnvTypes    []string   `yaml:"env_types"`
+	Path        string     `yaml:"path"`
+	Where       *Predicate `yaml:"where"`
+	Label       string     `yaml:"label"`
+
+	path  Path
+	label *Path
+}
+
+// Predicate tests a matched value. Field is a JSON path relative to the
+// matched value, which is tested itself when field is empty, and the
+// predicate holds when any value it selects meets every condition set. Times
+// are RFC 3339 times or YYYY-MM-DD dates.
+type Predicate struct {
+	Field         string      `yaml:"field"`
+	KeyMatches    string      `yaml:"key_matches"`
+	Exists        *bool       `yaml:"exists"`
+	Equals        interface{} `yaml:"equals"`
+	NotEquals     interface{} `yaml:"not_equals"`
+	Matches       string      `yaml:"matches"`
+	LessThan      *float64    `yaml:"less_than"`
+	GreaterThan   *float64    `yaml:"greater_than"`
+	WithinDays    *int        `yaml:"within_days"`
+	OlderThanDays *int        `yaml:"older_than_days"`
+	All           []Predicate `yaml:"all"`
+	Any           []Predicate `yaml:"any"`
+
+	field      *Path
+	keyMatches *regexp.Regexp
+	matches    *regexp.Regexp
+}
+
+// LoadRules reads a YAML or JSON rules file and validates every rule,
+// reporting all problems at once
+func LoadRules(rulesPath string) ([]Rule, error) {
+	contents, err := os.ReadFile(rulesPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, ReadRulesErrorFormat, rulesPath)
+	}
+	return ParseRules(contents, rulesPath)
+}
+
+// DefaultRules are the best practice rules built into the collector
+func DefaultRules() ([]Rule, error) {
+	return ParseRules(defaultRules, DefaultRulesName)
+}
+
+// ParseRules reads rules, rejecting unknown fields so a misspelt condition
+// is not silently ignored
+func ParseRules(contents []byte, name string) ([]Rule, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	decoder.KnownFields(true)
+
+	var ruleSet RuleSet
+	if err := decoder.Decode(&ruleSet); err != nil {
+		return nil, errors.Wrapf(err, ReadRulesErrorFormat, name)
+	}
+
+	if problems := ruleSet.validate(); len(problems) > 0 {
+		return nil, errors.Errorf(InvalidRulesErrorFormat, name, "  "+strings.Join(problems, "\n  "))
+	}
+	return ruleSet.Rules, nil
+}
+
+func (s *RuleSet) validate() []string {
+	var problems []string
+	ids := map[string]bool{}
+	for i := range s.Rules {
+		rule := &s.Rules[i]
+		if rule.ID == "" {
+			problems = append(problems, fmt.Sprintf(RuleWithoutIDFormat, i))
+			continue
+		}
+		if ids[rule.ID] {
+			problems = append(problems, fmt.Sprintf(RuleDuplicateIDFormat, rule.ID))
+		}
+		ids[rule.ID] = true
+		problems = append(problems, rule.validate()...)
+	}
+	return problems
+}
+
+func (r *Rule) validate() []string {
+	var problems []string
+	if _, ok := severityRanks[r.Severity]; !ok {
+		problems = append(problems, fmt.Sprintf(RuleInvalidSeverityFormat, r.ID, r.Severity))
+	}
+
+	if len(r.Files) == 0 {
+		problems = append(problems, fmt.Sprintf(RuleWithoutFilesFormat, r.ID))
+	}
+	for _, pattern := range r.Files {
+		if _, err := path.Match(pattern, ""); err != nil {
+			problems = append(problems, fmt.Sprintf(RuleInvalidFileFormat, r.ID, pattern))
+		}
+	}
+
+	if r.Path == "" {
+		problems = append(problems, fmt.Sprintf(RuleWithoutPathFormat, r.ID))
+	} else if parsed, err := ParsePath(r.Path); err != nil {
+		problems = append(problems, fmt.Sprintf(RuleInvalidPathFormat, r.ID, err))
+	} else {
+		r.path = parsed
+	}
+
+	if r.Label != "" {
+		if parsed, err := ParsePath(r.Label); err != nil {
+			problems = append(problems, fmt.Sprintf(RuleInvalidPathFormat, r.ID, err))
+		} else {
+			r.label = &parsed
+		}
+	}
+
+	if r.Where != nil {
+		problems = append(problems, r.Where.compile(r.ID)...)
+	}
+	return problems
+}
+
+func (p *Predicate) compile(ruleID string) []string {
+	var problems []string
+	if p.Field != "" {
+		if parsed, err := ParsePath(p.Field); err != nil {
+			problems = append(problems, fmt.Sprintf(RuleInvalidPathFormat, ruleID, err))
+		} else {
+			p.field = &parsed
+		}
+	}
+	if p.KeyMatches != "" {
+		if compiled, err := regexp.Compile(p.KeyMatches); err != nil {
+			problems = append(problems, fmt.Sprintf(RuleInvalidRegexFormat, ruleID, "key_matches", p.KeyMatches))
+		} else {
+			p.keyMatches = compiled
+		}
+	}
+	if p.Matches != "" {
+		if compiled, err := regexp.Compile(p.Matches); err != nil {
+			problems = append(problems, fmt.Sprintf(RuleInvalidRegexFormat, ruleID, "matches", p.Matches))
+		} else {
+			p.matches = compiled
+		}
+	}
+
+	if p.KeyMatches == "" && !p.hasValueCondition() && len(p.All) == 0 && len(p.Any) == 0 {
+		problems = append(problems, fmt.Sprintf(RuleEmptyPredicateFormat, ruleID))
+	}
+	for i := range p.All {
+		problems = append(problems, p.All[i].compile(ruleID)...)
+	}
+	for i := range p.Any {
+		problems = append(problems, p.Any[i].compile(ruleID)...)
+	}
+	return problems
+}
+
+func (p *Predicate) hasValueCondition() bool {
+	return p.Exists != nil || p.Equals != nil || p.NotEquals != nil || p.Matches != "" ||
+		p.LessThan != nil || p.GreaterThan != nil || p.WithinDays != nil || p.OlderThanDays != nil
+}
+
+func (r Rule) appliesTo(envType string) bool {
+	if len(r.EnvTypes) == 0 {
+		return true
+	}
+	for _, t := range r.EnvTypes {
+		if t == envType {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) matchesFile(filePath string) bool {
+	for _, pattern := range r.Files {
+		if matched, _ := path.Match(pattern, filePath); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Predicate) holds(m Match, now time.Time) bool {
+	if p.keyMatches != nil && !p.keyMatches.MatchString(m.Key) {
+		return false
+	}
+
+	if p.hasValueCondition() {
+		values := []Match{m}
+		if p.field != nil {
+			values = p.field.Select(m.Value)
+		}
+		if p.Exists != nil && *p.Exists != (len(values) > 0) {
+			return false
+		}
+		if !p.anyValueMeetsConditions(values, now) {
+			return false
+		}
+	}
+
+	for i := range p.All {
+		if !p.All[i].holds(m, now) {
+			return false
+		}
+	}
+	if len(p.Any) > 0 {
+		for i := range p.Any {
+			if p.Any[i].holds(m, now) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (p *Predicate) anyValueMeetsConditions(values []Match, now time.Time) bool {
+	if p.Exists != nil && !*p.Exists {
+		return true
+	}
+	for _, v := range values {
+		if p.valueMeetsConditions(v.Value, now) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Predicate) valueMeetsConditions(value interface{}, now time.Time) bool {
+	if p.Equals != nil && !equal(value, p.Equals) {
+		return false
+	}
+	if p.NotEquals != nil && equal(value, p.NotEquals) {
+		return false
+	}
+	if p.matches != nil {
+		s, ok := value.(string)
+		if !ok || !p.matches.MatchString(s) {
+			return false
+		}
+	}
+	if p.LessThan != nil || p.GreaterThan != nil {
+		n, ok := toNumber(value)
+		if !ok || (p.LessThan != nil && n >= *p.LessThan) || (p.GreaterThan != nil && n <= *p.GreaterThan) {
+			return false
+		}
+	}
+	if p.WithinDays != nil || p.OlderThanDays != nil {
+		t, ok := toTime(value)
+		if !ok {
+			return false
+		}
+		if p.WithinDays != nil && t.After(now.AddDate(0, 0, *p.WithinDays)) {
+			return false
+		}
+		if p.OlderThanDays != nil && !t.Before(now.AddDate(0, 0, -*p.OlderThanDays)) {
+			return false
+		}
+	}
+	return true
+}
+
+// equal compares a JSON value with a value from the rules, treating numbers
+// of any type as equal when their values are
+func equal(jsonValue, ruleValue interface{}) bool {
+	a, aIsNumber := strictNumber(jsonValue)
+	b, bIsNumber := strictNumber(ruleValue)
+	if aIsNumber || bIsNumber {
+		return aIsNumber && bIsNumber && a == b
+	}
+	return reflect.DeepEqual(jsonValue, ruleValue)
+}
+
+func strictNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// toNumber reads a number, including one Ops Manager returns as a string
+func toNumber(value interface{}) (float64, bool) {
+	if n, ok := strictNumber(value); ok {
+		return n, true
+	}
+	switch v := value.(type) {
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func toTime(value interface{}) (time.Time, bool) {
+	s, ok := value.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,154 @@
+package lint_test
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotal-cf/aqueduct-courier/lint"
+)
+
+var _ = Describe("Rules", func() {
+	Describe("LoadRules", func() {
+		var tempDir string
+
+		BeforeEach(func() {
+			var err error
+			tempDir, err = os.MkdirTemp("", "")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(tempDir)).To(Succeed())
+		})
+
+		It("reads rules from a file", func() {
+			rulesPath := filepath.Join(tempDir, "rules.yml")
+			Expect(os.WriteFile(rulesPath, []byte(`
+rules:
+- id: no-syslog
+  severity: warning
+  description: Syslog is not enabled
+  files: [opsmanager/ops_manager_settings]
+  path: $.syslog_enabled
+  where:
+    equals: false
+`), 0644)).To(Succeed())
+
+			rules, err := LoadRules(rulesPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rules).To(HaveLen(1))
+			Expect(rules[0].ID).To(Equal("no-syslog"))
+			Expect(rules[0].Files).To(Equal([]string{"opsmanager/ops_manager_settings"}))
+		})
+
+		It("errors when the file cannot be read", func() {
+			_, err := LoadRules(filepath.Join(tempDir, "missing.yml"))
+			Expect(err).To(MatchError(ContainSubstring("could not read lint rules")))
+		})
+	})
+
+	It("rejects unknown fields", func() {
+		_, err := ParseRules([]byte(`
+rules:
+- id: typo
+  severity: info
+  files: [opsmanager/*]
+  path: $
+  where:
+    equal: 1
+`), "rules.yml")
+		Expect(err).To(MatchError(ContainSubstring("field equal not found")))
+	})
+
+	It("reports every invalid rule at once", func() {
+		_, err := ParseRules([]byte(`
+rules:
+- severity: info
+- id: bad
+  severity: critical
+  files: ["opsmanager/["]
+  path: $.a[
+  label: $.b[
+  where:
+    key_matches: "("
+    any:
+    - field: value
+- id: bad
+  severity: info
+  files: [opsmanager/*]
+  path: $
+`), "rules.yml")
+		Expect(err).To(MatchError(ContainSubstring("lint rules rules.yml are invalid:")))
+		for _, problem := range []string{
+			"rule 0: id is required",
+			`rule "bad": severity "critical" is not error, warning or info`,
+			`rule "bad": file pattern "opsmanager/[" is invalid`,
+			`rule "bad": invalid JSON path "$.a["`,
+			`rule "bad": invalid JSON path "$.b["`,
+			`rule "bad": key_matches "(" is not a valid regular expression`,
+			`rule "bad": a predicate in where has no condition`,
+			`rule "bad": id is used by more than one rule`,
+		} {
+			Expect(err.Error()).To(ContainSubstring(problem))
+		}
+	})
+
+	It("requires files and a path", func() {
+		_, err := ParseRules([]byte(`
+rules:
+- id: empty
+  severity: info
+`), "rules.yml")
+		Expect(err).To(MatchError(ContainSubstring(`rule "empty": files is required`)))
+		Expect(err).To(MatchError(ContainSubstring(`rule "empty": path is required`)))
+	})
+
+	DescribeTable("predicates",
+		func(where string, expectedLabels ...string) {
+			rules, err := ParseRules([]byte(`
+rules:
+- id: predicate
+  severity: info
+  files: [data]
+  path: $.items[*]
+  label: name
+  where:
+`+where), "rules.yml")
+			Expect(err).NotTo(HaveOccurred())
+
+			now := func() time.Time { return time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC) }
+			findings := NewAnalyzer(log.New(gbytes.NewBuffer(), "", 0), rules, "production", now).Findings(map[string][]byte{
+				"data": []byte(`{"items": [
+					{"name": "a", "count": 1, "size": "10", "state": "enabled", "date": "2024-05-01"},
+					{"name": "b", "count": 3, "size": "20", "state": "disabled", "date": "2024-06-15T00:00:00Z", "optional": null},
+					{"name": "c", "count": "1", "state": true}
+				]}`),
+			})
+
+			var labels []string
+			for _, finding := range findings.Findings {
+				labels = append(labels, finding.Label)
+			}
+			Expect(labels).To(Equal(expectedLabels))
+		},
+		Entry("equals a number", "    field: count\n    equals: 1", "a"),
+		Entry("equals a boolean", "    field: state\n    equals: true", "c"),
+		Entry("does not equal", "    field: state\n    not_equals: enabled", "b", "c"),
+		Entry("matches", "    field: state\n    matches: ^dis", "b"),
+		Entry("less than, reading numeric strings", "    field: size\n    less_than: 15", "a"),
+		Entry("greater than", "    field: count\n    greater_than: 2", "b"),
+		Entry("exists", "    field: optional\n    exists: true", "b"),
+		Entry("does not exist", "    field: date\n    exists: false", "c"),
+		Entry("within days", "    field: date\n    within_days: 30", "a", "b"),
+		Entry("older than days", "    field: date\n    older_than_days: 14", "a"),
+		Entry("all", "    all:\n    - field: count\n      less_than: 5\n    - field: state\n      matches: abled", "a", "b"),
+		Entry("any", "    any:\n    - field: count\n      equals: 3\n    - field: state\n      equals: true", "b", "c"),
+	)
+})
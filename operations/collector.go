@@ -10,7 +10,12 @@ import (
 	"time"
 
 	"github.com/gofrs/uuid"
+	"github.com/pivotal-cf/aqueduct-courier/bosh"
+	"github.com/pivotal-cf/aqueduct-courier/capacity"
+	"github.com/pivotal-cf/aqueduct-courier/cfinventory"
 	"github.com/pivotal-cf/aqueduct-courier/consumption"
+	"github.com/pivotal-cf/aqueduct-courier/lifecycle"
+	"github.com/pivotal-cf/aqueduct-courier/lint"
 
 	"github.com/pivotal-cf/aqueduct-courier/credhub"
 
@@ -21,13 +26,21 @@ import (
 )
 
 const (
-	OpsManagerCollectFailureMessage = "Failed collecting from Operations Manager"
-	CredhubCollectFailureMessage    = "Failed collecting from Credhub"
-	UsageCollectFailureMessage      = "Failed collecting from Usage Service"
-	DataWriteFailureMessage         = "Failed writing data"
-	ContentReadingFailureMessage    = "Failed to read content"
-	UUIDGenerationErrorMessage      = "unable to generate UUID"
-	CoreCountsCollectFailureMessage = "Failed collecting from Core Counting API"
+	OpsManagerCollectFailureMessage  = "Failed collecting from Operations Manager"
+	CredhubCollectFailureMessage     = "Failed collecting from Credhub"
+	UsageCollectFailureMessage       = "Failed collecting from Usage Service"
+	DataWriteFailureMessage          = "Failed writing data"
+	ContentReadingFailureMessage     = "Failed to read content"
+	UUIDGenerationErrorMessage       = "unable to generate UUID"
+	CoreCountsCollectFailureMessage  = "Failed collecting from Core Counting API"
+	CoreCountsSkippedWarningFormat   = "Warning: core counts skipped, the Ops Manager version is unknown: %s"
+	CfInventoryCollectFailureMessage = "Failed collecting from CF API"
+	BoshCollectFailureMessage        = "Failed collecting from BOSH director"
+	LifecycleAnalysisFailureMessage  = "Failed analyzing stemcell and release freshness"
+	CapacityAnalysisFailureMessage   = "Failed analyzing product capacity"
+	LintFailureMessage               = "Failed running lint rules"
+	PseudonymizeFailureMessage       = "Failed pseudonymizing collected data"
+	SecretScanFailureMessage         = "Failed scanning collected data for secrets"
 )
 
 //go:generate counterfeiter . omDataCollector
@@ -50,6 +63,42 @@ type coreConsumptionDataCollector interface {
 	Collect() ([]coreconsumption.Data, error)
 }
 
+//go:generate counterfeiter . cfInventoryDataCollector
+type cfInventoryDataCollector interface {
+	Collect() ([]cfinventory.Data, error)
+}
+
+//go:generate counterfeiter . boshDataCollector
+type boshDataCollector interface {
+	Collect() ([]bosh.Data, error)
+}
+
+//go:generate counterfeiter . lifecycleAnalyzer
+type lifecycleAnalyzer interface {
+	Analyze(files map[string][]byte) ([]lifecycle.Data, error)
+}
+
+//go:generate counterfeiter . capacityAnalyzer
+type capacityAnalyzer interface {
+	Analyze(files map[string][]byte) ([]capacity.Data, error)
+}
+
+//go:generate counterfeiter . lintAnalyzer
+type lintAnalyzer interface {
+	Analyze(files map[string][]byte) ([]lint.Data, error)
+}
+
+//go:generate counterfeiter . pseudonymizer
+type pseudonymizer interface {
+	Pseudonymize(contents []byte) ([]byte, error)
+	FoundationID(foundationID string) string
+}
+
+//go:generate counterfeiter . secretScanner
+type secretScanner interface {
+	Scan(filePath string, contents []byte) ([]byte, error)
+}
+
 //go:generate counterfeiter . tarWriter
 type tarWriter interface {
 	AddFile([]byte, string) error
@@ -69,18 +118,71 @@ type collectedData interface {
 	Content() io.Reader
 }
 
+// CollectorOptions are the optional collectors, analyzers and filters of a
+// collection. A collector or analyzer left nil is not run, and its dataset is
+// not written.
+type CollectorOptions struct {
+	Credhub         credhubDataCollector
+	Consumption     consumptionDataCollector
+	CoreConsumption coreConsumptionDataCollector
+	// CoreCountsRequired is set when the Ops Manager version is known to
+	// serve the Core Counting API. Otherwise the API may be missing, and a
+	// failure only skips the core counts.
+	CoreCountsRequired bool
+	CfInventory        cfInventoryDataCollector
+	Bosh               boshDataCollector
+	Lifecycle          lifecycleAnalyzer
+	Capacity           capacityAnalyzer
+	Lint               lintAnalyzer
+	Pseudonymizer      pseudonymizer
+	SecretScanner      secretScanner
+}
+
 type CollectExecutor struct {
-	opsmanagerDC        omDataCollector
-	credhubDC           credhubDataCollector
-	consumptionDC       consumptionDataCollector
-	coreConsumptionDC   coreConsumptionDataCollector
-	tarWriter           tarWriter
-	uuidProvider        uuidProvider
-	operationalDataOnly bool
+	opsmanagerDC       omDataCollector
+	credhubDC          credhubDataCollector
+	consumptionDC      consumptionDataCollector
+	coreConsumptionDC  coreConsumptionDataCollector
+	coreCountsRequired bool
+	cfInventoryDC      cfInventoryDataCollector
+	boshDC             boshDataCollector
+	lifecycleAnalyzer  lifecycleAnalyzer
+	capacityAnalyzer   capacityAnalyzer
+	lintAnalyzer       lintAnalyzer
+	pseudonymizer      pseudonymizer
+	secretScanner      secretScanner
+	tarWriter          tarWriter
+	uuidProvider       uuidProvider
+	datasets           Datasets
+
+	// metadata is shared by every dataset of the collection, and copied for
+	// each to add the digests of its files
+	metadata collector_tar.Metadata
+
+	// writtenFiles keeps the contents written so far for the lifecycle,
+	// capacity and lint analyzers, keyed by path within the tar
+	writtenFiles map[string][]byte
 }
 
-func NewCollector(opsmanagerDC omDataCollector, credhubDC credhubDataCollector, consumptionDC consumptionDataCollector, coreConsumptionDC coreConsumptionDataCollector, tarWriter tarWriter, uuidProvider uuidProvider, operationalDataOnly bool) *CollectExecutor {
-	return &CollectExecutor{opsmanagerDC: opsmanagerDC, credhubDC: credhubDC, consumptionDC: consumptionDC, coreConsumptionDC: coreConsumptionDC, tarWriter: tarWriter, uuidProvider: uuidProvider, operationalDataOnly: operationalDataOnly}
+func NewCollector(opsmanagerDC omDataCollector, options CollectorOptions, tarWriter tarWriter, uuidProvider uuidProvider, datasets Datasets) *CollectExecutor {
+	return &CollectExecutor{
+		opsmanagerDC:       opsmanagerDC,
+		credhubDC:          options.Credhub,
+		consumptionDC:      options.Consumption,
+		coreConsumptionDC:  options.CoreConsumption,
+		coreCountsRequired: options.CoreCountsRequired,
+		cfInventoryDC:      options.CfInventory,
+		boshDC:             options.Bosh,
+		lifecycleAnalyzer:  options.Lifecycle,
+		capacityAnalyzer:   options.Capacity,
+		lintAnalyzer:       options.Lint,
+		pseudonymizer:      options.Pseudonymizer,
+		secretScanner:      options.SecretScanner,
+		tarWriter:          tarWriter,
+		uuidProvider:       uuidProvider,
+		datasets:           datasets,
+		writtenFiles:       map[string][]byte{},
+	}
 }
 
 func (ce *CollectExecutor) Collect(envType, collectorVersion, foundationNickname string) error {
@@ -90,121 +192,143 @@ func (ce *CollectExecutor) Collect(envType, collectorVersion, foundationNickname
 	if err != nil {
 		return errors.Wrap(err, UUIDGenerationErrorMessage)
 	}
-	collectionIDAsString := collectionID.String()
 
 	omDatas, foundationId, err := ce.opsmanagerDC.Collect()
 	if err != nil {
 		return errors.Wrap(err, OpsManagerCollectFailureMessage)
 	}
-
-	collectedAtTime := time.Now().UTC().Format(time.RFC3339)
-	opsManagerMetadata := collector_tar.Metadata{
-		CollectorVersion:   collectorVersion,
-		EnvType:            envType,
-		CollectionId:       collectionIDAsString,
-		FoundationId:       foundationId,
-		FoundationNickname: foundationNickname,
-		CollectedAt:        collectedAtTime,
-	}
-
-	usageMetadata := collector_tar.Metadata{
-		CollectorVersion:   collectorVersion,
-		EnvType:            envType,
-		CollectionId:       collectionIDAsString,
-		FoundationId:       foundationId,
-		FoundationNickname: foundationNickname,
-		CollectedAt:        collectedAtTime,
+	if ce.pseudonymizer != nil {
+		foundationId = ce.pseudonymizer.FoundationID(foundationId)
 	}
 
-	coreCountsMetadata := collector_tar.Metadata{
+	ce.metadata = collector_tar.Metadata{
 		CollectorVersion:   collectorVersion,
 		EnvType:            envType,
-		CollectionId:       collectionIDAsString,
+		CollectionId:       collectionID.String(),
 		FoundationId:       foundationId,
 		FoundationNickname: foundationNickname,
-		CollectedAt:        collectedAtTime,
+		CollectedAt:        time.Now().UTC().Format(time.RFC3339),
 	}
 
-	for _, omData := range omDatas {
-		err = ce.addData(omData, &opsManagerMetadata, collector_tar.OpsManagerCollectorDataSetId)
-		if err != nil {
-			return err
-		}
+	// Ops Manager data is only written when its dataset is selected, since
+	// the opsmanager dataset is never written without its metadata
+	var opsManagerDatas []collectedData
+	if ce.datasets.Includes(OpsManagerDataset) {
+		opsManagerDatas = toCollectedData(omDatas)
 	}
-
 	if ce.credhubDC != nil {
 		chData, err := ce.credhubDC.Collect()
 		if err != nil {
 			return errors.Wrap(err, CredhubCollectFailureMessage)
 		}
+		opsManagerDatas = append(opsManagerDatas, chData)
+	}
 
-		err = ce.addData(chData, &opsManagerMetadata, collector_tar.OpsManagerCollectorDataSetId)
-		if err != nil {
+	// CredHub data is written to the opsmanager dataset, so its metadata is
+	// written whenever CredHub is collected even without the Ops Manager data
+	if ce.datasets.Includes(OpsManagerDataset) || ce.credhubDC != nil {
+		if err := ce.writeDataset(collector_tar.OpsManagerCollectorDataSetId, opsManagerDatas); err != nil {
 			return err
 		}
 	}
 
-	metadataContents, err := json.Marshal(opsManagerMetadata)
-	if err != nil {
-		return err
+	if ce.consumptionDC != nil {
+		usageData, err := ce.consumptionDC.Collect()
+		if err != nil {
+			return errors.Wrap(err, UsageCollectFailureMessage)
+		}
+		if err := ce.writeDataset(collector_tar.UsageServiceCollectorDataSetId, toCollectedData(usageData)); err != nil {
+			return err
+		}
 	}
 
-	if !ce.operationalDataOnly {
-		err = ce.tarWriter.AddFile(metadataContents, path.Join(collector_tar.OpsManagerCollectorDataSetId, collector_tar.MetadataFileName))
+	if ce.coreConsumptionDC != nil {
+		coreCountsData, err := ce.coreConsumptionDC.Collect()
+		if err != nil && ce.coreCountsRequired {
+			return errors.Wrap(err, CoreCountsCollectFailureMessage)
+		}
 		if err != nil {
-			return errors.Wrap(err, DataWriteFailureMessage)
+			log.Printf(CoreCountsSkippedWarningFormat, errors.Wrap(err, CoreCountsCollectFailureMessage))
+		} else if err := ce.writeDataset(collector_tar.CoreConsumptionCollectorDataSetId, toCollectedData(coreCountsData)); err != nil {
+			return err
 		}
 	}
 
-	if ce.consumptionDC != nil {
-		usageData, err := ce.consumptionDC.Collect()
+	if ce.cfInventoryDC != nil {
+		inventoryData, err := ce.cfInventoryDC.Collect()
 		if err != nil {
-			return errors.Wrap(err, UsageCollectFailureMessage)
+			return errors.Wrap(err, CfInventoryCollectFailureMessage)
 		}
+		if err := ce.writeDataset(cfinventory.CfInventoryCollectorDataSetId, toCollectedData(inventoryData)); err != nil {
+			return err
+		}
+	}
 
-		for _, consumptionData := range usageData {
-			err = ce.addData(consumptionData, &usageMetadata, collector_tar.UsageServiceCollectorDataSetId)
-			if err != nil {
-				return err
-			}
+	if ce.boshDC != nil {
+		boshData, err := ce.boshDC.Collect()
+		if err != nil {
+			return errors.Wrap(err, BoshCollectFailureMessage)
+		}
+		if err := ce.writeDataset(bosh.BoshCollectorDataSetId, toCollectedData(boshData)); err != nil {
+			return err
 		}
-		usageMetadataContents, err := json.Marshal(usageMetadata)
+	}
+
+	if ce.lifecycleAnalyzer != nil {
+		// The analysis reads the data written above, so it runs last
+		findingsData, err := ce.lifecycleAnalyzer.Analyze(ce.writtenFiles)
 		if err != nil {
+			return errors.Wrap(err, LifecycleAnalysisFailureMessage)
+		}
+		if err := ce.writeDataset(lifecycle.LifecycleDataSetId, toCollectedData(findingsData)); err != nil {
 			return err
 		}
+	}
 
-		err = ce.tarWriter.AddFile(usageMetadataContents, path.Join(collector_tar.UsageServiceCollectorDataSetId, collector_tar.MetadataFileName))
+	if ce.capacityAnalyzer != nil {
+		capacityData, err := ce.capacityAnalyzer.Analyze(ce.writtenFiles)
 		if err != nil {
-			return errors.Wrap(err, DataWriteFailureMessage)
+			return errors.Wrap(err, CapacityAnalysisFailureMessage)
+		}
+		if err := ce.writeDataset(capacity.CapacityDataSetId, toCollectedData(capacityData)); err != nil {
+			return err
 		}
 	}
 
-	if ce.coreConsumptionDC != nil {
-		coreCountsData, err := ce.coreConsumptionDC.Collect()
+	if ce.lintAnalyzer != nil {
+		// Rules may read the lifecycle findings and capacity model, so
+		// linting runs after both
+		lintData, err := ce.lintAnalyzer.Analyze(ce.writtenFiles)
 		if err != nil {
-			// Do not fail when we are unable to collect Core Consumption data
-			// This API is only supported in Ops Manager 2.10.58+ and 3.0.10+
-			log.Println(errors.Wrap(err, CoreCountsCollectFailureMessage))
-		} else {
-			for _, coreConsumptionData := range coreCountsData {
-				err = ce.addData(coreConsumptionData, &coreCountsMetadata, collector_tar.CoreConsumptionCollectorDataSetId)
-				if err != nil {
-					return err
-				}
-			}
+			return errors.Wrap(err, LintFailureMessage)
+		}
+		if err := ce.writeDataset(lint.LintDataSetId, toCollectedData(lintData)); err != nil {
+			return err
+		}
+	}
 
-			coreCountsMetadataContents, err := json.Marshal(coreCountsMetadata)
-			if err != nil {
-				return err
-			}
+	return nil
+}
 
-			err = ce.tarWriter.AddFile(coreCountsMetadataContents, path.Join(collector_tar.CoreConsumptionCollectorDataSetId, collector_tar.MetadataFileName))
-			if err != nil {
-				return errors.Wrap(err, DataWriteFailureMessage)
-			}
+// writeDataset writes the files of a dataset, followed by a copy of the
+// collection metadata with their digests
+func (ce *CollectExecutor) writeDataset(dataSetId string, datas []collectedData) error {
+	metadata := ce.metadata
+	for _, data := range datas {
+		if err := ce.addData(data, &metadata, dataSetId); err != nil {
+			return err
 		}
 	}
 
+	metadataContents, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	err = ce.tarWriter.AddFile(metadataContents, path.Join(dataSetId, collector_tar.MetadataFileName))
+	if err != nil {
+		return errors.Wrap(err, DataWriteFailureMessage)
+	}
 	return nil
 }
 
@@ -213,11 +337,31 @@ func (ce *CollectExecutor) addData(collectedData collectedData, metadata *collec
 	if err != nil {
 		return errors.Wrap(err, ContentReadingFailureMessage)
 	}
+	if ce.pseudonymizer != nil {
+		// Identifiers are replaced before the analyzers read the data, so
+		// their output only holds pseudonyms
+		dataContents, err = ce.pseudonymizer.Pseudonymize(dataContents)
+		if err != nil {
+			return errors.Wrap(err, PseudonymizeFailureMessage)
+		}
+	}
 
-	err = ce.tarWriter.AddFile(dataContents, path.Join(dataSetType, collectedData.Name()))
+	filePath := path.Join(dataSetType, collectedData.Name())
+	if ce.secretScanner != nil {
+		// The scan is a safety net behind the redaction of each service, so
+		// it sees exactly what is written
+		dataContents, err = ce.secretScanner.Scan(filePath, dataContents)
+		if err != nil {
+			return errors.Wrap(err, SecretScanFailureMessage)
+		}
+	}
+	err = ce.tarWriter.AddFile(dataContents, filePath)
 	if err != nil {
 		return errors.Wrap(err, DataWriteFailureMessage)
 	}
+	if ce.lifecycleAnalyzer != nil || ce.capacityAnalyzer != nil || ce.lintAnalyzer != nil {
+		ce.writtenFiles[filePath] = dataContents
+	}
 
 	md5Sum := md5.Sum([]byte(dataContents))
 	metadata.FileDigests = append(metadata.FileDigests, collector_tar.FileDigest{
@@ -229,3 +373,11 @@ func (ce *CollectExecutor) addData(collectedData collectedData, metadata *collec
 	})
 	return nil
 }
+
+func toCollectedData[T collectedData](datas []T) []collectedData {
+	collected := make([]collectedData, 0, len(datas))
+	for _, data := range datas {
+		collected = append(collected, data)
+	}
+	return collected
+}
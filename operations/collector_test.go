@@ -5,12 +5,19 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"log"
+	"os"
 	"path"
 	"strings"
 	"time"
 
+	"github.com/pivotal-cf/aqueduct-courier/bosh"
+	"github.com/pivotal-cf/aqueduct-courier/capacity"
+	"github.com/pivotal-cf/aqueduct-courier/cfinventory"
 	"github.com/pivotal-cf/aqueduct-courier/consumption"
 	"github.com/pivotal-cf/aqueduct-courier/coreconsumption"
+	"github.com/pivotal-cf/aqueduct-courier/lifecycle"
+	"github.com/pivotal-cf/aqueduct-courier/lint"
 
 	"github.com/pivotal-cf/aqueduct-courier/credhub"
 
@@ -21,6 +28,7 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 	"github.com/pivotal-cf/aqueduct-courier/operations/operationsfakes"
 	"github.com/pivotal-cf/aqueduct-courier/opsmanager"
 )
@@ -33,6 +41,9 @@ var _ = Describe("DataCollector", func() {
 		uuidString                   = "cf736154-6fd5-47f4-8ca9-1b4a6fe451ad"
 		collector                    *CollectExecutor
 		collectorOperationalDataOnly *CollectExecutor
+
+		allDatasets         = NewDatasets(DatasetNames...)
+		operationalDatasets = NewDatasets(UsageServiceDataset, CoreConsumptionDataset)
 	)
 
 	BeforeEach(func() {
@@ -43,8 +54,8 @@ var _ = Describe("DataCollector", func() {
 			return uuid.FromString(uuidString)
 		}
 
-		collector = NewCollector(omDataCollector, nil, nil, nil, tarWriter, uuidProvider, false)
-		collectorOperationalDataOnly = NewCollector(omDataCollector, nil, nil, nil, tarWriter, uuidProvider, true)
+		collector = NewCollector(omDataCollector, CollectorOptions{}, tarWriter, uuidProvider, allDatasets)
+		collectorOperationalDataOnly = NewCollector(omDataCollector, CollectorOptions{}, tarWriter, uuidProvider, operationalDatasets)
 	})
 
 	It("collects opsmanager data and writes it", func() {
@@ -103,10 +114,8 @@ var _ = Describe("DataCollector", func() {
 	})
 
 	It("does not collect opsmanager data when --operational-data-only flag is passed", func() {
-		expectedD1Contents := "d1-content"
-		expectedD2Contents := "d2-content"
-		d1 := opsmanager.NewData(strings.NewReader(expectedD1Contents), "d1", "best-kind")
-		d2 := opsmanager.NewData(strings.NewReader(expectedD2Contents), "d2", "better-kind")
+		d1 := opsmanager.NewData(strings.NewReader("d1-content"), "d1", "best-kind")
+		d2 := opsmanager.NewData(strings.NewReader("d2-content"), "d2", "better-kind")
 		dataToWrite := []opsmanager.Data{d1, d2}
 		foundationId := "p-bosh-guid-of-some-sort"
 		foundationNickname := "some-nickname"
@@ -118,17 +127,7 @@ var _ = Describe("DataCollector", func() {
 		err := collectorOperationalDataOnly.Collect(envType, collectorVersion, foundationNickname)
 		Expect(err).NotTo(HaveOccurred())
 
-		Expect(tarWriter.AddFileCallCount()).To(Equal(2))
-
-		expectedD1Path := path.Join(collector_tar.OpsManagerCollectorDataSetId, d1.Name())
-		d1Contents, d1Path := tarWriter.AddFileArgsForCall(0)
-		expectedD2Path := path.Join(collector_tar.OpsManagerCollectorDataSetId, d2.Name())
-		d2Contents, d2Path := tarWriter.AddFileArgsForCall(1)
-
-		Expect(string(d1Contents)).To(Equal(expectedD1Contents))
-		Expect(d1Path).To(Equal(expectedD1Path))
-		Expect(string(d2Contents)).To(Equal(expectedD2Contents))
-		Expect(d2Path).To(Equal(expectedD2Path))
+		Expect(tarWriter.AddFileCallCount()).To(Equal(0))
 	})
 
 	It("returns an error when the ops manager collection errors", func() {
@@ -193,8 +192,8 @@ var _ = Describe("DataCollector", func() {
 
 		BeforeEach(func() {
 			credhubDataCollector = new(operationsfakes.FakeCredhubDataCollector)
-			collectorWithCredhub = NewCollector(omDataCollector, credhubDataCollector, nil, nil, tarWriter, uuidProvider, false)
-			collectorWithCredhubOperationalDataOnly = NewCollector(omDataCollector, credhubDataCollector, nil, nil, tarWriter, uuidProvider, true)
+			collectorWithCredhub = NewCollector(omDataCollector, CollectorOptions{Credhub: credhubDataCollector}, tarWriter, uuidProvider, allDatasets)
+			collectorWithCredhubOperationalDataOnly = NewCollector(omDataCollector, CollectorOptions{Credhub: credhubDataCollector}, tarWriter, uuidProvider, operationalDatasets)
 		})
 
 		It("collects credhub data and writes it", func() {
@@ -262,14 +261,42 @@ var _ = Describe("DataCollector", func() {
 
 			Expect(tarWriter.AddFileCallCount()).To(Equal(2))
 
-			chContents, credhubDataPath := tarWriter.AddFileArgsForCall(1)
+			chContents, credhubDataPath := tarWriter.AddFileArgsForCall(0)
 			Expect(string(chContents)).To(Equal(expectedCHContents))
 
 			expectedCredhubDataPath := path.Join(collector_tar.OpsManagerCollectorDataSetId, chData.Name())
 			Expect(credhubDataPath).To(Equal(expectedCredhubDataPath))
+			metadataContents, metadataPath := tarWriter.AddFileArgsForCall(1)
+			Expect(metadataPath).To(Equal(path.Join(collector_tar.OpsManagerCollectorDataSetId, collector_tar.MetadataFileName)))
+			var metadata collector_tar.Metadata
+			Expect(json.Unmarshal(metadataContents, &metadata)).To(Succeed())
+			Expect(metadata.FileDigests).To(HaveLen(1))
+			Expect(metadata.FileDigests[0].Name).To(Equal(chData.Name()))
 			Expect(tarWriter.CloseCallCount()).To(Equal(1))
 		})
 
+		It("writes the Ops Manager metadata when only credhub is selected", func() {
+			omDataCollector.CollectReturns([]opsmanager.Data{}, "some-foundation-id", nil)
+			chData := credhub.NewData(strings.NewReader("ch-content"))
+			credhubDataCollector.CollectReturns(chData, nil)
+
+			credhubOnlyCollector := NewCollector(omDataCollector, CollectorOptions{Credhub: credhubDataCollector}, tarWriter, uuidProvider, NewDatasets(CredhubDataset))
+			Expect(credhubOnlyCollector.Collect("most-production", "0.0.1-version", "some-nickname")).To(Succeed())
+
+			Expect(tarWriter.AddFileCallCount()).To(Equal(2))
+			_, credhubDataPath := tarWriter.AddFileArgsForCall(0)
+			Expect(credhubDataPath).To(Equal(path.Join(collector_tar.OpsManagerCollectorDataSetId, chData.Name())))
+
+			metadataContents, metadataPath := tarWriter.AddFileArgsForCall(1)
+			Expect(metadataPath).To(Equal(path.Join(collector_tar.OpsManagerCollectorDataSetId, collector_tar.MetadataFileName)))
+			var metadata collector_tar.Metadata
+			Expect(json.Unmarshal(metadataContents, &metadata)).To(Succeed())
+			Expect(metadata.FoundationId).To(Equal("some-foundation-id"))
+			Expect(metadata.EnvType).To(Equal("most-production"))
+			Expect(metadata.FileDigests).To(HaveLen(1))
+			Expect(metadata.FileDigests[0].Name).To(Equal(chData.Name()))
+		})
+
 		It("returns an error when the credhub collection errors", func() {
 			credhubDataCollector.CollectReturns(credhub.Data{}, errors.New("collecting is hard"))
 
@@ -317,8 +344,8 @@ var _ = Describe("DataCollector", func() {
 
 		BeforeEach(func() {
 			consumptionDataCollector = new(operationsfakes.FakeConsumptionDataCollector)
-			collectorWithConsumption = NewCollector(omDataCollector, nil, consumptionDataCollector, nil, tarWriter, uuidProvider, false)
-			collectorWithConsumptionOperationalDataOnly = NewCollector(omDataCollector, nil, consumptionDataCollector, nil, tarWriter, uuidProvider, true)
+			collectorWithConsumption = NewCollector(omDataCollector, CollectorOptions{Consumption: consumptionDataCollector}, tarWriter, uuidProvider, allDatasets)
+			collectorWithConsumptionOperationalDataOnly = NewCollector(omDataCollector, CollectorOptions{Consumption: consumptionDataCollector}, tarWriter, uuidProvider, operationalDatasets)
 		})
 
 		It("collects consumption data and writes it", func() {
@@ -404,20 +431,20 @@ var _ = Describe("DataCollector", func() {
 			err := collectorWithConsumptionOperationalDataOnly.Collect(envType, collectorVersion, foundationNickname)
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(tarWriter.AddFileCallCount()).To(Equal(4))
+			Expect(tarWriter.AddFileCallCount()).To(Equal(3))
 
 			expectedAppUsageConsumptionDataPath := path.Join(collector_tar.UsageServiceCollectorDataSetId, appUsageConsumptionData.Name())
-			appUsageConsumptionContents, appUsageConsumptionDataPath := tarWriter.AddFileArgsForCall(1)
+			appUsageConsumptionContents, appUsageConsumptionDataPath := tarWriter.AddFileArgsForCall(0)
 			Expect(string(appUsageConsumptionContents)).To(Equal(expectedAppUsageConsumptionContents))
 			Expect(appUsageConsumptionDataPath).To(Equal(expectedAppUsageConsumptionDataPath))
 
 			expectedServiceUsageConsumptionDataPath := path.Join(collector_tar.UsageServiceCollectorDataSetId, serviceUsageConsumptionData.Name())
-			serviceUsageConsumptionContents, serviceConsumptionDataPath := tarWriter.AddFileArgsForCall(2)
+			serviceUsageConsumptionContents, serviceConsumptionDataPath := tarWriter.AddFileArgsForCall(1)
 			Expect(string(serviceUsageConsumptionContents)).To(Equal(expectedServiceUsageConsumptionContents))
 			Expect(serviceConsumptionDataPath).To(Equal(expectedServiceUsageConsumptionDataPath))
 
 			expectedMetadataPath := path.Join(collector_tar.UsageServiceCollectorDataSetId, collector_tar.MetadataFileName)
-			metadataContents, metadataPath := tarWriter.AddFileArgsForCall(3)
+			metadataContents, metadataPath := tarWriter.AddFileArgsForCall(2)
 			Expect(metadataPath).To(Equal(expectedMetadataPath))
 
 			var metadata collector_tar.Metadata
@@ -547,21 +574,445 @@ var _ = Describe("DataCollector", func() {
 		})
 	})
 
-	Describe("core consumption collection", func() {
+	Describe("cf inventory collection", func() {
+		var (
+			collectorWithInventory *CollectExecutor
+			cfInventoryDC          *operationsfakes.FakeCfInventoryDataCollector
+		)
+
+		BeforeEach(func() {
+			cfInventoryDC = new(operationsfakes.FakeCfInventoryDataCollector)
+			collectorWithInventory = NewCollector(omDataCollector, CollectorOptions{CfInventory: cfInventoryDC}, tarWriter, uuidProvider, allDatasets)
+		})
+
+		It("writes the inventory to its own dataset with its own metadata", func() {
+			omDataCollector.CollectReturns([]opsmanager.Data{}, "p-bosh-guid-of-some-sort", nil)
+
+			expectedContents := "inventory-content"
+			md5sum := md5.Sum([]byte(expectedContents))
+			inventoryData := cfinventory.NewData(strings.NewReader(expectedContents), cfinventory.PlatformInventoryDataType)
+			cfInventoryDC.CollectReturns([]cfinventory.Data{inventoryData}, nil)
+
+			err := collectorWithInventory.Collect("most-production", "0.0.1-version", "some-nickname")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(tarWriter.AddFileCallCount()).To(Equal(3))
+			contents, dataPath := tarWriter.AddFileArgsForCall(1)
+			Expect(string(contents)).To(Equal(expectedContents))
+			Expect(dataPath).To(Equal(path.Join(cfinventory.CfInventoryCollectorDataSetId, cfinventory.PlatformInventoryDataType)))
+
+			metadataContents, metadataPath := tarWriter.AddFileArgsForCall(2)
+			Expect(metadataPath).To(Equal(path.Join(cfinventory.CfInventoryCollectorDataSetId, collector_tar.MetadataFileName)))
+
+			var metadata collector_tar.Metadata
+			Expect(json.Unmarshal(metadataContents, &metadata)).To(Succeed())
+			Expect(metadata.CollectionId).To(Equal(uuidString))
+			Expect(metadata.FoundationId).To(Equal("p-bosh-guid-of-some-sort"))
+			Expect(metadata.FileDigests).To(ConsistOf(collector_tar.FileDigest{
+				Name:        inventoryData.Name(),
+				MimeType:    inventoryData.MimeType(),
+				MD5Checksum: base64.StdEncoding.EncodeToString(md5sum[:]),
+				ProductType: inventoryData.Type(),
+				DataType:    inventoryData.DataType(),
+			}))
+		})
+
+		It("fails when the inventory cannot be collected", func() {
+			cfInventoryDC.CollectReturns(nil, errors.New("inventory is hard"))
+
+			err := collectorWithInventory.Collect("", "", "")
+			Expect(err).To(MatchError(ContainSubstring(CfInventoryCollectFailureMessage)))
+			Expect(err).To(MatchError(ContainSubstring("inventory is hard")))
+		})
+	})
+
+	Describe("bosh director collection", func() {
+		var (
+			collectorWithBosh *CollectExecutor
+			boshDC            *operationsfakes.FakeBoshDataCollector
+		)
+
+		BeforeEach(func() {
+			boshDC = new(operationsfakes.FakeBoshDataCollector)
+			collectorWithBosh = NewCollector(omDataCollector, CollectorOptions{Bosh: boshDC}, tarWriter, uuidProvider, allDatasets)
+		})
+
+		It("writes the director data to its own dataset with its own metadata", func() {
+			omDataCollector.CollectReturns([]opsmanager.Data{}, "p-bosh-guid-of-some-sort", nil)
+
+			expectedContents := "deployments-content"
+			md5sum := md5.Sum([]byte(expectedContents))
+			deploymentsData := bosh.NewData(strings.NewReader(expectedContents), bosh.DeploymentsDataType)
+			boshDC.CollectReturns([]bosh.Data{deploymentsData}, nil)
+
+			err := collectorWithBosh.Collect("most-production", "0.0.1-version", "some-nickname")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(tarWriter.AddFileCallCount()).To(Equal(3))
+			contents, dataPath := tarWriter.AddFileArgsForCall(1)
+			Expect(string(contents)).To(Equal(expectedContents))
+			Expect(dataPath).To(Equal(path.Join(bosh.BoshCollectorDataSetId, bosh.DeploymentsDataType)))
+
+			metadataContents, metadataPath := tarWriter.AddFileArgsForCall(2)
+			Expect(metadataPath).To(Equal(path.Join(bosh.BoshCollectorDataSetId, collector_tar.MetadataFileName)))
+
+			var metadata collector_tar.Metadata
+			Expect(json.Unmarshal(metadataContents, &metadata)).To(Succeed())
+			Expect(metadata.CollectionId).To(Equal(uuidString))
+			Expect(metadata.FoundationId).To(Equal("p-bosh-guid-of-some-sort"))
+			Expect(metadata.FileDigests).To(ConsistOf(collector_tar.FileDigest{
+				Name:        deploymentsData.Name(),
+				MimeType:    deploymentsData.MimeType(),
+				MD5Checksum: base64.StdEncoding.EncodeToString(md5sum[:]),
+				ProductType: deploymentsData.Type(),
+				DataType:    deploymentsData.DataType(),
+			}))
+		})
+
+		It("fails when the director cannot be read", func() {
+			boshDC.CollectReturns(nil, errors.New("directing is hard"))
+
+			err := collectorWithBosh.Collect("", "", "")
+			Expect(err).To(MatchError(ContainSubstring(BoshCollectFailureMessage)))
+			Expect(err).To(MatchError(ContainSubstring("directing is hard")))
+		})
+	})
+
+	Describe("lifecycle analysis", func() {
 		var (
-			coreConsumptionDC  *operationsfakes.FakeCoreConsumptionDataCollector
-			collectorOldOpsMan *CollectExecutor
+			collectorWithAnalysis *CollectExecutor
+			boshDC                *operationsfakes.FakeBoshDataCollector
+			analyzer              *operationsfakes.FakeLifecycleAnalyzer
 		)
 
+		BeforeEach(func() {
+			boshDC = new(operationsfakes.FakeBoshDataCollector)
+			analyzer = new(operationsfakes.FakeLifecycleAnalyzer)
+			collectorWithAnalysis = NewCollector(omDataCollector, CollectorOptions{Bosh: boshDC, Lifecycle: analyzer}, tarWriter, uuidProvider, allDatasets)
+		})
+
+		It("analyzes the collected data and writes the findings to their own dataset", func() {
+			omData := opsmanager.NewData(strings.NewReader("diagnostic-content"), collector_tar.OpsManagerProductType, collector_tar.DiagnosticReportDataType)
+			omDataCollector.CollectReturns([]opsmanager.Data{omData}, "p-bosh-guid-of-some-sort", nil)
+			boshDC.CollectReturns([]bosh.Data{bosh.NewData(strings.NewReader("deployments-content"), bosh.DeploymentsDataType)}, nil)
+
+			expectedContents := "findings-content"
+			md5sum := md5.Sum([]byte(expectedContents))
+			findingsData := lifecycle.NewData(strings.NewReader(expectedContents), lifecycle.FindingsDataType)
+			var analyzedFiles map[string][]byte
+			analyzer.AnalyzeStub = func(files map[string][]byte) ([]lifecycle.Data, error) {
+				analyzedFiles = map[string][]byte{}
+				for name, contents := range files {
+					analyzedFiles[name] = contents
+				}
+				return []lifecycle.Data{findingsData}, nil
+			}
+
+			err := collectorWithAnalysis.Collect("most-production", "0.0.1-version", "some-nickname")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(analyzer.AnalyzeCallCount()).To(Equal(1))
+			Expect(analyzedFiles).To(Equal(map[string][]byte{
+				path.Join(collector_tar.OpsManagerCollectorDataSetId, omData.Name()): []byte("diagnostic-content"),
+				path.Join(bosh.BoshCollectorDataSetId, bosh.DeploymentsDataType):     []byte("deployments-content"),
+			}))
+
+			Expect(tarWriter.AddFileCallCount()).To(Equal(6))
+			contents, dataPath := tarWriter.AddFileArgsForCall(4)
+			Expect(string(contents)).To(Equal(expectedContents))
+			Expect(dataPath).To(Equal(path.Join(lifecycle.LifecycleDataSetId, lifecycle.FindingsDataType)))
+
+			metadataContents, metadataPath := tarWriter.AddFileArgsForCall(5)
+			Expect(metadataPath).To(Equal(path.Join(lifecycle.LifecycleDataSetId, collector_tar.MetadataFileName)))
+
+			var metadata collector_tar.Metadata
+			Expect(json.Unmarshal(metadataContents, &metadata)).To(Succeed())
+			Expect(metadata.FoundationId).To(Equal("p-bosh-guid-of-some-sort"))
+			Expect(metadata.FileDigests).To(ConsistOf(collector_tar.FileDigest{
+				Name:        findingsData.Name(),
+				MimeType:    findingsData.MimeType(),
+				MD5Checksum: base64.StdEncoding.EncodeToString(md5sum[:]),
+				ProductType: findingsData.Type(),
+				DataType:    findingsData.DataType(),
+			}))
+		})
+
+		It("fails when the analysis fails", func() {
+			analyzer.AnalyzeReturns(nil, errors.New("analysis is hard"))
+
+			err := collectorWithAnalysis.Collect("", "", "")
+			Expect(err).To(MatchError(ContainSubstring(LifecycleAnalysisFailureMessage)))
+			Expect(err).To(MatchError(ContainSubstring("analysis is hard")))
+		})
+	})
+
+	Describe("capacity analysis", func() {
+		var (
+			collectorWithCapacity *CollectExecutor
+			analyzer              *operationsfakes.FakeCapacityAnalyzer
+		)
+
+		BeforeEach(func() {
+			analyzer = new(operationsfakes.FakeCapacityAnalyzer)
+			collectorWithCapacity = NewCollector(omDataCollector, CollectorOptions{Capacity: analyzer}, tarWriter, uuidProvider, allDatasets)
+		})
+
+		It("analyzes the collected data and writes the capacity model to its own dataset", func() {
+			omData := opsmanager.NewData(strings.NewReader("vm-types-content"), collector_tar.OpsManagerProductType, collector_tar.VmTypesDataType)
+			omDataCollector.CollectReturns([]opsmanager.Data{omData}, "p-bosh-guid-of-some-sort", nil)
+
+			expectedContents := "capacity-content"
+			md5sum := md5.Sum([]byte(expectedContents))
+			modelData := capacity.NewData(strings.NewReader(expectedContents), capacity.ModelDataType)
+			var analyzedFiles map[string][]byte
+			analyzer.AnalyzeStub = func(files map[string][]byte) ([]capacity.Data, error) {
+				analyzedFiles = map[string][]byte{}
+				for name, contents := range files {
+					analyzedFiles[name] = contents
+				}
+				return []capacity.Data{modelData}, nil
+			}
+
+			err := collectorWithCapacity.Collect("most-production", "0.0.1-version", "some-nickname")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(analyzer.AnalyzeCallCount()).To(Equal(1))
+			Expect(analyzedFiles).To(Equal(map[string][]byte{
+				path.Join(collector_tar.OpsManagerCollectorDataSetId, omData.Name()): []byte("vm-types-content"),
+			}))
+
+			Expect(tarWriter.AddFileCallCount()).To(Equal(4))
+			contents, dataPath := tarWriter.AddFileArgsForCall(2)
+			Expect(string(contents)).To(Equal(expectedContents))
+			Expect(dataPath).To(Equal(path.Join(capacity.CapacityDataSetId, capacity.ModelDataType)))
+
+			metadataContents, metadataPath := tarWriter.AddFileArgsForCall(3)
+			Expect(metadataPath).To(Equal(path.Join(capacity.CapacityDataSetId, collector_tar.MetadataFileName)))
+
+			var metadata collector_tar.Metadata
+			Expect(json.Unmarshal(metadataContents, &metadata)).To(Succeed())
+			Expect(metadata.FoundationId).To(Equal("p-bosh-guid-of-some-sort"))
+			Expect(metadata.FileDigests).To(ConsistOf(collector_tar.FileDigest{
+				Name:        modelData.Name(),
+				MimeType:    modelData.MimeType(),
+				MD5Checksum: base64.StdEncoding.EncodeToString(md5sum[:]),
+				ProductType: modelData.Type(),
+				DataType:    modelData.DataType(),
+			}))
+		})
+
+		It("fails when the analysis fails", func() {
+			analyzer.AnalyzeReturns(nil, errors.New("capacity is hard"))
+
+			err := collectorWithCapacity.Collect("", "", "")
+			Expect(err).To(MatchError(ContainSubstring(CapacityAnalysisFailureMessage)))
+			Expect(err).To(MatchError(ContainSubstring("capacity is hard")))
+		})
+	})
+
+	Describe("lint", func() {
+		var (
+			collectorWithLint *CollectExecutor
+			capacityAnalyzer  *operationsfakes.FakeCapacityAnalyzer
+			analyzer          *operationsfakes.FakeLintAnalyzer
+		)
+
+		BeforeEach(func() {
+			capacityAnalyzer = new(operationsfakes.FakeCapacityAnalyzer)
+			analyzer = new(operationsfakes.FakeLintAnalyzer)
+			collectorWithLint = NewCollector(omDataCollector, CollectorOptions{Capacity: capacityAnalyzer, Lint: analyzer}, tarWriter, uuidProvider, allDatasets)
+		})
+
+		It("runs the rules over the collected data and the capacity model, and writes the findings to their own dataset", func() {
+			omData := opsmanager.NewData(strings.NewReader("vm-types-content"), collector_tar.OpsManagerProductType, collector_tar.VmTypesDataType)
+			omDataCollector.CollectReturns([]opsmanager.Data{omData}, "p-bosh-guid-of-some-sort", nil)
+			capacityAnalyzer.AnalyzeReturns([]capacity.Data{capacity.NewData(strings.NewReader("capacity-content"), capacity.ModelDataType)}, nil)
+
+			expectedContents := "lint-content"
+			md5sum := md5.Sum([]byte(expectedContents))
+			findingsData := lint.NewData(strings.NewReader(expectedContents), lint.FindingsDataType)
+			var analyzedFiles map[string][]byte
+			analyzer.AnalyzeStub = func(files map[string][]byte) ([]lint.Data, error) {
+				analyzedFiles = map[string][]byte{}
+				for name, contents := range files {
+					analyzedFiles[name] = contents
+				}
+				return []lint.Data{findingsData}, nil
+			}
+
+			err := collectorWithLint.Collect("most-production", "0.0.1-version", "some-nickname")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(analyzer.AnalyzeCallCount()).To(Equal(1))
+			Expect(analyzedFiles).To(Equal(map[string][]byte{
+				path.Join(collector_tar.OpsManagerCollectorDataSetId, omData.Name()): []byte("vm-types-content"),
+				path.Join(capacity.CapacityDataSetId, capacity.ModelDataType):        []byte("capacity-content"),
+			}))
+
+			Expect(tarWriter.AddFileCallCount()).To(Equal(6))
+			contents, dataPath := tarWriter.AddFileArgsForCall(4)
+			Expect(string(contents)).To(Equal(expectedContents))
+			Expect(dataPath).To(Equal(path.Join(lint.LintDataSetId, lint.FindingsDataType)))
+
+			metadataContents, metadataPath := tarWriter.AddFileArgsForCall(5)
+			Expect(metadataPath).To(Equal(path.Join(lint.LintDataSetId, collector_tar.MetadataFileName)))
+
+			var metadata collector_tar.Metadata
+			Expect(json.Unmarshal(metadataContents, &metadata)).To(Succeed())
+			Expect(metadata.FoundationId).To(Equal("p-bosh-guid-of-some-sort"))
+			Expect(metadata.FileDigests).To(ConsistOf(collector_tar.FileDigest{
+				Name:        findingsData.Name(),
+				MimeType:    findingsData.MimeType(),
+				MD5Checksum: base64.StdEncoding.EncodeToString(md5sum[:]),
+				ProductType: findingsData.Type(),
+				DataType:    findingsData.DataType(),
+			}))
+		})
+
+		It("fails when the rules fail", func() {
+			analyzer.AnalyzeReturns(nil, errors.New("linting is hard"))
+
+			err := collectorWithLint.Collect("", "", "")
+			Expect(err).To(MatchError(ContainSubstring(LintFailureMessage)))
+			Expect(err).To(MatchError(ContainSubstring("linting is hard")))
+		})
+	})
+
+	Describe("pseudonymization", func() {
+		var (
+			pseudonymizingCollector *CollectExecutor
+			capacityAnalyzer        *operationsfakes.FakeCapacityAnalyzer
+			pseudonymizer           *operationsfakes.FakePseudonymizer
+		)
+
+		BeforeEach(func() {
+			capacityAnalyzer = new(operationsfakes.FakeCapacityAnalyzer)
+			pseudonymizer = new(operationsfakes.FakePseudonymizer)
+			pseudonymizer.PseudonymizeStub = func(contents []byte) ([]byte, error) {
+				return []byte("pseudonymized " + string(contents)), nil
+			}
+			pseudonymizer.FoundationIDReturns("guid-pseudonym")
+			pseudonymizingCollector = NewCollector(omDataCollector, CollectorOptions{Capacity: capacityAnalyzer, Pseudonymizer: pseudonymizer}, tarWriter, uuidProvider, allDatasets)
+		})
+
+		It("pseudonymizes every file before it is analyzed and written, and the foundation id", func() {
+			omData := opsmanager.NewData(strings.NewReader("vm-types-content"), collector_tar.OpsManagerProductType, collector_tar.VmTypesDataType)
+			omDataCollector.CollectReturns([]opsmanager.Data{omData}, "p-bosh-guid-of-some-sort", nil)
+			capacityAnalyzer.AnalyzeReturns([]capacity.Data{capacity.NewData(strings.NewReader("capacity-content"), capacity.ModelDataType)}, nil)
+
+			err := pseudonymizingCollector.Collect("most-production", "0.0.1-version", "some-nickname")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(pseudonymizer.FoundationIDCallCount()).To(Equal(1))
+			Expect(pseudonymizer.FoundationIDArgsForCall(0)).To(Equal("p-bosh-guid-of-some-sort"))
+			Expect(capacityAnalyzer.AnalyzeArgsForCall(0)).To(HaveKeyWithValue(path.Join(collector_tar.OpsManagerCollectorDataSetId, omData.Name()), []byte("pseudonymized vm-types-content")))
+
+			Expect(tarWriter.AddFileCallCount()).To(Equal(4))
+			contents, _ := tarWriter.AddFileArgsForCall(0)
+			Expect(string(contents)).To(Equal("pseudonymized vm-types-content"))
+			contents, _ = tarWriter.AddFileArgsForCall(2)
+			Expect(string(contents)).To(Equal("pseudonymized capacity-content"))
+
+			metadataContents, _ := tarWriter.AddFileArgsForCall(1)
+			var metadata collector_tar.Metadata
+			Expect(json.Unmarshal(metadataContents, &metadata)).To(Succeed())
+			Expect(metadata.FoundationId).To(Equal("guid-pseudonym"))
+			md5sum := md5.Sum([]byte("pseudonymized vm-types-content"))
+			Expect(metadata.FileDigests[0].MD5Checksum).To(Equal(base64.StdEncoding.EncodeToString(md5sum[:])))
+		})
+
+		It("fails when the data cannot be pseudonymized", func() {
+			omDataCollector.CollectReturns([]opsmanager.Data{opsmanager.NewData(strings.NewReader(""), "", "")}, "", nil)
+			pseudonymizer.PseudonymizeReturns(nil, errors.New("hiding is hard"))
+
+			err := pseudonymizingCollector.Collect("", "", "")
+			Expect(err).To(MatchError(ContainSubstring(PseudonymizeFailureMessage)))
+			Expect(err).To(MatchError(ContainSubstring("hiding is hard")))
+		})
+	})
+
+	Describe("secret scanning", func() {
+		var (
+			scanningCollector *CollectExecutor
+			capacityAnalyzer  *operationsfakes.FakeCapacityAnalyzer
+			secretScanner     *operationsfakes.FakeSecretScanner
+		)
+
+		BeforeEach(func() {
+			capacityAnalyzer = new(operationsfakes.FakeCapacityAnalyzer)
+			secretScanner = new(operationsfakes.FakeSecretScanner)
+			secretScanner.ScanStub = func(filePath string, contents []byte) ([]byte, error) {
+				return []byte("scanned " + string(contents)), nil
+			}
+			scanningCollector = NewCollector(omDataCollector, CollectorOptions{Capacity: capacityAnalyzer, SecretScanner: secretScanner}, tarWriter, uuidProvider, allDatasets)
+		})
+
+		It("scans every file before it is analyzed and written", func() {
+			omData := opsmanager.NewData(strings.NewReader("vm-types-content"), collector_tar.OpsManagerProductType, collector_tar.VmTypesDataType)
+			omDataCollector.CollectReturns([]opsmanager.Data{omData}, "", nil)
+			capacityAnalyzer.AnalyzeReturns([]capacity.Data{capacity.NewData(strings.NewReader("capacity-content"), capacity.ModelDataType)}, nil)
+
+			err := scanningCollector.Collect("", "", "")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(secretScanner.ScanCallCount()).To(Equal(2))
+			filePath, contents := secretScanner.ScanArgsForCall(0)
+			Expect(filePath).To(Equal(path.Join(collector_tar.OpsManagerCollectorDataSetId, omData.Name())))
+			Expect(string(contents)).To(Equal("vm-types-content"))
+			filePath, _ = secretScanner.ScanArgsForCall(1)
+			Expect(filePath).To(Equal(path.Join(capacity.CapacityDataSetId, capacity.ModelDataType)))
+
+			Expect(capacityAnalyzer.AnalyzeArgsForCall(0)).To(HaveKeyWithValue(path.Join(collector_tar.OpsManagerCollectorDataSetId, omData.Name()), []byte("scanned vm-types-content")))
+			contents, _ = tarWriter.AddFileArgsForCall(0)
+			Expect(string(contents)).To(Equal("scanned vm-types-content"))
+
+			metadataContents, _ := tarWriter.AddFileArgsForCall(1)
+			var metadata collector_tar.Metadata
+			Expect(json.Unmarshal(metadataContents, &metadata)).To(Succeed())
+			md5sum := md5.Sum([]byte("scanned vm-types-content"))
+			Expect(metadata.FileDigests[0].MD5Checksum).To(Equal(base64.StdEncoding.EncodeToString(md5sum[:])))
+		})
+
+		It("fails without writing the file when the scan fails", func() {
+			omDataCollector.CollectReturns([]opsmanager.Data{opsmanager.NewData(strings.NewReader(""), "", "")}, "", nil)
+			secretScanner.ScanReturns(nil, errors.New("a secret"))
+
+			err := scanningCollector.Collect("", "", "")
+			Expect(err).To(MatchError(ContainSubstring(SecretScanFailureMessage)))
+			Expect(err).To(MatchError(ContainSubstring("a secret")))
+			Expect(tarWriter.AddFileCallCount()).To(Equal(0))
+		})
+	})
+
+	Describe("core consumption collection", func() {
+		var coreConsumptionDC *operationsfakes.FakeCoreConsumptionDataCollector
+
 		BeforeEach(func() {
 			coreConsumptionDC = new(operationsfakes.FakeCoreConsumptionDataCollector)
 			coreConsumptionDC.CollectReturns([]coreconsumption.Data{}, errors.New("Can't collect Core Consumption"))
-			collectorOldOpsMan = NewCollector(omDataCollector, nil, nil, coreConsumptionDC, tarWriter, uuidProvider, false)
 		})
 
-		It("Does not fail when collect fails", func() {
-			err := collectorOldOpsMan.Collect("", "", "")
-			Expect(err).To(BeNil())
+		It("fails when collect fails on a version serving the Core Counting API", func() {
+			coreCountsCollector := NewCollector(omDataCollector, CollectorOptions{CoreConsumption: coreConsumptionDC, CoreCountsRequired: true}, tarWriter, uuidProvider, allDatasets)
+			err := coreCountsCollector.Collect("", "", "")
+			Expect(err).To(MatchError(ContainSubstring(CoreCountsCollectFailureMessage)))
+			Expect(err).To(MatchError(ContainSubstring("Can't collect Core Consumption")))
+		})
+
+		It("skips the core counts when collect fails and the version is unknown", func() {
+			logOutput := gbytes.NewBuffer()
+			log.SetOutput(logOutput)
+			defer log.SetOutput(os.Stderr)
+
+			coreCountsCollector := NewCollector(omDataCollector, CollectorOptions{CoreConsumption: coreConsumptionDC}, tarWriter, uuidProvider, allDatasets)
+			Expect(coreCountsCollector.Collect("", "", "")).To(Succeed())
+			Expect(logOutput).To(gbytes.Say("Warning: core counts skipped, the Ops Manager version is unknown: " + CoreCountsCollectFailureMessage + ": Can't collect Core Consumption"))
+
+			for i := 0; i < tarWriter.AddFileCallCount(); i++ {
+				_, filePath := tarWriter.AddFileArgsForCall(i)
+				Expect(filePath).NotTo(HavePrefix(collector_tar.CoreConsumptionCollectorDataSetId))
+			}
+			Expect(tarWriter.AddFileCallCount()).To(BeNumerically(">", 0))
 		})
 	})
 
@@ -0,0 +1,121 @@
+package operations
+
+import (
+	"strings"
+
+	"github.com/pivotal-cf/aqueduct-courier/bosh"
+	"github.com/pivotal-cf/aqueduct-courier/cfinventory"
+	"github.com/pivotal-cf/aqueduct-courier/opsmanager"
+	"github.com/pivotal-cf/telemetry-utils/collector_tar"
+	"github.com/pkg/errors"
+)
+
+const (
+	OpsManagerDataset      = collector_tar.OpsManagerCollectorDataSetId
+	CredhubDataset         = "credhub"
+	UsageServiceDataset    = collector_tar.UsageServiceCollectorDataSetId
+	CoreConsumptionDataset = collector_tar.CoreConsumptionCollectorDataSetId
+	CfInventoryDataset     = cfinventory.CfInventoryCollectorDataSetId
+	BoshDirectorDataset    = bosh.BoshCollectorDataSetId
+
+	StemcellAssociationsDataset = opsmanager.StemcellAssociationsDataType
+	ErrandsDataset              = opsmanager.ErrandsDataType
+	DirectorNetworkDataset      = opsmanager.DirectorNetworkDataType
+
+	UnknownDatasetErrorFormat       = "unknown dataset %q, valid datasets are: %s"
+	NoDatasetsErrorMessage          = "no datasets selected"
+	DatasetRequiresOpsManagerFormat = "dataset %q is collected from Ops Manager and requires the %s dataset"
+)
+
+// OpsManagerDatasets are collected along with the Ops Manager data, and can
+// only be selected with it
+var OpsManagerDatasets = []string{
+	StemcellAssociationsDataset,
+	ErrandsDataset,
+	DirectorNetworkDataset,
+}
+
+// DatasetNames lists every dataset which can be selected for collection.
+// CredHub certificate data is written to the opsmanager dataset, but is
+// selected on its own.
+var DatasetNames = append([]string{
+	OpsManagerDataset,
+	CredhubDataset,
+	UsageServiceDataset,
+	CoreConsumptionDataset,
+	CfInventoryDataset,
+	BoshDirectorDataset,
+}, OpsManagerDatasets...)
+
+// Datasets is the set of datasets selected for collection
+type Datasets map[string]bool
+
+func NewDatasets(names ...string) Datasets {
+	datasets := Datasets{}
+	for _, name := range names {
+		datasets[name] = true
+	}
+	return datasets
+}
+
+// ParseDatasets reads a selection of datasets. Each name may itself be a
+// comma separated list, as when read from an environment variable.
+func ParseDatasets(names []string) (Datasets, error) {
+	datasets := Datasets{}
+	for _, list := range names {
+		for _, name := range strings.Split(list, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if !isDatasetName(name) {
+				return nil, errors.Errorf(UnknownDatasetErrorFormat, name, strings.Join(DatasetNames, ", "))
+			}
+			datasets[name] = true
+		}
+	}
+	if len(datasets) == 0 {
+		return nil, errors.New(NoDatasetsErrorMessage)
+	}
+	for _, name := range OpsManagerDatasets {
+		if datasets[name] && !datasets[OpsManagerDataset] {
+			return nil, errors.Errorf(DatasetRequiresOpsManagerFormat, name, OpsManagerDataset)
+		}
+	}
+	return datasets, nil
+}
+
+// OpsManagerDataTypes returns the optional Ops Manager data types selected
+func (d Datasets) OpsManagerDataTypes() []string {
+	var dataTypes []string
+	for _, name := range OpsManagerDatasets {
+		if d[name] {
+			dataTypes = append(dataTypes, name)
+		}
+	}
+	return dataTypes
+}
+
+func (d Datasets) Includes(name string) bool {
+	return d[name]
+}
+
+// Names returns the selected datasets in the order of DatasetNames
+func (d Datasets) Names() []string {
+	var names []string
+	for _, name := range DatasetNames {
+		if d[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func isDatasetName(name string) bool {
+	for _, datasetName := range DatasetNames {
+		if datasetName == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package operations_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotal-cf/aqueduct-courier/operations"
+)
+
+var _ = Describe("Datasets", func() {
+	It("parses dataset names, including comma separated lists", func() {
+		datasets, err := ParseDatasets([]string{"opsmanager,core_consumption", " bosh_director "})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(datasets.Includes(OpsManagerDataset)).To(BeTrue())
+		Expect(datasets.Includes(CoreConsumptionDataset)).To(BeTrue())
+		Expect(datasets.Includes(BoshDirectorDataset)).To(BeTrue())
+		Expect(datasets.Includes(UsageServiceDataset)).To(BeFalse())
+	})
+
+	It("lists the selected datasets in a stable order", func() {
+		datasets := NewDatasets(BoshDirectorDataset, OpsManagerDataset, CredhubDataset)
+		Expect(datasets.Names()).To(Equal([]string{OpsManagerDataset, CredhubDataset, BoshDirectorDataset}))
+	})
+
+	It("errors for unknown datasets", func() {
+		_, err := ParseDatasets([]string{"opsmanager,usage"})
+		Expect(err).To(MatchError(`unknown dataset "usage", valid datasets are: opsmanager, credhub, usage_service, core_consumption, cf_inventory, bosh_director, stemcell_associations, errands, director_network`))
+	})
+
+	It("lists the selected optional Ops Manager data types", func() {
+		datasets, err := ParseDatasets([]string{"director_network,opsmanager,stemcell_associations"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(datasets.OpsManagerDataTypes()).To(Equal([]string{StemcellAssociationsDataset, DirectorNetworkDataset}))
+	})
+
+	It("errors when an Ops Manager dataset is selected without the opsmanager dataset", func() {
+		_, err := ParseDatasets([]string{"errands,core_consumption"})
+		Expect(err).To(MatchError(`dataset "errands" is collected from Ops Manager and requires the opsmanager dataset`))
+	})
+
+	It("errors when no dataset is selected", func() {
+		_, err := ParseDatasets([]string{" , "})
+		Expect(err).To(MatchError(NoDatasetsErrorMessage))
+	})
+})
@@ -0,0 +1,114 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package operationsfakes
+
+import (
+	"sync"
+
+	"github.com/pivotal-cf/aqueduct-courier/lint"
+)
+
+type FakeLintAnalyzer struct {
+	AnalyzeStub        func(map[string][]byte) ([]lint.Data, error)
+	analyzeMutex       sync.RWMutex
+	analyzeArgsForCall []struct {
+		arg1 map[string][]byte
+	}
+	analyzeReturns struct {
+		result1 []lint.Data
+		result2 error
+	}
+	analyzeReturnsOnCall map[int]struct {
+		result1 []lint.Data
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeLintAnalyzer) Analyze(arg1 map[string][]byte) ([]lint.Data, error) {
+	fake.analyzeMutex.Lock()
+	ret, specificReturn := fake.analyzeReturnsOnCall[len(fake.analyzeArgsForCall)]
+	fake.analyzeArgsForCall = append(fake.analyzeArgsForCall, struct {
+		arg1 map[string][]byte
+	}{arg1})
+	stub := fake.AnalyzeStub
+	fakeReturns := fake.analyzeReturns
+	fake.recordInvocation("Analyze", []interface{}{arg1})
+	fake.analyzeMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeLintAnalyzer) AnalyzeCallCount() int {
+	fake.analyzeMutex.RLock()
+	defer fake.analyzeMutex.RUnlock()
+	return len(fake.analyzeArgsForCall)
+}
+
+func (fake *FakeLintAnalyzer) AnalyzeCalls(stub func(map[string][]byte) ([]lint.Data, error)) {
+	fake.analyzeMutex.Lock()
+	defer fake.analyzeMutex.Unlock()
+	fake.AnalyzeStub = stub
+}
+
+func (fake *FakeLintAnalyzer) AnalyzeArgsForCall(i int) map[string][]byte {
+	fake.analyzeMutex.RLock()
+	defer fake.analyzeMutex.RUnlock()
+	argsForCall := fake.analyzeArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeLintAnalyzer) AnalyzeReturns(result1 []lint.Data, result2 error) {
+	fake.analyzeMutex.Lock()
+	defer fake.analyzeMutex.Unlock()
+	fake.AnalyzeStub = nil
+	fake.analyzeReturns = struct {
+		result1 []lint.Data
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeLintAnalyzer) AnalyzeReturnsOnCall(i int, result1 []lint.Data, result2 error) {
+	fake.analyzeMutex.Lock()
+	defer fake.analyzeMutex.Unlock()
+	fake.AnalyzeStub = nil
+	if fake.analyzeReturnsOnCall == nil {
+		fake.analyzeReturnsOnCall = make(map[int]struct {
+			result1 []lint.Data
+			result2 error
+		})
+	}
+	fake.analyzeReturnsOnCall[i] = struct {
+		result1 []lint.Data
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeLintAnalyzer) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.analyzeMutex.RLock()
+	defer fake.analyzeMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeLintAnalyzer) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
@@ -0,0 +1,191 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package operationsfakes
+
+import (
+	"sync"
+)
+
+type FakePseudonymizer struct {
+	FoundationIDStub        func(string) string
+	foundationIDMutex       sync.RWMutex
+	foundationIDArgsForCall []struct {
+		arg1 string
+	}
+	foundationIDReturns struct {
+		result1 string
+	}
+	foundationIDReturnsOnCall map[int]struct {
+		result1 string
+	}
+	PseudonymizeStub        func([]byte) ([]byte, error)
+	pseudonymizeMutex       sync.RWMutex
+	pseudonymizeArgsForCall []struct {
+		arg1 []byte
+	}
+	pseudonymizeReturns struct {
+		result1 []byte
+		result2 error
+	}
+	pseudonymizeReturnsOnCall map[int]struct {
+		result1 []byte
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakePseudonymizer) FoundationID(arg1 string) string {
+	fake.foundationIDMutex.Lock()
+	ret, specificReturn := fake.foundationIDReturnsOnCall[len(fake.foundationIDArgsForCall)]
+	fake.foundationIDArgsForCall = append(fake.foundationIDArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.FoundationIDStub
+	fakeReturns := fake.foundationIDReturns
+	fake.recordInvocation("FoundationID", []interface{}{arg1})
+	fake.foundationIDMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakePseudonymizer) FoundationIDCallCount() int {
+	fake.foundationIDMutex.RLock()
+	defer fake.foundationIDMutex.RUnlock()
+	return len(fake.foundationIDArgsForCall)
+}
+
+func (fake *FakePseudonymizer) FoundationIDCalls(stub func(string) string) {
+	fake.foundationIDMutex.Lock()
+	defer fake.foundationIDMutex.Unlock()
+	fake.FoundationIDStub = stub
+}
+
+func (fake *FakePseudonymizer) FoundationIDArgsForCall(i int) string {
+	fake.foundationIDMutex.RLock()
+	defer fake.foundationIDMutex.RUnlock()
+	argsForCall := fake.foundationIDArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakePseudonymizer) FoundationIDReturns(result1 string) {
+	fake.foundationIDMutex.Lock()
+	defer fake.foundationIDMutex.Unlock()
+	fake.FoundationIDStub = nil
+	fake.foundationIDReturns = struct {
+		result1 string
+	}{result1}
+}
+
+func (fake *FakePseudonymizer) FoundationIDReturnsOnCall(i int, result1 string) {
+	fake.foundationIDMutex.Lock()
+	defer fake.foundationIDMutex.Unlock()
+	fake.FoundationIDStub = nil
+	if fake.foundationIDReturnsOnCall == nil {
+		fake.foundationIDReturnsOnCall = make(map[int]struct {
+			result1 string
+		})
+	}
+	fake.foundationIDReturnsOnCall[i] = struct {
+		result1 string
+	}{result1}
+}
+
+func (fake *FakePseudonymizer) Pseudonymize(arg1 []byte) ([]byte, error) {
+	var arg1Copy []byte
+	if arg1 != nil {
+		arg1Copy = make([]byte, len(arg1))
+		copy(arg1Copy, arg1)
+	}
+	fake.pseudonymizeMutex.Lock()
+	ret, specificReturn := fake.pseudonymizeReturnsOnCall[len(fake.pseudonymizeArgsForCall)]
+	fake.pseudonymizeArgsForCall = append(fake.pseudonymizeArgsForCall, struct {
+		arg1 []byte
+	}{arg1Copy})
+	stub := fake.PseudonymizeStub
+	fakeReturns := fake.pseudonymizeReturns
+	fake.recordInvocation("Pseudonymize", []interface{}{arg1Copy})
+	fake.pseudonymizeMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakePseudonymizer) PseudonymizeCallCount() int {
+	fake.pseudonymizeMutex.RLock()
+	defer fake.pseudonymizeMutex.RUnlock()
+	return len(fake.pseudonymizeArgsForCall)
+}
+
+func (fake *FakePseudonymizer) PseudonymizeCalls(stub func([]byte) ([]byte, error)) {
+	fake.pseudonymizeMutex.Lock()
+	defer fake.pseudonymizeMutex.Unlock()
+	fake.PseudonymizeStub = stub
+}
+
+func (fake *FakePseudonymizer) PseudonymizeArgsForCall(i int) []byte {
+	fake.pseudonymizeMutex.RLock()
+	defer fake.pseudonymizeMutex.RUnlock()
+	argsForCall := fake.pseudonymizeArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakePseudonymizer) PseudonymizeReturns(result1 []byte, result2 error) {
+	fake.pseudonymizeMutex.Lock()
+	defer fake.pseudonymizeMutex.Unlock()
+	fake.PseudonymizeStub = nil
+	fake.pseudonymizeReturns = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakePseudonymizer) PseudonymizeReturnsOnCall(i int, result1 []byte, result2 error) {
+	fake.pseudonymizeMutex.Lock()
+	defer fake.pseudonymizeMutex.Unlock()
+	fake.PseudonymizeStub = nil
+	if fake.pseudonymizeReturnsOnCall == nil {
+		fake.pseudonymizeReturnsOnCall = make(map[int]struct {
+			result1 []byte
+			result2 error
+		})
+	}
+	fake.pseudonymizeReturnsOnCall[i] = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakePseudonymizer) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.foundationIDMutex.RLock()
+	defer fake.foundationIDMutex.RUnlock()
+	fake.pseudonymizeMutex.RLock()
+	defer fake.pseudonymizeMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakePseudonymizer) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
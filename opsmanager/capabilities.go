@@ -0,0 +1,129 @@
+package opsmanager
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/pivotal-cf/telemetry-utils/collector_tar"
+	"github.com/pkg/errors"
+)
+
+const (
+	InvalidVersionErrorFormat   = "invalid Ops Manager version %q"
+	UnsupportedReasonFormat     = "unsupported on OM %s, requires %s"
+	UnsupportedDatasetFormat    = "Warning: %s skipped, unsupported on OM %s"
+	UnknownVersionCapability    = "unknown"
+	minimumVersionsSeparator    = " or "
+	versionBuildSuffixSeparator = "-"
+)
+
+// MinimumVersions is the earliest Ops Manager version serving the endpoint of
+// each dataset, keyed by data type. Endpoints backported to a patch release
+// of an older line list the first patch of every line which has them.
+var MinimumVersions = map[string][]string{
+	collector_tar.DeployedProductsDataType:       {"2.0.0"},
+	collector_tar.PendingChangesDataType:         {"2.0.0"},
+	collector_tar.ResourcesDataType:              {"2.0.0"},
+	collector_tar.PropertiesDataType:             {"2.0.0"},
+	collector_tar.VmTypesDataType:                {"2.0.0"},
+	collector_tar.InstallationsDataType:          {"2.0.0"},
+	InstallationAnalyticsDataType:                {"2.0.0"},
+	collector_tar.DiagnosticReportDataType:       {"2.0.0"},
+	collector_tar.CertificateAuthoritiesDataType: {"2.0.0"},
+	collector_tar.CertificatesDataType:           {"2.3.0"},
+	collector_tar.CoreCountsDataType:             {"2.10.58", "3.0.10"},
+	BoshCredentialsDataType:                      {"2.0.0"},
+	DeployedResourcesDataType:                    {"2.0.0"},
+	StemcellAssociationsDataType:                 {"2.6.0"},
+	ErrandsDataType:                              {"2.0.0"},
+	DirectorNetworkDataType:                      {"2.0.0"},
+	SettingsDataType:                             {"2.0.0"},
+	DeployedPropertiesDataType:                   {"2.0.0"},
+}
+
+// Capabilities decides which endpoints the targeted Ops Manager serves, based
+// on the version it reports from /api/v0/info
+type Capabilities struct {
+	rawVersion string
+	version    *version.Version
+}
+
+// NewCapabilities parses an Ops Manager version such as "3.0.10-build.123".
+// An empty version is unknown, and every endpoint is then requested.
+func NewCapabilities(omVersion string) (*Capabilities, error) {
+	if omVersion == "" {
+		return &Capabilities{}, nil
+	}
+
+	parsed, err := parseVersion(omVersion)
+	if err != nil {
+		return &Capabilities{}, errors.Wrapf(err, InvalidVersionErrorFormat, omVersion)
+	}
+	return &Capabilities{rawVersion: omVersion, version: parsed}, nil
+}
+
+// Version is the version reported by Ops Manager, including the build number
+func (c *Capabilities) Version() string {
+	return c.rawVersion
+}
+
+// Supports reports whether the endpoint of the data type is served by the
+// Ops Manager version. A version is supported when it is at least a minimum
+// on the same major.minor line, or at least the latest minimum.
+func (c *Capabilities) Supports(dataType string) bool {
+	minimums, ok := MinimumVersions[dataType]
+	if c.version == nil || !ok {
+		return true
+	}
+
+	sorted := sortedVersions(minimums)
+	for i, minimum := range sorted {
+		if c.version.LessThan(minimum) {
+			continue
+		}
+		if i == len(sorted)-1 || sameLine(c.version, minimum) {
+			return true
+		}
+	}
+	return false
+}
+
+// UnsupportedReason explains why the data type is not collected, for the
+// collection details
+func (c *Capabilities) UnsupportedReason(dataType string) string {
+	return fmt.Sprintf(UnsupportedReasonFormat, c.shortVersion(), strings.Join(MinimumVersions[dataType], minimumVersionsSeparator))
+}
+
+// UnsupportedWarning is the message logged when a dataset is skipped
+func (c *Capabilities) UnsupportedWarning(name string) string {
+	return fmt.Sprintf(UnsupportedDatasetFormat, name, c.shortVersion())
+}
+
+func (c *Capabilities) shortVersion() string {
+	if c.version == nil {
+		return UnknownVersionCapability
+	}
+	return c.version.String()
+}
+
+// parseVersion drops the build number, which go-version would otherwise read
+// as a pre-release and order before the release itself
+func parseVersion(omVersion string) (*version.Version, error) {
+	return version.NewVersion(strings.SplitN(omVersion, versionBuildSuffixSeparator, 2)[0])
+}
+
+func sortedVersions(versions []string) []*version.Version {
+	var sorted []*version.Version
+	for _, v := range versions {
+		sorted = append(sorted, version.Must(version.NewVersion(v)))
+	}
+	sort.Sort(version.Collection(sorted))
+	return sorted
+}
+
+func sameLine(a, b *version.Version) bool {
+	aSegments, bSegments := a.Segments(), b.Segments()
+	return aSegments[0] == bSegments[0] && aSegments[1] == bSegments[1]
+}
@@ -0,0 +1,55 @@
+package opsmanager_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotal-cf/aqueduct-courier/opsmanager"
+	"github.com/pivotal-cf/telemetry-utils/collector_tar"
+)
+
+var _ = Describe("Capabilities", func() {
+	DescribeTable("decides whether the version serves the core consumption endpoint",
+		func(omVersion string, expected bool) {
+			capabilities, err := NewCapabilities(omVersion)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(capabilities.Supports(collector_tar.CoreCountsDataType)).To(Equal(expected))
+		},
+		Entry("before the 2.10 backport", "2.10.57-build.3", false),
+		Entry("at the 2.10 backport", "2.10.58-build.1", true),
+		Entry("after the 2.10 backport", "2.10.66", true),
+		Entry("an older line", "2.9.20", false),
+		Entry("before the 3.0 release", "3.0.9-build.99", false),
+		Entry("at the 3.0 release", "3.0.10-build.1", true),
+		Entry("a later line", "3.1.0", true),
+		Entry("a version without a patch", "3.1-build.2", true),
+	)
+
+	It("supports every endpoint when the version is unknown", func() {
+		capabilities, err := NewCapabilities("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(capabilities.Version()).To(BeEmpty())
+		Expect(capabilities.Supports(collector_tar.CoreCountsDataType)).To(BeTrue())
+		Expect(capabilities.Supports(collector_tar.CertificatesDataType)).To(BeTrue())
+	})
+
+	It("supports data types without a minimum version", func() {
+		capabilities, err := NewCapabilities("1.0.0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(capabilities.Supports("some-new-data-type")).To(BeTrue())
+	})
+
+	It("keeps the build number in the reported version", func() {
+		capabilities, err := NewCapabilities("2.2.5-build.12")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(capabilities.Version()).To(Equal("2.2.5-build.12"))
+		Expect(capabilities.UnsupportedWarning("ops_manager_certificates")).To(Equal("Warning: ops_manager_certificates skipped, unsupported on OM 2.2.5"))
+		Expect(capabilities.UnsupportedReason(collector_tar.CoreCountsDataType)).To(Equal("unsupported on OM 2.2.5, requires 2.10.58 or 3.0.10"))
+	})
+
+	It("errors when the version cannot be parsed", func() {
+		capabilities, err := NewCapabilities("not-a-version")
+		Expect(err).To(MatchError(ContainSubstring(`invalid Ops Manager version "not-a-version"`)))
+		Expect(capabilities.Supports(collector_tar.CoreCountsDataType)).To(BeTrue())
+	})
+})
@@ -1,6 +1,8 @@
 package opsmanager
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -17,8 +19,18 @@ const (
 	DeployedProductsFailedMessage = "Failed to retrieve deployed products list from Operations Manager"
 	RequestorFailureErrorFormat   = "Failed retrieving %s %s"
 	PendingChangesExistsFormat    = "Warning: This foundation has pending changes. The collector will continue to collect but reports from the Tanzu team may represent products with pending changes and therefore staged data, rather than deployed data. List of changes:\n%s"
+	PendingChangesDeployedFormat  = "This foundation has pending changes. Product resources and properties are read from the deployed manifests, so they describe what is running. List of changes:\n%s"
 )
 
+// Optional data types are only collected when selected
+const (
+	StemcellAssociationsDataType = "stemcell_associations"
+	ErrandsDataType              = "errands"
+	DirectorNetworkDataType      = "director_network"
+)
+
+var OptionalDataTypes = []string{StemcellAssociationsDataType, ErrandsDataType, DirectorNetworkDataType}
+
 var PendingChangesExistsError = errors.New(PendingChangesExistsMessage)
 
 //go:generate counterfeiter . PendingChangesLister
@@ -35,13 +47,20 @@ type DeployedProductsLister interface {
 type OmService interface {
 	ProductResources(guid string) (io.Reader, error)
 	ProductProperties(guid string) (io.Reader, error)
+	DeployedProductResources(guid string) (io.Reader, error)
+	DeployedProductProperties(guid string) (io.Reader, error)
 	VmTypes() (io.Reader, error)
 	DiagnosticReport() (io.Reader, error)
 	DeployedProducts() (io.Reader, error)
 	Installations() (io.Reader, error)
+	InstallationAnalytics() (io.Reader, error)
 	Certificates() (io.Reader, error)
 	CertificateAuthorities() (io.Reader, error)
 	PendingChanges() (io.Reader, error)
+	StemcellAssociations() (io.Reader, error)
+	ProductErrands(guid string) (io.Reader, error)
+	Settings() (io.Reader, error)
+	DirectorNetwork() (io.Reader, error)
 }
 
 type dataRetriever func() (io.Reader, error)
@@ -52,16 +71,41 @@ type DataCollector struct {
 	opsManagerURL         string
 	pendingChangesService PendingChangesLister
 	deployProductsService DeployedProductsLister
+	permissions           *Permissions
+	capabilities          *Capabilities
+	productFilter         *ProductFilter
+	configSource          ConfigSource
+	pendingChangesPolicy  PendingChangesPolicy
+	installationGuard     *InstallationGuard
+	optionalDataTypes     []string
 	operationalDataOnly   bool
+	changedProducts       []ChangedProduct
+}
+
+type collectionDetails struct {
+	Role                 string                `json:"role"`
+	OpsManagerVersion    string                `json:"ops_manager_version,omitempty"`
+	SkippedDatasets      []SkippedDataset      `json:"skipped_datasets"`
+	ExcludedProducts     []string              `json:"excluded_products,omitempty"`
+	PendingChanges       []ChangedProduct      `json:"pending_changes,omitempty"`
+	PendingChangesPolicy string                `json:"pending_changes_policy,omitempty"`
+	Installation         *ObservedInstallation `json:"installation,omitempty"`
 }
 
-func NewDataCollector(logger *log.Logger, oms OmService, omURL string, pcs PendingChangesLister, dps DeployedProductsLister, operationalDataOnly bool) *DataCollector {
+func NewDataCollector(logger *log.Logger, oms OmService, omURL string, pcs PendingChangesLister, dps DeployedProductsLister, permissions *Permissions, capabilities *Capabilities, productFilter *ProductFilter, configSource ConfigSource, pendingChangesPolicy PendingChangesPolicy, installationGuard *InstallationGuard, optionalDataTypes []string, operationalDataOnly bool) *DataCollector {
 	return &DataCollector{
 		logger:                logger,
 		omService:             oms,
 		opsManagerURL:         omURL,
 		pendingChangesService: pcs,
 		deployProductsService: dps,
+		permissions:           permissions,
+		capabilities:          capabilities,
+		productFilter:         productFilter,
+		configSource:          configSource,
+		pendingChangesPolicy:  pendingChangesPolicy,
+		installationGuard:     installationGuard,
+		optionalDataTypes:     optionalDataTypes,
 		operationalDataOnly:   operationalDataOnly,
 	}
 }
@@ -69,6 +113,14 @@ func NewDataCollector(logger *log.Logger, oms OmService, omURL string, pcs Pendi
 func (dc *DataCollector) Collect() ([]Data, string, error) {
 	dc.logger.Printf("Collecting data from Operations Manager at %s", dc.opsManagerURL)
 
+	if dc.operationalDataOnly {
+		return dc.collectFoundationID()
+	}
+
+	if err := dc.installationGuard.Check(); err != nil {
+		return []Data{}, "", err
+	}
+
 	var foundationId string
 	pc, err := dc.pendingChangesService.ListStagedPendingChanges()
 	if err != nil {
@@ -76,7 +128,11 @@ func (dc *DataCollector) Collect() ([]Data, string, error) {
 	}
 
 	if hasPendingChanges(pc.ChangeList) {
-		dc.logger.Print(createPendingChangesWarningMessage(pc.ChangeList))
+		if dc.pendingChangesPolicy == FailOnPendingChanges {
+			dc.logger.Printf(PendingChangesFailFormat, pendingChangesList(pc.ChangeList))
+			return []Data{}, "", PendingChangesExistsError
+		}
+		dc.logger.Print(createPendingChangesWarningMessage(pc.ChangeList, dc.configSource, dc.pendingChangesPolicy))
 	}
 
 	deletedPendingChanges := getDeletedPendingChanges(pc.ChangeList)
@@ -85,14 +141,13 @@ func (dc *DataCollector) Collect() ([]Data, string, error) {
 	if err != nil {
 		return []Data{}, "", errors.Wrap(err, DeployedProductsFailedMessage)
 	}
+	dc.changedProducts = changedProducts(pc.ChangeList, pl)
 
 	var d []Data
 
-	if !dc.operationalDataOnly {
-		d, err = appendRetrievedData(d, dc.omService.DeployedProducts, collector_tar.OpsManagerProductType, collector_tar.DeployedProductsDataType)
-		if err != nil {
-			return []Data{}, "", err
-		}
+	d, err = dc.appendRetrievedData(d, dc.omService.DeployedProducts, collector_tar.OpsManagerProductType, collector_tar.DeployedProductsDataType)
+	if err != nil {
+		return []Data{}, "", err
 	}
 
 	for _, product := range pl {
@@ -100,72 +155,144 @@ func (dc *DataCollector) Collect() ([]Data, string, error) {
 			continue
 		}
 		if product.Type != collector_tar.DirectorProductType {
-			d, err = appendRetrievedData(d, dc.productResourcesCaller(product.GUID), product.Type, collector_tar.ResourcesDataType)
+			if excluded, reason := dc.productFilter.Excludes(product); excluded {
+				dc.logger.Printf(ExcludedProductFormat, product.Type, product.GUID, reason)
+				continue
+			}
+			if dc.pendingChangesPolicy == SkipChangedProducts && dc.hasChanges(product.GUID) {
+				dc.logger.Printf(ExcludedProductFormat, product.Type, product.GUID, ChangedProductReason)
+				continue
+			}
+
+			d, err = dc.appendRetrievedData(d, dc.productResourcesCaller(product.GUID), product.Type, dc.configSource.ResourcesDataType())
 			if err != nil {
 				return []Data{}, "", err
 			}
 
-			d, err = appendRetrievedData(d, dc.productPropertiesCaller(product.GUID), product.Type, collector_tar.PropertiesDataType)
+			d, err = dc.appendRetrievedData(d, dc.productPropertiesCaller(product.GUID), product.Type, dc.configSource.PropertiesDataType())
 			if err != nil {
 				return []Data{}, "", err
 			}
+
+			if dc.collects(ErrandsDataType) {
+				d, err = dc.appendRetrievedData(d, dc.productErrandsCaller(product.GUID), product.Type, ErrandsDataType)
+				if err != nil {
+					return []Data{}, "", err
+				}
+			}
 		} else {
 			foundationId = product.GUID
 		}
 	}
 
-	if !dc.operationalDataOnly {
-		d, err = appendRetrievedData(d, dc.omService.VmTypes, collector_tar.OpsManagerProductType, collector_tar.VmTypesDataType)
-		if err != nil {
-			return []Data{}, "", err
-		}
+	d, err = dc.appendRetrievedData(d, dc.omService.VmTypes, collector_tar.OpsManagerProductType, collector_tar.VmTypesDataType)
+	if err != nil {
+		return []Data{}, "", err
+	}
 
-		d, err = appendRetrievedData(d, dc.omService.DiagnosticReport, collector_tar.OpsManagerProductType, collector_tar.DiagnosticReportDataType)
-		if err != nil {
-			return []Data{}, "", err
-		}
+	d, err = dc.appendRetrievedData(d, dc.omService.DiagnosticReport, collector_tar.OpsManagerProductType, collector_tar.DiagnosticReportDataType)
+	if err != nil {
+		return []Data{}, "", err
+	}
 
-		d, err = appendRetrievedData(d, dc.omService.Installations, collector_tar.OpsManagerProductType, collector_tar.InstallationsDataType)
-		if err != nil {
-			return []Data{}, "", err
-		}
+	d, err = dc.appendRetrievedData(d, dc.omService.Settings, collector_tar.OpsManagerProductType, SettingsDataType)
+	if err != nil {
+		return []Data{}, "", err
+	}
 
-		d, err = appendRetrievedData(d, dc.omService.Certificates, collector_tar.OpsManagerProductType, collector_tar.CertificatesDataType)
-		if err != nil {
-			return []Data{}, "", err
-		}
+	d, err = dc.appendRetrievedData(d, dc.omService.Installations, collector_tar.OpsManagerProductType, collector_tar.InstallationsDataType)
+	if err != nil {
+		return []Data{}, "", err
+	}
+
+	d, err = dc.appendRetrievedData(d, dc.omService.InstallationAnalytics, collector_tar.OpsManagerProductType, InstallationAnalyticsDataType)
+	if err != nil {
+		return []Data{}, "", err
+	}
+
+	d, err = dc.appendRetrievedData(d, dc.omService.Certificates, collector_tar.OpsManagerProductType, collector_tar.CertificatesDataType)
+	if err != nil {
+		return []Data{}, "", err
+	}
 
-		d, err = appendRetrievedData(d, dc.omService.CertificateAuthorities, collector_tar.OpsManagerProductType, collector_tar.CertificateAuthoritiesDataType)
+	d, err = dc.appendRetrievedData(d, dc.omService.CertificateAuthorities, collector_tar.OpsManagerProductType, collector_tar.CertificateAuthoritiesDataType)
+	if err != nil {
+		return []Data{}, "", err
+	}
+
+	d, err = dc.appendRetrievedData(d, dc.omService.PendingChanges, collector_tar.OpsManagerProductType, collector_tar.PendingChangesDataType)
+	if err != nil {
+		return []Data{}, "", err
+	}
+
+	if dc.collects(StemcellAssociationsDataType) {
+		d, err = dc.appendRetrievedData(d, dc.omService.StemcellAssociations, collector_tar.OpsManagerProductType, StemcellAssociationsDataType)
 		if err != nil {
 			return []Data{}, "", err
 		}
+	}
 
-		d, err = appendRetrievedData(d, dc.omService.PendingChanges, collector_tar.OpsManagerProductType, collector_tar.PendingChangesDataType)
+	if dc.collects(DirectorNetworkDataType) {
+		d, err = dc.appendRetrievedData(d, dc.omService.DirectorNetwork, collector_tar.OpsManagerProductType, DirectorNetworkDataType)
 		if err != nil {
 			return []Data{}, "", err
 		}
 	}
 
-	if !dc.operationalDataOnly {
-		return d, foundationId, nil
-	} else {
-		return []Data{}, foundationId, nil
+	d, err = dc.appendCollectionDetails(d)
+	if err != nil {
+		return []Data{}, "", err
 	}
+	return d, foundationId, nil
+}
 
+// collectFoundationID reads only the foundation ID, for a collection without
+// the Ops Manager data. No product data is requested, and neither running
+// installations nor pending changes stop the collection, since the Ops
+// Manager data they concern is not written.
+func (dc *DataCollector) collectFoundationID() ([]Data, string, error) {
+	pl, err := dc.deployProductsService.ListDeployedProducts()
+	if err != nil {
+		return []Data{}, "", errors.Wrap(err, DeployedProductsFailedMessage)
+	}
+	for _, product := range pl {
+		if product.Type == collector_tar.DirectorProductType {
+			return []Data{}, product.GUID, nil
+		}
+	}
+	return []Data{}, "", nil
 }
 
 func (dc DataCollector) productResourcesCaller(guid string) dataRetriever {
 	return func() (io.Reader, error) {
+		if dc.configSource == DeployedConfigSource {
+			return dc.omService.DeployedProductResources(guid)
+		}
 		return dc.omService.ProductResources(guid)
 	}
 }
 
 func (dc DataCollector) productPropertiesCaller(guid string) dataRetriever {
 	return func() (io.Reader, error) {
+		if dc.configSource == DeployedConfigSource {
+			return dc.omService.DeployedProductProperties(guid)
+		}
 		return dc.omService.ProductProperties(guid)
 	}
 }
 
+func (dc DataCollector) productErrandsCaller(guid string) dataRetriever {
+	return func() (io.Reader, error) {
+		return dc.omService.ProductErrands(guid)
+	}
+}
+
+// collects reports whether an optional data type was selected. Optional data
+// is not collected with operational data only.
+func (dc DataCollector) collects(dataType string) bool {
+	return !dc.operationalDataOnly && sliceContains(dc.optionalDataTypes, dataType)
+}
+
 func hasPendingChanges(changeList []api.ProductChange) bool {
 	for _, change := range changeList {
 		if change.Action != "unchanged" {
@@ -194,21 +321,90 @@ func sliceContains(s []string, test string) bool {
 	return false
 }
 
-func createPendingChangesWarningMessage(changeList []api.ProductChange) string {
+func createPendingChangesWarningMessage(changeList []api.ProductChange, configSource ConfigSource, policy PendingChangesPolicy) string {
+	switch {
+	case policy == SkipChangedProducts:
+		return fmt.Sprintf(PendingChangesSkipFormat, pendingChangesList(changeList))
+	case configSource == DeployedConfigSource:
+		return fmt.Sprintf(PendingChangesDeployedFormat, pendingChangesList(changeList))
+	}
+	return fmt.Sprintf(PendingChangesExistsFormat, pendingChangesList(changeList))
+}
+
+func pendingChangesList(changeList []api.ProductChange) string {
 	var changesList []string
 	for _, change := range changeList {
 		if change.Action != "unchanged" {
 			changesList = append(changesList, fmt.Sprintf("%s: %s", change.GUID, change.Action))
 		}
 	}
-	return fmt.Sprintf(PendingChangesExistsFormat, strings.Join(changesList, "\n"))
+	return strings.Join(changesList, "\n")
+}
+
+func (dc DataCollector) hasChanges(guid string) bool {
+	for _, changed := range dc.changedProducts {
+		if changed.GUID == guid {
+			return true
+		}
+	}
+	return false
 }
 
-func appendRetrievedData(d []Data, retriever dataRetriever, productType, dataType string) ([]Data, error) {
+// appendRetrievedData adds the retrieved data, unless the authenticated user
+// is not allowed to read it or the Ops Manager version does not serve it.
+// Skipped datasets are logged and recorded in the collection details instead
+// of failing the collection.
+func (dc DataCollector) appendRetrievedData(d []Data, retriever dataRetriever, productType, dataType string) ([]Data, error) {
+	name := NewData(nil, productType, dataType).Name()
+	if !dc.capabilities.Supports(dataType) {
+		dc.logger.Print(dc.capabilities.UnsupportedWarning(name))
+		dc.permissions.SkipUnsupported(name, dataType, dc.capabilities.UnsupportedReason(dataType))
+		return d, nil
+	}
+	if !dc.permissions.Allows(dataType) {
+		dc.logger.Printf(SkippingDatasetWarningFormat, name, dc.permissions.SkipForRole(name, dataType))
+		return d, nil
+	}
+
 	output, err := retriever()
+	if errors.As(err, &ForbiddenError{}) {
+		dc.logger.Printf(SkippingDatasetWarningFormat, name, dc.permissions.SkipForbidden(name, dataType))
+		return d, nil
+	}
 	if err != nil {
 		return d, errors.Wrap(err, fmt.Sprintf(RequestorFailureErrorFormat, productType, dataType))
 	}
 
 	return append(d, NewData(output, productType, dataType)), nil
 }
+
+// appendCollectionDetails records the role used, the Ops Manager version, any
+// skipped datasets and excluded products, the products with pending changes
+// and the latest installation. Nothing is added when none of them are known, since the
+// collection is then complete and of deployed configuration.
+func (dc DataCollector) appendCollectionDetails(d []Data) ([]Data, error) {
+	excludedProducts := dc.productFilter.ExcludedTypes()
+	if dc.permissions.Role() == RoleUnknown && dc.capabilities.Version() == "" && len(dc.permissions.Skipped()) == 0 && len(excludedProducts) == 0 && len(dc.changedProducts) == 0 && dc.installationGuard.Observed() == nil {
+		return d, nil
+	}
+
+	var pendingChangesPolicy string
+	if len(dc.changedProducts) > 0 {
+		pendingChangesPolicy = dc.pendingChangesPolicy.String()
+	}
+
+	details, err := json.Marshal(collectionDetails{
+		Role:                 dc.permissions.Role().String(),
+		OpsManagerVersion:    dc.capabilities.Version(),
+		SkippedDatasets:      dc.permissions.Skipped(),
+		ExcludedProducts:     excludedProducts,
+		PendingChanges:       dc.changedProducts,
+		PendingChangesPolicy: pendingChangesPolicy,
+		Installation:         dc.installationGuard.Observed(),
+	})
+	if err != nil {
+		return d, err
+	}
+
+	return append(d, NewData(bytes.NewReader(details), collector_tar.OpsManagerProductType, CollectionDetailsDataType)), nil
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/onsi/gomega/gbytes"
 
@@ -40,8 +41,8 @@ var _ = Describe("DataCollector", func() {
 		pendingChangesLister = new(opsmanagerfakes.FakePendingChangesLister)
 		deployedProductsLister = new(opsmanagerfakes.FakeDeployedProductsLister)
 
-		dataCollector = NewDataCollector(logger, omService, omURL, pendingChangesLister, deployedProductsLister, false)
-		dataCollectorOperationalOnly = NewDataCollector(logger, omService, omURL, pendingChangesLister, deployedProductsLister, true)
+		dataCollector = NewDataCollector(logger, omService, omURL, pendingChangesLister, deployedProductsLister, NewPermissions(RoleUnknown), &Capabilities{}, nil, StagedConfigSource, WarnOnPendingChanges, nil, nil, false)
+		dataCollectorOperationalOnly = NewDataCollector(logger, omService, omURL, pendingChangesLister, deployedProductsLister, NewPermissions(RoleUnknown), &Capabilities{}, nil, StagedConfigSource, WarnOnPendingChanges, nil, nil, true)
 	})
 
 	It("does not return an error if there are pending changes with an action other than unchanged", func() {
@@ -60,11 +61,14 @@ var _ = Describe("DataCollector", func() {
 		pendingChangesLister.ListStagedPendingChangesReturns(nonEmptyPendingChanges, nil)
 
 		data, foundationId, err := dataCollector.Collect()
-		Expect(data).To(ConsistOf(
+		Expect(data).To(HaveLen(10))
+		Expect(data[:9]).To(ConsistOf(
 			NewData(nil, collector_tar.OpsManagerProductType, collector_tar.DeployedProductsDataType),
 			NewData(nil, collector_tar.OpsManagerProductType, collector_tar.VmTypesDataType),
 			NewData(nil, collector_tar.OpsManagerProductType, collector_tar.DiagnosticReportDataType),
+			NewData(nil, collector_tar.OpsManagerProductType, SettingsDataType),
 			NewData(nil, collector_tar.OpsManagerProductType, collector_tar.InstallationsDataType),
+			NewData(nil, collector_tar.OpsManagerProductType, InstallationAnalyticsDataType),
 			NewData(nil, collector_tar.OpsManagerProductType, collector_tar.CertificatesDataType),
 			NewData(nil, collector_tar.OpsManagerProductType, collector_tar.CertificateAuthoritiesDataType),
 			NewData(nil, collector_tar.OpsManagerProductType, collector_tar.PendingChangesDataType),
@@ -73,6 +77,71 @@ var _ = Describe("DataCollector", func() {
 		Expect(err).ToNot(HaveOccurred())
 		Eventually(bufferedOutput).Should(gbytes.Say(fmt.Sprintf(PendingChangesExistsFormat, "")))
 		Eventually(bufferedOutput).Should(gbytes.Say("some-changed-guid: totally-changed"))
+
+		detailsContent, err := io.ReadAll(data[9].Content())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(detailsContent).To(MatchJSON(`{
+			"role": "unknown",
+			"skipped_datasets": [],
+			"pending_changes": [{"guid": "some-changed-guid", "action": "totally-changed"}],
+			"pending_changes_policy": "warn"
+		}`))
+	})
+
+	Context("with pending changes", func() {
+		BeforeEach(func() {
+			pendingChangesLister.ListStagedPendingChangesReturns(api.PendingChangesOutput{
+				ChangeList: []api.ProductChange{
+					{GUID: "p-bosh-guid", Action: "unchanged"},
+					{GUID: "cf-guid", Action: "update"},
+					{GUID: "new-guid", Action: "install"},
+				},
+			}, nil)
+			deployedProductsLister.ListDeployedProductsReturns(
+				[]api.DeployedProductOutput{
+					{Type: collector_tar.DirectorProductType, GUID: "p-bosh-guid"},
+					{Type: "cf", GUID: "cf-guid"},
+					{Type: "pivotal-mysql", GUID: "pivotal-mysql-guid"},
+				},
+				nil,
+			)
+		})
+
+		It("fails before collecting anything when the policy is to fail", func() {
+			dataCollector = NewDataCollector(logger, omService, omURL, pendingChangesLister, deployedProductsLister, NewPermissions(RoleUnknown), &Capabilities{}, nil, StagedConfigSource, FailOnPendingChanges, nil, nil, false)
+
+			data, foundationId, err := dataCollector.Collect()
+			Expect(err).To(Equal(PendingChangesExistsError))
+			Expect(data).To(BeEmpty())
+			Expect(foundationId).To(BeEmpty())
+			Expect(deployedProductsLister.ListDeployedProductsCallCount()).To(Equal(0))
+			Eventually(bufferedOutput).Should(gbytes.Say("This foundation has pending changes. List of changes:\ncf-guid: update\nnew-guid: install"))
+		})
+
+		It("skips resources and properties of the changed products when the policy is to skip them", func() {
+			dataCollector = NewDataCollector(logger, omService, omURL, pendingChangesLister, deployedProductsLister, NewPermissions(RoleUnknown), &Capabilities{}, nil, StagedConfigSource, SkipChangedProducts, nil, nil, false)
+
+			data, _, err := dataCollector.Collect()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(omService.ProductResourcesCallCount()).To(Equal(1))
+			Expect(omService.ProductResourcesArgsForCall(0)).To(Equal("pivotal-mysql-guid"))
+			Expect(omService.ProductPropertiesCallCount()).To(Equal(1))
+			Expect(omService.ProductPropertiesArgsForCall(0)).To(Equal("pivotal-mysql-guid"))
+			Eventually(bufferedOutput).Should(gbytes.Say("Resources and properties of the changed products will not be collected"))
+			Eventually(bufferedOutput).Should(gbytes.Say(`Skipping resources and properties of product cf \(cf-guid\): product has pending changes`))
+
+			detailsContent, err := io.ReadAll(data[len(data)-1].Content())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(detailsContent).To(MatchJSON(`{
+				"role": "unknown",
+				"skipped_datasets": [],
+				"pending_changes": [
+					{"guid": "cf-guid", "type": "cf", "action": "update"},
+					{"guid": "new-guid", "action": "install"}
+				],
+				"pending_changes_policy": "skip-products"
+			}`))
+		})
 	})
 
 	It("returns an error if listing pending changes errors", func() {
@@ -127,6 +196,18 @@ var _ = Describe("DataCollector", func() {
 		assertOmServiceFailure(collectedData, foundationId, err, collector_tar.OpsManagerProductType, collector_tar.VmTypesDataType, "Requesting things is hard")
 	})
 
+	It("returns an error when omService.InstallationAnalytics errors", func() {
+		omService.InstallationAnalyticsReturns(nil, errors.New("Requesting things is hard"))
+		collectedData, foundationId, err := dataCollector.Collect()
+		assertOmServiceFailure(collectedData, foundationId, err, collector_tar.OpsManagerProductType, InstallationAnalyticsDataType, "Requesting things is hard")
+	})
+
+	It("returns an error when omService.Settings errors", func() {
+		omService.SettingsReturns(nil, errors.New("Requesting things is hard"))
+		collectedData, foundationId, err := dataCollector.Collect()
+		assertOmServiceFailure(collectedData, foundationId, err, collector_tar.OpsManagerProductType, SettingsDataType, "Requesting things is hard")
+	})
+
 	It("returns an error when omService.DiagnosticReport errors", func() {
 		omService.DiagnosticReportReturns(nil, errors.New("Requesting things is hard"))
 		collectedData, foundationId, err := dataCollector.Collect()
@@ -170,6 +251,8 @@ var _ = Describe("DataCollector", func() {
 		diagnosticReportReader := strings.NewReader("diagnostic data")
 		deployedProductsReader := strings.NewReader("deployed products data")
 		installationsReader := strings.NewReader("installations data")
+		installationAnalyticsReader := strings.NewReader("installation analytics data")
+		settingsReader := strings.NewReader("settings data")
 		certificatesReader := strings.NewReader("certificates data")
 		certificateAuthoritiesReader := strings.NewReader("certificate authorities data")
 		pendingChangesReader := strings.NewReader("pending_changes")
@@ -189,6 +272,8 @@ var _ = Describe("DataCollector", func() {
 		omService.DiagnosticReportReturns(diagnosticReportReader, nil)
 		omService.DeployedProductsReturns(deployedProductsReader, nil)
 		omService.InstallationsReturns(installationsReader, nil)
+		omService.InstallationAnalyticsReturns(installationAnalyticsReader, nil)
+		omService.SettingsReturns(settingsReader, nil)
 		omService.CertificatesReturns(certificatesReader, nil)
 		omService.CertificateAuthoritiesReturns(certificateAuthoritiesReader, nil)
 		omService.PendingChangesReturns(pendingChangesReader, nil)
@@ -233,11 +318,21 @@ var _ = Describe("DataCollector", func() {
 				collector_tar.OpsManagerProductType,
 				collector_tar.DiagnosticReportDataType,
 			),
+			NewData(
+				settingsReader,
+				collector_tar.OpsManagerProductType,
+				SettingsDataType,
+			),
 			NewData(
 				installationsReader,
 				collector_tar.OpsManagerProductType,
 				collector_tar.InstallationsDataType,
 			),
+			NewData(
+				installationAnalyticsReader,
+				collector_tar.OpsManagerProductType,
+				InstallationAnalyticsDataType,
+			),
 			NewData(
 				certificatesReader,
 				collector_tar.OpsManagerProductType,
@@ -269,6 +364,8 @@ var _ = Describe("DataCollector", func() {
 		diagnosticReportReader := strings.NewReader("diagnostic data")
 		deployedProductsReader := strings.NewReader("deployed products data")
 		installationsReader := strings.NewReader("installations data")
+		installationAnalyticsReader := strings.NewReader("installation analytics data")
+		settingsReader := strings.NewReader("settings data")
 		certificatesReader := strings.NewReader("certificates data")
 		certificateAuthoritiesReader := strings.NewReader("certificate authorities data")
 		pendingChangesReader := strings.NewReader("pending_changes")
@@ -288,6 +385,8 @@ var _ = Describe("DataCollector", func() {
 		omService.DiagnosticReportReturns(diagnosticReportReader, nil)
 		omService.DeployedProductsReturns(deployedProductsReader, nil)
 		omService.InstallationsReturns(installationsReader, nil)
+		omService.InstallationAnalyticsReturns(installationAnalyticsReader, nil)
+		omService.SettingsReturns(settingsReader, nil)
 		omService.CertificatesReturns(certificatesReader, nil)
 		omService.CertificateAuthoritiesReturns(certificateAuthoritiesReader, nil)
 		omService.PendingChangesReturns(pendingChangesReader, nil)
@@ -297,6 +396,24 @@ var _ = Describe("DataCollector", func() {
 		Expect(bufferedOutput).To(gbytes.Say("Collecting data from Operations Manager at some-opsmanager-url"))
 		Expect(foundationId).To(Equal("p-bosh-always-first"))
 		Expect(collectedData).To(Equal([]Data{}))
+		Expect(omService.ProductResourcesCallCount()).To(Equal(0))
+		Expect(omService.ProductPropertiesCallCount()).To(Equal(0))
+		Expect(pendingChangesLister.ListStagedPendingChangesCallCount()).To(Equal(0))
+	})
+
+	It("does not fail on pending changes or a running installation without the Ops Manager data", func() {
+		installationsLister := new(opsmanagerfakes.FakeInstallationsLister)
+		installationsLister.ListInstallationsReturns([]api.InstallationsServiceOutput{{ID: 1, Status: InstallationRunningStatus}}, nil)
+		guard := NewInstallationGuard(logger, installationsLister, SkipIfInstalling, time.Second, 0, func(time.Duration) {})
+		deployedProductsLister.ListDeployedProductsReturns([]api.DeployedProductOutput{{Type: collector_tar.DirectorProductType, GUID: "p-bosh-guid"}}, nil)
+
+		operationalOnly := NewDataCollector(logger, omService, omURL, pendingChangesLister, deployedProductsLister, NewPermissions(RoleUnknown), &Capabilities{}, nil, StagedConfigSource, FailOnPendingChanges, guard, nil, true)
+		collectedData, foundationId, err := operationalOnly.Collect()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(foundationId).To(Equal("p-bosh-guid"))
+		Expect(collectedData).To(BeEmpty())
+		Expect(installationsLister.ListInstallationsCallCount()).To(Equal(0))
+		Expect(pendingChangesLister.ListStagedPendingChangesCallCount()).To(Equal(0))
 	})
 
 	It("succeeds when there is a deployed product in a delete state", func() {
@@ -331,6 +448,8 @@ var _ = Describe("DataCollector", func() {
 		diagnosticReportReader := strings.NewReader("diagnostic data")
 		deployedProductsReader := strings.NewReader("deployed products data")
 		installationsReader := strings.NewReader("installations data")
+		installationAnalyticsReader := strings.NewReader("installation analytics data")
+		settingsReader := strings.NewReader("settings data")
 		certificatesReader := strings.NewReader("certificates data")
 		certificateAuthoritiesReader := strings.NewReader("certificate authorities data")
 		pendingChangesReader := strings.NewReader("pending_changes")
@@ -351,6 +470,8 @@ var _ = Describe("DataCollector", func() {
 		omService.DiagnosticReportReturns(diagnosticReportReader, nil)
 		omService.DeployedProductsReturns(deployedProductsReader, nil)
 		omService.InstallationsReturns(installationsReader, nil)
+		omService.InstallationAnalyticsReturns(installationAnalyticsReader, nil)
+		omService.SettingsReturns(settingsReader, nil)
 		omService.CertificatesReturns(certificatesReader, nil)
 		omService.CertificateAuthoritiesReturns(certificateAuthoritiesReader, nil)
 		omService.PendingChangesReturns(pendingChangesReader, nil)
@@ -359,7 +480,8 @@ var _ = Describe("DataCollector", func() {
 		Expect(err).ToNot(HaveOccurred())
 		Expect(bufferedOutput).To(gbytes.Say("Collecting data from Operations Manager at some-opsmanager-url"))
 		Expect(foundationId).To(Equal("p-bosh-always-first"))
-		Expect(collectedData).To(ConsistOf(
+		Expect(collectedData[len(collectedData)-1].DataType()).To(Equal(CollectionDetailsDataType))
+		Expect(collectedData[:len(collectedData)-1]).To(ConsistOf(
 			NewData(
 				deployedProductsReader,
 				collector_tar.OpsManagerProductType,
@@ -395,11 +517,21 @@ var _ = Describe("DataCollector", func() {
 				collector_tar.OpsManagerProductType,
 				collector_tar.DiagnosticReportDataType,
 			),
+			NewData(
+				settingsReader,
+				collector_tar.OpsManagerProductType,
+				SettingsDataType,
+			),
 			NewData(
 				installationsReader,
 				collector_tar.OpsManagerProductType,
 				collector_tar.InstallationsDataType,
 			),
+			NewData(
+				installationAnalyticsReader,
+				collector_tar.OpsManagerProductType,
+				InstallationAnalyticsDataType,
+			),
 			NewData(
 				certificatesReader,
 				collector_tar.OpsManagerProductType,
@@ -426,13 +558,299 @@ var _ = Describe("DataCollector", func() {
 			NewData(nil, collector_tar.OpsManagerProductType, collector_tar.DeployedProductsDataType),
 			NewData(nil, collector_tar.OpsManagerProductType, collector_tar.VmTypesDataType),
 			NewData(nil, collector_tar.OpsManagerProductType, collector_tar.DiagnosticReportDataType),
+			NewData(nil, collector_tar.OpsManagerProductType, SettingsDataType),
 			NewData(nil, collector_tar.OpsManagerProductType, collector_tar.InstallationsDataType),
+			NewData(nil, collector_tar.OpsManagerProductType, InstallationAnalyticsDataType),
 			NewData(nil, collector_tar.OpsManagerProductType, collector_tar.CertificatesDataType),
 			NewData(nil, collector_tar.OpsManagerProductType, collector_tar.CertificateAuthoritiesDataType),
 			NewData(nil, collector_tar.OpsManagerProductType, collector_tar.PendingChangesDataType),
 		))
 	})
 
+	Context("when the role does not allow reading every dataset", func() {
+		var permissions *Permissions
+
+		BeforeEach(func() {
+			permissions = NewPermissions(RoleRestrictedView)
+			dataCollector = NewDataCollector(logger, omService, omURL, pendingChangesLister, deployedProductsLister, permissions, &Capabilities{}, nil, StagedConfigSource, WarnOnPendingChanges, nil, nil, false)
+		})
+
+		It("skips those datasets and records them in the collection details", func() {
+			collectedData, _, err := dataCollector.Collect()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(omService.DiagnosticReportCallCount()).To(Equal(0))
+			Expect(omService.SettingsCallCount()).To(Equal(0))
+			Expect(collectedData).To(HaveLen(8))
+			Expect(collectedData).NotTo(ContainElement(NewData(nil, collector_tar.OpsManagerProductType, collector_tar.DiagnosticReportDataType)))
+
+			details := collectedData[7]
+			Expect(details.Name()).To(Equal("ops_manager_collection_details"))
+			detailsContent, err := io.ReadAll(details.Content())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(detailsContent).To(MatchJSON(`{
+				"role": "restricted_view",
+				"skipped_datasets": [{
+					"name": "ops_manager_diagnostic_report",
+					"required_role": "full_view",
+					"reason": "requires the full_view role, authenticated with the restricted_view role"
+				}, {
+					"name": "ops_manager_settings",
+					"required_role": "full_view",
+					"reason": "requires the full_view role, authenticated with the restricted_view role"
+				}]
+			}`))
+			Eventually(bufferedOutput).Should(gbytes.Say("Warning: Skipping ops_manager_diagnostic_report: requires the full_view role"))
+			Eventually(bufferedOutput).Should(gbytes.Say("Warning: Skipping ops_manager_settings: requires the full_view role"))
+		})
+	})
+
+	Context("when Ops Manager refuses to return a dataset", func() {
+		BeforeEach(func() {
+			omService.InstallationsReturns(nil, ForbiddenError{Method: "GET", Path: InstallationsPath})
+		})
+
+		It("skips the dataset and records it in the collection details", func() {
+			collectedData, _, err := dataCollector.Collect()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(collectedData).NotTo(ContainElement(NewData(nil, collector_tar.OpsManagerProductType, collector_tar.InstallationsDataType)))
+			details := collectedData[len(collectedData)-1]
+			Expect(details.DataType()).To(Equal(CollectionDetailsDataType))
+			detailsContent, err := io.ReadAll(details.Content())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(detailsContent).To(MatchJSON(`{
+				"role": "unknown",
+				"skipped_datasets": [{
+					"name": "ops_manager_installations",
+					"required_role": "restricted_view",
+					"reason": "Ops Manager returned 403 Forbidden"
+				}]
+			}`))
+			Eventually(bufferedOutput).Should(gbytes.Say("Warning: Skipping ops_manager_installations: Ops Manager returned 403 Forbidden"))
+		})
+	})
+
+	Context("when products are excluded", func() {
+		BeforeEach(func() {
+			deployedProductsLister.ListDeployedProductsReturns(
+				[]api.DeployedProductOutput{
+					{Type: collector_tar.DirectorProductType, GUID: "p-bosh-guid"},
+					{Type: "cf", GUID: "cf-guid"},
+					{Type: "partner-tile", GUID: "partner-tile-guid"},
+					{Type: "pivotal-mysql", GUID: "pivotal-mysql-guid"},
+				},
+				nil,
+			)
+			productFilter, err := NewProductFilter(nil, []string{"partner-*"}, []string{"pivotal-mysql-guid"})
+			Expect(err).NotTo(HaveOccurred())
+			dataCollector = NewDataCollector(logger, omService, omURL, pendingChangesLister, deployedProductsLister, NewPermissions(RoleUnknown), &Capabilities{}, productFilter, StagedConfigSource, WarnOnPendingChanges, nil, nil, false)
+		})
+
+		It("skips their resources and properties and records their types in the collection details", func() {
+			collectedData, foundationId, err := dataCollector.Collect()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(foundationId).To(Equal("p-bosh-guid"))
+
+			Expect(omService.ProductResourcesCallCount()).To(Equal(1))
+			Expect(omService.ProductResourcesArgsForCall(0)).To(Equal("cf-guid"))
+			Expect(omService.ProductPropertiesCallCount()).To(Equal(1))
+			Expect(omService.ProductPropertiesArgsForCall(0)).To(Equal("cf-guid"))
+			Expect(collectedData).NotTo(ContainElement(NewData(nil, "partner-tile", collector_tar.PropertiesDataType)))
+
+			detailsContent, err := io.ReadAll(collectedData[len(collectedData)-1].Content())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(detailsContent).To(MatchJSON(`{
+				"role": "unknown",
+				"skipped_datasets": [],
+				"excluded_products": ["partner-tile", "pivotal-mysql"]
+			}`))
+			Eventually(bufferedOutput).Should(gbytes.Say("Skipping resources and properties of product partner-tile \\(partner-tile-guid\\): product type excluded"))
+			Eventually(bufferedOutput).Should(gbytes.Say("Skipping resources and properties of product pivotal-mysql \\(pivotal-mysql-guid\\): product GUID excluded"))
+		})
+	})
+
+	Context("when reading the deployed product configuration", func() {
+		BeforeEach(func() {
+			pendingChangesLister.ListStagedPendingChangesReturns(api.PendingChangesOutput{
+				ChangeList: []api.ProductChange{{GUID: "cf-guid", Action: "update"}},
+			}, nil)
+			deployedProductsLister.ListDeployedProductsReturns(
+				[]api.DeployedProductOutput{
+					{Type: collector_tar.DirectorProductType, GUID: "p-bosh-guid"},
+					{Type: "cf", GUID: "cf-guid"},
+				},
+				nil,
+			)
+			omService.DeployedProductResourcesReturns(strings.NewReader("deployed-resources"), nil)
+			omService.DeployedProductPropertiesReturns(strings.NewReader("deployed-properties"), nil)
+			dataCollector = NewDataCollector(logger, omService, omURL, pendingChangesLister, deployedProductsLister, NewPermissions(RoleUnknown), &Capabilities{}, nil, DeployedConfigSource, WarnOnPendingChanges, nil, nil, false)
+		})
+
+		It("collects the deployed resources and properties with their own data types", func() {
+			collectedData, _, err := dataCollector.Collect()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(omService.ProductResourcesCallCount()).To(Equal(0))
+			Expect(omService.ProductPropertiesCallCount()).To(Equal(0))
+			Expect(omService.DeployedProductResourcesArgsForCall(0)).To(Equal("cf-guid"))
+			Expect(omService.DeployedProductPropertiesArgsForCall(0)).To(Equal("cf-guid"))
+			Expect(collectedData).To(ContainElement(NewData(strings.NewReader("deployed-resources"), "cf", DeployedResourcesDataType)))
+			Expect(collectedData).To(ContainElement(NewData(strings.NewReader("deployed-properties"), "cf", DeployedPropertiesDataType)))
+			Expect(collectedData[1].Name()).To(Equal("cf_deployed_resources"))
+		})
+
+		It("notes that pending changes do not affect the product configuration", func() {
+			_, _, err := dataCollector.Collect()
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(bufferedOutput).Should(gbytes.Say(fmt.Sprintf(PendingChangesDeployedFormat, "cf-guid: update")))
+			Expect(string(bufferedOutput.Contents())).NotTo(ContainSubstring("Warning: This foundation has pending changes"))
+		})
+
+		It("skips the deployed configuration when the role cannot read manifests", func() {
+			dataCollector = NewDataCollector(logger, omService, omURL, pendingChangesLister, deployedProductsLister, NewPermissions(RoleRestrictedView), &Capabilities{}, nil, DeployedConfigSource, WarnOnPendingChanges, nil, nil, false)
+
+			collectedData, _, err := dataCollector.Collect()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(omService.DeployedProductPropertiesCallCount()).To(Equal(0))
+			Expect(collectedData).NotTo(ContainElement(NewData(nil, "cf", DeployedPropertiesDataType)))
+			Eventually(bufferedOutput).Should(gbytes.Say("Warning: Skipping cf_deployed_resources: requires the full_view role"))
+		})
+	})
+
+	Context("when checking for a running installation", func() {
+		var installationsLister *opsmanagerfakes.FakeInstallationsLister
+
+		BeforeEach(func() {
+			installationsLister = new(opsmanagerfakes.FakeInstallationsLister)
+		})
+
+		It("records the latest installation in the collection details", func() {
+			installationsLister.ListInstallationsReturns([]api.InstallationsServiceOutput{{ID: 7, Status: "succeeded"}}, nil)
+			guard := NewInstallationGuard(logger, installationsLister, WaitIfInstalling, time.Second, time.Second, func(time.Duration) {})
+			dataCollector = NewDataCollector(logger, omService, omURL, pendingChangesLister, deployedProductsLister, NewPermissions(RoleUnknown), &Capabilities{}, nil, StagedConfigSource, WarnOnPendingChanges, guard, nil, false)
+
+			collectedData, _, err := dataCollector.Collect()
+			Expect(err).NotTo(HaveOccurred())
+			detailsContent, err := io.ReadAll(collectedData[len(collectedData)-1].Content())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(detailsContent).To(MatchJSON(`{"role": "unknown", "skipped_datasets": [], "installation": {"id": 7, "status": "succeeded"}}`))
+		})
+
+		It("collects nothing when the guard stops the collection", func() {
+			installationsLister.ListInstallationsReturns([]api.InstallationsServiceOutput{{ID: 7, Status: "running"}}, nil)
+			guard := NewInstallationGuard(logger, installationsLister, SkipIfInstalling, time.Second, time.Second, func(time.Duration) {})
+			dataCollector = NewDataCollector(logger, omService, omURL, pendingChangesLister, deployedProductsLister, NewPermissions(RoleUnknown), &Capabilities{}, nil, StagedConfigSource, WarnOnPendingChanges, guard, nil, false)
+
+			collectedData, foundationId, err := dataCollector.Collect()
+			Expect(err).To(Equal(InstallationRunningError))
+			Expect(collectedData).To(BeEmpty())
+			Expect(foundationId).To(BeEmpty())
+			Expect(pendingChangesLister.ListStagedPendingChangesCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("with optional data types", func() {
+		BeforeEach(func() {
+			deployedProductsLister.ListDeployedProductsReturns(
+				[]api.DeployedProductOutput{
+					{Type: collector_tar.DirectorProductType, GUID: "p-bosh-guid"},
+					{Type: "cf", GUID: "cf-guid"},
+				},
+				nil,
+			)
+		})
+
+		It("does not collect them unless selected", func() {
+			_, _, err := dataCollector.Collect()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(omService.StemcellAssociationsCallCount()).To(Equal(0))
+			Expect(omService.ProductErrandsCallCount()).To(Equal(0))
+			Expect(omService.DirectorNetworkCallCount()).To(Equal(0))
+		})
+
+		It("collects the selected ones", func() {
+			omService.StemcellAssociationsReturns(strings.NewReader("stemcell associations"), nil)
+			omService.ProductErrandsReturns(strings.NewReader("errands"), nil)
+			omService.DirectorNetworkReturns(strings.NewReader("director network"), nil)
+			dataCollector = NewDataCollector(logger, omService, omURL, pendingChangesLister, deployedProductsLister, NewPermissions(RoleUnknown), &Capabilities{}, nil, StagedConfigSource, WarnOnPendingChanges, nil, OptionalDataTypes, false)
+
+			collectedData, _, err := dataCollector.Collect()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(omService.ProductErrandsArgsForCall(0)).To(Equal("cf-guid"))
+			Expect(collectedData).To(ContainElement(NewData(strings.NewReader("errands"), "cf", ErrandsDataType)))
+			Expect(collectedData).To(ContainElement(NewData(strings.NewReader("stemcell associations"), collector_tar.OpsManagerProductType, StemcellAssociationsDataType)))
+			Expect(collectedData).To(ContainElement(NewData(strings.NewReader("director network"), collector_tar.OpsManagerProductType, DirectorNetworkDataType)))
+		})
+
+		It("skips stemcell associations on Ops Manager versions without them", func() {
+			capabilities, err := NewCapabilities("2.5.3")
+			Expect(err).NotTo(HaveOccurred())
+			dataCollector = NewDataCollector(logger, omService, omURL, pendingChangesLister, deployedProductsLister, NewPermissions(RoleUnknown), capabilities, nil, StagedConfigSource, WarnOnPendingChanges, nil, []string{StemcellAssociationsDataType}, false)
+
+			_, _, err = dataCollector.Collect()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(omService.StemcellAssociationsCallCount()).To(Equal(0))
+			Eventually(bufferedOutput).Should(gbytes.Say("ops_manager_stemcell_associations skipped, unsupported on OM 2.5.3"))
+		})
+
+		It("returns an error when errands cannot be retrieved", func() {
+			omService.ProductErrandsReturns(nil, errors.New("Requesting things is hard"))
+			dataCollector = NewDataCollector(logger, omService, omURL, pendingChangesLister, deployedProductsLister, NewPermissions(RoleUnknown), &Capabilities{}, nil, StagedConfigSource, WarnOnPendingChanges, nil, []string{ErrandsDataType}, false)
+
+			collectedData, foundationId, err := dataCollector.Collect()
+			assertOmServiceFailure(collectedData, foundationId, err, "cf", ErrandsDataType, "Requesting things is hard")
+		})
+	})
+
+	It("records the role in the collection details when it is known", func() {
+		dataCollector = NewDataCollector(logger, omService, omURL, pendingChangesLister, deployedProductsLister, NewPermissions(RoleAdmin), &Capabilities{}, nil, StagedConfigSource, WarnOnPendingChanges, nil, nil, false)
+
+		collectedData, _, err := dataCollector.Collect()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(collectedData).To(HaveLen(10))
+		detailsContent, err := io.ReadAll(collectedData[9].Content())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(detailsContent).To(MatchJSON(`{"role": "admin", "skipped_datasets": []}`))
+	})
+
+	Context("when the Ops Manager version does not serve every dataset", func() {
+		BeforeEach(func() {
+			capabilities, err := NewCapabilities("2.2.5-build.12")
+			Expect(err).NotTo(HaveOccurred())
+			dataCollector = NewDataCollector(logger, omService, omURL, pendingChangesLister, deployedProductsLister, NewPermissions(RoleUnknown), capabilities, nil, StagedConfigSource, WarnOnPendingChanges, nil, nil, false)
+		})
+
+		It("skips those datasets and records them with the version in the collection details", func() {
+			collectedData, _, err := dataCollector.Collect()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(omService.CertificatesCallCount()).To(Equal(0))
+			Expect(collectedData).NotTo(ContainElement(NewData(nil, collector_tar.OpsManagerProductType, collector_tar.CertificatesDataType)))
+
+			detailsContent, err := io.ReadAll(collectedData[len(collectedData)-1].Content())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(detailsContent).To(MatchJSON(`{
+				"role": "unknown",
+				"ops_manager_version": "2.2.5-build.12",
+				"skipped_datasets": [{
+					"name": "ops_manager_certificates",
+					"required_role": "restricted_view",
+					"reason": "unsupported on OM 2.2.5, requires 2.3.0"
+				}]
+			}`))
+			Eventually(bufferedOutput).Should(gbytes.Say("Warning: ops_manager_certificates skipped, unsupported on OM 2.2.5"))
+		})
+
+		It("still fails when a supported dataset cannot be retrieved", func() {
+			omService.VmTypesReturns(nil, errors.New("vm types failed"))
+
+			collectedData, foundationId, err := dataCollector.Collect()
+			assertOmServiceFailure(collectedData, foundationId, err, collector_tar.OpsManagerProductType, collector_tar.VmTypesDataType, "vm types failed")
+		})
+	})
+
 	It("returns an error when omService.PendingChanges errors", func() {
 		omService.PendingChangesReturns(nil, errors.New("I broke when detecting stuff I should have detected"))
 		collectedData, foundationId, err := dataCollector.Collect()
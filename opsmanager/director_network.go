@@ -0,0 +1,152 @@
+package opsmanager
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"net"
+	"strings"
+)
+
+type availabilityZones struct {
+	AvailabilityZones []struct {
+		Name     string        `json:"name"`
+		Clusters []interface{} `json:"clusters"`
+	} `json:"availability_zones"`
+}
+
+type networks struct {
+	ICMPChecksEnabled bool `json:"icmp_checks_enabled"`
+	Networks          []struct {
+		Name    string `json:"name"`
+		Subnets []struct {
+			CIDR                  string   `json:"cidr"`
+			ReservedIPRanges      string   `json:"reserved_ip_ranges"`
+			DNS                   string   `json:"dns"`
+			Gateway               string   `json:"gateway"`
+			AvailabilityZoneNames []string `json:"availability_zone_names"`
+		} `json:"subnets"`
+	} `json:"networks"`
+}
+
+// directorNetwork is the shape of the director's availability zones and
+// networks. Addresses are hashed with a secret key, so that subnets can be
+// told apart without revealing them: an unkeyed hash of an address is
+// reversed by hashing every address in the private ranges.
+type directorNetwork struct {
+	AvailabilityZoneCount int                     `json:"availability_zone_count"`
+	NetworkCount          int                     `json:"network_count"`
+	SubnetCount           int                     `json:"subnet_count"`
+	ICMPChecksEnabled     bool                    `json:"icmp_checks_enabled"`
+	AvailabilityZones     []directorAZ            `json:"availability_zones"`
+	Networks              []directorNetworkDetail `json:"networks"`
+}
+
+type directorAZ struct {
+	Name         string `json:"name"`
+	ClusterCount int    `json:"cluster_count"`
+}
+
+type directorNetworkDetail struct {
+	Name    string           `json:"name"`
+	Subnets []directorSubnet `json:"subnets"`
+}
+
+type directorSubnet struct {
+	CIDRHash          string   `json:"cidr_hash"`
+	PrefixLength      int      `json:"prefix_length"`
+	Addresses         string   `json:"addresses"`
+	ReservedAddresses string   `json:"reserved_addresses"`
+	GatewayHash       string   `json:"gateway_hash,omitempty"`
+	DNSCount          int      `json:"dns_count"`
+	AvailabilityZones []string `json:"availability_zones"`
+}
+
+func newDirectorNetwork(key []byte, azs availabilityZones, ns networks) directorNetwork {
+	dn := directorNetwork{
+		AvailabilityZoneCount: len(azs.AvailabilityZones),
+		NetworkCount:          len(ns.Networks),
+		ICMPChecksEnabled:     ns.ICMPChecksEnabled,
+		AvailabilityZones:     []directorAZ{},
+		Networks:              []directorNetworkDetail{},
+	}
+	for _, az := range azs.AvailabilityZones {
+		dn.AvailabilityZones = append(dn.AvailabilityZones, directorAZ{Name: az.Name, ClusterCount: len(az.Clusters)})
+	}
+	for _, network := range ns.Networks {
+		detail := directorNetworkDetail{Name: network.Name, Subnets: []directorSubnet{}}
+		for _, subnet := range network.Subnets {
+			s := directorSubnet{
+				CIDRHash:          hashAddress(key, subnet.CIDR),
+				Addresses:         "0",
+				ReservedAddresses: countAddresses(subnet.ReservedIPRanges).String(),
+				GatewayHash:       hashAddress(key, subnet.Gateway),
+				DNSCount:          len(splitAddresses(subnet.DNS)),
+				AvailabilityZones: subnet.AvailabilityZoneNames,
+			}
+			if s.AvailabilityZones == nil {
+				s.AvailabilityZones = []string{}
+			}
+			if _, ipNet, err := net.ParseCIDR(strings.TrimSpace(subnet.CIDR)); err == nil {
+				ones, bits := ipNet.Mask.Size()
+				s.PrefixLength = ones
+				s.Addresses = new(big.Int).Lsh(big.NewInt(1), uint(bits-ones)).String()
+			}
+			detail.Subnets = append(detail.Subnets, s)
+		}
+		dn.SubnetCount += len(detail.Subnets)
+		dn.Networks = append(dn.Networks, detail)
+	}
+	return dn
+}
+
+func hashAddress(key []byte, address string) string {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(address))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func splitAddresses(addresses string) []string {
+	var split []string
+	for _, address := range strings.Split(addresses, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			split = append(split, address)
+		}
+	}
+	return split
+}
+
+// countAddresses counts the addresses in a comma separated list of addresses
+// and ranges such as "10.0.0.1-10.0.0.10,10.0.0.20". Entries which cannot be
+// parsed are not counted.
+func countAddresses(ranges string) *big.Int {
+	count := big.NewInt(0)
+	for _, entry := range splitAddresses(ranges) {
+		first, last := entry, entry
+		if bounds := strings.SplitN(entry, "-", 2); len(bounds) == 2 {
+			first, last = strings.TrimSpace(bounds[0]), strings.TrimSpace(bounds[1])
+		}
+		firstIP, lastIP := net.ParseIP(first), net.ParseIP(last)
+		if firstIP == nil || lastIP == nil {
+			continue
+		}
+		size := new(big.Int).Sub(ipToInt(lastIP), ipToInt(firstIP))
+		if size.Sign() < 0 {
+			continue
+		}
+		count.Add(count, size.Add(size, big.NewInt(1)))
+	}
+	return count
+}
+
+func ipToInt(ip net.IP) *big.Int {
+	if ipv4 := ip.To4(); ipv4 != nil {
+		ip = ipv4
+	}
+	return new(big.Int).SetBytes(ip)
+}
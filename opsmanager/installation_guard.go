@@ -0,0 +1,155 @@
+package opsmanager
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/pivotal-cf/om/api"
+	"github.com/pkg/errors"
+)
+
+// InstallingPolicy is what collection does while an Apply Changes is running
+type InstallingPolicy string
+
+const (
+	WaitIfInstalling    InstallingPolicy = "wait"
+	SkipIfInstalling    InstallingPolicy = "skip"
+	ProceedIfInstalling InstallingPolicy = "proceed"
+
+	InstallationRunningStatus = "running"
+
+	InvalidInstallingPolicyFormat    = "invalid installing policy %q, valid policies are: %s"
+	InstallationsFailedMessage       = "Failed to retrieve installations from Operations Manager"
+	InstallationStillRunningFormat   = "installation %d is still running after waiting %s"
+	InstallationRunningWarningFormat = "Warning: Installation %d is running, the collected data may describe a partially applied foundation"
+	WaitingForInstallationFormat     = "Installation %d is running, checking again in %s"
+	InstallationsUnreadableFormat    = "Warning: Could not check for a running installation: %s"
+	InstallationRunningMessage       = "an installation is running on this Operations Manager"
+)
+
+var InstallationRunningError = errors.New(InstallationRunningMessage)
+
+var installingPolicies = []InstallingPolicy{WaitIfInstalling, SkipIfInstalling, ProceedIfInstalling}
+
+// ParseInstallingPolicy reads a policy, defaulting to proceeding with the
+// collection
+func ParseInstallingPolicy(policy string) (InstallingPolicy, error) {
+	if policy == "" {
+		return ProceedIfInstalling, nil
+	}
+	for _, p := range installingPolicies {
+		if InstallingPolicy(strings.ToLower(policy)) == p {
+			return p, nil
+		}
+	}
+
+	var names []string
+	for _, p := range installingPolicies {
+		names = append(names, string(p))
+	}
+	return "", errors.Errorf(InvalidInstallingPolicyFormat, policy, strings.Join(names, ", "))
+}
+
+//go:generate counterfeiter . InstallationsLister
+type InstallationsLister interface {
+	ListInstallations() ([]api.InstallationsServiceOutput, error)
+}
+
+// ObservedInstallation is the latest installation when collection started,
+// recorded in the collection details
+type ObservedInstallation struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+}
+
+// InstallationGuard checks for a running installation before collection, so
+// that a half applied foundation is not collected unknowingly
+type InstallationGuard struct {
+	logger       *log.Logger
+	lister       InstallationsLister
+	policy       InstallingPolicy
+	pollInterval time.Duration
+	maxWait      time.Duration
+	sleep        func(time.Duration)
+	observed     *ObservedInstallation
+}
+
+func NewInstallationGuard(logger *log.Logger, lister InstallationsLister, policy InstallingPolicy, pollInterval, maxWait time.Duration, sleep func(time.Duration)) *InstallationGuard {
+	return &InstallationGuard{
+		logger:       logger,
+		lister:       lister,
+		policy:       policy,
+		pollInterval: pollInterval,
+		maxWait:      maxWait,
+		sleep:        sleep,
+	}
+}
+
+// Check applies the policy to the latest installation. It returns
+// InstallationRunningError when skipping, and an error when the installation
+// is still running after the maximum wait, or at once when the poll
+// interval would never add to the wait. A nil guard checks nothing.
+func (g *InstallationGuard) Check() error {
+	if g == nil {
+		return nil
+	}
+
+	waited := time.Duration(0)
+	for {
+		installation, err := g.latest()
+		if err != nil {
+			if g.policy == ProceedIfInstalling {
+				g.logger.Printf(InstallationsUnreadableFormat, err)
+				return nil
+			}
+			return errors.Wrap(err, InstallationsFailedMessage)
+		}
+		g.observed = installation
+		if installation == nil || installation.Status != InstallationRunningStatus {
+			return nil
+		}
+
+		switch g.policy {
+		case SkipIfInstalling:
+			return InstallationRunningError
+		case WaitIfInstalling:
+			if waited >= g.maxWait || g.pollInterval <= 0 {
+				return errors.Errorf(InstallationStillRunningFormat, installation.ID, g.maxWait)
+			}
+			g.logger.Printf(WaitingForInstallationFormat, installation.ID, g.pollInterval)
+			g.sleep(g.pollInterval)
+			waited += g.pollInterval
+		default:
+			g.logger.Printf(InstallationRunningWarningFormat, installation.ID)
+			return nil
+		}
+	}
+}
+
+// Observed is the latest installation seen by Check, or nil when there was
+// none or it could not be read
+func (g *InstallationGuard) Observed() *ObservedInstallation {
+	if g == nil {
+		return nil
+	}
+	return g.observed
+}
+
+func (g *InstallationGuard) latest() (*ObservedInstallation, error) {
+	installations, err := g.lister.ListInstallations()
+	if err != nil {
+		return nil, err
+	}
+	if len(installations) == 0 {
+		return nil, nil
+	}
+
+	latest := installations[0]
+	for _, installation := range installations[1:] {
+		if installation.ID > latest.ID {
+			latest = installation
+		}
+	}
+	return &ObservedInstallation{ID: latest.ID, Status: latest.Status}, nil
+}
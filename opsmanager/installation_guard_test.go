@@ -0,0 +1,135 @@
+package opsmanager_test
+
+import (
+	"log"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pivotal-cf/om/api"
+	"github.com/pkg/errors"
+
+	. "github.com/pivotal-cf/aqueduct-courier/opsmanager"
+	"github.com/pivotal-cf/aqueduct-courier/opsmanager/opsmanagerfakes"
+)
+
+var _ = Describe("InstallationGuard", func() {
+	var (
+		logger         *log.Logger
+		bufferedOutput *gbytes.Buffer
+		lister         *opsmanagerfakes.FakeInstallationsLister
+		sleeps         []time.Duration
+		sleep          func(time.Duration)
+
+		running   = []api.InstallationsServiceOutput{{ID: 41, Status: "succeeded"}, {ID: 42, Status: "running"}}
+		succeeded = []api.InstallationsServiceOutput{{ID: 42, Status: "succeeded"}, {ID: 41, Status: "succeeded"}}
+	)
+
+	BeforeEach(func() {
+		bufferedOutput = gbytes.NewBuffer()
+		logger = log.New(bufferedOutput, "", 0)
+		lister = new(opsmanagerfakes.FakeInstallationsLister)
+		sleeps = nil
+		sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	})
+
+	newGuard := func(policy InstallingPolicy) *InstallationGuard {
+		return NewInstallationGuard(logger, lister, policy, 10*time.Second, 30*time.Second, sleep)
+	}
+
+	It("records the latest installation when none is running", func() {
+		lister.ListInstallationsReturns(succeeded, nil)
+
+		guard := newGuard(WaitIfInstalling)
+		Expect(guard.Check()).To(Succeed())
+		Expect(guard.Observed()).To(Equal(&ObservedInstallation{ID: 42, Status: "succeeded"}))
+		Expect(sleeps).To(BeEmpty())
+	})
+
+	It("records nothing when there has been no installation", func() {
+		guard := newGuard(WaitIfInstalling)
+		Expect(guard.Check()).To(Succeed())
+		Expect(guard.Observed()).To(BeNil())
+	})
+
+	It("warns and proceeds by default", func() {
+		lister.ListInstallationsReturns(running, nil)
+
+		guard := newGuard(ProceedIfInstalling)
+		Expect(guard.Check()).To(Succeed())
+		Expect(guard.Observed()).To(Equal(&ObservedInstallation{ID: 42, Status: "running"}))
+		Expect(bufferedOutput).To(gbytes.Say("Warning: Installation 42 is running"))
+	})
+
+	It("skips the collection when the policy is to skip", func() {
+		lister.ListInstallationsReturns(running, nil)
+
+		Expect(newGuard(SkipIfInstalling).Check()).To(Equal(InstallationRunningError))
+	})
+
+	It("waits for the installation to finish", func() {
+		lister.ListInstallationsReturnsOnCall(0, running, nil)
+		lister.ListInstallationsReturnsOnCall(1, running, nil)
+		lister.ListInstallationsReturnsOnCall(2, []api.InstallationsServiceOutput{{ID: 42, Status: "failed"}}, nil)
+
+		guard := newGuard(WaitIfInstalling)
+		Expect(guard.Check()).To(Succeed())
+		Expect(sleeps).To(Equal([]time.Duration{10 * time.Second, 10 * time.Second}))
+		Expect(guard.Observed()).To(Equal(&ObservedInstallation{ID: 42, Status: "failed"}))
+		Expect(bufferedOutput).To(gbytes.Say("Installation 42 is running, checking again in 10s"))
+	})
+
+	It("errors when the installation is still running after the maximum wait", func() {
+		lister.ListInstallationsReturns(running, nil)
+
+		err := newGuard(WaitIfInstalling).Check()
+		Expect(err).To(MatchError("installation 42 is still running after waiting 30s"))
+		Expect(sleeps).To(HaveLen(3))
+	})
+
+	It("stops waiting when the poll interval is zero", func() {
+		lister.ListInstallationsReturns(running, nil)
+
+		err := NewInstallationGuard(logger, lister, WaitIfInstalling, 0, 30*time.Second, sleep).Check()
+		Expect(err).To(MatchError("installation 42 is still running after waiting 30s"))
+		Expect(lister.ListInstallationsCallCount()).To(Equal(1))
+		Expect(sleeps).To(BeEmpty())
+	})
+
+	It("warns and proceeds when installations cannot be read and the policy is to proceed", func() {
+		lister.ListInstallationsReturns(nil, errors.New("forbidden"))
+
+		guard := newGuard(ProceedIfInstalling)
+		Expect(guard.Check()).To(Succeed())
+		Expect(guard.Observed()).To(BeNil())
+		Expect(bufferedOutput).To(gbytes.Say("Warning: Could not check for a running installation: forbidden"))
+	})
+
+	It("errors when installations cannot be read and the policy is to wait", func() {
+		lister.ListInstallationsReturns(nil, errors.New("forbidden"))
+
+		err := newGuard(WaitIfInstalling).Check()
+		Expect(err).To(MatchError(ContainSubstring(InstallationsFailedMessage)))
+		Expect(err).To(MatchError(ContainSubstring("forbidden")))
+	})
+
+	It("checks nothing when nil", func() {
+		var guard *InstallationGuard
+		Expect(guard.Check()).To(Succeed())
+		Expect(guard.Observed()).To(BeNil())
+	})
+})
+
+var _ = Describe("ParseInstallingPolicy", func() {
+	It("defaults to proceeding", func() {
+		policy, err := ParseInstallingPolicy("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policy).To(Equal(ProceedIfInstalling))
+	})
+
+	It("errors on an unknown policy", func() {
+		_, err := ParseInstallingPolicy("abort")
+		Expect(err).To(MatchError(`invalid installing policy "abort", valid policies are: wait, skip, proceed`))
+	})
+})
@@ -0,0 +1,242 @@
+package opsmanager
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	InstallationAnalyticsDataType = "installation_analytics"
+
+	InvalidInstallationsSinceFormat = "invalid installations since %q, expected a date such as 2024-01-31 or an RFC 3339 time"
+	InvalidInstallationsMaxFormat   = "invalid installations max %d, expected zero for no maximum or a positive count"
+
+	installationsSinceDateLayout = "2006-01-02"
+	installationMonthLayout      = "2006-01"
+	failingProductsLimit         = 10
+)
+
+// InstallationsWindow limits the installations collected to those started
+// since a time, and to the newest ones up to a maximum count. The zero window
+// keeps every installation.
+type InstallationsWindow struct {
+	Since    time.Time
+	MaxCount int
+}
+
+// NewInstallationsWindow reads the start of the window as a date or an RFC
+// 3339 time. An empty since and a zero maximum leave the window open.
+func NewInstallationsWindow(since string, maxCount int) (InstallationsWindow, error) {
+	if maxCount < 0 {
+		return InstallationsWindow{}, errors.Errorf(InvalidInstallationsMaxFormat, maxCount)
+	}
+
+	window := InstallationsWindow{MaxCount: maxCount}
+	if since == "" {
+		return window, nil
+	}
+	if parsed, err := time.Parse(installationsSinceDateLayout, since); err == nil {
+		window.Since = parsed
+		return window, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return InstallationsWindow{}, errors.Errorf(InvalidInstallationsSinceFormat, since)
+	}
+	window.Since = parsed
+	return window, nil
+}
+
+// apply keeps the installations in the window, newest first
+func (w InstallationsWindow) apply(all []map[string]interface{}) []map[string]interface{} {
+	kept := []map[string]interface{}{}
+	for _, installation := range all {
+		if !w.Since.IsZero() {
+			startedAt, ok := installationTime(installation, "started_at")
+			if !ok || startedAt.Before(w.Since) {
+				continue
+			}
+		}
+		kept = append(kept, installation)
+	}
+
+	sort.SliceStable(kept, func(i, j int) bool {
+		return installationID(kept[i]) > installationID(kept[j])
+	})
+	if w.MaxCount > 0 && len(kept) > w.MaxCount {
+		kept = kept[:w.MaxCount]
+	}
+	return kept
+}
+
+type installationAnalytics struct {
+	InstallationCount int                      `json:"installation_count"`
+	Durations         installationDurations    `json:"durations"`
+	Months            []installationMonth      `json:"months"`
+	FailingProducts   []failingProductAnalytic `json:"failing_products"`
+}
+
+// installationDurations are the apply changes durations in seconds of the
+// finished installations, as nearest rank percentiles
+type installationDurations struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50_seconds"`
+	P90   float64 `json:"p90_seconds"`
+	P99   float64 `json:"p99_seconds"`
+	Max   float64 `json:"max_seconds"`
+}
+
+type installationMonth struct {
+	Month         string  `json:"month"`
+	Installations int     `json:"installations"`
+	Succeeded     int     `json:"succeeded"`
+	Failed        int     `json:"failed"`
+	SuccessRate   float64 `json:"success_rate"`
+	FailureRate   float64 `json:"failure_rate"`
+}
+
+// failingProductAnalytic counts the failed installations which changed a
+// product. The installations list does not name the errand which failed, so
+// failures are attributed to the products being applied.
+type failingProductAnalytic struct {
+	Product  string `json:"product"`
+	Failures int    `json:"failures"`
+}
+
+func newInstallationAnalytics(all []map[string]interface{}) installationAnalytics {
+	analytics := installationAnalytics{
+		InstallationCount: len(all),
+		Months:            []installationMonth{},
+		FailingProducts:   []failingProductAnalytic{},
+	}
+
+	var durations []float64
+	months := map[string]*installationMonth{}
+	failures := map[string]int{}
+	for _, installation := range all {
+		status, _ := installation["status"].(string)
+
+		startedAt, started := installationTime(installation, "started_at")
+		finishedAt, finished := installationTime(installation, "finished_at")
+		if started && finished && status != InstallationRunningStatus {
+			durations = append(durations, finishedAt.Sub(startedAt).Seconds())
+		}
+
+		if started {
+			name := startedAt.UTC().Format(installationMonthLayout)
+			month, ok := months[name]
+			if !ok {
+				month = &installationMonth{Month: name}
+				months[name] = month
+			}
+			month.Installations++
+			switch status {
+			case "succeeded":
+				month.Succeeded++
+			case "failed":
+				month.Failed++
+			}
+		}
+
+		if status == "failed" {
+			for _, product := range changedProductIdentifiers(installation) {
+				failures[product]++
+			}
+		}
+	}
+
+	analytics.Durations = newInstallationDurations(durations)
+
+	for _, month := range months {
+		if month.Installations > 0 {
+			month.SuccessRate = rate(month.Succeeded, month.Installations)
+			month.FailureRate = rate(month.Failed, month.Installations)
+		}
+		analytics.Months = append(analytics.Months, *month)
+	}
+	sort.Slice(analytics.Months, func(i, j int) bool {
+		return analytics.Months[i].Month < analytics.Months[j].Month
+	})
+
+	for product, count := range failures {
+		analytics.FailingProducts = append(analytics.FailingProducts, failingProductAnalytic{Product: product, Failures: count})
+	}
+	sort.Slice(analytics.FailingProducts, func(i, j int) bool {
+		a, b := analytics.FailingProducts[i], analytics.FailingProducts[j]
+		if a.Failures != b.Failures {
+			return a.Failures > b.Failures
+		}
+		return a.Product < b.Product
+	})
+	if len(analytics.FailingProducts) > failingProductsLimit {
+		analytics.FailingProducts = analytics.FailingProducts[:failingProductsLimit]
+	}
+
+	return analytics
+}
+
+func newInstallationDurations(durations []float64) installationDurations {
+	if len(durations) == 0 {
+		return installationDurations{}
+	}
+	sort.Float64s(durations)
+	return installationDurations{
+		Count: len(durations),
+		P50:   percentile(durations, 50),
+		P90:   percentile(durations, 90),
+		P99:   percentile(durations, 99),
+		Max:   durations[len(durations)-1],
+	}
+}
+
+// percentile is the nearest rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func rate(count, total int) float64 {
+	return math.Round(float64(count)/float64(total)*1000) / 1000
+}
+
+// changedProductIdentifiers lists the products added, updated or deleted by
+// an installation
+func changedProductIdentifiers(installation map[string]interface{}) []string {
+	var identifiers []string
+	seen := map[string]bool{}
+	for _, key := range []string{"additions", "updates", "deletions"} {
+		changes, _ := installation[key].([]interface{})
+		for _, change := range changes {
+			changeMap, _ := change.(map[string]interface{})
+			identifier, _ := changeMap["identifier"].(string)
+			if identifier != "" && !seen[identifier] {
+				seen[identifier] = true
+				identifiers = append(identifiers, identifier)
+			}
+		}
+	}
+	return identifiers
+}
+
+func installationTime(installation map[string]interface{}, key string) (time.Time, bool) {
+	value, _ := installation[key].(string)
+	if value == "" {
+		return time.Time{}, false
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return parsed, true
+}
+
+func installationID(installation map[string]interface{}) float64 {
+	id, _ := installation["id"].(float64)
+	return id
+}
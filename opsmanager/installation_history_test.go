@@ -0,0 +1,40 @@
+package opsmanager_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/pivotal-cf/aqueduct-courier/opsmanager"
+)
+
+var _ = Describe("InstallationsWindow", func() {
+	It("is open by default", func() {
+		window, err := NewInstallationsWindow("", 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(window).To(Equal(InstallationsWindow{}))
+	})
+
+	It("reads the start of the window as a date", func() {
+		window, err := NewInstallationsWindow("2024-01-31", 25)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(window).To(Equal(InstallationsWindow{Since: time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), MaxCount: 25}))
+	})
+
+	It("reads the start of the window as a time", func() {
+		window, err := NewInstallationsWindow("2024-01-31T12:30:00Z", 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(window.Since).To(Equal(time.Date(2024, 1, 31, 12, 30, 0, 0, time.UTC)))
+	})
+
+	It("errors for an invalid start", func() {
+		_, err := NewInstallationsWindow("last month", 0)
+		Expect(err).To(MatchError(`invalid installations since "last month", expected a date such as 2024-01-31 or an RFC 3339 time`))
+	})
+
+	It("errors for a negative maximum", func() {
+		_, err := NewInstallationsWindow("", -1)
+		Expect(err).To(MatchError("invalid installations max -1, expected zero for no maximum or a positive count"))
+	})
+})
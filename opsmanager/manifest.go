@@ -0,0 +1,196 @@
+package opsmanager
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pivotal-cf/telemetry-utils/collector_tar"
+	"github.com/pkg/errors"
+)
+
+// ConfigSource is where product resources and properties are read from
+type ConfigSource string
+
+const (
+	StagedConfigSource   ConfigSource = "staged"
+	DeployedConfigSource ConfigSource = "deployed"
+
+	DeployedResourcesDataType  = "deployed_resources"
+	DeployedPropertiesDataType = "deployed_properties"
+
+	InvalidConfigSourceFormat = "invalid product config source %q, valid sources are: %s, %s"
+)
+
+// ParseConfigSource reads a config source, defaulting to the staged
+// configuration
+func ParseConfigSource(source string) (ConfigSource, error) {
+	switch ConfigSource(strings.ToLower(source)) {
+	case "", StagedConfigSource:
+		return StagedConfigSource, nil
+	case DeployedConfigSource:
+		return DeployedConfigSource, nil
+	}
+	return "", errors.Errorf(InvalidConfigSourceFormat, source, StagedConfigSource, DeployedConfigSource)
+}
+
+// ResourcesDataType is the data type product resources are written with, so
+// that the metadata records where they were read from
+func (s ConfigSource) ResourcesDataType() string {
+	if s == DeployedConfigSource {
+		return DeployedResourcesDataType
+	}
+	return collector_tar.ResourcesDataType
+}
+
+// PropertiesDataType is the data type product properties are written with
+func (s ConfigSource) PropertiesDataType() string {
+	if s == DeployedConfigSource {
+		return DeployedPropertiesDataType
+	}
+	return collector_tar.PropertiesDataType
+}
+
+// PathFormats are the Ops Manager endpoints read for each product
+func (s ConfigSource) PathFormats() []string {
+	if s == DeployedConfigSource {
+		return []string{DeployedProductManifestPathFormat}
+	}
+	return []string{ProductResourcesPathFormat, ProductPropertiesPathFormat}
+}
+
+func (s ConfigSource) String() string {
+	return string(s)
+}
+
+type manifest struct {
+	InstanceGroups []manifestInstanceGroup `json:"instance_groups"`
+}
+
+type manifestInstanceGroup struct {
+	Name               string        `json:"name"`
+	Instances          int           `json:"instances"`
+	VMType             string        `json:"vm_type"`
+	VMExtensions       []string      `json:"vm_extensions"`
+	PersistentDiskType string        `json:"persistent_disk_type"`
+	PersistentDisk     int           `json:"persistent_disk"`
+	AZs                []string      `json:"azs"`
+	Stemcell           string        `json:"stemcell"`
+	Jobs               []manifestJob `json:"jobs"`
+}
+
+type manifestJob struct {
+	Name       string                 `json:"name"`
+	Release    string                 `json:"release"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type deployedResources struct {
+	InstanceGroups []deployedResource `json:"instance_groups"`
+}
+
+type deployedResource struct {
+	Name               string   `json:"name"`
+	Instances          int      `json:"instances"`
+	VMType             string   `json:"vm_type,omitempty"`
+	VMExtensions       []string `json:"vm_extensions,omitempty"`
+	PersistentDiskType string   `json:"persistent_disk_type,omitempty"`
+	PersistentDisk     int      `json:"persistent_disk,omitempty"`
+	AZs                []string `json:"azs"`
+	Stemcell           string   `json:"stemcell,omitempty"`
+	Jobs               []string `json:"jobs"`
+}
+
+type deployedProperties struct {
+	InstanceGroups []deployedInstanceGroupProperties `json:"instance_groups"`
+}
+
+type deployedInstanceGroupProperties struct {
+	Name string                  `json:"name"`
+	Jobs []deployedJobProperties `json:"jobs"`
+}
+
+type deployedJobProperties struct {
+	Name       string                 `json:"name"`
+	Release    string                 `json:"release"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+func (m manifest) resources() deployedResources {
+	resources := deployedResources{InstanceGroups: []deployedResource{}}
+	for _, group := range m.InstanceGroups {
+		jobs := []string{}
+		for _, job := range group.Jobs {
+			jobs = append(jobs, fmt.Sprintf("%s/%s", job.Release, job.Name))
+		}
+		azs := group.AZs
+		if azs == nil {
+			azs = []string{}
+		}
+		resources.InstanceGroups = append(resources.InstanceGroups, deployedResource{
+			Name:               group.Name,
+			Instances:          group.Instances,
+			VMType:             group.VMType,
+			VMExtensions:       group.VMExtensions,
+			PersistentDiskType: group.PersistentDiskType,
+			PersistentDisk:     group.PersistentDisk,
+			AZs:                azs,
+			Stemcell:           group.Stemcell,
+			Jobs:               jobs,
+		})
+	}
+	return resources
+}
+
+func (m manifest) properties() deployedProperties {
+	properties := deployedProperties{InstanceGroups: []deployedInstanceGroupProperties{}}
+	for _, group := range m.InstanceGroups {
+		groupProperties := deployedInstanceGroupProperties{Name: group.Name, Jobs: []deployedJobProperties{}}
+		for _, job := range group.Jobs {
+			jobProperties, _ := stripSecrets(job.Properties).(map[string]interface{})
+			if jobProperties == nil {
+				jobProperties = map[string]interface{}{}
+			}
+			groupProperties.Jobs = append(groupProperties.Jobs, deployedJobProperties{
+				Name:       job.Name,
+				Release:    job.Release,
+				Properties: jobProperties,
+			})
+		}
+		properties.InstanceGroups = append(properties.InstanceGroups, groupProperties)
+	}
+	return properties
+}
+
+// stripSecrets keeps only the numbers and booleans of a manifest value.
+// Manifests do not type their properties, so strings are dropped as any of
+// them may be a credential, as are maps and lists left empty. It returns nil
+// when nothing is kept.
+func stripSecrets(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bool, float64:
+		return v
+	case map[string]interface{}:
+		kept := map[string]interface{}{}
+		for key, child := range v {
+			if strippedChild := stripSecrets(child); strippedChild != nil {
+				kept[key] = strippedChild
+			}
+		}
+		if len(kept) == 0 {
+			return nil
+		}
+		return kept
+	case []interface{}:
+		var kept []interface{}
+		for _, child := range v {
+			if strippedChild := stripSecrets(child); strippedChild != nil {
+				kept = append(kept, strippedChild)
+			}
+		}
+		if len(kept) == 0 {
+			return nil
+		}
+		return kept
+	}
+	return nil
+}
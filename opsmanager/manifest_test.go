@@ -0,0 +1,34 @@
+package opsmanager_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/telemetry-utils/collector_tar"
+
+	. "github.com/pivotal-cf/aqueduct-courier/opsmanager"
+)
+
+var _ = Describe("ConfigSource", func() {
+	It("defaults to the staged configuration", func() {
+		source, err := ParseConfigSource("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(source).To(Equal(StagedConfigSource))
+		Expect(source.ResourcesDataType()).To(Equal(collector_tar.ResourcesDataType))
+		Expect(source.PropertiesDataType()).To(Equal(collector_tar.PropertiesDataType))
+		Expect(source.PathFormats()).To(Equal([]string{ProductResourcesPathFormat, ProductPropertiesPathFormat}))
+	})
+
+	It("reads the deployed manifests", func() {
+		source, err := ParseConfigSource("Deployed")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(source).To(Equal(DeployedConfigSource))
+		Expect(source.ResourcesDataType()).To(Equal(DeployedResourcesDataType))
+		Expect(source.PropertiesDataType()).To(Equal(DeployedPropertiesDataType))
+		Expect(source.PathFormats()).To(Equal([]string{DeployedProductManifestPathFormat}))
+	})
+
+	It("errors on an unknown source", func() {
+		_, err := ParseConfigSource("running")
+		Expect(err).To(MatchError(`invalid product config source "running", valid sources are: staged, deployed`))
+	})
+})
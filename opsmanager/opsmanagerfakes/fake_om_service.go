@@ -33,6 +33,32 @@ type FakeOmService struct {
 		result1 io.Reader
 		result2 error
 	}
+	DeployedProductPropertiesStub        func(string) (io.Reader, error)
+	deployedProductPropertiesMutex       sync.RWMutex
+	deployedProductPropertiesArgsForCall []struct {
+		arg1 string
+	}
+	deployedProductPropertiesReturns struct {
+		result1 io.Reader
+		result2 error
+	}
+	deployedProductPropertiesReturnsOnCall map[int]struct {
+		result1 io.Reader
+		result2 error
+	}
+	DeployedProductResourcesStub        func(string) (io.Reader, error)
+	deployedProductResourcesMutex       sync.RWMutex
+	deployedProductResourcesArgsForCall []struct {
+		arg1 string
+	}
+	deployedProductResourcesReturns struct {
+		result1 io.Reader
+		result2 error
+	}
+	deployedProductResourcesReturnsOnCall map[int]struct {
+		result1 io.Reader
+		result2 error
+	}
 	DeployedProductsStub        func() (io.Reader, error)
 	deployedProductsMutex       sync.RWMutex
 	deployedProductsArgsForCall []struct {
@@ -57,6 +83,30 @@ type FakeOmService struct {
 		result1 io.Reader
 		result2 error
 	}
+	DirectorNetworkStub        func() (io.Reader, error)
+	directorNetworkMutex       sync.RWMutex
+	directorNetworkArgsForCall []struct {
+	}
+	directorNetworkReturns struct {
+		result1 io.Reader
+		result2 error
+	}
+	directorNetworkReturnsOnCall map[int]struct {
+		result1 io.Reader
+		result2 error
+	}
+	InstallationAnalyticsStub        func() (io.Reader, error)
+	installationAnalyticsMutex       sync.RWMutex
+	installationAnalyticsArgsForCall []struct {
+	}
+	installationAnalyticsReturns struct {
+		result1 io.Reader
+		result2 error
+	}
+	installationAnalyticsReturnsOnCall map[int]struct {
+		result1 io.Reader
+		result2 error
+	}
 	InstallationsStub        func() (io.Reader, error)
 	installationsMutex       sync.RWMutex
 	installationsArgsForCall []struct {
@@ -81,6 +131,19 @@ type FakeOmService struct {
 		result1 io.Reader
 		result2 error
 	}
+	ProductErrandsStub        func(string) (io.Reader, error)
+	productErrandsMutex       sync.RWMutex
+	productErrandsArgsForCall []struct {
+		arg1 string
+	}
+	productErrandsReturns struct {
+		result1 io.Reader
+		result2 error
+	}
+	productErrandsReturnsOnCall map[int]struct {
+		result1 io.Reader
+		result2 error
+	}
 	ProductPropertiesStub        func(string) (io.Reader, error)
 	productPropertiesMutex       sync.RWMutex
 	productPropertiesArgsForCall []struct {
@@ -107,6 +170,30 @@ type FakeOmService struct {
 		result1 io.Reader
 		result2 error
 	}
+	SettingsStub        func() (io.Reader, error)
+	settingsMutex       sync.RWMutex
+	settingsArgsForCall []struct {
+	}
+	settingsReturns struct {
+		result1 io.Reader
+		result2 error
+	}
+	settingsReturnsOnCall map[int]struct {
+		result1 io.Reader
+		result2 error
+	}
+	StemcellAssociationsStub        func() (io.Reader, error)
+	stemcellAssociationsMutex       sync.RWMutex
+	stemcellAssociationsArgsForCall []struct {
+	}
+	stemcellAssociationsReturns struct {
+		result1 io.Reader
+		result2 error
+	}
+	stemcellAssociationsReturnsOnCall map[int]struct {
+		result1 io.Reader
+		result2 error
+	}
 	VmTypesStub        func() (io.Reader, error)
 	vmTypesMutex       sync.RWMutex
 	vmTypesArgsForCall []struct {
@@ -235,6 +322,134 @@ func (fake *FakeOmService) CertificatesReturnsOnCall(i int, result1 io.Reader, r
 	}{result1, result2}
 }
 
+func (fake *FakeOmService) DeployedProductProperties(arg1 string) (io.Reader, error) {
+	fake.deployedProductPropertiesMutex.Lock()
+	ret, specificReturn := fake.deployedProductPropertiesReturnsOnCall[len(fake.deployedProductPropertiesArgsForCall)]
+	fake.deployedProductPropertiesArgsForCall = append(fake.deployedProductPropertiesArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.DeployedProductPropertiesStub
+	fakeReturns := fake.deployedProductPropertiesReturns
+	fake.recordInvocation("DeployedProductProperties", []interface{}{arg1})
+	fake.deployedProductPropertiesMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeOmService) DeployedProductPropertiesCallCount() int {
+	fake.deployedProductPropertiesMutex.RLock()
+	defer fake.deployedProductPropertiesMutex.RUnlock()
+	return len(fake.deployedProductPropertiesArgsForCall)
+}
+
+func (fake *FakeOmService) DeployedProductPropertiesCalls(stub func(string) (io.Reader, error)) {
+	fake.deployedProductPropertiesMutex.Lock()
+	defer fake.deployedProductPropertiesMutex.Unlock()
+	fake.DeployedProductPropertiesStub = stub
+}
+
+func (fake *FakeOmService) DeployedProductPropertiesArgsForCall(i int) string {
+	fake.deployedProductPropertiesMutex.RLock()
+	defer fake.deployedProductPropertiesMutex.RUnlock()
+	argsForCall := fake.deployedProductPropertiesArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeOmService) DeployedProductPropertiesReturns(result1 io.Reader, result2 error) {
+	fake.deployedProductPropertiesMutex.Lock()
+	defer fake.deployedProductPropertiesMutex.Unlock()
+	fake.DeployedProductPropertiesStub = nil
+	fake.deployedProductPropertiesReturns = struct {
+		result1 io.Reader
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeOmService) DeployedProductPropertiesReturnsOnCall(i int, result1 io.Reader, result2 error) {
+	fake.deployedProductPropertiesMutex.Lock()
+	defer fake.deployedProductPropertiesMutex.Unlock()
+	fake.DeployedProductPropertiesStub = nil
+	if fake.deployedProductPropertiesReturnsOnCall == nil {
+		fake.deployedProductPropertiesReturnsOnCall = make(map[int]struct {
+			result1 io.Reader
+			result2 error
+		})
+	}
+	fake.deployedProductPropertiesReturnsOnCall[i] = struct {
+		result1 io.Reader
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeOmService) DeployedProductResources(arg1 string) (io.Reader, error) {
+	fake.deployedProductResourcesMutex.Lock()
+	ret, specificReturn := fake.deployedProductResourcesReturnsOnCall[len(fake.deployedProductResourcesArgsForCall)]
+	fake.deployedProductResourcesArgsForCall = append(fake.deployedProductResourcesArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.DeployedProductResourcesStub
+	fakeReturns := fake.deployedProductResourcesReturns
+	fake.recordInvocation("DeployedProductResources", []interface{}{arg1})
+	fake.deployedProductResourcesMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeOmService) DeployedProductResourcesCallCount() int {
+	fake.deployedProductResourcesMutex.RLock()
+	defer fake.deployedProductResourcesMutex.RUnlock()
+	return len(fake.deployedProductResourcesArgsForCall)
+}
+
+func (fake *FakeOmService) DeployedProductResourcesCalls(stub func(string) (io.Reader, error)) {
+	fake.deployedProductResourcesMutex.Lock()
+	defer fake.deployedProductResourcesMutex.Unlock()
+	fake.DeployedProductResourcesStub = stub
+}
+
+func (fake *FakeOmService) DeployedProductResourcesArgsForCall(i int) string {
+	fake.deployedProductResourcesMutex.RLock()
+	defer fake.deployedProductResourcesMutex.RUnlock()
+	argsForCall := fake.deployedProductResourcesArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeOmService) DeployedProductResourcesReturns(result1 io.Reader, result2 error) {
+	fake.deployedProductResourcesMutex.Lock()
+	defer fake.deployedProductResourcesMutex.Unlock()
+	fake.DeployedProductResourcesStub = nil
+	fake.deployedProductResourcesReturns = struct {
+		result1 io.Reader
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeOmService) DeployedProductResourcesReturnsOnCall(i int, result1 io.Reader, result2 error) {
+	fake.deployedProductResourcesMutex.Lock()
+	defer fake.deployedProductResourcesMutex.Unlock()
+	fake.DeployedProductResourcesStub = nil
+	if fake.deployedProductResourcesReturnsOnCall == nil {
+		fake.deployedProductResourcesReturnsOnCall = make(map[int]struct {
+			result1 io.Reader
+			result2 error
+		})
+	}
+	fake.deployedProductResourcesReturnsOnCall[i] = struct {
+		result1 io.Reader
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeOmService) DeployedProducts() (io.Reader, error) {
 	fake.deployedProductsMutex.Lock()
 	ret, specificReturn := fake.deployedProductsReturnsOnCall[len(fake.deployedProductsArgsForCall)]
@@ -347,6 +562,118 @@ func (fake *FakeOmService) DiagnosticReportReturnsOnCall(i int, result1 io.Reade
 	}{result1, result2}
 }
 
+func (fake *FakeOmService) DirectorNetwork() (io.Reader, error) {
+	fake.directorNetworkMutex.Lock()
+	ret, specificReturn := fake.directorNetworkReturnsOnCall[len(fake.directorNetworkArgsForCall)]
+	fake.directorNetworkArgsForCall = append(fake.directorNetworkArgsForCall, struct {
+	}{})
+	stub := fake.DirectorNetworkStub
+	fakeReturns := fake.directorNetworkReturns
+	fake.recordInvocation("DirectorNetwork", []interface{}{})
+	fake.directorNetworkMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeOmService) DirectorNetworkCallCount() int {
+	fake.directorNetworkMutex.RLock()
+	defer fake.directorNetworkMutex.RUnlock()
+	return len(fake.directorNetworkArgsForCall)
+}
+
+func (fake *FakeOmService) DirectorNetworkCalls(stub func() (io.Reader, error)) {
+	fake.directorNetworkMutex.Lock()
+	defer fake.directorNetworkMutex.Unlock()
+	fake.DirectorNetworkStub = stub
+}
+
+func (fake *FakeOmService) DirectorNetworkReturns(result1 io.Reader, result2 error) {
+	fake.directorNetworkMutex.Lock()
+	defer fake.directorNetworkMutex.Unlock()
+	fake.DirectorNetworkStub = nil
+	fake.directorNetworkReturns = struct {
+		result1 io.Reader
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeOmService) DirectorNetworkReturnsOnCall(i int, result1 io.Reader, result2 error) {
+	fake.directorNetworkMutex.Lock()
+	defer fake.directorNetworkMutex.Unlock()
+	fake.DirectorNetworkStub = nil
+	if fake.directorNetworkReturnsOnCall == nil {
+		fake.directorNetworkReturnsOnCall = make(map[int]struct {
+			result1 io.Reader
+			result2 error
+		})
+	}
+	fake.directorNetworkReturnsOnCall[i] = struct {
+		result1 io.Reader
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeOmService) InstallationAnalytics() (io.Reader, error) {
+	fake.installationAnalyticsMutex.Lock()
+	ret, specificReturn := fake.installationAnalyticsReturnsOnCall[len(fake.installationAnalyticsArgsForCall)]
+	fake.installationAnalyticsArgsForCall = append(fake.installationAnalyticsArgsForCall, struct {
+	}{})
+	stub := fake.InstallationAnalyticsStub
+	fakeReturns := fake.installationAnalyticsReturns
+	fake.recordInvocation("InstallationAnalytics", []interface{}{})
+	fake.installationAnalyticsMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeOmService) InstallationAnalyticsCallCount() int {
+	fake.installationAnalyticsMutex.RLock()
+	defer fake.installationAnalyticsMutex.RUnlock()
+	return len(fake.installationAnalyticsArgsForCall)
+}
+
+func (fake *FakeOmService) InstallationAnalyticsCalls(stub func() (io.Reader, error)) {
+	fake.installationAnalyticsMutex.Lock()
+	defer fake.installationAnalyticsMutex.Unlock()
+	fake.InstallationAnalyticsStub = stub
+}
+
+func (fake *FakeOmService) InstallationAnalyticsReturns(result1 io.Reader, result2 error) {
+	fake.installationAnalyticsMutex.Lock()
+	defer fake.installationAnalyticsMutex.Unlock()
+	fake.InstallationAnalyticsStub = nil
+	fake.installationAnalyticsReturns = struct {
+		result1 io.Reader
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeOmService) InstallationAnalyticsReturnsOnCall(i int, result1 io.Reader, result2 error) {
+	fake.installationAnalyticsMutex.Lock()
+	defer fake.installationAnalyticsMutex.Unlock()
+	fake.InstallationAnalyticsStub = nil
+	if fake.installationAnalyticsReturnsOnCall == nil {
+		fake.installationAnalyticsReturnsOnCall = make(map[int]struct {
+			result1 io.Reader
+			result2 error
+		})
+	}
+	fake.installationAnalyticsReturnsOnCall[i] = struct {
+		result1 io.Reader
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeOmService) Installations() (io.Reader, error) {
 	fake.installationsMutex.Lock()
 	ret, specificReturn := fake.installationsReturnsOnCall[len(fake.installationsArgsForCall)]
@@ -459,6 +786,70 @@ func (fake *FakeOmService) PendingChangesReturnsOnCall(i int, result1 io.Reader,
 	}{result1, result2}
 }
 
+func (fake *FakeOmService) ProductErrands(arg1 string) (io.Reader, error) {
+	fake.productErrandsMutex.Lock()
+	ret, specificReturn := fake.productErrandsReturnsOnCall[len(fake.productErrandsArgsForCall)]
+	fake.productErrandsArgsForCall = append(fake.productErrandsArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.ProductErrandsStub
+	fakeReturns := fake.productErrandsReturns
+	fake.recordInvocation("ProductErrands", []interface{}{arg1})
+	fake.productErrandsMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeOmService) ProductErrandsCallCount() int {
+	fake.productErrandsMutex.RLock()
+	defer fake.productErrandsMutex.RUnlock()
+	return len(fake.productErrandsArgsForCall)
+}
+
+func (fake *FakeOmService) ProductErrandsCalls(stub func(string) (io.Reader, error)) {
+	fake.productErrandsMutex.Lock()
+	defer fake.productErrandsMutex.Unlock()
+	fake.ProductErrandsStub = stub
+}
+
+func (fake *FakeOmService) ProductErrandsArgsForCall(i int) string {
+	fake.productErrandsMutex.RLock()
+	defer fake.productErrandsMutex.RUnlock()
+	argsForCall := fake.productErrandsArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeOmService) ProductErrandsReturns(result1 io.Reader, result2 error) {
+	fake.productErrandsMutex.Lock()
+	defer fake.productErrandsMutex.Unlock()
+	fake.ProductErrandsStub = nil
+	fake.productErrandsReturns = struct {
+		result1 io.Reader
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeOmService) ProductErrandsReturnsOnCall(i int, result1 io.Reader, result2 error) {
+	fake.productErrandsMutex.Lock()
+	defer fake.productErrandsMutex.Unlock()
+	fake.ProductErrandsStub = nil
+	if fake.productErrandsReturnsOnCall == nil {
+		fake.productErrandsReturnsOnCall = make(map[int]struct {
+			result1 io.Reader
+			result2 error
+		})
+	}
+	fake.productErrandsReturnsOnCall[i] = struct {
+		result1 io.Reader
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeOmService) ProductProperties(arg1 string) (io.Reader, error) {
 	fake.productPropertiesMutex.Lock()
 	ret, specificReturn := fake.productPropertiesReturnsOnCall[len(fake.productPropertiesArgsForCall)]
@@ -587,6 +978,118 @@ func (fake *FakeOmService) ProductResourcesReturnsOnCall(i int, result1 io.Reade
 	}{result1, result2}
 }
 
+func (fake *FakeOmService) Settings() (io.Reader, error) {
+	fake.settingsMutex.Lock()
+	ret, specificReturn := fake.settingsReturnsOnCall[len(fake.settingsArgsForCall)]
+	fake.settingsArgsForCall = append(fake.settingsArgsForCall, struct {
+	}{})
+	stub := fake.SettingsStub
+	fakeReturns := fake.settingsReturns
+	fake.recordInvocation("Settings", []interface{}{})
+	fake.settingsMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeOmService) SettingsCallCount() int {
+	fake.settingsMutex.RLock()
+	defer fake.settingsMutex.RUnlock()
+	return len(fake.settingsArgsForCall)
+}
+
+func (fake *FakeOmService) SettingsCalls(stub func() (io.Reader, error)) {
+	fake.settingsMutex.Lock()
+	defer fake.settingsMutex.Unlock()
+	fake.SettingsStub = stub
+}
+
+func (fake *FakeOmService) SettingsReturns(result1 io.Reader, result2 error) {
+	fake.settingsMutex.Lock()
+	defer fake.settingsMutex.Unlock()
+	fake.SettingsStub = nil
+	fake.settingsReturns = struct {
+		result1 io.Reader
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeOmService) SettingsReturnsOnCall(i int, result1 io.Reader, result2 error) {
+	fake.settingsMutex.Lock()
+	defer fake.settingsMutex.Unlock()
+	fake.SettingsStub = nil
+	if fake.settingsReturnsOnCall == nil {
+		fake.settingsReturnsOnCall = make(map[int]struct {
+			result1 io.Reader
+			result2 error
+		})
+	}
+	fake.settingsReturnsOnCall[i] = struct {
+		result1 io.Reader
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeOmService) StemcellAssociations() (io.Reader, error) {
+	fake.stemcellAssociationsMutex.Lock()
+	ret, specificReturn := fake.stemcellAssociationsReturnsOnCall[len(fake.stemcellAssociationsArgsForCall)]
+	fake.stemcellAssociationsArgsForCall = append(fake.stemcellAssociationsArgsForCall, struct {
+	}{})
+	stub := fake.StemcellAssociationsStub
+	fakeReturns := fake.stemcellAssociationsReturns
+	fake.recordInvocation("StemcellAssociations", []interface{}{})
+	fake.stemcellAssociationsMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeOmService) StemcellAssociationsCallCount() int {
+	fake.stemcellAssociationsMutex.RLock()
+	defer fake.stemcellAssociationsMutex.RUnlock()
+	return len(fake.stemcellAssociationsArgsForCall)
+}
+
+func (fake *FakeOmService) StemcellAssociationsCalls(stub func() (io.Reader, error)) {
+	fake.stemcellAssociationsMutex.Lock()
+	defer fake.stemcellAssociationsMutex.Unlock()
+	fake.StemcellAssociationsStub = stub
+}
+
+func (fake *FakeOmService) StemcellAssociationsReturns(result1 io.Reader, result2 error) {
+	fake.stemcellAssociationsMutex.Lock()
+	defer fake.stemcellAssociationsMutex.Unlock()
+	fake.StemcellAssociationsStub = nil
+	fake.stemcellAssociationsReturns = struct {
+		result1 io.Reader
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeOmService) StemcellAssociationsReturnsOnCall(i int, result1 io.Reader, result2 error) {
+	fake.stemcellAssociationsMutex.Lock()
+	defer fake.stemcellAssociationsMutex.Unlock()
+	fake.StemcellAssociationsStub = nil
+	if fake.stemcellAssociationsReturnsOnCall == nil {
+		fake.stemcellAssociationsReturnsOnCall = make(map[int]struct {
+			result1 io.Reader
+			result2 error
+		})
+	}
+	fake.stemcellAssociationsReturnsOnCall[i] = struct {
+		result1 io.Reader
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeOmService) VmTypes() (io.Reader, error) {
 	fake.vmTypesMutex.Lock()
 	ret, specificReturn := fake.vmTypesReturnsOnCall[len(fake.vmTypesArgsForCall)]
@@ -650,18 +1153,32 @@ func (fake *FakeOmService) Invocations() map[string][][]interface{} {
 	defer fake.certificateAuthoritiesMutex.RUnlock()
 	fake.certificatesMutex.RLock()
 	defer fake.certificatesMutex.RUnlock()
+	fake.deployedProductPropertiesMutex.RLock()
+	defer fake.deployedProductPropertiesMutex.RUnlock()
+	fake.deployedProductResourcesMutex.RLock()
+	defer fake.deployedProductResourcesMutex.RUnlock()
 	fake.deployedProductsMutex.RLock()
 	defer fake.deployedProductsMutex.RUnlock()
 	fake.diagnosticReportMutex.RLock()
 	defer fake.diagnosticReportMutex.RUnlock()
+	fake.directorNetworkMutex.RLock()
+	defer fake.directorNetworkMutex.RUnlock()
+	fake.installationAnalyticsMutex.RLock()
+	defer fake.installationAnalyticsMutex.RUnlock()
 	fake.installationsMutex.RLock()
 	defer fake.installationsMutex.RUnlock()
 	fake.pendingChangesMutex.RLock()
 	defer fake.pendingChangesMutex.RUnlock()
+	fake.productErrandsMutex.RLock()
+	defer fake.productErrandsMutex.RUnlock()
 	fake.productPropertiesMutex.RLock()
 	defer fake.productPropertiesMutex.RUnlock()
 	fake.productResourcesMutex.RLock()
 	defer fake.productResourcesMutex.RUnlock()
+	fake.settingsMutex.RLock()
+	defer fake.settingsMutex.RUnlock()
+	fake.stemcellAssociationsMutex.RLock()
+	defer fake.stemcellAssociationsMutex.RUnlock()
 	fake.vmTypesMutex.RLock()
 	defer fake.vmTypesMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
@@ -0,0 +1,72 @@
+package opsmanager
+
+import (
+	"strings"
+
+	"github.com/pivotal-cf/om/api"
+	"github.com/pkg/errors"
+)
+
+// PendingChangesPolicy is how collection treats staged changes that have not
+// been applied
+type PendingChangesPolicy string
+
+const (
+	WarnOnPendingChanges PendingChangesPolicy = "warn"
+	FailOnPendingChanges PendingChangesPolicy = "fail"
+	SkipChangedProducts  PendingChangesPolicy = "skip-products"
+
+	InvalidPendingChangesPolicyFormat = "invalid pending changes policy %q, valid policies are: %s"
+	PendingChangesFailFormat          = "This foundation has pending changes. List of changes:\n%s"
+	PendingChangesSkipFormat          = "Warning: This foundation has pending changes. Resources and properties of the changed products will not be collected. List of changes:\n%s"
+	ChangedProductReason              = "product has pending changes"
+)
+
+var pendingChangesPolicies = []PendingChangesPolicy{WarnOnPendingChanges, FailOnPendingChanges, SkipChangedProducts}
+
+// ParsePendingChangesPolicy reads a policy, defaulting to a warning
+func ParsePendingChangesPolicy(policy string) (PendingChangesPolicy, error) {
+	if policy == "" {
+		return WarnOnPendingChanges, nil
+	}
+	for _, p := range pendingChangesPolicies {
+		if PendingChangesPolicy(strings.ToLower(policy)) == p {
+			return p, nil
+		}
+	}
+
+	var names []string
+	for _, p := range pendingChangesPolicies {
+		names = append(names, string(p))
+	}
+	return "", errors.Errorf(InvalidPendingChangesPolicyFormat, policy, strings.Join(names, ", "))
+}
+
+func (p PendingChangesPolicy) String() string {
+	return string(p)
+}
+
+// ChangedProduct is a product with staged changes, recorded in the
+// collection details whatever the policy
+type ChangedProduct struct {
+	GUID   string `json:"guid"`
+	Type   string `json:"type,omitempty"`
+	Action string `json:"action"`
+}
+
+// changedProducts lists the products with pending changes. The type is only
+// known for deployed products, not for those being installed.
+func changedProducts(changeList []api.ProductChange, products []api.DeployedProductOutput) []ChangedProduct {
+	types := map[string]string{}
+	for _, product := range products {
+		types[product.GUID] = product.Type
+	}
+
+	changed := []ChangedProduct{}
+	for _, change := range changeList {
+		if change.Action != "unchanged" {
+			changed = append(changed, ChangedProduct{GUID: change.GUID, Type: types[change.GUID], Action: change.Action})
+		}
+	}
+	return changed
+}
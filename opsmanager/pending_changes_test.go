@@ -0,0 +1,33 @@
+package opsmanager_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/pivotal-cf/aqueduct-courier/opsmanager"
+)
+
+var _ = Describe("ParsePendingChangesPolicy", func() {
+	It("defaults to a warning", func() {
+		policy, err := ParsePendingChangesPolicy("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policy).To(Equal(WarnOnPendingChanges))
+	})
+
+	It("reads each policy", func() {
+		for name, expected := range map[string]PendingChangesPolicy{
+			"warn":          WarnOnPendingChanges,
+			"FAIL":          FailOnPendingChanges,
+			"skip-products": SkipChangedProducts,
+		} {
+			policy, err := ParsePendingChangesPolicy(name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy).To(Equal(expected))
+		}
+	})
+
+	It("errors on an unknown policy", func() {
+		_, err := ParsePendingChangesPolicy("ignore")
+		Expect(err).To(MatchError(`invalid pending changes policy "ignore", valid policies are: warn, fail, skip-products`))
+	})
+})
@@ -0,0 +1,187 @@
+package opsmanager
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pivotal-cf/telemetry-utils/collector_tar"
+	"github.com/pkg/errors"
+)
+
+// Role is an Ops Manager RBAC role. Roles are ordered, so that each role can
+// read everything the roles below it can.
+type Role int
+
+const (
+	RoleUnknown Role = iota
+	RoleRestrictedView
+	RoleFullView
+	RoleRestrictedControl
+	RoleFullControl
+	RoleAdmin
+)
+
+const (
+	BoshCredentialsDataType   = "bosh_credentials"
+	CollectionDetailsDataType = "collection_details"
+
+	InvalidAccessTokenError      = "access token is not a JSON web token"
+	NoRoleScopesErrorFormat      = "access token has no Ops Manager role scopes, found: %s"
+	SkippedForRoleReasonFormat   = "requires the %s role, authenticated with the %s role"
+	SkippedForbiddenReason       = "Ops Manager returned 403 Forbidden"
+	SkippingDatasetWarningFormat = "Warning: Skipping %s: %s"
+)
+
+var roleNames = map[Role]string{
+	RoleUnknown:           "unknown",
+	RoleRestrictedView:    "restricted_view",
+	RoleFullView:          "full_view",
+	RoleRestrictedControl: "restricted_control",
+	RoleFullControl:       "full_control",
+	RoleAdmin:             "admin",
+}
+
+// MinimumRoles is the least privileged role able to read each dataset, keyed
+// by data type. Restricted roles cannot read credentials or the diagnostic
+// report, deployed manifests and settings, which include them.
+var MinimumRoles = map[string]Role{
+	collector_tar.DeployedProductsDataType:       RoleRestrictedView,
+	collector_tar.PendingChangesDataType:         RoleRestrictedView,
+	collector_tar.ResourcesDataType:              RoleRestrictedView,
+	collector_tar.PropertiesDataType:             RoleRestrictedView,
+	collector_tar.VmTypesDataType:                RoleRestrictedView,
+	collector_tar.InstallationsDataType:          RoleRestrictedView,
+	InstallationAnalyticsDataType:                RoleRestrictedView,
+	collector_tar.CertificatesDataType:           RoleRestrictedView,
+	collector_tar.CertificateAuthoritiesDataType: RoleRestrictedView,
+	collector_tar.CoreCountsDataType:             RoleRestrictedView,
+	StemcellAssociationsDataType:                 RoleRestrictedView,
+	ErrandsDataType:                              RoleRestrictedView,
+	DirectorNetworkDataType:                      RoleRestrictedView,
+	collector_tar.DiagnosticReportDataType:       RoleFullView,
+	SettingsDataType:                             RoleFullView,
+	DeployedResourcesDataType:                    RoleFullView,
+	DeployedPropertiesDataType:                   RoleFullView,
+	BoshCredentialsDataType:                      RoleFullView,
+}
+
+func (r Role) String() string {
+	return roleNames[r]
+}
+
+// RoleFromAccessToken reads the role from the `opsman.*` scopes of a UAA
+// access token. The signature is not verified; the role is only used to
+// decide which datasets to request.
+func RoleFromAccessToken(accessToken string) (Role, error) {
+	parts := strings.Split(accessToken, ".")
+	if len(parts) != 3 {
+		return RoleUnknown, errors.New(InvalidAccessTokenError)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return RoleUnknown, errors.Wrap(err, InvalidAccessTokenError)
+	}
+
+	var claims struct {
+		Scope       []string `json:"scope"`
+		Authorities []string `json:"authorities"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return RoleUnknown, errors.Wrap(err, InvalidAccessTokenError)
+	}
+
+	scopes := append(claims.Scope, claims.Authorities...)
+	role := RoleUnknown
+	for _, scope := range scopes {
+		for candidate, name := range roleNames {
+			if scope == "opsman."+name && candidate > role {
+				role = candidate
+			}
+		}
+	}
+	if role == RoleUnknown {
+		return RoleUnknown, errors.Errorf(NoRoleScopesErrorFormat, strings.Join(scopes, ", "))
+	}
+	return role, nil
+}
+
+// SkippedDataset records a dataset which was not collected because the
+// authenticated user is not allowed to read it
+type SkippedDataset struct {
+	Name         string `json:"name"`
+	RequiredRole string `json:"required_role"`
+	Reason       string `json:"reason"`
+}
+
+// Permissions tracks which datasets the authenticated user may read, and
+// which were skipped during collection
+type Permissions struct {
+	role    Role
+	skipped []SkippedDataset
+}
+
+func NewPermissions(role Role) *Permissions {
+	return &Permissions{role: role, skipped: []SkippedDataset{}}
+}
+
+func (p *Permissions) Role() Role {
+	return p.role
+}
+
+// Allows reports whether the role can read the data type. When the role is
+// unknown every dataset is attempted, and 403 responses are skipped instead.
+func (p *Permissions) Allows(dataType string) bool {
+	return p.role == RoleUnknown || p.role >= MinimumRoles[dataType]
+}
+
+// SkipForRole records that the dataset was not requested because the role
+// does not allow it, and returns the reason
+func (p *Permissions) SkipForRole(name, dataType string) string {
+	reason := fmt.Sprintf(SkippedForRoleReasonFormat, MinimumRoles[dataType], p.role)
+	p.skip(name, dataType, reason)
+	return reason
+}
+
+// SkipForbidden records that Ops Manager refused to return the dataset, and
+// returns the reason
+func (p *Permissions) SkipForbidden(name, dataType string) string {
+	p.skip(name, dataType, SkippedForbiddenReason)
+	return SkippedForbiddenReason
+}
+
+// SkipUnsupported records that the dataset was not requested because the Ops
+// Manager version does not serve it
+func (p *Permissions) SkipUnsupported(name, dataType, reason string) {
+	p.skip(name, dataType, reason)
+}
+
+func (p *Permissions) Skipped() []SkippedDataset {
+	return p.skipped
+}
+
+func (p *Permissions) skip(name, dataType, reason string) {
+	p.skipped = append(p.skipped, SkippedDataset{
+		Name:         name,
+		RequiredRole: MinimumRoles[dataType].String(),
+		Reason:       reason,
+	})
+}
+
+// MinimumRolesHelp lists the minimum role for each data type, for help text
+func MinimumRolesHelp() string {
+	var dataTypes []string
+	for dataType := range MinimumRoles {
+		dataTypes = append(dataTypes, dataType)
+	}
+	sort.Strings(dataTypes)
+
+	var lines []string
+	for _, dataType := range dataTypes {
+		lines = append(lines, fmt.Sprintf("  %-40s %s", dataType, MinimumRoles[dataType]))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
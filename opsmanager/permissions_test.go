@@ -0,0 +1,80 @@
+package opsmanager_test
+
+import (
+	"encoding/base64"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotal-cf/aqueduct-courier/opsmanager"
+	"github.com/pivotal-cf/telemetry-utils/collector_tar"
+)
+
+var _ = Describe("Permissions", func() {
+	accessToken := func(claims string) string {
+		return "e30." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".signature"
+	}
+
+	Describe("RoleFromAccessToken", func() {
+		DescribeTable("reads the most privileged role from the token scopes",
+			func(claims string, expected Role) {
+				role, err := RoleFromAccessToken(accessToken(claims))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(role).To(Equal(expected))
+			},
+			Entry("restricted view", `{"scope": ["opsman.restricted_view", "openid"]}`, RoleRestrictedView),
+			Entry("full view", `{"scope": ["opsman.full_view"]}`, RoleFullView),
+			Entry("restricted control", `{"scope": ["opsman.restricted_control"]}`, RoleRestrictedControl),
+			Entry("full control", `{"scope": ["opsman.full_control"]}`, RoleFullControl),
+			Entry("admin", `{"scope": ["opsman.admin"]}`, RoleAdmin),
+			Entry("several roles", `{"scope": ["opsman.restricted_view", "opsman.full_control"]}`, RoleFullControl),
+			Entry("client authorities", `{"authorities": ["opsman.full_view"]}`, RoleFullView),
+		)
+
+		It("errors when the token is not a JSON web token", func() {
+			_, err := RoleFromAccessToken("some-opaque-token")
+			Expect(err).To(MatchError(InvalidAccessTokenError))
+		})
+
+		It("errors when the token payload is not JSON", func() {
+			_, err := RoleFromAccessToken("e30.bm90LWpzb24.signature")
+			Expect(err).To(MatchError(ContainSubstring(InvalidAccessTokenError)))
+		})
+
+		It("errors when the token has no Ops Manager role scopes", func() {
+			_, err := RoleFromAccessToken(accessToken(`{"scope": ["openid"]}`))
+			Expect(err).To(MatchError("access token has no Ops Manager role scopes, found: openid"))
+		})
+	})
+
+	Describe("Allows", func() {
+		It("allows data types up to the role", func() {
+			permissions := NewPermissions(RoleRestrictedView)
+			Expect(permissions.Allows(collector_tar.VmTypesDataType)).To(BeTrue())
+			Expect(permissions.Allows(collector_tar.DiagnosticReportDataType)).To(BeFalse())
+			Expect(permissions.Allows(BoshCredentialsDataType)).To(BeFalse())
+
+			Expect(NewPermissions(RoleFullView).Allows(BoshCredentialsDataType)).To(BeTrue())
+		})
+
+		It("allows every data type when the role is unknown", func() {
+			permissions := NewPermissions(RoleUnknown)
+			Expect(permissions.Allows(collector_tar.DiagnosticReportDataType)).To(BeTrue())
+			Expect(permissions.Allows(BoshCredentialsDataType)).To(BeTrue())
+		})
+	})
+
+	It("records skipped datasets with the role they require", func() {
+		permissions := NewPermissions(RoleRestrictedView)
+
+		Expect(permissions.SkipForRole("ops_manager_diagnostic_report", collector_tar.DiagnosticReportDataType)).To(
+			Equal("requires the full_view role, authenticated with the restricted_view role"),
+		)
+		Expect(permissions.SkipForbidden("p-bosh_certificates", BoshCredentialsDataType)).To(Equal(SkippedForbiddenReason))
+
+		Expect(permissions.Skipped()).To(Equal([]SkippedDataset{
+			{Name: "ops_manager_diagnostic_report", RequiredRole: "full_view", Reason: "requires the full_view role, authenticated with the restricted_view role"},
+			{Name: "p-bosh_certificates", RequiredRole: "full_view", Reason: SkippedForbiddenReason},
+		}))
+	})
+})
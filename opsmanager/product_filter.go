@@ -0,0 +1,91 @@
+package opsmanager
+
+import (
+	"path"
+	"sort"
+
+	"github.com/pivotal-cf/om/api"
+	"github.com/pkg/errors"
+)
+
+const (
+	InvalidProductPatternFormat = "invalid product pattern %q"
+	ExcludedProductFormat       = "Skipping resources and properties of product %s (%s): %s"
+	ExcludedByTypeReason        = "product type excluded"
+	ExcludedByGUIDReason        = "product GUID excluded"
+	NotIncludedReason           = "product type not included"
+)
+
+// ProductFilter decides which deployed products have their resources and
+// properties collected. Patterns are globs as understood by path.Match. When
+// any type is included, products of other types are excluded.
+type ProductFilter struct {
+	includeTypes []string
+	excludeTypes []string
+	excludeGUIDs []string
+	excluded     map[string]bool
+}
+
+func NewProductFilter(includeTypes, excludeTypes, excludeGUIDs []string) (*ProductFilter, error) {
+	for _, patterns := range [][]string{includeTypes, excludeTypes, excludeGUIDs} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, errors.Wrapf(err, InvalidProductPatternFormat, pattern)
+			}
+		}
+	}
+
+	return &ProductFilter{
+		includeTypes: includeTypes,
+		excludeTypes: excludeTypes,
+		excludeGUIDs: excludeGUIDs,
+		excluded:     map[string]bool{},
+	}, nil
+}
+
+// Excludes reports whether the product is filtered out, with the reason, and
+// records the product type as excluded. A nil filter excludes nothing.
+func (f *ProductFilter) Excludes(product api.DeployedProductOutput) (bool, string) {
+	if f == nil {
+		return false, ""
+	}
+
+	reason := ""
+	switch {
+	case len(f.includeTypes) > 0 && !matchesAny(f.includeTypes, product.Type):
+		reason = NotIncludedReason
+	case matchesAny(f.excludeTypes, product.Type):
+		reason = ExcludedByTypeReason
+	case matchesAny(f.excludeGUIDs, product.GUID):
+		reason = ExcludedByGUIDReason
+	default:
+		return false, ""
+	}
+
+	f.excluded[product.Type] = true
+	return true, reason
+}
+
+// ExcludedTypes returns the sorted types of the products excluded so far.
+// GUIDs are not recorded, since they identify the installation.
+func (f *ProductFilter) ExcludedTypes() []string {
+	if f == nil {
+		return nil
+	}
+
+	var types []string
+	for productType := range f.excluded {
+		types = append(types, productType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package opsmanager_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/om/api"
+
+	. "github.com/pivotal-cf/aqueduct-courier/opsmanager"
+)
+
+var _ = Describe("ProductFilter", func() {
+	cf := api.DeployedProductOutput{Type: "cf", GUID: "cf-abc123"}
+	mysql := api.DeployedProductOutput{Type: "pivotal-mysql", GUID: "pivotal-mysql-xyz"}
+	partner := api.DeployedProductOutput{Type: "partner-tile", GUID: "partner-tile-123"}
+
+	It("excludes nothing when no pattern is given", func() {
+		filter, err := NewProductFilter(nil, nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		excluded, _ := filter.Excludes(cf)
+		Expect(excluded).To(BeFalse())
+		Expect(filter.ExcludedTypes()).To(BeEmpty())
+	})
+
+	It("excludes nothing when nil", func() {
+		var filter *ProductFilter
+
+		excluded, _ := filter.Excludes(cf)
+		Expect(excluded).To(BeFalse())
+		Expect(filter.ExcludedTypes()).To(BeEmpty())
+	})
+
+	It("excludes products by type and GUID globs", func() {
+		filter, err := NewProductFilter(nil, []string{"partner-*"}, []string{"pivotal-mysql-*"})
+		Expect(err).NotTo(HaveOccurred())
+
+		excluded, reason := filter.Excludes(partner)
+		Expect(excluded).To(BeTrue())
+		Expect(reason).To(Equal(ExcludedByTypeReason))
+
+		excluded, reason = filter.Excludes(mysql)
+		Expect(excluded).To(BeTrue())
+		Expect(reason).To(Equal(ExcludedByGUIDReason))
+
+		excluded, _ = filter.Excludes(cf)
+		Expect(excluded).To(BeFalse())
+	})
+
+	It("excludes products whose type is not included", func() {
+		filter, err := NewProductFilter([]string{"cf", "pivotal-*"}, []string{"pivotal-mysql"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		excluded, _ := filter.Excludes(cf)
+		Expect(excluded).To(BeFalse())
+
+		excluded, reason := filter.Excludes(partner)
+		Expect(excluded).To(BeTrue())
+		Expect(reason).To(Equal(NotIncludedReason))
+
+		excluded, reason = filter.Excludes(mysql)
+		Expect(excluded).To(BeTrue())
+		Expect(reason).To(Equal(ExcludedByTypeReason))
+	})
+
+	It("records the sorted types of the excluded products once", func() {
+		filter, err := NewProductFilter(nil, []string{"pivotal-mysql", "partner-tile"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, product := range []api.DeployedProductOutput{mysql, cf, partner, mysql} {
+			filter.Excludes(product)
+		}
+		Expect(filter.ExcludedTypes()).To(Equal([]string{"partner-tile", "pivotal-mysql"}))
+	})
+
+	It("errors when a pattern is not a valid glob", func() {
+		_, err := NewProductFilter(nil, []string{"partner-["}, nil)
+		Expect(err).To(MatchError(ContainSubstring(`invalid product pattern "partner-["`)))
+	})
+})
@@ -2,6 +2,7 @@ package opsmanager
 
 import (
 	"bytes"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,26 +14,47 @@ import (
 )
 
 const (
-	ProductResourcesPathFormat  = "/api/v0/staged/products/%s/resources"
-	ProductPropertiesPathFormat = "/api/v0/staged/products/%s/properties"
-	InstallationsPath           = "/api/v0/installations"
-	DeployedProductsPath        = "/api/v0/deployed/products"
-	PendingChangesPath          = "/api/v0/staged/pending_changes"
-	VmTypesPath                 = "/api/v0/vm_types"
-	DiagnosticReportPath        = "/api/v0/diagnostic_report"
-	CertificatesPath            = "/api/v0/deployed/certificates"
-	CertificateAuthoritiesPath  = "/api/v0/certificate_authorities"
-	BoshCredentialsPath         = "/api/v0/deployed/director/credentials/bosh_commandline_credentials"
+	ProductResourcesPathFormat        = "/api/v0/staged/products/%s/resources"
+	ProductPropertiesPathFormat       = "/api/v0/staged/products/%s/properties"
+	DeployedProductManifestPathFormat = "/api/v0/deployed/products/%s/manifest"
+	InstallationsPath                 = "/api/v0/installations"
+	DeployedProductsPath              = "/api/v0/deployed/products"
+	PendingChangesPath                = "/api/v0/staged/pending_changes"
+	VmTypesPath                       = "/api/v0/vm_types"
+	DiagnosticReportPath              = "/api/v0/diagnostic_report"
+	CertificatesPath                  = "/api/v0/deployed/certificates"
+	CertificateAuthoritiesPath        = "/api/v0/certificate_authorities"
+	BoshCredentialsPath               = "/api/v0/deployed/director/credentials/bosh_commandline_credentials"
+	StemcellAssociationsPath          = "/api/v0/stemcell_associations"
+	ProductErrandsPathFormat          = "/api/v0/staged/products/%s/errands"
+	DirectorAvailabilityZonesPath     = "/api/v0/staged/director/availability_zones"
+	DirectorNetworksPath              = "/api/v0/staged/director/networks"
+	SSLCertificateSettingsPath        = "/api/v0/settings/ssl_certificate"
+	SyslogSettingsPath                = "/api/v0/settings/syslog"
+	BannerSettingsPath                = "/api/v0/settings/banner"
+	PivnetSettingsPath                = "/api/v0/settings/pivotal_network_settings"
+	RBACSettingsPath                  = "/api/v0/settings/rbac"
 
 	ReadResponseBodyFailureFormat      = "Unable to read response from %s"
 	InvalidResponseErrorFormat         = "Invalid response format for request to %s"
 	RequestFailureErrorFormat          = "Failed %s %s"
 	RequestUnexpectedStatusErrorFormat = "%s %s returned with unexpected status %d"
 	UnmarshalResponseError             = "error unmarshalling response"
+	AddressKeyFailureMessage           = "Unable to generate a key for hashing director network addresses"
+
+	addressKeyLength = 32
 )
 
 type Service struct {
-	Requestor Requestor
+	Requestor           Requestor
+	InstallationsWindow InstallationsWindow
+	// AddressKey keys the hashes of director network addresses. A random key
+	// is used when it is empty, so hashes only match within a collection.
+	AddressKey []byte
+
+	// responses keeps the responses more than one dataset is read from, so
+	// they are requested once per collection
+	responses map[string][]byte
 }
 
 type BoshCredential struct {
@@ -61,6 +83,33 @@ type productProperties struct {
 	Properties map[string]property `json:"properties"`
 }
 
+type stemcellAssociations struct {
+	Products []struct {
+		Identifier              string                `json:"identifier"`
+		StagedProductVersion    string                `json:"staged_product_version"`
+		DeployedProductVersion  string                `json:"deployed_product_version"`
+		IsStagedForDeletion     bool                  `json:"is_staged_for_deletion"`
+		StagedStemcells         []stemcellAssociation `json:"staged_stemcells"`
+		DeployedStemcells       []stemcellAssociation `json:"deployed_stemcells"`
+		AvailableStemcells      []stemcellAssociation `json:"available_stemcells"`
+		RequiredStemcellVersion string                `json:"required_stemcell_version"`
+		RequiredStemcellOS      string                `json:"required_stemcell_os"`
+	} `json:"products"`
+}
+
+type stemcellAssociation struct {
+	OS      string `json:"os"`
+	Version string `json:"version"`
+}
+
+type productErrands struct {
+	Errands []struct {
+		Name       string      `json:"name"`
+		PostDeploy interface{} `json:"post_deploy"`
+		PreDelete  interface{} `json:"pre_delete"`
+	} `json:"errands"`
+}
+
 type property struct {
 	Type         string      `json:"type"`
 	Value        interface{} `json:"value"`
@@ -69,21 +118,40 @@ type property struct {
 	Optional     bool        `json:"optional"`
 }
 
+// ForbiddenError is returned when the authenticated user's role does not
+// allow it to read an endpoint
+type ForbiddenError struct {
+	Method string
+	Path   string
+}
+
+func (e ForbiddenError) Error() string {
+	return fmt.Sprintf(RequestUnexpectedStatusErrorFormat, e.Method, e.Path, http.StatusForbidden)
+}
+
+// NotFoundError is returned when Ops Manager does not serve an endpoint
+type NotFoundError struct {
+	Method string
+	Path   string
+}
+
+func (e NotFoundError) Error() string {
+	return fmt.Sprintf(RequestUnexpectedStatusErrorFormat, e.Method, e.Path, http.StatusNotFound)
+}
+
 //go:generate counterfeiter . Requestor
 type Requestor interface {
 	Curl(input api.RequestServiceCurlInput) (api.RequestServiceCurlOutput, error)
 }
 
+// Installations reads the installations in the window, newest first,
+// leaving out who started them
 func (s *Service) Installations() (io.Reader, error) {
-	contents, err := s.makeRequest(InstallationsPath)
+	i, err := s.installations()
 	if err != nil {
 		return nil, err
 	}
-
-	var i installations
-	if err := json.Unmarshal(contents, &i); err != nil {
-		return nil, errors.Wrapf(err, InvalidResponseErrorFormat, InstallationsPath)
-	}
+	i.Installations = s.InstallationsWindow.apply(i.Installations)
 	for _, installation := range i.Installations {
 		delete(installation, "user_name")
 	}
@@ -96,6 +164,56 @@ func (s *Service) Installations() (io.Reader, error) {
 	return bytes.NewReader(redactedContent), nil
 }
 
+// InstallationAnalytics derives apply changes durations, monthly success and
+// failure rates and the most frequently failing products from the whole
+// installation history, which stays small however long the history is
+func (s *Service) InstallationAnalytics() (io.Reader, error) {
+	i, err := s.installations()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := json.Marshal(newInstallationAnalytics(i.Installations))
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(content), nil
+}
+
+// ListInstallations lists the installations for the installation guard. It
+// requests them every time, since the guard polls while an installation is
+// running, and keeps the latest response for the installation datasets.
+func (s *Service) ListInstallations() ([]api.InstallationsServiceOutput, error) {
+	contents, err := s.makeRequest(InstallationsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var i struct {
+		Installations []api.InstallationsServiceOutput `json:"installations"`
+	}
+	if err := json.Unmarshal(contents, &i); err != nil {
+		return nil, errors.Wrapf(err, InvalidResponseErrorFormat, InstallationsPath)
+	}
+
+	s.keepResponse(InstallationsPath, contents)
+	return i.Installations, nil
+}
+
+func (s *Service) installations() (installations, error) {
+	contents, err := s.makeSharedRequest(InstallationsPath)
+	if err != nil {
+		return installations{}, err
+	}
+
+	var i installations
+	if err := json.Unmarshal(contents, &i); err != nil {
+		return installations{}, errors.Wrapf(err, InvalidResponseErrorFormat, InstallationsPath)
+	}
+	return i, nil
+}
+
 func (s *Service) CertificateAuthorities() (io.Reader, error) {
 	contents, err := s.makeRequest(CertificateAuthoritiesPath)
 	if err != nil {
@@ -155,12 +273,213 @@ func (s *Service) ProductProperties(guid string) (io.Reader, error) {
 	return bytes.NewReader(redactedContent), nil
 }
 
+// DeployedProductResources reads the instance groups of the deployed
+// manifest, which describe what is running even when changes are pending
+func (s *Service) DeployedProductResources(guid string) (io.Reader, error) {
+	m, err := s.deployedManifest(guid)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := json.Marshal(m.resources())
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(content), nil
+}
+
+// DeployedProductProperties reads the job properties of the deployed
+// manifest, keeping only numbers and booleans
+func (s *Service) DeployedProductProperties(guid string) (io.Reader, error) {
+	m, err := s.deployedManifest(guid)
+	if err != nil {
+		return nil, err
+	}
+
+	redactedContent, err := json.Marshal(m.properties())
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(redactedContent), nil
+}
+
+func (s *Service) deployedManifest(guid string) (manifest, error) {
+	manifestPath := fmt.Sprintf(DeployedProductManifestPathFormat, guid)
+	contents, err := s.makeRequest(manifestPath)
+	if err != nil {
+		return manifest{}, err
+	}
+
+	var m manifest
+	if err := json.Unmarshal(contents, &m); err != nil {
+		return manifest{}, errors.Wrapf(err, InvalidResponseErrorFormat, manifestPath)
+	}
+	return m, nil
+}
+
+// StemcellAssociations reads the stemcells staged, deployed and available
+// for each product, leaving out product GUIDs and the stemcell library
+func (s *Service) StemcellAssociations() (io.Reader, error) {
+	contents, err := s.makeRequest(StemcellAssociationsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var sa stemcellAssociations
+	if err := json.Unmarshal(contents, &sa); err != nil {
+		return nil, errors.Wrapf(err, InvalidResponseErrorFormat, StemcellAssociationsPath)
+	}
+
+	redactedContent, err := json.Marshal(sa)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(redactedContent), nil
+}
+
+// ProductErrands reads the errands of a product and when they run, leaving
+// out their labels
+func (s *Service) ProductErrands(guid string) (io.Reader, error) {
+	productErrandsPath := fmt.Sprintf(ProductErrandsPathFormat, guid)
+	contents, err := s.makeRequest(productErrandsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var pe productErrands
+	if err := json.Unmarshal(contents, &pe); err != nil {
+		return nil, errors.Wrapf(err, InvalidResponseErrorFormat, productErrandsPath)
+	}
+
+	redactedContent, err := json.Marshal(pe)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(redactedContent), nil
+}
+
+// DirectorNetwork reduces the director's availability zones and networks to
+// counts, subnet sizes and availability zone names, hashing addresses with
+// the address key
+func (s *Service) DirectorNetwork() (io.Reader, error) {
+	key := s.AddressKey
+	if len(key) == 0 {
+		key = make([]byte, addressKeyLength)
+		if _, err := rand.Read(key); err != nil {
+			return nil, errors.Wrap(err, AddressKeyFailureMessage)
+		}
+	}
+
+	azContents, err := s.makeRequest(DirectorAvailabilityZonesPath)
+	if err != nil {
+		return nil, err
+	}
+	var azs availabilityZones
+	if err := json.Unmarshal(azContents, &azs); err != nil {
+		return nil, errors.Wrapf(err, InvalidResponseErrorFormat, DirectorAvailabilityZonesPath)
+	}
+
+	networkContents, err := s.makeRequest(DirectorNetworksPath)
+	if err != nil {
+		return nil, err
+	}
+	var ns networks
+	if err := json.Unmarshal(networkContents, &ns); err != nil {
+		return nil, errors.Wrapf(err, InvalidResponseErrorFormat, DirectorNetworksPath)
+	}
+
+	redactedContent, err := json.Marshal(newDirectorNetwork(key, azs, ns))
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(redactedContent), nil
+}
+
+// Settings records the authentication, certificate, syslog, banner, Tanzu
+// Network token and IaaS settings as facts, leaving out every URL, DN and
+// secret. Settings whose endpoint is not found are recorded as null.
+func (s *Service) Settings() (io.Reader, error) {
+	var st settings
+
+	var sslCertificate sslCertificateSettings
+	if found, err := s.readSettings(SSLCertificateSettingsPath, &sslCertificate); err != nil {
+		return nil, err
+	} else if found {
+		st.setSSLCertificate(sslCertificate)
+	}
+
+	var syslog syslogSettings
+	if found, err := s.readSettings(SyslogSettingsPath, &syslog); err != nil {
+		return nil, err
+	} else if found {
+		st.setSyslog(syslog)
+	}
+
+	var banner bannerSettings
+	if found, err := s.readSettings(BannerSettingsPath, &banner); err != nil {
+		return nil, err
+	} else if found {
+		st.setBanner(banner)
+	}
+
+	var pivnet pivnetSettings
+	if found, err := s.readSettings(PivnetSettingsPath, &pivnet); err != nil {
+		return nil, err
+	} else if found {
+		st.setPivnet(pivnet)
+	}
+
+	var rbac rbacSettings
+	if found, err := s.readSettings(RBACSettingsPath, &rbac); err != nil {
+		return nil, err
+	} else if found {
+		st.setAuthType(rbac)
+	}
+
+	var infrastructure diagnosticInfrastructure
+	if found, err := s.readSettings(DiagnosticReportPath, &infrastructure); err != nil {
+		return nil, err
+	} else if found {
+		st.setIaaSType(infrastructure)
+	}
+
+	content, err := json.Marshal(st)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(content), nil
+}
+
+// readSettings unmarshals the response of a settings endpoint, reporting
+// whether Ops Manager serves it. Responses are shared, as the IaaS type is
+// read from the diagnostic report collected on its own.
+func (s *Service) readSettings(path string, target interface{}) (bool, error) {
+	contents, err := s.makeSharedRequest(path)
+	if _, notFound := err.(NotFoundError); notFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := json.Unmarshal(contents, target); err != nil {
+		return false, errors.Wrapf(err, InvalidResponseErrorFormat, path)
+	}
+	return true, nil
+}
+
 func (s *Service) VmTypes() (io.Reader, error) {
 	return s.makeRequestReader(VmTypesPath)
 }
 
 func (s *Service) DiagnosticReport() (io.Reader, error) {
-	diagnosticReportBytes, err := s.makeRequest(DiagnosticReportPath)
+	diagnosticReportBytes, err := s.makeSharedRequest(DiagnosticReportPath)
 	if err != nil {
 		return nil, err
 	}
@@ -223,6 +542,27 @@ func (s *Service) makeRequestReader(path string) (io.Reader, error) {
 	return bytes.NewReader(content), nil
 }
 
+// makeSharedRequest requests a path the first time it is read, and returns
+// the same response afterwards
+func (s *Service) makeSharedRequest(path string) ([]byte, error) {
+	if contents, ok := s.responses[path]; ok {
+		return contents, nil
+	}
+	contents, err := s.makeRequest(path)
+	if err != nil {
+		return nil, err
+	}
+	s.keepResponse(path, contents)
+	return contents, nil
+}
+
+func (s *Service) keepResponse(path string, contents []byte) {
+	if s.responses == nil {
+		s.responses = map[string][]byte{}
+	}
+	s.responses[path] = contents
+}
+
 func (s *Service) makeRequest(path string) ([]byte, error) {
 	input := api.RequestServiceCurlInput{
 		Path:    path,
@@ -235,6 +575,12 @@ func (s *Service) makeRequest(path string) ([]byte, error) {
 	}
 
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusForbidden {
+		return nil, ForbiddenError{Method: http.MethodGet, Path: path}
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, NotFoundError{Method: http.MethodGet, Path: path}
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, errors.New(fmt.Sprintf(RequestUnexpectedStatusErrorFormat, http.MethodGet, path, resp.StatusCode))
 	}
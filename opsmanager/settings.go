@@ -0,0 +1,129 @@
+package opsmanager
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SettingsDataType = "settings"
+
+	UnknownAuthType = "unknown"
+	SAMLAuthType    = "saml"
+	LDAPAuthType    = "ldap"
+)
+
+type sslCertificateSettings struct {
+	SSLCertificate struct {
+		Certificate string `json:"certificate"`
+	} `json:"ssl_certificate"`
+}
+
+type syslogSettings struct {
+	Syslog struct {
+		Enabled interface{} `json:"enabled"`
+	} `json:"syslog"`
+}
+
+type bannerSettings struct {
+	UIBanner  string `json:"ui_banner_contents"`
+	SSHBanner string `json:"ssh_banner_contents"`
+}
+
+type pivnetSettings struct {
+	PivotalNetworkSettings struct {
+		APIToken string `json:"api_token"`
+	} `json:"pivotal_network_settings"`
+}
+
+type rbacSettings struct {
+	SAMLAdminGroup      string `json:"rbac_saml_admin_group"`
+	SAMLGroupsAttribute string `json:"rbac_saml_groups_attribute"`
+	LDAPAdminGroupName  string `json:"ldap_rbac_admin_group_name"`
+}
+
+type diagnosticInfrastructure struct {
+	InfrastructureType string `json:"infrastructure_type"`
+}
+
+// settings records how Ops Manager is configured without any URL, DN or
+// secret. A fact is null when the endpoint it is read from is not served by
+// the Ops Manager version.
+type settings struct {
+	AuthType                *string `json:"auth_type"`
+	CustomSSLCertificate    *bool   `json:"custom_ssl_certificate"`
+	SSLCertificateExpiresOn *string `json:"ssl_certificate_expires_on,omitempty"`
+	SyslogEnabled           *bool   `json:"syslog_enabled"`
+	UIBannerSet             *bool   `json:"ui_banner_set"`
+	SSHBannerSet            *bool   `json:"ssh_banner_set"`
+	PivnetTokenConfigured   *bool   `json:"pivnet_token_configured"`
+	IaaSType                *string `json:"iaas_type"`
+}
+
+func (s *settings) setSSLCertificate(c sslCertificateSettings) {
+	certificate := strings.TrimSpace(c.SSLCertificate.Certificate)
+	s.CustomSSLCertificate = boolPointer(certificate != "")
+
+	block, _ := pem.Decode([]byte(certificate))
+	if block == nil {
+		return
+	}
+	parsed, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return
+	}
+	expiresOn := parsed.NotAfter.UTC().Format(time.RFC3339)
+	s.SSLCertificateExpiresOn = &expiresOn
+}
+
+// setSyslog reads whether syslog is enabled, which Ops Manager returns either
+// as a boolean or as a string
+func (s *settings) setSyslog(c syslogSettings) {
+	switch enabled := c.Syslog.Enabled.(type) {
+	case bool:
+		s.SyslogEnabled = boolPointer(enabled)
+	case string:
+		parsed, _ := strconv.ParseBool(enabled)
+		s.SyslogEnabled = boolPointer(parsed)
+	default:
+		s.SyslogEnabled = boolPointer(false)
+	}
+}
+
+func (s *settings) setBanner(c bannerSettings) {
+	s.UIBannerSet = boolPointer(strings.TrimSpace(c.UIBanner) != "")
+	s.SSHBannerSet = boolPointer(strings.TrimSpace(c.SSHBanner) != "")
+}
+
+func (s *settings) setPivnet(c pivnetSettings) {
+	s.PivnetTokenConfigured = boolPointer(strings.TrimSpace(c.PivotalNetworkSettings.APIToken) != "")
+}
+
+// setAuthType infers the authentication from the RBAC settings, which only
+// name groups when users come from a SAML or LDAP provider. Ops Manager does
+// not report its authentication otherwise, and a SAML or LDAP provider
+// without RBAC groups looks like internal authentication, so the
+// authentication is unknown without groups.
+func (s *settings) setAuthType(c rbacSettings) {
+	authType := UnknownAuthType
+	switch {
+	case c.SAMLAdminGroup != "" || c.SAMLGroupsAttribute != "":
+		authType = SAMLAuthType
+	case c.LDAPAdminGroupName != "":
+		authType = LDAPAuthType
+	}
+	s.AuthType = &authType
+}
+
+func (s *settings) setIaaSType(c diagnosticInfrastructure) {
+	if c.InfrastructureType != "" {
+		s.IaaSType = &c.InfrastructureType
+	}
+}
+
+func boolPointer(b bool) *bool {
+	return &b
+}
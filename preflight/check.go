@@ -0,0 +1,153 @@
+package preflight
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+)
+
+type Status string
+
+const (
+	StatusPass Status = "PASS"
+	StatusFail Status = "FAIL"
+	StatusSkip Status = "SKIP"
+
+	SkippedDependencyFormat = "skipped because %q did not pass"
+)
+
+// Check is a single pre-flight check against a dependency of collect
+type Check struct {
+	Name        string
+	Target      string
+	Remediation string
+	DependsOn   []string
+	Run         func() error
+}
+
+// Skipped is returned by a check whose target collect would not read, so that
+// it is reported as skipped with the reason instead of failing
+func Skipped(reason string) error {
+	return skipError{reason: reason}
+}
+
+type skipError struct {
+	reason string
+}
+
+func (e skipError) Error() string {
+	return e.reason
+}
+
+// remediator is implemented by errors which know a more specific
+// remediation hint than the check they were returned from
+type remediator interface {
+	Remediation() string
+}
+
+type Result struct {
+	Name        string
+	Target      string
+	Remediation string
+	Status      Status
+	Detail      string
+}
+
+// Run executes the checks in order. A check is skipped when any check it
+// depends on did not pass, since its failure would only repeat the cause, or
+// when it returns an error from Skipped.
+func Run(checks []Check) []Result {
+	statuses := map[string]Status{}
+	var results []Result
+
+	for _, check := range checks {
+		result := Result{Name: check.Name, Target: check.Target, Remediation: check.Remediation}
+
+		if dependency := failedDependency(check, statuses); dependency != "" {
+			result.Status = StatusSkip
+			result.Detail = fmt.Sprintf(SkippedDependencyFormat, dependency)
+		} else if err := check.Run(); errors.As(err, &skipError{}) {
+			result.Status = StatusSkip
+			result.Detail = err.Error()
+		} else if err != nil {
+			result.Status = StatusFail
+			result.Detail = err.Error()
+			var hinted remediator
+			if errors.As(err, &hinted) {
+				result.Remediation = hinted.Remediation()
+			}
+		} else {
+			result.Status = StatusPass
+		}
+
+		statuses[check.Name] = result.Status
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// Failed reports whether any of the results failed
+func Failed(results []Result) bool {
+	for _, result := range results {
+		if result.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Write prints the results as a pass/fail matrix, followed by the error and
+// a remediation hint for each failed check and the reason each check was
+// skipped
+func Write(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tTARGET\tRESULT")
+
+	counts := map[Status]int{}
+	var failures, skips []Result
+	for _, result := range results {
+		counts[result.Status]++
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", result.Name, result.Target, result.Status)
+		if result.Status == StatusFail {
+			failures = append(failures, result)
+		}
+		if result.Status == StatusSkip && result.Detail != "" {
+			skips = append(skips, result)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(failures) > 0 {
+		fmt.Fprintln(w, "\nFAILURES")
+	}
+	for _, failure := range failures {
+		fmt.Fprintf(w, "\n%s (%s)\n  error: %s\n", failure.Name, failure.Target, failure.Detail)
+		if failure.Remediation != "" {
+			fmt.Fprintf(w, "  hint: %s\n", failure.Remediation)
+		}
+	}
+
+	if len(skips) > 0 {
+		fmt.Fprintln(w, "\nSKIPPED")
+	}
+	for _, skip := range skips {
+		fmt.Fprintf(w, "\n%s (%s)\n  reason: %s\n", skip.Name, skip.Target, skip.Detail)
+	}
+
+	_, err := fmt.Fprintf(w, "\n%d passed, %d failed, %d skipped\n", counts[StatusPass], counts[StatusFail], counts[StatusSkip])
+	return err
+}
+
+func failedDependency(check Check, statuses map[string]Status) string {
+	for _, dependency := range check.DependsOn {
+		if statuses[dependency] != StatusPass {
+			return dependency
+		}
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package preflight_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotal-cf/aqueduct-courier/preflight"
+)
+
+var _ = Describe("Check", func() {
+	passing := func() error { return nil }
+	failing := func() error { return errors.New("boom") }
+
+	Describe("Run", func() {
+		It("runs every check in order", func() {
+			var ran []string
+			record := func(name string) func() error {
+				return func() error {
+					ran = append(ran, name)
+					return nil
+				}
+			}
+
+			results := Run([]Check{
+				{Name: "first", Target: "first-target", Run: record("first")},
+				{Name: "second", Target: "second-target", Run: record("second")},
+			})
+
+			Expect(ran).To(Equal([]string{"first", "second"}))
+			Expect(results).To(Equal([]Result{
+				{Name: "first", Target: "first-target", Status: StatusPass},
+				{Name: "second", Target: "second-target", Status: StatusPass},
+			}))
+		})
+
+		It("records the error and remediation of failing checks", func() {
+			results := Run([]Check{{Name: "failing", Remediation: "fix it", Run: failing}})
+
+			Expect(results).To(Equal([]Result{
+				{Name: "failing", Remediation: "fix it", Status: StatusFail, Detail: "boom"},
+			}))
+		})
+
+		It("skips checks whose dependencies did not pass", func() {
+			ran := false
+			results := Run([]Check{
+				{Name: "failing", Run: failing},
+				{Name: "dependent", DependsOn: []string{"failing"}, Run: func() error { ran = true; return nil }},
+				{Name: "transitive", DependsOn: []string{"dependent"}, Run: passing},
+			})
+
+			Expect(ran).To(BeFalse())
+			Expect(results[1].Status).To(Equal(StatusSkip))
+			Expect(results[1].Detail).To(Equal(fmt.Sprintf(SkippedDependencyFormat, "failing")))
+			Expect(results[2].Status).To(Equal(StatusSkip))
+			Expect(results[2].Detail).To(Equal(fmt.Sprintf(SkippedDependencyFormat, "dependent")))
+		})
+
+		It("skips checks which return a skip reason", func() {
+			results := Run([]Check{
+				{Name: "skipped", Remediation: "fix it", Run: func() error { return Skipped("not read by collect") }},
+				{Name: "dependent", DependsOn: []string{"skipped"}, Run: passing},
+			})
+
+			Expect(results[0]).To(Equal(Result{Name: "skipped", Remediation: "fix it", Status: StatusSkip, Detail: "not read by collect"}))
+			Expect(results[1].Status).To(Equal(StatusSkip))
+		})
+
+		It("runs checks whose dependencies passed", func() {
+			results := Run([]Check{
+				{Name: "passing", Run: passing},
+				{Name: "dependent", DependsOn: []string{"passing"}, Run: passing},
+			})
+
+			Expect(results[1].Status).To(Equal(StatusPass))
+		})
+	})
+
+	Describe("Failed", func() {
+		It("is true when any check failed", func() {
+			Expect(Failed([]Result{{Status: StatusPass}, {Status: StatusFail}})).To(BeTrue())
+		})
+
+		It("is false when checks only passed or were skipped", func() {
+			Expect(Failed([]Result{{Status: StatusPass}, {Status: StatusSkip}})).To(BeFalse())
+		})
+	})
+
+	Describe("Write", func() {
+		It("prints a matrix of results followed by the failures and a summary", func() {
+			output := &bytes.Buffer{}
+			err := Write(output, []Result{
+				{Name: "passing", Target: "passing-target", Status: StatusPass},
+				{Name: "failing", Target: "failing-target", Remediation: "fix it", Status: StatusFail, Detail: "boom"},
+				{Name: "skipped", Target: "skipped-target", Status: StatusSkip},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(output.String()).To(Equal(`CHECK    TARGET          RESULT
+passing  passing-target  PASS
+failing  failing-target  FAIL
+skipped  skipped-target  SKIP
+
+FAILURES
+
+failing (failing-target)
+  error: boom
+  hint: fix it
+
+1 passed, 1 failed, 1 skipped
+`))
+		})
+
+		It("prints the reason each check was skipped", func() {
+			output := &bytes.Buffer{}
+			err := Write(output, []Result{
+				{Name: "skipped", Target: "skipped-target", Status: StatusSkip, Detail: "not read by collect"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(output.String()).To(Equal(`CHECK    TARGET          RESULT
+skipped  skipped-target  SKIP
+
+SKIPPED
+
+skipped (skipped-target)
+  reason: not read by collect
+
+0 passed, 0 failed, 1 skipped
+`))
+		})
+
+		It("omits the failures section when every check passed", func() {
+			output := &bytes.Buffer{}
+			Expect(Write(output, []Result{{Name: "passing", Target: "target", Status: StatusPass}})).To(Succeed())
+
+			Expect(output.String()).NotTo(ContainSubstring("FAILURES"))
+			Expect(output.String()).To(HaveSuffix("1 passed, 0 failed, 0 skipped\n"))
+		})
+	})
+})
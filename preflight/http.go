@@ -0,0 +1,127 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const (
+	EndpointRemediation     = "Check the service is healthy and the URL points at it."
+	UnauthorizedRemediation = "Check the credentials are correct and have not expired."
+	ForbiddenRemediation    = "Grant the user or client a role or scope that can read this endpoint."
+	NotFoundRemediation     = "Check the URL is correct; this endpoint may not exist in the installed version."
+	TokenRemediation        = "Check the UAA credentials are correct and the client is allowed the requested grant type."
+
+	UnexpectedStatusErrorFormat = "%s %s returned with unexpected status %d"
+	TokenErrorFormat            = "could not retrieve a token from %s"
+)
+
+// UAACredentials selects the grant used to request a token. A username selects
+// the password grant; otherwise the client credentials grant is used.
+type UAACredentials struct {
+	ClientID     string
+	ClientSecret string
+	Username     string
+	Password     string
+}
+
+// Endpoint checks that a GET against the URL returns a successful status
+func Endpoint(name string, client httpClient, rawURL string, dependsOn ...string) Check {
+	return Request(name, rawURL, func() (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		return client.Do(req)
+	}, dependsOn...)
+}
+
+// Request checks that the GET made by do returns a successful status. The
+// remediation hint depends on the status returned.
+func Request(name, target string, do func() (*http.Response, error), dependsOn ...string) Check {
+	return Check{
+		Name:        name,
+		Target:      target,
+		Remediation: EndpointRemediation,
+		DependsOn:   dependsOn,
+		Run: func() error {
+			resp, err := do()
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+				return nil
+			}
+			return statusError{method: http.MethodGet, target: target, status: resp.StatusCode}
+		},
+	}
+}
+
+// UAAToken checks that a token can be retrieved from the UAA token endpoint
+func UAAToken(name string, client *http.Client, tokenURL string, credentials UAACredentials, dependsOn ...string) Check {
+	return Check{
+		Name:        name,
+		Target:      tokenURL,
+		Remediation: TokenRemediation,
+		DependsOn:   dependsOn,
+		Run: func() error {
+			ctx := context.WithValue(context.Background(), oauth2.HTTPClient, client)
+
+			var err error
+			if credentials.Username != "" {
+				conf := &oauth2.Config{
+					ClientID:     credentials.ClientID,
+					ClientSecret: credentials.ClientSecret,
+					Endpoint:     oauth2.Endpoint{TokenURL: tokenURL},
+				}
+				_, err = conf.PasswordCredentialsToken(ctx, credentials.Username, credentials.Password)
+			} else {
+				conf := &clientcredentials.Config{
+					ClientID:     credentials.ClientID,
+					ClientSecret: credentials.ClientSecret,
+					TokenURL:     tokenURL,
+				}
+				_, err = conf.Token(ctx)
+			}
+
+			return errors.Wrapf(err, TokenErrorFormat, tokenURL)
+		},
+	}
+}
+
+// Forbidden reports whether the check failed because the endpoint returned
+// 403 Forbidden
+func Forbidden(err error) bool {
+	var status statusError
+	return errors.As(err, &status) && status.status == http.StatusForbidden
+}
+
+// statusError carries a remediation hint matching the HTTP status returned
+type statusError struct {
+	method string
+	target string
+	status int
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf(UnexpectedStatusErrorFormat, e.method, e.target, e.status)
+}
+
+func (e statusError) Remediation() string {
+	switch e.status {
+	case http.StatusUnauthorized:
+		return UnauthorizedRemediation
+	case http.StatusForbidden:
+		return ForbiddenRemediation
+	case http.StatusNotFound:
+		return NotFoundRemediation
+	}
+	return EndpointRemediation
+}
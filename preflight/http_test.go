@@ -0,0 +1,111 @@
+package preflight_test
+
+import (
+	"fmt"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	. "github.com/pivotal-cf/aqueduct-courier/preflight"
+)
+
+var _ = Describe("HTTP", func() {
+	var server *ghttp.Server
+
+	BeforeEach(func() {
+		server = ghttp.NewTLSServer()
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("Endpoint", func() {
+		It("passes when the endpoint returns a successful status", func() {
+			server.RouteToHandler(http.MethodGet, "/some/path", ghttp.RespondWith(http.StatusOK, "{}"))
+
+			results := Run([]Check{Endpoint("endpoint", server.HTTPTestServer.Client(), server.URL()+"/some/path")})
+
+			Expect(results[0].Status).To(Equal(StatusPass), results[0].Detail)
+			Expect(results[0].Target).To(Equal(server.URL() + "/some/path"))
+		})
+
+		DescribeTable("fails with a hint matching the status returned",
+			func(status int, remediation string) {
+				server.RouteToHandler(http.MethodGet, "/some/path", ghttp.RespondWith(status, ""))
+
+				results := Run([]Check{Endpoint("endpoint", server.HTTPTestServer.Client(), server.URL()+"/some/path")})
+
+				Expect(results[0].Status).To(Equal(StatusFail))
+				Expect(results[0].Detail).To(Equal(fmt.Sprintf(UnexpectedStatusErrorFormat, http.MethodGet, server.URL()+"/some/path", status)))
+				Expect(results[0].Remediation).To(Equal(remediation))
+			},
+			Entry("unauthorized", http.StatusUnauthorized, UnauthorizedRemediation),
+			Entry("forbidden", http.StatusForbidden, ForbiddenRemediation),
+			Entry("not found", http.StatusNotFound, NotFoundRemediation),
+			Entry("server error", http.StatusInternalServerError, EndpointRemediation),
+		)
+
+		It("reports whether it failed with 403 Forbidden", func() {
+			server.RouteToHandler(http.MethodGet, "/forbidden", ghttp.RespondWith(http.StatusForbidden, ""))
+			server.RouteToHandler(http.MethodGet, "/missing", ghttp.RespondWith(http.StatusNotFound, ""))
+
+			Expect(Forbidden(Endpoint("forbidden", server.HTTPTestServer.Client(), server.URL()+"/forbidden").Run())).To(BeTrue())
+			Expect(Forbidden(Endpoint("missing", server.HTTPTestServer.Client(), server.URL()+"/missing").Run())).To(BeFalse())
+			Expect(Forbidden(nil)).To(BeFalse())
+		})
+
+		It("fails when the request cannot be made", func() {
+			closedURL := server.URL() + "/some/path"
+			server.Close()
+
+			results := Run([]Check{Endpoint("endpoint", http.DefaultClient, closedURL)})
+
+			Expect(results[0].Status).To(Equal(StatusFail))
+			Expect(results[0].Remediation).To(Equal(EndpointRemediation))
+		})
+	})
+
+	Describe("UAAToken", func() {
+		tokenResponse := ghttp.RespondWith(http.StatusOK, `{"access_token": "some-token", "token_type": "bearer", "expires_in": 3600}`, http.Header{"Content-Type": []string{"application/json"}})
+
+		It("requests a token with the client credentials grant", func() {
+			server.RouteToHandler(http.MethodPost, "/oauth/token", ghttp.CombineHandlers(
+				ghttp.VerifyBasicAuth("some-client", "some-secret"),
+				ghttp.VerifyFormKV("grant_type", "client_credentials"),
+				tokenResponse,
+			))
+
+			credentials := UAACredentials{ClientID: "some-client", ClientSecret: "some-secret"}
+			results := Run([]Check{UAAToken("token", server.HTTPTestServer.Client(), server.URL()+"/oauth/token", credentials)})
+
+			Expect(results[0].Status).To(Equal(StatusPass), results[0].Detail)
+		})
+
+		It("requests a token with the password grant when a username is given", func() {
+			server.RouteToHandler(http.MethodPost, "/oauth/token", ghttp.CombineHandlers(
+				ghttp.VerifyFormKV("grant_type", "password"),
+				ghttp.VerifyFormKV("username", "some-user"),
+				ghttp.VerifyFormKV("password", "some-password"),
+				tokenResponse,
+			))
+
+			credentials := UAACredentials{ClientID: "opsman", Username: "some-user", Password: "some-password"}
+			results := Run([]Check{UAAToken("token", server.HTTPTestServer.Client(), server.URL()+"/oauth/token", credentials)})
+
+			Expect(results[0].Status).To(Equal(StatusPass), results[0].Detail)
+		})
+
+		It("fails when UAA rejects the credentials", func() {
+			server.RouteToHandler(http.MethodPost, "/oauth/token", ghttp.RespondWith(http.StatusUnauthorized, `{"error": "unauthorized"}`))
+
+			results := Run([]Check{UAAToken("token", server.HTTPTestServer.Client(), server.URL()+"/oauth/token", UAACredentials{ClientID: "bad"})})
+
+			Expect(results[0].Status).To(Equal(StatusFail))
+			Expect(results[0].Detail).To(ContainSubstring(fmt.Sprintf(TokenErrorFormat, server.URL()+"/oauth/token")))
+			Expect(results[0].Remediation).To(Equal(TokenRemediation))
+		})
+	})
+})
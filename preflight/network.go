@@ -0,0 +1,213 @@
+package preflight
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DNSRemediation       = "Check the hostname is spelled correctly and resolvable from this machine's DNS servers."
+	TCPRemediation       = "Check firewalls and security groups allow this machine to reach the host and port, or set https_proxy."
+	TLSRemediation       = "Check the server presents a certificate trusted by this machine and supports TLS 1.2 or later, or skip TLS validation."
+	ClockSkewRemediation = "Synchronize this machine's clock with NTP; UAA rejects tokens when clocks disagree."
+
+	MaxClockSkew = time.Minute
+
+	InvalidURLErrorFormat    = "invalid URL %s"
+	NoDateHeaderErrorFormat  = "no Date header in response from %s"
+	ClockSkewErrorFormat     = "local clock differs from %s by %s"
+	ProxyTargetFormat        = "%s via proxy %s"
+	ProxyConnectErrorFormat  = "proxy %s refused to connect to %s: %s"
+	DefaultHTTPSPort         = "443"
+	DefaultHTTPPort          = "80"
+	NetworkCheckTimeout      = 10 * time.Second
+	ClockSkewCheckNameFormat = "%s clock skew"
+)
+
+type httpClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// NetworkChecks returns DNS, TCP and TLS checks for the host of the given
+// URL. When a proxy is configured for the URL, DNS and TCP are checked
+// against the proxy instead, and the TLS handshake is made through a tunnel
+// the proxy opens with CONNECT.
+func NetworkChecks(label, rawURL string, skipTLSVerify bool) []Check {
+	target, err := url.Parse(rawURL)
+	if err != nil || target.Host == "" {
+		return []Check{{
+			Name:   label + " URL",
+			Target: rawURL,
+			Run: func() error {
+				return errors.Errorf(InvalidURLErrorFormat, rawURL)
+			},
+		}}
+	}
+
+	address := hostPort(target)
+	dialAddress := address
+	proxyURL, err := http.ProxyFromEnvironment(&http.Request{URL: target})
+	if err != nil {
+		proxyURL = nil
+	}
+	if proxyURL != nil {
+		dialAddress = hostPort(proxyURL)
+	}
+	host, _, _ := net.SplitHostPort(dialAddress)
+
+	dnsName, tcpName, tlsName := label+" DNS", label+" TCP", label+" TLS"
+	checks := []Check{
+		{Name: dnsName, Target: host, Remediation: DNSRemediation, Run: resolveHost(host)},
+		{Name: tcpName, Target: dialAddress, Remediation: TCPRemediation, DependsOn: []string{dnsName}, Run: dialTCP(dialAddress)},
+	}
+
+	if target.Scheme == "https" {
+		if proxyURL != nil {
+			proxiedTarget := fmt.Sprintf(ProxyTargetFormat, address, proxyURL.Host)
+			checks = append(checks, Check{Name: tlsName, Target: proxiedTarget, Remediation: TLSRemediation, DependsOn: []string{tcpName}, Run: handshakeTLSThroughProxy(proxyURL, address, target.Hostname(), skipTLSVerify)})
+		} else {
+			checks = append(checks, Check{Name: tlsName, Target: address, Remediation: TLSRemediation, DependsOn: []string{tcpName}, Run: handshakeTLS(address, target.Hostname(), skipTLSVerify)})
+		}
+	}
+
+	return checks
+}
+
+// ClockSkew compares the local clock with the Date header returned by the
+// server at the given URL
+func ClockSkew(label string, client httpClient, rawURL string, dependsOn ...string) Check {
+	return Check{
+		Name:        fmt.Sprintf(ClockSkewCheckNameFormat, label),
+		Target:      rawURL,
+		Remediation: ClockSkewRemediation,
+		DependsOn:   dependsOn,
+		Run: func() error {
+			req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+			if err != nil {
+				return err
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+			if err != nil {
+				return errors.Errorf(NoDateHeaderErrorFormat, rawURL)
+			}
+
+			skew := time.Since(serverTime)
+			if skew < 0 {
+				skew = -skew
+			}
+			if skew > MaxClockSkew {
+				return errors.Errorf(ClockSkewErrorFormat, rawURL, skew.Round(time.Second))
+			}
+			return nil
+		},
+	}
+}
+
+func resolveHost(host string) func() error {
+	return func() error {
+		if net.ParseIP(host) != nil {
+			return nil
+		}
+		_, err := net.LookupHost(host)
+		return err
+	}
+}
+
+func dialTCP(address string) func() error {
+	return func() error {
+		conn, err := net.DialTimeout("tcp", address, NetworkCheckTimeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+func handshakeTLS(address, serverName string, skipTLSVerify bool) func() error {
+	return func() error {
+		dialer := &net.Dialer{Timeout: NetworkCheckTimeout}
+		conn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{
+			ServerName:         serverName,
+			InsecureSkipVerify: skipTLSVerify,
+			MinVersion:         tls.VersionTLS12,
+		})
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+// handshakeTLSThroughProxy asks the proxy to CONNECT to the address, then
+// makes the TLS handshake with the server through the tunnel
+func handshakeTLSThroughProxy(proxyURL *url.URL, address, serverName string, skipTLSVerify bool) func() error {
+	return func() error {
+		conn, err := net.DialTimeout("tcp", hostPort(proxyURL), NetworkCheckTimeout)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if err := conn.SetDeadline(time.Now().Add(NetworkCheckTimeout)); err != nil {
+			return err
+		}
+
+		tunnel := conn
+		if proxyURL.Scheme == "https" {
+			tunnel = tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname(), MinVersion: tls.VersionTLS12})
+		}
+
+		connectRequest := &http.Request{
+			Method: http.MethodConnect,
+			URL:    &url.URL{Opaque: address},
+			Host:   address,
+			Header: make(http.Header),
+		}
+		if proxyURL.User != nil {
+			password, _ := proxyURL.User.Password()
+			credentials := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+			connectRequest.Header.Set("Proxy-Authorization", "Basic "+credentials)
+		}
+		if err := connectRequest.Write(tunnel); err != nil {
+			return err
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(tunnel), connectRequest)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return errors.Errorf(ProxyConnectErrorFormat, proxyURL.Host, address, resp.Status)
+		}
+
+		return tls.Client(tunnel, &tls.Config{
+			ServerName:         serverName,
+			InsecureSkipVerify: skipTLSVerify,
+			MinVersion:         tls.VersionTLS12,
+		}).Handshake()
+	}
+}
+
+func hostPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = DefaultHTTPSPort
+		if u.Scheme == "http" {
+			port = DefaultHTTPPort
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
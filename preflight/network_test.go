@@ -0,0 +1,107 @@
+package preflight_test
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	. "github.com/pivotal-cf/aqueduct-courier/preflight"
+)
+
+var _ = Describe("Network", func() {
+	var server *ghttp.Server
+
+	BeforeEach(func() {
+		server = ghttp.NewTLSServer()
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("NetworkChecks", func() {
+		It("checks DNS, TCP and TLS for an https URL", func() {
+			results := Run(NetworkChecks("Server", server.URL(), true))
+
+			Expect(results).To(HaveLen(3))
+			Expect(results[0].Name).To(Equal("Server DNS"))
+			Expect(results[1].Name).To(Equal("Server TCP"))
+			Expect(results[2].Name).To(Equal("Server TLS"))
+			for _, result := range results {
+				Expect(result.Status).To(Equal(StatusPass), result.Detail)
+			}
+		})
+
+		It("does not check TLS for an http URL", func() {
+			results := Run(NetworkChecks("Server", "http://127.0.0.1:80", false))
+
+			Expect(results).To(HaveLen(2))
+		})
+
+		It("fails TLS when the certificate is not trusted", func() {
+			results := Run(NetworkChecks("Server", server.URL(), false))
+
+			Expect(results[2].Status).To(Equal(StatusFail))
+			Expect(results[2].Remediation).To(Equal(TLSRemediation))
+		})
+
+		It("fails TCP and skips TLS when nothing is listening", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			closedAddress := listener.Addr().String()
+			Expect(listener.Close()).To(Succeed())
+
+			results := Run(NetworkChecks("Server", "https://"+closedAddress, true))
+
+			Expect(results[0].Status).To(Equal(StatusPass))
+			Expect(results[1].Status).To(Equal(StatusFail))
+			Expect(results[1].Remediation).To(Equal(TCPRemediation))
+			Expect(results[2].Status).To(Equal(StatusSkip))
+		})
+
+		It("fails for a URL without a host", func() {
+			results := Run(NetworkChecks("Server", "not-a-url", true))
+
+			Expect(results).To(HaveLen(1))
+			Expect(results[0].Status).To(Equal(StatusFail))
+			Expect(results[0].Detail).To(Equal(fmt.Sprintf(InvalidURLErrorFormat, "not-a-url")))
+		})
+	})
+
+	Describe("ClockSkew", func() {
+		It("passes when the server clock agrees with the local clock", func() {
+			server.RouteToHandler(http.MethodGet, "/", ghttp.RespondWith(http.StatusOK, ""))
+
+			results := Run([]Check{ClockSkew("Server", server.HTTPTestServer.Client(), server.URL()+"/")})
+
+			Expect(results[0].Name).To(Equal("Server clock skew"))
+			Expect(results[0].Status).To(Equal(StatusPass), results[0].Detail)
+		})
+
+		It("fails when the server clock differs by more than the maximum skew", func() {
+			serverTime := time.Now().Add(-2 * MaxClockSkew).UTC().Format(http.TimeFormat)
+			server.RouteToHandler(http.MethodGet, "/", ghttp.RespondWith(http.StatusOK, "", http.Header{"Date": []string{serverTime}}))
+
+			results := Run([]Check{ClockSkew("Server", server.HTTPTestServer.Client(), server.URL()+"/")})
+
+			Expect(results[0].Status).To(Equal(StatusFail))
+			Expect(results[0].Detail).To(ContainSubstring("local clock differs from"))
+			Expect(results[0].Remediation).To(Equal(ClockSkewRemediation))
+		})
+
+		It("fails when the server does not return a Date header", func() {
+			server.RouteToHandler(http.MethodGet, "/", func(w http.ResponseWriter, req *http.Request) {
+				w.Header()["Date"] = nil
+			})
+
+			results := Run([]Check{ClockSkew("Server", server.HTTPTestServer.Client(), server.URL()+"/")})
+
+			Expect(results[0].Status).To(Equal(StatusFail))
+			Expect(results[0].Detail).To(Equal(fmt.Sprintf(NoDateHeaderErrorFormat, server.URL()+"/")))
+		})
+	})
+})
@@ -0,0 +1,289 @@
+package pseudonym
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	GUIDKind         = "guid"
+	InstallationKind = "installation"
+	CertificateKind  = "certificate"
+	HostKind         = "host"
+	IPKind           = "ip"
+
+	MinimumSaltLength = 16
+
+	ShortSaltErrorFormat        = "the pseudonymize salt must be at least %d characters"
+	WriteLookupTableErrorFormat = "could not write the pseudonym lookup table %s"
+
+	tokenHexLength = 16
+)
+
+var (
+	uuidPattern = regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+	// Ops Manager names products and their deployments with a GUID such as
+	// cf-0123456789abcdef0123
+	opsManagerGUIDPattern = regexp.MustCompile(`\b[a-z0-9][a-z0-9_-]*-[0-9a-f]{20}\b`)
+	ipv4Pattern           = regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])\.){3}(?:25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])\b`)
+	urlHostPattern        = regexp.MustCompile(`(?i)\b([a-z][a-z0-9+.-]*://)(?:[^@/\s"']*@)?(\[[0-9a-f:.]+\]|[^/:\s"'?#]+)`)
+	// fqdnPattern finds bare hostnames of three or more labels ending in an
+	// alphabetic top level domain. Names following a dot, such as the Ops
+	// Manager property .properties.smtp.auth.mechanism, are left alone.
+	fqdnPattern = regexp.MustCompile(`(?i)(^|[^\w.-])((?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.){2,}[a-z]{2,63})\b`)
+	hasLetter   = regexp.MustCompile(`[A-Za-z]`)
+	// fileExtensions end file names which would otherwise read as hostnames
+	fileExtensions = map[string]bool{"tgz": true, "gz": true, "tar": true, "zip": true, "pivotal": true, "yml": true, "yaml": true, "json": true, "txt": true, "log": true, "pem": true, "crt": true, "key": true, "sh": true}
+
+	// guidFields and hostFields are pseudonymized wherever they appear,
+	// along with fields ending in _guid, or in _host, _hostname, _domain or
+	// _domains
+	guidFields    = map[string]bool{"guid": true, "installation_name": true, "foundation_id": true}
+	hostFields    = map[string]bool{"host": true, "hostname": true, "fqdn": true, "domain": true, "domains": true, "address": true}
+	hostFieldEnds = []string{"_host", "_hostname", "_domain", "_domains"}
+
+	// listFields are pseudonymized in the objects listed under a field
+	listFields = map[string]map[string]string{
+		"installations":        {"id": InstallationKind},
+		"credhub_certificates": {"name": CertificateKind},
+	}
+)
+
+// Pseudonymizer replaces identifiers with tokens keyed by a salt, so the same
+// identifier maps to the same token in every file and every collection made
+// with the salt. It remembers the identifier behind each token for the lookup
+// table.
+type Pseudonymizer struct {
+	key              []byte
+	keepFoundationID bool
+	identifiers      map[string]Pseudonym
+}
+
+// Pseudonym is an identifier and the token replacing it
+type Pseudonym struct {
+	Token      string `json:"token"`
+	Kind       string `json:"kind"`
+	Identifier string `json:"identifier"`
+}
+
+func New(salt string, keepFoundationID bool) (*Pseudonymizer, error) {
+	if len(salt) < MinimumSaltLength {
+		return nil, errors.Errorf(ShortSaltErrorFormat, MinimumSaltLength)
+	}
+	return &Pseudonymizer{key: []byte(salt), keepFoundationID: keepFoundationID, identifiers: map[string]Pseudonym{}}, nil
+}
+
+// FoundationID is the foundation id recorded in the metadata, left as is when
+// the pseudonymizer keeps it
+func (p *Pseudonymizer) FoundationID(foundationID string) string {
+	if p.keepFoundationID || foundationID == "" {
+		return foundationID
+	}
+	return p.token(GUIDKind, foundationID)
+}
+
+// Pseudonymize replaces the identifiers in a collected file. JSON is walked
+// so fields can be recognized by name, and other content has the GUIDs, IP
+// addresses and hostnames in its text replaced.
+func (p *Pseudonymizer) Pseudonymize(contents []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil || decoder.More() {
+		return []byte(p.text(string(contents))), nil
+	}
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(p.value(document, "")); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), nil
+}
+
+// LookupTable lists every pseudonym given so far, ordered by token
+func (p *Pseudonymizer) LookupTable() []Pseudonym {
+	table := make([]Pseudonym, 0, len(p.identifiers))
+	for _, pseudonym := range p.identifiers {
+		table = append(table, pseudonym)
+	}
+	sort.Slice(table, func(i, j int) bool { return table[i].Token < table[j].Token })
+	return table
+}
+
+// WriteLookupTable writes the lookup table readable only by the user, since
+// it reverses the pseudonyms
+func (p *Pseudonymizer) WriteLookupTable(tablePath string) error {
+	contents, err := json.MarshalIndent(map[string][]Pseudonym{"pseudonyms": p.LookupTable()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(tablePath, contents, 0600); err != nil {
+		return errors.Wrapf(err, WriteLookupTableErrorFormat, tablePath)
+	}
+	return nil
+}
+
+func (p *Pseudonymizer) value(v interface{}, field string) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for key, child := range value {
+			result[p.text(key)] = p.field(key, child, listFields[field])
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, child := range value {
+			result[i] = p.value(child, field)
+		}
+		return result
+	case string:
+		return p.text(value)
+	}
+	return v
+}
+
+func (p *Pseudonymizer) field(name string, v interface{}, listed map[string]string) interface{} {
+	lowerName := strings.ToLower(name)
+	if isHostField(lowerName) {
+		return p.hostValue(v, name)
+	}
+
+	identifier, ok := scalar(v)
+	if !ok || identifier == "" {
+		return p.value(v, name)
+	}
+
+	switch {
+	case listed[lowerName] != "":
+		return p.token(listed[lowerName], identifier)
+	case guidFields[lowerName] || strings.HasSuffix(lowerName, "_guid"):
+		return p.token(GUIDKind, identifier)
+	}
+	return p.value(v, name)
+}
+
+// hostValue replaces the hosts held by a host field: a host, a list of
+// hosts, or an Ops Manager property with the host as its value
+func (p *Pseudonymizer) hostValue(v interface{}, field string) interface{} {
+	switch value := v.(type) {
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, child := range value {
+			result[i] = p.hostValue(child, field)
+		}
+		return result
+	case map[string]interface{}:
+		result := p.value(value, field).(map[string]interface{})
+		if propertyValue, ok := value["value"]; ok {
+			result["value"] = p.hostValue(propertyValue, field)
+		}
+		return result
+	}
+
+	identifier, ok := scalar(v)
+	if !ok || identifier == "" {
+		return p.value(v, field)
+	}
+	if ip := net.ParseIP(identifier); ip != nil {
+		return p.token(IPKind, identifier)
+	}
+	if strings.Contains(identifier, "://") {
+		return p.text(identifier)
+	}
+	return p.token(HostKind, identifier)
+}
+
+func isHostField(lowerName string) bool {
+	if hostFields[lowerName] {
+		return true
+	}
+	for _, end := range hostFieldEnds {
+		if strings.HasSuffix(lowerName, end) {
+			return true
+		}
+	}
+	return false
+}
+
+// text replaces the identifiers found in free text
+func (p *Pseudonymizer) text(s string) string {
+	if ip := net.ParseIP(s); ip != nil {
+		return p.token(IPKind, s)
+	}
+	if _, _, err := net.ParseCIDR(s); err == nil {
+		address, prefix, _ := strings.Cut(s, "/")
+		return p.token(IPKind, address) + "/" + prefix
+	}
+
+	s = urlHostPattern.ReplaceAllStringFunc(s, func(match string) string {
+		parts := urlHostPattern.FindStringSubmatch(match)
+		host := strings.Trim(parts[2], "[]")
+		if net.ParseIP(host) != nil {
+			return parts[1] + p.token(IPKind, host)
+		}
+		return parts[1] + p.token(HostKind, host)
+	})
+	s = fqdnPattern.ReplaceAllStringFunc(s, func(match string) string {
+		parts := fqdnPattern.FindStringSubmatch(match)
+		labels := strings.Split(parts[2], ".")
+		// File names, and versions such as cf-2.13.4 followed by a word,
+		// are not hosts
+		secondLevel, topLevel := labels[len(labels)-2], labels[len(labels)-1]
+		if fileExtensions[strings.ToLower(topLevel)] || len(secondLevel) < 2 || !hasLetter.MatchString(secondLevel) {
+			return match
+		}
+		return parts[1] + p.token(HostKind, parts[2])
+	})
+	s = uuidPattern.ReplaceAllStringFunc(s, func(match string) string {
+		return p.token(GUIDKind, match)
+	})
+	s = opsManagerGUIDPattern.ReplaceAllStringFunc(s, func(match string) string {
+		return p.token(GUIDKind, match)
+	})
+	return ipv4Pattern.ReplaceAllStringFunc(s, func(match string) string {
+		return p.token(IPKind, match)
+	})
+}
+
+// token is the keyed hash of an identifier, prefixed with its kind. Tokens
+// do not match the patterns of any identifier, so replacing is idempotent.
+// GUIDs and hosts are compared without case and IP addresses in their
+// canonical form, so an identifier has one token wherever it is found.
+func (p *Pseudonymizer) token(kind, identifier string) string {
+	switch kind {
+	case GUIDKind, HostKind:
+		identifier = strings.ToLower(identifier)
+	case IPKind:
+		if ip := net.ParseIP(identifier); ip != nil {
+			identifier = ip.String()
+		}
+	}
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(identifier))
+	token := kind + "-" + hex.EncodeToString(mac.Sum(nil))[:tokenHexLength]
+	p.identifiers[token] = Pseudonym{Token: token, Kind: kind, Identifier: identifier}
+	return token
+}
+
+func scalar(v interface{}) (string, bool) {
+	switch value := v.(type) {
+	case string:
+		return value, true
+	case json.Number:
+		return value.String(), true
+	}
+	return "", false
+}
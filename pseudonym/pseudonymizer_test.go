@@ -0,0 +1,203 @@
+package pseudonym_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotal-cf/aqueduct-courier/pseudonym"
+)
+
+var _ = Describe("Pseudonymizer", func() {
+	const salt = "some-salt-of-sixteen-characters"
+
+	var pseudonymizer *Pseudonymizer
+
+	BeforeEach(func() {
+		var err error
+		pseudonymizer, err = New(salt, false)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	pseudonymize := func(contents string) map[string]interface{} {
+		result, err := pseudonymizer.Pseudonymize([]byte(contents))
+		Expect(err).NotTo(HaveOccurred())
+		var document map[string]interface{}
+		Expect(json.Unmarshal(result, &document)).To(Succeed())
+		return document
+	}
+
+	tokenOf := func(kind string) OmegaMatcher {
+		return MatchRegexp(`^` + kind + `-[0-9a-f]{16}$`)
+	}
+
+	It("requires a salt long enough to key the hashes", func() {
+		_, err := New("short", false)
+		Expect(err).To(MatchError("the pseudonymize salt must be at least 16 characters"))
+	})
+
+	It("replaces GUIDs, installation ids and CredHub certificate names found by field", func() {
+		document := pseudonymize(`{
+			"products": [{"guid": "cf", "product_guid": "cf-guid", "installation_name": "cf-abc123", "type": "cf"}],
+			"installations": [{"id": 42, "status": "succeeded"}],
+			"credhub_certificates": [{"name": "/p-bosh/cf/router_cert", "not_after": "2025-01-01T00:00:00Z"}]
+		}`)
+
+		product := document["products"].([]interface{})[0].(map[string]interface{})
+		Expect(product["guid"]).To(tokenOf(GUIDKind))
+		Expect(product["product_guid"]).To(tokenOf(GUIDKind))
+		Expect(product["installation_name"]).To(tokenOf(GUIDKind))
+		Expect(product["type"]).To(Equal("cf"))
+
+		installation := document["installations"].([]interface{})[0].(map[string]interface{})
+		Expect(installation["id"]).To(tokenOf(InstallationKind))
+		Expect(installation["status"]).To(Equal("succeeded"))
+
+		certificate := document["credhub_certificates"].([]interface{})[0].(map[string]interface{})
+		Expect(certificate["name"]).To(tokenOf(CertificateKind))
+		Expect(certificate["not_after"]).To(Equal("2025-01-01T00:00:00Z"))
+	})
+
+	It("replaces hostnames and IP addresses found by field or in text", func() {
+		document := pseudonymize(`{
+			"hostname": "opsman.example.com",
+			"director_host": "10.0.0.5",
+			"url": "https://uaa.sys.example.com:8443/oauth/token",
+			"message": "could not reach 10.0.4.12 from deployment cf-0123456789abcdef0123",
+			"vm_cid": "4b2f2c1e-8e4a-4a0b-9f3e-1c2d3e4f5a6b",
+			"subnet": "10.0.4.0/24",
+			"ipv6": "fd00::1",
+			"version": "2.13.4"
+		}`)
+
+		Expect(document["hostname"]).To(tokenOf(HostKind))
+		Expect(document["director_host"]).To(tokenOf(IPKind))
+		Expect(document["url"]).To(MatchRegexp(`^https://host-[0-9a-f]{16}:8443/oauth/token$`))
+		Expect(document["message"]).To(MatchRegexp(`^could not reach ip-[0-9a-f]{16} from deployment guid-[0-9a-f]{16}$`))
+		Expect(document["vm_cid"]).To(tokenOf(GUIDKind))
+		Expect(document["subnet"]).To(MatchRegexp(`^ip-[0-9a-f]{16}/24$`))
+		Expect(document["ipv6"]).To(tokenOf(IPKind))
+		Expect(document["version"]).To(Equal("2.13.4"))
+	})
+
+	It("replaces domains found by field and bare hostnames in text", func() {
+		document := pseudonymize(`{
+			"properties": {
+				".cloud_controller.system_domain": {"type": "domain", "value": "example.com"},
+				".cloud_controller.apps_domain": {"type": "domain", "value": "apps.example.com"},
+				".properties.smtp.auth.mechanism": {"type": "dropdown_select", "value": "plain"}
+			},
+			"shared_domains": ["example.com", "apps.example.org"],
+			"message": "resolving sys.example.com failed",
+			"stemcell": "light-bosh-stemcell-621.x.tgz",
+			"file": "cf-2.13.4.pivotal",
+			"scope": "uaa.admin"
+		}`)
+
+		properties := document["properties"].(map[string]interface{})
+		systemDomain := properties[".cloud_controller.system_domain"].(map[string]interface{})
+		Expect(systemDomain["value"]).To(tokenOf(HostKind))
+		Expect(systemDomain["type"]).To(Equal("domain"))
+		Expect(properties[".cloud_controller.apps_domain"].(map[string]interface{})["value"]).To(tokenOf(HostKind))
+		Expect(properties).To(HaveKey(".properties.smtp.auth.mechanism"))
+		Expect(document["shared_domains"]).To(ConsistOf(tokenOf(HostKind), tokenOf(HostKind)))
+		Expect(document["message"]).To(MatchRegexp(`^resolving host-[0-9a-f]{16} failed$`))
+		Expect(document["stemcell"]).To(Equal("light-bosh-stemcell-621.x.tgz"))
+		Expect(document["file"]).To(Equal("cf-2.13.4.pivotal"))
+		Expect(document["scope"]).To(Equal("uaa.admin"))
+	})
+
+	It("gives an identifier the same token whatever its case or where it is found", func() {
+		document := pseudonymize(`{
+			"hostname": "OpsMan.Example.com",
+			"url": "https://opsman.example.com/api",
+			"message": "OPSMAN.EXAMPLE.COM is down",
+			"product_guid": "4B2F2C1E-8E4A-4A0B-9F3E-1C2D3E4F5A6B",
+			"vm_cid": "vm-4b2f2c1e-8e4a-4a0b-9f3e-1c2d3e4f5a6b",
+			"address": "FD00::1",
+			"note": "fd00:0:0::1"
+		}`)
+
+		Expect(document["url"]).To(Equal("https://" + document["hostname"].(string) + "/api"))
+		Expect(document["message"]).To(Equal(document["hostname"].(string) + " is down"))
+		Expect(document["vm_cid"]).To(Equal("vm-" + document["product_guid"].(string)))
+		Expect(document["address"]).To(tokenOf(IPKind))
+	})
+
+	It("maps the same identifier to the same token in every file and with every pseudonymizer using the salt", func() {
+		first := pseudonymize(`{"guid": "cf-abc123", "host": "10.0.0.5"}`)
+		second := pseudonymize(`{"items": [{"product_guid": "cf-abc123"}], "message": "at 10.0.0.5"}`)
+		Expect(second["items"].([]interface{})[0].(map[string]interface{})["product_guid"]).To(Equal(first["guid"]))
+		Expect(second["message"]).To(Equal("at " + first["host"].(string)))
+
+		again, err := New(salt, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(again.FoundationID("cf-abc123")).To(Equal(first["guid"]))
+
+		otherSalt, err := New("another-salt-of-sixteen-characters", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(otherSalt.FoundationID("cf-abc123")).NotTo(Equal(first["guid"]))
+	})
+
+	It("replaces identifiers in object keys", func() {
+		document := pseudonymize(`{"by_address": {"10.0.0.5": 3}}`)
+		Expect(document["by_address"]).To(HaveKey(MatchRegexp(`^ip-[0-9a-f]{16}$`)))
+	})
+
+	It("keeps numbers, HTML characters and other values intact", func() {
+		result, err := pseudonymizer.Pseudonymize([]byte(`{"count": 10000000, "ratio": 0.25, "banner": "<b>&</b>", "enabled": true, "missing": null}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(MatchJSON(`{"count": 10000000, "ratio": 0.25, "banner": "<b>&</b>", "enabled": true, "missing": null}`))
+		Expect(string(result)).To(ContainSubstring("10000000"))
+	})
+
+	It("replaces identifiers in content which is not JSON", func() {
+		result, err := pseudonymizer.Pseudonymize([]byte("foundation,address\ncf-0123456789abcdef0123,192.168.1.1\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(result)).To(MatchRegexp(`^foundation,address\nguid-[0-9a-f]{16},ip-[0-9a-f]{16}\n$`))
+	})
+
+	Describe("the foundation id", func() {
+		It("is replaced by the token of the GUID", func() {
+			document := pseudonymize(`{"guid": "p-bosh-0123456789abcdef0123"}`)
+			Expect(pseudonymizer.FoundationID("p-bosh-0123456789abcdef0123")).To(Equal(document["guid"]))
+		})
+
+		It("is kept when asked", func() {
+			keeping, err := New(salt, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keeping.FoundationID("p-bosh-0123456789abcdef0123")).To(Equal("p-bosh-0123456789abcdef0123"))
+		})
+	})
+
+	Describe("the lookup table", func() {
+		It("lists the identifier behind every token, readable only by the user", func() {
+			pseudonymize(`{"guid": "cf-abc123", "host": "opsman.example.com"}`)
+
+			table := pseudonymizer.LookupTable()
+			Expect(table).To(HaveLen(2))
+			Expect(table).To(ContainElement(And(
+				HaveField("Kind", GUIDKind),
+				HaveField("Identifier", "cf-abc123"),
+				HaveField("Token", MatchRegexp(`^guid-`)),
+			)))
+
+			tempDir, err := os.MkdirTemp("", "")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(tempDir)
+
+			tablePath := filepath.Join(tempDir, "table.json")
+			Expect(pseudonymizer.WriteLookupTable(tablePath)).To(Succeed())
+			info, err := os.Stat(tablePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+			contents, err := os.ReadFile(tablePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(regexp.MustCompile(`"identifier": "opsman.example.com"`).Match(contents)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,1174 @@
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// builtin is a function callable from a query. Its arguments are passed
+// unevaluated, so functions such as map and select can run them on each
+// value.
+type builtin func(input interface{}, args []node, e *env) ([]interface{}, error)
+
+var (
+	builtins = map[string]builtin{}
+	formats  = map[string]func(interface{}) (interface{}, error){}
+)
+
+func builtinKey(name string, arity int) string {
+	return fmt.Sprintf("%s/%d", name, arity)
+}
+
+// value registers a function of the input alone
+func value(name string, f func(interface{}) (interface{}, error)) {
+	builtins[builtinKey(name, 0)] = func(input interface{}, _ []node, _ *env) ([]interface{}, error) {
+		v, err := f(input)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{v}, nil
+	}
+}
+
+// withArgs registers a function of the input and the values of its
+// arguments, called for every combination of the argument outputs
+func withArgs(name string, arity int, f func(input interface{}, args []interface{}) (interface{}, error)) {
+	builtins[builtinKey(name, arity)] = func(input interface{}, args []node, e *env) ([]interface{}, error) {
+		combinations := [][]interface{}{{}}
+		for _, arg := range args {
+			values, err := arg.eval(input, e)
+			if err != nil {
+				return nil, err
+			}
+			var next [][]interface{}
+			for _, combination := range combinations {
+				for _, v := range values {
+					next = append(next, append(append([]interface{}{}, combination...), v))
+				}
+			}
+			combinations = next
+		}
+
+		var result []interface{}
+		for _, combination := range combinations {
+			v, err := f(input, combination)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, v)
+		}
+		return result, nil
+	}
+}
+
+func init() {
+	builtins[builtinKey("empty", 0)] = func(interface{}, []node, *env) ([]interface{}, error) {
+		return nil, nil
+	}
+	builtins[builtinKey("error", 0)] = func(input interface{}, _ []node, _ *env) ([]interface{}, error) {
+		return nil, &queryError{value: input}
+	}
+	withArgs("error", 1, func(_ interface{}, args []interface{}) (interface{}, error) {
+		return nil, &queryError{value: args[0]}
+	})
+
+	value("not", func(v interface{}) (interface{}, error) { return !truthy(v), nil })
+	value("type", func(v interface{}) (interface{}, error) { return typeName(v), nil })
+	value("length", length)
+	value("utf8bytelength", func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, raise("%s only strings have UTF-8 byte length", describeValue(v))
+		}
+		return float64(len(s)), nil
+	})
+	value("keys", keys)
+	// objects do not keep the order of their keys, so they are always sorted
+	value("keys_unsorted", keys)
+	value("add", func(v interface{}) (interface{}, error) {
+		values, err := iterate(v)
+		if v == nil {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var sum interface{}
+		for _, value := range values {
+			if sum, err = add(sum, value); err != nil {
+				return nil, err
+			}
+		}
+		return sum, nil
+	})
+	value("any", func(v interface{}) (interface{}, error) { return anyAll(v, true) })
+	value("all", func(v interface{}) (interface{}, error) { return anyAll(v, false) })
+	value("flatten", func(v interface{}) (interface{}, error) { return flatten(v, math.Inf(1)) })
+	withArgs("flatten", 1, func(input interface{}, args []interface{}) (interface{}, error) {
+		depth, ok := args[0].(float64)
+		if !ok || depth < 0 {
+			return nil, raise("flatten depth must not be negative")
+		}
+		return flatten(input, depth)
+	})
+	for name, f := range map[string]func(float64) float64{"floor": math.Floor, "ceil": math.Ceil, "round": math.Round, "sqrt": math.Sqrt, "fabs": math.Abs, "abs": math.Abs} {
+		f := f
+		name := name
+		value(name, func(v interface{}) (interface{}, error) {
+			n, ok := v.(float64)
+			if !ok {
+				return nil, raise("%s number required", describeValue(v))
+			}
+			return f(n), nil
+		})
+	}
+	value("tostring", func(v interface{}) (interface{}, error) { return toText(v), nil })
+	value("tonumber", func(v interface{}) (interface{}, error) {
+		switch value := v.(type) {
+		case float64:
+			return value, nil
+		case string:
+			n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				return nil, raise("Cannot parse %q as a number", value)
+			}
+			return n, nil
+		}
+		return nil, raise("%s cannot be parsed as a number", describeValue(v))
+	})
+	value("tojson", func(v interface{}) (interface{}, error) { return toJSON(v), nil })
+	value("fromjson", func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, raise("%s cannot be parsed as JSON", describeValue(v))
+		}
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(s), &parsed); err != nil {
+			return nil, raise("%s (while parsing '%s')", err, s)
+		}
+		return parsed, nil
+	})
+	value("ascii_downcase", stringFunction(strings.ToLower))
+	value("ascii_upcase", stringFunction(strings.ToUpper))
+	value("trim", stringFunction(strings.TrimSpace))
+	value("ltrim", stringFunction(func(s string) string { return strings.TrimLeft(s, " \t\n\r") }))
+	value("rtrim", stringFunction(func(s string) string { return strings.TrimRight(s, " \t\n\r") }))
+	value("explode", func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, raise("%s cannot be exploded", describeValue(v))
+		}
+		var codepoints []interface{}
+		for _, r := range s {
+			codepoints = append(codepoints, float64(r))
+		}
+		return append([]interface{}{}, codepoints...), nil
+	})
+	value("implode", func(v interface{}) (interface{}, error) {
+		values, ok := v.([]interface{})
+		if !ok {
+			return nil, raise("%s cannot be imploded", describeValue(v))
+		}
+		var builder strings.Builder
+		for _, value := range values {
+			n, ok := value.(float64)
+			if !ok {
+				return nil, raise("Unicode codepoints must be numbers")
+			}
+			builder.WriteRune(rune(n))
+		}
+		return builder.String(), nil
+	})
+	value("sort", func(v interface{}) (interface{}, error) {
+		return sortBy(v, nil, nil)
+	})
+	value("unique", func(v interface{}) (interface{}, error) {
+		return uniqueBy(v, nil, nil)
+	})
+	value("min", func(v interface{}) (interface{}, error) { return extremeBy(v, nil, nil, -1) })
+	value("max", func(v interface{}) (interface{}, error) { return extremeBy(v, nil, nil, 1) })
+	value("reverse", func(v interface{}) (interface{}, error) {
+		switch value := v.(type) {
+		case nil:
+			return []interface{}{}, nil
+		case string:
+			runes := []rune(value)
+			for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+				runes[i], runes[j] = runes[j], runes[i]
+			}
+			return string(runes), nil
+		case []interface{}:
+			reversed := make([]interface{}, len(value))
+			for i, item := range value {
+				reversed[len(value)-1-i] = item
+			}
+			return reversed, nil
+		}
+		return nil, raise("Cannot reverse %s", describeValue(v))
+	})
+	value("to_entries", toEntries)
+	value("from_entries", fromEntries)
+	value("now", func(interface{}) (interface{}, error) { return float64(time.Now().UnixNano()) / 1e9, nil })
+	value("fromdateiso8601", fromDate)
+	value("fromdate", fromDate)
+	value("todateiso8601", toDate)
+	value("todate", toDate)
+	builtins[builtinKey("paths", 0)] = func(input interface{}, _ []node, _ *env) ([]interface{}, error) {
+		return paths(input, nil, nil)
+	}
+	builtins[builtinKey("paths", 1)] = func(input interface{}, args []node, e *env) ([]interface{}, error) {
+		return paths(input, args[0], e)
+	}
+	builtins[builtinKey("leaf_paths", 0)] = func(input interface{}, _ []node, e *env) ([]interface{}, error) {
+		return paths(input, callNode{name: builtinKey("scalars", 0)}, e)
+	}
+	for name, types := range map[string][]string{
+		"nulls": {"null"}, "booleans": {"boolean"}, "numbers": {"number"}, "strings": {"string"},
+		"arrays": {"array"}, "objects": {"object"}, "iterables": {"array", "object"},
+		"scalars": {"null", "boolean", "number", "string"}, "values": {"boolean", "number", "string", "array", "object"},
+	} {
+		types := types
+		builtins[builtinKey(name, 0)] = func(input interface{}, _ []node, _ *env) ([]interface{}, error) {
+			for _, t := range types {
+				if typeName(input) == t {
+					return []interface{}{input}, nil
+				}
+			}
+			return nil, nil
+		}
+	}
+	builtins[builtinKey("recurse", 0)] = func(input interface{}, _ []node, e *env) ([]interface{}, error) {
+		return recurseNode{}.eval(input, e)
+	}
+	builtins[builtinKey("recurse", 1)] = func(input interface{}, args []node, e *env) ([]interface{}, error) {
+		var result []interface{}
+		var walk func(v interface{}) error
+		walk = func(v interface{}) error {
+			result = append(result, v)
+			children, err := args[0].eval(v, e)
+			if err != nil {
+				return err
+			}
+			for _, child := range children {
+				if err := walk(child); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return result, walk(input)
+	}
+
+	withArgs("has", 1, func(input interface{}, args []interface{}) (interface{}, error) { return has(input, args[0]) })
+	withArgs("in", 1, func(input interface{}, args []interface{}) (interface{}, error) { return has(args[0], input) })
+	withArgs("contains", 1, func(input interface{}, args []interface{}) (interface{}, error) {
+		if typeName(input) != typeName(args[0]) {
+			return nil, raise("%s and %s cannot have their containment checked", describeValue(input), describeValue(args[0]))
+		}
+		return contains(input, args[0]), nil
+	})
+	withArgs("inside", 1, func(input interface{}, args []interface{}) (interface{}, error) {
+		if typeName(input) != typeName(args[0]) {
+			return nil, raise("%s and %s cannot have their containment checked", describeValue(args[0]), describeValue(input))
+		}
+		return contains(args[0], input), nil
+	})
+	withArgs("getpath", 1, func(input interface{}, args []interface{}) (interface{}, error) {
+		path, ok := args[0].([]interface{})
+		if !ok {
+			return nil, raise("Path must be specified as an array")
+		}
+		current := input
+		for _, key := range path {
+			var err error
+			if current, err = index(current, key); err != nil {
+				return nil, err
+			}
+		}
+		return current, nil
+	})
+	withArgs("startswith", 1, stringPredicate("startswith", strings.HasPrefix))
+	withArgs("endswith", 1, stringPredicate("endswith", strings.HasSuffix))
+	withArgs("ltrimstr", 1, trimFunction(strings.TrimPrefix))
+	withArgs("rtrimstr", 1, trimFunction(strings.TrimSuffix))
+	withArgs("split", 1, func(input interface{}, args []interface{}) (interface{}, error) {
+		s, ok := input.(string)
+		separator, sok := args[0].(string)
+		if !ok || !sok {
+			return nil, raise("split input and separator must be strings")
+		}
+		return split(s, separator), nil
+	})
+	withArgs("join", 1, func(input interface{}, args []interface{}) (interface{}, error) {
+		values, err := iterate(input)
+		if err != nil {
+			return nil, err
+		}
+		separator, ok := args[0].(string)
+		if !ok {
+			return nil, raise("join separator must be a string")
+		}
+		parts := make([]string, len(values))
+		for i, v := range values {
+			switch value := v.(type) {
+			case nil:
+			case string:
+				parts[i] = value
+			case float64, bool:
+				parts[i] = toJSON(value)
+			default:
+				return nil, raise("Cannot join with %s", describeValue(v))
+			}
+		}
+		return strings.Join(parts, separator), nil
+	})
+	withArgs("test", 1, func(input interface{}, args []interface{}) (interface{}, error) { return test(input, args[0], nil) })
+	withArgs("test", 2, func(input interface{}, args []interface{}) (interface{}, error) { return test(input, args[0], args[1]) })
+	builtins[builtinKey("capture", 1)] = regexBuiltin(capture)
+	builtins[builtinKey("capture", 2)] = regexBuiltin(capture)
+	builtins[builtinKey("scan", 1)] = regexBuiltin(scan)
+	builtins[builtinKey("scan", 2)] = regexBuiltin(scan)
+	builtins[builtinKey("sub", 2)] = substitute(false)
+	builtins[builtinKey("sub", 3)] = substitute(false)
+	builtins[builtinKey("gsub", 2)] = substitute(true)
+	builtins[builtinKey("gsub", 3)] = substitute(true)
+
+	builtins[builtinKey("select", 1)] = func(input interface{}, args []node, e *env) ([]interface{}, error) {
+		conds, err := args[0].eval(input, e)
+		if err != nil {
+			return nil, err
+		}
+		var result []interface{}
+		for _, cond := range conds {
+			if truthy(cond) {
+				result = append(result, input)
+			}
+		}
+		return result, nil
+	}
+	builtins[builtinKey("map", 1)] = func(input interface{}, args []node, e *env) ([]interface{}, error) {
+		return arrayNode{body: pipeNode{left: iterateNode{source: identityNode{}}, right: args[0]}}.eval(input, e)
+	}
+	builtins[builtinKey("map_values", 1)] = func(input interface{}, args []node, e *env) ([]interface{}, error) {
+		mapped, err := mapValues(input, func(v interface{}) ([]interface{}, error) { return args[0].eval(v, e) })
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{mapped}, nil
+	}
+	builtins[builtinKey("walk", 1)] = func(input interface{}, args []node, e *env) ([]interface{}, error) {
+		var walk func(v interface{}) ([]interface{}, error)
+		walk = func(v interface{}) ([]interface{}, error) {
+			switch v.(type) {
+			case []interface{}, map[string]interface{}:
+				mapped, err := mapValues(v, walk)
+				if err != nil {
+					return nil, err
+				}
+				v = mapped
+			}
+			return args[0].eval(v, e)
+		}
+		return walk(input)
+	}
+	builtins[builtinKey("with_entries", 1)] = func(input interface{}, args []node, e *env) ([]interface{}, error) {
+		entries, err := toEntries(input)
+		if err != nil {
+			return nil, err
+		}
+		mapped, err := builtins[builtinKey("map", 1)](entries, args, e)
+		if err != nil {
+			return nil, err
+		}
+		result := make([]interface{}, len(mapped))
+		for i, m := range mapped {
+			if result[i], err = fromEntries(m); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+	for name, direction := range map[string]int{"min_by": -1, "max_by": 1} {
+		direction := direction
+		builtins[builtinKey(name, 1)] = func(input interface{}, args []node, e *env) ([]interface{}, error) {
+			v, err := extremeBy(input, args[0], e, direction)
+			return []interface{}{v}, err
+		}
+	}
+	builtins[builtinKey("sort_by", 1)] = func(input interface{}, args []node, e *env) ([]interface{}, error) {
+		v, err := sortBy(input, args[0], e)
+		return []interface{}{v}, err
+	}
+	builtins[builtinKey("unique_by", 1)] = func(input interface{}, args []node, e *env) ([]interface{}, error) {
+		v, err := uniqueBy(input, args[0], e)
+		return []interface{}{v}, err
+	}
+	builtins[builtinKey("group_by", 1)] = func(input interface{}, args []node, e *env) ([]interface{}, error) {
+		v, err := groupBy(input, args[0], e)
+		return []interface{}{v}, err
+	}
+	builtins[builtinKey("any", 1)] = func(input interface{}, args []node, e *env) ([]interface{}, error) {
+		return anyAllOf(iterateNode{source: identityNode{}}, args[0], input, e, true)
+	}
+	builtins[builtinKey("all", 1)] = func(input interface{}, args []node, e *env) ([]interface{}, error) {
+		return anyAllOf(iterateNode{source: identityNode{}}, args[0], input, e, false)
+	}
+	builtins[builtinKey("any", 2)] = func(input interface{}, args []node, e *env) ([]interface{}, error) {
+		return anyAllOf(args[0], args[1], input, e, true)
+	}
+	builtins[builtinKey("all", 2)] = func(input interface{}, args []node, e *env) ([]interface{}, error) {
+		return anyAllOf(args[0], args[1], input, e, false)
+	}
+	builtins[builtinKey("first", 0)] = func(input interface{}, _ []node, _ *env) ([]interface{}, error) {
+		v, err := index(input, 0.0)
+		return []interface{}{v}, err
+	}
+	builtins[builtinKey("last", 0)] = func(input interface{}, _ []node, _ *env) ([]interface{}, error) {
+		v, err := index(input, -1.0)
+		return []interface{}{v}, err
+	}
+	builtins[builtinKey("first", 1)] = func(input interface{}, args []node, e *env) ([]interface{}, error) {
+		values, err := args[0].eval(input, e)
+		if err != nil || len(values) == 0 {
+			return nil, err
+		}
+		return values[:1], nil
+	}
+	builtins[builtinKey("last", 1)] = func(input interface{}, args []node, e *env) ([]interface{}, error) {
+		values, err := args[0].eval(input, e)
+		if err != nil || len(values) == 0 {
+			return nil, err
+		}
+		return values[len(values)-1:], nil
+	}
+	builtins[builtinKey("isempty", 1)] = func(input interface{}, args []node, e *env) ([]interface{}, error) {
+		values, err := args[0].eval(input, e)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{len(values) == 0}, nil
+	}
+	builtins[builtinKey("limit", 2)] = func(input interface{}, args []node, e *env) ([]interface{}, error) {
+		limits, err := args[0].eval(input, e)
+		if err != nil {
+			return nil, err
+		}
+		var result []interface{}
+		for _, limit := range limits {
+			n, ok := limit.(float64)
+			if !ok {
+				return nil, raise("Invalid limit %s", describeValue(limit))
+			}
+			if n <= 0 {
+				continue
+			}
+			values, err := args[1].eval(input, e)
+			if err != nil {
+				return nil, err
+			}
+			if int(n) < len(values) {
+				values = values[:int(n)]
+			}
+			result = append(result, values...)
+		}
+		return result, nil
+	}
+	withArgs("range", 1, func(_ interface{}, args []interface{}) (interface{}, error) { return rangeOf(0.0, args[0], 1.0) })
+	withArgs("range", 2, func(_ interface{}, args []interface{}) (interface{}, error) { return rangeOf(args[0], args[1], 1.0) })
+	withArgs("range", 3, func(_ interface{}, args []interface{}) (interface{}, error) {
+		return rangeOf(args[0], args[1], args[2])
+	})
+	for _, arity := range []int{1, 2, 3} {
+		// range emits its numbers rather than an array of them
+		ranged := builtins[builtinKey("range", arity)]
+		builtins[builtinKey("range", arity)] = func(input interface{}, args []node, e *env) ([]interface{}, error) {
+			ranges, err := ranged(input, args, e)
+			if err != nil {
+				return nil, err
+			}
+			var result []interface{}
+			for _, r := range ranges {
+				result = append(result, r.([]interface{})...)
+			}
+			return result, nil
+		}
+	}
+
+	formats["text"] = func(v interface{}) (interface{}, error) { return toText(v), nil }
+	formats["json"] = func(v interface{}) (interface{}, error) { return toJSON(v), nil }
+	formats["csv"] = func(v interface{}) (interface{}, error) { return delimited(v, "csv") }
+	formats["tsv"] = func(v interface{}) (interface{}, error) { return delimited(v, "tsv") }
+	formats["html"] = func(v interface{}) (interface{}, error) {
+		return strings.NewReplacer("<", "&lt;", ">", "&gt;", "&", "&amp;", "'", "&#39;", `"`, "&quot;").Replace(toText(v)), nil
+	}
+	formats["uri"] = func(v interface{}) (interface{}, error) {
+		return strings.ReplaceAll(url.QueryEscape(toText(v)), "+", "%20"), nil
+	}
+	formats["sh"] = func(v interface{}) (interface{}, error) {
+		quote := func(item interface{}) (string, error) {
+			switch value := item.(type) {
+			case string:
+				return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'", nil
+			case []interface{}, map[string]interface{}:
+				return "", raise("%s can not be escaped for shell", describeValue(item))
+			}
+			return toJSON(item), nil
+		}
+		values, ok := v.([]interface{})
+		if !ok {
+			return quote(v)
+		}
+		parts := make([]string, len(values))
+		for i, item := range values {
+			var err error
+			if parts[i], err = quote(item); err != nil {
+				return nil, err
+			}
+		}
+		return strings.Join(parts, " "), nil
+	}
+	formats["base64"] = func(v interface{}) (interface{}, error) {
+		return base64.StdEncoding.EncodeToString([]byte(toText(v))), nil
+	}
+	formats["base64d"] = func(v interface{}) (interface{}, error) {
+		text := toText(v)
+		decoded, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			if decoded, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(text, "=")); err != nil {
+				return nil, raise("%s is not valid base64 data", describeValue(v))
+			}
+		}
+		return string(decoded), nil
+	}
+}
+
+func length(v interface{}) (interface{}, error) {
+	switch value := v.(type) {
+	case nil:
+		return 0.0, nil
+	case float64:
+		return math.Abs(value), nil
+	case string:
+		return float64(utf8.RuneCountInString(value)), nil
+	case []interface{}:
+		return float64(len(value)), nil
+	case map[string]interface{}:
+		return float64(len(value)), nil
+	}
+	return nil, raise("%s has no length", describeValue(v))
+}
+
+func keys(v interface{}) (interface{}, error) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		return stringsToValues(sortedKeys(value)), nil
+	case []interface{}:
+		indices := make([]interface{}, len(value))
+		for i := range value {
+			indices[i] = float64(i)
+		}
+		return indices, nil
+	}
+	return nil, raise("%s has no keys", describeValue(v))
+}
+
+func has(v, key interface{}) (interface{}, error) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if k, ok := key.(string); ok {
+			_, found := value[k]
+			return found, nil
+		}
+	case []interface{}:
+		if k, ok := key.(float64); ok {
+			return k >= 0 && int(k) < len(value), nil
+		}
+	}
+	return nil, raise("Cannot check whether %s has a %s key", typeName(v), typeName(key))
+}
+
+func contains(a, b interface{}) bool {
+	switch av := a.(type) {
+	case string:
+		bv, ok := b.(string)
+		return ok && strings.Contains(av, bv)
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, bItem := range bv {
+			found := false
+			for _, aItem := range av {
+				if typeName(aItem) == typeName(bItem) && contains(aItem, bItem) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, bItem := range bv {
+			aItem, found := av[key]
+			if !found || typeName(aItem) != typeName(bItem) || !contains(aItem, bItem) {
+				return false
+			}
+		}
+		return true
+	}
+	return compare(a, b) == 0
+}
+
+func anyAll(v interface{}, any bool) (interface{}, error) {
+	values, err := iterate(v)
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values {
+		if truthy(value) == any {
+			return any, nil
+		}
+	}
+	return !any, nil
+}
+
+func anyAllOf(generator, cond node, input interface{}, e *env, any bool) ([]interface{}, error) {
+	values, err := generator.eval(input, e)
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values {
+		results, err := cond.eval(value, e)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range results {
+			if truthy(result) == any {
+				return []interface{}{any}, nil
+			}
+		}
+	}
+	return []interface{}{!any}, nil
+}
+
+func flatten(v interface{}, depth float64) (interface{}, error) {
+	values, ok := v.([]interface{})
+	if !ok {
+		return nil, raise("Cannot flatten %s", describeValue(v))
+	}
+	result := []interface{}{}
+	for _, value := range values {
+		if nested, ok := value.([]interface{}); ok && depth > 0 {
+			flattened, _ := flatten(nested, depth-1)
+			result = append(result, flattened.([]interface{})...)
+			continue
+		}
+		result = append(result, value)
+	}
+	return result, nil
+}
+
+func stringFunction(f func(string) string) func(interface{}) (interface{}, error) {
+	return func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, raise("%s cannot be case converted or trimmed, only strings can", describeValue(v))
+		}
+		return f(s), nil
+	}
+}
+
+func stringPredicate(name string, f func(string, string) bool) func(interface{}, []interface{}) (interface{}, error) {
+	return func(input interface{}, args []interface{}) (interface{}, error) {
+		s, ok := input.(string)
+		arg, aok := args[0].(string)
+		if !ok || !aok {
+			return nil, raise("%s() requires string inputs", name)
+		}
+		return f(s, arg), nil
+	}
+}
+
+func trimFunction(f func(string, string) string) func(interface{}, []interface{}) (interface{}, error) {
+	return func(input interface{}, args []interface{}) (interface{}, error) {
+		s, ok := input.(string)
+		arg, aok := args[0].(string)
+		if !ok || !aok {
+			return input, nil
+		}
+		return f(s, arg), nil
+	}
+}
+
+// keyed pairs the values of an array with the outputs of f for each, the
+// key jq sorts and groups them by
+type keyed struct {
+	key   interface{}
+	value interface{}
+}
+
+func keyedValues(v interface{}, f node, e *env) ([]keyed, error) {
+	values, ok := v.([]interface{})
+	if !ok {
+		return nil, raise("Cannot sort %s, as it is not an array", describeValue(v))
+	}
+	result := make([]keyed, len(values))
+	for i, value := range values {
+		result[i] = keyed{key: value, value: value}
+		if f == nil {
+			continue
+		}
+		keys, err := f.eval(value, e)
+		if err != nil {
+			return nil, err
+		}
+		result[i].key = append([]interface{}{}, keys...)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return compare(result[i].key, result[j].key) < 0 })
+	return result, nil
+}
+
+func sortBy(v interface{}, f node, e *env) (interface{}, error) {
+	sorted, err := keyedValues(v, f, e)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, len(sorted))
+	for i, k := range sorted {
+		result[i] = k.value
+	}
+	return result, nil
+}
+
+func groupBy(v interface{}, f node, e *env) (interface{}, error) {
+	sorted, err := keyedValues(v, f, e)
+	if err != nil {
+		return nil, err
+	}
+	groups := []interface{}{}
+	for i, k := range sorted {
+		if i == 0 || compare(sorted[i-1].key, k.key) != 0 {
+			groups = append(groups, []interface{}{})
+		}
+		last := len(groups) - 1
+		groups[last] = append(groups[last].([]interface{}), k.value)
+	}
+	return groups, nil
+}
+
+func uniqueBy(v interface{}, f node, e *env) (interface{}, error) {
+	sorted, err := keyedValues(v, f, e)
+	if err != nil {
+		return nil, err
+	}
+	result := []interface{}{}
+	for i, k := range sorted {
+		if i == 0 || compare(sorted[i-1].key, k.key) != 0 {
+			result = append(result, k.value)
+		}
+	}
+	return result, nil
+}
+
+// extremeBy is the smallest value for a negative direction and the largest
+// otherwise, or null for an empty array
+func extremeBy(v interface{}, f node, e *env, direction int) (interface{}, error) {
+	sorted, err := keyedValues(v, f, e)
+	if err != nil || len(sorted) == 0 {
+		return nil, err
+	}
+	if direction < 0 {
+		return sorted[0].value, nil
+	}
+	// max takes the last of equal values, as jq does
+	return sorted[len(sorted)-1].value, nil
+}
+
+// toEntries pairs each key of an object, or index of an array, with its
+// value
+func toEntries(v interface{}) (interface{}, error) {
+	keyList, err := keys(v)
+	if err != nil {
+		return nil, err
+	}
+	entries := []interface{}{}
+	for _, key := range keyList.([]interface{}) {
+		value, err := index(v, key)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, map[string]interface{}{"key": key, "value": value})
+	}
+	return entries, nil
+}
+
+func fromEntries(v interface{}) (interface{}, error) {
+	entries, err := iterate(v)
+	if err != nil {
+		return nil, err
+	}
+	object := map[string]interface{}{}
+	for _, entry := range entries {
+		fields, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, raise("Cannot use %s as an entry", describeValue(entry))
+		}
+
+		var key interface{}
+		for _, name := range []string{"key", "k", "name", "Name", "Key", "K"} {
+			if key = fields[name]; truthy(key) {
+				break
+			}
+		}
+		var value interface{}
+		for _, name := range []string{"value", "v", "Value", "V"} {
+			if value = fields[name]; value != nil {
+				break
+			}
+		}
+
+		switch k := key.(type) {
+		case string:
+			object[k] = value
+		case float64, bool:
+			object[toJSON(k)] = value
+		case nil:
+			object["null"] = value
+		default:
+			return nil, raise("Cannot use %s as object key", describeValue(key))
+		}
+	}
+	return object, nil
+}
+
+// mapValues replaces each value of an array with all outputs of f, and each
+// value of an object with the first, dropping keys f outputs nothing for
+func mapValues(v interface{}, f func(interface{}) ([]interface{}, error)) (interface{}, error) {
+	switch value := v.(type) {
+	case []interface{}:
+		result := []interface{}{}
+		for _, item := range value {
+			outputs, err := f(item)
+			if err != nil {
+				return nil, err
+			}
+			if len(outputs) > 0 {
+				result = append(result, outputs[0])
+			}
+		}
+		return result, nil
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for _, key := range sortedKeys(value) {
+			outputs, err := f(value[key])
+			if err != nil {
+				return nil, err
+			}
+			if len(outputs) > 0 {
+				result[key] = outputs[0]
+			}
+		}
+		return result, nil
+	}
+	return nil, raise("Cannot iterate over %s", describeValue(v))
+}
+
+func paths(v interface{}, filter node, e *env) ([]interface{}, error) {
+	var result []interface{}
+	var walk func(v interface{}, path []interface{}) error
+	walk = func(v interface{}, path []interface{}) error {
+		if len(path) > 0 {
+			include := filter == nil
+			if filter != nil {
+				conds, err := filter.eval(v, e)
+				if err != nil {
+					return err
+				}
+				for _, cond := range conds {
+					include = include || truthy(cond)
+				}
+			}
+			if include {
+				result = append(result, append([]interface{}{}, path...))
+			}
+		}
+		switch value := v.(type) {
+		case []interface{}:
+			for i, child := range value {
+				if err := walk(child, append(path, float64(i))); err != nil {
+					return err
+				}
+			}
+		case map[string]interface{}:
+			for _, key := range sortedKeys(value) {
+				if err := walk(value[key], append(path, key)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return result, walk(v, nil)
+}
+
+func rangeOf(from, upto, by interface{}) (interface{}, error) {
+	start, sok := from.(float64)
+	end, eok := upto.(float64)
+	step, bok := by.(float64)
+	if !sok || !eok || !bok {
+		return nil, raise("Range bounds must be numeric")
+	}
+	result := []interface{}{}
+	if step == 0 {
+		return result, nil
+	}
+	for n := start; (step > 0 && n < end) || (step < 0 && n > end); n += step {
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+func fromDate(v interface{}) (interface{}, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, raise("fromdate requires a string input, found %s", describeValue(v))
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, raise("date %q does not match format \"%%Y-%%m-%%dT%%H:%%M:%%SZ\"", s)
+	}
+	return float64(t.Unix()), nil
+}
+
+func toDate(v interface{}) (interface{}, error) {
+	n, ok := v.(float64)
+	if !ok {
+		return nil, raise("todate requires a number input, found %s", describeValue(v))
+	}
+	return time.Unix(int64(n), 0).UTC().Format("2006-01-02T15:04:05Z"), nil
+}
+
+func compileRegex(re, flags interface{}) (*regexp.Regexp, bool, error) {
+	pattern, ok := re.(string)
+	if !ok {
+		return nil, false, raise("%s cannot be matched, as it is not a string", describeValue(re))
+	}
+	global := false
+	if flags != nil {
+		f, ok := flags.(string)
+		if !ok {
+			return nil, false, raise("%s is not a string", describeValue(flags))
+		}
+		for _, flag := range f {
+			switch flag {
+			case 'g':
+				global = true
+			case 'i', 's':
+				pattern = "(?" + string(flag) + ")" + pattern
+			case 'x', 'n', 'p', 'l':
+				return nil, false, raise("regular expression flag %q is not supported", string(flag))
+			default:
+				return nil, false, raise("%s is not a valid modifier string", f)
+			}
+		}
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, false, raise("%s (at offset 0) is not a valid regex: %s", pattern, err)
+	}
+	return compiled, global, nil
+}
+
+func test(input, re, flags interface{}) (interface{}, error) {
+	s, ok := input.(string)
+	if !ok {
+		return nil, raise("%s cannot be matched, as it is not a string", describeValue(input))
+	}
+	compiled, _, err := compileRegex(re, flags)
+	if err != nil {
+		return nil, err
+	}
+	return compiled.MatchString(s), nil
+}
+
+// regexBuiltin runs f on each string and regular expression, with optional
+// flags as the second argument
+func regexBuiltin(f func(s string, re *regexp.Regexp, global bool) []interface{}) builtin {
+	return func(input interface{}, args []node, e *env) ([]interface{}, error) {
+		s, ok := input.(string)
+		if !ok {
+			return nil, raise("%s cannot be matched, as it is not a string", describeValue(input))
+		}
+		res, err := args[0].eval(input, e)
+		if err != nil {
+			return nil, err
+		}
+		flags := []interface{}{nil}
+		if len(args) > 1 {
+			if flags, err = args[1].eval(input, e); err != nil {
+				return nil, err
+			}
+		}
+
+		var result []interface{}
+		for _, re := range res {
+			for _, flag := range flags {
+				compiled, global, err := compileRegex(re, flag)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, f(s, compiled, global)...)
+			}
+		}
+		return result, nil
+	}
+}
+
+func matches(s string, re *regexp.Regexp, global bool) [][]int {
+	if global {
+		return re.FindAllStringSubmatchIndex(s, -1)
+	}
+	if m := re.FindStringSubmatchIndex(s); m != nil {
+		return [][]int{m}
+	}
+	return nil
+}
+
+func captureObject(s string, re *regexp.Regexp, m []int) map[string]interface{} {
+	object := map[string]interface{}{}
+	for i, name := range re.SubexpNames() {
+		if i == 0 || name == "" {
+			continue
+		}
+		if m[2*i] < 0 {
+			object[name] = nil
+		} else {
+			object[name] = s[m[2*i]:m[2*i+1]]
+		}
+	}
+	return object
+}
+
+func capture(s string, re *regexp.Regexp, global bool) []interface{} {
+	var result []interface{}
+	for _, m := range matches(s, re, global) {
+		result = append(result, captureObject(s, re, m))
+	}
+	return result
+}
+
+func scan(s string, re *regexp.Regexp, _ bool) []interface{} {
+	var result []interface{}
+	for _, m := range matches(s, re, true) {
+		if re.NumSubexp() == 0 {
+			result = append(result, s[m[0]:m[1]])
+			continue
+		}
+		groups := []interface{}{}
+		for i := 1; i <= re.NumSubexp(); i++ {
+			if m[2*i] < 0 {
+				groups = append(groups, nil)
+			} else {
+				groups = append(groups, s[m[2*i]:m[2*i+1]])
+			}
+		}
+		result = append(result, groups)
+	}
+	return result
+}
+
+// substitute replaces matches with the replacement, which is run with the
+// named captures of each match as its input
+func substitute(global bool) builtin {
+	return func(input interface{}, args []node, e *env) ([]interface{}, error) {
+		s, ok := input.(string)
+		if !ok {
+			return nil, raise("%s cannot be matched, as it is not a string", describeValue(input))
+		}
+		res, err := args[0].eval(input, e)
+		if err != nil {
+			return nil, err
+		}
+		flags := []interface{}{nil}
+		if len(args) > 2 {
+			if flags, err = args[2].eval(input, e); err != nil {
+				return nil, err
+			}
+		}
+
+		var result []interface{}
+		for _, re := range res {
+			for _, flag := range flags {
+				compiled, g, err := compileRegex(re, flag)
+				if err != nil {
+					return nil, err
+				}
+				var replaced strings.Builder
+				last := 0
+				for _, m := range matches(s, compiled, global || g) {
+					replacements, err := args[1].eval(captureObject(s, compiled, m), e)
+					if err != nil {
+						return nil, err
+					}
+					if len(replacements) != 1 {
+						return nil, raise("the replacement of sub and gsub must have exactly one output")
+					}
+					replacement, ok := replacements[0].(string)
+					if !ok {
+						return nil, raise("%s cannot be added to a string", describeValue(replacements[0]))
+					}
+					replaced.WriteString(s[last:m[0]])
+					replaced.WriteString(replacement)
+					last = m[1]
+				}
+				replaced.WriteString(s[last:])
+				result = append(result, replaced.String())
+			}
+		}
+		return result, nil
+	}
+}
+
+// delimited formats an array as a CSV or TSV row
+func delimited(v interface{}, format string) (interface{}, error) {
+	values, ok := v.([]interface{})
+	if !ok {
+		return nil, raise("%s cannot be %s-formatted, only an array can be", describeValue(v), format)
+	}
+	parts := make([]string, len(values))
+	for i, item := range values {
+		switch value := item.(type) {
+		case nil:
+		case bool, float64:
+			parts[i] = toJSON(value)
+		case string:
+			if format == "csv" {
+				parts[i] = `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+			} else {
+				parts[i] = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`).Replace(value)
+			}
+		default:
+			return nil, raise("%s is not valid in a %s row", describeValue(item), format)
+		}
+	}
+	if format == "csv" {
+		return strings.Join(parts, ","), nil
+	}
+	return strings.Join(parts, "\t"), nil
+}
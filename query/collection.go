@@ -0,0 +1,106 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/pivotal-cf/telemetry-utils/collector_tar"
+	"github.com/pkg/errors"
+)
+
+const (
+	FoundationIDVariable       = "foundation_id"
+	FoundationNicknameVariable = "foundation_nickname"
+
+	InvalidMetadataErrorFormat = "invalid metadata in %s"
+	MissingFileErrorFormat     = "%s is listed in %s but is not in the collection"
+)
+
+// Collection is the data written by one run of collect, as a document
+// queries run against
+type Collection struct {
+	FoundationID       string
+	FoundationNickname string
+
+	// Document holds the contents of each collected file keyed by product
+	// type, or the data set for data without one, and then by data type,
+	// such as cf.properties or bosh_director.deployments
+	Document map[string]interface{}
+}
+
+// NewCollection builds the document from the files in a collection tar,
+// keyed by their path within it. JSON files are parsed and other files
+// are kept as strings.
+func NewCollection(files map[string][]byte) (Collection, error) {
+	var metadataPaths []string
+	for name := range files {
+		if path.Base(name) == collector_tar.MetadataFileName {
+			metadataPaths = append(metadataPaths, name)
+		}
+	}
+	sort.Strings(metadataPaths)
+
+	collection := Collection{Document: map[string]interface{}{}}
+	for _, metadataPath := range metadataPaths {
+		var metadata collector_tar.Metadata
+		if err := json.Unmarshal(files[metadataPath], &metadata); err != nil {
+			return Collection{}, errors.Wrapf(err, InvalidMetadataErrorFormat, metadataPath)
+		}
+		if collection.FoundationID == "" {
+			collection.FoundationID = metadata.FoundationId
+		}
+		if collection.FoundationNickname == "" {
+			collection.FoundationNickname = metadata.FoundationNickname
+		}
+
+		dataSet := path.Dir(metadataPath)
+		for _, digest := range metadata.FileDigests {
+			contents, ok := files[path.Join(dataSet, digest.Name)]
+			if !ok {
+				return Collection{}, errors.New(fmt.Sprintf(MissingFileErrorFormat, digest.Name, metadataPath))
+			}
+
+			productType := digest.ProductType
+			if productType == "" {
+				productType = dataSet
+			}
+			dataType := digest.DataType
+			if dataType == "" {
+				dataType = digest.Name
+			}
+
+			product, ok := collection.Document[productType].(map[string]interface{})
+			if !ok {
+				product = map[string]interface{}{}
+				collection.Document[productType] = product
+			}
+			product[dataType] = parseContents(contents)
+		}
+	}
+	return collection, nil
+}
+
+// Variables are the details of the collection available to queries
+func (c Collection) Variables() map[string]interface{} {
+	return map[string]interface{}{
+		FoundationIDVariable:       optionalString(c.FoundationID),
+		FoundationNicknameVariable: optionalString(c.FoundationNickname),
+	}
+}
+
+func parseContents(contents []byte) interface{} {
+	var parsed interface{}
+	if err := json.Unmarshal(contents, &parsed); err != nil {
+		return string(contents)
+	}
+	return parsed
+}
+
+func optionalString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
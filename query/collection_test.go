@@ -0,0 +1,77 @@
+package query_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotal-cf/aqueduct-courier/query"
+	"github.com/pivotal-cf/telemetry-utils/collector_tar"
+)
+
+var _ = Describe("Collection", func() {
+	metadata := func(nickname string, digests ...collector_tar.FileDigest) []byte {
+		contents, err := json.Marshal(collector_tar.Metadata{
+			FoundationId:       "foundation-guid",
+			FoundationNickname: nickname,
+			FileDigests:        digests,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		return contents
+	}
+
+	It("keys collected files by product type and data type", func() {
+		collection, err := NewCollection(map[string][]byte{
+			"opsmanager/metadata": metadata("prod",
+				collector_tar.FileDigest{Name: "cf_properties", ProductType: "cf", DataType: collector_tar.PropertiesDataType},
+				collector_tar.FileDigest{Name: "p-bosh_certificates", ProductType: collector_tar.DirectorProductType, DataType: collector_tar.CertificatesDataType},
+			),
+			"opsmanager/cf_properties":       []byte(`{"enabled": true, "count": 3}`),
+			"opsmanager/p-bosh_certificates": []byte(`[{"issuer": "CN=root"}]`),
+			"bosh_director/metadata": metadata("prod",
+				collector_tar.FileDigest{Name: "deployments", DataType: "deployments"},
+				collector_tar.FileDigest{Name: "notes"},
+			),
+			"bosh_director/deployments": []byte(`[{"name": "cf-1"}]`),
+			"bosh_director/notes":       []byte("not json"),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(collection.FoundationID).To(Equal("foundation-guid"))
+		Expect(collection.FoundationNickname).To(Equal("prod"))
+		Expect(collection.Document).To(Equal(map[string]interface{}{
+			"cf": map[string]interface{}{
+				"properties": map[string]interface{}{"enabled": true, "count": 3.0},
+			},
+			"p-bosh": map[string]interface{}{
+				"certificates": []interface{}{map[string]interface{}{"issuer": "CN=root"}},
+			},
+			"bosh_director": map[string]interface{}{
+				"deployments": []interface{}{map[string]interface{}{"name": "cf-1"}},
+				"notes":       "not json",
+			},
+		}))
+		Expect(collection.Variables()).To(Equal(map[string]interface{}{
+			FoundationIDVariable:       "foundation-guid",
+			FoundationNicknameVariable: "prod",
+		}))
+	})
+
+	It("leaves a missing nickname null", func() {
+		collection, err := NewCollection(map[string][]byte{"opsmanager/metadata": metadata("")})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(collection.Variables()[FoundationNicknameVariable]).To(BeNil())
+	})
+
+	It("fails when the metadata is invalid", func() {
+		_, err := NewCollection(map[string][]byte{"opsmanager/metadata": []byte("{")})
+		Expect(err).To(MatchError(ContainSubstring("invalid metadata in opsmanager/metadata")))
+	})
+
+	It("fails when a file listed in the metadata is missing", func() {
+		_, err := NewCollection(map[string][]byte{
+			"opsmanager/metadata": metadata("", collector_tar.FileDigest{Name: "cf_properties"}),
+		})
+		Expect(err).To(MatchError("cf_properties is listed in opsmanager/metadata but is not in the collection"))
+	})
+})
@@ -0,0 +1,972 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// node is an expression. Every expression is a generator, turning an input
+// into any number of outputs.
+type node interface {
+	eval(input interface{}, e *env) ([]interface{}, error)
+}
+
+// env holds the bound variables, innermost first
+type env struct {
+	name   string
+	value  interface{}
+	parent *env
+}
+
+func (e *env) bind(name string, value interface{}) *env {
+	return &env{name: name, value: value, parent: e}
+}
+
+func (e *env) lookup(name string) (interface{}, bool) {
+	for ; e != nil; e = e.parent {
+		if e.name == name {
+			return e.value, true
+		}
+	}
+	return nil, false
+}
+
+// queryError is an error raised by the query itself, such as with error(),
+// which try catches with its value
+type queryError struct {
+	value interface{}
+}
+
+func (q *queryError) Error() string {
+	if s, ok := q.value.(string); ok {
+		return s
+	}
+	return toJSON(q.value) + " (not a string)"
+}
+
+func raise(format string, args ...interface{}) error {
+	return &queryError{value: fmt.Sprintf(format, args...)}
+}
+
+type identityNode struct{}
+
+func (identityNode) eval(input interface{}, _ *env) ([]interface{}, error) {
+	return []interface{}{input}, nil
+}
+
+type recurseNode struct{}
+
+func (recurseNode) eval(input interface{}, _ *env) ([]interface{}, error) {
+	var result []interface{}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		result = append(result, v)
+		switch value := v.(type) {
+		case []interface{}:
+			for _, child := range value {
+				walk(child)
+			}
+		case map[string]interface{}:
+			for _, key := range sortedKeys(value) {
+				walk(value[key])
+			}
+		}
+	}
+	walk(input)
+	return result, nil
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n literalNode) eval(_ interface{}, _ *env) ([]interface{}, error) {
+	return []interface{}{n.value}, nil
+}
+
+type variableNode struct {
+	name string
+}
+
+func (n variableNode) eval(_ interface{}, e *env) ([]interface{}, error) {
+	value, ok := e.lookup(n.name)
+	if !ok {
+		return nil, errors.Errorf("$%s is not defined", n.name)
+	}
+	return []interface{}{value}, nil
+}
+
+type pipeNode struct {
+	left, right node
+}
+
+func (n pipeNode) eval(input interface{}, e *env) ([]interface{}, error) {
+	lefts, err := n.left.eval(input, e)
+	if err != nil {
+		return nil, err
+	}
+	var result []interface{}
+	for _, left := range lefts {
+		rights, err := n.right.eval(left, e)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, rights...)
+	}
+	return result, nil
+}
+
+type commaNode struct {
+	left, right node
+}
+
+func (n commaNode) eval(input interface{}, e *env) ([]interface{}, error) {
+	lefts, err := n.left.eval(input, e)
+	if err != nil {
+		return nil, err
+	}
+	rights, err := n.right.eval(input, e)
+	if err != nil {
+		return nil, err
+	}
+	return append(lefts, rights...), nil
+}
+
+type indexNode struct {
+	source, key node
+}
+
+func (n indexNode) eval(input interface{}, e *env) ([]interface{}, error) {
+	sources, err := n.source.eval(input, e)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := n.key.eval(input, e)
+	if err != nil {
+		return nil, err
+	}
+	var result []interface{}
+	for _, source := range sources {
+		for _, key := range keys {
+			value, err := index(source, key)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, value)
+		}
+	}
+	return result, nil
+}
+
+func index(source, key interface{}) (interface{}, error) {
+	switch k := key.(type) {
+	case string:
+		switch s := source.(type) {
+		case nil:
+			return nil, nil
+		case map[string]interface{}:
+			return s[k], nil
+		}
+		return nil, raise("Cannot index %s with %q", typeName(source), k)
+	case float64:
+		switch s := source.(type) {
+		case nil:
+			return nil, nil
+		case []interface{}:
+			i := int(math.Floor(k))
+			if i < 0 {
+				i += len(s)
+			}
+			if i < 0 || i >= len(s) {
+				return nil, nil
+			}
+			return s[i], nil
+		}
+	case nil:
+		if source == nil {
+			return nil, nil
+		}
+	}
+	return nil, raise("Cannot index %s with %s", typeName(source), typeName(key))
+}
+
+type sliceNode struct {
+	source, from, to node
+}
+
+func (n sliceNode) eval(input interface{}, e *env) ([]interface{}, error) {
+	sources, err := n.source.eval(input, e)
+	if err != nil {
+		return nil, err
+	}
+	froms, tos := []interface{}{nil}, []interface{}{nil}
+	if n.from != nil {
+		if froms, err = n.from.eval(input, e); err != nil {
+			return nil, err
+		}
+	}
+	if n.to != nil {
+		if tos, err = n.to.eval(input, e); err != nil {
+			return nil, err
+		}
+	}
+
+	var result []interface{}
+	for _, source := range sources {
+		for _, to := range tos {
+			for _, from := range froms {
+				value, err := slice(source, from, to)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, value)
+			}
+		}
+	}
+	return result, nil
+}
+
+func slice(source, from, to interface{}) (interface{}, error) {
+	var length int
+	switch s := source.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		length = len(s)
+	case string:
+		length = len([]rune(s))
+	default:
+		return nil, raise("Cannot index %s with object", typeName(source))
+	}
+
+	bound := func(v interface{}, fallback int, round func(float64) float64) (int, error) {
+		if v == nil {
+			return fallback, nil
+		}
+		f, ok := v.(float64)
+		if !ok {
+			return 0, raise("Start and end indices of an array slice must be numbers")
+		}
+		i := int(round(f))
+		if i < 0 {
+			i += length
+		}
+		return int(math.Max(0, math.Min(float64(length), float64(i)))), nil
+	}
+	start, err := bound(from, 0, math.Floor)
+	if err != nil {
+		return nil, err
+	}
+	end, err := bound(to, length, math.Ceil)
+	if err != nil {
+		return nil, err
+	}
+	if end < start {
+		end = start
+	}
+
+	if s, ok := source.(string); ok {
+		return string([]rune(s)[start:end]), nil
+	}
+	return append([]interface{}{}, source.([]interface{})[start:end]...), nil
+}
+
+type iterateNode struct {
+	source node
+}
+
+func (n iterateNode) eval(input interface{}, e *env) ([]interface{}, error) {
+	sources, err := n.source.eval(input, e)
+	if err != nil {
+		return nil, err
+	}
+	var result []interface{}
+	for _, source := range sources {
+		values, err := iterate(source)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, values...)
+	}
+	return result, nil
+}
+
+// iterate is the values of an array, or of an object in the order of its
+// keys
+func iterate(v interface{}) ([]interface{}, error) {
+	switch value := v.(type) {
+	case []interface{}:
+		return value, nil
+	case map[string]interface{}:
+		result := make([]interface{}, 0, len(value))
+		for _, key := range sortedKeys(value) {
+			result = append(result, value[key])
+		}
+		return result, nil
+	}
+	return nil, raise("Cannot iterate over %s", describeValue(v))
+}
+
+type tryNode struct {
+	body, catch node
+}
+
+func (n tryNode) eval(input interface{}, e *env) ([]interface{}, error) {
+	result, err := n.body.eval(input, e)
+	if err == nil {
+		return result, nil
+	}
+	var raised *queryError
+	if !errors.As(err, &raised) {
+		return nil, err
+	}
+	if n.catch == nil {
+		return nil, nil
+	}
+	return n.catch.eval(raised.value, e)
+}
+
+type arrayNode struct {
+	body node
+}
+
+func (n arrayNode) eval(input interface{}, e *env) ([]interface{}, error) {
+	if n.body == nil {
+		return []interface{}{[]interface{}{}}, nil
+	}
+	values, err := n.body.eval(input, e)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{append([]interface{}{}, values...)}, nil
+}
+
+type objectEntry struct {
+	key, value node
+}
+
+type objectNode struct {
+	entries []objectEntry
+}
+
+// eval builds an object for every combination of the outputs of the keys
+// and values
+func (n objectNode) eval(input interface{}, e *env) ([]interface{}, error) {
+	objects := []map[string]interface{}{{}}
+	for _, entry := range n.entries {
+		keys, err := entry.key.eval(input, e)
+		if err != nil {
+			return nil, err
+		}
+		values, err := entry.value.eval(input, e)
+		if err != nil {
+			return nil, err
+		}
+
+		var next []map[string]interface{}
+		for _, object := range objects {
+			for _, key := range keys {
+				k, ok := key.(string)
+				if !ok {
+					return nil, raise("Object keys must be strings, not %s", typeName(key))
+				}
+				for _, value := range values {
+					extended := make(map[string]interface{}, len(object)+1)
+					for ok, ov := range object {
+						extended[ok] = ov
+					}
+					extended[k] = value
+					next = append(next, extended)
+				}
+			}
+		}
+		objects = next
+	}
+
+	result := make([]interface{}, len(objects))
+	for i, object := range objects {
+		result[i] = object
+	}
+	return result, nil
+}
+
+type interpolationNode struct {
+	parts []node
+}
+
+func (n interpolationNode) eval(input interface{}, e *env) ([]interface{}, error) {
+	results := []string{""}
+	for _, part := range n.parts {
+		values, err := part.eval(input, e)
+		if err != nil {
+			return nil, err
+		}
+		var next []string
+		for _, value := range values {
+			text := toText(value)
+			for _, prefix := range results {
+				next = append(next, prefix+text)
+			}
+		}
+		results = next
+	}
+
+	output := make([]interface{}, len(results))
+	for i, r := range results {
+		output[i] = r
+	}
+	return output, nil
+}
+
+type negateNode struct {
+	body node
+}
+
+func (n negateNode) eval(input interface{}, e *env) ([]interface{}, error) {
+	values, err := n.body.eval(input, e)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, len(values))
+	for i, value := range values {
+		f, ok := value.(float64)
+		if !ok {
+			return nil, raise("%s cannot be negated", describeValue(value))
+		}
+		result[i] = -f
+	}
+	return result, nil
+}
+
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+// eval combines every output of the right with every output of the left,
+// the right varying slowest as in jq
+func (n binaryNode) eval(input interface{}, e *env) ([]interface{}, error) {
+	rights, err := n.right.eval(input, e)
+	if err != nil {
+		return nil, err
+	}
+	lefts, err := n.left.eval(input, e)
+	if err != nil {
+		return nil, err
+	}
+	var result []interface{}
+	for _, right := range rights {
+		for _, left := range lefts {
+			value, err := binary(n.op, left, right)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, value)
+		}
+	}
+	return result, nil
+}
+
+func binary(op string, left, right interface{}) (interface{}, error) {
+	switch op {
+	case "==":
+		return compare(left, right) == 0, nil
+	case "!=":
+		return compare(left, right) != 0, nil
+	case "<":
+		return compare(left, right) < 0, nil
+	case "<=":
+		return compare(left, right) <= 0, nil
+	case ">":
+		return compare(left, right) > 0, nil
+	case ">=":
+		return compare(left, right) >= 0, nil
+	case "+":
+		return add(left, right)
+	case "-":
+		return subtract(left, right)
+	case "*":
+		return multiply(left, right)
+	case "/":
+		return divide(left, right)
+	}
+	return modulo(left, right)
+}
+
+func add(left, right interface{}) (interface{}, error) {
+	if left == nil {
+		return right, nil
+	}
+	if right == nil {
+		return left, nil
+	}
+	switch l := left.(type) {
+	case float64:
+		if r, ok := right.(float64); ok {
+			return l + r, nil
+		}
+	case string:
+		if r, ok := right.(string); ok {
+			return l + r, nil
+		}
+	case []interface{}:
+		if r, ok := right.([]interface{}); ok {
+			return append(append([]interface{}{}, l...), r...), nil
+		}
+	case map[string]interface{}:
+		if r, ok := right.(map[string]interface{}); ok {
+			merged := make(map[string]interface{}, len(l)+len(r))
+			for k, v := range l {
+				merged[k] = v
+			}
+			for k, v := range r {
+				merged[k] = v
+			}
+			return merged, nil
+		}
+	}
+	return nil, raise("%s and %s cannot be added", describeValue(left), describeValue(right))
+}
+
+func subtract(left, right interface{}) (interface{}, error) {
+	switch l := left.(type) {
+	case float64:
+		if r, ok := right.(float64); ok {
+			return l - r, nil
+		}
+	case []interface{}:
+		if r, ok := right.([]interface{}); ok {
+			result := []interface{}{}
+			for _, v := range l {
+				if !containsValue(r, v) {
+					result = append(result, v)
+				}
+			}
+			return result, nil
+		}
+	}
+	return nil, raise("%s and %s cannot be subtracted", describeValue(left), describeValue(right))
+}
+
+func multiply(left, right interface{}) (interface{}, error) {
+	switch l := left.(type) {
+	case float64:
+		switch r := right.(type) {
+		case float64:
+			return l * r, nil
+		case string:
+			return repeat(r, l), nil
+		}
+	case string:
+		if r, ok := right.(float64); ok {
+			return repeat(l, r), nil
+		}
+	case map[string]interface{}:
+		if r, ok := right.(map[string]interface{}); ok {
+			return deepMerge(l, r), nil
+		}
+	}
+	return nil, raise("%s and %s cannot be multiplied", describeValue(left), describeValue(right))
+}
+
+func repeat(s string, times float64) interface{} {
+	if times <= 0 {
+		return nil
+	}
+	return strings.Repeat(s, int(math.Ceil(times)))
+}
+
+func deepMerge(left, right map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(left)+len(right))
+	for k, v := range left {
+		merged[k] = v
+	}
+	for k, v := range right {
+		lo, lok := merged[k].(map[string]interface{})
+		ro, rok := v.(map[string]interface{})
+		if lok && rok {
+			merged[k] = deepMerge(lo, ro)
+		} else {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+func divide(left, right interface{}) (interface{}, error) {
+	switch l := left.(type) {
+	case float64:
+		if r, ok := right.(float64); ok {
+			if r == 0 {
+				return nil, raise("%s and %s cannot be divided because the divisor is zero", describeValue(left), describeValue(right))
+			}
+			return l / r, nil
+		}
+	case string:
+		if r, ok := right.(string); ok {
+			return split(l, r), nil
+		}
+	}
+	return nil, raise("%s and %s cannot be divided", describeValue(left), describeValue(right))
+}
+
+func modulo(left, right interface{}) (interface{}, error) {
+	l, lok := left.(float64)
+	r, rok := right.(float64)
+	if !lok || !rok {
+		return nil, raise("%s and %s cannot be divided", describeValue(left), describeValue(right))
+	}
+	if int64(r) == 0 {
+		return nil, raise("%s and %s cannot be divided because the divisor is zero", describeValue(left), describeValue(right))
+	}
+	return float64(int64(l) % int64(math.Abs(r))), nil
+}
+
+type logicalNode struct {
+	and         bool
+	left, right node
+}
+
+func (n logicalNode) eval(input interface{}, e *env) ([]interface{}, error) {
+	lefts, err := n.left.eval(input, e)
+	if err != nil {
+		return nil, err
+	}
+	var result []interface{}
+	for _, left := range lefts {
+		if truthy(left) != n.and {
+			result = append(result, !n.and)
+			continue
+		}
+		rights, err := n.right.eval(input, e)
+		if err != nil {
+			return nil, err
+		}
+		for _, right := range rights {
+			result = append(result, truthy(right))
+		}
+	}
+	return result, nil
+}
+
+type alternativeNode struct {
+	left, right node
+}
+
+func (n alternativeNode) eval(input interface{}, e *env) ([]interface{}, error) {
+	var result []interface{}
+	lefts, err := n.left.eval(input, e)
+	var raised *queryError
+	if err != nil && !errors.As(err, &raised) {
+		return nil, err
+	}
+	for _, left := range lefts {
+		if truthy(left) {
+			result = append(result, left)
+		}
+	}
+	if len(result) > 0 {
+		return result, nil
+	}
+	return n.right.eval(input, e)
+}
+
+type ifNode struct {
+	cond, then, otherwise node
+}
+
+func (n ifNode) eval(input interface{}, e *env) ([]interface{}, error) {
+	conds, err := n.cond.eval(input, e)
+	if err != nil {
+		return nil, err
+	}
+	var result []interface{}
+	for _, cond := range conds {
+		branch := n.otherwise
+		if truthy(cond) {
+			branch = n.then
+		}
+		values, err := branch.eval(input, e)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, values...)
+	}
+	return result, nil
+}
+
+type bindNode struct {
+	source node
+	name   string
+	body   node
+}
+
+func (n bindNode) eval(input interface{}, e *env) ([]interface{}, error) {
+	values, err := n.source.eval(input, e)
+	if err != nil {
+		return nil, err
+	}
+	var result []interface{}
+	for _, value := range values {
+		outputs, err := n.body.eval(input, e.bind(n.name, value))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, outputs...)
+	}
+	return result, nil
+}
+
+type reduceNode struct {
+	source       node
+	name         string
+	init, update node
+}
+
+func (n reduceNode) eval(input interface{}, e *env) ([]interface{}, error) {
+	values, err := n.source.eval(input, e)
+	if err != nil {
+		return nil, err
+	}
+	accumulators, err := n.init.eval(input, e)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []interface{}
+	for _, accumulator := range accumulators {
+		current := []interface{}{accumulator}
+		for _, value := range values {
+			if len(current) == 0 {
+				break
+			}
+			// jq carries on with the last output of the update
+			outputs, err := n.update.eval(current[len(current)-1], e.bind(n.name, value))
+			if err != nil {
+				return nil, err
+			}
+			current = outputs
+		}
+		if len(current) > 0 {
+			result = append(result, current[len(current)-1])
+		}
+	}
+	return result, nil
+}
+
+type foreachNode struct {
+	source                node
+	name                  string
+	init, update, extract node
+}
+
+func (n foreachNode) eval(input interface{}, e *env) ([]interface{}, error) {
+	values, err := n.source.eval(input, e)
+	if err != nil {
+		return nil, err
+	}
+	accumulators, err := n.init.eval(input, e)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []interface{}
+	for _, accumulator := range accumulators {
+		current := []interface{}{accumulator}
+		for _, value := range values {
+			bound := e.bind(n.name, value)
+			var next []interface{}
+			for _, state := range current {
+				outputs, err := n.update.eval(state, bound)
+				if err != nil {
+					return nil, err
+				}
+				for _, output := range outputs {
+					if n.extract == nil {
+						result = append(result, output)
+						continue
+					}
+					extracted, err := n.extract.eval(output, bound)
+					if err != nil {
+						return nil, err
+					}
+					result = append(result, extracted...)
+				}
+				next = append(next, outputs...)
+			}
+			if len(next) == 0 {
+				break
+			}
+			current = next[len(next)-1:]
+		}
+	}
+	return result, nil
+}
+
+type formatNode struct {
+	name string
+}
+
+func (n formatNode) eval(input interface{}, _ *env) ([]interface{}, error) {
+	value, err := formats[n.name](input)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{value}, nil
+}
+
+type callNode struct {
+	name string
+	args []node
+}
+
+func (n callNode) eval(input interface{}, e *env) ([]interface{}, error) {
+	return builtins[n.name](input, n.args, e)
+}
+
+func truthy(v interface{}) bool {
+	return v != nil && v != false
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+// describeValue names a value in errors the way jq does, with a shortened
+// copy of it
+func describeValue(v interface{}) string {
+	text := toJSON(v)
+	if len(text) > 11 {
+		text = text[:10] + "..."
+	}
+	return fmt.Sprintf("%s (%s)", typeName(v), text)
+}
+
+func typeOrder(v interface{}) int {
+	switch value := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if !value {
+			return 1
+		}
+		return 2
+	case float64:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	}
+	return 6
+}
+
+// compare orders values as jq does: null, false, true, numbers, strings,
+// arrays and then objects
+func compare(a, b interface{}) int {
+	if oa, ob := typeOrder(a), typeOrder(b); oa != ob {
+		return oa - ob
+	}
+	switch av := a.(type) {
+	case float64:
+		bv := b.(float64)
+		switch {
+		case av < bv:
+			return -1
+		case av > bv:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(av, b.(string))
+	case []interface{}:
+		bv := b.([]interface{})
+		for i := 0; i < len(av) && i < len(bv); i++ {
+			if c := compare(av[i], bv[i]); c != 0 {
+				return c
+			}
+		}
+		return len(av) - len(bv)
+	case map[string]interface{}:
+		bv := b.(map[string]interface{})
+		ak, bk := sortedKeys(av), sortedKeys(bv)
+		if c := compare(stringsToValues(ak), stringsToValues(bk)); c != 0 {
+			return c
+		}
+		for _, key := range ak {
+			if c := compare(av[key], bv[key]); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
+
+func containsValue(values []interface{}, v interface{}) bool {
+	for _, value := range values {
+		if compare(value, v) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func stringsToValues(ss []string) []interface{} {
+	values := make([]interface{}, len(ss))
+	for i, s := range ss {
+		values[i] = s
+	}
+	return values
+}
+
+func split(s, separator string) []interface{} {
+	if s == "" {
+		return []interface{}{}
+	}
+	return stringsToValues(strings.Split(s, separator))
+}
+
+func toJSON(v interface{}) string {
+	var buffer strings.Builder
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSuffix(buffer.String(), "\n")
+}
+
+// toText is a value as text: strings as they are, and everything else as
+// JSON
+func toText(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return toJSON(v)
+}
@@ -0,0 +1,269 @@
+package query
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	eofToken tokenKind = iota
+	punctToken
+	fieldToken
+	identToken
+	variableToken
+	numberToken
+	stringToken
+	formatToken
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	number float64
+	parts  []stringPart
+	pos    int
+}
+
+// stringPart is literal text, or the source of an interpolated \(...)
+// expression
+type stringPart struct {
+	literal string
+	source  string
+	isExpr  bool
+}
+
+var punctuation = []string{"..", "==", "!=", "<=", ">=", "//", ".", "[", "]", "{", "}", "(", ")", "|", ",", ":", ";", "?", "+", "-", "*", "/", "%", "<", ">"}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for {
+		i = skipSpace(src, i)
+		if i >= len(src) {
+			return append(tokens, token{kind: eofToken, pos: i}), nil
+		}
+
+		c := src[i]
+		switch {
+		case c == '"':
+			parts, end, err := lexString(src, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: stringToken, parts: parts, pos: i})
+			i = end
+		case c == '.' && i+1 < len(src) && isIdentStart(src[i+1]):
+			end := identEnd(src, i+1)
+			tokens = append(tokens, token{kind: fieldToken, text: src[i+1 : end], pos: i})
+			i = end
+		case isDigit(c) || (c == '.' && i+1 < len(src) && isDigit(src[i+1])):
+			end := numberEnd(src, i)
+			number, err := strconv.ParseFloat(src[i:end], 64)
+			if err != nil {
+				return nil, syntaxError(src, i, "invalid number %q", src[i:end])
+			}
+			tokens = append(tokens, token{kind: numberToken, number: number, text: src[i:end], pos: i})
+			i = end
+		case c == '$' || c == '@':
+			if i+1 >= len(src) || !isIdentStart(src[i+1]) {
+				return nil, syntaxError(src, i, "expected a name after %q", string(c))
+			}
+			end := identEnd(src, i+1)
+			kind := variableToken
+			if c == '@' {
+				kind = formatToken
+			}
+			tokens = append(tokens, token{kind: kind, text: src[i+1 : end], pos: i})
+			i = end
+		case isIdentStart(c):
+			end := identEnd(src, i)
+			tokens = append(tokens, token{kind: identToken, text: src[i:end], pos: i})
+			i = end
+		default:
+			p := matchPunctuation(src[i:])
+			if p == "" {
+				if c == '=' || (i+1 < len(src) && src[i+1] == '=') {
+					return nil, syntaxError(src, i, "assignment is not supported")
+				}
+				return nil, syntaxError(src, i, "unexpected character %q", string(c))
+			}
+			if (p == "|" || p == "+" || p == "-" || p == "*" || p == "/" || p == "%" || p == "//") && strings.HasPrefix(src[i+len(p):], "=") {
+				return nil, syntaxError(src, i, "assignment is not supported")
+			}
+			tokens = append(tokens, token{kind: punctToken, text: p, pos: i})
+			i += len(p)
+		}
+	}
+}
+
+func skipSpace(src string, i int) int {
+	for i < len(src) {
+		switch src[i] {
+		case ' ', '\t', '\n', '\r':
+			i++
+		case '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+func matchPunctuation(s string) string {
+	for _, p := range punctuation {
+		if strings.HasPrefix(s, p) {
+			return p
+		}
+	}
+	return ""
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func identEnd(src string, i int) int {
+	for i < len(src) && (isIdentStart(src[i]) || isDigit(src[i])) {
+		i++
+	}
+	return i
+}
+
+func numberEnd(src string, i int) int {
+	for i < len(src) && isDigit(src[i]) {
+		i++
+	}
+	if i < len(src) && src[i] == '.' {
+		i++
+		for i < len(src) && isDigit(src[i]) {
+			i++
+		}
+	}
+	if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+		j := i + 1
+		if j < len(src) && (src[j] == '+' || src[j] == '-') {
+			j++
+		}
+		if j < len(src) && isDigit(src[j]) {
+			i = j
+			for i < len(src) && isDigit(src[i]) {
+				i++
+			}
+		}
+	}
+	return i
+}
+
+// lexString reads the string starting at the quote at start, returning its
+// parts and the position after the closing quote
+func lexString(src string, start int) ([]stringPart, int, error) {
+	var parts []stringPart
+	var literal strings.Builder
+	i := start + 1
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == '"':
+			if literal.Len() > 0 || len(parts) == 0 {
+				parts = append(parts, stringPart{literal: literal.String()})
+			}
+			return parts, i + 1, nil
+		case c == '\\':
+			if i+1 >= len(src) {
+				return nil, 0, syntaxError(src, i, "unterminated string")
+			}
+			escape := src[i+1]
+			switch escape {
+			case '(':
+				end, err := matchParen(src, i+2)
+				if err != nil {
+					return nil, 0, err
+				}
+				if literal.Len() > 0 {
+					parts = append(parts, stringPart{literal: literal.String()})
+					literal.Reset()
+				}
+				parts = append(parts, stringPart{source: src[i+2 : end], isExpr: true})
+				i = end + 1
+			case 'u':
+				r, end, err := lexUnicodeEscape(src, i)
+				if err != nil {
+					return nil, 0, err
+				}
+				literal.WriteRune(r)
+				i = end
+			default:
+				unescaped, ok := map[byte]string{'"': `"`, '\\': `\`, '/': "/", 'b': "\b", 'f': "\f", 'n': "\n", 'r': "\r", 't': "\t"}[escape]
+				if !ok {
+					return nil, 0, syntaxError(src, i, "invalid escape %q", src[i:i+2])
+				}
+				literal.WriteString(unescaped)
+				i += 2
+			}
+		default:
+			r, size := utf8.DecodeRuneInString(src[i:])
+			literal.WriteRune(r)
+			i += size
+		}
+	}
+	return nil, 0, syntaxError(src, start, "unterminated string")
+}
+
+func lexUnicodeEscape(src string, i int) (rune, int, error) {
+	readHex := func(at int) (rune, bool) {
+		if at+6 > len(src) || src[at] != '\\' || src[at+1] != 'u' {
+			return 0, false
+		}
+		value, err := strconv.ParseUint(src[at+2:at+6], 16, 32)
+		return rune(value), err == nil
+	}
+
+	r, ok := readHex(i)
+	if !ok {
+		return 0, 0, syntaxError(src, i, "invalid unicode escape")
+	}
+	if utf16.IsSurrogate(r) {
+		if low, ok := readHex(i + 6); ok {
+			return utf16.DecodeRune(r, low), i + 12, nil
+		}
+	}
+	return r, i + 6, nil
+}
+
+// matchParen finds the parenthesis closing an interpolation which starts at
+// i, skipping the strings nested in it
+func matchParen(src string, i int) (int, error) {
+	start := i
+	depth := 1
+	for i < len(src) {
+		switch src[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		case '"':
+			_, end, err := lexString(src, i)
+			if err != nil {
+				return 0, err
+			}
+			i = end
+			continue
+		}
+		i++
+	}
+	return 0, syntaxError(src, start, "unterminated interpolation")
+}
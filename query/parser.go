@@ -0,0 +1,674 @@
+package query
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+const InvalidQueryErrorFormat = "invalid query at column %d: %s"
+
+var keywords = map[string]bool{
+	"if": true, "then": true, "elif": true, "else": true, "end": true,
+	"as": true, "reduce": true, "foreach": true, "try": true, "catch": true,
+	"and": true, "or": true, "def": true, "label": true, "import": true, "include": true,
+}
+
+func syntaxError(_ string, pos int, format string, args ...interface{}) error {
+	return errors.Errorf(InvalidQueryErrorFormat, pos+1, fmt.Sprintf(format, args...))
+}
+
+type parser struct {
+	src    string
+	tokens []token
+	pos    int
+	offset int
+}
+
+// parse reads an expression. Interpolated strings are parsed on their own,
+// so offset places their errors within the whole query.
+func parse(src string, offset int) (node, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: src, tokens: tokens, offset: offset}
+	n, err := p.parsePipe(false)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != eofToken {
+		return nil, p.unexpected(t)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != eofToken {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isPunct(text string) bool {
+	t := p.peek()
+	return t.kind == punctToken && t.text == text
+}
+
+func (p *parser) isKeyword(text string) bool {
+	t := p.peek()
+	return t.kind == identToken && t.text == text
+}
+
+func (p *parser) expectPunct(text string) error {
+	if !p.isPunct(text) {
+		return p.expected(fmt.Sprintf("%q", text))
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) expectKeyword(text string) error {
+	if !p.isKeyword(text) {
+		return p.expected(text)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) errorAt(t token, format string, args ...interface{}) error {
+	return syntaxError(p.src, p.offset+t.pos, format, args...)
+}
+
+func (p *parser) expected(what string) error {
+	t := p.peek()
+	if t.kind == eofToken {
+		return p.errorAt(t, "expected %s, found the end of the query", what)
+	}
+	return p.errorAt(t, "expected %s, found %s", what, describe(t))
+}
+
+func (p *parser) unexpected(t token) error {
+	if t.kind == eofToken {
+		return p.errorAt(t, "unexpected end of the query")
+	}
+	return p.errorAt(t, "unexpected %s", describe(t))
+}
+
+func describe(t token) string {
+	switch t.kind {
+	case fieldToken:
+		return fmt.Sprintf("%q", "."+t.text)
+	case variableToken:
+		return fmt.Sprintf("%q", "$"+t.text)
+	case formatToken:
+		return fmt.Sprintf("%q", "@"+t.text)
+	case numberToken:
+		return fmt.Sprintf("%q", t.text)
+	case stringToken:
+		return "a string"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// parsePipe reads pipes of comma separated expressions. Object values may
+// not hold a bare comma, which separates the entries.
+func (p *parser) parsePipe(noComma bool) (node, error) {
+	var left node
+	var err error
+	if noComma {
+		left, err = p.parseAlternative()
+	} else {
+		left, err = p.parseComma()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !p.isPunct("|") {
+		return left, nil
+	}
+	p.next()
+	right, err := p.parsePipe(noComma)
+	if err != nil {
+		return nil, err
+	}
+	return pipeNode{left: left, right: right}, nil
+}
+
+func (p *parser) parseComma() (node, error) {
+	left, err := p.parseAlternative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isPunct(",") {
+		p.next()
+		right, err := p.parseAlternative()
+		if err != nil {
+			return nil, err
+		}
+		left = commaNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAlternative() (node, error) {
+	left, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isPunct("//") {
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseAlternative()
+	if err != nil {
+		return nil, err
+	}
+	return alternativeNode{left: left, right: right}, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("and") {
+		p.next()
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<", "<=", ">", ">="} {
+		if p.isPunct(op) {
+			p.next()
+			right, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			return binaryNode{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAdditive() (node, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isPunct("+") || p.isPunct("-") {
+		op := p.next().text
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseMultiplicative() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isPunct("*") || p.isPunct("/") || p.isPunct("%") {
+		op := p.next().text
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isPunct("-") {
+		p.next()
+		body, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return negateNode{body: body}, nil
+	}
+	return p.parsePostfix(true)
+}
+
+// parsePostfix reads a term followed by indexes, iterations and optional
+// markers. The source of reduce and foreach stops before their "as".
+func (p *parser) parsePostfix(allowAs bool) (node, error) {
+	term, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == fieldToken:
+			p.next()
+			term = indexNode{source: term, key: literalNode{value: t.text}}
+		case t.kind == punctToken && t.text == ".":
+			p.next()
+			switch {
+			case p.peek().kind == stringToken:
+				key, err := p.parseString(p.next())
+				if err != nil {
+					return nil, err
+				}
+				term = indexNode{source: term, key: key}
+			case p.isPunct("["):
+				if term, err = p.parseBracketSuffix(term); err != nil {
+					return nil, err
+				}
+			default:
+				return nil, p.expected("a field name after \".\"")
+			}
+		case t.kind == punctToken && t.text == "[":
+			if term, err = p.parseBracketSuffix(term); err != nil {
+				return nil, err
+			}
+		case t.kind == punctToken && t.text == "?":
+			p.next()
+			term = tryNode{body: term}
+		case allowAs && t.kind == identToken && t.text == "as":
+			p.next()
+			name, err := p.parseVariableName()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct("|"); err != nil {
+				return nil, err
+			}
+			body, err := p.parsePipe(false)
+			if err != nil {
+				return nil, err
+			}
+			return bindNode{source: term, name: name, body: body}, nil
+		default:
+			return term, nil
+		}
+	}
+}
+
+func (p *parser) parseBracketSuffix(source node) (node, error) {
+	p.next()
+	if p.isPunct("]") {
+		p.next()
+		return iterateNode{source: source}, nil
+	}
+
+	var from, to node
+	var err error
+	if !p.isPunct(":") {
+		if from, err = p.parsePipe(false); err != nil {
+			return nil, err
+		}
+	}
+	if !p.isPunct(":") {
+		if err := p.expectPunct("]"); err != nil {
+			return nil, err
+		}
+		return indexNode{source: source, key: from}, nil
+	}
+
+	p.next()
+	if !p.isPunct("]") {
+		if to, err = p.parsePipe(false); err != nil {
+			return nil, err
+		}
+	}
+	if from == nil && to == nil {
+		return nil, p.expected("a slice index")
+	}
+	if err := p.expectPunct("]"); err != nil {
+		return nil, err
+	}
+	return sliceNode{source: source, from: from, to: to}, nil
+}
+
+func (p *parser) parseVariableName() (string, error) {
+	t := p.peek()
+	if t.kind == punctToken && (t.text == "[" || t.text == "{") {
+		return "", p.errorAt(t, "destructuring is not supported, bind a variable such as $name and index it")
+	}
+	if t.kind != variableToken {
+		return "", p.expected("a variable such as $name")
+	}
+	p.next()
+	return t.text, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case numberToken:
+		return literalNode{value: t.number}, nil
+	case stringToken:
+		return p.parseString(t)
+	case fieldToken:
+		return indexNode{source: identityNode{}, key: literalNode{value: t.text}}, nil
+	case variableToken:
+		return variableNode{name: t.text}, nil
+	case formatToken:
+		if p.peek().kind == stringToken {
+			return nil, p.errorAt(p.peek(), "format strings are not supported, pipe the value to @%s instead", t.text)
+		}
+		if _, ok := formats[t.text]; !ok {
+			return nil, p.errorAt(t, "unknown format @%s", t.text)
+		}
+		return formatNode{name: t.text}, nil
+	case punctToken:
+		switch t.text {
+		case ".":
+			if p.peek().kind == stringToken {
+				key, err := p.parseString(p.next())
+				if err != nil {
+					return nil, err
+				}
+				return indexNode{source: identityNode{}, key: key}, nil
+			}
+			return identityNode{}, nil
+		case "..":
+			return recurseNode{}, nil
+		case "(":
+			body, err := p.parsePipe(false)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct(")"); err != nil {
+				return nil, err
+			}
+			return body, nil
+		case "[":
+			if p.isPunct("]") {
+				p.next()
+				return arrayNode{}, nil
+			}
+			body, err := p.parsePipe(false)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct("]"); err != nil {
+				return nil, err
+			}
+			return arrayNode{body: body}, nil
+		case "{":
+			return p.parseObject()
+		}
+	case identToken:
+		switch t.text {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		case "null":
+			return literalNode{value: nil}, nil
+		case "if":
+			return p.parseIf()
+		case "try":
+			return p.parseTry()
+		case "reduce":
+			return p.parseReduce()
+		case "foreach":
+			return p.parseForeach()
+		case "def", "label", "import", "include":
+			return nil, p.errorAt(t, "%q is not supported", t.text)
+		}
+		if keywords[t.text] {
+			return nil, p.unexpected(t)
+		}
+		return p.parseCall(t)
+	}
+	return nil, p.unexpected(t)
+}
+
+func (p *parser) parseString(t token) (node, error) {
+	if len(t.parts) == 1 && !t.parts[0].isExpr {
+		return literalNode{value: t.parts[0].literal}, nil
+	}
+
+	var parts []node
+	for _, part := range t.parts {
+		if !part.isExpr {
+			parts = append(parts, literalNode{value: part.literal})
+			continue
+		}
+		n, err := parse(part.source, p.offset+t.pos)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, n)
+	}
+	return interpolationNode{parts: parts}, nil
+}
+
+func (p *parser) parseObject() (node, error) {
+	var entries []objectEntry
+	for !p.isPunct("}") {
+		entry, err := p.parseObjectEntry()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+		if !p.isPunct(",") {
+			break
+		}
+		p.next()
+	}
+	if err := p.expectPunct("}"); err != nil {
+		return nil, err
+	}
+	return objectNode{entries: entries}, nil
+}
+
+func (p *parser) parseObjectEntry() (objectEntry, error) {
+	t := p.next()
+	var key node
+	switch {
+	case t.kind == variableToken:
+		return objectEntry{key: literalNode{value: t.text}, value: variableNode{name: t.text}}, nil
+	case t.kind == identToken:
+		key = literalNode{value: t.text}
+	case t.kind == stringToken:
+		var err error
+		if key, err = p.parseString(t); err != nil {
+			return objectEntry{}, err
+		}
+	case t.kind == punctToken && t.text == "(":
+		var err error
+		if key, err = p.parsePipe(false); err != nil {
+			return objectEntry{}, err
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return objectEntry{}, err
+		}
+		if !p.isPunct(":") {
+			return objectEntry{}, p.expected(`":"`)
+		}
+	default:
+		return objectEntry{}, p.errorAt(t, "expected an object key, found %s", describe(t))
+	}
+
+	if !p.isPunct(":") {
+		return objectEntry{key: key, value: indexNode{source: identityNode{}, key: key}}, nil
+	}
+	p.next()
+	value, err := p.parsePipe(true)
+	if err != nil {
+		return objectEntry{}, err
+	}
+	return objectEntry{key: key, value: value}, nil
+}
+
+func (p *parser) parseIf() (node, error) {
+	cond, err := p.parsePipe(false)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("then"); err != nil {
+		return nil, err
+	}
+	then, err := p.parsePipe(false)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.isKeyword("elif"):
+		p.next()
+		otherwise, err := p.parseIf()
+		if err != nil {
+			return nil, err
+		}
+		return ifNode{cond: cond, then: then, otherwise: otherwise}, nil
+	case p.isKeyword("else"):
+		p.next()
+		otherwise, err := p.parsePipe(false)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("end"); err != nil {
+			return nil, err
+		}
+		return ifNode{cond: cond, then: then, otherwise: otherwise}, nil
+	}
+	if err := p.expectKeyword("end"); err != nil {
+		return nil, err
+	}
+	return ifNode{cond: cond, then: then, otherwise: identityNode{}}, nil
+}
+
+func (p *parser) parseTry() (node, error) {
+	body, err := p.parsePostfix(false)
+	if err != nil {
+		return nil, err
+	}
+	if !p.isKeyword("catch") {
+		return tryNode{body: body}, nil
+	}
+	p.next()
+	catch, err := p.parsePostfix(false)
+	if err != nil {
+		return nil, err
+	}
+	return tryNode{body: body, catch: catch}, nil
+}
+
+// parseReduceHead reads the "SOURCE as $name (" common to reduce and foreach
+func (p *parser) parseReduceHead() (node, string, error) {
+	source, err := p.parsePostfix(false)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := p.expectKeyword("as"); err != nil {
+		return nil, "", err
+	}
+	name, err := p.parseVariableName()
+	if err != nil {
+		return nil, "", err
+	}
+	if err := p.expectPunct("("); err != nil {
+		return nil, "", err
+	}
+	return source, name, nil
+}
+
+func (p *parser) parseReduce() (node, error) {
+	source, name, err := p.parseReduceHead()
+	if err != nil {
+		return nil, err
+	}
+	args, err := p.parseArgs(2, 2)
+	if err != nil {
+		return nil, err
+	}
+	return reduceNode{source: source, name: name, init: args[0], update: args[1]}, nil
+}
+
+func (p *parser) parseForeach() (node, error) {
+	source, name, err := p.parseReduceHead()
+	if err != nil {
+		return nil, err
+	}
+	args, err := p.parseArgs(2, 3)
+	if err != nil {
+		return nil, err
+	}
+	n := foreachNode{source: source, name: name, init: args[0], update: args[1]}
+	if len(args) == 3 {
+		n.extract = args[2]
+	}
+	return n, nil
+}
+
+// parseArgs reads arguments separated by semicolons up to the closing
+// parenthesis, the opening one already read
+func (p *parser) parseArgs(min, max int) ([]node, error) {
+	var args []node
+	for {
+		arg, err := p.parsePipe(false)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if !p.isPunct(";") {
+			break
+		}
+		p.next()
+	}
+	if err := p.expectPunct(")"); err != nil {
+		return nil, err
+	}
+	if len(args) < min || len(args) > max {
+		return nil, p.errorAt(p.tokens[p.pos-1], "expected %d to %d arguments, found %d", min, max, len(args))
+	}
+	return args, nil
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	var args []node
+	if p.isPunct("(") {
+		p.next()
+		var err error
+		if args, err = p.parseArgs(1, 3); err != nil {
+			return nil, err
+		}
+	}
+	key := builtinKey(name.text, len(args))
+	if _, ok := builtins[key]; !ok {
+		return nil, p.errorAt(name, "%s is not defined", key)
+	}
+	return callNode{name: key, args: args}, nil
+}
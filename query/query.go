@@ -0,0 +1,35 @@
+package query
+
+import (
+	"sort"
+)
+
+// Query is a parsed jq-style expression
+type Query struct {
+	root node
+}
+
+// Parse reads a jq-style expression, such as .cf.properties | keys
+func Parse(expression string) (*Query, error) {
+	root, err := parse(expression, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Query{root: root}, nil
+}
+
+// Run evaluates the query against input, with variables available to it
+// as $name. Each output of the query is one result.
+func (q *Query) Run(input interface{}, variables map[string]interface{}) ([]interface{}, error) {
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var e *env
+	for _, name := range names {
+		e = e.bind(name, variables[name])
+	}
+	return q.root.eval(input, e)
+}
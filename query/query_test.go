@@ -0,0 +1,119 @@
+package query_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotal-cf/aqueduct-courier/query"
+)
+
+const document = `{
+	"cf": {
+		"properties": {"enabled": true, "name": "cf-1", "instances": [3, 1, 2], "empty": null},
+		"resources": [
+			{"identifier": "router", "instances": 2, "vm_type": "micro"},
+			{"identifier": "diego_cell", "instances": 6, "vm_type": "large"},
+			{"identifier": "uaa", "instances": 1, "vm_type": "micro"}
+		]
+	},
+	"p-bosh": {"certificates": [{"issuer": "CN=root", "expires": "2024-01-02T03:04:05Z"}]},
+	"text": "Diego-Cell router"
+}`
+
+var _ = Describe("Query", func() {
+	run := func(expression string, variables map[string]interface{}) ([]interface{}, error) {
+		var input interface{}
+		Expect(json.Unmarshal([]byte(document), &input)).To(Succeed())
+
+		q, err := Parse(expression)
+		Expect(err).NotTo(HaveOccurred())
+		return q.Run(input, variables)
+	}
+
+	DescribeTable("evaluating expressions like jq",
+		func(expression string, expected ...string) {
+			results, err := run(expression, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			outputs := []string{}
+			for _, result := range results {
+				output, err := json.Marshal(result)
+				Expect(err).NotTo(HaveOccurred())
+				outputs = append(outputs, string(output))
+			}
+			Expect(outputs).To(Equal(append([]string{}, expected...)))
+		},
+		Entry("identity", `.cf.properties.name`, `"cf-1"`),
+		Entry("quoted fields", `."p-bosh".certificates[0].issuer`, `"CN=root"`),
+		Entry("bracketed fields", `.["p-bosh"].certificates[-1].issuer`, `"CN=root"`),
+		Entry("missing fields", `.cf.missing.deeper`, `null`),
+		Entry("optional errors", `.text.field?`),
+		Entry("iteration", `.cf.resources[].identifier`, `"router"`, `"diego_cell"`, `"uaa"`),
+		Entry("object iteration in key order", `[.cf.properties[]]`, `[null,true,[3,1,2],"cf-1"]`),
+		Entry("slices", `.cf.properties.instances[1:]`, `[1,2]`),
+		Entry("comma", `.cf.properties.name, .cf.properties.enabled`, `"cf-1"`, `true`),
+		Entry("arithmetic", `[.cf.resources[].instances] | add * 2 - 1`, `17`),
+		Entry("comparisons", `.cf.resources | map(.instances > 1)`, `[true,true,false]`),
+		Entry("alternatives", `.cf.properties.empty // "none"`, `"none"`),
+		Entry("conditionals", `.cf.resources[] | if .instances > 1 then "ha" elif .instances == 1 then "single" else "none" end`, `"ha"`, `"ha"`, `"single"`),
+		Entry("objects", `.cf.resources[0] | {identifier, count: .instances}`, `{"count":2,"identifier":"router"}`),
+		Entry("string interpolation", `.cf.resources[0] | "\(.identifier) x\(.instances)"`, `"router x2"`),
+		Entry("variables", `.cf.properties.name as $name | .cf.resources | map("\($name)/\(.identifier)")`, `["cf-1/router","cf-1/diego_cell","cf-1/uaa"]`),
+		Entry("reduce", `reduce .cf.resources[] as $r (0; . + $r.instances)`, `9`),
+		Entry("foreach", `[foreach .cf.properties.instances[] as $n (0; . + $n)]`, `[3,4,6]`),
+		Entry("try and catch", `try error("broken") catch "caught: \(.)"`, `"caught: broken"`),
+		Entry("recursion", `[.. | numbers] | add`, `15`),
+		Entry("select and length", `[.cf.resources[] | select(.vm_type == "micro")] | length`, `2`),
+		Entry("sort_by", `.cf.resources | sort_by(.instances) | map(.identifier)`, `["uaa","router","diego_cell"]`),
+		Entry("group_by", `.cf.resources | group_by(.vm_type) | map({(.[0].vm_type): length}) | add`, `{"large":1,"micro":2}`),
+		Entry("unique and min and max", `.cf.properties.instances | [unique, min, max]`, `[[1,2,3],1,3]`),
+		Entry("keys and has", `.cf.properties | [keys, has("name"), has("nothing")]`, `[["empty","enabled","instances","name"],true,false]`),
+		Entry("entries", `.cf.properties | with_entries(select(.value != null)) | to_entries | map(.key)`, `["enabled","instances","name"]`),
+		Entry("array entries", `.cf.properties.instances[:2] | to_entries`, `[{"key":0,"value":3},{"key":1,"value":1}]`),
+		Entry("contains", `.cf.resources | [contains([{"identifier": "uaa"}]), any(.instances > 5), all(.instances > 5)]`, `[true,true,false]`),
+		Entry("paths", `[."p-bosh" | paths]`, `[["certificates"],["certificates",0],["certificates",0,"expires"],["certificates",0,"issuer"]]`),
+		Entry("limit and first", `[limit(2; .cf.resources[].identifier)], first(.cf.resources[].identifier)`, `["router","diego_cell"]`, `"router"`),
+		Entry("ranges", `[range(0; 10; 3)]`, `[0,3,6,9]`),
+		Entry("strings", `.text | [ascii_downcase, (split(" ") | join(",")), startswith("Diego"), ltrimstr("Diego-")]`, `["diego-cell router","Diego-Cell,router",true,"Cell router"]`),
+		Entry("regular expressions", `.text | [test("cell"; "i"), (capture("(?<first>\\w+)-(?<second>\\w+)") | .second), gsub("(?<c>[A-Z])"; "_\(.c)")]`, `[true,"Cell","_Diego-_Cell router"]`),
+		Entry("conversions", `[(1 | tostring), ("2.5" | tonumber), ({"a": [1]} | tojson), ("[1]" | fromjson), (null | type)]`, `["1",2.5,"{\"a\":[1]}",[1],"null"]`),
+		Entry("dates", `."p-bosh".certificates[0].expires | fromdate | ., todate`, `1704164645`, `"2024-01-02T03:04:05Z"`),
+		Entry("formats", `[.cf.properties.name, 1, null] | @csv, @tsv, @sh, (.[0] | @base64)`, `"\"cf-1\",1,"`, `"cf-1\t1\t"`, `"'cf-1' 1 null"`, `"Y2YtMQ=="`),
+		Entry("empty", `.cf.resources[] | empty`),
+	)
+
+	It("makes variables available to the expression", func() {
+		results, err := run(`"\($foundation_nickname): \(.cf.properties.name)"`, map[string]interface{}{"foundation_nickname": "prod"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(Equal([]interface{}{"prod: cf-1"}))
+	})
+
+	It("fails for undefined variables", func() {
+		_, err := run(`$missing`, nil)
+		Expect(err).To(MatchError("$missing is not defined"))
+	})
+
+	It("fails with jq errors", func() {
+		_, err := run(`.cf.resources | .name`, nil)
+		Expect(err).To(MatchError(`Cannot index array with "name"`))
+
+		_, err = run(`.text + 1`, nil)
+		Expect(err).To(MatchError(ContainSubstring("cannot be added")))
+	})
+
+	DescribeTable("rejecting invalid expressions",
+		func(expression, message string) {
+			_, err := Parse(expression)
+			Expect(err).To(MatchError(message))
+		},
+		Entry("unclosed brackets", `.cf[`, "invalid query at column 5: unexpected end of the query"),
+		Entry("unknown functions", `.cf | nonsense`, "invalid query at column 7: nonsense/0 is not defined"),
+		Entry("wrong arity", `map`, "invalid query at column 1: map/0 is not defined"),
+		Entry("assignment", `.cf.name = 1`, "invalid query at column 10: assignment is not supported"),
+		Entry("destructuring", `. as [$a, $b] | $a`, "invalid query at column 6: destructuring is not supported, bind a variable such as $name and index it"),
+		Entry("function definitions", `def f: .; f`, `invalid query at column 1: "def" is not supported`),
+		Entry("unknown formats", `@nonsense`, "invalid query at column 1: unknown format @nonsense"),
+	)
+})
@@ -0,0 +1,283 @@
+package secretscan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Policy is what collection does with a file holding secret-shaped content
+type Policy string
+
+const (
+	FailOnSecrets Policy = "fail"
+	RedactSecrets Policy = "redact"
+	WarnOnSecrets Policy = "warn"
+
+	PrivateKeyKind  = "private key"
+	JWTKind         = "JWT"
+	SecretFieldKind = "password or secret field"
+	AWSKeyKind      = "AWS access key"
+	HighEntropyKind = "high entropy string"
+
+	RedactedValue = "[REDACTED]"
+
+	InvalidPolicyFormat         = "invalid secret detected policy %q, valid policies are: %s"
+	SecretDetectedWarningFormat = "Warning: Possible %s in %s at %s"
+	SecretRedactedWarningFormat = "Warning: Redacted possible %s in %s at %s"
+	SecretsDetectedErrorFormat  = "possible secrets detected in %s, no collection was written:\n%s"
+
+	// Random base64 scores well above 4.5 bits a character, while hex,
+	// GUIDs and words stay below it
+	minimumEntropyLength = 32
+	minimumEntropy       = 4.5
+)
+
+var (
+	policies = []Policy{FailOnSecrets, RedactSecrets, WarnOnSecrets}
+
+	privateKeyPattern  = regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----[\s\S]*?(-----END [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----|$)`)
+	jwtPattern         = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	awsKeyPattern      = regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[0-9A-Z]{16}\b`)
+	entropyPattern     = regexp.MustCompile(`[A-Za-z0-9+/=_-]{32,}`)
+	secretFieldPattern = regexp.MustCompile(`(?i)(password|passwd|passphrase|secret)$`)
+	plainName          = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// placeholders are values services already put in place of a secret
+	placeholders = map[string]bool{"": true, "[redacted]": true, "<redacted>": true, "redacted": true}
+)
+
+// ParsePolicy reads a policy, defaulting to warning, so the collected data is
+// only changed when redacting is asked for
+func ParsePolicy(policy string) (Policy, error) {
+	if policy == "" {
+		return WarnOnSecrets, nil
+	}
+	for _, p := range policies {
+		if Policy(strings.ToLower(policy)) == p {
+			return p, nil
+		}
+	}
+
+	var names []string
+	for _, p := range policies {
+		names = append(names, string(p))
+	}
+	return "", errors.Errorf(InvalidPolicyFormat, policy, strings.Join(names, ", "))
+}
+
+func (p Policy) String() string {
+	return string(p)
+}
+
+// Detection is secret-shaped content found in a file. Path is the JSON path
+// of the value, or the line for content which is not JSON. The value itself
+// is never kept.
+type Detection struct {
+	Kind string
+	Path string
+}
+
+// Scanner is the safety net behind the redaction of each service: it looks
+// at every file before it is written to the collection
+type Scanner struct {
+	logger *log.Logger
+	policy Policy
+}
+
+func NewScanner(logger *log.Logger, policy Policy) *Scanner {
+	return &Scanner{logger: logger, policy: policy}
+}
+
+// Scan returns the contents to write for a file, with the detections
+// redacted when the policy asks. The file and path of each detection are
+// logged, or returned as an error when the policy is to fail.
+func (s *Scanner) Scan(filePath string, contents []byte) ([]byte, error) {
+	detections, redacted, err := Detect(contents)
+	if err != nil {
+		return nil, err
+	}
+	if len(detections) == 0 {
+		return contents, nil
+	}
+
+	switch s.policy {
+	case FailOnSecrets:
+		var lines []string
+		for _, d := range detections {
+			lines = append(lines, fmt.Sprintf("  %s at %s", d.Kind, d.Path))
+		}
+		return nil, errors.Errorf(SecretsDetectedErrorFormat, filePath, strings.Join(lines, "\n"))
+	case WarnOnSecrets:
+		for _, d := range detections {
+			s.logger.Printf(SecretDetectedWarningFormat, d.Kind, filePath, d.Path)
+		}
+		return contents, nil
+	}
+
+	for _, d := range detections {
+		s.logger.Printf(SecretRedactedWarningFormat, d.Kind, filePath, d.Path)
+	}
+	return redacted, nil
+}
+
+// Detect finds the secret-shaped content in a file and returns the file with
+// it redacted. JSON is walked, so fields can be recognized by name and each
+// detection has a JSON path.
+func Detect(contents []byte) ([]Detection, []byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil || decoder.More() {
+		return detectLines(string(contents))
+	}
+
+	var detections []Detection
+	redactedDocument := walk(document, "$", &detections)
+	if len(detections) == 0 {
+		return nil, contents, nil
+	}
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(redactedDocument); err != nil {
+		return nil, nil, err
+	}
+	return detections, bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), nil
+}
+
+func walk(v interface{}, path string, detections *[]Detection) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		result := make(map[string]interface{}, len(value))
+		for _, key := range keys {
+			childPath := fmt.Sprintf("%s[%s]", path, strconv.Quote(key))
+			if plainName.MatchString(key) {
+				childPath = path + "." + key
+			}
+
+			if s, ok := value[key].(string); ok && secretFieldPattern.MatchString(key) && !isPlaceholder(s) {
+				*detections = append(*detections, Detection{Kind: SecretFieldKind, Path: childPath})
+				result[key] = RedactedValue
+				continue
+			}
+			result[key] = walk(value[key], childPath, detections)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, child := range value {
+			result[i] = walk(child, fmt.Sprintf("%s[%d]", path, i), detections)
+		}
+		return result
+	case string:
+		redacted, kinds := redactText(value)
+		for _, kind := range kinds {
+			*detections = append(*detections, Detection{Kind: kind, Path: path})
+		}
+		return redacted
+	}
+	return v
+}
+
+func detectLines(contents string) ([]Detection, []byte, error) {
+	var detections []Detection
+	redacted, _ := redactText(contents)
+
+	// Private keys span lines, so they are reported once at the line they
+	// begin
+	inPrivateKey := false
+	for i, line := range strings.Split(contents, "\n") {
+		if strings.Contains(line, "-----BEGIN ") && strings.Contains(line, "PRIVATE KEY") {
+			detections = append(detections, Detection{Kind: PrivateKeyKind, Path: fmt.Sprintf("line %d", i+1)})
+			inPrivateKey = !strings.Contains(line, "-----END ")
+			continue
+		}
+		if inPrivateKey {
+			inPrivateKey = !strings.Contains(line, "-----END ")
+			continue
+		}
+		_, kinds := redactText(line)
+		for _, kind := range kinds {
+			if kind != PrivateKeyKind {
+				detections = append(detections, Detection{Kind: kind, Path: fmt.Sprintf("line %d", i+1)})
+			}
+		}
+	}
+	return detections, []byte(redacted), nil
+}
+
+// redactText replaces the secret-shaped parts of a string and returns the
+// kinds found, each once
+func redactText(s string) (string, []string) {
+	var kinds []string
+	found := func(kind string) {
+		for _, k := range kinds {
+			if k == kind {
+				return
+			}
+		}
+		kinds = append(kinds, kind)
+	}
+
+	for _, detector := range []struct {
+		kind    string
+		pattern *regexp.Regexp
+		matches func(string) bool
+	}{
+		{PrivateKeyKind, privateKeyPattern, nil},
+		{JWTKind, jwtPattern, nil},
+		{AWSKeyKind, awsKeyPattern, nil},
+		{HighEntropyKind, entropyPattern, isHighEntropy},
+	} {
+		s = detector.pattern.ReplaceAllStringFunc(s, func(match string) string {
+			if detector.matches != nil && !detector.matches(match) {
+				return match
+			}
+			found(detector.kind)
+			return RedactedValue
+		})
+	}
+	return s, kinds
+}
+
+func isPlaceholder(s string) bool {
+	return placeholders[strings.ToLower(strings.TrimSpace(s))] || strings.Trim(s, "*") == ""
+}
+
+// isHighEntropy is true for long strings mixing upper case, lower case and
+// digits with the character spread of random data
+func isHighEntropy(s string) bool {
+	if len(s) < minimumEntropyLength {
+		return false
+	}
+	if !strings.ContainsAny(s, "0123456789") || strings.ToLower(s) == s || strings.ToUpper(s) == s {
+		return false
+	}
+
+	counts := map[rune]int{}
+	for _, r := range s {
+		counts[r]++
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(len(s))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy >= minimumEntropy
+}
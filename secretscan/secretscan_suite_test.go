@@ -0,0 +1,13 @@
+package secretscan_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestSecretscan(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Secretscan Suite")
+}